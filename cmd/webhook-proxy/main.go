@@ -1,13 +1,30 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/loadtest"
 	"github.com/flemzord/webhook-proxy/internal/logger"
+	"github.com/flemzord/webhook-proxy/internal/manifest"
+	"github.com/flemzord/webhook-proxy/internal/mockdest"
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/flemzord/webhook-proxy/internal/selftest"
 	"github.com/flemzord/webhook-proxy/internal/server"
+	"github.com/flemzord/webhook-proxy/pkg/logging"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,19 +39,58 @@ var (
 var exitFunc = os.Exit
 
 func main() {
+	// The selftest command checks a configuration instead of serving it
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		exitFunc(runSelftest(os.Args[2:], os.Stdout))
+		return
+	}
+
+	// The validate command checks a configuration and previews the requests of a sample webhook
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		exitFunc(runValidate(os.Args[2:], os.Stdout))
+		return
+	}
+
+	// The mock-destination command serves a destination for local development
+	if len(os.Args) > 1 && os.Args[1] == "mock-destination" {
+		exitFunc(runMockDestination(os.Args[2:], os.Stdout, server.DefaultHTTPServerFunc))
+		return
+	}
+
+	// The loadtest command drives a running instance and reports its throughput and latencies
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		exitFunc(runLoadtest(os.Args[2:], os.Stdout))
+		return
+	}
+
+	// The config migrate command rewrites a legacy configuration to the current schema
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "migrate" {
+		exitFunc(runConfigMigrate(os.Args[3:], os.Stdout))
+		return
+	}
+
+	// The verify-manifests command checks the signatures and chain of delivery manifests
+	if len(os.Args) > 1 && os.Args[1] == "verify-manifests" {
+		exitFunc(runVerifyManifests(os.Args[2:], os.Stdout))
+		return
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
 	showVersion := flag.Bool("version", false, "Show version information")
+	strictStartup := flag.Bool("strict-startup", false, "Fail to start when a critical destination is unreachable")
 	flag.Parse()
 
 	// Show version information if requested
 	if *showVersion {
 		fmt.Printf("webhook-proxy version %s, commit %s, built at %s\n", version, commit, date)
 		exitFunc(0)
+		return
 	}
 
-	// Initialize logger
+	// Initialize logger and route log/slog through it
 	log := logger.NewLogger()
+	slog.SetDefault(logging.NewSlogLogger(log))
 	log.WithFields(logrus.Fields{
 		"version": version,
 		"commit":  commit,
@@ -51,6 +107,11 @@ func main() {
 		exitFunc(1)
 	}
 
+	// The flag enables strict startup checks on top of the configuration
+	if *strictStartup {
+		cfg.Server.Startup.Strict = true
+	}
+
 	// Configure logger based on config
 	logger.ConfigureLogger(log, cfg.Logging)
 
@@ -64,3 +125,414 @@ func main() {
 		exitFunc(1)
 	}
 }
+
+// runSelftest sends a synthetic webhook through every endpoint of the configuration,
+// with mock destinations, prints the results and returns the exit code
+func runSelftest(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configPath := flags.String("config", "config.yaml", "Path to configuration file")
+	timeout := flags.Duration("timeout", selftest.DefaultTimeout, "Time to wait for the webhooks to reach the destinations")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(out, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	// Only the report is printed; proxy logs would drown it
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	report, err := selftest.Run(cfg, log, *timeout)
+	if err != nil {
+		fmt.Fprintf(out, "Self-test failed: %v\n", err)
+		return 1
+	}
+
+	report.Write(out)
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
+
+// runVerifyManifests verifies the signature of delivery manifest files and that they form
+// an unbroken chain, in the order of their windows, prints their windows and counts, and
+// returns the exit code
+func runVerifyManifests(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("verify-manifests", flag.ContinueOnError)
+	flags.SetOutput(out)
+	algorithm := flags.String("algorithm", manifest.AlgorithmHMAC, "Signing algorithm: hmac-sha256 or ed25519")
+	keyValue := flags.String("key", "", "HMAC secret, or base64 ed25519 public key")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *keyValue == "" || flags.NArg() == 0 {
+		fmt.Fprintln(out, "Usage: webhook-proxy verify-manifests -key KEY [-algorithm ed25519] MANIFEST...")
+		return 2
+	}
+
+	key, err := manifest.ParseVerificationKey(*algorithm, *keyValue)
+	if err != nil {
+		fmt.Fprintf(out, "Invalid key: %v\n", err)
+		return 2
+	}
+
+	// Manifest files are named after their window, so that name order is window order
+	paths := append([]string(nil), flags.Args()...)
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+	files := make([][]byte, len(paths))
+	for i, path := range paths {
+		if files[i], err = os.ReadFile(path); err != nil {
+			fmt.Fprintf(out, "Failed to read manifest: %v\n", err)
+			return 1
+		}
+	}
+
+	manifests, err := manifest.VerifyChain(files, key)
+	for i, m := range manifests {
+		if i > 0 && m.WindowStart.After(manifests[i-1].WindowEnd) {
+			fmt.Fprintf(out, "GAP no manifest from %s to %s\n", manifests[i-1].WindowEnd.Format(time.RFC3339), m.WindowStart.Format(time.RFC3339))
+		}
+		fmt.Fprintf(out, "OK %s %s - %s: %d delivered, %d dead letters", paths[i], m.WindowStart.Format(time.RFC3339), m.WindowEnd.Format(time.RFC3339), m.Delivered, m.DeadLetters)
+		if m.Unreadable > 0 {
+			fmt.Fprintf(out, ", %d unreadable", m.Unreadable)
+		}
+		fmt.Fprintln(out)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "FAIL %s: %v\n", paths[len(manifests)], err)
+		return 1
+	}
+
+	fmt.Fprintf(out, "%d manifests verified\n", len(manifests))
+	return 0
+}
+
+// runConfigMigrate rewrites a configuration written for the legacy config package, or
+// with renamed fields, to the current schema, prints the diff and returns the exit code
+func runConfigMigrate(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("config migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configPath := flags.String("config", "config.yaml", "Path to configuration file")
+	dryRun := flags.Bool("dry-run", false, "Print the diff without rewriting the file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(out, "Failed to read configuration: %v\n", err)
+		return 1
+	}
+
+	migrated, changes, err := config.Migrate(data)
+	if err != nil {
+		fmt.Fprintf(out, "Failed to migrate configuration: %v\n", err)
+		return 1
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(out, "%s already uses the current schema\n", *configPath)
+		return 0
+	}
+
+	writeDiff(out, *configPath, string(data), string(migrated))
+	fmt.Fprintln(out)
+	for _, change := range changes {
+		fmt.Fprintf(out, "- %s\n", change)
+	}
+
+	if *dryRun {
+		return 0
+	}
+	info, err := os.Stat(*configPath)
+	if err != nil {
+		fmt.Fprintf(out, "Failed to rewrite configuration: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(*configPath, migrated, info.Mode().Perm()); err != nil {
+		fmt.Fprintf(out, "Failed to rewrite configuration: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(out, "\nRewrote %s\n", *configPath)
+	return 0
+}
+
+// runMockDestination serves a mock destination printing the requests it receives and
+// answering with the scripted responses, and returns the exit code
+func runMockDestination(args []string, out io.Writer, serve server.HTTPServerFunc) int {
+	flags := flag.NewFlagSet("mock-destination", flag.ContinueOnError)
+	flags.SetOutput(out)
+	host := flags.String("host", "127.0.0.1", "Host to listen on")
+	port := flags.Int("port", 9000, "Port to listen on")
+	status := flags.Int("status", 200, "Status code of the responses")
+	delay := flags.Duration("delay", 0, "Delay before each response")
+	sequence := flags.String("sequence", "", "Responses of the first requests, such as 503,503:2s,200")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	responses, err := mockdest.ParseSequence(*sequence)
+	if err != nil {
+		fmt.Fprintf(out, "Invalid sequence: %v\n", err)
+		return 2
+	}
+	if *status < 100 || *status > 599 {
+		fmt.Fprintf(out, "Invalid status code: %d\n", *status)
+		return 2
+	}
+
+	mock := mockdest.New(mockdest.Config{
+		Default:  mockdest.Response{Status: *status, Delay: *delay},
+		Sequence: responses,
+	}, out)
+
+	addr := fmt.Sprintf("%s:%d", *host, *port)
+	fmt.Fprintf(out, "Mock destination listening on http://%s\n\n", addr)
+	if err := serve(addr, mock); err != nil {
+		fmt.Fprintf(out, "Mock destination failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runValidate loads the configuration and, given a sample webhook and an endpoint, prints
+// the requests its destinations would receive, and returns the exit code
+func runValidate(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configPath := flags.String("config", "config.yaml", "Path to configuration file")
+	samplePath := flags.String("sample", "", "Path to a sample webhook body to run through the endpoint")
+	endpointPath := flags.String("endpoint", "", "Path of the endpoint receiving the sample")
+	contentType := flags.String("content-type", "application/json", "Content type of the sample")
+	headers := map[string]string{}
+	headerFlag(flags, headers, "Header of the sample, as 'Name: value' (repeatable)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (*samplePath == "") != (*endpointPath == "") {
+		fmt.Fprintln(out, "The -sample and -endpoint flags must be used together")
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(out, "Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	if *samplePath == "" {
+		fmt.Fprintf(out, "Configuration is valid: %d endpoints\n", len(cfg.Endpoints))
+		return 0
+	}
+
+	var endpoint *config.EndpointConfig
+	for i := range cfg.Endpoints {
+		if cfg.Endpoints[i].Path == *endpointPath {
+			endpoint = &cfg.Endpoints[i]
+		}
+	}
+	if endpoint == nil {
+		fmt.Fprintf(out, "Unknown endpoint: %s\n", *endpointPath)
+		return 1
+	}
+
+	body, err := os.ReadFile(*samplePath)
+	if err != nil {
+		fmt.Fprintf(out, "Failed to read sample: %v\n", err)
+		return 1
+	}
+	headers["Content-Type"] = *contentType
+
+	// Conversion failures are reported on the output, next to the requests
+	log := logrus.New()
+	log.SetOutput(out)
+	log.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	for _, preview := range proxy.PreviewWebhook(*endpoint, body, headers, log) {
+		writePreview(out, preview)
+	}
+	return 0
+}
+
+// runLoadtest posts webhooks to an endpoint of a running instance at a constant rate, prints
+// the achieved throughput, latency percentiles and error rate, and returns the exit code
+func runLoadtest(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	flags.SetOutput(out)
+	target := flags.String("target", "http://127.0.0.1:8080", "Base URL of the running instance")
+	endpointPath := flags.String("endpoint", "", "Path of the endpoint receiving the webhooks")
+	rate := flags.Int("rate", 100, "Webhooks sent per second")
+	duration := flags.Duration("duration", time.Minute, "Duration of the test")
+	payloadPath := flags.String("payload", "", "Path to the webhook body (default: {})")
+	contentType := flags.String("content-type", "application/json", "Content type of the webhooks")
+	maxInFlight := flags.Int("max-in-flight", loadtest.DefaultMaxInFlight, "Requests waiting for a response at most")
+	timeout := flags.Duration("timeout", loadtest.DefaultTimeout, "Time a request may take")
+	headers := map[string]string{}
+	headerFlag(flags, headers, "Header of the webhooks, as 'Name: value' (repeatable)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *endpointPath == "" {
+		fmt.Fprintln(out, "The -endpoint flag is required")
+		return 2
+	}
+	if *rate <= 0 || *duration <= 0 {
+		fmt.Fprintln(out, "The -rate and -duration flags must be positive")
+		return 2
+	}
+
+	body := []byte("{}")
+	if *payloadPath != "" {
+		var err error
+		if body, err = os.ReadFile(*payloadPath); err != nil {
+			fmt.Fprintf(out, "Failed to read payload: %v\n", err)
+			return 1
+		}
+	}
+	headers["Content-Type"] = *contentType
+
+	// Interrupting the test still prints the report of the webhooks sent so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	url := strings.TrimSuffix(*target, "/") + *endpointPath
+	fmt.Fprintf(out, "Sending %d webhooks/s to %s for %s\n\n", *rate, url, *duration)
+	report := loadtest.Run(ctx, loadtest.Config{
+		URL:         url,
+		Rate:        *rate,
+		Duration:    *duration,
+		Body:        body,
+		Headers:     headers,
+		MaxInFlight: *maxInFlight,
+		Timeout:     *timeout,
+	}, nil)
+
+	report.Write(out)
+	if report.Succeeded == 0 {
+		return 1
+	}
+	return 0
+}
+
+// headerFlag defines the repeatable -header flag, adding each 'Name: value' to headers
+func headerFlag(flags *flag.FlagSet, headers map[string]string, usage string) {
+	flags.Func("header", usage, func(value string) error {
+		name, val, ok := strings.Cut(value, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid header: %q (must be 'Name: value')", value)
+		}
+		headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(val)
+		return nil
+	})
+}
+
+// writePreview prints the request a destination would receive, or why it would not
+func writePreview(out io.Writer, preview proxy.Preview) {
+	fmt.Fprintf(out, "=== %s (%s)\n", preview.Destination.Key(), preview.Destination.Type)
+	if preview.Skipped != "" {
+		fmt.Fprintf(out, "Skipped: %s\n\n", preview.Skipped)
+		return
+	}
+
+	if preview.Method != "" {
+		fmt.Fprintf(out, "%s %s\n", preview.Method, preview.URL)
+	}
+	names := make([]string, 0, len(preview.Header))
+	for name := range preview.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "%s: %s\n", name, strings.Join(preview.Header[name], ", "))
+	}
+
+	// Binary bodies, such as MessagePack or protobuf encodings, are printed as a hex dump
+	if !utf8.Valid(preview.Body) {
+		fmt.Fprintf(out, "\n%s\n", hex.Dump(preview.Body))
+		return
+	}
+	fmt.Fprintf(out, "\n%s\n\n", preview.Body)
+}
+
+// diffContext is the number of unchanged lines shown around the changes of a diff
+const diffContext = 3
+
+// writeDiff prints the line changes from before to after as a unified diff
+func writeDiff(out io.Writer, name, before, after string) {
+	a := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the common subsequence into lines prefixed with ' ', '-' or '+'
+	type line struct {
+		op   byte
+		text string
+		i, j int // lines of a and b before this one
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i], i, j})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", name, name)
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// A hunk spans the changes closer than twice the context, and their context
+		end := start
+		for k := start; k < len(lines) && k <= end+2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		from, to := max(start-diffContext, 0), min(end+diffContext+1, len(lines))
+
+		removed, added := 0, 0
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				removed++
+			}
+			if l.op != '-' {
+				added++
+			}
+		}
+		fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", lines[from].i+1, removed, lines[from].j+1, added)
+		for _, l := range lines[from:to] {
+			fmt.Fprintf(out, "%c%s\n", l.op, l.text)
+		}
+		start = to
+	}
+}
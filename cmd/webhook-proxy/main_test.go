@@ -1,13 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
 	"flag"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/flemzord/webhook-proxy/internal/manifest"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestVersionFlag tests the -version flag
@@ -75,3 +84,185 @@ func TestVersionFlag(t *testing.T) {
 	// Check exit code
 	assert.Equal(t, 0, exitCode, "Expected exit code 0 when version flag is set")
 }
+
+// TestRunSelftest tests the selftest command
+func TestRunSelftest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+endpoints:
+  - path: "/webhook/github"
+    provider: "github"
+    secret: "s3cr3t"
+    destinations:
+      - url: "https://example.com/github-webhook"
+`), 0o600)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, runSelftest([]string{"-config", path, "-timeout", "2s"}, &out))
+	assert.Contains(t, out.String(), "PASS /webhook/github -> https://example.com/github-webhook")
+	assert.Contains(t, out.String(), "1 passed, 0 failed, 0 skipped")
+
+	out.Reset()
+	assert.Equal(t, 1, runSelftest([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, &out))
+	assert.Contains(t, out.String(), "Failed to load configuration")
+}
+
+// TestRunMockDestination tests the mock-destination command
+func TestRunMockDestination(t *testing.T) {
+	var out bytes.Buffer
+	var addr string
+	var handler http.Handler
+	code := runMockDestination([]string{"--port", "9100", "-sequence", "503,200"}, &out, func(a string, h http.Handler) error {
+		addr, handler = a, h
+		return nil
+	})
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "127.0.0.1:9100", addr)
+	assert.Contains(t, out.String(), "Mock destination listening on http://127.0.0.1:9100")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hook", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	assert.Equal(t, 2, runMockDestination([]string{"-sequence", "later"}, &out, nil))
+	assert.Equal(t, 2, runMockDestination([]string{"-status", "42"}, &out, nil))
+}
+
+// TestRunValidate tests the validate command, with and without a sample webhook
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(path, []byte(`
+endpoints:
+  - path: "/webhook/orders"
+    destinations:
+      - url: "https://example.com/orders"
+        headers:
+          X-Source: "proxy"
+      - url: "https://example.com/paid"
+        filters:
+          - xpath: "/order/status"
+            equals: "paid"
+`), 0o600)
+	assert.NoError(t, err)
+
+	sample := filepath.Join(dir, "order.xml")
+	assert.NoError(t, os.WriteFile(sample, []byte(`<order><status>pending</status></order>`), 0o600))
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, runValidate([]string{"-config", path}, &out))
+	assert.Equal(t, "Configuration is valid: 1 endpoints\n", out.String())
+
+	out.Reset()
+	code := runValidate([]string{"-config", path, "-sample", sample, "-endpoint", "/webhook/orders",
+		"-content-type", "application/xml", "-header", "x-github-event: push"}, &out)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out.String(), "=== https://example.com/orders (http)\nPOST https://example.com/orders\n")
+	assert.Contains(t, out.String(), "Content-Type: application/xml\n")
+	assert.Contains(t, out.String(), "X-Github-Event: push\n")
+	assert.Contains(t, out.String(), "X-Source: proxy\n\n<order><status>pending</status></order>\n")
+	assert.Contains(t, out.String(), "=== https://example.com/paid (http)\nSkipped: does not match the destination filters\n")
+
+	out.Reset()
+	assert.Equal(t, 1, runValidate([]string{"-config", path, "-sample", sample, "-endpoint", "/unknown"}, &out))
+	assert.Contains(t, out.String(), "Unknown endpoint: /unknown")
+
+	assert.Equal(t, 2, runValidate([]string{"-config", path, "-sample", sample}, &out))
+	assert.Equal(t, 2, runValidate([]string{"-header", "no-colon"}, &out))
+	assert.Equal(t, 1, runValidate([]string{"-config", filepath.Join(dir, "missing.yaml")}, &out))
+}
+
+// TestRunConfigMigrate tests the config migrate command on a legacy configuration
+func TestRunConfigMigrate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	legacy := `config:
+  logging:
+    file: /var/log/webhook-proxy.log
+  endpoints:
+    - path: "/webhook/github"
+      destinations:
+        - url: "https://example.com/github"
+          retry_count: 3
+`
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0o600))
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, runConfigMigrate([]string{"-config", path, "-dry-run"}, &out))
+	assert.Contains(t, out.String(), "--- "+path+"\n+++ "+path+"\n@@ -1,8 +1,7 @@\n-config:\n")
+	assert.Contains(t, out.String(), "\n+  file_path: /var/log/webhook-proxy.log\n")
+	assert.Contains(t, out.String(), "- renamed endpoints[0].destinations[0].retry_count to endpoints[0].destinations[0].retries\n")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, legacy, string(data), "a dry run leaves the file as is")
+
+	out.Reset()
+	assert.Equal(t, 0, runConfigMigrate([]string{"-config", path}, &out))
+	assert.Contains(t, out.String(), "Rewrote "+path)
+
+	out.Reset()
+	assert.Equal(t, 0, runValidate([]string{"-config", path}, &out))
+	assert.Equal(t, 0, runConfigMigrate([]string{"-config", path}, &out))
+	assert.Contains(t, out.String(), "already uses the current schema")
+
+	assert.Equal(t, 1, runConfigMigrate([]string{"-config", filepath.Join(dir, "missing.yaml")}, &out))
+}
+
+// TestRunVerifyManifests tests the verify-manifests command on a chain of manifests
+func TestRunVerifyManifests(t *testing.T) {
+	dir := t.TempDir()
+	seed := base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+	key, err := manifest.ParseSigningKey(manifest.AlgorithmEd25519, seed)
+	require.NoError(t, err)
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	exporter, err := manifest.Open(dir, time.Hour, key, start)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Record(manifest.Entry{Time: start.Add(time.Minute), DeliveryID: "d1", Outcome: manifest.OutcomeDelivered}))
+	paths, err := exporter.Export(start.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, exporter.Close())
+	require.Len(t, paths, 2)
+
+	var out bytes.Buffer
+	args := []string{"-algorithm", "ed25519", "-key", manifest.PublicKey(key)}
+	assert.Equal(t, 0, runVerifyManifests(append(args, paths[1], paths[0]), &out))
+	assert.Contains(t, out.String(), "OK "+paths[0]+" 2023-01-01T12:00:00Z - 2023-01-01T13:00:00Z: 1 delivered, 0 dead letters\n")
+	assert.Contains(t, out.String(), "2 manifests verified\n")
+
+	// A tampered manifest fails the verification
+	data, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(paths[0], bytes.Replace(data, []byte(`"d1"`), []byte(`"d2"`), 1), 0o600))
+	out.Reset()
+	assert.Equal(t, 1, runVerifyManifests(append(args, paths...), &out))
+	assert.Contains(t, out.String(), "FAIL "+paths[0])
+
+	assert.Equal(t, 2, runVerifyManifests([]string{"-key", "secret"}, &out))
+	assert.Equal(t, 2, runVerifyManifests([]string{"-algorithm", "ed25519", "-key", "secret", paths[1]}, &out))
+}
+
+// TestRunLoadtest tests the loadtest command against a running endpoint
+func TestRunLoadtest(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/webhook/orders", r.URL.Path)
+		assert.Equal(t, "push", r.Header.Get("X-Github-Event"))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	payload := filepath.Join(t.TempDir(), "order.json")
+	assert.NoError(t, os.WriteFile(payload, []byte(`{"id":1}`), 0o600))
+
+	var out bytes.Buffer
+	code := runLoadtest([]string{"-target", target.URL + "/", "-endpoint", "/webhook/orders", "-rate", "50",
+		"-duration", "100ms", "-payload", payload, "-header", "x-github-event: push"}, &out)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out.String(), "Sending 50 webhooks/s to "+target.URL+"/webhook/orders for 100ms")
+	assert.Contains(t, out.String(), "Status 202: ")
+
+	assert.Equal(t, 2, runLoadtest([]string{"-rate", "50"}, &out))
+	assert.Equal(t, 2, runLoadtest([]string{"-endpoint", "/x", "-rate", "0"}, &out))
+	assert.Equal(t, 1, runLoadtest([]string{"-endpoint", "/x", "-payload", filepath.Join(t.TempDir(), "missing.json")}, &out))
+}
@@ -0,0 +1,59 @@
+// Package accounting reports each webhook delivered to a destination, with its size, to an
+// accountant, for teams billing internal customers per forwarded event. It ships two
+// reference accountants: a CSV file and Prometheus counters.
+package accounting
+
+import (
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+)
+
+// Record is a webhook delivered to a destination
+type Record struct {
+	Time        time.Time
+	Endpoint    string
+	Destination string
+
+	// DeliveryID identifies the delivery of the webhook to the destination
+	DeliveryID string
+
+	// Bytes is the size of the body sent, before compression
+	Bytes int
+
+	// Attempts is the number of attempts the delivery took
+	Attempts int
+}
+
+// Accountant receives a record for each delivered webhook. It is called synchronously from
+// the delivery goroutines and must be safe for concurrent use.
+type Accountant interface {
+	Account(record Record)
+}
+
+// hook passes the successful deliveries of a handler to an accountant
+type hook struct {
+	proxy.NopHook
+	accountant Accountant
+}
+
+// NewHook returns a lifecycle hook reporting every successful delivery to the accountant.
+// Deliveries answered from the response cache sent nothing and are not reported.
+func NewHook(accountant Accountant) proxy.Hook {
+	return &hook{accountant: accountant}
+}
+
+// AfterForward reports the delivery when the attempt succeeded
+func (h *hook) AfterForward(event *proxy.Event) {
+	if event.Err != nil || event.Cached {
+		return
+	}
+	h.accountant.Account(Record{
+		Time:        time.Now(),
+		Endpoint:    event.Endpoint,
+		Destination: event.Destination.Key(),
+		DeliveryID:  event.ID,
+		Bytes:       len(event.Body),
+		Attempts:    event.Attempt,
+	})
+}
@@ -0,0 +1,51 @@
+package accounting
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAccountant keeps the records it receives
+type recordingAccountant struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (a *recordingAccountant) Account(record Record) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, record)
+}
+
+func TestHook(t *testing.T) {
+	accountant := &recordingAccountant{}
+	hook := NewHook(accountant)
+
+	event := &proxy.Event{
+		ID:          "d1",
+		Endpoint:    "/webhook/orders",
+		Destination: config.DestinationConfig{URL: "https://example.com/orders"},
+		Body:        []byte(`{"id":1}`),
+		Attempt:     2,
+	}
+	hook.AfterForward(event)
+
+	// Failed and cached attempts are not accounted for
+	hook.AfterForward(&proxy.Event{ID: "d2", Err: errors.New("timeout")})
+	hook.AfterForward(&proxy.Event{ID: "d3", Cached: true})
+
+	require.Len(t, accountant.records, 1)
+	record := accountant.records[0]
+	assert.Equal(t, "/webhook/orders", record.Endpoint)
+	assert.Equal(t, "https://example.com/orders", record.Destination)
+	assert.Equal(t, "d1", record.DeliveryID)
+	assert.Equal(t, 8, record.Bytes)
+	assert.Equal(t, 2, record.Attempts)
+	assert.False(t, record.Time.IsZero())
+}
@@ -0,0 +1,84 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// csvHeader names the columns of the CSV file
+var csvHeader = []string{"time", "endpoint", "destination", "delivery_id", "bytes", "attempts"}
+
+// CSV appends one row per delivered webhook to a file
+type CSV struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+	log    *logrus.Logger
+}
+
+// OpenCSV opens the CSV file at path for appending, creating it with a header row if
+// needed. Rows that fail to be written are logged.
+func OpenCSV(path string, log *logrus.Logger) (*CSV, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open accounting file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open accounting file: %w", err)
+	}
+
+	c := &CSV{file: file, writer: csv.NewWriter(file), log: log}
+	if info.Size() == 0 {
+		if err := c.write(csvHeader); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write accounting file header: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Account appends the record to the file. Rows are flushed one by one, so that a crash
+// loses at most the row being written.
+func (c *CSV) Account(record Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.write([]string{
+		record.Time.UTC().Format(time.RFC3339Nano),
+		record.Endpoint,
+		record.Destination,
+		record.DeliveryID,
+		strconv.Itoa(record.Bytes),
+		strconv.Itoa(record.Attempts),
+	})
+	if err != nil {
+		c.log.WithFields(logrus.Fields{
+			"error":       err,
+			"destination": record.Destination,
+			"delivery_id": record.DeliveryID,
+		}).Error("Failed to write accounting record")
+	}
+}
+
+// write writes and flushes a row
+func (c *CSV) write(row []string) error {
+	if err := c.writer.Write(row); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// Close closes the file
+func (c *CSV) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
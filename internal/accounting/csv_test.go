@@ -0,0 +1,51 @@
+package accounting
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSV(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	path := filepath.Join(t.TempDir(), "accounting.csv")
+	record := Record{
+		Time:        time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+		Endpoint:    "/webhook/orders",
+		Destination: "https://example.com/orders",
+		DeliveryID:  "d1",
+		Bytes:       128,
+		Attempts:    1,
+	}
+
+	file, err := OpenCSV(path, log)
+	require.NoError(t, err)
+	file.Account(record)
+	require.NoError(t, file.Close())
+
+	// Reopening appends without repeating the header
+	file, err = OpenCSV(path, log)
+	require.NoError(t, err)
+	record.DeliveryID, record.Attempts = "d2", 3
+	file.Account(record)
+	require.NoError(t, file.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "time,endpoint,destination,delivery_id,bytes,attempts\n"+
+		"2026-10-15T12:00:00Z,/webhook/orders,https://example.com/orders,d1,128,1\n"+
+		"2026-10-15T12:00:00Z,/webhook/orders,https://example.com/orders,d2,128,3\n", string(data))
+}
+
+func TestOpenCSVError(t *testing.T) {
+	_, err := OpenCSV(filepath.Join(t.TempDir(), "missing", "accounting.csv"), logrus.New())
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package accounting
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// counterKey identifies the counters of a destination of an endpoint
+type counterKey struct {
+	endpoint    string
+	destination string
+}
+
+// counterValues are the totals of a destination of an endpoint
+type counterValues struct {
+	deliveries int64
+	bytes      int64
+}
+
+// Counters counts the delivered webhooks and their bytes per endpoint and destination,
+// and serves them in the Prometheus text exposition format
+type Counters struct {
+	mu     sync.Mutex
+	values map[counterKey]*counterValues
+}
+
+// NewCounters creates empty counters
+func NewCounters() *Counters {
+	return &Counters{values: make(map[counterKey]*counterValues)}
+}
+
+// Account counts the record
+func (c *Counters) Account(record Record) {
+	key := counterKey{endpoint: record.Endpoint, destination: record.Destination}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values, ok := c.values[key]
+	if !ok {
+		values = &counterValues{}
+		c.values[key] = values
+	}
+	values.deliveries++
+	values.bytes += int64(record.Bytes)
+}
+
+// ServeHTTP writes the counters in the Prometheus text exposition format. Counters are
+// never reset, as Prometheus computes rates and increases from monotonic totals.
+func (c *Counters) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	c.mu.Lock()
+	keys := make([]counterKey, 0, len(c.values))
+	totals := make(map[counterKey]counterValues, len(c.values))
+	for key, values := range c.values {
+		keys = append(keys, key)
+		totals[key] = *values
+	}
+	c.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].destination < keys[j].destination
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP webhook_proxy_accounted_deliveries_total Webhooks delivered to a destination.\n")
+	b.WriteString("# TYPE webhook_proxy_accounted_deliveries_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "webhook_proxy_accounted_deliveries_total%s %d\n", key.labels(), totals[key].deliveries)
+	}
+	b.WriteString("# HELP webhook_proxy_accounted_bytes_total Bytes of the webhooks delivered to a destination, before compression.\n")
+	b.WriteString("# TYPE webhook_proxy_accounted_bytes_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "webhook_proxy_accounted_bytes_total%s %d\n", key.labels(), totals[key].bytes)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// labels returns the label set of the key
+func (k counterKey) labels() string {
+	return `{endpoint="` + escapeLabel(k.endpoint) + `",destination="` + escapeLabel(k.destination) + `"}`
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,31 @@
+package accounting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounters(t *testing.T) {
+	counters := NewCounters()
+	counters.Account(Record{Endpoint: "/webhook/orders", Destination: "https://example.com/orders", Bytes: 100})
+	counters.Account(Record{Endpoint: "/webhook/orders", Destination: "https://example.com/orders", Bytes: 50})
+	counters.Account(Record{Endpoint: "/webhook/a", Destination: `https://example.com/?q="x"`, Bytes: 10})
+
+	w := httptest.NewRecorder()
+	counters.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/accounting", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP webhook_proxy_accounted_deliveries_total Webhooks delivered to a destination.
+# TYPE webhook_proxy_accounted_deliveries_total counter
+webhook_proxy_accounted_deliveries_total{endpoint="/webhook/a",destination="https://example.com/?q=\"x\""} 1
+webhook_proxy_accounted_deliveries_total{endpoint="/webhook/orders",destination="https://example.com/orders"} 2
+# HELP webhook_proxy_accounted_bytes_total Bytes of the webhooks delivered to a destination, before compression.
+# TYPE webhook_proxy_accounted_bytes_total counter
+webhook_proxy_accounted_bytes_total{endpoint="/webhook/a",destination="https://example.com/?q=\"x\""} 10
+webhook_proxy_accounted_bytes_total{endpoint="/webhook/orders",destination="https://example.com/orders"} 150
+`, w.Body.String())
+}
@@ -0,0 +1,233 @@
+// Package alert raises alerts on the proxy's lifecycle events, such as a destination paused
+// for too long or repeated delivery failures, and sends them to Slack, PagerDuty or any
+// HTTP endpoint, so that the proxy reports its own problems
+package alert
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/sirupsen/logrus"
+)
+
+// sendTimeout bounds the time spent sending an alert
+const sendTimeout = 10 * time.Second
+
+// Statuses of an alert
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Alert is a notification sent to a channel
+type Alert struct {
+	// Name of the alert in the configuration
+	Name   string `json:"name"`
+	Status string `json:"status"`
+
+	// Key identifies the alert across its firing and resolution
+	Key string `json:"key"`
+
+	Event       events.Type            `json:"event"`
+	Endpoint    string                 `json:"endpoint,omitempty"`
+	Destination string                 `json:"destination,omitempty"`
+	Summary     string                 `json:"summary"`
+	Count       int                    `json:"count,omitempty"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+	Time        time.Time              `json:"time"`
+}
+
+// Manager evaluates the configured alerts on the lifecycle events it receives and sends
+// them in the background. Its Handle method is an events.Subscriber.
+type Manager struct {
+	rules []*rule
+	log   *logrus.Logger
+	wg    sync.WaitGroup
+}
+
+// rule is the state of a configured alert
+type rule struct {
+	config   config.AlertConfig
+	notifier notifier
+
+	mu sync.Mutex
+
+	// Times of the events within the window, and the end of the silence after firing
+	times         []time.Time
+	silencedUntil time.Time
+
+	// Destinations paused, by endpoint and destination, for destination_paused alerts
+	paused map[string]*pausedDestination
+}
+
+// pausedDestination tracks a paused destination until it resumes or its alert fires
+type pausedDestination struct {
+	timer *time.Timer
+	fired bool
+}
+
+// NewManager creates a manager for the configured alerts
+func NewManager(alerts []config.AlertConfig, log *logrus.Logger) *Manager {
+	m := &Manager{log: log}
+	for _, cfg := range alerts {
+		m.rules = append(m.rules, &rule{
+			config:   cfg,
+			notifier: newNotifier(cfg.Channel),
+			paused:   make(map[string]*pausedDestination),
+		})
+	}
+	return m
+}
+
+// Handle evaluates the alerts on a lifecycle event. It never blocks: alerts are sent in
+// the background.
+func (m *Manager) Handle(event events.Event) {
+	for _, r := range m.rules {
+		switch {
+		case r.config.Event == config.AlertEventDestinationPaused && event.Type == events.DestinationPaused:
+			m.paused(r, event)
+		case r.config.Event == config.AlertEventDestinationPaused && event.Type == events.DestinationResumed:
+			m.resumed(r, event)
+		case r.config.Event == string(event.Type):
+			m.count(r, event)
+		}
+	}
+}
+
+// Wait blocks until the alerts being sent are sent
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// paused fires the alert once the destination has been paused for the alert's duration
+func (m *Manager) paused(r *rule, event events.Event) {
+	key := event.Endpoint + " " + event.Destination
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.paused[key]; ok {
+		return
+	}
+
+	state := &pausedDestination{}
+	fire := func() {
+		r.mu.Lock()
+		if r.paused[key] != state {
+			r.mu.Unlock()
+			return
+		}
+		state.fired = true
+		r.mu.Unlock()
+
+		m.send(r, newAlert(r.config, StatusFiring, key, event,
+			fmt.Sprintf("Destination %s of %s paused for more than %s", event.Destination, event.Endpoint, r.config.For)))
+	}
+	r.paused[key] = state
+	state.timer = time.AfterFunc(r.config.For, fire)
+}
+
+// resumed cancels the alert of a resumed destination, or resolves it when it fired
+func (m *Manager) resumed(r *rule, event events.Event) {
+	key := event.Endpoint + " " + event.Destination
+
+	r.mu.Lock()
+	state, ok := r.paused[key]
+	if ok {
+		delete(r.paused, key)
+		state.timer.Stop()
+	}
+	r.mu.Unlock()
+
+	if ok && state.fired {
+		m.send(r, newAlert(r.config, StatusResolved, key, event,
+			fmt.Sprintf("Destination %s of %s resumed", event.Destination, event.Endpoint)))
+	}
+}
+
+// count fires the alert when the threshold of events is reached within the window, then
+// keeps it silent for a window
+func (m *Manager) count(r *rule, event events.Event) {
+	now := event.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	r.mu.Lock()
+	start := now.Add(-r.config.Window)
+	kept := r.times[:0]
+	for _, t := range r.times {
+		if t.After(start) {
+			kept = append(kept, t)
+		}
+	}
+	r.times = append(kept, now)
+
+	count := len(r.times)
+	if count < r.config.Threshold || now.Before(r.silencedUntil) {
+		r.mu.Unlock()
+		return
+	}
+	r.times = r.times[:0]
+	r.silencedUntil = now.Add(r.config.Window)
+	r.mu.Unlock()
+
+	summary := fmt.Sprintf("%d %s events within %s, last on %s", count, event.Type, r.config.Window, event.Endpoint)
+	if event.Destination != "" {
+		summary += " " + event.Destination
+	}
+	alert := newAlert(r.config, StatusFiring, strconv.FormatInt(now.Unix(), 10), event, summary)
+	alert.Count = count
+	m.send(r, alert)
+}
+
+// newAlert creates the alert of a rule for an event
+func newAlert(cfg config.AlertConfig, status, key string, event events.Event, summary string) Alert {
+	details := make(map[string]interface{}, len(event.Fields)+1)
+	for k, v := range event.Fields {
+		details[k] = v
+	}
+	if event.Message != "" {
+		details["message"] = event.Message
+	}
+
+	return Alert{
+		Name:        cfg.Name,
+		Status:      status,
+		Key:         cfg.Name + " " + key,
+		Event:       event.Type,
+		Endpoint:    event.Endpoint,
+		Destination: event.Destination,
+		Summary:     summary,
+		Details:     details,
+		Time:        time.Now().UTC(),
+	}
+}
+
+// send sends an alert in the background, logging failures
+func (m *Manager) send(r *rule, alert Alert) {
+	m.log.WithFields(logrus.Fields{
+		"alert":   alert.Name,
+		"status":  alert.Status,
+		"channel": r.config.Channel.Type,
+	}).Warn(alert.Summary)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := r.notifier.notify(ctx, alert); err != nil {
+			m.log.WithFields(logrus.Fields{
+				"error":   err,
+				"alert":   alert.Name,
+				"channel": r.config.Channel.Type,
+			}).Error("Failed to send alert")
+		}
+	}()
+}
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newChannel starts a server receiving alerts and returns the channel of their bodies
+func newChannel(t *testing.T) (*httptest.Server, chan map[string]interface{}) {
+	t.Helper()
+
+	received := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// newTestManager creates a manager with logs discarded
+func newTestManager(alerts ...config.AlertConfig) *Manager {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return NewManager(alerts, log)
+}
+
+func TestPausedAlert(t *testing.T) {
+	server, received := newChannel(t)
+	manager := newTestManager(config.AlertConfig{
+		Name:      "destination-down",
+		Event:     config.AlertEventDestinationPaused,
+		For:       20 * time.Millisecond,
+		Threshold: 1,
+		Window:    time.Minute,
+		Channel:   config.AlertChannelConfig{Type: config.AlertChannelHTTP, URL: server.URL, Headers: map[string]string{"X-Token": "secret"}},
+	})
+
+	paused := events.Event{Type: events.DestinationPaused, Endpoint: "/webhook", Destination: "https://example.com", Message: "Destination paused"}
+	resumed := events.Event{Type: events.DestinationResumed, Endpoint: "/webhook", Destination: "https://example.com"}
+
+	// A destination resuming before the duration raises no alert
+	manager.Handle(paused)
+	manager.Handle(resumed)
+	time.Sleep(50 * time.Millisecond)
+	manager.Wait()
+	assert.Empty(t, received)
+
+	// A destination paused longer fires the alert, resolved when it resumes
+	manager.Handle(paused)
+	var firing map[string]interface{}
+	select {
+	case firing = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a firing alert")
+	}
+	assert.Equal(t, "destination-down", firing["name"])
+	assert.Equal(t, StatusFiring, firing["status"])
+	assert.Equal(t, "destination_paused", firing["event"])
+	assert.Equal(t, "/webhook", firing["endpoint"])
+	assert.Equal(t, "https://example.com", firing["destination"])
+	assert.Equal(t, "Destination https://example.com of /webhook paused for more than 20ms", firing["summary"])
+	assert.Equal(t, "Destination paused", firing["details"].(map[string]interface{})["message"])
+
+	manager.Handle(resumed)
+	manager.Wait()
+	require.Len(t, received, 1)
+	resolved := <-received
+	assert.Equal(t, StatusResolved, resolved["status"])
+	assert.Equal(t, firing["key"], resolved["key"])
+}
+
+func TestCountAlert(t *testing.T) {
+	server, received := newChannel(t)
+	manager := newTestManager(config.AlertConfig{
+		Name:      "failures",
+		Event:     config.AlertEventDeliveryFailed,
+		Threshold: 3,
+		Window:    time.Minute,
+		Channel:   config.AlertChannelConfig{Type: config.AlertChannelSlack, URL: server.URL},
+	})
+
+	start := time.Now()
+	failed := func(at time.Duration) {
+		manager.Handle(events.Event{Type: events.DeliveryFailed, Time: start.Add(at), Endpoint: "/webhook"})
+	}
+
+	// Events outside the window do not count
+	failed(0)
+	failed(2 * time.Minute)
+	failed(2*time.Minute + time.Second)
+	manager.Wait()
+	assert.Empty(t, received)
+
+	failed(2*time.Minute + 2*time.Second)
+	manager.Wait()
+	require.Len(t, received, 1)
+	assert.Equal(t, "[FIRING] failures: 3 delivery_failed events within 1m0s, last on /webhook", (<-received)["text"])
+
+	// The alert stays silent for a window after firing
+	for i := 0; i < 3; i++ {
+		failed(2*time.Minute + 3*time.Second)
+	}
+	manager.Wait()
+	assert.Empty(t, received)
+
+	// Other events are ignored
+	manager.Handle(events.Event{Type: events.QueueHighWater})
+	manager.Wait()
+	assert.Empty(t, received)
+}
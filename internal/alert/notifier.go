@@ -0,0 +1,137 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// notifier sends alerts to a channel
+type notifier interface {
+	notify(ctx context.Context, alert Alert) error
+}
+
+// newNotifier creates the notifier of a channel
+func newNotifier(channel config.AlertChannelConfig) notifier {
+	client := &http.Client{Timeout: sendTimeout}
+	switch channel.Type {
+	case config.AlertChannelSlack:
+		return &slackNotifier{url: channel.URL, client: client}
+	case config.AlertChannelPagerDuty:
+		return &pagerDutyNotifier{url: channel.URL, routingKey: channel.RoutingKey, client: client}
+	default:
+		return &httpNotifier{url: channel.URL, headers: channel.Headers, client: client}
+	}
+}
+
+// slackNotifier posts alerts as messages to a Slack incoming webhook
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// notify posts the alert's summary
+func (n *slackNotifier) notify(ctx context.Context, alert Alert) error {
+	text := fmt.Sprintf("[%s] %s: %s", strings.ToUpper(alert.Status), alert.Name, alert.Summary)
+	return postJSON(ctx, n.client, n.url, nil, map[string]string{"text": text})
+}
+
+// pagerDutyNotifier triggers and resolves incidents with the PagerDuty Events API v2,
+// deduplicated by the alert's key
+type pagerDutyNotifier struct {
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+// pagerDutyEvent is an event of the PagerDuty Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+// pagerDutyPayload describes the incident of a triggered PagerDuty event
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Component     string                 `json:"component,omitempty"`
+	Group         string                 `json:"group,omitempty"`
+	Class         string                 `json:"class"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// notify triggers the incident of a firing alert, or resolves it
+func (n *pagerDutyNotifier) notify(ctx context.Context, alert Alert) error {
+	event := pagerDutyEvent{RoutingKey: n.routingKey, EventAction: "resolve", DedupKey: alert.Key}
+	if alert.Status == StatusFiring {
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:       alert.Summary,
+			Source:        "webhook-proxy",
+			Severity:      "error",
+			Component:     alert.Destination,
+			Group:         alert.Endpoint,
+			Class:         string(alert.Event),
+			CustomDetails: alert.Details,
+		}
+	}
+	return postJSON(ctx, n.client, n.url, nil, event)
+}
+
+// httpNotifier posts alerts as JSON to any HTTP endpoint
+type httpNotifier struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// notify posts the alert
+func (n *httpNotifier) notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.client, n.url, n.headers, alert)
+}
+
+// postJSON posts a value as JSON, expecting a 2xx response. Errors do not include the URL,
+// which may be a secret.
+func postJSON(ctx context.Context, client *http.Client, target string, headers map[string]string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Client errors embed the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("received non-2xx status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package alert
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagerDutyNotifier(t *testing.T) {
+	server, received := newChannel(t)
+	n := newNotifier(config.AlertChannelConfig{Type: config.AlertChannelPagerDuty, URL: server.URL, RoutingKey: "routing-key"})
+
+	alert := Alert{
+		Name:        "destination-down",
+		Status:      StatusFiring,
+		Key:         "destination-down /webhook https://example.com",
+		Event:       events.DestinationPaused,
+		Endpoint:    "/webhook",
+		Destination: "https://example.com",
+		Summary:     "Destination paused",
+	}
+	require.NoError(t, n.notify(t.Context(), alert))
+
+	trigger := <-received
+	assert.Equal(t, "routing-key", trigger["routing_key"])
+	assert.Equal(t, "trigger", trigger["event_action"])
+	assert.Equal(t, alert.Key, trigger["dedup_key"])
+	payload := trigger["payload"].(map[string]interface{})
+	assert.Equal(t, "Destination paused", payload["summary"])
+	assert.Equal(t, "error", payload["severity"])
+	assert.Equal(t, "https://example.com", payload["component"])
+
+	alert.Status = StatusResolved
+	require.NoError(t, n.notify(t.Context(), alert))
+	resolve := <-received
+	assert.Equal(t, "resolve", resolve["event_action"])
+	assert.Equal(t, alert.Key, resolve["dedup_key"])
+	assert.NotContains(t, resolve, "payload")
+}
+
+func TestNotifierErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	n := newNotifier(config.AlertChannelConfig{Type: config.AlertChannelHTTP, URL: server.URL + "/secret-token"})
+	assert.EqualError(t, n.notify(t.Context(), Alert{}), "received non-2xx status code: 403")
+
+	// Errors do not reveal the channel's URL
+	n = newNotifier(config.AlertChannelConfig{Type: config.AlertChannelSlack, URL: "http://127.0.0.1:1/secret-token"})
+	err := n.notify(t.Context(), Alert{})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret-token")
+}
@@ -0,0 +1,58 @@
+// Package bufpool provides pooled buffers for reading request and response bodies
+package bufpool
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+const (
+	// defaultSize is the initial capacity of new buffers
+	defaultSize = 4 << 10
+
+	// maxPooledSize is the capacity above which buffers are dropped instead of pooled,
+	// so that a few large bodies don't keep memory allocated
+	maxPooledSize = 1 << 20
+)
+
+var pool = sync.Pool{
+	New: func() interface{} {
+		return bytes.NewBuffer(make([]byte, 0, defaultSize))
+	},
+}
+
+// Get returns an empty buffer from the pool
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put returns a buffer to the pool. The buffer must not be used afterwards.
+func Put(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledSize {
+		return
+	}
+	buf.Reset()
+	pool.Put(buf)
+}
+
+// ReadAll reads r until EOF using a pooled buffer and returns the data in a
+// slice of exactly its size. The slice is owned by the caller and can be
+// shared read-only, e.g. between destinations. sizeHint is the expected size,
+// such as a Content-Length, or a negative value if unknown.
+func ReadAll(r io.Reader, sizeHint int64) ([]byte, error) {
+	buf := Get()
+	defer Put(buf)
+
+	if sizeHint > 0 && sizeHint <= maxPooledSize {
+		buf.Grow(int(sizeHint) + bytes.MinRead)
+	}
+
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, buf.Len())
+	copy(data, buf.Bytes())
+	return data, nil
+}
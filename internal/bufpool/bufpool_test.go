@@ -0,0 +1,73 @@
+package bufpool
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		sizeHint int64
+	}{
+		{name: "Empty body", data: "", sizeHint: 0},
+		{name: "Known size", data: `{"event":"push"}`, sizeHint: 16},
+		{name: "Unknown size", data: `{"event":"push"}`, sizeHint: -1},
+		{name: "Larger than the initial buffer", data: strings.Repeat("x", 3*defaultSize), sizeHint: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ReadAll(strings.NewReader(tt.data), tt.sizeHint)
+			require.NoError(t, err)
+			assert.Equal(t, tt.data, string(data))
+			assert.Equal(t, len(data), cap(data))
+		})
+	}
+}
+
+func TestReadAllError(t *testing.T) {
+	data, err := ReadAll(iotest.ErrReader(errors.New("read error")), -1)
+	assert.EqualError(t, err, "read error")
+	assert.Nil(t, data)
+}
+
+func TestReadAllDoesNotShareBuffers(t *testing.T) {
+	first, err := ReadAll(strings.NewReader("first"), -1)
+	require.NoError(t, err)
+
+	// Reading again reuses the pooled buffer but not the returned slice
+	_, err = ReadAll(strings.NewReader("second"), -1)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(first))
+}
+
+func TestPut(t *testing.T) {
+	buf := Get()
+	buf.WriteString("data")
+	Put(buf)
+
+	// Buffers are reset when returned to the pool
+	assert.Equal(t, 0, Get().Len())
+
+	// Large buffers are not pooled
+	large := bytes.NewBuffer(make([]byte, 0, 2*maxPooledSize))
+	Put(large)
+}
+
+func BenchmarkReadAll(b *testing.B) {
+	body := bytes.Repeat([]byte("x"), 16<<10)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadAll(bytes.NewReader(body), int64(len(body))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package charset converts the bodies of webhooks sent in legacy charsets, such as
+// ISO-8859-1, to UTF-8, which the templates, filters and JSON parsing expect
+package charset
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// xmlEncodingPattern matches the encoding of an XML declaration
+var xmlEncodingPattern = regexp.MustCompile(`^(\s*<\?xml[^>]*?\sencoding\s*=\s*["'])([A-Za-z0-9._:-]+)(["'])`)
+
+// Result is a body converted to UTF-8
+type Result struct {
+	Body []byte
+
+	// ContentType is the Content-Type with a utf-8 charset, when it had a charset
+	ContentType string
+
+	// Charset is the charset the body was converted from, empty when it was left as is
+	Charset string
+}
+
+// Valid reports whether a charset name is known
+func Valid(name string) bool {
+	_, err := htmlindex.Get(name)
+	return err == nil
+}
+
+// Normalize converts a text body to UTF-8. Its charset is the charset parameter of the
+// Content-Type or, for XML, the encoding of its declaration. A body without either that
+// is not valid UTF-8 is decoded with the fallback charset. Binary and multipart bodies
+// are left as is.
+func Normalize(body []byte, contentType, fallback string) (Result, error) {
+	result := Result{Body: body, ContentType: contentType}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if contentType != "" && err != nil {
+		return result, nil
+	}
+	if contentType != "" && !textual(mediaType) {
+		return result, nil
+	}
+
+	name := params["charset"]
+	declaration := xmlEncodingPattern.FindSubmatch(body)
+	if name == "" && declaration != nil {
+		name = string(declaration[2])
+	}
+	if name == "" {
+		if utf8.Valid(body) {
+			return result, nil
+		}
+		name = fallback
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return result, fmt.Errorf("unknown charset: %s", name)
+	}
+	if params["charset"] != "" {
+		params["charset"] = "utf-8"
+		result.ContentType = mime.FormatMediaType(mediaType, params)
+	}
+	if isUTF8(enc) {
+		return result, nil
+	}
+
+	converted, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return result, fmt.Errorf("failed to decode %s body: %w", name, err)
+	}
+
+	// The XML declaration must not contradict the converted body
+	if declaration != nil {
+		converted = xmlEncodingPattern.ReplaceAll(converted, []byte("${1}UTF-8${3}"))
+	}
+
+	result.Body = converted
+	result.Charset = name
+	return result, nil
+}
+
+// textual reports whether a media type is a text format, whose charset can be converted
+func textual(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") ||
+		mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml") ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+// isUTF8 reports whether an encoding is UTF-8, which needs no conversion
+func isUTF8(enc encoding.Encoding) bool {
+	name, err := htmlindex.Name(enc)
+	return err == nil && strings.EqualFold(name, "utf-8")
+}
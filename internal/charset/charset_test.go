@@ -0,0 +1,78 @@
+package charset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// latin1 is "Café" in ISO-8859-1
+var latin1 = []byte{'C', 'a', 'f', 0xe9}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("iso-8859-1"))
+	assert.True(t, Valid("windows-1252"))
+	assert.True(t, Valid("UTF-8"))
+	assert.False(t, Valid("klingon"))
+}
+
+func TestNormalizeDeclaredCharset(t *testing.T) {
+	body := append(append([]byte(`{"name":"`), latin1...), '"', '}')
+	result, err := Normalize(body, "application/json; charset=ISO-8859-1", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"name":"Café"}`, string(result.Body))
+	assert.Equal(t, "application/json; charset=utf-8", result.ContentType)
+	assert.Equal(t, "ISO-8859-1", result.Charset)
+}
+
+func TestNormalizeXMLDeclaration(t *testing.T) {
+	body := append(append([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?><name>`), latin1...), []byte("</name>")...)
+	result, err := Normalize(body, "application/xml", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?><name>Café</name>`, string(result.Body))
+	assert.Equal(t, "application/xml", result.ContentType)
+	assert.Equal(t, "ISO-8859-1", result.Charset)
+}
+
+func TestNormalizeFallback(t *testing.T) {
+	// Valid UTF-8 without a charset is left as is
+	result, err := Normalize([]byte(`{"name":"Café"}`), "application/json", "iso-8859-1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"Café"}`, string(result.Body))
+	assert.Empty(t, result.Charset)
+
+	// Invalid UTF-8 is decoded with the fallback
+	result, err = Normalize(latin1, "text/plain", "iso-8859-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Café", string(result.Body))
+	assert.Equal(t, "text/plain", result.ContentType)
+	assert.Equal(t, "iso-8859-1", result.Charset)
+}
+
+func TestNormalizeUTF8(t *testing.T) {
+	result, err := Normalize([]byte("Café"), "text/plain; charset=UTF-8", "iso-8859-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Café", string(result.Body))
+	assert.Equal(t, "text/plain; charset=utf-8", result.ContentType)
+	assert.Empty(t, result.Charset)
+}
+
+func TestNormalizeBinary(t *testing.T) {
+	for _, contentType := range []string{"application/octet-stream", "multipart/form-data; boundary=abc; charset=iso-8859-1", "invalid;;"} {
+		result, err := Normalize(latin1, contentType, "iso-8859-1")
+		require.NoError(t, err)
+		assert.Equal(t, latin1, result.Body, contentType)
+		assert.Equal(t, contentType, result.ContentType)
+		assert.Empty(t, result.Charset)
+	}
+}
+
+func TestNormalizeUnknownCharset(t *testing.T) {
+	result, err := Normalize(latin1, "text/plain; charset=klingon", "iso-8859-1")
+	assert.EqualError(t, err, "unknown charset: klingon")
+	assert.Equal(t, latin1, result.Body)
+}
@@ -2,15 +2,48 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/flemzord/webhook-proxy/internal/charset"
+	"github.com/flemzord/webhook-proxy/internal/encoder"
+	"github.com/flemzord/webhook-proxy/internal/maintenance"
+	"github.com/flemzord/webhook-proxy/internal/manifest"
+	"github.com/flemzord/webhook-proxy/internal/transform"
+	"github.com/flemzord/webhook-proxy/internal/xmldata"
 	"gopkg.in/yaml.v3"
 )
 
+// tableNamePattern matches valid, optionally schema-qualified, table names
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// httpErrorClassPattern matches the error classes of non-2xx responses, e.g. http_503
+var httpErrorClassPattern = regexp.MustCompile(`^http_[1-5][0-9]{2}$`)
+
+// pipelineNamePattern matches valid pipeline names
+var pipelineNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// headerNamePattern matches valid HTTP header names
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// labelKeyPattern matches valid label keys, usable as metric label and span attribute names
+var labelKeyPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// RetryErrorClasses lists the error classes a retry policy can refer to, besides http_NNN
+var RetryErrorClasses = map[string]bool{
+	"timeout": true, "connection_refused": true, "connection_reset": true, "dns": true,
+	"tls": true, "concurrency_limit": true, "invalid_request": true, "unexpected_body": true, "other": true,
+}
+
 // Default configuration values
 const (
 	DefaultLogLevel  = "info"
@@ -18,28 +51,747 @@ const (
 	DefaultLogOutput = "stdout"
 	DefaultMethod    = "POST"
 	DefaultHost      = "0.0.0.0"
+
+	// DefaultErrorSuppressionWindow is the period over which repeated delivery errors are summarized
+	DefaultErrorSuppressionWindow = time.Minute
+
+	// DefaultBodyLogMaxSize is the number of bytes of a body logged before truncation
+	DefaultBodyLogMaxSize = 4096
+
+	// DefaultRetentionInterval is the period between two compactions of a store
+	DefaultRetentionInterval = time.Minute
+
+	// DefaultStatsRetentionDays is the number of days of delivery statistics kept
+	DefaultStatsRetentionDays = 90
+
+	// DefaultStatsFlushInterval is the period between two writes of the delivery statistics
+	DefaultStatsFlushInterval = time.Minute
+
+	// DefaultManifestWindow is the period covered by each delivery manifest
+	DefaultManifestWindow = time.Hour
+
+	// DefaultQueueWorkers is the number of workers draining the delivery queue
+	DefaultQueueWorkers = 16
+
+	// DefaultDeliveriesQueueSize is the number of deliveries waiting for a slot when the
+	// concurrent deliveries are bounded
+	DefaultDeliveriesQueueSize = 10000
+
+	// DefaultDeliveriesMaxRetryAfter is the longest Retry-After hinted to the senders
+	// rejected because the delivery queue is full
+	DefaultDeliveriesMaxRetryAfter = time.Minute
+
+	// DefaultChaosMaxDelay is the longest delay injected into a delivery
+	DefaultChaosMaxDelay = time.Second
+
+	// DefaultDNSOutageThreshold is the number of consecutive DNS failures that pause a destination
+	DefaultDNSOutageThreshold = 3
+
+	// DefaultDNSOutageInitialBackoff is the delay before the first resolution of a paused destination
+	DefaultDNSOutageInitialBackoff = 5 * time.Second
+
+	// DefaultDNSOutageMaxBackoff is the longest delay between two resolutions of a paused destination
+	DefaultDNSOutageMaxBackoff = 5 * time.Minute
+
+	// DefaultDialTimeout bounds the time to open a connection to an HTTP destination
+	DefaultDialTimeout = 30 * time.Second
+
+	// DefaultDialFallbackDelay is the delay before connecting to the other address family
+	DefaultDialFallbackDelay = 300 * time.Millisecond
+
+	// DefaultPoolMaxIdleConns is the number of idle connections kept across all hosts
+	DefaultPoolMaxIdleConns = 100
+
+	// DefaultPoolMaxIdleConnsPerHost is the number of idle connections kept to each host
+	DefaultPoolMaxIdleConnsPerHost = 100
+
+	// DefaultPoolIdleConnTimeout is how long an idle connection is kept open
+	DefaultPoolIdleConnTimeout = 90 * time.Second
+
+	// DefaultPoolKeepAlive is the interval of the TCP keep-alive probes
+	DefaultPoolKeepAlive = 30 * time.Second
+
+	// DefaultAdminBurst is the number of destructive admin actions allowed at once
+	DefaultAdminBurst = 5
+
+	// DefaultAdminInterval is the period after which another destructive admin action is allowed
+	DefaultAdminInterval = time.Minute
+
+	// DefaultMonitorInterval is the period between two self-monitoring samples
+	DefaultMonitorInterval = 30 * time.Second
+
+	// DefaultMonitorBudget is the longest time a self-monitoring sample may take
+	DefaultMonitorBudget = 5 * time.Second
+
+	// DefaultUpgradeReadyTimeout is the time a new process has to serve during an upgrade
+	DefaultUpgradeReadyTimeout = 30 * time.Second
+
+	// DefaultUpgradeDrainTimeout is the longest time the old process drains during an upgrade
+	DefaultUpgradeDrainTimeout = 30 * time.Second
+
+	// DefaultCompressionLevel is the gzip level of compressed responses
+	DefaultCompressionLevel = 6
+
+	// DefaultCompressionMinSize is the size in bytes from which responses are compressed
+	DefaultCompressionMinSize = 1024
+
+	// DefaultQuotaQueueSize is the number of webhooks an endpoint over its quota holds in queue mode
+	DefaultQuotaQueueSize = 1000
+
+	// DefaultDedupTTL is the time an endpoint remembers the key of a webhook it accepted
+	DefaultDedupTTL = 24 * time.Hour
+
+	// DefaultDedupMaxKeys is the number of webhook keys an endpoint remembers
+	DefaultDedupMaxKeys = 100000
+
+	// DefaultGELFPort is the port Graylog GELF inputs listen on
+	DefaultGELFPort = 12201
+
+	// DefaultResponseBody is the body returned to the sender of an accepted webhook, with the
+	// ID its delivery results are looked up with on /deliveries/{id}
+	DefaultResponseBody = `{"status":"accepted","id":"{{.ID}}"}`
+
+	// DefaultS3KeyTemplate stores objects by date and endpoint
+	DefaultS3KeyTemplate = "{{.Year}}/{{.Month}}/{{.Day}}/{{.Endpoint}}/{{.Timestamp}}-{{.ID}}.jsonl"
+
+	// DefaultCharsetFallback is the charset of the bodies declaring none that are not valid UTF-8
+	DefaultCharsetFallback = "iso-8859-1"
+
+	// DefaultAlertWindow is the period in which an alert counts the events of its type
+	DefaultAlertWindow = 5 * time.Minute
+
+	// DefaultPagerDutyURL is the PagerDuty Events API v2 endpoint
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+)
+
+// SyslogFacilities maps syslog facility names to their numeric codes
+var SyslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Bounds of the labels of an endpoint or destination, which are attached to every log
+// line, span and metric of its webhooks
+const (
+	MaxLabels          = 16
+	MaxLabelValueBytes = 128
+)
+
+// Destination types
+const (
+	DestinationTypeHTTP      = "http"
+	DestinationTypeWebSocket = "websocket"
+	DestinationTypeDatabase  = "database"
+	DestinationTypeS3        = "s3"
+	DestinationTypeSlack     = "slack"
+	DestinationTypeKafka     = "kafka"
+	DestinationTypeSQS       = "sqs"
+	DestinationTypeSNS       = "sns"
+)
+
+// Webhook providers with a preset
+const (
+	ProviderGitHub  = "github"
+	ProviderStripe  = "stripe"
+	ProviderGitLab  = "gitlab"
+	ProviderSlack   = "slack"
+	ProviderShopify = "shopify"
+)
+
+// Styles of the signatures checked by an endpoint's auth block
+const (
+	// AuthStyleGitHub expects the algorithm and the hex HMAC of the body, as sha256=<hex>
+	AuthStyleGitHub = "github"
+	// AuthStyleStripe expects a timestamp and the hex HMAC of timestamp.body, as t=<unix>,v1=<hex>
+	AuthStyleStripe = "stripe"
+	// AuthStyleToken expects the secret itself, as GitLab sends it
+	AuthStyleToken = "token"
+)
+
+// HMAC algorithms of an endpoint's auth block
+const (
+	AuthAlgorithmSHA256 = "sha256"
+	AuthAlgorithmSHA1   = "sha1"
+)
+
+// Routing fields extracted by provider presets, for field filters and response templates
+const (
+	// ProviderFieldEvent is the event type, extracted by every preset
+	ProviderFieldEvent = "event"
+
+	GitHubFieldAction = "action"
+	GitHubFieldRepo   = "repo"
+	GitHubFieldSender = "sender"
+	GitHubFieldRef    = "ref"
+
+	// StripeFieldLivemode is "true" for live events and "false" for test mode events
+	StripeFieldLivemode = "livemode"
+	StripeFieldObjectID = "object_id"
+)
+
+// ProviderFields lists the routing fields of each provider
+var ProviderFields = map[string]map[string]bool{
+	ProviderGitHub: {
+		ProviderFieldEvent: true, GitHubFieldAction: true, GitHubFieldRepo: true, GitHubFieldSender: true, GitHubFieldRef: true,
+	},
+	ProviderStripe:  {ProviderFieldEvent: true, StripeFieldLivemode: true, StripeFieldObjectID: true},
+	ProviderGitLab:  {ProviderFieldEvent: true},
+	ProviderSlack:   {ProviderFieldEvent: true},
+	ProviderShopify: {ProviderFieldEvent: true},
+}
+
+// HTTP protocols of the connections to an HTTP destination
+const (
+	// ProtocolAuto negotiates HTTP/2 over TLS with https destinations, and uses HTTP/1.1 otherwise
+	ProtocolAuto = "auto"
+	// ProtocolHTTP1 always uses HTTP/1.1, for destinations with a broken HTTP/2 support
+	ProtocolHTTP1 = "http1"
+	// ProtocolH2C uses HTTP/2 without TLS (prior knowledge), for internal http destinations
+	ProtocolH2C = "h2c"
+)
+
+// Address families connected to by an HTTP destination's dialer
+const (
+	// IPFamilyAny connects to the addresses in the system's order, falling back to the
+	// other family after the fallback delay
+	IPFamilyAny  = "any"
+	IPFamilyIPv4 = "ipv4"
+	IPFamilyIPv6 = "ipv6"
+	// IPFamilyPreferIPv4 and IPFamilyPreferIPv6 connect to the preferred family first,
+	// and to the other one after the fallback delay
+	IPFamilyPreferIPv4 = "prefer_ipv4"
+	IPFamilyPreferIPv6 = "prefer_ipv6"
+)
+
+// CompressionGzip compresses outbound bodies with gzip
+const CompressionGzip = "gzip"
+
+// Behaviors of a destination receiving a body larger than its max_body_size
+const (
+	OversizeDeadLetter = "dead_letter"
+	OversizeTruncate   = "truncate"
+)
+
+// Behaviors of the endpoints when the deliveries waiting for a slot fill the queue
+const (
+	DeliveriesOverflowReject = "reject"
+	DeliveriesOverflowDrop   = "drop"
+)
+
+// Behaviors of an endpoint receiving a webhook that no destination's filters and event
+// types match
+const (
+	NoMatchAccept     = "accept"
+	NoMatchReject     = "reject"
+	NoMatchDeadLetter = "dead_letter"
+)
+
+// Behaviors of an endpoint receiving a webhook over its quota
+const (
+	QuotaReject  = "reject"
+	QuotaQueue   = "queue"
+	QuotaLogOnly = "log_only"
+)
+
+// Lifecycle events alerts are raised on
+const (
+	AlertEventDeliveryFailed    = "delivery_failed"
+	AlertEventDestinationPaused = "destination_paused"
+	AlertEventQueueHighWater    = "queue_high_water"
+)
+
+// AlertEvents lists the valid alert events
+var AlertEvents = map[string]bool{
+	AlertEventDeliveryFailed:    true,
+	AlertEventDestinationPaused: true,
+	AlertEventQueueHighWater:    true,
+}
+
+// Channels alerts are sent to
+const (
+	AlertChannelSlack     = "slack"
+	AlertChannelPagerDuty = "pagerduty"
+	AlertChannelHTTP      = "http"
+)
+
+// DefaultSigningHeader is the header holding the signatures of a signed destination
+const DefaultSigningHeader = "X-Webhook-Signature"
+
+// Correlation headers a destination can ask for
+const (
+	TraceHeadersRequestID = "request_id"
+	TraceHeadersB3        = "b3"
+)
+
+// Times a signed destination's requests are signed with
+const (
+	SigningTimestampAttempt  = "attempt"
+	SigningTimestampReceived = "received"
+)
+
+// Formats of form-encoded and XML webhooks sent to a destination
+const (
+	PayloadFormatVerbatim = "verbatim"
+	PayloadFormatJSON     = "json"
+)
+
+// Database drivers
+const (
+	DatabaseDriverPostgres   = "postgres"
+	DatabaseDriverClickHouse = "clickhouse"
+)
+
+// Payloads broadcast by websocket destinations
+const (
+	WebSocketPayloadMasked   = "masked"
+	WebSocketPayloadRedacted = "redacted"
+)
+
+// Acknowledgements required by Kafka destinations
+const (
+	KafkaAcksAll  = "all"
+	KafkaAcksOne  = "one"
+	KafkaAcksNone = "none"
+)
+
+// SASL mechanisms of Kafka destinations
+const (
+	KafkaSASLPlain       = "plain"
+	KafkaSASLScramSHA256 = "scram-sha-256"
+	KafkaSASLScramSHA512 = "scram-sha-512"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server    ServerConfig     `yaml:"server"`
-	Logging   LoggingConfig    `yaml:"logging"`
-	Telemetry TelemetryConfig  `yaml:"telemetry"`
-	Endpoints []EndpointConfig `yaml:"endpoints"`
+	Server      ServerConfig     `yaml:"server"`
+	Logging     LoggingConfig    `yaml:"logging"`
+	Telemetry   TelemetryConfig  `yaml:"telemetry"`
+	RetryState  RetryStateConfig `yaml:"retry_state"`
+	DeadLetters DeadLetterConfig `yaml:"dead_letters"`
+	History     HistoryConfig    `yaml:"history"`
+	Queue       QueueConfig      `yaml:"queue"`
+	Stats       StatsConfig      `yaml:"stats"`
+	Accounting  AccountingConfig `yaml:"accounting"`
+	Manifests   ManifestConfig   `yaml:"manifests"`
+	Recording   RecordingConfig  `yaml:"recording"`
+	Outbound    OutboundConfig   `yaml:"outbound"`
+	Alerts      []AlertConfig    `yaml:"alerts"`
+	Pipelines   []PipelineConfig `yaml:"pipelines"`
+	Endpoints   []EndpointConfig `yaml:"endpoints"`
+
+	// StaticEndpoints answer with a fixed response, next to the webhook endpoints
+	StaticEndpoints []StaticEndpointConfig `yaml:"static_endpoints"`
+}
+
+// StaticEndpointConfig represents an endpoint answering every request with a fixed response,
+// such as a provider's verification URL, a robots.txt or a landing page. Nothing is forwarded.
+// A GET endpoint also answers HEAD requests.
+type StaticEndpointConfig struct {
+	Path       string            `yaml:"path"`
+	Method     string            `yaml:"method"`
+	StatusCode int               `yaml:"status_code"`
+	Headers    map[string]string `yaml:"headers"`
+	Body       string            `yaml:"body"`
+}
+
+// AlertConfig represents an alert raised on a lifecycle event and sent to a channel.
+// A destination_paused alert fires when a destination stays paused longer than For, and
+// is resolved when it resumes; the other alerts fire when Threshold events of their type
+// happen within Window, then stay silent for a window.
+type AlertConfig struct {
+	Name      string             `yaml:"name"`
+	Event     string             `yaml:"event"`
+	For       time.Duration      `yaml:"for"`
+	Threshold int                `yaml:"threshold"`
+	Window    time.Duration      `yaml:"window"`
+	Channel   AlertChannelConfig `yaml:"channel"`
+}
+
+// AlertChannelConfig represents where alerts are sent: a Slack incoming webhook, the
+// PagerDuty Events API, or any HTTP endpoint receiving them as JSON. The URL, routing key
+// and header values are literal values or secret references resolved when the
+// configuration is loaded.
+type AlertChannelConfig struct {
+	Type       string            `yaml:"type"`
+	URL        string            `yaml:"url"`
+	RoutingKey string            `yaml:"routing_key"`
+	Headers    map[string]string `yaml:"headers"`
+}
+
+// OutboundConfig represents the defaults of the connections to the HTTP destinations
+type OutboundConfig struct {
+	// LocalAddress is the default local_address of the destinations
+	LocalAddress string `yaml:"local_address"`
+
+	// Proxy is the default proxy of the destinations
+	Proxy *ProxyConfig `yaml:"proxy"`
+
+	// Dial is the default dial of the HTTP destinations
+	Dial *DialConfig `yaml:"dial"`
+
+	// ConnectionPool is the default connection pool of the HTTP destinations
+	ConnectionPool *ConnectionPoolConfig `yaml:"connection_pool"`
+
+	// Deliveries bounds the deliveries running at once, unbounded when nil
+	Deliveries *DeliveriesConfig `yaml:"deliveries"`
+}
+
+// DeliveriesConfig bounds the deliveries running at once, across all destinations and to
+// each destination, each delivery running its attempts and retries in a slot. Deliveries
+// waiting for a slot are queued; once the queue is full, the endpoints reject the webhooks
+// they receive, or accept them and drop their deliveries, depending on the overflow.
+type DeliveriesConfig struct {
+	// MaxConcurrent bounds the deliveries running at once, 0 for no bound
+	MaxConcurrent int `yaml:"max_concurrent"`
+
+	// MaxPerDestination bounds the deliveries running at once to each destination, 0 for no bound
+	MaxPerDestination int `yaml:"max_per_destination"`
+
+	// QueueSize is the number of deliveries waiting for a slot
+	QueueSize int `yaml:"queue_size"`
+
+	// Overflow is reject (default), answering 503 to the senders, or drop
+	Overflow string `yaml:"overflow"`
+
+	// MaxRetryAfter caps the Retry-After of the rejections, the time the queue takes to
+	// drain at the current rate (default: 1m)
+	MaxRetryAfter time.Duration `yaml:"max_retry_after"`
+}
+
+// DialConfig represents how the connections to an HTTP destination are opened, for hosts
+// in IPv6-only networks or with a broken IPv6 route
+type DialConfig struct {
+	// IPFamily restricts or orders the addresses connected to: any (default), ipv4, ipv6,
+	// prefer_ipv4 or prefer_ipv6
+	IPFamily string `yaml:"ip_family"`
+
+	// FallbackDelay is how long a connection to the first address family is waited for
+	// before connecting to the other one in parallel (happy eyeballs)
+	FallbackDelay time.Duration `yaml:"fallback_delay"`
+
+	// Timeout bounds the time to open a connection
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// ConnectionPoolConfig tunes the connections kept open to an HTTP destination for reuse.
+// Destinations with the same connection settings share a pool.
+type ConnectionPoolConfig struct {
+	// MaxIdleConns bounds the idle connections kept across all hosts (default: 100)
+	MaxIdleConns int `yaml:"max_idle_conns"`
+
+	// MaxIdleConnsPerHost bounds the idle connections kept to each host (default: 100)
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+
+	// MaxConnsPerHost bounds the connections open to each host, requests waiting for one
+	// to be free, 0 for no bound
+	MaxConnsPerHost int `yaml:"max_conns_per_host"`
+
+	// IdleConnTimeout closes the connections idle for longer (default: 90s)
+	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout"`
+
+	// KeepAlive is the interval of the TCP keep-alive probes (default: 30s), negative to disable them
+	KeepAlive time.Duration `yaml:"keep_alive"`
+
+	// DisableKeepAlives closes each connection after its request instead of reusing it
+	DisableKeepAlives bool `yaml:"disable_keep_alives"`
+}
+
+// ProxyConfig represents the egress proxy an HTTP destination is reached through. The
+// credentials are either literal values or secret references resolved when the
+// configuration is loaded: env:NAME reads an environment variable and file:PATH a file,
+// such as a mounted secret.
+type ProxyConfig struct {
+	// URL of the proxy, with the http, https, socks5 or socks5h (remote DNS) scheme
+	URL string `yaml:"url"`
+
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// PipelineConfig represents destinations shared by several endpoints, for providers calling
+// a different URL per event type. Endpoints referencing a pipeline by name feed a single
+// handler: its destinations, filters, conversions, concurrency limits and metrics are shared.
+type PipelineConfig struct {
+	Name         string              `yaml:"name"`
+	Destinations []DestinationConfig `yaml:"destinations"`
+
+	// MaxDeliveryDuration is the default max_delivery_duration of the pipeline's destinations
+	MaxDeliveryDuration time.Duration `yaml:"max_delivery_duration"`
+
+	// CallbackURL receives a receipt for each delivery of the pipeline
+	CallbackURL string `yaml:"callback_url"`
+
+	// Labels are the labels of the endpoints using the pipeline
+	Labels map[string]string `yaml:"labels"`
+}
+
+// RetryStateConfig represents the configuration of retry state persistence.
+// When a directory is set, deliveries waiting for a retry are persisted there
+// and resumed after a restart.
+type RetryStateConfig struct {
+	Directory string          `yaml:"directory"`
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+// DeadLetterConfig represents the persistence of the deliveries that failed for good. When
+// a directory is set, each one is saved there with its webhook and last error, listed on
+// /admin/dead-letters and sent again on demand.
+type DeadLetterConfig struct {
+	Directory string `yaml:"directory"`
+}
+
+// HistoryConfig represents the history of the last webhooks, kept in memory with the
+// results of their deliveries. When a size is set, that many webhooks are listed on
+// /admin/deliveries and replayed on demand; the history is lost on a restart.
+//
+// When a results size is set, the delivery results of that many webhooks, without their
+// bodies, are kept in a least recently used cache and served on /deliveries/{id}, for
+// senders to check what happened to the webhook whose ID they were answered with.
+type HistoryConfig struct {
+	Size        int `yaml:"size"`
+	ResultsSize int `yaml:"results_size"`
+}
+
+// QueueConfig represents the persistent delivery queue. When a directory is set, each
+// accepted webhook is written there before the endpoint answers, then forwarded by a pool
+// of workers and removed once every destination's delivery is done. Webhooks still queued
+// on a restart are forwarded again, so deliveries are at least once.
+type QueueConfig struct {
+	Directory string `yaml:"directory"`
+	Workers   int    `yaml:"workers"`
+}
+
+// StatsConfig represents the persistence of daily delivery statistics. When a directory
+// is set, the attempts, failures and latencies of each destination are rolled up per UTC
+// day, written there at the flush interval, and served on /admin/stats.
+type StatsConfig struct {
+	Directory     string        `yaml:"directory"`
+	RetentionDays int           `yaml:"retention_days"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+// AccountingConfig represents the accounting of the webhooks delivered to each destination,
+// for billing internal customers: one CSV row per delivery, and Prometheus counters served
+// on /metrics/accounting
+type AccountingConfig struct {
+	CSVPath    string `yaml:"csv_path"`
+	Prometheus bool   `yaml:"prometheus"`
+}
+
+// ManifestConfig represents the export of signed delivery manifests for audits. When a
+// directory is set, the outcome of each delivery is journaled there, and the manifest of
+// each window, listing its deliveries, is written and signed once the window has ended.
+type ManifestConfig struct {
+	Directory string        `yaml:"directory"`
+	Window    time.Duration `yaml:"window"`
+
+	// Algorithm signs the manifests: hmac-sha256 (default) or ed25519
+	Algorithm string `yaml:"algorithm"`
+
+	// Key is the HMAC secret, or the base64 ed25519 private key: a literal value, env:NAME
+	// or file:PATH
+	Key string `yaml:"key"`
+}
+
+// RecordingConfig represents the recording of received webhooks as fixture files.
+// When a directory is set, each accepted webhook is saved there with the given
+// headers and JSON fields masked, to be replayed by integration tests.
+type RecordingConfig struct {
+	Directory     string   `yaml:"directory"`
+	RedactFields  []string `yaml:"redact_fields"`
+	RedactHeaders []string `yaml:"redact_headers"`
+}
+
+// RetentionConfig bounds the disk space used by a store. A background compactor
+// removes the oldest entries beyond any limit; a zero limit is disabled.
+type RetentionConfig struct {
+	MaxAge              time.Duration `yaml:"max_age"`
+	MaxTotalSize        int64         `yaml:"max_total_size"`
+	MaxCountPerEndpoint int           `yaml:"max_count_per_endpoint"`
+	Interval            time.Duration `yaml:"interval"`
+}
+
+// Enabled reports whether any retention limit is set
+func (r RetentionConfig) Enabled() bool {
+	return r.MaxAge > 0 || r.MaxTotalSize > 0 || r.MaxCountPerEndpoint > 0
 }
 
 // ServerConfig represents the server configuration
 type ServerConfig struct {
-	Port int    `yaml:"port"`
-	Host string `yaml:"host"`
+	Port    int           `yaml:"port"`
+	Host    string        `yaml:"host"`
+	Prewarm PrewarmConfig `yaml:"prewarm"`
+	Startup StartupConfig `yaml:"startup"`
+	Admin   AdminConfig   `yaml:"admin"`
+	Monitor MonitorConfig `yaml:"monitor"`
+
+	// CrashReports receives a report of each panic recovered while serving a request
+	CrashReports CrashReportConfig `yaml:"crash_reports"`
+
+	// Compression gzips the JSON responses of clients accepting it
+	Compression CompressionConfig `yaml:"compression"`
+
+	// Listeners are additional ports, each serving the endpoints of a tenant
+	Listeners []ListenerConfig `yaml:"listeners"`
+
+	// ListenerPorts is a range of ports, such as 9001-9010, whose ports are given in order
+	// to the listeners without a port
+	ListenerPorts string `yaml:"listener_ports"`
+
+	// Upgrade hands the listening sockets over to a new binary on SIGUSR2
+	Upgrade UpgradeConfig `yaml:"upgrade"`
+}
+
+// UpgradeConfig represents the graceful upgrades of the proxy. On SIGUSR2, the proxy starts
+// its binary again and hands it the listening sockets. Once the new process serves, which
+// it must within ReadyTimeout, the old one stops accepting connections and exits when its
+// requests and deliveries are done, or after DrainTimeout. PIDFile, when set, holds the PID
+// of the process serving, for process managers following it.
+type UpgradeConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	ReadyTimeout time.Duration `yaml:"ready_timeout"`
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+	PIDFile      string        `yaml:"pid_file"`
+}
+
+// ListenerConfig represents a port serving only the endpoints of one tenant, for network
+// policies isolating tenants by port. Its endpoints are no longer served on the main port,
+// which keeps the other endpoints and the admin routes. The name labels the listener's
+// requests in the logs and metrics.
+type ListenerConfig struct {
+	Name      string   `yaml:"name"`
+	Port      int      `yaml:"port"`
+	Endpoints []string `yaml:"endpoints"`
+}
+
+// DefaultListener is the name of the main port in the logs and metrics
+const DefaultListener = "default"
+
+// Listener returns the name of the listener serving an endpoint, or DefaultListener when
+// the endpoint is served on the main port
+func (s ServerConfig) Listener(path string) string {
+	for _, listener := range s.Listeners {
+		for _, endpoint := range listener.Endpoints {
+			if endpoint == path {
+				return listener.Name
+			}
+		}
+	}
+	return DefaultListener
+}
+
+// CompressionConfig represents the gzip compression of the JSON responses sent to clients
+// with Accept-Encoding: gzip, such as the metrics, history and dead letter APIs and the
+// endpoints' responses to senders. Responses smaller than MinSize bytes are sent as is.
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Level   int  `yaml:"level"`
+	MinSize int  `yaml:"min_size"`
+}
+
+// CrashReportConfig represents where reports of recovered panics are posted, as JSON
+type CrashReportConfig struct {
+	URL string `yaml:"url"`
+}
+
+// AdminConfig represents the protection of destructive admin routes, such as /metrics/reset.
+// They share a token bucket holding Burst actions and refilled with one action per Interval;
+// when ConfirmToken is set, they also require it in their confirm query parameter.
+type AdminConfig struct {
+	Burst        int           `yaml:"burst"`
+	Interval     time.Duration `yaml:"interval"`
+	ConfirmToken string        `yaml:"confirm_token"`
+}
+
+// StartupConfig represents the checks run on startup, before webhooks are accepted.
+// Duplicate destinations are always reported; probing sends a request to each HTTP
+// destination and reports the unreachable ones, failing the startup in strict mode
+// when a critical destination is unreachable.
+type StartupConfig struct {
+	Probe       bool   `yaml:"probe"`
+	ProbeMethod string `yaml:"probe_method"`
+	Strict      bool   `yaml:"strict"`
+}
+
+// MonitorConfig represents the self-monitoring of the proxy: queue depths, goroutines and,
+// when Probe is set, destination probes are sampled by a single background goroutine at
+// each Interval and served on /admin/status. A sample is cut short once it has spent its
+// Budget, so that monitoring never competes with deliveries.
+type MonitorConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	Budget   time.Duration `yaml:"budget"`
+	Probe    bool          `yaml:"probe"`
+}
+
+// PrewarmConfig represents the configuration of destination connection prewarming.
+// When enabled, a HEAD request is sent to each HTTP destination on startup, and then
+// at the given interval if set, so that deliveries reuse established connections.
+type PrewarmConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
 }
 
 // LoggingConfig represents the logging configuration
 type LoggingConfig struct {
-	Level    string `yaml:"level"`
-	Format   string `yaml:"format"`
-	Output   string `yaml:"output"`
-	FilePath string `yaml:"file_path"`
+	Level    string       `yaml:"level"`
+	Format   string       `yaml:"format"`
+	Output   string       `yaml:"output"`
+	FilePath string       `yaml:"file_path"`
+	Syslog   SyslogConfig `yaml:"syslog"`
+	GELF     GELFConfig   `yaml:"gelf"`
+
+	// AlsoStdout mirrors file output to stdout (default: true)
+	AlsoStdout *bool `yaml:"also_stdout"`
+
+	Body BodyLoggingConfig `yaml:"body"`
+
+	// FieldMap renames the time, level and msg fields of the json format
+	FieldMap map[string]string `yaml:"field_map"`
+
+	// Labels are added to every entry of the ecs and gcp formats
+	Labels map[string]string `yaml:"labels"`
+
+	ErrorSuppression ErrorSuppressionConfig `yaml:"error_suppression"`
+}
+
+// ErrorSuppressionConfig represents the configuration of repeated delivery error summarization
+type ErrorSuppressionConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Window  time.Duration `yaml:"window"`
+}
+
+// BodyLoggingConfig represents the configuration of request and response body logging
+type BodyLoggingConfig struct {
+	Request      bool     `yaml:"request"`
+	Response     bool     `yaml:"response"`
+	MaxSize      int      `yaml:"max_size"`
+	RedactFields []string `yaml:"redact_fields"`
+}
+
+// MirrorToStdout reports whether file output should also be written to stdout
+func (l LoggingConfig) MirrorToStdout() bool {
+	return l.AlsoStdout == nil || *l.AlsoStdout
+}
+
+// SyslogConfig represents the syslog output configuration.
+// An empty network logs to the local syslog daemon; udp and tcp send RFC5424 messages to a remote server.
+type SyslogConfig struct {
+	Network  string `yaml:"network"`
+	Address  string `yaml:"address"`
+	Tag      string `yaml:"tag"`
+	Facility string `yaml:"facility"`
+}
+
+// GELFConfig represents the GELF output configuration, shipping entries to Graylog.
+// UDP messages can be compressed and are chunked when too large; TCP messages are null-byte delimited.
+type GELFConfig struct {
+	Network     string `yaml:"network"`
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	Compression string `yaml:"compression"`
 }
 
 // TelemetryConfig represents the telemetry configuration
@@ -47,285 +799,2987 @@ type TelemetryConfig struct {
 	Enabled      bool   `yaml:"enabled"`
 	ExporterType string `yaml:"exporter_type"`
 	Endpoint     string `yaml:"endpoint"`
+
+	// Protocol is the transport of the otlp exporter: grpc (default) or http
+	Protocol string `yaml:"protocol"`
+
+	// Headers are sent with each export, such as the collector's API key
+	Headers map[string]string `yaml:"headers"`
+
+	// Insecure exports without TLS, for a collector on a trusted network
+	Insecure bool `yaml:"insecure"`
+
+	// TLS sets up the verification of the collector and the client certificate
+	TLS *TelemetryTLSConfig `yaml:"tls"`
+}
+
+// TelemetryTLSConfig represents the TLS settings of the connection to the OTLP collector
+type TelemetryTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// EndpointConfig represents an endpoint configuration
+type EndpointConfig struct {
+	Path         string              `yaml:"path"`
+	Destinations []DestinationConfig `yaml:"destinations"`
+
+	// Pipeline takes the destinations from the named pipeline, instead of Destinations
+	Pipeline string `yaml:"pipeline"`
+
+	// Provider applies the preset of a webhook provider: signature verification
+	// with Secret, and extraction of the delivery ID and event type
+	Provider string `yaml:"provider"`
+	Secret   string `yaml:"secret"`
+
+	// PreviousSecrets are still accepted while the provider's secret is rotated.
+	// Secrets are literal values, env:NAME or file:PATH.
+	PreviousSecrets []string `yaml:"previous_secrets"`
+
+	// SignatureTolerance is the maximum age of the timestamps signed by the stripe and slack
+	// providers and the stripe auth style, protecting against replays (default: 5m)
+	SignatureTolerance time.Duration `yaml:"signature_tolerance"`
+
+	// Response customizes the response returned to the sender of a webhook
+	Response *ResponseConfig `yaml:"response"`
+
+	// MaxDeliveryDuration is the default max_delivery_duration of the endpoint's destinations
+	MaxDeliveryDuration time.Duration `yaml:"max_delivery_duration"`
+
+	// CallbackURL receives a receipt when a delivery succeeds or dead-letters
+	CallbackURL string `yaml:"callback_url"`
+
+	// Quota bounds the number of webhooks the endpoint forwards per day and month
+	Quota *QuotaConfig `yaml:"quota"`
+
+	// Dedup answers the redeliveries of the webhooks the endpoint accepted without
+	// forwarding them again
+	Dedup *DedupConfig `yaml:"dedup"`
+
+	// OnNoMatch is what the endpoint does with a webhook that no destination matches:
+	// accept it with the endpoint's response, reject it with 422, or accept it and save
+	// it as a dead letter (default: accept)
+	OnNoMatch string `yaml:"on_no_match"`
+
+	// Charset converts the webhooks sent in legacy charsets to UTF-8
+	Charset *CharsetConfig `yaml:"charset"`
+
+	// Auth verifies the signatures of webhooks from senders without a provider preset
+	Auth *AuthConfig `yaml:"auth"`
+
+	// PreserveRawHeaders keeps the headers of the webhooks as sent, with the case and order
+	// of their names, and forwards them with their original case
+	PreserveRawHeaders bool `yaml:"preserve_raw_headers"`
+
+	// CompressResponse enables or disables the compression of the endpoint's responses,
+	// overriding server.compression.enabled
+	CompressResponse *bool `yaml:"compress_response"`
+
+	// Labels attribute the endpoint, for example to the team owning it, in its logs, spans,
+	// metrics, delivery history and dead letters
+	Labels map[string]string `yaml:"labels"`
+}
+
+// CompressesResponse reports whether the responses of the endpoint are compressed, given
+// whether the server compresses responses
+func (e EndpointConfig) CompressesResponse(server bool) bool {
+	if e.CompressResponse == nil {
+		return server
+	}
+	return *e.CompressResponse
 }
 
-// EndpointConfig represents an endpoint configuration
-type EndpointConfig struct {
-	Path         string              `yaml:"path"`
-	Destinations []DestinationConfig `yaml:"destinations"`
-}
+// AuthConfig represents the verification of the signatures of an endpoint's webhooks, in
+// the style of a well-known provider but with any header and secret. Webhooks with a
+// missing or invalid signature are rejected with 401 before being forwarded.
+type AuthConfig struct {
+	Style     string `yaml:"style"`
+	Algorithm string `yaml:"algorithm"`
+	Header    string `yaml:"header"`
+
+	// Secret is a literal value, env:NAME or file:PATH
+	Secret string `yaml:"secret"`
+
+	// PreviousSecrets are still accepted while Secret is rotated
+	PreviousSecrets []string `yaml:"previous_secrets"`
+}
+
+// CharsetConfig represents the conversion of webhook bodies to UTF-8, after the signature
+// is verified. The charset is read from the Content-Type or the XML declaration; a body
+// declaring none that is not valid UTF-8 is decoded with Fallback.
+type CharsetConfig struct {
+	Normalize bool   `yaml:"normalize"`
+	Fallback  string `yaml:"fallback"`
+}
+
+// QuotaConfig represents the quotas of webhooks forwarded by an endpoint, per UTC day
+// and month (0 = unlimited). Webhooks over a quota are rejected, queued until the
+// quota resets, or forwarded with a warning.
+type QuotaConfig struct {
+	Daily    int64  `yaml:"daily"`
+	Monthly  int64  `yaml:"monthly"`
+	OnExceed string `yaml:"on_exceed"`
+
+	// QueueSize bounds the webhooks held in queue mode; the next ones are rejected
+	QueueSize int `yaml:"queue_size"`
+}
+
+// DedupConfig represents the deduplication of an endpoint's webhooks by idempotency key: the
+// value of Header or, when Header is not set, the delivery ID extracted by the endpoint's
+// provider, or the SHA-256 of the body. A webhook with the key of a webhook accepted less
+// than TTL ago is answered with 200 OK and not forwarded.
+type DedupConfig struct {
+	Header string        `yaml:"header"`
+	TTL    time.Duration `yaml:"ttl"`
+
+	// MaxKeys bounds the keys remembered; the oldest are forgotten first
+	MaxKeys int `yaml:"max_keys"`
+}
+
+// FilterConfig represents a condition on the webhooks sent to a destination. It selects one
+// value of the webhook: a node of an XML webhook for XPath, the routing field extracted by the
+// endpoint's provider preset for Field, a request header for Header, or a dot-separated path
+// into a JSON body for JSONPath. The value must be set, equal Equals if set, and match Regex
+// if set.
+type FilterConfig struct {
+	XPath    string `yaml:"xpath"`
+	Field    string `yaml:"field"`
+	Header   string `yaml:"header"`
+	JSONPath string `yaml:"json_path"`
+
+	Equals string `yaml:"equals"`
+	Regex  string `yaml:"regex"`
+}
+
+// EventsConfig represents the event types of the endpoint's provider sent to a destination.
+// A webhook is sent if its event type matches Allow, when set, and not Deny. Event types are
+// matched as path.Match patterns, such as invoice.* for every Stripe invoice event.
+type EventsConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// Allows reports whether webhooks of an event type are sent to the destination
+func (e *EventsConfig) Allows(event string) bool {
+	if e == nil {
+		return true
+	}
+	if matchesEvent(e.Deny, event) {
+		return false
+	}
+	return len(e.Allow) == 0 || matchesEvent(e.Allow, event)
+}
+
+// matchesEvent reports whether an event type matches one of the patterns
+func matchesEvent(patterns []string, event string) bool {
+	for _, pattern := range patterns {
+		// Patterns are validated with the configuration
+		if matched, _ := path.Match(pattern, event); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// TransformConfig represents the reshaping of the webhooks sent to a destination. The template
+// is a text/template rendered with the endpoint, request ID, headers, provider metadata and
+// JSON, form or XML payload of the webhook, replacing the body sent to the destination.
+type TransformConfig struct {
+	Template string `yaml:"template"`
+
+	// ContentType is the Content-Type of the rendered body (default: application/json)
+	ContentType string `yaml:"content_type"`
+}
+
+// EncodingConfig represents the encoding of the webhooks sent to a destination, converted from
+// their JSON, form or XML payload: json, form, msgpack, or protobuf with the message of a schema.
+type EncodingConfig struct {
+	Format string `yaml:"format"`
+
+	// Schema is the FileDescriptorSet file of the protobuf message (protoc --descriptor_set_out)
+	Schema string `yaml:"schema"`
+
+	// Message is the full name of the protobuf message, as in shop.v1.Order
+	Message string `yaml:"message"`
+}
+
+// ResponseConfig represents the response returned to the sender of an accepted webhook.
+// The body is a text/template rendered with the endpoint, request ID, provider metadata and JSON payload.
+type ResponseConfig struct {
+	StatusCode int               `yaml:"status_code"`
+	Headers    map[string]string `yaml:"headers"`
+	Body       string            `yaml:"body"`
+}
+
+// DestinationConfig represents a destination configuration
+type DestinationConfig struct {
+	Type       string            `yaml:"type"`
+	URL        string            `yaml:"url"`
+	Method     string            `yaml:"method"`
+	Headers    map[string]string `yaml:"headers"`
+	Timeout    time.Duration     `yaml:"timeout"`
+	Retries    int               `yaml:"retries"`
+	RetryDelay time.Duration     `yaml:"retry_delay"`
+	WebSocket  *WebSocketConfig  `yaml:"websocket"`
+	Database   *DatabaseConfig   `yaml:"database"`
+	S3         *S3Config         `yaml:"s3"`
+	Slack      *SlackConfig      `yaml:"slack"`
+	Kafka      *KafkaConfig      `yaml:"kafka"`
+	SQS        *SQSConfig        `yaml:"sqs"`
+	SNS        *SNSConfig        `yaml:"sns"`
+
+	// RetryPolicy enables or disables retries per error class; unlisted classes are retried
+	RetryPolicy map[string]bool `yaml:"retry_policy"`
+
+	// Compression compresses the body sent to an HTTP destination (gzip, or empty for none)
+	Compression string `yaml:"compression"`
+
+	// FormFormat forwards form-encoded webhooks verbatim (default) or converted to JSON
+	FormFormat string `yaml:"form_format"`
+
+	// XMLFormat forwards XML webhooks verbatim (default) or converted to JSON
+	XMLFormat string `yaml:"xml_format"`
+
+	// Transform reshapes the body sent to the destination with a template
+	Transform *TransformConfig `yaml:"transform"`
+
+	// Encoding converts the body sent to the destination to another format
+	Encoding *EncodingConfig `yaml:"encoding"`
+
+	// Filters restrict the destination to the webhooks matching all of them
+	Filters []FilterConfig `yaml:"filters"`
+
+	// Events restricts the destination to event types of the endpoint's provider
+	Events *EventsConfig `yaml:"events"`
+
+	// Success defines the responses of an HTTP destination counted as successes (default: any 2xx)
+	Success *SuccessConfig `yaml:"success"`
+
+	// CaptureHeaders lists the response headers of an HTTP destination kept in its metrics
+	// and in the delivery history, such as X-RateLimit-Remaining
+	CaptureHeaders []string `yaml:"capture_headers"`
+
+	// MaxDeliveryDuration bounds the total time spent across all attempts of a delivery (0 = unbounded)
+	MaxDeliveryDuration time.Duration `yaml:"max_delivery_duration"`
+
+	// Concurrency enables the adaptive concurrency limit of the destination
+	Concurrency *ConcurrencyConfig `yaml:"concurrency"`
+
+	// Critical fails a strict startup when the destination is unreachable
+	Critical bool `yaml:"critical"`
+
+	// Chaos injects faults into the deliveries of the destination, for testing only
+	Chaos *ChaosConfig `yaml:"chaos"`
+
+	// TraceHeaders lists the correlation headers added to the requests sent to the
+	// destination: request_id (X-Request-Id) and b3 (Zipkin X-B3-* headers)
+	TraceHeaders []string `yaml:"trace_headers"`
+
+	// Signing signs the requests sent to the destination
+	Signing *SigningConfig `yaml:"signing"`
+
+	// Cache reuses the successful responses of a GET destination
+	Cache *CacheConfig `yaml:"cache"`
+
+	// MaxBodySize bounds the size in bytes of the bodies sent to the destination (0 = unbounded)
+	MaxBodySize int64 `yaml:"max_body_size"`
+
+	// OnOversize dead-letters (default) or truncates the bodies larger than MaxBodySize
+	OnOversize string `yaml:"on_oversize"`
+
+	// DNSOutage pauses the HTTP destination while its host does not resolve
+	DNSOutage *DNSOutageConfig `yaml:"dns_outage"`
+
+	// MaintenanceWindows are the recurring windows during which the destination's deliveries
+	// wait for the window to end instead of being attempted
+	MaintenanceWindows []MaintenanceWindowConfig `yaml:"maintenance_windows"`
+
+	// Proxy sends the requests to the HTTP destination through an egress proxy
+	Proxy *ProxyConfig `yaml:"proxy"`
+
+	// LocalAddress binds the connections to the HTTP destination to a local IP address, or
+	// to the first address of a network interface, for destinations allowlisting an egress IP
+	LocalAddress string `yaml:"local_address"`
+
+	// Protocol is the HTTP protocol of the connections to the HTTP destination: auto
+	// (default), http1 or h2c
+	Protocol string `yaml:"protocol"`
+
+	// Dial sets the address family and timeouts of the connections to the HTTP destination
+	Dial *DialConfig `yaml:"dial"`
+
+	// ConnectionPool tunes the connections kept open to the HTTP destination
+	ConnectionPool *ConnectionPoolConfig `yaml:"connection_pool"`
+
+	// Labels attribute the destination, adding to or overriding the labels of its endpoint
+	Labels map[string]string `yaml:"labels"`
+}
+
+// DNSOutageConfig represents the handling of an HTTP destination's DNS outages. After
+// Threshold consecutive DNS resolution failures, the destination is paused: its deliveries
+// wait without consuming their retries, while the host is resolved again after
+// InitialBackoff, then after delays doubling up to MaxBackoff, until it resolves.
+type DNSOutageConfig struct {
+	Threshold      int           `yaml:"threshold"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// MaintenanceWindowConfig represents a recurring maintenance window of a destination. The
+// window opens at Start, a HH:MM time in Timezone (default UTC), on each of Days (default
+// every day), and lasts Duration.
+type MaintenanceWindowConfig struct {
+	Days     []string      `yaml:"days"`
+	Start    string        `yaml:"start"`
+	Duration time.Duration `yaml:"duration"`
+	Timezone string        `yaml:"timezone"`
+}
+
+// Parse parses the window
+func (w MaintenanceWindowConfig) Parse() (*maintenance.Window, error) {
+	return maintenance.Parse(w.Days, w.Start, w.Duration, w.Timezone)
+}
+
+// ChaosConfig represents the faults injected into a destination's deliveries, so that
+// consumers can test their retry and deduplication handling. Rates are probabilities
+// between 0 and 1 applied to each webhook; delays are drawn up to MaxDelay.
+type ChaosConfig struct {
+	DelayRate     float64       `yaml:"delay_rate"`
+	MaxDelay      time.Duration `yaml:"max_delay"`
+	DropRate      float64       `yaml:"drop_rate"`
+	DuplicateRate float64       `yaml:"duplicate_rate"`
+}
+
+// CacheConfig represents the caching of a GET destination's successful responses, keyed by
+// URL including its query. While a response is cached, deliveries reuse it without a request.
+type CacheConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// SigningConfig represents the signing of the requests sent to an HTTP destination.
+// Requests carry one signature per secret, so that the receiver can rotate its key.
+type SigningConfig struct {
+	// Secrets sign each request, the current secret first
+	Secrets []string `yaml:"secrets"`
+
+	// Header holds the signatures
+	Header string `yaml:"header"`
+
+	// TimestampSource is the time signed: each attempt's (default), so that every attempt is
+	// signed again, or the time the webhook was received, so that retries carry the same signature
+	TimestampSource string `yaml:"timestamp_source"`
+
+	// MaxAge signs an attempt again when the received time is older, for receivers rejecting
+	// old timestamps (0 = never)
+	MaxAge time.Duration `yaml:"max_age"`
+
+	// Skew is added to the signed time, negative for receivers whose clock runs behind
+	Skew time.Duration `yaml:"skew"`
+}
+
+// SuccessConfig represents the rules an HTTP destination's response must follow to count
+// as a successful delivery. Responses breaking a rule are failures, retried as usual.
+type SuccessConfig struct {
+	// StatusCodes lists the accepted status codes, instead of any 2xx
+	StatusCodes []int `yaml:"status_codes"`
+
+	// BodyContains must appear in the response body
+	BodyContains string `yaml:"body_contains"`
+
+	// BodyNotContains must not appear in the response body
+	BodyNotContains string `yaml:"body_not_contains"`
+}
+
+// ConcurrencyConfig represents the configuration of a destination's adaptive concurrency limit.
+// The limit grows while the destination answers below the latency threshold and shrinks
+// by the backoff factor when it slows down or fails; requests above the limit are shed.
+type ConcurrencyConfig struct {
+	InitialLimit     int           `yaml:"initial_limit"`
+	MinLimit         int           `yaml:"min_limit"`
+	MaxLimit         int           `yaml:"max_limit"`
+	LatencyThreshold time.Duration `yaml:"latency_threshold"`
+	Backoff          float64       `yaml:"backoff"`
+}
+
+// WebSocketConfig represents the configuration of a websocket broadcast destination
+type WebSocketConfig struct {
+	Path string `yaml:"path"`
+
+	// Token subscribers authenticate with, as a bearer token or the token query parameter.
+	// Literal value, env:NAME or file:PATH.
+	Token string `yaml:"token"`
+
+	AllowedOrigins []string `yaml:"allowed_origins"`
+
+	// Payload is masked, only the structure of the payload being broadcast, or redacted,
+	// the payload with the redact fields masked. Redacted by default when there are redact
+	// fields, masked otherwise.
+	Payload       string   `yaml:"payload"`
+	RedactFields  []string `yaml:"redact_fields"`
+	RedactHeaders []string `yaml:"redact_headers"`
+}
+
+// DatabaseConfig represents the configuration of a database insert destination
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+	Table  string `yaml:"table"`
+}
+
+// S3Config represents the configuration of an S3-compatible object storage destination
+type S3Config struct {
+	Bucket          string        `yaml:"bucket"`
+	Region          string        `yaml:"region"`
+	Endpoint        string        `yaml:"endpoint"`
+	PathStyle       bool          `yaml:"path_style"`
+	AccessKeyID     string        `yaml:"access_key_id"`
+	SecretAccessKey string        `yaml:"secret_access_key"`
+	KeyTemplate     string        `yaml:"key_template"`
+	Gzip            bool          `yaml:"gzip"`
+	BatchSize       int           `yaml:"batch_size"`
+	FlushInterval   time.Duration `yaml:"flush_interval"`
+}
+
+// SQSConfig represents the configuration of an SQS destination, sending each webhook as a
+// message of a queue, given by its URL or ARN. The AWS default chain provides the credentials
+// when no static ones are set: environment, shared configuration, IRSA or instance role.
+type SQSConfig struct {
+	Queue           string `yaml:"queue"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+
+	// MessageGroupID is a transform template of the message group, required by FIFO queues
+	MessageGroupID string `yaml:"message_group_id"`
+
+	// Attributes are transform templates of the message attributes, by name
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// SNSConfig represents the configuration of an SNS destination, publishing each webhook as a
+// message of a topic. Credentials are resolved as for SQS destinations.
+type SNSConfig struct {
+	TopicARN        string `yaml:"topic_arn"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+
+	// MessageGroupID is a transform template of the message group, required by FIFO topics
+	MessageGroupID string `yaml:"message_group_id"`
+
+	// Attributes are transform templates of the message attributes, by name
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// MaxMessageAttributes is the number of message attributes of SQS and SNS destinations; AWS
+// accepts 10 and the proxy keeps one to mark base64 bodies
+const MaxMessageAttributes = 9
+
+// FIFOSuffix ends the names of FIFO queues and topics
+const FIFOSuffix = ".fifo"
+
+// KafkaConfig represents the configuration of a Kafka destination, publishing each webhook as a
+// record of a topic. The key template is a transform template rendered with the endpoint,
+// headers and payload of the webhook; records without a key are spread over the partitions.
+type KafkaConfig struct {
+	Brokers     []string `yaml:"brokers"`
+	Topic       string   `yaml:"topic"`
+	KeyTemplate string   `yaml:"key_template"`
+
+	// Acks is the acknowledgement waited for: all in-sync replicas (default), the leader, or none
+	Acks string `yaml:"acks"`
+
+	// SASL authenticates to the brokers
+	SASL *KafkaSASLConfig `yaml:"sasl"`
+
+	// TLS connects to the brokers over TLS, verifying them and with an optional client certificate
+	TLS *KafkaTLSConfig `yaml:"tls"`
+}
+
+// KafkaSASLConfig represents the SASL authentication of a Kafka destination. The username and
+// password are literal values or secret references resolved when the configuration is loaded.
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// KafkaTLSConfig represents the TLS settings of the connections to the Kafka brokers
+type KafkaTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// SlackConfig represents the message posted to a Slack incoming webhook by a slack destination.
+// Text is a transform template rendered with the data of the webhook; when empty, the message
+// summarizes the webhook with its payload in a code block.
+type SlackConfig struct {
+	Text      string `yaml:"text"`
+	Channel   string `yaml:"channel"`
+	Username  string `yaml:"username"`
+	IconEmoji string `yaml:"icon_emoji"`
+}
+
+// SendsHTTP reports whether the destination receives webhooks as HTTP requests to its URL,
+// which http and slack destinations do
+func (d DestinationConfig) SendsHTTP() bool {
+	return d.Type == "" || d.Type == DestinationTypeHTTP || d.Type == DestinationTypeSlack
+}
+
+// Key returns a stable identifier for the destination, used in logs and metrics
+func (d DestinationConfig) Key() string {
+	switch d.Type {
+	case DestinationTypeWebSocket:
+		if d.WebSocket != nil {
+			return "websocket:" + d.WebSocket.Path
+		}
+		return "websocket:"
+	case DestinationTypeDatabase:
+		if d.Database != nil {
+			return "database:" + d.Database.Driver + ":" + d.Database.Table
+		}
+		return "database:"
+	case DestinationTypeS3:
+		if d.S3 != nil {
+			return "s3://" + d.S3.Bucket
+		}
+		return "s3://"
+	case DestinationTypeKafka:
+		if d.Kafka != nil && len(d.Kafka.Brokers) > 0 {
+			return "kafka://" + d.Kafka.Brokers[0] + "/" + d.Kafka.Topic
+		}
+		return "kafka://"
+	case DestinationTypeSQS:
+		if d.SQS != nil {
+			return "sqs:" + d.SQS.Queue
+		}
+		return "sqs:"
+	case DestinationTypeSNS:
+		if d.SNS != nil {
+			return "sns:" + d.SNS.TopicARN
+		}
+		return "sns:"
+	default:
+		return d.URL
+	}
+}
+
+// Secrets returns the secrets accepted for the endpoint's signatures, the current secret first
+func (e EndpointConfig) Secrets() []string {
+	return append([]string{e.Secret}, e.PreviousSecrets...)
+}
+
+// Secrets returns the secrets accepted for the signatures, the current secret first
+func (a AuthConfig) Secrets() []string {
+	return append([]string{a.Secret}, a.PreviousSecrets...)
+}
+
+// DuplicateDestinations returns the keys of the destinations configured more than once
+// on the endpoint, in configuration order. Each duplicate receives every webhook.
+func (e EndpointConfig) DuplicateDestinations() []string {
+	seen := make(map[string]int, len(e.Destinations))
+	var duplicates []string
+	for _, dest := range e.Destinations {
+		seen[dest.Key()]++
+		if seen[dest.Key()] == 2 {
+			duplicates = append(duplicates, dest.Key())
+		}
+	}
+	return duplicates
+}
+
+// RetryErrorClass reports whether the retry policy allows retrying errors of the given class
+func (d DestinationConfig) RetryErrorClass(class string) bool {
+	retry, ok := d.RetryPolicy[class]
+	return !ok || retry
+}
+
+// LoadConfig loads the configuration from a file
+func LoadConfig(path string) (*Config, error) {
+	// Read the configuration file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses a YAML configuration the way LoadConfig loads it from a file: secret
+// references are resolved, defaults and environment variable overrides applied, and the
+// result validated
+func Parse(data []byte) (*Config, error) {
+	// Parse the YAML
+	var config Config
+	err := yaml.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	// Give the endpoints referencing a pipeline its destinations
+	if err := resolvePipelines(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Resolve the secret references of the proxy credentials, alert channels, Kafka credentials,
+	// websocket tokens, endpoint auth, manifest key and telemetry headers
+	if err := resolveProxyCredentials(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := resolveAlertChannels(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := resolveKafkaCredentials(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := resolveWebSocketTokens(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := resolveEndpointAuth(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if config.Manifests.Key, err = resolveSecretReference(config.Manifests.Key); err != nil {
+		return nil, fmt.Errorf("invalid configuration: manifests.key: %w", err)
+	}
+	for name, value := range config.Telemetry.Headers {
+		if config.Telemetry.Headers[name], err = resolveSecretReference(value); err != nil {
+			return nil, fmt.Errorf("invalid configuration: telemetry.headers.%s: %w", name, err)
+		}
+	}
+
+	// Set default values
+	setDefaultValues(&config)
+
+	// Apply environment variable overrides
+	applyEnvironmentOverrides(&config)
+
+	// Give the listeners without a port theirs, once the main port is known
+	if err := assignListenerPorts(&config.Server); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Validate the configuration
+	err = validateConfig(&config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &config, nil
+}
+
+// ParseEndpoint parses a candidate endpoint, in YAML or JSON, the way LoadConfig would load it
+// in the given configuration: its pipeline is resolved, and it inherits the defaults of the
+// configuration before being validated. An endpoint without a path gets defaultPath. The
+// configuration is not modified. Candidates come from API callers, so their own secrets must
+// be literal values: secret references, which would read the proxy's environment and files,
+// are rejected.
+func ParseEndpoint(data []byte, defaultPath string, base *Config) (EndpointConfig, error) {
+	var endpoint EndpointConfig
+	if err := yaml.Unmarshal(data, &endpoint); err != nil {
+		return EndpointConfig{}, fmt.Errorf("error parsing endpoint: %w", err)
+	}
+	if endpoint.Path == "" {
+		endpoint.Path = defaultPath
+	}
+	if err := rejectSecretReferences(endpoint); err != nil {
+		return EndpointConfig{}, err
+	}
+
+	// Only the secret references of the pipelines of the configuration remain to be
+	// resolved, those of its endpoints being already
+	candidate := Config{Pipelines: base.Pipelines, Endpoints: []EndpointConfig{endpoint}}
+	if err := resolvePipelines(&candidate); err != nil {
+		return EndpointConfig{}, err
+	}
+	if err := resolveProxyCredentials(&candidate); err != nil {
+		return EndpointConfig{}, err
+	}
+	if err := resolveKafkaCredentials(&candidate); err != nil {
+		return EndpointConfig{}, err
+	}
+	if err := resolveWebSocketTokens(&candidate); err != nil {
+		return EndpointConfig{}, err
+	}
+	if err := resolveEndpointAuth(&candidate); err != nil {
+		return EndpointConfig{}, err
+	}
+
+	resolved := *base
+	resolved.Endpoints = candidate.Endpoints
+	setDefaultValues(&resolved)
+	if err := validateEndpointConfig(0, resolved.Endpoints[0]); err != nil {
+		return EndpointConfig{}, err
+	}
+	return resolved.Endpoints[0], nil
+}
+
+// resolveProxyCredentials replaces the secret references of the proxies' credentials by
+// their values. Resolved proxies are copies, as pipeline destinations share theirs.
+func resolveProxyCredentials(config *Config) error {
+	if config.Outbound.Proxy != nil {
+		proxy, err := resolveProxyConfig(*config.Outbound.Proxy)
+		if err != nil {
+			return fmt.Errorf("outbound.proxy: %w", err)
+		}
+		config.Outbound.Proxy = &proxy
+	}
+
+	for i := range config.Endpoints {
+		for j := range config.Endpoints[i].Destinations {
+			dest := &config.Endpoints[i].Destinations[j]
+			if dest.Proxy == nil {
+				continue
+			}
+			proxy, err := resolveProxyConfig(*dest.Proxy)
+			if err != nil {
+				return fmt.Errorf("endpoint[%d].destination[%d].proxy: %w", i, j, err)
+			}
+			dest.Proxy = &proxy
+		}
+	}
+
+	return nil
+}
+
+// resolveProxyConfig resolves the secret references of a proxy's credentials
+func resolveProxyConfig(proxy ProxyConfig) (ProxyConfig, error) {
+	var err error
+	if proxy.Username, err = resolveSecretReference(proxy.Username); err != nil {
+		return ProxyConfig{}, fmt.Errorf("username: %w", err)
+	}
+	if proxy.Password, err = resolveSecretReference(proxy.Password); err != nil {
+		return ProxyConfig{}, fmt.Errorf("password: %w", err)
+	}
+	return proxy, nil
+}
+
+// resolveKafkaCredentials replaces the secret references of the Kafka destinations' SASL
+// credentials by their values. Resolved credentials are copies, as pipeline destinations share theirs.
+func resolveKafkaCredentials(config *Config) error {
+	for i := range config.Endpoints {
+		for j := range config.Endpoints[i].Destinations {
+			dest := &config.Endpoints[i].Destinations[j]
+			if dest.Kafka == nil || dest.Kafka.SASL == nil {
+				continue
+			}
+
+			sasl := *dest.Kafka.SASL
+			var err error
+			if sasl.Username, err = resolveSecretReference(sasl.Username); err != nil {
+				return fmt.Errorf("endpoint[%d].destination[%d].kafka.sasl.username: %w", i, j, err)
+			}
+			if sasl.Password, err = resolveSecretReference(sasl.Password); err != nil {
+				return fmt.Errorf("endpoint[%d].destination[%d].kafka.sasl.password: %w", i, j, err)
+			}
+			kafka := *dest.Kafka
+			kafka.SASL = &sasl
+			dest.Kafka = &kafka
+		}
+	}
+	return nil
+}
+
+// resolveWebSocketTokens replaces the secret references of the websocket destinations'
+// subscription tokens by their values. Resolved configurations are copies, as pipeline
+// destinations share theirs.
+func resolveWebSocketTokens(config *Config) error {
+	for i := range config.Endpoints {
+		for j := range config.Endpoints[i].Destinations {
+			dest := &config.Endpoints[i].Destinations[j]
+			if dest.WebSocket == nil {
+				continue
+			}
+
+			ws := *dest.WebSocket
+			var err error
+			if ws.Token, err = resolveSecretReference(ws.Token); err != nil {
+				return fmt.Errorf("endpoint[%d].destination[%d].websocket.token: %w", i, j, err)
+			}
+			dest.WebSocket = &ws
+		}
+	}
+	return nil
+}
+
+// resolveEndpointAuth replaces the secret references of the endpoints' provider secrets and
+// auth blocks by their values
+func resolveEndpointAuth(config *Config) error {
+	for i := range config.Endpoints {
+		endpoint := &config.Endpoints[i]
+
+		var err error
+		if endpoint.Secret, err = resolveSecretReference(endpoint.Secret); err != nil {
+			return fmt.Errorf("endpoint[%d].secret: %w", i, err)
+		}
+		for j, secret := range endpoint.PreviousSecrets {
+			if endpoint.PreviousSecrets[j], err = resolveSecretReference(secret); err != nil {
+				return fmt.Errorf("endpoint[%d].previous_secrets[%d]: %w", i, j, err)
+			}
+		}
+
+		auth := endpoint.Auth
+		if auth == nil {
+			continue
+		}
+		if auth.Secret, err = resolveSecretReference(auth.Secret); err != nil {
+			return fmt.Errorf("endpoint[%d].auth.secret: %w", i, err)
+		}
+		for j, secret := range auth.PreviousSecrets {
+			if auth.PreviousSecrets[j], err = resolveSecretReference(secret); err != nil {
+				return fmt.Errorf("endpoint[%d].auth.previous_secrets[%d]: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rejectSecretReferences returns an error when a secret of an endpoint, or of its own
+// destinations, is a secret reference. The error does not depend on the reference.
+func rejectSecretReferences(endpoint EndpointConfig) error {
+	fields := []string{"secret"}
+	values := []string{endpoint.Secret}
+	add := func(field, value string) {
+		fields, values = append(fields, field), append(values, value)
+	}
+	for i, secret := range endpoint.PreviousSecrets {
+		add(fmt.Sprintf("previous_secrets[%d]", i), secret)
+	}
+	if auth := endpoint.Auth; auth != nil {
+		add("auth.secret", auth.Secret)
+		for i, secret := range auth.PreviousSecrets {
+			add(fmt.Sprintf("auth.previous_secrets[%d]", i), secret)
+		}
+	}
+	for i, dest := range endpoint.Destinations {
+		if dest.Proxy != nil {
+			add(fmt.Sprintf("destination[%d].proxy.username", i), dest.Proxy.Username)
+			add(fmt.Sprintf("destination[%d].proxy.password", i), dest.Proxy.Password)
+		}
+		if dest.Kafka != nil && dest.Kafka.SASL != nil {
+			add(fmt.Sprintf("destination[%d].kafka.sasl.username", i), dest.Kafka.SASL.Username)
+			add(fmt.Sprintf("destination[%d].kafka.sasl.password", i), dest.Kafka.SASL.Password)
+		}
+		if dest.WebSocket != nil {
+			add(fmt.Sprintf("destination[%d].websocket.token", i), dest.WebSocket.Token)
+		}
+	}
+
+	for i, value := range values {
+		if isSecretReference(value) {
+			return fmt.Errorf("endpoint.%s: secret references are not allowed in a candidate endpoint", fields[i])
+		}
+	}
+	return nil
+}
+
+// isSecretReference reports whether a value is a secret reference
+func isSecretReference(value string) bool {
+	return strings.HasPrefix(value, "env:") || strings.HasPrefix(value, "file:")
+}
+
+// resolveAlertChannels replaces the secret references of the alert channels by their values
+func resolveAlertChannels(config *Config) error {
+	for i := range config.Alerts {
+		channel := &config.Alerts[i].Channel
+
+		var err error
+		if channel.URL, err = resolveSecretReference(channel.URL); err != nil {
+			return fmt.Errorf("alert[%d].channel.url: %w", i, err)
+		}
+		if channel.RoutingKey, err = resolveSecretReference(channel.RoutingKey); err != nil {
+			return fmt.Errorf("alert[%d].channel.routing_key: %w", i, err)
+		}
+		for name, value := range channel.Headers {
+			if channel.Headers[name], err = resolveSecretReference(value); err != nil {
+				return fmt.Errorf("alert[%d].channel.headers.%s: %w", i, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSecretReference returns the value of a secret reference, env:NAME for an
+// environment variable or file:PATH for a file without its trailing newline, or the
+// value itself when it is not a reference
+func resolveSecretReference(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, "file:"):
+		path := strings.TrimPrefix(value, "file:")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return value, nil
+	}
+}
+
+// resolvePipelines copies the destinations of the referenced pipeline into each endpoint
+// using one, so that defaults and validation apply to them like to any destination
+func resolvePipelines(config *Config) error {
+	pipelines := make(map[string]PipelineConfig, len(config.Pipelines))
+	for i, pipeline := range config.Pipelines {
+		if !pipelineNamePattern.MatchString(pipeline.Name) {
+			return fmt.Errorf("pipeline[%d]: invalid name: %q (letters, digits, '_', '.' and '-' only)", i, pipeline.Name)
+		}
+		if _, ok := pipelines[pipeline.Name]; ok {
+			return fmt.Errorf("pipeline[%d]: duplicate name: %s", i, pipeline.Name)
+		}
+		if len(pipeline.Destinations) == 0 {
+			return fmt.Errorf("pipeline[%d]: at least one destination is required", i)
+		}
+		pipelines[pipeline.Name] = pipeline
+	}
+
+	for i := range config.Endpoints {
+		endpoint := &config.Endpoints[i]
+		if endpoint.Pipeline == "" {
+			continue
+		}
+
+		pipeline, ok := pipelines[endpoint.Pipeline]
+		if !ok {
+			return fmt.Errorf("endpoint[%d]: unknown pipeline: %s", i, endpoint.Pipeline)
+		}
+
+		// The destinations are shared, so they cannot be changed per endpoint
+		if len(endpoint.Destinations) > 0 {
+			return fmt.Errorf("endpoint[%d]: destinations cannot be set with a pipeline", i)
+		}
+		if endpoint.MaxDeliveryDuration != 0 {
+			return fmt.Errorf("endpoint[%d]: max_delivery_duration cannot be set with a pipeline, set it on the pipeline", i)
+		}
+		if endpoint.CallbackURL != "" {
+			return fmt.Errorf("endpoint[%d]: callback_url cannot be set with a pipeline, set it on the pipeline", i)
+		}
+		if len(endpoint.Labels) > 0 {
+			return fmt.Errorf("endpoint[%d]: labels cannot be set with a pipeline, set them on the pipeline", i)
+		}
+
+		endpoint.Destinations = append([]DestinationConfig(nil), pipeline.Destinations...)
+		endpoint.MaxDeliveryDuration = pipeline.MaxDeliveryDuration
+		endpoint.CallbackURL = pipeline.CallbackURL
+		endpoint.Labels = pipeline.Labels
+	}
+
+	return nil
+}
+
+// mergeLabels returns the labels of an endpoint with those of one of its destinations, which
+// take precedence, in a new map since pipeline destinations share theirs
+func mergeLabels(endpoint, destination map[string]string) map[string]string {
+	if len(endpoint) == 0 {
+		return destination
+	}
+	merged := make(map[string]string, len(endpoint)+len(destination))
+	for key, value := range endpoint {
+		merged[key] = value
+	}
+	for key, value := range destination {
+		merged[key] = value
+	}
+	return merged
+}
+
+// setDefaultValues sets default values for the configuration
+func setDefaultValues(config *Config) {
+	// Server defaults
+	if config.Server.Port == 0 {
+		config.Server.Port = 8080
+	}
+	if config.Server.Host == "" {
+		config.Server.Host = DefaultHost
+	}
+	if config.Server.Startup.ProbeMethod == "" {
+		config.Server.Startup.ProbeMethod = http.MethodHead
+	}
+	if config.Server.Admin.Burst == 0 {
+		config.Server.Admin.Burst = DefaultAdminBurst
+	}
+	if config.Server.Admin.Interval == 0 {
+		config.Server.Admin.Interval = DefaultAdminInterval
+	}
+	if config.Server.Monitor.Interval == 0 {
+		config.Server.Monitor.Interval = DefaultMonitorInterval
+	}
+	if config.Server.Monitor.Budget == 0 {
+		config.Server.Monitor.Budget = DefaultMonitorBudget
+	}
+	if config.Server.Upgrade.ReadyTimeout == 0 {
+		config.Server.Upgrade.ReadyTimeout = DefaultUpgradeReadyTimeout
+	}
+	if config.Server.Upgrade.DrainTimeout == 0 {
+		config.Server.Upgrade.DrainTimeout = DefaultUpgradeDrainTimeout
+	}
+	if config.Server.Compression.Level == 0 {
+		config.Server.Compression.Level = DefaultCompressionLevel
+	}
+	if config.Server.Compression.MinSize == 0 {
+		config.Server.Compression.MinSize = DefaultCompressionMinSize
+	}
+
+	// Logging defaults
+	if config.Logging.Level == "" {
+		config.Logging.Level = DefaultLogLevel
+	}
+	if config.Logging.Format == "" {
+		config.Logging.Format = DefaultLogFormat
+	}
+	if config.Logging.Output == "" {
+		config.Logging.Output = DefaultLogOutput
+	}
+	if config.Logging.ErrorSuppression.Window == 0 {
+		config.Logging.ErrorSuppression.Window = DefaultErrorSuppressionWindow
+	}
+	if config.Logging.Body.MaxSize == 0 {
+		config.Logging.Body.MaxSize = DefaultBodyLogMaxSize
+	}
+	if config.Logging.GELF.Network == "" {
+		config.Logging.GELF.Network = "udp"
+	}
+	if config.Logging.GELF.Port == 0 {
+		config.Logging.GELF.Port = DefaultGELFPort
+	}
+	if config.Logging.GELF.Compression == "" {
+		config.Logging.GELF.Compression = "none"
+	}
+	if config.Logging.AlsoStdout == nil {
+		alsoStdout := true
+		config.Logging.AlsoStdout = &alsoStdout
+	}
+
+	// Retry state defaults
+	if config.RetryState.Retention.Interval == 0 {
+		config.RetryState.Retention.Interval = DefaultRetentionInterval
+	}
+
+	// Bounded deliveries defaults
+	if d := config.Outbound.Deliveries; d != nil {
+		if d.QueueSize == 0 {
+			d.QueueSize = DefaultDeliveriesQueueSize
+		}
+		if d.Overflow == "" {
+			d.Overflow = DeliveriesOverflowReject
+		}
+		if d.MaxRetryAfter == 0 {
+			d.MaxRetryAfter = DefaultDeliveriesMaxRetryAfter
+		}
+	}
+
+	// Queue defaults
+	if config.Queue.Workers == 0 {
+		config.Queue.Workers = DefaultQueueWorkers
+	}
+
+	// Stats defaults
+	if config.Stats.RetentionDays == 0 {
+		config.Stats.RetentionDays = DefaultStatsRetentionDays
+	}
+	if config.Stats.FlushInterval == 0 {
+		config.Stats.FlushInterval = DefaultStatsFlushInterval
+	}
+
+	// Manifest defaults
+	if config.Manifests.Window == 0 {
+		config.Manifests.Window = DefaultManifestWindow
+	}
+	if config.Manifests.Algorithm == "" {
+		config.Manifests.Algorithm = manifest.AlgorithmHMAC
+	}
+
+	// Alert defaults
+	for i := range config.Alerts {
+		alert := &config.Alerts[i]
+		if alert.Threshold == 0 {
+			alert.Threshold = 1
+		}
+		if alert.Window == 0 {
+			alert.Window = DefaultAlertWindow
+		}
+		if alert.Channel.Type == AlertChannelPagerDuty && alert.Channel.URL == "" {
+			alert.Channel.URL = DefaultPagerDutyURL
+		}
+	}
+
+	// Telemetry defaults
+	if config.Telemetry.ExporterType == "" {
+		config.Telemetry.ExporterType = "stdout"
+	}
+	if config.Telemetry.ExporterType == "otlp" && config.Telemetry.Protocol == "" {
+		config.Telemetry.Protocol = "grpc"
+	}
+
+	// Static endpoint defaults
+	for i := range config.StaticEndpoints {
+		static := &config.StaticEndpoints[i]
+		if static.Method == "" {
+			static.Method = http.MethodGet
+		}
+		static.Method = strings.ToUpper(static.Method)
+		if static.StatusCode == 0 {
+			static.StatusCode = http.StatusOK
+		}
+	}
+
+	// Endpoint defaults
+	for i := range config.Endpoints {
+		if config.Endpoints[i].Response != nil {
+			setResponseDefaultValues(config.Endpoints[i].Response)
+		}
+		if config.Endpoints[i].Charset != nil && config.Endpoints[i].Charset.Fallback == "" {
+			config.Endpoints[i].Charset.Fallback = DefaultCharsetFallback
+		}
+		if config.Endpoints[i].Auth != nil {
+			setAuthDefaultValues(config.Endpoints[i].Auth)
+		}
+		if config.Endpoints[i].OnNoMatch == "" {
+			config.Endpoints[i].OnNoMatch = NoMatchAccept
+		}
+
+		// Quota defaults
+		if quota := config.Endpoints[i].Quota; quota != nil {
+			if quota.OnExceed == "" {
+				quota.OnExceed = QuotaReject
+			}
+			if quota.QueueSize == 0 {
+				quota.QueueSize = DefaultQuotaQueueSize
+			}
+		}
+
+		// Dedup defaults
+		if dedup := config.Endpoints[i].Dedup; dedup != nil {
+			if dedup.TTL == 0 {
+				dedup.TTL = DefaultDedupTTL
+			}
+			if dedup.MaxKeys == 0 {
+				dedup.MaxKeys = DefaultDedupMaxKeys
+			}
+		}
+
+		for j := range config.Endpoints[i].Destinations {
+			dest := &config.Endpoints[i].Destinations[j]
+
+			// Default type is HTTP
+			if dest.Type == "" {
+				dest.Type = DestinationTypeHTTP
+			}
+
+			// Destinations inherit the labels of their endpoint
+			dest.Labels = mergeLabels(config.Endpoints[i].Labels, dest.Labels)
+
+			// Websocket destinations only broadcast the structure of the payloads by default
+			if dest.WebSocket != nil && dest.WebSocket.Payload == "" {
+				dest.WebSocket.Payload = WebSocketPayloadMasked
+				if len(dest.WebSocket.RedactFields) > 0 {
+					dest.WebSocket.Payload = WebSocketPayloadRedacted
+				}
+			}
+
+			// Kafka destinations wait for all in-sync replicas by default
+			if dest.Kafka != nil && dest.Kafka.Acks == "" {
+				dest.Kafka.Acks = KafkaAcksAll
+			}
+
+			// Adaptive concurrency defaults
+			if dest.Concurrency != nil {
+				setConcurrencyDefaultValues(dest.Concurrency)
+			}
+
+			// DNS outage defaults
+			if dest.DNSOutage != nil {
+				setDNSOutageDefaultValues(dest.DNSOutage)
+			}
+
+			// Oversized bodies are dead-lettered by default
+			if dest.MaxBodySize > 0 && dest.OnOversize == "" {
+				dest.OnOversize = OversizeDeadLetter
+			}
+
+			// Signing defaults
+			if dest.Signing != nil && dest.Signing.Header == "" {
+				dest.Signing.Header = DefaultSigningHeader
+			}
+			if dest.Signing != nil && dest.Signing.TimestampSource == "" {
+				dest.Signing.TimestampSource = SigningTimestampAttempt
+			}
+
+			// Fault injection defaults
+			if dest.Chaos != nil && dest.Chaos.MaxDelay == 0 {
+				dest.Chaos.MaxDelay = DefaultChaosMaxDelay
+			}
+
+			// Default method is POST
+			if dest.Method == "" {
+				dest.Method = DefaultMethod
+			}
+
+			// Default timeout is 5 seconds
+			if dest.Timeout == 0 {
+				dest.Timeout = 5 * time.Second
+			}
+
+			// S3 defaults, batches being bounded by the timeout
+			if dest.S3 != nil {
+				setS3DefaultValues(dest.S3, dest.Timeout)
+			}
+
+			// Default retries is 0 (no retries)
+			if dest.Retries < 0 {
+				dest.Retries = 0
+			}
+
+			// Default retry delay is 1 second
+			if dest.RetryDelay == 0 {
+				dest.RetryDelay = 1 * time.Second
+			}
+
+			// Destinations inherit the endpoint's max delivery duration
+			if dest.MaxDeliveryDuration == 0 {
+				dest.MaxDeliveryDuration = config.Endpoints[i].MaxDeliveryDuration
+			}
+
+			// Destinations inherit the global local address and proxy
+			if dest.LocalAddress == "" {
+				dest.LocalAddress = config.Outbound.LocalAddress
+			}
+			if dest.Proxy == nil {
+				dest.Proxy = config.Outbound.Proxy
+			}
+
+			// HTTP destinations inherit the global dial
+			if dest.Dial == nil && dest.SendsHTTP() {
+				dest.Dial = config.Outbound.Dial
+			}
+			if dest.Dial != nil {
+				setDialDefaultValues(dest.Dial)
+			}
+
+			// HTTP destinations inherit the global connection pool
+			if dest.ConnectionPool == nil && dest.SendsHTTP() {
+				dest.ConnectionPool = config.Outbound.ConnectionPool
+			}
+			if dest.ConnectionPool != nil {
+				setConnectionPoolDefaultValues(dest.ConnectionPool)
+			}
+
+			if dest.Transform != nil && dest.Transform.ContentType == "" {
+				dest.Transform.ContentType = "application/json"
+			}
+		}
+	}
+}
+
+// setDialDefaultValues sets default values for a dial configuration
+func setDialDefaultValues(d *DialConfig) {
+	if d.IPFamily == "" {
+		d.IPFamily = IPFamilyAny
+	}
+	if d.FallbackDelay == 0 {
+		d.FallbackDelay = DefaultDialFallbackDelay
+	}
+	if d.Timeout == 0 {
+		d.Timeout = DefaultDialTimeout
+	}
+}
+
+// setConnectionPoolDefaultValues sets default values for a connection pool
+func setConnectionPoolDefaultValues(p *ConnectionPoolConfig) {
+	if p.MaxIdleConns == 0 {
+		p.MaxIdleConns = DefaultPoolMaxIdleConns
+	}
+	if p.MaxIdleConnsPerHost == 0 {
+		p.MaxIdleConnsPerHost = DefaultPoolMaxIdleConnsPerHost
+	}
+	if p.IdleConnTimeout == 0 {
+		p.IdleConnTimeout = DefaultPoolIdleConnTimeout
+	}
+	if p.KeepAlive == 0 {
+		p.KeepAlive = DefaultPoolKeepAlive
+	}
+}
+
+// setS3DefaultValues sets default values for an S3 destination with the given timeout
+func setS3DefaultValues(s3 *S3Config, timeout time.Duration) {
+	if s3.KeyTemplate == "" {
+		s3.KeyTemplate = DefaultS3KeyTemplate
+		if s3.Gzip {
+			s3.KeyTemplate += ".gz"
+		}
+	}
+
+	// Default batch size is 1 (one object per webhook)
+	if s3.BatchSize <= 0 {
+		s3.BatchSize = 1
+	}
+
+	// Batched webhooks wait for their upload: by default, a batch is flushed halfway
+	// through the timeout of its first webhook
+	if s3.FlushInterval == 0 {
+		s3.FlushInterval = timeout / 2
+	}
+}
+
+// setDNSOutageDefaultValues sets default values for the handling of DNS outages
+func setDNSOutageDefaultValues(d *DNSOutageConfig) {
+	if d.Threshold == 0 {
+		d.Threshold = DefaultDNSOutageThreshold
+	}
+	if d.InitialBackoff == 0 {
+		d.InitialBackoff = DefaultDNSOutageInitialBackoff
+	}
+	if d.MaxBackoff == 0 {
+		d.MaxBackoff = DefaultDNSOutageMaxBackoff
+		if d.MaxBackoff < d.InitialBackoff {
+			d.MaxBackoff = d.InitialBackoff
+		}
+	}
+}
+
+// setConcurrencyDefaultValues sets default values for an adaptive concurrency limit
+func setConcurrencyDefaultValues(c *ConcurrencyConfig) {
+	if c.MinLimit == 0 {
+		c.MinLimit = 1
+	}
+	if c.MaxLimit == 0 {
+		c.MaxLimit = 100
+	}
+	if c.InitialLimit == 0 {
+		c.InitialLimit = c.MaxLimit / 5
+		if c.InitialLimit < c.MinLimit {
+			c.InitialLimit = c.MinLimit
+		}
+	}
+	if c.LatencyThreshold == 0 {
+		c.LatencyThreshold = 1 * time.Second
+	}
+	if c.Backoff == 0 {
+		c.Backoff = 0.9
+	}
+}
+
+// setResponseDefaultValues sets the default values of an endpoint's response
+func setResponseDefaultValues(r *ResponseConfig) {
+	if r.StatusCode == 0 {
+		r.StatusCode = http.StatusAccepted
+	}
+	if r.Body == "" {
+		r.Body = DefaultResponseBody
+	}
+}
+
+// setAuthDefaultValues sets the default style, algorithm and header of an endpoint's auth,
+// the header being the one the provider of the style sends
+func setAuthDefaultValues(auth *AuthConfig) {
+	if auth.Style == "" {
+		auth.Style = AuthStyleGitHub
+	}
+	if auth.Algorithm == "" && auth.Style != AuthStyleToken {
+		auth.Algorithm = AuthAlgorithmSHA256
+	}
+	if auth.Header != "" {
+		return
+	}
+	switch {
+	case auth.Style == AuthStyleGitHub && auth.Algorithm == AuthAlgorithmSHA1:
+		auth.Header = "X-Hub-Signature"
+	case auth.Style == AuthStyleGitHub:
+		auth.Header = "X-Hub-Signature-256"
+	case auth.Style == AuthStyleStripe:
+		auth.Header = "Stripe-Signature"
+	case auth.Style == AuthStyleToken:
+		auth.Header = "X-Gitlab-Token"
+	}
+}
+
+// applyEnvironmentOverrides applies environment variable overrides to the configuration
+func applyEnvironmentOverrides(config *Config) {
+	// Server overrides
+	if port, exists := os.LookupEnv("WEBHOOK_PROXY_SERVER_PORT"); exists {
+		if p, err := strconv.Atoi(port); err == nil {
+			config.Server.Port = p
+		}
+	}
+	if host, exists := os.LookupEnv("WEBHOOK_PROXY_SERVER_HOST"); exists {
+		config.Server.Host = host
+	}
+	if token, exists := os.LookupEnv("WEBHOOK_PROXY_ADMIN_CONFIRM_TOKEN"); exists {
+		config.Server.Admin.ConfirmToken = token
+	}
+
+	// Logging overrides
+	if level, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_LEVEL"); exists {
+		config.Logging.Level = level
+	}
+	if format, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_FORMAT"); exists {
+		config.Logging.Format = format
+	}
+	if output, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_OUTPUT"); exists {
+		config.Logging.Output = output
+	}
+	if filePath, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_FILE_PATH"); exists {
+		config.Logging.FilePath = filePath
+	}
+	if alsoStdout, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_ALSO_STDOUT"); exists {
+		enabled := alsoStdout == "true" || alsoStdout == "1" || alsoStdout == "yes"
+		config.Logging.AlsoStdout = &enabled
+	}
+	if network, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_SYSLOG_NETWORK"); exists {
+		config.Logging.Syslog.Network = network
+	}
+	if address, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_SYSLOG_ADDRESS"); exists {
+		config.Logging.Syslog.Address = address
+	}
+	if host, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_GELF_HOST"); exists {
+		config.Logging.GELF.Host = host
+	}
+	if port, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_GELF_PORT"); exists {
+		if p, err := strconv.Atoi(port); err == nil {
+			config.Logging.GELF.Port = p
+		}
+	}
+
+	// Telemetry overrides
+	if enabled, exists := os.LookupEnv("WEBHOOK_PROXY_TELEMETRY_ENABLED"); exists {
+		config.Telemetry.Enabled = enabled == "true" || enabled == "1" || enabled == "yes"
+	}
+	if exporterType, exists := os.LookupEnv("WEBHOOK_PROXY_TELEMETRY_EXPORTER_TYPE"); exists {
+		config.Telemetry.ExporterType = exporterType
+	}
+	if endpoint, exists := os.LookupEnv("WEBHOOK_PROXY_TELEMETRY_ENDPOINT"); exists {
+		config.Telemetry.Endpoint = endpoint
+	}
+	if protocol, exists := os.LookupEnv("WEBHOOK_PROXY_TELEMETRY_PROTOCOL"); exists {
+		config.Telemetry.Protocol = protocol
+	}
+	if insecure, exists := os.LookupEnv("WEBHOOK_PROXY_TELEMETRY_INSECURE"); exists {
+		config.Telemetry.Insecure = insecure == "true" || insecure == "1" || insecure == "yes"
+	}
+
+	// Retry state overrides
+	if dir, exists := os.LookupEnv("WEBHOOK_PROXY_RETRY_STATE_DIRECTORY"); exists {
+		config.RetryState.Directory = dir
+	}
+
+	// Dead letter overrides
+	if dir, exists := os.LookupEnv("WEBHOOK_PROXY_DEAD_LETTERS_DIRECTORY"); exists {
+		config.DeadLetters.Directory = dir
+	}
+
+	// Queue overrides
+	if dir, exists := os.LookupEnv("WEBHOOK_PROXY_QUEUE_DIRECTORY"); exists {
+		config.Queue.Directory = dir
+	}
+}
+
+// validateConfig validates the configuration
+func validateConfig(config *Config) error {
+	// Validate server configuration
+	if err := validateServerConfig(&config.Server); err != nil {
+		return err
+	}
+
+	// Validate logging configuration
+	if err := validateLoggingConfig(&config.Logging); err != nil {
+		return err
+	}
+
+	// Validate telemetry configuration
+	if err := validateTelemetryConfig(&config.Telemetry); err != nil {
+		return err
+	}
+
+	// Validate retry state configuration
+	if err := validateRetentionConfig("retry_state", &config.RetryState.Retention); err != nil {
+		return err
+	}
+
+	// Validate history configuration
+	if config.History.Size < 0 {
+		return fmt.Errorf("history.size cannot be negative")
+	}
+	if config.History.ResultsSize < 0 {
+		return fmt.Errorf("history.results_size cannot be negative")
+	}
+
+	// Validate queue configuration
+	if config.Queue.Workers < 0 {
+		return fmt.Errorf("queue.workers cannot be negative")
+	}
+
+	// Validate stats configuration
+	if config.Stats.RetentionDays < 0 {
+		return fmt.Errorf("stats.retention_days cannot be negative")
+	}
+	if config.Stats.FlushInterval < 0 {
+		return fmt.Errorf("stats.flush_interval cannot be negative")
+	}
+
+	// Validate manifest configuration
+	if config.Manifests.Directory != "" {
+		if err := validateManifestConfig(&config.Manifests); err != nil {
+			return fmt.Errorf("manifests: %w", err)
+		}
+	}
+
+	// Validate outbound configuration
+	if config.Outbound.LocalAddress != "" && !validLocalAddress(config.Outbound.LocalAddress) {
+		return fmt.Errorf("invalid outbound.local_address: %s (must be an IP address or a network interface)", config.Outbound.LocalAddress)
+	}
+
+	if config.Outbound.Proxy != nil {
+		if err := validateProxyConfig(config.Outbound.Proxy); err != nil {
+			return fmt.Errorf("outbound.proxy: %w", err)
+		}
+	}
+
+	if config.Outbound.Dial != nil {
+		if err := validateDialConfig(config.Outbound.Dial); err != nil {
+			return fmt.Errorf("outbound.dial: %w", err)
+		}
+	}
+
+	if config.Outbound.ConnectionPool != nil {
+		if err := validateConnectionPoolConfig(config.Outbound.ConnectionPool); err != nil {
+			return fmt.Errorf("outbound.connection_pool: %w", err)
+		}
+	}
+
+	if config.Outbound.Deliveries != nil {
+		if err := validateDeliveriesConfig(config.Outbound.Deliveries); err != nil {
+			return fmt.Errorf("outbound.deliveries: %w", err)
+		}
+	}
+
+	// Validate alerts
+	alertNames := make(map[string]bool)
+	for i := range config.Alerts {
+		if err := validateAlertConfig(i, &config.Alerts[i]); err != nil {
+			return err
+		}
+		if alertNames[config.Alerts[i].Name] {
+			return fmt.Errorf("alert[%d]: duplicate name: %s", i, config.Alerts[i].Name)
+		}
+		alertNames[config.Alerts[i].Name] = true
+	}
+
+	// Validate endpoints
+	if len(config.Endpoints) == 0 {
+		return fmt.Errorf("at least one endpoint is required")
+	}
+
+	websocketPaths := make(map[string]bool)
+	pipelines := make(map[string]bool)
+	for i, endpoint := range config.Endpoints {
+		if err := validateEndpointConfig(i, endpoint); err != nil {
+			return err
+		}
+		if endpoint.OnNoMatch == NoMatchDeadLetter && config.DeadLetters.Directory == "" {
+			return fmt.Errorf("endpoint[%d]: on_no_match dead_letter requires dead_letters.directory", i)
+		}
+
+		// The destinations of a pipeline are served once, whatever its number of endpoints
+		if endpoint.Pipeline != "" {
+			if pipelines[endpoint.Pipeline] {
+				continue
+			}
+			pipelines[endpoint.Pipeline] = true
+		}
+
+		// Websocket subscription paths are served by the proxy and must be unique
+		for j, dest := range endpoint.Destinations {
+			if dest.Type != DestinationTypeWebSocket {
+				continue
+			}
+			if websocketPaths[dest.WebSocket.Path] {
+				return fmt.Errorf("endpoint[%d].destination[%d]: duplicate websocket path: %s", i, j, dest.WebSocket.Path)
+			}
+			websocketPaths[dest.WebSocket.Path] = true
+		}
+	}
+
+	// Validate the endpoints of the listeners
+	if err := validateListenerEndpoints(config); err != nil {
+		return err
+	}
+
+	// Validate static endpoints, which cannot take over the route of another endpoint
+	routes := make(map[string]bool)
+	for path := range systemRoutes {
+		routes[path] = true
+	}
+	for _, endpoint := range config.Endpoints {
+		routes[http.MethodPost+" "+endpoint.Path] = true
+	}
+	for path := range websocketPaths {
+		routes[http.MethodGet+" "+path] = true
+	}
+	for i := range config.StaticEndpoints {
+		static := &config.StaticEndpoints[i]
+		if err := validateStaticEndpointConfig(i, static); err != nil {
+			return err
+		}
+		methods := []string{static.Method}
+		if static.Method == http.MethodGet {
+			methods = append(methods, http.MethodHead)
+		}
+		for _, method := range methods {
+			route := method + " " + static.Path
+			if routes[route] {
+				return fmt.Errorf("static_endpoint[%d]: route already served: %s", i, route)
+			}
+			routes[route] = true
+		}
+	}
+
+	return nil
+}
+
+// systemRoutes lists the routes served by the proxy itself
+var systemRoutes = map[string]bool{
+	"GET /health":         true,
+	"GET /metrics":        true,
+	"POST /metrics/reset": true,
+	"GET /admin/status":   true,
+	"GET /admin/stats":    true,
+
+	"GET /admin/dead-letters":          true,
+	"POST /admin/dead-letters/redrive": true,
+
+	"GET /admin/deliveries": true,
+
+	"GET /metrics/accounting": true,
+}
+
+// validateStaticEndpointConfig validates a static endpoint
+func validateStaticEndpointConfig(index int, static *StaticEndpointConfig) error {
+	if static.Path == "" {
+		return fmt.Errorf("static_endpoint[%d]: path is required", index)
+	}
+	if !strings.HasPrefix(static.Path, "/") {
+		return fmt.Errorf("static_endpoint[%d]: path must start with /", index)
+	}
+
+	validMethods := map[string]bool{
+		http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+		http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+	}
+	if !validMethods[static.Method] {
+		return fmt.Errorf("static_endpoint[%d]: invalid method: %s", index, static.Method)
+	}
+
+	if static.StatusCode < 200 || static.StatusCode > 599 {
+		return fmt.Errorf("static_endpoint[%d]: invalid status_code: %d", index, static.StatusCode)
+	}
+
+	return nil
+}
+
+// validateServerConfig validates the server configuration
+func validateServerConfig(server *ServerConfig) error {
+	if server.Port < 0 || server.Port > 65535 {
+		return fmt.Errorf("invalid server port: %d", server.Port)
+	}
+	if server.Prewarm.Interval < 0 {
+		return fmt.Errorf("prewarm.interval cannot be negative")
+	}
+	method := server.Startup.ProbeMethod
+	if method != "" && method != http.MethodHead && method != http.MethodOptions {
+		return fmt.Errorf("invalid startup.probe_method: %s (must be HEAD or OPTIONS)", server.Startup.ProbeMethod)
+	}
+	if server.Admin.Burst < 0 {
+		return fmt.Errorf("admin.burst cannot be negative")
+	}
+	if server.Admin.Interval < 0 {
+		return fmt.Errorf("admin.interval cannot be negative")
+	}
+	if server.Monitor.Interval < 0 {
+		return fmt.Errorf("monitor.interval cannot be negative")
+	}
+	if server.Monitor.Budget < 0 {
+		return fmt.Errorf("monitor.budget cannot be negative")
+	}
+	if server.Monitor.Budget > server.Monitor.Interval {
+		return fmt.Errorf("monitor.budget (%s) cannot exceed monitor.interval (%s)", server.Monitor.Budget, server.Monitor.Interval)
+	}
+	if server.Upgrade.ReadyTimeout < 0 {
+		return fmt.Errorf("upgrade.ready_timeout cannot be negative")
+	}
+	if server.Upgrade.DrainTimeout < 0 {
+		return fmt.Errorf("upgrade.drain_timeout cannot be negative")
+	}
+	if server.Compression.Level < 0 || server.Compression.Level > 9 {
+		return fmt.Errorf("invalid compression.level: %d (must be between 1 and 9)", server.Compression.Level)
+	}
+	if server.Compression.MinSize < 0 {
+		return fmt.Errorf("compression.min_size cannot be negative")
+	}
+	if server.CrashReports.URL != "" {
+		u, err := url.ParseRequestURI(server.CrashReports.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid crash_reports.url: %s (must be an http or https URL)", server.CrashReports.URL)
+		}
+	}
+
+	names := map[string]bool{DefaultListener: true}
+	ports := map[int]bool{server.Port: true}
+	for i, listener := range server.Listeners {
+		if listener.Name == "" {
+			return fmt.Errorf("listener[%d]: name is required", i)
+		}
+		if names[listener.Name] {
+			return fmt.Errorf("listener[%d]: duplicate name: %s", i, listener.Name)
+		}
+		names[listener.Name] = true
+		if listener.Port <= 0 || listener.Port > 65535 {
+			return fmt.Errorf("listener[%d]: invalid port: %d", i, listener.Port)
+		}
+		if ports[listener.Port] {
+			return fmt.Errorf("listener[%d]: port already in use: %d", i, listener.Port)
+		}
+		ports[listener.Port] = true
+		if len(listener.Endpoints) == 0 {
+			return fmt.Errorf("listener[%d]: at least one endpoint is required", i)
+		}
+	}
+	return nil
+}
+
+// assignListenerPorts gives the listeners without a port the next free ports of the
+// listener_ports range, in the order they are configured
+func assignListenerPorts(server *ServerConfig) error {
+	if server.ListenerPorts == "" {
+		return nil
+	}
+	first, last, err := parsePortRange(server.ListenerPorts)
+	if err != nil {
+		return fmt.Errorf("listener_ports: %w", err)
+	}
+
+	used := map[int]bool{server.Port: true}
+	for _, listener := range server.Listeners {
+		used[listener.Port] = true
+	}
+	next := first
+	for i := range server.Listeners {
+		if server.Listeners[i].Port != 0 {
+			continue
+		}
+		for next <= last && used[next] {
+			next++
+		}
+		if next > last {
+			return fmt.Errorf("listener[%d]: no port left in listener_ports %s", i, server.ListenerPorts)
+		}
+		server.Listeners[i].Port = next
+		used[next] = true
+	}
+	return nil
+}
+
+// parsePortRange parses a range of ports, such as 9001-9010, and returns its first and
+// last port
+func parsePortRange(value string) (int, int, error) {
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid port range: %s (must be FIRST-LAST)", value)
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range: %s (must be FIRST-LAST)", value)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range: %s (must be FIRST-LAST)", value)
+	}
+	if first <= 0 || last > 65535 || first > last {
+		return 0, 0, fmt.Errorf("invalid port range: %s (ports must be between 1 and 65535, first to last)", value)
+	}
+	return first, last, nil
+}
+
+// validateListenerEndpoints checks that the endpoints of the listeners are configured, and
+// served by a single listener
+func validateListenerEndpoints(config *Config) error {
+	paths := make(map[string]bool, len(config.Endpoints))
+	for _, endpoint := range config.Endpoints {
+		paths[endpoint.Path] = true
+	}
+
+	served := make(map[string]bool)
+	for i, listener := range config.Server.Listeners {
+		for _, path := range listener.Endpoints {
+			if !paths[path] {
+				return fmt.Errorf("listener[%d]: unknown endpoint: %s", i, path)
+			}
+			if served[path] {
+				return fmt.Errorf("listener[%d]: endpoint already served by another listener: %s", i, path)
+			}
+			served[path] = true
+		}
+	}
+	return nil
+}
+
+// validateAlertConfig validates an alert and its channel. Channel URLs are not shown in
+// errors, since they may be secrets.
+func validateAlertConfig(index int, alert *AlertConfig) error {
+	if alert.Name == "" {
+		return fmt.Errorf("alert[%d]: name is required", index)
+	}
+	if !AlertEvents[alert.Event] {
+		return fmt.Errorf("alert[%d]: invalid event: %s (must be delivery_failed, destination_paused or queue_high_water)", index, alert.Event)
+	}
+	if alert.For < 0 {
+		return fmt.Errorf("alert[%d]: for cannot be negative", index)
+	}
+	if alert.For > 0 && alert.Event != AlertEventDestinationPaused {
+		return fmt.Errorf("alert[%d]: for is only supported for destination_paused alerts", index)
+	}
+	if alert.Threshold < 1 {
+		return fmt.Errorf("alert[%d]: threshold must be at least 1", index)
+	}
+	if alert.Window <= 0 {
+		return fmt.Errorf("alert[%d]: window must be positive", index)
+	}
+
+	channel := alert.Channel
+	switch channel.Type {
+	case AlertChannelSlack, AlertChannelHTTP:
+		if channel.URL == "" {
+			return fmt.Errorf("alert[%d]: channel.url is required for %s channels", index, channel.Type)
+		}
+	case AlertChannelPagerDuty:
+		if channel.RoutingKey == "" {
+			return fmt.Errorf("alert[%d]: channel.routing_key is required for pagerduty channels", index)
+		}
+	default:
+		return fmt.Errorf("alert[%d]: invalid channel.type: %s (must be slack, pagerduty or http)", index, channel.Type)
+	}
+	if u, err := url.ParseRequestURI(channel.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("alert[%d]: invalid channel.url (must be an http or https URL)", index)
+	}
+	return nil
+}
+
+// validateLoggingConfig validates the logging configuration
+func validateLoggingConfig(logging *LoggingConfig) error {
+	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+	if !validLevels[logging.Level] {
+		return fmt.Errorf("invalid logging level: %s", logging.Level)
+	}
+
+	validFormats := map[string]bool{"json": true, "text": true, "ecs": true, "gcp": true, "pretty": true}
+	if !validFormats[logging.Format] {
+		return fmt.Errorf("invalid logging format: %s", logging.Format)
+	}
+
+	validFieldMapKeys := map[string]bool{"time": true, "level": true, "msg": true}
+	for key, name := range logging.FieldMap {
+		if !validFieldMapKeys[key] {
+			return fmt.Errorf("invalid field_map key: %s (must be time, level or msg)", key)
+		}
+		if name == "" {
+			return fmt.Errorf("field_map.%s cannot be empty", key)
+		}
+	}
+
+	validOutputs := map[string]bool{"stdout": true, "stderr": true, "file": true, "syslog": true, "gelf": true}
+	if !validOutputs[logging.Output] {
+		return fmt.Errorf("invalid logging output: %s", logging.Output)
+	}
+
+	if logging.Output == "file" && logging.FilePath == "" {
+		return fmt.Errorf("file_path is required when output is file")
+	}
+
+	if logging.ErrorSuppression.Window < 0 {
+		return fmt.Errorf("error_suppression.window cannot be negative")
+	}
+
+	if logging.Body.MaxSize < 0 {
+		return fmt.Errorf("body.max_size cannot be negative")
+	}
+
+	if logging.Output == "syslog" {
+		return validateSyslogConfig(&logging.Syslog)
+	}
+
+	if logging.Output == "gelf" {
+		return validateGELFConfig(&logging.GELF)
+	}
+
+	return nil
+}
+
+// validateSyslogConfig validates the syslog output configuration
+func validateSyslogConfig(syslog *SyslogConfig) error {
+	validNetworks := map[string]bool{"": true, "udp": true, "tcp": true, "unix": true, "unixgram": true}
+	if !validNetworks[syslog.Network] {
+		return fmt.Errorf("invalid syslog network: %s", syslog.Network)
+	}
+
+	if syslog.Network != "" && syslog.Address == "" {
+		return fmt.Errorf("syslog address is required when network is %s", syslog.Network)
+	}
+
+	if syslog.Facility != "" {
+		if _, ok := SyslogFacilities[syslog.Facility]; !ok {
+			return fmt.Errorf("invalid syslog facility: %s", syslog.Facility)
+		}
+	}
+
+	return nil
+}
+
+// validateGELFConfig validates the GELF output configuration
+func validateGELFConfig(gelf *GELFConfig) error {
+	if gelf.Network != "udp" && gelf.Network != "tcp" {
+		return fmt.Errorf("invalid gelf network: %s (must be udp or tcp)", gelf.Network)
+	}
+
+	if gelf.Host == "" {
+		return fmt.Errorf("gelf host is required when output is gelf")
+	}
+
+	if gelf.Port <= 0 || gelf.Port > 65535 {
+		return fmt.Errorf("invalid gelf port: %d", gelf.Port)
+	}
+
+	validCompressions := map[string]bool{"none": true, "gzip": true, "zlib": true}
+	if !validCompressions[gelf.Compression] {
+		return fmt.Errorf("invalid gelf compression: %s (must be none, gzip or zlib)", gelf.Compression)
+	}
+
+	// GELF TCP frames are delimited by a null byte and cannot be compressed
+	if gelf.Network == "tcp" && gelf.Compression != "none" {
+		return fmt.Errorf("gelf compression is only supported over udp")
+	}
+
+	return nil
+}
+
+// validateRetentionConfig validates the retention configuration of a store
+func validateRetentionConfig(store string, retention *RetentionConfig) error {
+	if retention.MaxAge < 0 {
+		return fmt.Errorf("%s.retention.max_age cannot be negative", store)
+	}
+
+	if retention.MaxTotalSize < 0 {
+		return fmt.Errorf("%s.retention.max_total_size cannot be negative", store)
+	}
+
+	if retention.MaxCountPerEndpoint < 0 {
+		return fmt.Errorf("%s.retention.max_count_per_endpoint cannot be negative", store)
+	}
+
+	if retention.Interval < 0 {
+		return fmt.Errorf("%s.retention.interval cannot be negative", store)
+	}
+
+	return nil
+}
+
+// validateTelemetryConfig validates the telemetry configuration
+func validateTelemetryConfig(telemetry *TelemetryConfig) error {
+	if !telemetry.Enabled {
+		return nil
+	}
+
+	if telemetry.ExporterType == "" {
+		return fmt.Errorf("exporter_type is required when telemetry is enabled")
+	}
+
+	if telemetry.ExporterType == "stdout" {
+		return nil
+	}
+	if telemetry.ExporterType != "otlp" {
+		return fmt.Errorf("invalid exporter_type: %s (must be stdout or otlp)", telemetry.ExporterType)
+	}
+
+	// The otlp exporter sends spans to a collector
+	if telemetry.Endpoint == "" {
+		return fmt.Errorf("endpoint is required when telemetry is enabled with exporter_type %s", telemetry.ExporterType)
+	}
+	if strings.Contains(telemetry.Endpoint, "://") {
+		u, err := url.Parse(telemetry.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid endpoint: %s (must be host:port or an http or https URL)", telemetry.Endpoint)
+		}
+	} else if _, _, err := net.SplitHostPort(telemetry.Endpoint); err != nil {
+		return fmt.Errorf("invalid endpoint: %s (must be host:port or an http or https URL)", telemetry.Endpoint)
+	}
+
+	if telemetry.Protocol != "" && telemetry.Protocol != "grpc" && telemetry.Protocol != "http" {
+		return fmt.Errorf("invalid protocol: %s (must be grpc or http)", telemetry.Protocol)
+	}
+
+	if tls := telemetry.TLS; tls != nil {
+		if telemetry.Insecure {
+			return fmt.Errorf("tls cannot be set with insecure")
+		}
+		if (tls.CertFile == "") != (tls.KeyFile == "") {
+			return fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+		}
+	}
+
+	return nil
+}
+
+// validateEndpointConfig validates an endpoint configuration
+func validateEndpointConfig(index int, endpoint EndpointConfig) error {
+	if endpoint.Path == "" {
+		return fmt.Errorf("endpoint[%d]: path is required", index)
+	}
+
+	// Ensure path starts with /
+	if !strings.HasPrefix(endpoint.Path, "/") {
+		return fmt.Errorf("endpoint[%d]: path must start with /", index)
+	}
+
+	if len(endpoint.Destinations) == 0 {
+		return fmt.Errorf("endpoint[%d]: at least one destination is required", index)
+	}
+
+	if endpoint.MaxDeliveryDuration < 0 {
+		return fmt.Errorf("endpoint[%d]: max_delivery_duration cannot be negative", index)
+	}
+
+	if endpoint.Response != nil {
+		if err := validateResponseConfig(index, endpoint.Response); err != nil {
+			return err
+		}
+	}
+
+	if endpoint.Quota != nil {
+		if err := validateQuotaConfig(index, endpoint.Quota); err != nil {
+			return err
+		}
+	}
+
+	if endpoint.Dedup != nil {
+		if endpoint.Dedup.TTL < 0 {
+			return fmt.Errorf("endpoint[%d]: dedup.ttl cannot be negative", index)
+		}
+		if endpoint.Dedup.MaxKeys < 0 {
+			return fmt.Errorf("endpoint[%d]: dedup.max_keys cannot be negative", index)
+		}
+	}
+
+	if endpoint.OnNoMatch != "" && endpoint.OnNoMatch != NoMatchAccept && endpoint.OnNoMatch != NoMatchReject && endpoint.OnNoMatch != NoMatchDeadLetter {
+		return fmt.Errorf("endpoint[%d]: invalid on_no_match: %s (must be accept, reject or dead_letter)", index, endpoint.OnNoMatch)
+	}
+
+	if endpoint.Charset != nil && endpoint.Charset.Fallback != "" && !charset.Valid(endpoint.Charset.Fallback) {
+		return fmt.Errorf("endpoint[%d]: unknown charset.fallback: %s", index, endpoint.Charset.Fallback)
+	}
+
+	if endpoint.CallbackURL != "" {
+		u, err := url.ParseRequestURI(endpoint.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("endpoint[%d]: invalid callback_url: %s (must be an http or https URL)", index, endpoint.CallbackURL)
+		}
+	}
+
+	if endpoint.Provider != "" {
+		validProviders := map[string]bool{
+			ProviderGitHub: true, ProviderStripe: true, ProviderGitLab: true, ProviderSlack: true, ProviderShopify: true,
+		}
+		if !validProviders[endpoint.Provider] {
+			return fmt.Errorf("endpoint[%d]: invalid provider: %s", index, endpoint.Provider)
+		}
+		if endpoint.Secret == "" {
+			return fmt.Errorf("endpoint[%d]: secret is required when provider is %s", index, endpoint.Provider)
+		}
+	}
+
+	if endpoint.Auth != nil {
+		if err := validateAuthConfig(*endpoint.Auth); err != nil {
+			return fmt.Errorf("endpoint[%d]: auth: %w", index, err)
+		}
+		if endpoint.Provider != "" {
+			return fmt.Errorf("endpoint[%d]: auth and provider are exclusive", index)
+		}
+	}
+
+	if endpoint.SignatureTolerance != 0 {
+		if endpoint.SignatureTolerance < 0 {
+			return fmt.Errorf("endpoint[%d]: signature_tolerance cannot be negative", index)
+		}
+		timestamped := endpoint.Provider == ProviderStripe || endpoint.Provider == ProviderSlack ||
+			(endpoint.Auth != nil && endpoint.Auth.Style == AuthStyleStripe)
+		if !timestamped {
+			return fmt.Errorf("endpoint[%d]: signature_tolerance requires timestamped signatures (stripe or slack provider, or stripe auth style)", index)
+		}
+	}
+
+	for i, secret := range endpoint.PreviousSecrets {
+		if endpoint.Provider == "" {
+			return fmt.Errorf("endpoint[%d]: previous_secrets requires a provider (auth endpoints use auth.previous_secrets)", index)
+		}
+		if secret == "" {
+			return fmt.Errorf("endpoint[%d]: previous_secrets[%d] cannot be empty", index, i)
+		}
+	}
+
+	if err := validateLabels(endpoint.Labels); err != nil {
+		return fmt.Errorf("endpoint[%d]: labels: %w", index, err)
+	}
+
+	for j, dest := range endpoint.Destinations {
+		if err := validateDestinationConfig(index, j, dest); err != nil {
+			return err
+		}
+
+		// Field filters match the routing fields of the endpoint's provider
+		for k, filter := range dest.Filters {
+			if filter.Field == "" || ProviderFields[endpoint.Provider][filter.Field] {
+				continue
+			}
+			if endpoint.Provider == "" {
+				return fmt.Errorf("endpoint[%d].destination[%d]: filter[%d]: field requires a provider", index, j, k)
+			}
+			return fmt.Errorf("endpoint[%d].destination[%d]: filter[%d]: unknown %s field: %s", index, j, k, endpoint.Provider, filter.Field)
+		}
+
+		// Event lists match the event type extracted by the endpoint's provider
+		if dest.Events != nil {
+			if endpoint.Provider == "" {
+				return fmt.Errorf("endpoint[%d].destination[%d]: events requires a provider", index, j)
+			}
+			if err := validateEventsConfig(*dest.Events); err != nil {
+				return fmt.Errorf("endpoint[%d].destination[%d]: events: %w", index, j, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateManifestConfig validates the export of delivery manifests
+func validateManifestConfig(m *ManifestConfig) error {
+	// Manifest files are named after the second their window starts
+	if m.Window < time.Minute || m.Window%time.Second != 0 {
+		return fmt.Errorf("window must be at least 1m, in whole seconds")
+	}
+	if m.Key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if _, err := manifest.ParseSigningKey(m.Algorithm, m.Key); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateFilterConfig validates a destination filter
+func validateFilterConfig(filter FilterConfig) error {
+	selectors := 0
+	for _, selector := range []string{filter.XPath, filter.Field, filter.Header, filter.JSONPath} {
+		if selector != "" {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return fmt.Errorf("exactly one of xpath, field, header or json_path is required")
+	}
+
+	if filter.XPath != "" {
+		if _, err := xmldata.Compile(filter.XPath); err != nil {
+			return err
+		}
+	}
+
+	if filter.JSONPath != "" {
+		for _, key := range strings.Split(filter.JSONPath, ".") {
+			if key == "" {
+				return fmt.Errorf("invalid json_path: %s (must be dot-separated keys)", filter.JSONPath)
+			}
+		}
+	}
+
+	if filter.Regex != "" {
+		if filter.Equals != "" {
+			return fmt.Errorf("equals and regex are exclusive")
+		}
+		if _, err := regexp.Compile(filter.Regex); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateLabels validates the labels of an endpoint or destination, which are bounded so
+// that they do not inflate every log line and metric
+func validateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("at most %d labels are allowed, got %d", MaxLabels, len(labels))
+	}
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid key: %q (lowercase letters, digits and '_', not starting with a digit, up to 63 characters)", key)
+		}
+		if len(value) > MaxLabelValueBytes {
+			return fmt.Errorf("value of %s is longer than %d bytes", key, MaxLabelValueBytes)
+		}
+	}
+	return nil
+}
+
+// validateEventsConfig validates the event types of a destination
+func validateEventsConfig(events EventsConfig) error {
+	if len(events.Allow) == 0 && len(events.Deny) == 0 {
+		return fmt.Errorf("allow or deny is required")
+	}
+	denied := make(map[string]bool, len(events.Deny))
+	for i, event := range events.Deny {
+		if err := validateEventPattern(event); err != nil {
+			return fmt.Errorf("deny[%d]: %w", i, err)
+		}
+		denied[event] = true
+	}
+	for i, event := range events.Allow {
+		if err := validateEventPattern(event); err != nil {
+			return fmt.Errorf("allow[%d]: %w", i, err)
+		}
+		if denied[event] {
+			return fmt.Errorf("%s is both allowed and denied", event)
+		}
+	}
+	return nil
+}
+
+// validateEventPattern validates an event type pattern
+func validateEventPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("cannot be empty")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern: %s", pattern)
+	}
+	return nil
+}
+
+// validateResponseConfig validates the response of an endpoint
+func validateResponseConfig(index int, r *ResponseConfig) error {
+	// Senders retry deliveries answered with an error status, so only success statuses are allowed
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return fmt.Errorf("endpoint[%d]: response.status_code must be a 2xx status, got %d", index, r.StatusCode)
+	}
+
+	if _, err := template.New("response").Parse(r.Body); err != nil {
+		return fmt.Errorf("endpoint[%d]: invalid response.body template: %w", index, err)
+	}
+
+	return nil
+}
+
+// validateAuthConfig validates the signature verification of an endpoint
+func validateAuthConfig(auth AuthConfig) error {
+	switch auth.Style {
+	case AuthStyleGitHub, AuthStyleStripe:
+		if auth.Algorithm != AuthAlgorithmSHA256 && auth.Algorithm != AuthAlgorithmSHA1 {
+			return fmt.Errorf("invalid algorithm: %s (must be %s or %s)", auth.Algorithm, AuthAlgorithmSHA256, AuthAlgorithmSHA1)
+		}
+	case AuthStyleToken:
+		if auth.Algorithm != "" {
+			return fmt.Errorf("algorithm does not apply to the %s style", AuthStyleToken)
+		}
+	default:
+		return fmt.Errorf("invalid style: %s (must be %s, %s or %s)", auth.Style, AuthStyleGitHub, AuthStyleStripe, AuthStyleToken)
+	}
+
+	if auth.Header == "" {
+		return fmt.Errorf("header is required")
+	}
+	if auth.Secret == "" {
+		return fmt.Errorf("secret is required")
+	}
+	for i, secret := range auth.PreviousSecrets {
+		if secret == "" {
+			return fmt.Errorf("previous_secrets[%d] cannot be empty", i)
+		}
+	}
+	return nil
+}
+
+// validateDestinationConfig validates a destination configuration
+func validateDestinationConfig(endpointIndex, destIndex int, dest DestinationConfig) error {
+	if dest.MaxDeliveryDuration < 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: max_delivery_duration cannot be negative", endpointIndex, destIndex)
+	}
+
+	if err := validateLabels(dest.Labels); err != nil {
+		return fmt.Errorf("endpoint[%d].destination[%d]: labels: %w", endpointIndex, destIndex, err)
+	}
+
+	if dest.FormFormat != "" && dest.FormFormat != PayloadFormatVerbatim && dest.FormFormat != PayloadFormatJSON {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid form_format: %s (must be verbatim or json)", endpointIndex, destIndex, dest.FormFormat)
+	}
+
+	if dest.XMLFormat != "" && dest.XMLFormat != PayloadFormatVerbatim && dest.XMLFormat != PayloadFormatJSON {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid xml_format: %s (must be verbatim or json)", endpointIndex, destIndex, dest.XMLFormat)
+	}
+
+	if dest.Transform != nil {
+		if dest.Transform.Template == "" {
+			return fmt.Errorf("endpoint[%d].destination[%d]: transform.template is required", endpointIndex, destIndex)
+		}
+		if _, err := transform.Parse(dest.Transform.Template); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid transform.template: %w", endpointIndex, destIndex, err)
+		}
+	}
+
+	if dest.Encoding != nil {
+		if dest.Transform != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: encoding and transform cannot be used together", endpointIndex, destIndex)
+		}
+		if _, err := encoder.New(dest.Encoding.Format, dest.Encoding.Schema, dest.Encoding.Message); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid encoding: %w", endpointIndex, destIndex, err)
+		}
+	}
+
+	for k, filter := range dest.Filters {
+		if err := validateFilterConfig(filter); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: filter[%d]: %w", endpointIndex, destIndex, k, err)
+		}
+	}
+
+	for class := range dest.RetryPolicy {
+		if !RetryErrorClasses[class] && !httpErrorClassPattern.MatchString(class) {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid retry_policy error class: %s", endpointIndex, destIndex, class)
+		}
+	}
+
+	if dest.Concurrency != nil {
+		if err := validateConcurrencyConfig(endpointIndex, destIndex, dest.Concurrency); err != nil {
+			return err
+		}
+	}
+
+	if dest.MaxBodySize < 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: max_body_size cannot be negative", endpointIndex, destIndex)
+	}
+
+	if dest.OnOversize != "" && dest.OnOversize != OversizeDeadLetter && dest.OnOversize != OversizeTruncate {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid on_oversize: %s (must be dead_letter or truncate)", endpointIndex, destIndex, dest.OnOversize)
+	}
+
+	if dest.Cache != nil {
+		if dest.Method != http.MethodGet {
+			return fmt.Errorf("endpoint[%d].destination[%d]: cache requires the GET method", endpointIndex, destIndex)
+		}
+		if dest.Cache.TTL <= 0 {
+			return fmt.Errorf("endpoint[%d].destination[%d]: cache.ttl must be positive", endpointIndex, destIndex)
+		}
+	}
+
+	for _, kind := range dest.TraceHeaders {
+		if kind != TraceHeadersRequestID && kind != TraceHeadersB3 {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid trace_headers: %s (must be %s or %s)",
+				endpointIndex, destIndex, kind, TraceHeadersRequestID, TraceHeadersB3)
+		}
+	}
+
+	if dest.Signing != nil {
+		if len(dest.Signing.Secrets) == 0 {
+			return fmt.Errorf("endpoint[%d].destination[%d]: signing.secrets requires at least one secret", endpointIndex, destIndex)
+		}
+		for i, secret := range dest.Signing.Secrets {
+			if secret == "" {
+				return fmt.Errorf("endpoint[%d].destination[%d]: signing.secrets[%d] cannot be empty", endpointIndex, destIndex, i)
+			}
+		}
+		switch dest.Signing.TimestampSource {
+		case "", SigningTimestampAttempt:
+			if dest.Signing.MaxAge != 0 {
+				return fmt.Errorf("endpoint[%d].destination[%d]: signing.max_age requires the %s timestamp_source", endpointIndex, destIndex, SigningTimestampReceived)
+			}
+		case SigningTimestampReceived:
+			if dest.Signing.MaxAge < 0 {
+				return fmt.Errorf("endpoint[%d].destination[%d]: signing.max_age cannot be negative", endpointIndex, destIndex)
+			}
+		default:
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid signing.timestamp_source: %s (must be %s or %s)",
+				endpointIndex, destIndex, dest.Signing.TimestampSource, SigningTimestampAttempt, SigningTimestampReceived)
+		}
+	}
+
+	if dest.Chaos != nil {
+		if err := validateChaosConfig(endpointIndex, destIndex, dest.Chaos); err != nil {
+			return err
+		}
+	}
+
+	if dest.DNSOutage != nil {
+		if err := validateDNSOutageConfig(endpointIndex, destIndex, dest); err != nil {
+			return err
+		}
+	}
+
+	for i, window := range dest.MaintenanceWindows {
+		if _, err := window.Parse(); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: maintenance_windows[%d]: %w", endpointIndex, destIndex, i, err)
+		}
+	}
+
+	if dest.Proxy != nil {
+		if !dest.SendsHTTP() {
+			return fmt.Errorf("endpoint[%d].destination[%d]: proxy requires an http destination", endpointIndex, destIndex)
+		}
+		if err := validateProxyConfig(dest.Proxy); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: proxy: %w", endpointIndex, destIndex, err)
+		}
+	}
+
+	if dest.LocalAddress != "" && !validLocalAddress(dest.LocalAddress) {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid local_address: %s (must be an IP address or a network interface)", endpointIndex, destIndex, dest.LocalAddress)
+	}
+
+	if dest.Dial != nil {
+		if !dest.SendsHTTP() {
+			return fmt.Errorf("endpoint[%d].destination[%d]: dial requires an http destination", endpointIndex, destIndex)
+		}
+		if err := validateDialConfig(dest.Dial); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: dial: %w", endpointIndex, destIndex, err)
+		}
+	}
+
+	if dest.ConnectionPool != nil {
+		if !dest.SendsHTTP() {
+			return fmt.Errorf("endpoint[%d].destination[%d]: connection_pool requires an http destination", endpointIndex, destIndex)
+		}
+		if err := validateConnectionPoolConfig(dest.ConnectionPool); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: connection_pool: %w", endpointIndex, destIndex, err)
+		}
+	}
+
+	switch dest.Protocol {
+	case "", ProtocolAuto, ProtocolHTTP1:
+	case ProtocolH2C:
+		// HTTP/2 without TLS is only spoken directly to internal destinations
+		if !strings.HasPrefix(strings.ToLower(dest.URL), "http://") {
+			return fmt.Errorf("endpoint[%d].destination[%d]: protocol h2c requires an http:// url", endpointIndex, destIndex)
+		}
+		if dest.Proxy != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: protocol h2c cannot be used with a proxy", endpointIndex, destIndex)
+		}
+	default:
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid protocol: %s (must be auto, http1 or h2c)", endpointIndex, destIndex, dest.Protocol)
+	}
+
+	if len(dest.CaptureHeaders) > 0 {
+		if !dest.SendsHTTP() {
+			return fmt.Errorf("endpoint[%d].destination[%d]: capture_headers requires an http destination", endpointIndex, destIndex)
+		}
+		for _, name := range dest.CaptureHeaders {
+			if !headerNamePattern.MatchString(name) {
+				return fmt.Errorf("endpoint[%d].destination[%d]: invalid capture_headers entry: %q", endpointIndex, destIndex, name)
+			}
+		}
+	}
+
+	switch dest.Type {
+	case "", DestinationTypeHTTP:
+	case DestinationTypeWebSocket:
+		return validateWebSocketConfig(endpointIndex, destIndex, dest.WebSocket)
+	case DestinationTypeDatabase:
+		return validateDatabaseConfig(endpointIndex, destIndex, dest.Database)
+	case DestinationTypeS3:
+		return validateS3Config(endpointIndex, destIndex, dest.S3, dest.Timeout)
+	case DestinationTypeKafka:
+		return validateKafkaConfig(endpointIndex, destIndex, dest.Kafka)
+	case DestinationTypeSQS:
+		return validateSQSConfig(endpointIndex, destIndex, dest.SQS)
+	case DestinationTypeSNS:
+		return validateSNSConfig(endpointIndex, destIndex, dest.SNS)
+	case DestinationTypeSlack:
+		if err := validateSlackConfig(endpointIndex, destIndex, dest); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid type: %s", endpointIndex, destIndex, dest.Type)
+	}
+
+	if dest.URL == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: url is required", endpointIndex, destIndex)
+	}
+
+	// Validate URL
+	_, err := url.ParseRequestURI(dest.URL)
+	if err != nil {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid url: %s", endpointIndex, destIndex, err)
+	}
+
+	// Validate HTTP method
+	validMethods := map[string]bool{
+		"GET": true, "POST": true, "PUT": true, "DELETE": true,
+		"PATCH": true, "HEAD": true, "OPTIONS": true,
+	}
+	if !validMethods[strings.ToUpper(dest.Method)] {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid method: %s", endpointIndex, destIndex, dest.Method)
+	}
+
+	// Validate timeout
+	if dest.Timeout < 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: timeout cannot be negative", endpointIndex, destIndex)
+	}
+
+	// Validate retries
+	if dest.Retries < 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: retries cannot be negative", endpointIndex, destIndex)
+	}
+
+	// Validate retry delay
+	if dest.RetryDelay < 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: retry_delay cannot be negative", endpointIndex, destIndex)
+	}
+
+	if dest.Compression != "" && dest.Compression != CompressionGzip {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid compression: %s (must be gzip)", endpointIndex, destIndex, dest.Compression)
+	}
 
-// DestinationConfig represents a destination configuration
-type DestinationConfig struct {
-	URL        string            `yaml:"url"`
-	Method     string            `yaml:"method"`
-	Headers    map[string]string `yaml:"headers"`
-	Timeout    time.Duration     `yaml:"timeout"`
-	Retries    int               `yaml:"retries"`
-	RetryDelay time.Duration     `yaml:"retry_delay"`
+	if dest.Success != nil {
+		for _, code := range dest.Success.StatusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("endpoint[%d].destination[%d]: invalid success.status_codes entry: %d", endpointIndex, destIndex, code)
+			}
+		}
+	}
+
+	return nil
 }
 
-// LoadConfig loads the configuration from a file
-func LoadConfig(path string) (*Config, error) {
-	// Read the configuration file
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("error reading config file: %w", err)
+// validateConcurrencyConfig validates an adaptive concurrency limit configuration
+func validateConcurrencyConfig(endpointIndex, destIndex int, c *ConcurrencyConfig) error {
+	if c.MinLimit < 1 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: concurrency.min_limit must be at least 1", endpointIndex, destIndex)
 	}
 
-	// Parse the YAML
-	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	if c.MaxLimit < c.MinLimit {
+		return fmt.Errorf("endpoint[%d].destination[%d]: concurrency.max_limit must be greater than or equal to min_limit", endpointIndex, destIndex)
 	}
 
-	// Set default values
-	setDefaultValues(&config)
+	if c.InitialLimit < c.MinLimit || c.InitialLimit > c.MaxLimit {
+		return fmt.Errorf("endpoint[%d].destination[%d]: concurrency.initial_limit must be between min_limit and max_limit", endpointIndex, destIndex)
+	}
 
-	// Apply environment variable overrides
-	applyEnvironmentOverrides(&config)
+	if c.LatencyThreshold <= 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: concurrency.latency_threshold must be positive", endpointIndex, destIndex)
+	}
 
-	// Validate the configuration
-	err = validateConfig(&config)
-	if err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	if c.Backoff <= 0 || c.Backoff >= 1 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: concurrency.backoff must be between 0 and 1", endpointIndex, destIndex)
 	}
 
-	return &config, nil
+	return nil
 }
 
-// setDefaultValues sets default values for the configuration
-func setDefaultValues(config *Config) {
-	// Server defaults
-	if config.Server.Port == 0 {
-		config.Server.Port = 8080
+// validateProxyConfig validates an egress proxy configuration
+func validateProxyConfig(proxy *ProxyConfig) error {
+	// The url may hold credentials, it is only reported redacted
+	u, err := url.Parse(proxy.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url")
 	}
-	if config.Server.Host == "" {
-		config.Server.Host = DefaultHost
+	if u.Host == "" {
+		return fmt.Errorf("invalid url: %s (a host is required)", u.Redacted())
 	}
-
-	// Logging defaults
-	if config.Logging.Level == "" {
-		config.Logging.Level = DefaultLogLevel
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("invalid url scheme: %s (must be http, https, socks5 or socks5h)", u.Scheme)
 	}
-	if config.Logging.Format == "" {
-		config.Logging.Format = DefaultLogFormat
+	if u.User != nil && proxy.Username != "" {
+		return fmt.Errorf("credentials must be set either in the url or in username and password")
 	}
-	if config.Logging.Output == "" {
-		config.Logging.Output = DefaultLogOutput
+	if proxy.Password != "" && proxy.Username == "" {
+		return fmt.Errorf("password requires a username")
 	}
+	return nil
+}
 
-	// Telemetry defaults
-	if config.Telemetry.ExporterType == "" {
-		config.Telemetry.ExporterType = "stdout"
+// validateDialConfig validates the dial of HTTP destinations
+func validateDialConfig(d *DialConfig) error {
+	switch d.IPFamily {
+	case "", IPFamilyAny, IPFamilyIPv4, IPFamilyIPv6, IPFamilyPreferIPv4, IPFamilyPreferIPv6:
+	default:
+		return fmt.Errorf("invalid ip_family: %s (must be any, ipv4, ipv6, prefer_ipv4 or prefer_ipv6)", d.IPFamily)
 	}
+	if d.FallbackDelay < 0 {
+		return fmt.Errorf("fallback_delay cannot be negative")
+	}
+	if d.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	return nil
+}
 
-	// Endpoint defaults
-	for i := range config.Endpoints {
-		for j := range config.Endpoints[i].Destinations {
-			dest := &config.Endpoints[i].Destinations[j]
+// validateConnectionPoolConfig validates the connection pool of HTTP destinations
+func validateConnectionPoolConfig(p *ConnectionPoolConfig) error {
+	if p.MaxIdleConns < 0 {
+		return fmt.Errorf("max_idle_conns cannot be negative")
+	}
+	if p.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("max_idle_conns_per_host cannot be negative")
+	}
+	if p.MaxConnsPerHost < 0 {
+		return fmt.Errorf("max_conns_per_host cannot be negative")
+	}
+	if p.IdleConnTimeout < 0 {
+		return fmt.Errorf("idle_conn_timeout cannot be negative")
+	}
+	return nil
+}
 
-			// Default method is POST
-			if dest.Method == "" {
-				dest.Method = DefaultMethod
-			}
+// validateDeliveriesConfig validates the bounds of the concurrent deliveries
+func validateDeliveriesConfig(d *DeliveriesConfig) error {
+	if d.MaxConcurrent < 0 {
+		return fmt.Errorf("max_concurrent cannot be negative")
+	}
+	if d.MaxPerDestination < 0 {
+		return fmt.Errorf("max_per_destination cannot be negative")
+	}
+	if d.MaxConcurrent == 0 && d.MaxPerDestination == 0 {
+		return fmt.Errorf("max_concurrent or max_per_destination is required")
+	}
+	if d.QueueSize < 0 {
+		return fmt.Errorf("queue_size cannot be negative")
+	}
+	if d.MaxRetryAfter < 0 {
+		return fmt.Errorf("max_retry_after cannot be negative")
+	}
+	switch d.Overflow {
+	case DeliveriesOverflowReject, DeliveriesOverflowDrop:
+	default:
+		return fmt.Errorf("invalid overflow: %s (must be reject or drop)", d.Overflow)
+	}
+	return nil
+}
 
-			// Default timeout is 5 seconds
-			if dest.Timeout == 0 {
-				dest.Timeout = 5 * time.Second
-			}
+// validLocalAddress reports whether a local address is an IP address or the name of a
+// network interface of the host
+func validLocalAddress(address string) bool {
+	if net.ParseIP(address) != nil {
+		return true
+	}
+	_, err := net.InterfaceByName(address)
+	return err == nil
+}
 
-			// Default retries is 0 (no retries)
-			if dest.Retries < 0 {
-				dest.Retries = 0
-			}
+// validateDNSOutageConfig validates the handling of a destination's DNS outages
+func validateDNSOutageConfig(endpointIndex, destIndex int, dest DestinationConfig) error {
+	if !dest.SendsHTTP() {
+		return fmt.Errorf("endpoint[%d].destination[%d]: dns_outage requires an http destination", endpointIndex, destIndex)
+	}
 
-			// Default retry delay is 1 second
-			if dest.RetryDelay == 0 {
-				dest.RetryDelay = 1 * time.Second
-			}
-		}
+	d := dest.DNSOutage
+	if d.Threshold < 1 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: dns_outage.threshold must be at least 1", endpointIndex, destIndex)
+	}
+	if d.InitialBackoff <= 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: dns_outage.initial_backoff must be positive", endpointIndex, destIndex)
+	}
+	if d.MaxBackoff < d.InitialBackoff {
+		return fmt.Errorf("endpoint[%d].destination[%d]: dns_outage.max_backoff must be greater than or equal to initial_backoff", endpointIndex, destIndex)
 	}
+
+	return nil
 }
 
-// applyEnvironmentOverrides applies environment variable overrides to the configuration
-func applyEnvironmentOverrides(config *Config) {
-	// Server overrides
-	if port, exists := os.LookupEnv("WEBHOOK_PROXY_SERVER_PORT"); exists {
-		if p, err := strconv.Atoi(port); err == nil {
-			config.Server.Port = p
+// validateChaosConfig validates a fault injection configuration
+func validateChaosConfig(endpointIndex, destIndex int, c *ChaosConfig) error {
+	rates := []struct {
+		name  string
+		value float64
+	}{
+		{"delay_rate", c.DelayRate},
+		{"drop_rate", c.DropRate},
+		{"duplicate_rate", c.DuplicateRate},
+	}
+	for _, rate := range rates {
+		if rate.value < 0 || rate.value > 1 {
+			return fmt.Errorf("endpoint[%d].destination[%d]: chaos.%s must be between 0 and 1", endpointIndex, destIndex, rate.name)
 		}
 	}
-	if host, exists := os.LookupEnv("WEBHOOK_PROXY_SERVER_HOST"); exists {
-		config.Server.Host = host
+
+	if c.MaxDelay < 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: chaos.max_delay cannot be negative", endpointIndex, destIndex)
 	}
 
-	// Logging overrides
-	if level, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_LEVEL"); exists {
-		config.Logging.Level = level
+	return nil
+}
+
+// validateQuotaConfig validates the quota of an endpoint
+func validateQuotaConfig(index int, q *QuotaConfig) error {
+	if q.Daily < 0 || q.Monthly < 0 {
+		return fmt.Errorf("endpoint[%d]: quota.daily and quota.monthly cannot be negative", index)
 	}
-	if format, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_FORMAT"); exists {
-		config.Logging.Format = format
+	if q.Daily == 0 && q.Monthly == 0 {
+		return fmt.Errorf("endpoint[%d]: quota requires a daily or monthly limit", index)
 	}
-	if output, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_OUTPUT"); exists {
-		config.Logging.Output = output
+	if q.OnExceed != "" && q.OnExceed != QuotaReject && q.OnExceed != QuotaQueue && q.OnExceed != QuotaLogOnly {
+		return fmt.Errorf("endpoint[%d]: invalid quota.on_exceed: %s (must be reject, queue or log_only)", index, q.OnExceed)
 	}
-	if filePath, exists := os.LookupEnv("WEBHOOK_PROXY_LOG_FILE_PATH"); exists {
-		config.Logging.FilePath = filePath
+	if q.QueueSize < 0 {
+		return fmt.Errorf("endpoint[%d]: quota.queue_size cannot be negative", index)
 	}
+	return nil
+}
 
-	// Telemetry overrides
-	if enabled, exists := os.LookupEnv("WEBHOOK_PROXY_TELEMETRY_ENABLED"); exists {
-		config.Telemetry.Enabled = enabled == "true" || enabled == "1" || enabled == "yes"
+// validateWebSocketConfig validates a websocket destination configuration
+func validateWebSocketConfig(endpointIndex, destIndex int, ws *WebSocketConfig) error {
+	if ws == nil || ws.Path == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: websocket.path is required", endpointIndex, destIndex)
 	}
-	if exporterType, exists := os.LookupEnv("WEBHOOK_PROXY_TELEMETRY_EXPORTER_TYPE"); exists {
-		config.Telemetry.ExporterType = exporterType
+
+	if !strings.HasPrefix(ws.Path, "/") {
+		return fmt.Errorf("endpoint[%d].destination[%d]: websocket.path must start with /", endpointIndex, destIndex)
 	}
-	if endpoint, exists := os.LookupEnv("WEBHOOK_PROXY_TELEMETRY_ENDPOINT"); exists {
-		config.Telemetry.Endpoint = endpoint
+
+	if ws.Token == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: websocket.token is required", endpointIndex, destIndex)
 	}
-}
 
-// validateConfig validates the configuration
-func validateConfig(config *Config) error {
-	// Validate server configuration
-	if err := validateServerConfig(&config.Server); err != nil {
-		return err
+	switch ws.Payload {
+	case "", WebSocketPayloadMasked, WebSocketPayloadRedacted:
+	default:
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid websocket.payload: %s (must be masked or redacted)", endpointIndex, destIndex, ws.Payload)
 	}
 
-	// Validate logging configuration
-	if err := validateLoggingConfig(&config.Logging); err != nil {
-		return err
+	return nil
+}
+
+// validateDatabaseConfig validates a database destination configuration
+func validateDatabaseConfig(endpointIndex, destIndex int, db *DatabaseConfig) error {
+	if db == nil {
+		return fmt.Errorf("endpoint[%d].destination[%d]: database configuration is required", endpointIndex, destIndex)
 	}
 
-	// Validate telemetry configuration
-	if err := validateTelemetryConfig(&config.Telemetry); err != nil {
-		return err
+	switch db.Driver {
+	case DatabaseDriverPostgres:
+	case DatabaseDriverClickHouse:
+		// ClickHouse is reached through its HTTP interface
+		if u, err := url.ParseRequestURI(db.DSN); db.DSN != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https")) {
+			return fmt.Errorf("endpoint[%d].destination[%d]: database.dsn must be an http(s) url for clickhouse", endpointIndex, destIndex)
+		}
+	default:
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid database.driver: %s", endpointIndex, destIndex, db.Driver)
 	}
 
-	// Validate endpoints
-	if len(config.Endpoints) == 0 {
-		return fmt.Errorf("at least one endpoint is required")
+	if db.DSN == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: database.dsn is required", endpointIndex, destIndex)
 	}
 
-	for i, endpoint := range config.Endpoints {
-		if err := validateEndpointConfig(i, endpoint); err != nil {
-			return err
-		}
+	if !tableNamePattern.MatchString(db.Table) {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid database.table: %q", endpointIndex, destIndex, db.Table)
 	}
 
 	return nil
 }
 
-// validateServerConfig validates the server configuration
-func validateServerConfig(server *ServerConfig) error {
-	if server.Port < 0 || server.Port > 65535 {
-		return fmt.Errorf("invalid server port: %d", server.Port)
+// validateKafkaConfig validates a Kafka destination configuration
+func validateKafkaConfig(endpointIndex, destIndex int, kafka *KafkaConfig) error {
+	if kafka == nil || len(kafka.Brokers) == 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: kafka.brokers is required", endpointIndex, destIndex)
+	}
+	for _, broker := range kafka.Brokers {
+		if _, _, err := net.SplitHostPort(broker); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid kafka broker: %s (must be host:port)", endpointIndex, destIndex, broker)
+		}
 	}
-	return nil
-}
 
-// validateLoggingConfig validates the logging configuration
-func validateLoggingConfig(logging *LoggingConfig) error {
-	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
-	if !validLevels[logging.Level] {
-		return fmt.Errorf("invalid logging level: %s", logging.Level)
+	if kafka.Topic == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: kafka.topic is required", endpointIndex, destIndex)
 	}
 
-	validFormats := map[string]bool{"json": true, "text": true}
-	if !validFormats[logging.Format] {
-		return fmt.Errorf("invalid logging format: %s", logging.Format)
+	if kafka.KeyTemplate != "" {
+		if _, err := transform.Parse(kafka.KeyTemplate); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid kafka.key_template: %w", endpointIndex, destIndex, err)
+		}
 	}
 
-	validOutputs := map[string]bool{"stdout": true, "file": true}
-	if !validOutputs[logging.Output] {
-		return fmt.Errorf("invalid logging output: %s", logging.Output)
+	switch kafka.Acks {
+	case "", KafkaAcksAll, KafkaAcksOne, KafkaAcksNone:
+	default:
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid kafka.acks: %s (must be all, one or none)", endpointIndex, destIndex, kafka.Acks)
 	}
 
-	if logging.Output == "file" && logging.FilePath == "" {
-		return fmt.Errorf("file_path is required when output is file")
+	if sasl := kafka.SASL; sasl != nil {
+		switch sasl.Mechanism {
+		case KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512:
+		default:
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid kafka.sasl.mechanism: %s (must be plain, scram-sha-256 or scram-sha-512)", endpointIndex, destIndex, sasl.Mechanism)
+		}
+		if sasl.Username == "" || sasl.Password == "" {
+			return fmt.Errorf("endpoint[%d].destination[%d]: kafka.sasl.username and kafka.sasl.password are required", endpointIndex, destIndex)
+		}
+	}
+
+	if tls := kafka.TLS; tls != nil && (tls.CertFile == "") != (tls.KeyFile == "") {
+		return fmt.Errorf("endpoint[%d].destination[%d]: kafka.tls.cert_file and kafka.tls.key_file must be set together", endpointIndex, destIndex)
 	}
 
 	return nil
 }
 
-// validateTelemetryConfig validates the telemetry configuration
-func validateTelemetryConfig(telemetry *TelemetryConfig) error {
-	if !telemetry.Enabled {
-		return nil
+// validateSQSConfig validates an SQS destination configuration
+func validateSQSConfig(endpointIndex, destIndex int, sqs *SQSConfig) error {
+	if sqs == nil || sqs.Queue == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: sqs.queue is required", endpointIndex, destIndex)
 	}
-
-	if telemetry.ExporterType == "" {
-		return fmt.Errorf("exporter_type is required when telemetry is enabled")
+	if arn.IsARN(sqs.Queue) {
+		if parsed, err := arn.Parse(sqs.Queue); err != nil || parsed.Service != "sqs" || parsed.AccountID == "" {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid sqs.queue: %s (must be an SQS queue ARN or URL)", endpointIndex, destIndex, sqs.Queue)
+		}
+	} else if u, err := url.ParseRequestURI(sqs.Queue); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid sqs.queue: %s (must be an SQS queue ARN or URL)", endpointIndex, destIndex, sqs.Queue)
 	}
 
-	// Only require endpoint for certain exporter types
-	if telemetry.ExporterType != "stdout" && telemetry.Endpoint == "" {
-		return fmt.Errorf("endpoint is required when telemetry is enabled with exporter_type %s", telemetry.ExporterType)
+	return validateAWSMessaging(endpointIndex, destIndex, "sqs", awsMessaging{
+		endpoint:        sqs.Endpoint,
+		accessKeyID:     sqs.AccessKeyID,
+		secretAccessKey: sqs.SecretAccessKey,
+		fifo:            strings.HasSuffix(sqs.Queue, FIFOSuffix),
+		messageGroupID:  sqs.MessageGroupID,
+		attributes:      sqs.Attributes,
+	})
+}
+
+// validateSNSConfig validates an SNS destination configuration
+func validateSNSConfig(endpointIndex, destIndex int, sns *SNSConfig) error {
+	if sns == nil || sns.TopicARN == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: sns.topic_arn is required", endpointIndex, destIndex)
+	}
+	if parsed, err := arn.Parse(sns.TopicARN); err != nil || parsed.Service != "sns" || parsed.Region == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid sns.topic_arn: %s (must be an SNS topic ARN)", endpointIndex, destIndex, sns.TopicARN)
 	}
 
-	return nil
+	return validateAWSMessaging(endpointIndex, destIndex, "sns", awsMessaging{
+		endpoint:        sns.Endpoint,
+		accessKeyID:     sns.AccessKeyID,
+		secretAccessKey: sns.SecretAccessKey,
+		fifo:            strings.HasSuffix(sns.TopicARN, FIFOSuffix),
+		messageGroupID:  sns.MessageGroupID,
+		attributes:      sns.Attributes,
+	})
 }
 
-// validateEndpointConfig validates an endpoint configuration
-func validateEndpointConfig(index int, endpoint EndpointConfig) error {
-	if endpoint.Path == "" {
-		return fmt.Errorf("endpoint[%d]: path is required", index)
+// awsMessaging holds the settings SQS and SNS destinations share
+type awsMessaging struct {
+	endpoint        string
+	accessKeyID     string
+	secretAccessKey string
+	fifo            bool
+	messageGroupID  string
+	attributes      map[string]string
+}
+
+// messageAttributeNamePattern matches the names AWS accepts for message attributes: dot
+// separated words, up to 256 characters
+var messageAttributeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// ContentTransferEncodingAttribute is the message attribute marking the base64 bodies of
+// SQS and SNS destinations
+const ContentTransferEncodingAttribute = "Content-Transfer-Encoding"
+
+// validateAWSMessaging validates the settings of an SQS or SNS destination, under its type's key
+func validateAWSMessaging(endpointIndex, destIndex int, key string, m awsMessaging) error {
+	if m.endpoint != "" {
+		if u, err := url.ParseRequestURI(m.endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid %s.endpoint: must be an http or https URL", endpointIndex, destIndex, key)
+		}
 	}
 
-	// Ensure path starts with /
-	if !strings.HasPrefix(endpoint.Path, "/") {
-		return fmt.Errorf("endpoint[%d]: path must start with /", index)
+	if (m.accessKeyID == "") != (m.secretAccessKey == "") {
+		return fmt.Errorf("endpoint[%d].destination[%d]: %s.access_key_id and %s.secret_access_key must be set together", endpointIndex, destIndex, key, key)
 	}
 
-	if len(endpoint.Destinations) == 0 {
-		return fmt.Errorf("endpoint[%d]: at least one destination is required", index)
+	if m.fifo && m.messageGroupID == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: %s.message_group_id is required by FIFO destinations", endpointIndex, destIndex, key)
+	}
+	if m.messageGroupID != "" {
+		if _, err := transform.Parse(m.messageGroupID); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid %s.message_group_id: %w", endpointIndex, destIndex, key, err)
+		}
 	}
 
-	for j, dest := range endpoint.Destinations {
-		if err := validateDestinationConfig(index, j, dest); err != nil {
-			return err
+	if len(m.attributes) > MaxMessageAttributes {
+		return fmt.Errorf("endpoint[%d].destination[%d]: too many %s.attributes: %d (max %d)", endpointIndex, destIndex, key, len(m.attributes), MaxMessageAttributes)
+	}
+	for name, tmpl := range m.attributes {
+		lower := strings.ToLower(name)
+		if !messageAttributeNamePattern.MatchString(name) || len(name) > 256 || name == ContentTransferEncodingAttribute ||
+			strings.HasPrefix(lower, "aws.") || strings.HasPrefix(lower, "amazon.") {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid %s.attributes name: %q", endpointIndex, destIndex, key, name)
+		}
+		if _, err := transform.Parse(tmpl); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid %s.attributes[%s]: %w", endpointIndex, destIndex, key, name, err)
 		}
 	}
 
 	return nil
 }
 
-// validateDestinationConfig validates a destination configuration
-func validateDestinationConfig(endpointIndex, destIndex int, dest DestinationConfig) error {
-	if dest.URL == "" {
-		return fmt.Errorf("endpoint[%d].destination[%d]: url is required", endpointIndex, destIndex)
+// validateSlackConfig validates the message of a slack destination, posted to its url
+func validateSlackConfig(endpointIndex, destIndex int, dest DestinationConfig) error {
+	if dest.Transform != nil || dest.Encoding != nil {
+		return fmt.Errorf("endpoint[%d].destination[%d]: transform and encoding cannot be used with a slack destination, use slack.text instead", endpointIndex, destIndex)
 	}
+	if dest.Slack == nil || dest.Slack.Text == "" {
+		return nil
+	}
+	if _, err := transform.Parse(dest.Slack.Text); err != nil {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid slack.text: %w", endpointIndex, destIndex, err)
+	}
+	return nil
+}
 
-	// Validate URL
-	_, err := url.ParseRequestURI(dest.URL)
-	if err != nil {
-		return fmt.Errorf("endpoint[%d].destination[%d]: invalid url: %s", endpointIndex, destIndex, err)
+// validateS3Config validates an S3 destination configuration with the given timeout
+func validateS3Config(endpointIndex, destIndex int, s3 *S3Config, timeout time.Duration) error {
+	if s3 == nil || s3.Bucket == "" {
+		return fmt.Errorf("endpoint[%d].destination[%d]: s3.bucket is required", endpointIndex, destIndex)
 	}
 
-	// Validate HTTP method
-	validMethods := map[string]bool{
-		"GET": true, "POST": true, "PUT": true, "DELETE": true,
-		"PATCH": true, "HEAD": true, "OPTIONS": true,
+	if s3.Endpoint != "" {
+		if _, err := url.ParseRequestURI(s3.Endpoint); err != nil {
+			return fmt.Errorf("endpoint[%d].destination[%d]: invalid s3.endpoint: %s", endpointIndex, destIndex, err)
+		}
 	}
-	if !validMethods[strings.ToUpper(dest.Method)] {
-		return fmt.Errorf("endpoint[%d].destination[%d]: invalid method: %s", endpointIndex, destIndex, dest.Method)
+
+	if (s3.AccessKeyID == "") != (s3.SecretAccessKey == "") {
+		return fmt.Errorf("endpoint[%d].destination[%d]: s3.access_key_id and s3.secret_access_key must be set together", endpointIndex, destIndex)
 	}
 
-	// Validate timeout
-	if dest.Timeout < 0 {
-		return fmt.Errorf("endpoint[%d].destination[%d]: timeout cannot be negative", endpointIndex, destIndex)
+	if _, err := template.New("key").Parse(s3.KeyTemplate); err != nil {
+		return fmt.Errorf("endpoint[%d].destination[%d]: invalid s3.key_template: %s", endpointIndex, destIndex, err)
 	}
 
-	// Validate retries
-	if dest.Retries < 0 {
-		return fmt.Errorf("endpoint[%d].destination[%d]: retries cannot be negative", endpointIndex, destIndex)
+	if s3.BatchSize < 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: s3.batch_size cannot be negative", endpointIndex, destIndex)
 	}
 
-	// Validate retry delay
-	if dest.RetryDelay < 0 {
-		return fmt.Errorf("endpoint[%d].destination[%d]: retry_delay cannot be negative", endpointIndex, destIndex)
+	if s3.FlushInterval < 0 {
+		return fmt.Errorf("endpoint[%d].destination[%d]: s3.flush_interval cannot be negative", endpointIndex, destIndex)
+	}
+
+	// Batched webhooks are only acknowledged once uploaded, within the destination timeout
+	if s3.BatchSize > 1 && s3.FlushInterval >= timeout {
+		return fmt.Errorf("endpoint[%d].destination[%d]: s3.flush_interval must be shorter than the timeout (%s), as batched webhooks wait for their upload", endpointIndex, destIndex, timeout)
 	}
 
 	return nil
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -154,9 +159,15 @@ endpoints:
 	if config.Logging.Output != "stdout" {
 		t.Errorf("Expected default logging output stdout, got %s", config.Logging.Output)
 	}
+	if !config.Logging.MirrorToStdout() {
+		t.Errorf("Expected file output to be mirrored to stdout by default")
+	}
 
 	// Verify default destination config
 	dest := config.Endpoints[0].Destinations[0]
+	if dest.Type != DestinationTypeHTTP {
+		t.Errorf("Expected default destination type http, got %s", dest.Type)
+	}
 	if dest.Method != "POST" {
 		t.Errorf("Expected default destination method POST, got %s", dest.Method)
 	}
@@ -624,95 +635,3507 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expectError: true,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateConfig(&tt.config)
-			if (err != nil) != tt.expectError {
-				t.Errorf("validateConfig() error = %v, expectError %v", err, tt.expectError)
-			}
-		})
-	}
-}
-
-func TestValidateTelemetryConfig(t *testing.T) {
-	tests := []struct {
-		name      string
-		config    TelemetryConfig
-		expectErr bool
-	}{
 		{
-			name: "Disabled telemetry",
-			config: TelemetryConfig{
-				Enabled: false,
+			name: "Valid websocket destination",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:      DestinationTypeWebSocket,
+								WebSocket: &WebSocketConfig{Path: "/live", Token: "subscriber-token"},
+							},
+						},
+					},
+				},
 			},
-			expectErr: false,
+			expectError: false,
 		},
 		{
-			name: "Enabled with missing exporter_type",
-			config: TelemetryConfig{
-				Enabled:      true,
-				ExporterType: "",
+			name: "Websocket destination without token",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:      DestinationTypeWebSocket,
+								WebSocket: &WebSocketConfig{Path: "/live"},
+							},
+						},
+					},
+				},
 			},
-			expectErr: true,
+			expectError: true,
 		},
 		{
-			name: "Enabled with stdout exporter and no endpoint",
-			config: TelemetryConfig{
-				Enabled:      true,
-				ExporterType: "stdout",
-				Endpoint:     "",
+			name: "Invalid websocket payload",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:      DestinationTypeWebSocket,
+								WebSocket: &WebSocketConfig{Path: "/live", Token: "subscriber-token", Payload: "full"},
+							},
+						},
+					},
+				},
 			},
-			expectErr: false,
+			expectError: true,
 		},
 		{
-			name: "Enabled with non-stdout exporter and missing endpoint",
-			config: TelemetryConfig{
-				Enabled:      true,
-				ExporterType: "otlp",
-				Endpoint:     "",
+			name: "Websocket destination without path",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type: DestinationTypeWebSocket,
+							},
+						},
+					},
+				},
 			},
-			expectErr: true,
+			expectError: true,
 		},
 		{
-			name: "Enabled with non-stdout exporter and valid endpoint",
-			config: TelemetryConfig{
-				Enabled:      true,
-				ExporterType: "otlp",
-				Endpoint:     "http://localhost:4317",
+			name: "Websocket destination with relative path",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:      DestinationTypeWebSocket,
+								WebSocket: &WebSocketConfig{Path: "live"},
+							},
+						},
+					},
+				},
 			},
-			expectErr: false,
+			expectError: true,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateTelemetryConfig(&tt.config)
-			if tt.expectErr && err == nil {
-				t.Errorf("Expected error but got nil")
-			}
-			if !tt.expectErr && err != nil {
-				t.Errorf("Expected no error but got: %v", err)
-			}
-		})
-	}
-}
-
-// Helper function to create a temporary config file
-func createTempConfigFile(t *testing.T, configContent string) string {
-	tmpfile, err := os.CreateTemp("", "config-*.yaml")
-	if err != nil {
-		t.Fatalf("Failed to create temp file: %v", err)
-	}
-
-	if _, writeErr := tmpfile.Write([]byte(configContent)); writeErr != nil {
-		t.Fatalf("Failed to write to temp file: %v", writeErr)
-	}
-
-	if closeErr := tmpfile.Close(); closeErr != nil {
-		t.Fatalf("Failed to close temp file: %v", closeErr)
-	}
-
-	return tmpfile.Name()
+		{
+			name: "Duplicate websocket paths",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook/a",
+						Destinations: []DestinationConfig{
+							{
+								Type:      DestinationTypeWebSocket,
+								WebSocket: &WebSocketConfig{Path: "/live"},
+							},
+						},
+					},
+					{
+						Path: "/webhook/b",
+						Destinations: []DestinationConfig{
+							{
+								Type:      DestinationTypeWebSocket,
+								WebSocket: &WebSocketConfig{Path: "/live"},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid destination type",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type: "carrier-pigeon",
+								URL:  "http://example.com",
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Valid postgres destination",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:     DestinationTypeDatabase,
+								Database: &DatabaseConfig{Driver: DatabaseDriverPostgres, DSN: "postgres://localhost/webhooks", Table: "public.webhooks"},
+							},
+						},
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "Valid clickhouse destination",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:     DestinationTypeDatabase,
+								Database: &DatabaseConfig{Driver: DatabaseDriverClickHouse, DSN: "http://localhost:8123", Table: "webhooks"},
+							},
+						},
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "Database destination without configuration",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:     DestinationTypeDatabase,
+								Database: nil,
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Database destination with invalid driver",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:     DestinationTypeDatabase,
+								Database: &DatabaseConfig{Driver: "oracle", DSN: "dsn", Table: "webhooks"},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Database destination without dsn",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:     DestinationTypeDatabase,
+								Database: &DatabaseConfig{Driver: DatabaseDriverPostgres, Table: "webhooks"},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Database destination with invalid table",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:     DestinationTypeDatabase,
+								Database: &DatabaseConfig{Driver: DatabaseDriverPostgres, DSN: "postgres://localhost/webhooks", Table: "webhooks; DROP TABLE users"},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Clickhouse destination with invalid dsn",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:     DestinationTypeDatabase,
+								Database: &DatabaseConfig{Driver: DatabaseDriverClickHouse, DSN: "localhost:8123", Table: "webhooks"},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Valid s3 destination",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type: DestinationTypeS3,
+								S3:   &S3Config{Bucket: "archive", Endpoint: "http://localhost:9000", KeyTemplate: DefaultS3KeyTemplate},
+							},
+						},
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "S3 destination without bucket",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type: DestinationTypeS3,
+								S3:   &S3Config{KeyTemplate: DefaultS3KeyTemplate},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "S3 destination with invalid endpoint",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type: DestinationTypeS3,
+								S3:   &S3Config{Bucket: "archive", Endpoint: "localhost", KeyTemplate: DefaultS3KeyTemplate},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "S3 destination with partial credentials",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type: DestinationTypeS3,
+								S3:   &S3Config{Bucket: "archive", AccessKeyID: "key", KeyTemplate: DefaultS3KeyTemplate},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "S3 destination with invalid key template",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type: DestinationTypeS3,
+								S3:   &S3Config{Bucket: "archive", KeyTemplate: "{{.Unclosed"},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "S3 batches flushed after the timeout",
+			config: Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []DestinationConfig{
+							{
+								Type:    DestinationTypeS3,
+								Timeout: 5 * time.Second,
+								S3:      &S3Config{Bucket: "archive", KeyTemplate: DefaultS3KeyTemplate, BatchSize: 100, FlushInterval: time.Minute},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(&tt.config)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateConfig() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAlsoStdout(t *testing.T) {
+	configContent := `
+logging:
+  output: "file"
+  file_path: "/var/log/webhook-proxy.log"
+  also_stdout: false
+
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if config.Logging.MirrorToStdout() {
+		t.Errorf("Expected stdout mirroring to be disabled")
+	}
+
+	if config.Logging.ErrorSuppression.Window != DefaultErrorSuppressionWindow {
+		t.Errorf("Expected error suppression window %v, got %v", DefaultErrorSuppressionWindow, config.Logging.ErrorSuppression.Window)
+	}
+
+	if config.Logging.Body.MaxSize != DefaultBodyLogMaxSize {
+		t.Errorf("Expected body log max size %d, got %d", DefaultBodyLogMaxSize, config.Logging.Body.MaxSize)
+	}
+}
+
+func TestValidateLoggingConfigOutputs(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    LoggingConfig
+		expectErr bool
+	}{
+		{name: "Stderr output", config: LoggingConfig{Level: "info", Format: "json", Output: "stderr"}},
+		{name: "Local syslog output", config: LoggingConfig{Level: "info", Format: "json", Output: "syslog"}},
+		{
+			name:   "Remote syslog output",
+			config: LoggingConfig{Level: "info", Format: "json", Output: "syslog", Syslog: SyslogConfig{Network: "udp", Address: "syslog:514", Facility: "local7"}},
+		},
+		{
+			name:      "Syslog output with invalid network",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "syslog", Syslog: SyslogConfig{Network: "http", Address: "syslog:514"}},
+			expectErr: true,
+		},
+		{
+			name:      "Syslog output without address",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "syslog", Syslog: SyslogConfig{Network: "tcp"}},
+			expectErr: true,
+		},
+		{
+			name:      "Syslog output with invalid facility",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "syslog", Syslog: SyslogConfig{Facility: "local9"}},
+			expectErr: true,
+		},
+		{
+			name:   "GELF output",
+			config: LoggingConfig{Level: "info", Format: "json", Output: "gelf", GELF: GELFConfig{Network: "udp", Host: "graylog", Port: 12201, Compression: "gzip"}},
+		},
+		{
+			name:      "GELF output without host",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "gelf", GELF: GELFConfig{Network: "udp", Port: 12201, Compression: "none"}},
+			expectErr: true,
+		},
+		{
+			name:      "GELF output with invalid port",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "gelf", GELF: GELFConfig{Network: "udp", Host: "graylog", Port: 70000, Compression: "none"}},
+			expectErr: true,
+		},
+		{
+			name:      "GELF output with invalid compression",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "gelf", GELF: GELFConfig{Network: "udp", Host: "graylog", Port: 12201, Compression: "lz4"}},
+			expectErr: true,
+		},
+		{
+			name:      "GELF output with compression over tcp",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "gelf", GELF: GELFConfig{Network: "tcp", Host: "graylog", Port: 12201, Compression: "gzip"}},
+			expectErr: true,
+		},
+		{name: "ECS format", config: LoggingConfig{Level: "info", Format: "ecs", Output: "stdout", Labels: map[string]string{"env": "prod"}}},
+		{name: "GCP format", config: LoggingConfig{Level: "info", Format: "gcp", Output: "stdout"}},
+		{name: "Pretty format", config: LoggingConfig{Level: "info", Format: "pretty", Output: "stdout"}},
+		{name: "JSON field map", config: LoggingConfig{Level: "info", Format: "json", Output: "stdout", FieldMap: map[string]string{"time": "ts", "msg": "message"}}},
+		{
+			name:      "JSON field map with invalid key",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "stdout", FieldMap: map[string]string{"caller": "source"}},
+			expectErr: true,
+		},
+		{
+			name:      "JSON field map with empty name",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "stdout", FieldMap: map[string]string{"level": ""}},
+			expectErr: true,
+		},
+		{
+			name:      "Error suppression with negative window",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "stdout", ErrorSuppression: ErrorSuppressionConfig{Enabled: true, Window: -time.Second}},
+			expectErr: true,
+		},
+		{
+			name:   "Body logging",
+			config: LoggingConfig{Level: "info", Format: "json", Output: "stdout", Body: BodyLoggingConfig{Request: true, Response: true, MaxSize: 1024}},
+		},
+		{
+			name:      "Body logging with negative max size",
+			config:    LoggingConfig{Level: "info", Format: "json", Output: "stdout", Body: BodyLoggingConfig{Request: true, MaxSize: -1}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLoggingConfig(&tt.config)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateTelemetryConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    TelemetryConfig
+		expectErr bool
+	}{
+		{
+			name: "Disabled telemetry",
+			config: TelemetryConfig{
+				Enabled: false,
+			},
+			expectErr: false,
+		},
+		{
+			name: "Enabled with missing exporter_type",
+			config: TelemetryConfig{
+				Enabled:      true,
+				ExporterType: "",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Enabled with stdout exporter and no endpoint",
+			config: TelemetryConfig{
+				Enabled:      true,
+				ExporterType: "stdout",
+				Endpoint:     "",
+			},
+			expectErr: false,
+		},
+		{
+			name: "Enabled with non-stdout exporter and missing endpoint",
+			config: TelemetryConfig{
+				Enabled:      true,
+				ExporterType: "otlp",
+				Endpoint:     "",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Enabled with non-stdout exporter and valid endpoint",
+			config: TelemetryConfig{
+				Enabled:      true,
+				ExporterType: "otlp",
+				Endpoint:     "http://localhost:4317",
+			},
+			expectErr: false,
+		},
+		{
+			name:      "Unknown exporter",
+			config:    TelemetryConfig{Enabled: true, ExporterType: "jaeger", Endpoint: "localhost:14268"},
+			expectErr: true,
+		},
+		{
+			name:      "OTLP over HTTP with headers",
+			config:    TelemetryConfig{Enabled: true, ExporterType: "otlp", Endpoint: "https://otlp.example.com/v1/traces", Protocol: "http", Headers: map[string]string{"Api-Key": "secret"}},
+			expectErr: false,
+		},
+		{
+			name:      "Invalid protocol",
+			config:    TelemetryConfig{Enabled: true, ExporterType: "otlp", Endpoint: "localhost:4317", Protocol: "thrift"},
+			expectErr: true,
+		},
+		{
+			name:      "Endpoint without port",
+			config:    TelemetryConfig{Enabled: true, ExporterType: "otlp", Endpoint: "collector"},
+			expectErr: true,
+		},
+		{
+			name:      "Endpoint with another scheme",
+			config:    TelemetryConfig{Enabled: true, ExporterType: "otlp", Endpoint: "grpc://collector:4317"},
+			expectErr: true,
+		},
+		{
+			name:      "Client certificate",
+			config:    TelemetryConfig{Enabled: true, ExporterType: "otlp", Endpoint: "collector:4317", TLS: &TelemetryTLSConfig{CAFile: "ca.pem", CertFile: "client.pem", KeyFile: "client.key"}},
+			expectErr: false,
+		},
+		{
+			name:      "Certificate without key",
+			config:    TelemetryConfig{Enabled: true, ExporterType: "otlp", Endpoint: "collector:4317", TLS: &TelemetryTLSConfig{CertFile: "client.pem"}},
+			expectErr: true,
+		},
+		{
+			name:      "TLS with insecure",
+			config:    TelemetryConfig{Enabled: true, ExporterType: "otlp", Endpoint: "collector:4317", Insecure: true, TLS: &TelemetryTLSConfig{CAFile: "ca.pem"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTelemetryConfig(&tt.config)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigTelemetry(t *testing.T) {
+	t.Setenv("TEST_OTLP_API_KEY", "collector-key")
+	tmpFileName := createTempConfigFile(t, `
+telemetry:
+  enabled: true
+  exporter_type: "otlp"
+  endpoint: "otel-collector:4317"
+  headers:
+    api-key: "env:TEST_OTLP_API_KEY"
+endpoints:
+  - path: "/webhook"
+    destinations:
+      - url: "https://example.com/webhook"
+`)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Telemetry.Protocol != "grpc" {
+		t.Errorf("Expected the grpc protocol by default, got %s", config.Telemetry.Protocol)
+	}
+	if config.Telemetry.Headers["api-key"] != "collector-key" {
+		t.Errorf("Expected the header to be resolved, got %s", config.Telemetry.Headers["api-key"])
+	}
+}
+
+func TestLoadConfigS3Defaults(t *testing.T) {
+	configContent := `
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - type: "s3"
+        s3:
+          bucket: "archive"
+          gzip: true
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	s3 := config.Endpoints[0].Destinations[0].S3
+	if s3.KeyTemplate != DefaultS3KeyTemplate+".gz" {
+		t.Errorf("Expected default key template with .gz suffix, got %s", s3.KeyTemplate)
+	}
+	if s3.BatchSize != 1 {
+		t.Errorf("Expected default batch size 1, got %d", s3.BatchSize)
+	}
+	if s3.FlushInterval != 2500*time.Millisecond {
+		t.Errorf("Expected default flush interval of half the timeout, got %s", s3.FlushInterval)
+	}
+}
+
+func TestLoadConfigWebSocket(t *testing.T) {
+	t.Setenv("TEST_WEBSOCKET_TOKEN", "s3cret")
+
+	configContent := `
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - type: "websocket"
+        websocket:
+          path: "/live/masked"
+          token: "env:TEST_WEBSOCKET_TOKEN"
+      - type: "websocket"
+        websocket:
+          path: "/live/redacted"
+          token: "literal"
+          redact_fields: ["sender.email"]
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	masked, redacted := config.Endpoints[0].Destinations[0].WebSocket, config.Endpoints[0].Destinations[1].WebSocket
+	if masked.Token != "s3cret" {
+		t.Errorf("Expected the resolved token, got %s", masked.Token)
+	}
+	if masked.Payload != WebSocketPayloadMasked {
+		t.Errorf("Expected masked payloads by default, got %s", masked.Payload)
+	}
+	if redacted.Payload != WebSocketPayloadRedacted {
+		t.Errorf("Expected redacted payloads with redact fields, got %s", redacted.Payload)
+	}
+}
+
+func TestLoadConfigKafka(t *testing.T) {
+	t.Setenv("TEST_KAFKA_PASSWORD", "s3cret")
+
+	configContent := `
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - type: "kafka"
+        kafka:
+          brokers: ["kafka-1:9093", "kafka-2:9093"]
+          topic: "webhooks"
+          key_template: "{{.Payload.id}}"
+          sasl:
+            mechanism: "scram-sha-256"
+            username: "proxy"
+            password: "env:TEST_KAFKA_PASSWORD"
+          tls: {}
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dest := config.Endpoints[0].Destinations[0]
+	if dest.Key() != "kafka://kafka-1:9093/webhooks" {
+		t.Errorf("Expected key kafka://kafka-1:9093/webhooks, got %s", dest.Key())
+	}
+	if dest.Kafka.Acks != KafkaAcksAll {
+		t.Errorf("Expected default acks all, got %s", dest.Kafka.Acks)
+	}
+	if dest.Kafka.SASL.Password != "s3cret" {
+		t.Errorf("Expected the resolved SASL password, got %s", dest.Kafka.SASL.Password)
+	}
+	if dest.Kafka.TLS == nil {
+		t.Errorf("Expected TLS to be enabled")
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	t.Setenv("TEST_CANDIDATE_SECRET", "s3cret")
+
+	tmpFileName := createTempConfigFile(t, `
+outbound:
+  local_address: "127.0.0.1"
+pipelines:
+  - name: "shared"
+    destinations:
+      - url: "https://example.com/shared"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`)
+	defer os.Remove(tmpFileName)
+	base, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// A candidate inherits the path and the defaults
+	endpoint, err := ParseEndpoint([]byte(`
+destinations:
+  - url: "https://example.com/fixed"
+    signing:
+      secrets: ["current"]
+auth:
+  type: "bearer"
+  secret: "s3cret"
+`), "/webhook/test", base)
+	if err != nil {
+		t.Fatalf("Failed to parse endpoint: %v", err)
+	}
+	if endpoint.Path != "/webhook/test" {
+		t.Errorf("Expected the default path, got %s", endpoint.Path)
+	}
+	dest := endpoint.Destinations[0]
+	if dest.Method != "POST" || dest.Type != DestinationTypeHTTP || dest.LocalAddress != "127.0.0.1" {
+		t.Errorf("Expected the destination defaults, got method %s, type %s, local address %s", dest.Method, dest.Type, dest.LocalAddress)
+	}
+	if endpoint.Auth == nil || endpoint.Auth.Secret != "s3cret" {
+		t.Errorf("Expected the auth secret, got %+v", endpoint.Auth)
+	}
+
+	// Secret references are rejected without being resolved, whether they exist or not
+	var messages []string
+	for _, reference := range []string{"env:TEST_CANDIDATE_SECRET", "env:TEST_CANDIDATE_MISSING", "file:/etc/hostname", "file:/missing"} {
+		_, err := ParseEndpoint([]byte(`{"auth": {"type": "bearer", "secret": "`+reference+`"}}`), "/webhook/test", base)
+		if err == nil {
+			t.Fatalf("Expected error for secret reference %s", reference)
+		}
+		messages = append(messages, err.Error())
+	}
+	for _, message := range messages[1:] {
+		if message != messages[0] {
+			t.Errorf("Expected the same error for every secret reference, got %q and %q", messages[0], message)
+		}
+	}
+	if _, err := ParseEndpoint([]byte(`{"destinations": [{"url": "https://example.com", "proxy": {"url": "http://proxy:3128", "password": "env:TEST_CANDIDATE_SECRET"}}]}`), "/webhook/test", base); err == nil {
+		t.Error("Expected error for a proxy password secret reference")
+	}
+
+	// Candidates can use the pipelines of the configuration
+	endpoint, err = ParseEndpoint([]byte(`{"path": "/webhook/other", "pipeline": "shared"}`), "/webhook/test", base)
+	if err != nil {
+		t.Fatalf("Failed to parse endpoint: %v", err)
+	}
+	if endpoint.Path != "/webhook/other" || len(endpoint.Destinations) != 1 || endpoint.Destinations[0].URL != "https://example.com/shared" {
+		t.Errorf("Expected the pipeline's destinations, got %+v", endpoint)
+	}
+
+	// Invalid candidates are rejected, and the configuration is left untouched
+	for _, candidate := range []string{`destinations: {`, `pipeline: "unknown"`, `destinations: [{url: "not a url"}]`} {
+		if _, err := ParseEndpoint([]byte(candidate), "/webhook/test", base); err == nil {
+			t.Errorf("Expected error for candidate %q", candidate)
+		}
+	}
+	if len(base.Endpoints) != 1 || base.Endpoints[0].Destinations[0].URL != "https://example.com/webhook" {
+		t.Errorf("Expected the configuration to be unchanged, got %+v", base.Endpoints)
+	}
+}
+
+func TestValidateKafkaDestination(t *testing.T) {
+	valid := func() *KafkaConfig {
+		return &KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "webhooks"}
+	}
+	tests := []struct {
+		name        string
+		modify      func(k *KafkaConfig) *KafkaConfig
+		expectError bool
+	}{
+		{"valid", func(k *KafkaConfig) *KafkaConfig { return k }, false},
+		{"missing configuration", func(*KafkaConfig) *KafkaConfig { return nil }, true},
+		{"missing brokers", func(k *KafkaConfig) *KafkaConfig { k.Brokers = nil; return k }, true},
+		{"broker without port", func(k *KafkaConfig) *KafkaConfig { k.Brokers = []string{"localhost"}; return k }, true},
+		{"missing topic", func(k *KafkaConfig) *KafkaConfig { k.Topic = ""; return k }, true},
+		{"key template", func(k *KafkaConfig) *KafkaConfig { k.KeyTemplate = "{{.Payload.id}}"; return k }, false},
+		{"invalid key template", func(k *KafkaConfig) *KafkaConfig { k.KeyTemplate = "{{.Payload"; return k }, true},
+		{"acks", func(k *KafkaConfig) *KafkaConfig { k.Acks = KafkaAcksNone; return k }, false},
+		{"invalid acks", func(k *KafkaConfig) *KafkaConfig { k.Acks = "2"; return k }, true},
+		{"sasl", func(k *KafkaConfig) *KafkaConfig {
+			k.SASL = &KafkaSASLConfig{Mechanism: KafkaSASLPlain, Username: "proxy", Password: "secret"}
+			return k
+		}, false},
+		{"invalid sasl mechanism", func(k *KafkaConfig) *KafkaConfig {
+			k.SASL = &KafkaSASLConfig{Mechanism: "gssapi", Username: "proxy", Password: "secret"}
+			return k
+		}, true},
+		{"sasl without password", func(k *KafkaConfig) *KafkaConfig {
+			k.SASL = &KafkaSASLConfig{Mechanism: KafkaSASLScramSHA512, Username: "proxy"}
+			return k
+		}, true},
+		{"client certificate without key", func(k *KafkaConfig) *KafkaConfig {
+			k.TLS = &KafkaTLSConfig{CertFile: "client.pem"}
+			return k
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{Type: DestinationTypeKafka, Kafka: tt.modify(valid())}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSQSDestination(t *testing.T) {
+	valid := func() *SQSConfig {
+		return &SQSConfig{Queue: "https://sqs.eu-west-1.amazonaws.com/123456789012/webhooks"}
+	}
+	tests := []struct {
+		name        string
+		modify      func(q *SQSConfig) *SQSConfig
+		expectError bool
+	}{
+		{"valid", func(q *SQSConfig) *SQSConfig { return q }, false},
+		{"missing configuration", func(*SQSConfig) *SQSConfig { return nil }, true},
+		{"missing queue", func(q *SQSConfig) *SQSConfig { q.Queue = ""; return q }, true},
+		{"queue arn", func(q *SQSConfig) *SQSConfig { q.Queue = "arn:aws:sqs:eu-west-1:123456789012:webhooks"; return q }, false},
+		{"arn of another service", func(q *SQSConfig) *SQSConfig { q.Queue = "arn:aws:sns:eu-west-1:123456789012:webhooks"; return q }, true},
+		{"queue name", func(q *SQSConfig) *SQSConfig { q.Queue = "webhooks"; return q }, true},
+		{"custom endpoint", func(q *SQSConfig) *SQSConfig { q.Endpoint = "http://localhost:4566"; return q }, false},
+		{"invalid endpoint", func(q *SQSConfig) *SQSConfig { q.Endpoint = "localhost:4566"; return q }, true},
+		{"access key without secret", func(q *SQSConfig) *SQSConfig { q.AccessKeyID = "AKIA"; return q }, true},
+		{"fifo queue", func(q *SQSConfig) *SQSConfig {
+			q.Queue += FIFOSuffix
+			q.MessageGroupID = "{{.Payload.repository.full_name}}"
+			return q
+		}, false},
+		{"fifo queue without message group", func(q *SQSConfig) *SQSConfig { q.Queue += FIFOSuffix; return q }, true},
+		{"invalid message group template", func(q *SQSConfig) *SQSConfig { q.MessageGroupID = "{{.Payload"; return q }, true},
+		{"attributes", func(q *SQSConfig) *SQSConfig {
+			q.Attributes = map[string]string{"event": `{{index .Headers "X-GitHub-Event"}}`, "source.name": "github"}
+			return q
+		}, false},
+		{"invalid attribute template", func(q *SQSConfig) *SQSConfig { q.Attributes = map[string]string{"event": "{{.Payload"}; return q }, true},
+		{"invalid attribute name", func(q *SQSConfig) *SQSConfig { q.Attributes = map[string]string{"event type": "push"}; return q }, true},
+		{"reserved attribute prefix", func(q *SQSConfig) *SQSConfig { q.Attributes = map[string]string{"AWS.trace": "1"}; return q }, true},
+		{"reserved attribute", func(q *SQSConfig) *SQSConfig {
+			q.Attributes = map[string]string{ContentTransferEncodingAttribute: "gzip"}
+			return q
+		}, true},
+		{"too many attributes", func(q *SQSConfig) *SQSConfig {
+			q.Attributes = map[string]string{}
+			for i := 0; i <= MaxMessageAttributes; i++ {
+				q.Attributes[fmt.Sprintf("attribute%d", i)] = "value"
+			}
+			return q
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{Type: DestinationTypeSQS, SQS: tt.modify(valid())}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSNSDestination(t *testing.T) {
+	valid := func() *SNSConfig {
+		return &SNSConfig{TopicARN: "arn:aws:sns:eu-west-1:123456789012:webhooks"}
+	}
+	tests := []struct {
+		name        string
+		modify      func(n *SNSConfig) *SNSConfig
+		expectError bool
+	}{
+		{"valid", func(n *SNSConfig) *SNSConfig { return n }, false},
+		{"missing configuration", func(*SNSConfig) *SNSConfig { return nil }, true},
+		{"missing topic", func(n *SNSConfig) *SNSConfig { n.TopicARN = ""; return n }, true},
+		{"topic name", func(n *SNSConfig) *SNSConfig { n.TopicARN = "webhooks"; return n }, true},
+		{"arn of another service", func(n *SNSConfig) *SNSConfig { n.TopicARN = "arn:aws:sqs:eu-west-1:123456789012:webhooks"; return n }, true},
+		{"arn without region", func(n *SNSConfig) *SNSConfig { n.TopicARN = "arn:aws:sns::123456789012:webhooks"; return n }, true},
+		{"fifo topic", func(n *SNSConfig) *SNSConfig {
+			n.TopicARN += FIFOSuffix
+			n.MessageGroupID = "{{.Payload.customer}}"
+			return n
+		}, false},
+		{"fifo topic without message group", func(n *SNSConfig) *SNSConfig { n.TopicARN += FIFOSuffix; return n }, true},
+		{"attributes", func(n *SNSConfig) *SNSConfig { n.Attributes = map[string]string{"type": "{{.Payload.type}}"}; return n }, false},
+		{"invalid attribute name", func(n *SNSConfig) *SNSConfig { n.Attributes = map[string]string{".type": "push"}; return n }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{Type: DestinationTypeSNS, SNS: tt.modify(valid())}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestDestinationKey(t *testing.T) {
+	httpDest := DestinationConfig{Type: DestinationTypeHTTP, URL: "https://example.com/webhook"}
+	if httpDest.Key() != "https://example.com/webhook" {
+		t.Errorf("Expected key https://example.com/webhook, got %s", httpDest.Key())
+	}
+
+	wsDest := DestinationConfig{Type: DestinationTypeWebSocket, WebSocket: &WebSocketConfig{Path: "/live"}}
+	if wsDest.Key() != "websocket:/live" {
+		t.Errorf("Expected key websocket:/live, got %s", wsDest.Key())
+	}
+
+	sqsDest := DestinationConfig{Type: DestinationTypeSQS, SQS: &SQSConfig{Queue: "arn:aws:sqs:eu-west-1:123456789012:webhooks"}}
+	if sqsDest.Key() != "sqs:arn:aws:sqs:eu-west-1:123456789012:webhooks" {
+		t.Errorf("Expected key sqs:arn:aws:sqs:eu-west-1:123456789012:webhooks, got %s", sqsDest.Key())
+	}
+}
+
+// Helper function to create a temporary config file
+func createTempConfigFile(t *testing.T, configContent string) string {
+	tmpfile, err := os.CreateTemp("", "config-*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	if _, writeErr := tmpfile.Write([]byte(configContent)); writeErr != nil {
+		t.Fatalf("Failed to write to temp file: %v", writeErr)
+	}
+
+	if closeErr := tmpfile.Close(); closeErr != nil {
+		t.Fatalf("Failed to close temp file: %v", closeErr)
+	}
+
+	return tmpfile.Name()
+}
+
+func TestLoadConfigConcurrencyDefaults(t *testing.T) {
+	configContent := `
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+        concurrency:
+          max_limit: 50
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	concurrency := config.Endpoints[0].Destinations[0].Concurrency
+	if concurrency.MinLimit != 1 {
+		t.Errorf("Expected default min limit 1, got %d", concurrency.MinLimit)
+	}
+	if concurrency.InitialLimit != 10 {
+		t.Errorf("Expected default initial limit 10, got %d", concurrency.InitialLimit)
+	}
+	if concurrency.LatencyThreshold != time.Second {
+		t.Errorf("Expected default latency threshold 1s, got %s", concurrency.LatencyThreshold)
+	}
+	if concurrency.Backoff != 0.9 {
+		t.Errorf("Expected default backoff 0.9, got %f", concurrency.Backoff)
+	}
+}
+
+func TestValidateServerConfigPrewarm(t *testing.T) {
+	if err := validateServerConfig(&ServerConfig{Port: 8080, Prewarm: PrewarmConfig{Enabled: true, Interval: time.Minute}}); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+
+	if err := validateServerConfig(&ServerConfig{Port: 8080, Prewarm: PrewarmConfig{Enabled: true, Interval: -time.Minute}}); err == nil {
+		t.Errorf("Expected error for negative prewarm interval")
+	}
+}
+
+func TestValidateConcurrencyConfig(t *testing.T) {
+	valid := ConcurrencyConfig{InitialLimit: 10, MinLimit: 1, MaxLimit: 100, LatencyThreshold: time.Second, Backoff: 0.9}
+
+	tests := []struct {
+		name      string
+		modify    func(c *ConcurrencyConfig)
+		expectErr bool
+	}{
+		{name: "Valid", modify: func(*ConcurrencyConfig) {}},
+		{name: "Min limit below 1", modify: func(c *ConcurrencyConfig) { c.MinLimit = 0 }, expectErr: true},
+		{name: "Max limit below min limit", modify: func(c *ConcurrencyConfig) { c.MaxLimit = 0 }, expectErr: true},
+		{name: "Initial limit above max limit", modify: func(c *ConcurrencyConfig) { c.InitialLimit = 200 }, expectErr: true},
+		{name: "Negative latency threshold", modify: func(c *ConcurrencyConfig) { c.LatencyThreshold = -time.Second }, expectErr: true},
+		{name: "Backoff of 1", modify: func(c *ConcurrencyConfig) { c.Backoff = 1 }, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+
+			err := validateConcurrencyConfig(0, 0, &c)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMaxDeliveryDuration(t *testing.T) {
+	configContent := `
+endpoints:
+  - path: "/webhook/test"
+    max_delivery_duration: 30s
+    destinations:
+      - url: "https://example.com/inherited"
+      - url: "https://example.com/overridden"
+        max_delivery_duration: 10s
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dests := config.Endpoints[0].Destinations
+	if dests[0].MaxDeliveryDuration != 30*time.Second {
+		t.Errorf("Expected inherited max delivery duration 30s, got %s", dests[0].MaxDeliveryDuration)
+	}
+	if dests[1].MaxDeliveryDuration != 10*time.Second {
+		t.Errorf("Expected max delivery duration 10s, got %s", dests[1].MaxDeliveryDuration)
+	}
+}
+
+func TestValidateMaxDeliveryDuration(t *testing.T) {
+	endpoint := EndpointConfig{
+		Path:                "/webhook/test",
+		MaxDeliveryDuration: -time.Second,
+		Destinations:        []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+	}
+	if err := validateEndpointConfig(0, endpoint); err == nil {
+		t.Errorf("Expected error for negative endpoint max_delivery_duration")
+	}
+
+	endpoint.MaxDeliveryDuration = 0
+	endpoint.Destinations[0].MaxDeliveryDuration = -time.Second
+	if err := validateEndpointConfig(0, endpoint); err == nil {
+		t.Errorf("Expected error for negative destination max_delivery_duration")
+	}
+}
+
+func TestValidateRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    map[string]bool
+		expectErr bool
+	}{
+		{name: "Transport classes", policy: map[string]bool{"dns": false, "tls": false, "connection_reset": true}},
+		{name: "HTTP status class", policy: map[string]bool{"http_400": false}},
+		{name: "Unknown class", policy: map[string]bool{"dns_failure": false}, expectErr: true},
+		{name: "Invalid HTTP status class", policy: map[string]bool{"http_4xx": false}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", RetryPolicy: tt.policy}
+
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestDestinationRetryErrorClass(t *testing.T) {
+	dest := DestinationConfig{RetryPolicy: map[string]bool{"dns": false, "timeout": true}}
+
+	if dest.RetryErrorClass("dns") {
+		t.Errorf("Expected dns errors not to be retried")
+	}
+	if !dest.RetryErrorClass("timeout") {
+		t.Errorf("Expected timeout errors to be retried")
+	}
+	if !dest.RetryErrorClass("connection_reset") {
+		t.Errorf("Expected unlisted error classes to be retried")
+	}
+}
+
+func TestLoadConfigGELFDefaults(t *testing.T) {
+	configContent := `
+logging:
+  output: "gelf"
+  gelf:
+    host: "graylog.example.com"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	gelf := config.Logging.GELF
+	if gelf.Network != "udp" {
+		t.Errorf("Expected default gelf network udp, got %s", gelf.Network)
+	}
+	if gelf.Port != DefaultGELFPort {
+		t.Errorf("Expected default gelf port %d, got %d", DefaultGELFPort, gelf.Port)
+	}
+	if gelf.Compression != "none" {
+		t.Errorf("Expected default gelf compression none, got %s", gelf.Compression)
+	}
+}
+
+func TestLoadConfigStats(t *testing.T) {
+	tmpFileName := createTempConfigFile(t, `
+stats:
+  directory: "/var/lib/webhook-proxy/stats"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Stats.RetentionDays != DefaultStatsRetentionDays {
+		t.Errorf("Expected default retention of %d days, got %d", DefaultStatsRetentionDays, config.Stats.RetentionDays)
+	}
+	if config.Stats.FlushInterval != DefaultStatsFlushInterval {
+		t.Errorf("Expected default flush interval %s, got %s", DefaultStatsFlushInterval, config.Stats.FlushInterval)
+	}
+
+	invalidFileName := createTempConfigFile(t, `
+stats:
+  directory: "/var/lib/webhook-proxy/stats"
+  retention_days: -1
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`)
+	defer os.Remove(invalidFileName)
+
+	if _, err := LoadConfig(invalidFileName); err == nil {
+		t.Errorf("Expected error for negative stats.retention_days")
+	}
+}
+
+func TestLoadConfigDeadLetters(t *testing.T) {
+	tmpFileName := createTempConfigFile(t, `
+dead_letters:
+  directory: "/var/lib/webhook-proxy/dead-letters"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.DeadLetters.Directory != "/var/lib/webhook-proxy/dead-letters" {
+		t.Errorf("Expected dead letter directory from the file, got %s", config.DeadLetters.Directory)
+	}
+
+	t.Setenv("WEBHOOK_PROXY_DEAD_LETTERS_DIRECTORY", "/tmp/dead-letters")
+	config, err = LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.DeadLetters.Directory != "/tmp/dead-letters" {
+		t.Errorf("Expected dead letter directory from the environment, got %s", config.DeadLetters.Directory)
+	}
+}
+
+func TestLoadConfigQueue(t *testing.T) {
+	tmpFileName := createTempConfigFile(t, `
+queue:
+  directory: "/var/lib/webhook-proxy/queue"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Queue.Workers != DefaultQueueWorkers {
+		t.Errorf("Expected default of %d workers, got %d", DefaultQueueWorkers, config.Queue.Workers)
+	}
+
+	t.Setenv("WEBHOOK_PROXY_QUEUE_DIRECTORY", "/tmp/queue")
+	config, err = LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Queue.Directory != "/tmp/queue" {
+		t.Errorf("Expected queue directory from the environment, got %s", config.Queue.Directory)
+	}
+
+	invalidFileName := createTempConfigFile(t, `
+queue:
+  directory: "/var/lib/webhook-proxy/queue"
+  workers: -1
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`)
+	defer os.Remove(invalidFileName)
+
+	if _, err := LoadConfig(invalidFileName); err == nil {
+		t.Errorf("Expected error for negative queue.workers")
+	}
+}
+
+func TestValidateRetentionConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention RetentionConfig
+		expectErr bool
+	}{
+		{name: "Disabled", retention: RetentionConfig{}},
+		{name: "All limits", retention: RetentionConfig{MaxAge: time.Hour, MaxTotalSize: 1 << 20, MaxCountPerEndpoint: 100, Interval: time.Minute}},
+		{name: "Negative max age", retention: RetentionConfig{MaxAge: -time.Hour}, expectErr: true},
+		{name: "Negative max total size", retention: RetentionConfig{MaxTotalSize: -1}, expectErr: true},
+		{name: "Negative max count per endpoint", retention: RetentionConfig{MaxCountPerEndpoint: -1}, expectErr: true},
+		{name: "Negative interval", retention: RetentionConfig{Interval: -time.Minute}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRetentionConfig("retry_state", &tt.retention)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	if (RetentionConfig{Interval: time.Minute}).Enabled() {
+		t.Errorf("Expected retention without limits to be disabled")
+	}
+	if !(RetentionConfig{MaxCountPerEndpoint: 10}).Enabled() {
+		t.Errorf("Expected retention with a limit to be enabled")
+	}
+}
+
+func TestValidateEndpointProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  string
+		secret    string
+		expectErr bool
+	}{
+		{name: "No provider"},
+		{name: "GitHub with secret", provider: ProviderGitHub, secret: "s3cr3t"},
+		{name: "Shopify with secret", provider: ProviderShopify, secret: "s3cr3t"},
+		{name: "Provider without secret", provider: ProviderStripe, expectErr: true},
+		{name: "Unknown provider", provider: "bitbucket", secret: "s3cr3t", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := EndpointConfig{
+				Path:         "/webhook/test",
+				Provider:     tt.provider,
+				Secret:       tt.secret,
+				Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+			}
+
+			err := validateEndpointConfig(0, endpoint)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  string
+		events    *EventsConfig
+		expectErr bool
+	}{
+		{name: "No events", provider: ProviderGitHub},
+		{name: "Allow", provider: ProviderGitHub, events: &EventsConfig{Allow: []string{"push", "pull_request"}}},
+		{name: "Deny", provider: ProviderStripe, events: &EventsConfig{Deny: []string{"charge.refunded"}}},
+		{name: "Without provider", events: &EventsConfig{Allow: []string{"push"}}, expectErr: true},
+		{name: "Empty lists", provider: ProviderGitHub, events: &EventsConfig{}, expectErr: true},
+		{name: "Empty event", provider: ProviderGitHub, events: &EventsConfig{Allow: []string{""}}, expectErr: true},
+		{name: "Allowed and denied", provider: ProviderGitHub, events: &EventsConfig{Allow: []string{"push"}, Deny: []string{"push"}}, expectErr: true},
+		{name: "Patterns", provider: ProviderStripe, events: &EventsConfig{Allow: []string{"invoice.*"}, Deny: []string{"invoice.upcoming"}}},
+		{name: "Invalid pattern", provider: ProviderStripe, events: &EventsConfig{Allow: []string{"invoice.[paid"}}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := ""
+			if tt.provider != "" {
+				secret = "s3cr3t"
+			}
+			endpoint := EndpointConfig{
+				Path:         "/webhook/test",
+				Provider:     tt.provider,
+				Secret:       secret,
+				Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST", Events: tt.events}},
+			}
+
+			err := validateEndpointConfig(0, endpoint)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestEventsConfigAllows(t *testing.T) {
+	var unset *EventsConfig
+	if !unset.Allows("ping") {
+		t.Errorf("Expected unset events to allow every event")
+	}
+
+	events := &EventsConfig{Allow: []string{"push", "pull_request"}}
+	if !events.Allows("push") || events.Allows("issues") {
+		t.Errorf("Expected only the allowed events to be allowed")
+	}
+
+	events = &EventsConfig{Deny: []string{"star"}}
+	if events.Allows("star") || !events.Allows("push") {
+		t.Errorf("Expected every event but the denied ones to be allowed")
+	}
+
+	events = &EventsConfig{Allow: []string{"invoice.*", "charge.*"}, Deny: []string{"charge.refund.*"}}
+	for event, expected := range map[string]bool{
+		"invoice.paid":           true,
+		"charge.succeeded":       true,
+		"charge.refund.updated":  false,
+		"customer.created":       false,
+		"invoice_item.created":   false,
+		"invoice.payment_failed": true,
+	} {
+		if events.Allows(event) != expected {
+			t.Errorf("Expected Allows(%s) to be %v", event, expected)
+		}
+	}
+}
+
+func TestValidateSignatureTolerance(t *testing.T) {
+	stripeAuth := &AuthConfig{Style: AuthStyleStripe, Algorithm: AuthAlgorithmSHA256, Header: "X-Signature", Secret: "s3cr3t"}
+	tests := []struct {
+		name      string
+		endpoint  EndpointConfig
+		expectErr bool
+	}{
+		{name: "Stripe", endpoint: EndpointConfig{Provider: ProviderStripe, Secret: "s3cr3t", SignatureTolerance: 10 * time.Minute}},
+		{name: "Slack", endpoint: EndpointConfig{Provider: ProviderSlack, Secret: "s3cr3t", SignatureTolerance: time.Minute}},
+		{name: "Stripe auth style", endpoint: EndpointConfig{Auth: stripeAuth, SignatureTolerance: time.Minute}},
+		{name: "GitHub", endpoint: EndpointConfig{Provider: ProviderGitHub, Secret: "s3cr3t", SignatureTolerance: time.Minute}, expectErr: true},
+		{name: "No signature", endpoint: EndpointConfig{SignatureTolerance: time.Minute}, expectErr: true},
+		{name: "Negative", endpoint: EndpointConfig{Provider: ProviderStripe, Secret: "s3cr3t", SignatureTolerance: -time.Minute}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.endpoint.Path = "/webhook/test"
+			tt.endpoint.Destinations = []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}}
+
+			err := validateEndpointConfig(0, tt.endpoint)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateResponseConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  ResponseConfig
+		expectErr bool
+	}{
+		{name: "Templated body", response: ResponseConfig{StatusCode: 200, Body: `{"id":"{{.DeliveryID}}"}`}},
+		{name: "Error status", response: ResponseConfig{StatusCode: 500, Body: "error"}, expectErr: true},
+		{name: "Invalid template", response: ResponseConfig{StatusCode: 200, Body: "{{.DeliveryID"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResponseConfig(0, &tt.response)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigResponseDefaults(t *testing.T) {
+	configContent := `
+endpoints:
+  - path: "/webhook/test"
+    response:
+      headers:
+        X-Custom: "value"
+    destinations:
+      - url: "https://example.com/webhook"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	response := config.Endpoints[0].Response
+	if response.StatusCode != 202 {
+		t.Errorf("Expected default response status 202, got %d", response.StatusCode)
+	}
+	if response.Body != DefaultResponseBody {
+		t.Errorf("Expected default response body, got %s", response.Body)
+	}
+}
+
+func TestValidateDestinationCompression(t *testing.T) {
+	dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", Compression: CompressionGzip}
+	if err := validateDestinationConfig(0, 0, dest); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+
+	dest.Compression = "br"
+	if err := validateDestinationConfig(0, 0, dest); err == nil {
+		t.Errorf("Expected error for unsupported compression")
+	}
+}
+
+func TestValidateDestinationTraceHeaders(t *testing.T) {
+	dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", TraceHeaders: []string{TraceHeadersRequestID, TraceHeadersB3}}
+	if err := validateDestinationConfig(0, 0, dest); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+
+	dest.TraceHeaders = []string{"traceparent"}
+	if err := validateDestinationConfig(0, 0, dest); err == nil {
+		t.Errorf("Expected error for unsupported trace headers")
+	}
+}
+
+func TestValidateDestinationFormFormat(t *testing.T) {
+	for _, format := range []string{"", PayloadFormatVerbatim, PayloadFormatJSON} {
+		dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", FormFormat: format}
+		if err := validateDestinationConfig(0, 0, dest); err != nil {
+			t.Errorf("Expected no error for form_format %q but got: %v", format, err)
+		}
+	}
+
+	dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", FormFormat: "xml"}
+	if err := validateDestinationConfig(0, 0, dest); err == nil {
+		t.Errorf("Expected error for invalid form_format")
+	}
+}
+
+func TestValidateDestinationXMLFormat(t *testing.T) {
+	for _, format := range []string{"", PayloadFormatVerbatim, PayloadFormatJSON} {
+		dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", XMLFormat: format}
+		if err := validateDestinationConfig(0, 0, dest); err != nil {
+			t.Errorf("Expected no error for xml_format %q but got: %v", format, err)
+		}
+	}
+
+	dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", XMLFormat: "yaml"}
+	if err := validateDestinationConfig(0, 0, dest); err == nil {
+		t.Errorf("Expected error for invalid xml_format")
+	}
+}
+
+func TestValidateDestinationFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		filters     []FilterConfig
+		expectError bool
+	}{
+		{"no filters", nil, false},
+		{"valid xpath", []FilterConfig{{XPath: "/Notification/Type", Equals: "Notification"}}, false},
+		{"attribute xpath", []FilterConfig{{XPath: "//Event/@type"}}, false},
+		{"missing xpath", []FilterConfig{{Equals: "Notification"}}, true},
+		{"relative xpath", []FilterConfig{{XPath: "Notification/Type"}}, true},
+		{"descendant attribute", []FilterConfig{{XPath: "//@type"}}, true},
+		{"field", []FilterConfig{{Field: "action", Equals: "opened"}}, false},
+		{"xpath and field", []FilterConfig{{XPath: "//Type", Field: "action"}}, true},
+		{"header", []FilterConfig{{Header: "X-GitHub-Event", Equals: "push"}}, false},
+		{"json path", []FilterConfig{{JSONPath: "ref", Equals: "refs/heads/main"}}, false},
+		{"nested json path", []FilterConfig{{JSONPath: "commits.0.author.email"}}, false},
+		{"invalid json path", []FilterConfig{{JSONPath: "repository..name"}}, true},
+		{"header and json path", []FilterConfig{{Header: "X-GitHub-Event", JSONPath: "ref"}}, true},
+		{"regex", []FilterConfig{{JSONPath: "ref", Regex: "^refs/heads/(main|release/.*)$"}}, false},
+		{"invalid regex", []FilterConfig{{Header: "X-GitHub-Event", Regex: "push("}}, true},
+		{"regex and equals", []FilterConfig{{Field: "action", Equals: "opened", Regex: "open"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", Filters: tt.filters}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationSuccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		success     *SuccessConfig
+		expectError bool
+	}{
+		{"no rules", nil, false},
+		{"status codes", &SuccessConfig{StatusCodes: []int{200, 204}}, false},
+		{"body rules", &SuccessConfig{BodyContains: `"ok":true`, BodyNotContains: `"error"`}, false},
+		{"status code too low", &SuccessConfig{StatusCodes: []int{99}}, true},
+		{"status code too high", &SuccessConfig{StatusCodes: []int{600}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", Success: tt.success}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateServerConfigStartup(t *testing.T) {
+	for _, method := range []string{"", "HEAD", "OPTIONS"} {
+		server := &ServerConfig{Port: 8080, Startup: StartupConfig{ProbeMethod: method}}
+		if err := validateServerConfig(server); err != nil {
+			t.Errorf("Expected no error for probe_method %q but got: %v", method, err)
+		}
+	}
+
+	server := &ServerConfig{Port: 8080, Startup: StartupConfig{ProbeMethod: "GET"}}
+	if err := validateServerConfig(server); err == nil {
+		t.Errorf("Expected error for invalid probe_method")
+	}
+}
+
+func TestDuplicateDestinations(t *testing.T) {
+	endpoint := EndpointConfig{
+		Path: "/webhook",
+		Destinations: []DestinationConfig{
+			{URL: "https://example.com/a"},
+			{URL: "https://example.com/b"},
+			{URL: "https://example.com/a"},
+			{URL: "https://example.com/a"},
+			{Type: DestinationTypeWebSocket, WebSocket: &WebSocketConfig{Path: "/live"}},
+			{Type: DestinationTypeWebSocket, WebSocket: &WebSocketConfig{Path: "/live"}},
+		},
+	}
+
+	duplicates := endpoint.DuplicateDestinations()
+	expected := []string{"https://example.com/a", "websocket:/live"}
+	if len(duplicates) != len(expected) || duplicates[0] != expected[0] || duplicates[1] != expected[1] {
+		t.Errorf("Expected duplicates %v, got %v", expected, duplicates)
+	}
+
+	if duplicates := (EndpointConfig{Destinations: []DestinationConfig{{URL: "https://example.com/a"}}}).DuplicateDestinations(); len(duplicates) != 0 {
+		t.Errorf("Expected no duplicates, got %v", duplicates)
+	}
+}
+
+func TestLoadConfigPipelines(t *testing.T) {
+	configContent := `
+pipelines:
+  - name: "github-events"
+    max_delivery_duration: 30s
+    destinations:
+      - url: "https://example.com/events"
+      - type: "websocket"
+        websocket:
+          path: "/live/github"
+          token: "subscriber-token"
+
+endpoints:
+  - path: "/webhook/github/push"
+    pipeline: "github-events"
+  - path: "/webhook/github/issues"
+    pipeline: "github-events"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	for _, endpoint := range config.Endpoints {
+		if len(endpoint.Destinations) != 2 {
+			t.Fatalf("Expected 2 destinations for %s, got %d", endpoint.Path, len(endpoint.Destinations))
+		}
+		dest := endpoint.Destinations[0]
+		if dest.URL != "https://example.com/events" || dest.Method != DefaultMethod {
+			t.Errorf("Expected the pipeline destination with defaults for %s, got %+v", endpoint.Path, dest)
+		}
+		if dest.MaxDeliveryDuration != 30*time.Second {
+			t.Errorf("Expected inherited max delivery duration 30s, got %s", dest.MaxDeliveryDuration)
+		}
+	}
+}
+
+func TestResolvePipelinesErrors(t *testing.T) {
+	destinations := []DestinationConfig{{URL: "https://example.com/events"}}
+
+	tests := []struct {
+		name      string
+		pipelines []PipelineConfig
+		endpoint  EndpointConfig
+	}{
+		{"invalid name", []PipelineConfig{{Name: "github events", Destinations: destinations}}, EndpointConfig{Path: "/webhook"}},
+		{"duplicate name", []PipelineConfig{{Name: "events", Destinations: destinations}, {Name: "events", Destinations: destinations}}, EndpointConfig{Path: "/webhook"}},
+		{"no destinations", []PipelineConfig{{Name: "events"}}, EndpointConfig{Path: "/webhook"}},
+		{"unknown pipeline", nil, EndpointConfig{Path: "/webhook", Pipeline: "events"}},
+		{"endpoint destinations", []PipelineConfig{{Name: "events", Destinations: destinations}}, EndpointConfig{Path: "/webhook", Pipeline: "events", Destinations: destinations}},
+		{"endpoint max delivery duration", []PipelineConfig{{Name: "events", Destinations: destinations}}, EndpointConfig{Path: "/webhook", Pipeline: "events", MaxDeliveryDuration: time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Pipelines: tt.pipelines, Endpoints: []EndpointConfig{tt.endpoint}}
+			if err := resolvePipelines(config); err == nil {
+				t.Errorf("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestValidateEndpointCallbackURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		expectError bool
+	}{
+		{"", false},
+		{"https://tracker.example.com/receipts", false},
+		{"http://localhost:9000/receipts", false},
+		{"tracker.example.com/receipts", true},
+		{"ftp://tracker.example.com/receipts", true},
+	}
+
+	for _, tt := range tests {
+		endpoint := EndpointConfig{
+			Path:         "/webhook",
+			Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+			CallbackURL:  tt.url,
+		}
+		err := validateEndpointConfig(0, endpoint)
+		if tt.expectError && err == nil {
+			t.Errorf("Expected error for callback_url %q", tt.url)
+		}
+		if !tt.expectError && err != nil {
+			t.Errorf("Expected no error for callback_url %q but got: %v", tt.url, err)
+		}
+	}
+}
+
+func TestResolvePipelinesCallbackURL(t *testing.T) {
+	config := &Config{
+		Pipelines: []PipelineConfig{{
+			Name:         "events",
+			Destinations: []DestinationConfig{{URL: "https://example.com/events"}},
+			CallbackURL:  "https://tracker.example.com/receipts",
+		}},
+		Endpoints: []EndpointConfig{{Path: "/webhook", Pipeline: "events"}},
+	}
+	if err := resolvePipelines(config); err != nil {
+		t.Fatalf("Failed to resolve pipelines: %v", err)
+	}
+	if config.Endpoints[0].CallbackURL != "https://tracker.example.com/receipts" {
+		t.Errorf("Expected the pipeline callback_url, got %q", config.Endpoints[0].CallbackURL)
+	}
+
+	config.Endpoints = []EndpointConfig{{Path: "/webhook", Pipeline: "events", CallbackURL: "https://other.example.com"}}
+	if err := resolvePipelines(config); err == nil {
+		t.Errorf("Expected error for callback_url set with a pipeline")
+	}
+}
+
+func TestResolvePipelinesLabels(t *testing.T) {
+	config := &Config{
+		Pipelines: []PipelineConfig{{
+			Name:         "events",
+			Destinations: []DestinationConfig{{URL: "https://example.com/events"}},
+			Labels:       map[string]string{"team": "payments"},
+		}},
+		Endpoints: []EndpointConfig{{Path: "/webhook", Pipeline: "events", Labels: map[string]string{"team": "platform"}}},
+	}
+	if err := resolvePipelines(config); err == nil {
+		t.Errorf("Expected error for labels set with a pipeline")
+	}
+}
+
+func TestValidateDestinationChaos(t *testing.T) {
+	tests := []struct {
+		name        string
+		chaos       ChaosConfig
+		expectError bool
+	}{
+		{"valid rates", ChaosConfig{DelayRate: 0.1, MaxDelay: time.Second, DropRate: 0.05, DuplicateRate: 1}, false},
+		{"negative rate", ChaosConfig{DropRate: -0.1}, true},
+		{"rate above one", ChaosConfig{DuplicateRate: 1.5}, true},
+		{"negative max delay", ChaosConfig{DelayRate: 0.5, MaxDelay: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", Chaos: &tt.chaos}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBodySize int64
+		onOversize  string
+		expectError bool
+	}{
+		{"unbounded", 0, "", false},
+		{"dead letter", 1024, OversizeDeadLetter, false},
+		{"truncate", 1024, OversizeTruncate, false},
+		{"negative size", -1, "", true},
+		{"invalid behavior", 1024, "drop", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", MaxBodySize: tt.maxBodySize, OnOversize: tt.onOversize}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationSigning(t *testing.T) {
+	tests := []struct {
+		name        string
+		signing     SigningConfig
+		expectError bool
+	}{
+		{"current and previous", SigningConfig{Secrets: []string{"current", "previous"}, Header: DefaultSigningHeader}, false},
+		{"no secrets", SigningConfig{Header: DefaultSigningHeader}, true},
+		{"empty secret", SigningConfig{Secrets: []string{"current", ""}, Header: DefaultSigningHeader}, true},
+		{"received timestamp", SigningConfig{Secrets: []string{"current"}, TimestampSource: SigningTimestampReceived, MaxAge: 5 * time.Minute, Skew: -30 * time.Second}, false},
+		{"invalid timestamp source", SigningConfig{Secrets: []string{"current"}, TimestampSource: "sent"}, true},
+		{"max age with attempt timestamps", SigningConfig{Secrets: []string{"current"}, TimestampSource: SigningTimestampAttempt, MaxAge: time.Minute}, true},
+		{"negative max age", SigningConfig{Secrets: []string{"current"}, TimestampSource: SigningTimestampReceived, MaxAge: -time.Minute}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", Signing: &tt.signing}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateEndpointPreviousSecrets(t *testing.T) {
+	tests := []struct {
+		name        string
+		endpoint    EndpointConfig
+		expectError bool
+	}{
+		{"rotation", EndpointConfig{Provider: ProviderGitHub, Secret: "current", PreviousSecrets: []string{"previous"}}, false},
+		{"without provider", EndpointConfig{PreviousSecrets: []string{"previous"}}, true},
+		{"empty secret", EndpointConfig{Provider: ProviderGitHub, Secret: "current", PreviousSecrets: []string{""}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.endpoint.Path = "/webhook"
+			tt.endpoint.Destinations = []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}}
+			err := validateEndpointConfig(0, tt.endpoint)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	secrets := EndpointConfig{Secret: "current", PreviousSecrets: []string{"previous"}}.Secrets()
+	if len(secrets) != 2 || secrets[0] != "current" || secrets[1] != "previous" {
+		t.Errorf("Expected current then previous secret, got %v", secrets)
+	}
+}
+
+func TestValidateServerConfigAdmin(t *testing.T) {
+	tests := []struct {
+		name        string
+		admin       AdminConfig
+		expectError bool
+	}{
+		{"defaults", AdminConfig{Burst: DefaultAdminBurst, Interval: DefaultAdminInterval}, false},
+		{"confirm token", AdminConfig{Burst: 1, Interval: time.Hour, ConfirmToken: "yes-really"}, false},
+		{"negative burst", AdminConfig{Burst: -1, Interval: time.Minute}, true},
+		{"negative interval", AdminConfig{Burst: 1, Interval: -time.Minute}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServerConfig(&ServerConfig{Port: 8080, Admin: tt.admin})
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateServerConfigMonitor(t *testing.T) {
+	tests := []struct {
+		name        string
+		monitor     MonitorConfig
+		expectError bool
+	}{
+		{"defaults", MonitorConfig{Enabled: true, Interval: DefaultMonitorInterval, Budget: DefaultMonitorBudget}, false},
+		{"budget equal to interval", MonitorConfig{Enabled: true, Interval: time.Second, Budget: time.Second, Probe: true}, false},
+		{"budget over interval", MonitorConfig{Enabled: true, Interval: time.Second, Budget: 2 * time.Second}, true},
+		{"negative interval", MonitorConfig{Interval: -time.Second}, true},
+		{"negative budget", MonitorConfig{Interval: time.Second, Budget: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServerConfig(&ServerConfig{Port: 8080, Monitor: tt.monitor})
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateServerConfigCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression CompressionConfig
+		expectError bool
+	}{
+		{"defaults", CompressionConfig{Enabled: true, Level: DefaultCompressionLevel, MinSize: DefaultCompressionMinSize}, false},
+		{"best compression", CompressionConfig{Enabled: true, Level: 9, MinSize: 1}, false},
+		{"level too high", CompressionConfig{Enabled: true, Level: 10}, true},
+		{"negative level", CompressionConfig{Enabled: true, Level: -1}, true},
+		{"negative min size", CompressionConfig{Enabled: true, Level: 1, MinSize: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServerConfig(&ServerConfig{Port: 8080, Compression: tt.compression})
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateServerConfigUpgrade(t *testing.T) {
+	tests := []struct {
+		name        string
+		upgrade     UpgradeConfig
+		expectError bool
+	}{
+		{"defaults", UpgradeConfig{Enabled: true, ReadyTimeout: DefaultUpgradeReadyTimeout, DrainTimeout: DefaultUpgradeDrainTimeout}, false},
+		{"pid file", UpgradeConfig{Enabled: true, ReadyTimeout: time.Second, DrainTimeout: time.Minute, PIDFile: "/run/webhook-proxy.pid"}, false},
+		{"negative ready timeout", UpgradeConfig{Enabled: true, ReadyTimeout: -time.Second}, true},
+		{"negative drain timeout", UpgradeConfig{Enabled: true, DrainTimeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServerConfig(&ServerConfig{Port: 8080, Upgrade: tt.upgrade})
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestEndpointCompressesResponse(t *testing.T) {
+	enabled, disabled := true, false
+	if !(EndpointConfig{}).CompressesResponse(true) || (EndpointConfig{}).CompressesResponse(false) {
+		t.Errorf("Expected endpoints to follow the server by default")
+	}
+	if !(EndpointConfig{CompressResponse: &enabled}).CompressesResponse(false) {
+		t.Errorf("Expected the endpoint to enable compression")
+	}
+	if (EndpointConfig{CompressResponse: &disabled}).CompressesResponse(true) {
+		t.Errorf("Expected the endpoint to disable compression")
+	}
+}
+
+func TestValidateEndpointQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		quota       QuotaConfig
+		expectError bool
+	}{
+		{"daily", QuotaConfig{Daily: 1000, OnExceed: QuotaReject}, false},
+		{"monthly queue", QuotaConfig{Monthly: 10000, OnExceed: QuotaQueue, QueueSize: 100}, false},
+		{"no limit", QuotaConfig{OnExceed: QuotaLogOnly}, true},
+		{"negative limit", QuotaConfig{Daily: -1}, true},
+		{"invalid behavior", QuotaConfig{Daily: 1000, OnExceed: "drop"}, true},
+		{"negative queue size", QuotaConfig{Daily: 1000, OnExceed: QuotaQueue, QueueSize: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := EndpointConfig{
+				Path:         "/webhook",
+				Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+				Quota:        &tt.quota,
+			}
+			err := validateEndpointConfig(0, endpoint)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		cache       CacheConfig
+		expectError bool
+	}{
+		{"GET", "GET", CacheConfig{TTL: time.Minute}, false},
+		{"POST", "POST", CacheConfig{TTL: time.Minute}, true},
+		{"no ttl", "GET", CacheConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/lookup?id=1", Method: tt.method, Cache: &tt.cache}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateServerConfigCrashReports(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectError bool
+	}{
+		{"disabled", "", false},
+		{"https", "https://crashes.example.com/reports", false},
+		{"not a URL", "crashes", true},
+		{"unsupported scheme", "ftp://crashes.example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServerConfig(&ServerConfig{Port: 8080, CrashReports: CrashReportConfig{URL: tt.url}})
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationDNSOutage(t *testing.T) {
+	tests := []struct {
+		name        string
+		destType    string
+		dnsOutage   DNSOutageConfig
+		expectError bool
+	}{
+		{"valid", DestinationTypeHTTP, DNSOutageConfig{Threshold: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}, false},
+		{"threshold below 1", DestinationTypeHTTP, DNSOutageConfig{Threshold: 0, InitialBackoff: time.Second, MaxBackoff: time.Minute}, true},
+		{"no initial backoff", DestinationTypeHTTP, DNSOutageConfig{Threshold: 3, MaxBackoff: time.Minute}, true},
+		{"max backoff below initial backoff", DestinationTypeHTTP, DNSOutageConfig{Threshold: 3, InitialBackoff: time.Minute, MaxBackoff: time.Second}, true},
+		{"sink destination", DestinationTypeDatabase, DNSOutageConfig{Threshold: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{
+				Type:      tt.destType,
+				URL:       "https://example.com/webhook",
+				Method:    "POST",
+				DNSOutage: &tt.dnsOutage,
+				Database:  &DatabaseConfig{Driver: "postgres", DSN: "postgres://localhost/db", Table: "webhooks"},
+			}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetDNSOutageDefaultValues(t *testing.T) {
+	d := DNSOutageConfig{}
+	setDNSOutageDefaultValues(&d)
+	if d.Threshold != DefaultDNSOutageThreshold || d.InitialBackoff != DefaultDNSOutageInitialBackoff || d.MaxBackoff != DefaultDNSOutageMaxBackoff {
+		t.Errorf("Expected default values, got %+v", d)
+	}
+
+	// The max backoff is never below an explicit initial backoff
+	d = DNSOutageConfig{InitialBackoff: 10 * time.Minute}
+	setDNSOutageDefaultValues(&d)
+	if d.MaxBackoff != 10*time.Minute {
+		t.Errorf("Expected max backoff of 10m, got %s", d.MaxBackoff)
+	}
+}
+
+func TestLoadConfigLocalAddress(t *testing.T) {
+	configContent := `
+outbound:
+  local_address: "127.0.0.1"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/inherited"
+      - url: "https://example.com/overridden"
+        local_address: "::1"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dests := config.Endpoints[0].Destinations
+	if dests[0].LocalAddress != "127.0.0.1" {
+		t.Errorf("Expected inherited local address 127.0.0.1, got %s", dests[0].LocalAddress)
+	}
+	if dests[1].LocalAddress != "::1" {
+		t.Errorf("Expected local address ::1, got %s", dests[1].LocalAddress)
+	}
+}
+
+func TestValidateLocalAddress(t *testing.T) {
+	tests := []struct {
+		name         string
+		localAddress string
+		expectError  bool
+	}{
+		{"IPv4 address", "10.0.0.5", false},
+		{"IPv6 address", "fd00::5", false},
+		{"interface", "lo", false},
+		{"unknown interface", "no-such-interface0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.localAddress == "lo" {
+				if _, err := net.InterfaceByName("lo"); err != nil {
+					t.Skip("No lo interface on this host")
+				}
+			}
+
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", LocalAddress: tt.localAddress}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	config := &Config{
+		Outbound:  OutboundConfig{LocalAddress: "no-such-interface0"},
+		Endpoints: []EndpointConfig{{Path: "/webhook", Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}}}},
+	}
+	setDefaultValues(config)
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected error for an unknown outbound.local_address")
+	}
+}
+
+func TestValidateProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		dest        DestinationConfig
+		expectError bool
+	}{
+		{"default", DestinationConfig{URL: "https://example.com/webhook"}, false},
+		{"auto", DestinationConfig{URL: "https://example.com/webhook", Protocol: ProtocolAuto}, false},
+		{"http1", DestinationConfig{URL: "https://example.com/webhook", Protocol: ProtocolHTTP1}, false},
+		{"h2c", DestinationConfig{URL: "http://billing.internal:8080/webhook", Protocol: ProtocolH2C}, false},
+		{"h2c over https", DestinationConfig{URL: "https://example.com/webhook", Protocol: ProtocolH2C}, true},
+		{"h2c through a proxy", DestinationConfig{
+			URL:      "http://billing.internal:8080/webhook",
+			Protocol: ProtocolH2C,
+			Proxy:    &ProxyConfig{URL: "http://egress.internal:3128"},
+		}, true},
+		{"unknown", DestinationConfig{URL: "https://example.com/webhook", Protocol: "http3"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.dest.Method = "POST"
+			err := validateDestinationConfig(0, 0, tt.dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDial(t *testing.T) {
+	configContent := `
+outbound:
+  dial:
+    ip_family: "prefer_ipv4"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/inherited"
+      - url: "https://example.com/overridden"
+        dial:
+          ip_family: "ipv6"
+          fallback_delay: 50ms
+          timeout: 5s
+      - type: "websocket"
+        websocket:
+          path: "/live/test"
+          token: "subscriber-token"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dests := config.Endpoints[0].Destinations
+	inherited := dests[0].Dial
+	if inherited == nil || inherited.IPFamily != IPFamilyPreferIPv4 {
+		t.Fatalf("Expected the outbound dial, got %+v", inherited)
+	}
+	if inherited.FallbackDelay != DefaultDialFallbackDelay || inherited.Timeout != DefaultDialTimeout {
+		t.Errorf("Expected default dial delays, got %+v", inherited)
+	}
+	overridden := dests[1].Dial
+	if overridden.IPFamily != IPFamilyIPv6 || overridden.FallbackDelay != 50*time.Millisecond || overridden.Timeout != 5*time.Second {
+		t.Errorf("Expected the destination's dial, got %+v", overridden)
+	}
+	if dests[2].Dial != nil {
+		t.Errorf("Expected no dial for a websocket destination, got %+v", dests[2].Dial)
+	}
+}
+
+func TestValidateDialConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		dial        DialConfig
+		expectError bool
+	}{
+		{"defaults", DialConfig{}, false},
+		{"ipv6 only", DialConfig{IPFamily: IPFamilyIPv6}, false},
+		{"prefer ipv4", DialConfig{IPFamily: IPFamilyPreferIPv4, FallbackDelay: 100 * time.Millisecond}, false},
+		{"unknown family", DialConfig{IPFamily: "ipv5"}, true},
+		{"negative fallback delay", DialConfig{FallbackDelay: -time.Second}, true},
+		{"negative timeout", DialConfig{Timeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDialConfig(&tt.dial)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	// Sinks do not dial
+	dest := DestinationConfig{Type: DestinationTypeS3, Dial: &DialConfig{}}
+	if err := validateDestinationConfig(0, 0, dest); err == nil {
+		t.Errorf("Expected error for a dial on an s3 destination")
+	}
+}
+
+func TestLoadConfigConnectionPool(t *testing.T) {
+	configContent := `
+outbound:
+  connection_pool:
+    max_conns_per_host: 64
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/inherited"
+      - url: "https://example.com/overridden"
+        connection_pool:
+          max_idle_conns_per_host: 8
+          idle_conn_timeout: 30s
+          disable_keep_alives: true
+      - type: "websocket"
+        websocket:
+          path: "/live/test"
+          token: "subscriber-token"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dests := config.Endpoints[0].Destinations
+	inherited := dests[0].ConnectionPool
+	if inherited == nil || inherited.MaxConnsPerHost != 64 {
+		t.Fatalf("Expected the outbound connection pool, got %+v", inherited)
+	}
+	if inherited.MaxIdleConns != DefaultPoolMaxIdleConns || inherited.MaxIdleConnsPerHost != DefaultPoolMaxIdleConnsPerHost ||
+		inherited.IdleConnTimeout != DefaultPoolIdleConnTimeout || inherited.KeepAlive != DefaultPoolKeepAlive {
+		t.Errorf("Expected default pool settings, got %+v", inherited)
+	}
+	overridden := dests[1].ConnectionPool
+	if overridden.MaxConnsPerHost != 0 || overridden.MaxIdleConnsPerHost != 8 || overridden.IdleConnTimeout != 30*time.Second || !overridden.DisableKeepAlives {
+		t.Errorf("Expected the destination's connection pool, got %+v", overridden)
+	}
+	if dests[2].ConnectionPool != nil {
+		t.Errorf("Expected no connection pool for a websocket destination, got %+v", dests[2].ConnectionPool)
+	}
+}
+
+func TestLoadConfigLabels(t *testing.T) {
+	configContent := `
+pipelines:
+  - name: "billing"
+    labels:
+      team: "payments"
+    destinations:
+      - url: "https://billing.example.com/webhook"
+endpoints:
+  - path: "/webhook/github"
+    labels:
+      team: "platform"
+      service: "ci"
+    destinations:
+      - url: "https://ci.example.com/webhook"
+      - url: "https://audit.example.com/webhook"
+        labels:
+          team: "security"
+  - path: "/webhook/stripe"
+    pipeline: "billing"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dests := config.Endpoints[0].Destinations
+	if !reflect.DeepEqual(dests[0].Labels, map[string]string{"team": "platform", "service": "ci"}) {
+		t.Errorf("Expected the endpoint's labels, got %v", dests[0].Labels)
+	}
+	if !reflect.DeepEqual(dests[1].Labels, map[string]string{"team": "security", "service": "ci"}) {
+		t.Errorf("Expected the destination to override the endpoint's labels, got %v", dests[1].Labels)
+	}
+	if config.Endpoints[0].Labels["team"] != "platform" {
+		t.Errorf("Expected the endpoint's labels to be unchanged, got %v", config.Endpoints[0].Labels)
+	}
+
+	pipeline := config.Endpoints[1]
+	if pipeline.Labels["team"] != "payments" || pipeline.Destinations[0].Labels["team"] != "payments" {
+		t.Errorf("Expected the pipeline's labels, got %v and %v", pipeline.Labels, pipeline.Destinations[0].Labels)
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	tooMany := make(map[string]string, MaxLabels+1)
+	for i := 0; i <= MaxLabels; i++ {
+		tooMany[fmt.Sprintf("label_%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		expectError bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]string{"team": "platform", "cost_center": "42", "_internal": ""}, false},
+		{"uppercase key", map[string]string{"Team": "platform"}, true},
+		{"dashed key", map[string]string{"cost-center": "42"}, true},
+		{"leading digit", map[string]string{"1team": "platform"}, true},
+		{"long value", map[string]string{"team": strings.Repeat("a", MaxLabelValueBytes+1)}, true},
+		{"too many", tooMany, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", Labels: tt.labels}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	endpoint := EndpointConfig{
+		Path:         "/webhook/test",
+		Labels:       map[string]string{"Team": "platform"},
+		Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+	}
+	if err := validateEndpointConfig(0, endpoint); err == nil {
+		t.Errorf("Expected an error for invalid endpoint labels")
+	}
+}
+
+func TestValidateConnectionPoolConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		pool        ConnectionPoolConfig
+		expectError bool
+	}{
+		{"defaults", ConnectionPoolConfig{}, false},
+		{"bounded", ConnectionPoolConfig{MaxIdleConns: 200, MaxIdleConnsPerHost: 50, MaxConnsPerHost: 50, IdleConnTimeout: time.Minute}, false},
+		{"keep-alive probes disabled", ConnectionPoolConfig{KeepAlive: -1}, false},
+		{"negative max idle conns", ConnectionPoolConfig{MaxIdleConns: -1}, true},
+		{"negative max idle conns per host", ConnectionPoolConfig{MaxIdleConnsPerHost: -1}, true},
+		{"negative max conns per host", ConnectionPoolConfig{MaxConnsPerHost: -1}, true},
+		{"negative idle conn timeout", ConnectionPoolConfig{IdleConnTimeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConnectionPoolConfig(&tt.pool)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	// Sinks do not keep connections
+	dest := DestinationConfig{Type: DestinationTypeS3, ConnectionPool: &ConnectionPoolConfig{}}
+	if err := validateDestinationConfig(0, 0, dest); err == nil {
+		t.Errorf("Expected error for a connection pool on an s3 destination")
+	}
+}
+
+func TestResolveSecretReference(t *testing.T) {
+	t.Setenv("TEST_PROXY_PASSWORD", "from-env")
+	secretFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    string
+		expectError bool
+	}{
+		{"literal", "s3cret", "s3cret", false},
+		{"environment variable", "env:TEST_PROXY_PASSWORD", "from-env", false},
+		{"unset environment variable", "env:TEST_PROXY_UNSET", "", true},
+		{"file", "file:" + secretFile, "from-file", false},
+		{"missing file", "file:" + secretFile + ".missing", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := resolveSecretReference(tt.value)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestLoadConfigProxy(t *testing.T) {
+	t.Setenv("TEST_PROXY_PASSWORD", "s3cret")
+
+	configContent := `
+outbound:
+  proxy:
+    url: "socks5h://jump.internal:1080"
+    username: "webhooks"
+    password: "env:TEST_PROXY_PASSWORD"
+pipelines:
+  - name: "shared"
+    destinations:
+      - url: "https://example.com/shared"
+        proxy:
+          url: "http://egress.internal:3128"
+          username: "env:TEST_PROXY_PASSWORD"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/inherited"
+  - path: "/webhook/a"
+    pipeline: "shared"
+  - path: "/webhook/b"
+    pipeline: "shared"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	inherited := config.Endpoints[0].Destinations[0].Proxy
+	if inherited == nil || inherited.URL != "socks5h://jump.internal:1080" || inherited.Password != "s3cret" {
+		t.Errorf("Expected the outbound proxy with its resolved password, got %+v", inherited)
+	}
+
+	// The references of a pipeline's destinations are resolved for each endpoint
+	for _, endpoint := range config.Endpoints[1:] {
+		proxy := endpoint.Destinations[0].Proxy
+		if proxy == nil || proxy.URL != "http://egress.internal:3128" || proxy.Username != "s3cret" {
+			t.Errorf("Expected the destination proxy with its resolved username, got %+v", proxy)
+		}
+	}
+
+	// Unresolvable references fail the loading
+	configContent = `
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+        proxy:
+          url: "socks5://jump.internal:1080"
+          username: "webhooks"
+          password: "env:TEST_PROXY_UNSET"
+`
+	tmpFileName = createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	if _, err := LoadConfig(tmpFileName); err == nil {
+		t.Errorf("Expected error for an unset environment variable")
+	}
+}
+
+func TestValidateDestinationProxy(t *testing.T) {
+	tests := []struct {
+		name        string
+		destType    string
+		proxy       ProxyConfig
+		expectError bool
+	}{
+		{"http proxy", DestinationTypeHTTP, ProxyConfig{URL: "http://egress.internal:3128"}, false},
+		{"socks5 proxy with credentials", DestinationTypeHTTP, ProxyConfig{URL: "socks5://jump.internal:1080", Username: "u", Password: "p"}, false},
+		{"credentials in url", DestinationTypeHTTP, ProxyConfig{URL: "socks5h://u:p@jump.internal:1080"}, false},
+		{"credentials in url and fields", DestinationTypeHTTP, ProxyConfig{URL: "socks5://u:p@jump.internal:1080", Username: "u"}, true},
+		{"password without username", DestinationTypeHTTP, ProxyConfig{URL: "socks5://jump.internal:1080", Password: "p"}, true},
+		{"unsupported scheme", DestinationTypeHTTP, ProxyConfig{URL: "ftp://jump.internal:21"}, true},
+		{"no host", DestinationTypeHTTP, ProxyConfig{URL: "socks5://"}, true},
+		{"sink destination", DestinationTypeDatabase, ProxyConfig{URL: "http://egress.internal:3128"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{
+				Type:     tt.destType,
+				URL:      "https://example.com/webhook",
+				Method:   "POST",
+				Proxy:    &tt.proxy,
+				Database: &DatabaseConfig{Driver: "postgres", DSN: "postgres://localhost/db", Table: "webhooks"},
+			}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAlerts(t *testing.T) {
+	t.Setenv("TEST_PAGERDUTY_KEY", "routing-key")
+
+	configContent := `
+alerts:
+  - name: "destination-down"
+    event: "destination_paused"
+    for: 5m
+    channel:
+      type: "pagerduty"
+      routing_key: "env:TEST_PAGERDUTY_KEY"
+  - name: "failures"
+    event: "delivery_failed"
+    threshold: 10
+    channel:
+      type: "slack"
+      url: "https://hooks.slack.com/services/T000/B000/XXX"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	paused := config.Alerts[0]
+	if paused.Channel.RoutingKey != "routing-key" {
+		t.Errorf("Expected the resolved routing key, got %s", paused.Channel.RoutingKey)
+	}
+	if paused.Channel.URL != DefaultPagerDutyURL {
+		t.Errorf("Expected the PagerDuty URL by default, got %s", paused.Channel.URL)
+	}
+	if paused.Threshold != 1 || paused.Window != DefaultAlertWindow {
+		t.Errorf("Expected the default threshold and window, got %d and %v", paused.Threshold, paused.Window)
+	}
+	if config.Alerts[1].Threshold != 10 {
+		t.Errorf("Expected threshold 10, got %d", config.Alerts[1].Threshold)
+	}
+}
+
+func TestValidateAlertConfig(t *testing.T) {
+	slack := AlertChannelConfig{Type: AlertChannelSlack, URL: "https://hooks.slack.com/services/T000/B000/XXX"}
+	tests := []struct {
+		name        string
+		alert       AlertConfig
+		expectError bool
+	}{
+		{"paused alert", AlertConfig{Name: "a", Event: AlertEventDestinationPaused, For: time.Minute, Threshold: 1, Window: time.Minute, Channel: slack}, false},
+		{"failure alert", AlertConfig{Name: "a", Event: AlertEventDeliveryFailed, Threshold: 5, Window: time.Minute, Channel: slack}, false},
+		{"pagerduty channel", AlertConfig{Name: "a", Event: AlertEventQueueHighWater, Threshold: 1, Window: time.Minute, Channel: AlertChannelConfig{Type: AlertChannelPagerDuty, URL: DefaultPagerDutyURL, RoutingKey: "key"}}, false},
+		{"http channel", AlertConfig{Name: "a", Event: AlertEventQueueHighWater, Threshold: 1, Window: time.Minute, Channel: AlertChannelConfig{Type: AlertChannelHTTP, URL: "http://alerts.internal/hook"}}, false},
+		{"missing name", AlertConfig{Event: AlertEventDeliveryFailed, Threshold: 1, Window: time.Minute, Channel: slack}, true},
+		{"unknown event", AlertConfig{Name: "a", Event: "destination_resumed", Threshold: 1, Window: time.Minute, Channel: slack}, true},
+		{"for on a counted event", AlertConfig{Name: "a", Event: AlertEventDeliveryFailed, For: time.Minute, Threshold: 1, Window: time.Minute, Channel: slack}, true},
+		{"negative threshold", AlertConfig{Name: "a", Event: AlertEventDeliveryFailed, Threshold: -1, Window: time.Minute, Channel: slack}, true},
+		{"negative window", AlertConfig{Name: "a", Event: AlertEventDeliveryFailed, Threshold: 1, Window: -time.Minute, Channel: slack}, true},
+		{"unknown channel", AlertConfig{Name: "a", Event: AlertEventDeliveryFailed, Threshold: 1, Window: time.Minute, Channel: AlertChannelConfig{Type: "email", URL: "https://example.com"}}, true},
+		{"slack without url", AlertConfig{Name: "a", Event: AlertEventDeliveryFailed, Threshold: 1, Window: time.Minute, Channel: AlertChannelConfig{Type: AlertChannelSlack}}, true},
+		{"pagerduty without routing key", AlertConfig{Name: "a", Event: AlertEventDeliveryFailed, Threshold: 1, Window: time.Minute, Channel: AlertChannelConfig{Type: AlertChannelPagerDuty, URL: DefaultPagerDutyURL}}, true},
+		{"invalid url", AlertConfig{Name: "a", Event: AlertEventDeliveryFailed, Threshold: 1, Window: time.Minute, Channel: AlertChannelConfig{Type: AlertChannelHTTP, URL: "ftp://alerts.internal"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlertConfig(0, &tt.alert)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigStaticEndpoints(t *testing.T) {
+	configContent := `
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+static_endpoints:
+  - path: "/robots.txt"
+    body: "User-agent: *\nDisallow: /\n"
+  - path: "/webhook/test"
+    method: "put"
+    status_code: 204
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	robots := config.StaticEndpoints[0]
+	if robots.Method != "GET" || robots.StatusCode != 200 {
+		t.Errorf("Expected GET and 200 by default, got %s and %d", robots.Method, robots.StatusCode)
+	}
+	if config.StaticEndpoints[1].Method != "PUT" {
+		t.Errorf("Expected the method in upper case, got %s", config.StaticEndpoints[1].Method)
+	}
+}
+
+func TestValidateStaticEndpoints(t *testing.T) {
+	tests := []struct {
+		name        string
+		static      StaticEndpointConfig
+		expectError bool
+	}{
+		{"valid", StaticEndpointConfig{Path: "/", Method: "GET", StatusCode: 200, Body: "OK"}, false},
+		{"webhook path with another method", StaticEndpointConfig{Path: "/webhook", Method: "GET", StatusCode: 200}, false},
+		{"no path", StaticEndpointConfig{Method: "GET", StatusCode: 200}, true},
+		{"relative path", StaticEndpointConfig{Path: "robots.txt", Method: "GET", StatusCode: 200}, true},
+		{"invalid method", StaticEndpointConfig{Path: "/", Method: "FETCH", StatusCode: 200}, true},
+		{"invalid status code", StaticEndpointConfig{Path: "/", Method: "GET", StatusCode: 99}, true},
+		{"webhook route", StaticEndpointConfig{Path: "/webhook", Method: "POST", StatusCode: 200}, true},
+		{"system route", StaticEndpointConfig{Path: "/health", Method: "GET", StatusCode: 200}, true},
+		{"head of a system route", StaticEndpointConfig{Path: "/metrics", Method: "HEAD", StatusCode: 200}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Server:          ServerConfig{Port: 8080},
+				Logging:         LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints:       []EndpointConfig{{Path: "/webhook", Destinations: []DestinationConfig{{URL: "https://example.com", Method: "POST"}}}},
+				StaticEndpoints: []StaticEndpointConfig{tt.static},
+			}
+			err := validateConfig(config)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	// Two static endpoints cannot share a route, GET also serving HEAD
+	config := &Config{
+		Server:    ServerConfig{Port: 8080},
+		Logging:   LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+		Endpoints: []EndpointConfig{{Path: "/webhook", Destinations: []DestinationConfig{{URL: "https://example.com", Method: "POST"}}}},
+		StaticEndpoints: []StaticEndpointConfig{
+			{Path: "/", Method: "GET", StatusCode: 200},
+			{Path: "/", Method: "HEAD", StatusCode: 200},
+		},
+	}
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected error for a duplicate route but got none")
+	}
+}
+
+func TestValidateEndpointCharset(t *testing.T) {
+	tests := []struct {
+		fallback    string
+		expectError bool
+	}{
+		{"iso-8859-1", false},
+		{"windows-1252", false},
+		{"klingon", true},
+	}
+
+	for _, tt := range tests {
+		endpoint := EndpointConfig{
+			Path:         "/webhook",
+			Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+			Charset:      &CharsetConfig{Normalize: true, Fallback: tt.fallback},
+		}
+		err := validateEndpointConfig(0, endpoint)
+		if tt.expectError && err == nil {
+			t.Errorf("Expected error for charset.fallback %q", tt.fallback)
+		}
+		if !tt.expectError && err != nil {
+			t.Errorf("Expected no error for charset.fallback %q but got: %v", tt.fallback, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`
+endpoints:
+  - path: "/webhook"
+    destinations:
+      - url: "https://example.com/webhook"
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.Server.Port != 8080 {
+		t.Errorf("Expected default port 8080, got %d", config.Server.Port)
+	}
+	if config.Endpoints[0].Destinations[0].Method != "POST" {
+		t.Errorf("Expected default method POST, got %s", config.Endpoints[0].Destinations[0].Method)
+	}
+
+	if _, err := Parse([]byte("endpoints: []")); err == nil {
+		t.Errorf("Expected error for a configuration without endpoints")
+	}
+}
+
+func TestValidateEndpointOnNoMatch(t *testing.T) {
+	tests := []struct {
+		onNoMatch   string
+		deadLetters string
+		expectError bool
+	}{
+		{"", "", false},
+		{NoMatchAccept, "", false},
+		{NoMatchReject, "", false},
+		{NoMatchDeadLetter, "/var/lib/webhook-proxy/dead-letters", false},
+		{NoMatchDeadLetter, "", true},
+		{"drop", "", true},
+	}
+
+	for _, tt := range tests {
+		config := &Config{
+			Server:      ServerConfig{Port: 8080},
+			Logging:     LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+			DeadLetters: DeadLetterConfig{Directory: tt.deadLetters},
+			Endpoints: []EndpointConfig{{
+				Path:         "/webhook",
+				Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+				OnNoMatch:    tt.onNoMatch,
+			}},
+		}
+		err := validateConfig(config)
+		if tt.expectError && err == nil {
+			t.Errorf("Expected error for on_no_match %q with dead_letters.directory %q", tt.onNoMatch, tt.deadLetters)
+		}
+		if !tt.expectError && err != nil {
+			t.Errorf("Expected no error for on_no_match %q but got: %v", tt.onNoMatch, err)
+		}
+	}
+}
+
+func TestValidateEndpointDedup(t *testing.T) {
+	tests := []struct {
+		name        string
+		dedup       DedupConfig
+		expectError bool
+	}{
+		{"header", DedupConfig{Header: "X-GitHub-Delivery", TTL: time.Hour, MaxKeys: 1000}, false},
+		{"body hash", DedupConfig{TTL: time.Hour, MaxKeys: 1000}, false},
+		{"negative ttl", DedupConfig{TTL: -time.Second, MaxKeys: 1000}, true},
+		{"negative max keys", DedupConfig{TTL: time.Hour, MaxKeys: -1}, true},
+	}
+
+	for _, tt := range tests {
+		dedup := tt.dedup
+		err := validateEndpointConfig(0, EndpointConfig{
+			Path:         "/webhook",
+			Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+			Dedup:        &dedup,
+		})
+		if tt.expectError && err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+		if !tt.expectError && err != nil {
+			t.Errorf("%s: expected no error but got: %v", tt.name, err)
+		}
+	}
+}
+
+func TestDedupDefaults(t *testing.T) {
+	config := &Config{Endpoints: []EndpointConfig{{Path: "/webhook", Dedup: &DedupConfig{Header: "X-GitHub-Delivery"}}}}
+	setDefaultValues(config)
+
+	if config.Endpoints[0].Dedup.TTL != DefaultDedupTTL {
+		t.Errorf("Expected default dedup ttl %s, got %s", DefaultDedupTTL, config.Endpoints[0].Dedup.TTL)
+	}
+	if config.Endpoints[0].Dedup.MaxKeys != DefaultDedupMaxKeys {
+		t.Errorf("Expected default dedup max keys %d, got %d", DefaultDedupMaxKeys, config.Endpoints[0].Dedup.MaxKeys)
+	}
+}
+
+func TestValidateEndpointFieldFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    string
+		field       string
+		expectError bool
+	}{
+		{"github action", ProviderGitHub, GitHubFieldAction, false},
+		{"stripe event", ProviderStripe, ProviderFieldEvent, false},
+		{"stripe livemode", ProviderStripe, StripeFieldLivemode, false},
+		{"unknown field", ProviderStripe, GitHubFieldRepo, true},
+		{"no provider", "", ProviderFieldEvent, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := EndpointConfig{
+				Path:     "/webhook",
+				Provider: tt.provider,
+				Secret:   "s3cret",
+				Destinations: []DestinationConfig{{
+					URL:     "https://example.com/webhook",
+					Method:  "POST",
+					Filters: []FilterConfig{{Field: tt.field}},
+				}},
+			}
+			err := validateEndpointConfig(0, endpoint)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateEndpointAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    string
+		auth        AuthConfig
+		expectError bool
+	}{
+		{"github style", "", AuthConfig{Style: AuthStyleGitHub, Algorithm: AuthAlgorithmSHA1, Header: "X-Signature", Secret: "s3cret"}, false},
+		{"stripe style", "", AuthConfig{Style: AuthStyleStripe, Algorithm: AuthAlgorithmSHA256, Header: "X-Signature", Secret: "s3cret"}, false},
+		{"token style", "", AuthConfig{Style: AuthStyleToken, Header: "X-Token", Secret: "s3cret"}, false},
+		{"unknown style", "", AuthConfig{Style: "basic", Header: "X-Signature", Secret: "s3cret"}, true},
+		{"unknown algorithm", "", AuthConfig{Style: AuthStyleGitHub, Algorithm: "md5", Header: "X-Signature", Secret: "s3cret"}, true},
+		{"token algorithm", "", AuthConfig{Style: AuthStyleToken, Algorithm: AuthAlgorithmSHA256, Header: "X-Token", Secret: "s3cret"}, true},
+		{"missing header", "", AuthConfig{Style: AuthStyleToken, Secret: "s3cret"}, true},
+		{"missing secret", "", AuthConfig{Style: AuthStyleToken, Header: "X-Token"}, true},
+		{"previous secrets", "", AuthConfig{Style: AuthStyleToken, Header: "X-Token", Secret: "s3cret", PreviousSecrets: []string{"old"}}, false},
+		{"empty previous secret", "", AuthConfig{Style: AuthStyleToken, Header: "X-Token", Secret: "s3cret", PreviousSecrets: []string{""}}, true},
+		{"with provider", ProviderGitHub, AuthConfig{Style: AuthStyleToken, Header: "X-Token", Secret: "s3cret"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := tt.auth
+			endpoint := EndpointConfig{
+				Path:         "/webhook",
+				Provider:     tt.provider,
+				Secret:       "s3cret",
+				Auth:         &auth,
+				Destinations: []DestinationConfig{{URL: "https://example.com/webhook", Method: "POST"}},
+			}
+			err := validateEndpointConfig(0, endpoint)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAuthDefaults(t *testing.T) {
+	t.Setenv("TEST_AUTH_SECRET", "from-env")
+	t.Setenv("TEST_AUTH_PREVIOUS_SECRET", "previous-from-env")
+	configContent := `
+endpoints:
+  - path: "/webhook/github-style"
+    auth:
+      algorithm: "sha1"
+      secret: "env:TEST_AUTH_SECRET"
+      previous_secrets:
+        - "env:TEST_AUTH_PREVIOUS_SECRET"
+    destinations:
+      - url: "https://example.com/webhook"
+  - path: "/webhook/stripe-style"
+    auth:
+      style: "stripe"
+      secret: "s3cret"
+    destinations:
+      - url: "https://example.com/webhook"
+  - path: "/webhook/token"
+    auth:
+      style: "token"
+      secret: "s3cret"
+    destinations:
+      - url: "https://example.com/webhook"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	expected := []AuthConfig{
+		{Style: AuthStyleGitHub, Algorithm: AuthAlgorithmSHA1, Header: "X-Hub-Signature", Secret: "from-env", PreviousSecrets: []string{"previous-from-env"}},
+		{Style: AuthStyleStripe, Algorithm: AuthAlgorithmSHA256, Header: "Stripe-Signature", Secret: "s3cret"},
+		{Style: AuthStyleToken, Header: "X-Gitlab-Token", Secret: "s3cret"},
+	}
+	for i, want := range expected {
+		if got := *config.Endpoints[i].Auth; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected endpoint[%d] auth %+v, got %+v", i, want, got)
+		}
+	}
+}
+
+func TestLoadConfigProviderSecretReferences(t *testing.T) {
+	t.Setenv("TEST_PROVIDER_SECRET", "current-from-env")
+	previousFile := filepath.Join(t.TempDir(), "previous")
+	if err := os.WriteFile(previousFile, []byte("previous-from-file\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	configContent := `
+endpoints:
+  - path: "/webhook/github"
+    provider: "github"
+    secret: "env:TEST_PROVIDER_SECRET"
+    previous_secrets:
+      - "file:` + previousFile + `"
+      - "literal"
+    destinations:
+      - url: "https://example.com/webhook"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	want := []string{"current-from-env", "previous-from-file", "literal"}
+	if got := config.Endpoints[0].Secrets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected secrets %v, got %v", want, got)
+	}
+}
+
+func TestLoadConfigCharsetDefaults(t *testing.T) {
+	configContent := `
+endpoints:
+  - path: "/webhook/test"
+    charset:
+      normalize: true
+    destinations:
+      - url: "https://example.com/webhook"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if fallback := config.Endpoints[0].Charset.Fallback; fallback != DefaultCharsetFallback {
+		t.Errorf("Expected default charset fallback %s, got %s", DefaultCharsetFallback, fallback)
+	}
+}
+
+func TestValidateTransform(t *testing.T) {
+	tests := []struct {
+		name        string
+		transform   *TransformConfig
+		expectError bool
+	}{
+		{"none", nil, false},
+		{"template", &TransformConfig{Template: `{"text": {{json .Payload.action}}}`}, false},
+		{"missing template", &TransformConfig{ContentType: "application/json"}, true},
+		{"invalid template", &TransformConfig{Template: `{{.Payload`}, true},
+		{"unknown function", &TransformConfig{Template: `{{slack .Payload}}`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", Transform: tt.transform}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSlackDestination(t *testing.T) {
+	slackURL := "https://hooks.slack.com/services/T000/B000/XXXX"
+	tests := []struct {
+		name        string
+		dest        DestinationConfig
+		expectError bool
+	}{
+		{"default message", DestinationConfig{Type: DestinationTypeSlack, URL: slackURL, Method: "POST"}, false},
+		{"text", DestinationConfig{Type: DestinationTypeSlack, URL: slackURL, Method: "POST", Slack: &SlackConfig{Text: `{{.Fields.event}} on {{.Fields.repo}}`, Channel: "#deploys"}}, false},
+		{"missing url", DestinationConfig{Type: DestinationTypeSlack, Method: "POST"}, true},
+		{"invalid text", DestinationConfig{Type: DestinationTypeSlack, URL: slackURL, Method: "POST", Slack: &SlackConfig{Text: `{{.Payload`}}, true},
+		{"transform", DestinationConfig{Type: DestinationTypeSlack, URL: slackURL, Method: "POST", Transform: &TransformConfig{Template: `{}`}}, true},
+		{"encoding", DestinationConfig{Type: DestinationTypeSlack, URL: slackURL, Method: "POST", Encoding: &EncodingConfig{Format: "msgpack"}}, true},
+		{"capture headers", DestinationConfig{Type: DestinationTypeSlack, URL: slackURL, Method: "POST", CaptureHeaders: []string{"Retry-After"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDestinationConfig(0, 0, tt.dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateCaptureHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		dest        DestinationConfig
+		expectError bool
+	}{
+		{"none", DestinationConfig{URL: "https://example.com/webhook", Method: "POST"}, false},
+		{"rate limit headers", DestinationConfig{URL: "https://example.com/webhook", Method: "POST", CaptureHeaders: []string{"X-RateLimit-Remaining", "Retry-After"}}, false},
+		{"empty name", DestinationConfig{URL: "https://example.com/webhook", Method: "POST", CaptureHeaders: []string{""}}, true},
+		{"invalid name", DestinationConfig{URL: "https://example.com/webhook", Method: "POST", CaptureHeaders: []string{"X-RateLimit: Remaining"}}, true},
+		{"sink", DestinationConfig{Type: DestinationTypeS3, S3: &S3Config{Bucket: "webhooks"}, CaptureHeaders: []string{"X-RateLimit-Remaining"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDestinationConfig(0, 0, tt.dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateEncoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		encoding    *EncodingConfig
+		transform   *TransformConfig
+		expectError bool
+	}{
+		{"none", nil, nil, false},
+		{"json", &EncodingConfig{Format: "json"}, nil, false},
+		{"form", &EncodingConfig{Format: "form"}, nil, false},
+		{"msgpack", &EncodingConfig{Format: "msgpack"}, nil, false},
+		{"unsupported format", &EncodingConfig{Format: "yaml"}, nil, true},
+		{"protobuf without schema", &EncodingConfig{Format: "protobuf", Message: "shop.Order"}, nil, true},
+		{"protobuf missing schema file", &EncodingConfig{Format: "protobuf", Schema: "/nonexistent/order.pb", Message: "shop.Order"}, nil, true},
+		{"with transform", &EncodingConfig{Format: "msgpack"}, &TransformConfig{Template: `{}`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", Encoding: tt.encoding, Transform: tt.transform}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateMaintenanceWindows(t *testing.T) {
+	tests := []struct {
+		name        string
+		windows     []MaintenanceWindowConfig
+		expectError bool
+	}{
+		{"none", nil, false},
+		{"nightly", []MaintenanceWindowConfig{{Start: "02:00", Duration: time.Hour}}, false},
+		{"weekly", []MaintenanceWindowConfig{{Days: []string{"sat", "sun"}, Start: "22:00", Duration: 4 * time.Hour, Timezone: "Europe/Paris"}}, false},
+		{"missing start", []MaintenanceWindowConfig{{Duration: time.Hour}}, true},
+		{"missing duration", []MaintenanceWindowConfig{{Start: "02:00"}}, true},
+		{"invalid day", []MaintenanceWindowConfig{{Days: []string{"weekend"}, Start: "02:00", Duration: time.Hour}}, true},
+		{"unknown timezone", []MaintenanceWindowConfig{{Start: "02:00", Duration: time.Hour, Timezone: "Paris"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := DestinationConfig{URL: "https://example.com/webhook", Method: "POST", MaintenanceWindows: tt.windows}
+			err := validateDestinationConfig(0, 0, dest)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigTransformDefaults(t *testing.T) {
+	configContent := `
+endpoints:
+  - path: "/webhook/github"
+    destinations:
+      - url: "https://hooks.slack.com/services/T000/B000/XXXX"
+        transform:
+          template: '{"text": {{json .Payload.action}}}'
+      - url: "https://example.com/text"
+        transform:
+          template: '{{.Payload.action}}'
+          content_type: "text/plain"
+`
+	tmpFileName := createTempConfigFile(t, configContent)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dests := config.Endpoints[0].Destinations
+	if dests[0].Transform.ContentType != "application/json" {
+		t.Errorf("Expected the default content type, got %s", dests[0].Transform.ContentType)
+	}
+	if dests[1].Transform.ContentType != "text/plain" {
+		t.Errorf("Expected the configured content type, got %s", dests[1].Transform.ContentType)
+	}
+}
+
+func TestLoadConfigManifests(t *testing.T) {
+	t.Setenv("TEST_MANIFEST_KEY", "manifest-secret")
+	tmpFileName := createTempConfigFile(t, `
+manifests:
+  directory: "/var/lib/webhook-proxy/manifests"
+  key: "env:TEST_MANIFEST_KEY"
+endpoints:
+  - path: "/webhook/test"
+    destinations:
+      - url: "https://example.com/webhook"
+`)
+	defer os.Remove(tmpFileName)
+
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Manifests.Window != DefaultManifestWindow {
+		t.Errorf("Expected default window %s, got %s", DefaultManifestWindow, config.Manifests.Window)
+	}
+	if config.Manifests.Algorithm != "hmac-sha256" {
+		t.Errorf("Expected default algorithm hmac-sha256, got %s", config.Manifests.Algorithm)
+	}
+	if config.Manifests.Key != "manifest-secret" {
+		t.Errorf("Expected the key to be resolved from the environment")
+	}
+}
+
+func TestValidateManifestConfig(t *testing.T) {
+	seed := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	tests := []struct {
+		name        string
+		manifests   ManifestConfig
+		expectError bool
+	}{
+		{"hmac", ManifestConfig{Window: time.Hour, Algorithm: "hmac-sha256", Key: "secret"}, false},
+		{"ed25519", ManifestConfig{Window: 24 * time.Hour, Algorithm: "ed25519", Key: seed}, false},
+		{"missing key", ManifestConfig{Window: time.Hour, Algorithm: "hmac-sha256"}, true},
+		{"invalid ed25519 key", ManifestConfig{Window: time.Hour, Algorithm: "ed25519", Key: "secret"}, true},
+		{"unknown algorithm", ManifestConfig{Window: time.Hour, Algorithm: "rsa", Key: "secret"}, true},
+		{"short window", ManifestConfig{Window: 30 * time.Second, Algorithm: "hmac-sha256", Key: "secret"}, true},
+		{"fractional window", ManifestConfig{Window: time.Minute + time.Millisecond, Algorithm: "hmac-sha256", Key: "secret"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateManifestConfig(&tt.manifests)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateListeners(t *testing.T) {
+	tests := []struct {
+		name        string
+		listeners   []ListenerConfig
+		expectError bool
+	}{
+		{"valid", []ListenerConfig{{Name: "acme", Port: 9001, Endpoints: []string{"/acme"}}, {Name: "globex", Port: 9002, Endpoints: []string{"/globex"}}}, false},
+		{"no name", []ListenerConfig{{Port: 9001, Endpoints: []string{"/acme"}}}, true},
+		{"default name", []ListenerConfig{{Name: DefaultListener, Port: 9001, Endpoints: []string{"/acme"}}}, true},
+		{"duplicate name", []ListenerConfig{{Name: "acme", Port: 9001, Endpoints: []string{"/acme"}}, {Name: "acme", Port: 9002, Endpoints: []string{"/globex"}}}, true},
+		{"invalid port", []ListenerConfig{{Name: "acme", Port: 70000, Endpoints: []string{"/acme"}}}, true},
+		{"main port", []ListenerConfig{{Name: "acme", Port: 8080, Endpoints: []string{"/acme"}}}, true},
+		{"duplicate port", []ListenerConfig{{Name: "acme", Port: 9001, Endpoints: []string{"/acme"}}, {Name: "globex", Port: 9001, Endpoints: []string{"/globex"}}}, true},
+		{"no endpoints", []ListenerConfig{{Name: "acme", Port: 9001}}, true},
+		{"unknown endpoint", []ListenerConfig{{Name: "acme", Port: 9001, Endpoints: []string{"/initech"}}}, true},
+		{"endpoint on two listeners", []ListenerConfig{{Name: "acme", Port: 9001, Endpoints: []string{"/acme"}}, {Name: "globex", Port: 9002, Endpoints: []string{"/acme"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Server:  ServerConfig{Port: 8080, Listeners: tt.listeners},
+				Logging: LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Endpoints: []EndpointConfig{
+					{Path: "/acme", Destinations: []DestinationConfig{{URL: "https://example.com", Method: "POST"}}},
+					{Path: "/globex", Destinations: []DestinationConfig{{URL: "https://example.com", Method: "POST"}}},
+				},
+			}
+			err := validateConfig(config)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	server := ServerConfig{Listeners: []ListenerConfig{{Name: "acme", Port: 9001, Endpoints: []string{"/acme"}}}}
+	if got := server.Listener("/acme"); got != "acme" {
+		t.Errorf("Expected /acme on the acme listener, got %s", got)
+	}
+	if got := server.Listener("/globex"); got != DefaultListener {
+		t.Errorf("Expected /globex on the default listener, got %s", got)
+	}
+}
+
+func TestAssignListenerPorts(t *testing.T) {
+	tests := []struct {
+		name        string
+		ports       string
+		listeners   []ListenerConfig
+		expected    []int
+		expectError bool
+	}{
+		{"no range", "", []ListenerConfig{{Name: "acme", Port: 9001}}, []int{9001}, false},
+		{"in order", "9001-9003", []ListenerConfig{{Name: "acme"}, {Name: "globex"}}, []int{9001, 9002}, false},
+		{"explicit ports kept", "9001-9003", []ListenerConfig{{Name: "acme"}, {Name: "globex", Port: 9001}, {Name: "initech"}}, []int{9002, 9001, 9003}, false},
+		{"main port skipped", "8080-8081", []ListenerConfig{{Name: "acme"}}, []int{8081}, false},
+		{"range exhausted", "9001-9001", []ListenerConfig{{Name: "acme"}, {Name: "globex"}}, nil, true},
+		{"not a range", "9001", []ListenerConfig{{Name: "acme"}}, nil, true},
+		{"reversed range", "9003-9001", []ListenerConfig{{Name: "acme"}}, nil, true},
+		{"invalid port", "9001-70000", []ListenerConfig{{Name: "acme"}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := ServerConfig{Port: 8080, ListenerPorts: tt.ports, Listeners: tt.listeners}
+			err := assignListenerPorts(&server)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			for i, port := range tt.expected {
+				if server.Listeners[i].Port != port {
+					t.Errorf("Expected listener[%d] on port %d, got %d", i, port, server.Listeners[i].Port)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDeliveries(t *testing.T) {
+	tests := []struct {
+		name        string
+		deliveries  DeliveriesConfig
+		expectError bool
+	}{
+		{"valid", DeliveriesConfig{MaxConcurrent: 256, MaxPerDestination: 32, QueueSize: 1000, Overflow: DeliveriesOverflowReject}, false},
+		{"per destination only", DeliveriesConfig{MaxPerDestination: 32, QueueSize: 1000, Overflow: DeliveriesOverflowDrop}, false},
+		{"no bound", DeliveriesConfig{QueueSize: 1000, Overflow: DeliveriesOverflowReject}, true},
+		{"negative max concurrent", DeliveriesConfig{MaxConcurrent: -1, MaxPerDestination: 32, QueueSize: 1000, Overflow: DeliveriesOverflowReject}, true},
+		{"negative queue size", DeliveriesConfig{MaxConcurrent: 256, QueueSize: -1, Overflow: DeliveriesOverflowReject}, true},
+		{"invalid overflow", DeliveriesConfig{MaxConcurrent: 256, QueueSize: 1000, Overflow: "block"}, true},
+		{"negative max retry after", DeliveriesConfig{MaxConcurrent: 256, QueueSize: 1000, Overflow: DeliveriesOverflowReject, MaxRetryAfter: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deliveries := tt.deliveries
+			config := &Config{
+				Server:    ServerConfig{Port: 8080},
+				Logging:   LoggingConfig{Level: "info", Format: "json", Output: "stdout"},
+				Outbound:  OutboundConfig{Deliveries: &deliveries},
+				Endpoints: []EndpointConfig{{Path: "/webhook", Destinations: []DestinationConfig{{URL: "https://example.com", Method: "POST"}}}},
+			}
+			err := validateConfig(config)
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+
+	config := &Config{Outbound: OutboundConfig{Deliveries: &DeliveriesConfig{MaxConcurrent: 256}}}
+	setDefaultValues(config)
+	if config.Outbound.Deliveries.QueueSize != DefaultDeliveriesQueueSize || config.Outbound.Deliveries.Overflow != DeliveriesOverflowReject {
+		t.Errorf("Expected the default queue size and overflow, got %+v", config.Outbound.Deliveries)
+	}
 }
@@ -175,3 +175,40 @@ func TestTelemetryEnvironmentOverrides(t *testing.T) {
 		})
 	}
 }
+
+func TestLoggingOutputEnvironmentOverrides(t *testing.T) {
+	tmpFileName := createTempConfigFile(t, testConfigContent)
+	defer os.Remove(tmpFileName)
+
+	// Set environment variables
+	os.Setenv("WEBHOOK_PROXY_LOG_OUTPUT", "syslog")
+	os.Setenv("WEBHOOK_PROXY_LOG_SYSLOG_NETWORK", "udp")
+	os.Setenv("WEBHOOK_PROXY_LOG_SYSLOG_ADDRESS", "syslog.example.com:514")
+	os.Setenv("WEBHOOK_PROXY_LOG_ALSO_STDOUT", "false")
+	defer func() {
+		os.Unsetenv("WEBHOOK_PROXY_LOG_ALSO_STDOUT")
+		os.Unsetenv("WEBHOOK_PROXY_LOG_OUTPUT")
+		os.Unsetenv("WEBHOOK_PROXY_LOG_SYSLOG_NETWORK")
+		os.Unsetenv("WEBHOOK_PROXY_LOG_SYSLOG_ADDRESS")
+	}()
+
+	// Load the config
+	config, err := LoadConfig(tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Verify environment variables were applied
+	if config.Logging.Output != "syslog" {
+		t.Errorf("Expected logging output syslog, got %s", config.Logging.Output)
+	}
+	if config.Logging.Syslog.Network != "udp" {
+		t.Errorf("Expected syslog network udp, got %s", config.Logging.Syslog.Network)
+	}
+	if config.Logging.Syslog.Address != "syslog.example.com:514" {
+		t.Errorf("Expected syslog address syslog.example.com:514, got %s", config.Logging.Syslog.Address)
+	}
+	if config.Logging.MirrorToStdout() {
+		t.Errorf("Expected stdout mirroring to be disabled")
+	}
+}
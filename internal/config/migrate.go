@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"gopkg.in/yaml.v3"
+)
+
+// legacyRoot is the key the legacy config package read every setting under
+const legacyRoot = "config"
+
+// Fields renamed since the legacy config package, by the section holding them
+var (
+	legacyTopLevelKeys    = map[string]string{"webhooks": "endpoints"}
+	legacyLoggingKeys     = map[string]string{"file": "file_path"}
+	legacyTelemetryKeys   = map[string]string{"exporter": "exporter_type"}
+	legacyEndpointKeys    = map[string]string{"targets": "destinations"}
+	legacyDestinationKeys = map[string]string{"retry_count": "retries", "retry_interval": "retry_delay"}
+)
+
+// Migrate rewrites a configuration written for the legacy config package, with its
+// settings under a top-level config key, or with field names renamed since, to the
+// current schema. It returns the rewritten configuration and a description of each
+// change; when there is none, the configuration is returned as is. Comments are kept.
+func Migrate(data []byte) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return data, nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("config file must be a mapping")
+	}
+
+	m := &migration{}
+
+	// The legacy package read its settings under a single top-level key
+	if len(root.Content) == 2 && root.Content[0].Value == legacyRoot && root.Content[1].Kind == yaml.MappingNode {
+		root = root.Content[1]
+		doc.Content[0] = root
+		m.changes = append(m.changes, "moved the settings under config to the top level")
+	}
+
+	m.rename(root, "", legacyTopLevelKeys)
+	if server := mappingValue(root, "server"); server != nil {
+		m.splitAddress(server)
+	}
+	if logging := mappingValue(root, "logging"); logging != nil {
+		m.rename(logging, "logging.", legacyLoggingKeys)
+	}
+	if telemetry := mappingValue(root, "telemetry"); telemetry != nil {
+		m.rename(telemetry, "telemetry.", legacyTelemetryKeys)
+	}
+	for i, endpoint := range sequenceValue(root, "endpoints") {
+		prefix := fmt.Sprintf("endpoints[%d].", i)
+		m.rename(endpoint, prefix, legacyEndpointKeys)
+		m.renameDestinations(endpoint, prefix)
+	}
+	for i, pipeline := range sequenceValue(root, "pipelines") {
+		m.renameDestinations(pipeline, fmt.Sprintf("pipelines[%d].", i))
+	}
+
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	if len(m.changes) == 0 {
+		return data, nil, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("error writing config file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, fmt.Errorf("error writing config file: %w", err)
+	}
+	return buf.Bytes(), m.changes, nil
+}
+
+// migration records the changes made to a configuration, and the first error
+type migration struct {
+	changes []string
+	err     error
+}
+
+// rename renames the legacy keys of a mapping. A legacy key set next to its current name
+// is an error, since either value could be the intended one.
+func (m *migration) rename(mapping *yaml.Node, prefix string, renames map[string]string) {
+	if m.err != nil || mapping.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(mapping.Content); i += 2 {
+		key := mapping.Content[i]
+		current, ok := renames[key.Value]
+		if !ok {
+			continue
+		}
+		if mappingValue(mapping, current) != nil {
+			m.err = fmt.Errorf("%s%s and %s%s are both set", prefix, key.Value, prefix, current)
+			return
+		}
+		m.changes = append(m.changes, fmt.Sprintf("renamed %s%s to %s%s", prefix, key.Value, prefix, current))
+		key.Value = current
+	}
+}
+
+// renameDestinations renames the legacy keys of the destinations of an endpoint or pipeline
+func (m *migration) renameDestinations(parent *yaml.Node, prefix string) {
+	for i, destination := range sequenceValue(parent, "destinations") {
+		m.rename(destination, fmt.Sprintf("%sdestinations[%d].", prefix, i), legacyDestinationKeys)
+	}
+}
+
+// splitAddress replaces the legacy server address, as host:port, with the host and port
+func (m *migration) splitAddress(server *yaml.Node) {
+	if m.err != nil || server.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(server.Content); i += 2 {
+		if server.Content[i].Value != "address" {
+			continue
+		}
+		if mappingValue(server, "host") != nil || mappingValue(server, "port") != nil {
+			m.err = fmt.Errorf("server.address cannot be set with server.host or server.port")
+			return
+		}
+		host, port, err := net.SplitHostPort(server.Content[i+1].Value)
+		if err != nil {
+			m.err = fmt.Errorf("invalid server.address: %s (must be host:port)", server.Content[i+1].Value)
+			return
+		}
+
+		fields := []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "host", HeadComment: server.Content[i].HeadComment},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: host, Style: yaml.DoubleQuotedStyle},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "port"},
+			{Kind: yaml.ScalarNode, Tag: "!!int", Value: port, LineComment: server.Content[i+1].LineComment},
+		}
+		server.Content = append(server.Content[:i], append(fields, server.Content[i+2:]...)...)
+		m.changes = append(m.changes, "split server.address into server.host and server.port")
+		return
+	}
+}
+
+// mappingValue returns the value of a key of a mapping, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceValue returns the items of a sequence under a key of a mapping
+func sequenceValue(mapping *yaml.Node, key string) []*yaml.Node {
+	value := mappingValue(mapping, key)
+	if value == nil || value.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return value.Content
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrate(t *testing.T) {
+	legacy := `config:
+  server:
+    address: "127.0.0.1:9090" # listen address
+  telemetry:
+    exporter: stdout
+  webhooks:
+    # GitHub events
+    - path: "/webhook/github"
+      targets:
+        - url: "https://example.com/github"
+          retry_interval: 2s
+  pipelines:
+    - name: orders
+      destinations:
+        - url: "https://example.com/orders"
+          retry_count: 3
+`
+	migrated, changes, err := Migrate([]byte(legacy))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"moved the settings under config to the top level",
+		"renamed webhooks to endpoints",
+		"split server.address into server.host and server.port",
+		"renamed telemetry.exporter to telemetry.exporter_type",
+		"renamed endpoints[0].targets to endpoints[0].destinations",
+		"renamed endpoints[0].destinations[0].retry_interval to endpoints[0].destinations[0].retry_delay",
+		"renamed pipelines[0].destinations[0].retry_count to pipelines[0].destinations[0].retries",
+	}, changes)
+	assert.Contains(t, string(migrated), "# GitHub events")
+	assert.Contains(t, string(migrated), "port: 9090 # listen address")
+
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal(migrated, &cfg))
+	assert.Equal(t, "127.0.0.1", cfg.Server.Host)
+	assert.Equal(t, 9090, cfg.Server.Port)
+	assert.Equal(t, "stdout", cfg.Telemetry.ExporterType)
+	require.Len(t, cfg.Endpoints, 1)
+	require.Len(t, cfg.Endpoints[0].Destinations, 1)
+	assert.Equal(t, "2s", cfg.Endpoints[0].Destinations[0].RetryDelay.String())
+	assert.Equal(t, 3, cfg.Pipelines[0].Destinations[0].Retries)
+
+	// A current configuration is returned as is
+	current := "server:\n  port: 8080\nendpoints: []\n"
+	migrated, changes, err = Migrate([]byte(current))
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, current, string(migrated))
+}
+
+func TestMigrateConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"legacy and current name", "logging:\n  file: a.log\n  file_path: b.log\n"},
+		{"address with port", "server:\n  address: \":9090\"\n  port: 8080\n"},
+		{"invalid address", "server:\n  address: localhost\n"},
+		{"not a mapping", "- server\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Migrate([]byte(tt.config))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Package deadletter persists the deliveries that failed for good, with their webhook and
+// last error, so that operators can inspect them and send them again once the destination
+// is fixed
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileExtension is the extension of dead letter files
+const fileExtension = ".json"
+
+// ErrNotFound is returned for an unknown dead letter
+var ErrNotFound = errors.New("dead letter not found")
+
+// Letter is a delivery that failed for good
+type Letter struct {
+	// ID is the ID of the failed delivery
+	ID       string `json:"id"`
+	Endpoint string `json:"endpoint"`
+
+	// Destination is empty for a webhook no destination matched, saved by an endpoint
+	// dead-lettering such webhooks
+	Destination string `json:"destination"`
+
+	// Metadata are the routing fields extracted by the endpoint's provider preset, kept for
+	// webhooks no destination matched so that they are routed again when redriven
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Labels are the labels of the destination
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Body and Headers are those of the last attempt, before the destination's own headers
+	Body    []byte            `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Result of the last attempt
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error"`
+
+	ReceivedAt time.Time `json:"received_at,omitzero"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// Store keeps one file per dead letter in a directory
+type Store struct {
+	dir string
+}
+
+// Open opens the store in the given directory, creating it if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Save writes the dead letter
+func (s *Store) Save(letter Letter) error {
+	if !validID(letter.ID) {
+		return fmt.Errorf("invalid dead letter ID: %q", letter.ID)
+	}
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+
+	// Write to a temporary file first so that a crash never leaves a partial letter
+	tmp, err := os.CreateTemp(s.dir, letter.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(letter.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
+
+// Get returns a dead letter, or ErrNotFound
+func (s *Store) Get(id string) (Letter, error) {
+	if !validID(id) {
+		return Letter{}, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Letter{}, ErrNotFound
+	}
+	if err != nil {
+		return Letter{}, fmt.Errorf("failed to read dead letter: %w", err)
+	}
+
+	var letter Letter
+	if err := json.Unmarshal(data, &letter); err != nil {
+		return Letter{}, fmt.Errorf("failed to decode dead letter: %w", err)
+	}
+	return letter, nil
+}
+
+// List returns the stored dead letters, oldest failure first.
+// Unreadable letters are skipped and reported in the returned error.
+func (s *Store) List() ([]Letter, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter directory: %w", err)
+	}
+
+	var letters []Letter
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileExtension) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var letter Letter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		}
+		letters = append(letters, letter)
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+
+	return letters, errors.Join(errs...)
+}
+
+// Delete removes a dead letter, or returns ErrNotFound
+func (s *Store) Delete(id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	return nil
+}
+
+// validID reports whether an ID names a file of the directory, so that IDs taken from
+// admin requests cannot reach other files
+func validID(id string) bool {
+	return id != "" && !strings.HasPrefix(id, ".") && !strings.ContainsAny(id, `/\`)
+}
+
+// path returns the file of a dead letter
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+fileExtension)
+}
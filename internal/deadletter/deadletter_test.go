@@ -0,0 +1,86 @@
+package deadletter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "dead-letters"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	later := Letter{
+		ID:          "later",
+		Endpoint:    "/webhook",
+		Destination: "https://example.com",
+		Body:        []byte(`{"event":"push"}`),
+		Headers:     map[string]string{"Content-Type": "application/json"},
+		Attempts:    3,
+		StatusCode:  503,
+		Error:       "received non-2xx status code: 503",
+		ReceivedAt:  now.Add(-time.Minute),
+		FailedAt:    now,
+	}
+	sooner := later
+	sooner.ID = "sooner"
+	sooner.FailedAt = now.Add(-time.Second)
+
+	require.NoError(t, store.Save(later))
+	require.NoError(t, store.Save(sooner))
+
+	letters, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "sooner", letters[0].ID)
+	assert.Equal(t, "later", letters[1].ID)
+
+	letter, err := store.Get("later")
+	require.NoError(t, err)
+	assert.Equal(t, later.Body, letter.Body)
+	assert.Equal(t, later.Headers, letter.Headers)
+	assert.Equal(t, later.Error, letter.Error)
+	assert.True(t, later.FailedAt.Equal(letter.FailedAt))
+
+	// Deleted letters are gone, deleting twice reports them missing
+	require.NoError(t, store.Delete("sooner"))
+	assert.ErrorIs(t, store.Delete("sooner"), ErrNotFound)
+	_, err = store.Get("sooner")
+	assert.ErrorIs(t, err, ErrNotFound)
+	letters, err = store.List()
+	require.NoError(t, err)
+	assert.Len(t, letters, 1)
+}
+
+func TestStoreRejectsInvalidIDs(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(filepath.Join(dir, "dead-letters"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.json"), []byte(`{}`), 0o600))
+
+	for _, id := range []string{"", "../secret", ".hidden", `a\b`} {
+		_, err := store.Get(id)
+		assert.ErrorIs(t, err, ErrNotFound, id)
+		assert.ErrorIs(t, store.Delete(id), ErrNotFound, id)
+		assert.Error(t, store.Save(Letter{ID: id}), id)
+	}
+	assert.FileExists(t, filepath.Join(dir, "secret.json"))
+}
+
+func TestStoreListSkipsInvalidLetters(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(Letter{ID: "valid", FailedAt: time.Now()}))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600))
+
+	letters, err := store.List()
+	assert.Error(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "valid", letters[0].ID)
+}
@@ -0,0 +1,71 @@
+// Package encoder encodes the normalized payload of a webhook, its JSON, form or XML body
+// parsed into maps and slices, in the format a destination asks for: JSON, form-encoded,
+// MessagePack, or protobuf with the schema of a compiled descriptor set
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Formats a payload is encoded in
+const (
+	FormatJSON     = "json"
+	FormatForm     = "form"
+	FormatMsgpack  = "msgpack"
+	FormatProtobuf = "protobuf"
+)
+
+// Encoder encodes payloads in a format
+type Encoder interface {
+	// Encode encodes a normalized payload
+	Encode(payload interface{}) ([]byte, error)
+
+	// ContentType is the Content-Type of the encoded payloads
+	ContentType() string
+}
+
+// New returns the encoder of a format. The protobuf format encodes payloads as the message
+// with the given full name, found in the schema, a FileDescriptorSet file such as written
+// by protoc --descriptor_set_out --include_imports.
+func New(format, schema, message string) (Encoder, error) {
+	switch format {
+	case FormatJSON:
+		return jsonEncoder{}, nil
+	case FormatForm:
+		return formEncoder{}, nil
+	case FormatMsgpack:
+		return msgpackEncoder{}, nil
+	case FormatProtobuf:
+		return newProtobufEncoder(schema, message)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// jsonEncoder encodes payloads as JSON
+type jsonEncoder struct{}
+
+// Encode encodes the payload as JSON
+func (jsonEncoder) Encode(payload interface{}) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
+// ContentType returns application/json
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+// generic returns a value of a type the encoders do not handle, such as a file uploaded
+// in a multipart form, as the objects, arrays and scalars of its JSON encoding
+func generic(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported value of type %T: %w", value, err)
+	}
+	var converted interface{}
+	if err := json.Unmarshal(data, &converted); err != nil {
+		return nil, err
+	}
+	return converted, nil
+}
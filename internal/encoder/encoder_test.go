@@ -0,0 +1,156 @@
+package encoder
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func payload(t *testing.T, body string) interface{} {
+	var parsed interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &parsed))
+	return parsed
+}
+
+func TestNew(t *testing.T) {
+	for format, contentType := range map[string]string{
+		FormatJSON:    "application/json",
+		FormatForm:    "application/x-www-form-urlencoded",
+		FormatMsgpack: "application/msgpack",
+	} {
+		enc, err := New(format, "", "")
+		require.NoError(t, err, format)
+		assert.Equal(t, contentType, enc.ContentType())
+	}
+
+	_, err := New("yaml", "", "")
+	assert.ErrorContains(t, err, "unsupported format")
+
+	_, err = New(FormatProtobuf, "", "")
+	assert.ErrorContains(t, err, "requires a schema and a message")
+}
+
+func TestEncodeJSON(t *testing.T) {
+	enc, err := New(FormatJSON, "", "")
+	require.NoError(t, err)
+
+	body, err := enc.Encode(payload(t, `{"name":"mona","tags":["a","b"]}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"mona","tags":["a","b"]}`, string(body))
+}
+
+func TestEncodeForm(t *testing.T) {
+	enc, err := New(FormatForm, "", "")
+	require.NoError(t, err)
+
+	body, err := enc.Encode(payload(t, `{
+		"event": "order.paid",
+		"amount": 12.5,
+		"paid": true,
+		"note": null,
+		"tags": ["a", "b"],
+		"customer": {"email": "mona@example.com"},
+		"items": [{"sku": "A1", "qty": 2}]
+	}`))
+	require.NoError(t, err)
+
+	values, err := url.ParseQuery(string(body))
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"event":           {"order.paid"},
+		"amount":          {"12.5"},
+		"paid":            {"true"},
+		"note":            {""},
+		"tags":            {"a", "b"},
+		"customer[email]": {"mona@example.com"},
+		"items[0][sku]":   {"A1"},
+		"items[0][qty]":   {"2"},
+	}, values)
+
+	_, err = enc.Encode(payload(t, `["a"]`))
+	assert.ErrorContains(t, err, "only object payloads")
+}
+
+func TestEncodeMsgpack(t *testing.T) {
+	enc, err := New(FormatMsgpack, "", "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		payload  string
+		expected []byte
+	}{
+		{name: "positive int", payload: `{"a":1}`, expected: []byte{0x81, 0xa1, 'a', 0x01}},
+		{name: "negative int", payload: `-1`, expected: []byte{0xff}},
+		{name: "int16", payload: `1000`, expected: []byte{0xd1, 0x03, 0xe8}},
+		{name: "float", payload: `1.5`, expected: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{name: "scalars", payload: `[true,false,null,"hi"]`, expected: []byte{0x94, 0xc3, 0xc2, 0xc0, 0xa2, 'h', 'i'}},
+		{name: "sorted keys", payload: `{"b":1,"a":2}`, expected: []byte{0x82, 0xa1, 'a', 0x02, 0xa1, 'b', 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := enc.Encode(payload(t, tt.payload))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, body)
+		})
+	}
+}
+
+func TestEncodeProtobuf(t *testing.T) {
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("order.proto"),
+		Package: proto.String("shop"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:     proto.String("order_id"),
+					JsonName: proto.String("orderId"),
+					Number:   proto.Int32(1),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				},
+				{
+					Name:     proto.String("amount"),
+					JsonName: proto.String("amount"),
+					Number:   proto.Int32(2),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				},
+			},
+		}},
+	}}}
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+	schema := filepath.Join(t.TempDir(), "order.pb")
+	require.NoError(t, os.WriteFile(schema, data, 0o600))
+
+	_, err = New(FormatProtobuf, schema, "shop.Missing")
+	assert.ErrorContains(t, err, "not found")
+
+	enc, err := New(FormatProtobuf, schema, "shop.Order")
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-protobuf", enc.ContentType())
+
+	body, err := enc.Encode(payload(t, `{"order_id":"ord_1","amount":1250,"unknown":true}`))
+	require.NoError(t, err)
+
+	fields := enc.(*protobufEncoder).message.Fields()
+	decoded := dynamicpb.NewMessage(enc.(*protobufEncoder).message)
+	require.NoError(t, proto.Unmarshal(body, decoded))
+	assert.Equal(t, "ord_1", decoded.Get(fields.ByName("order_id")).String())
+	assert.Equal(t, int64(1250), decoded.Get(fields.ByName("amount")).Int())
+
+	_, err = enc.Encode(payload(t, `{"amount":"many"}`))
+	assert.ErrorContains(t, err, "does not match message shop.Order")
+}
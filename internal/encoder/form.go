@@ -0,0 +1,99 @@
+package encoder
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// formEncoder encodes payloads as application/x-www-form-urlencoded
+type formEncoder struct{}
+
+// Encode encodes an object payload as a form. Nested objects use the bracket notation,
+// as in customer[email]; arrays of scalars repeat their key and arrays of objects are
+// indexed, as in items[0][sku].
+func (formEncoder) Encode(payload interface{}) ([]byte, error) {
+	object, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("only object payloads can be form-encoded")
+	}
+
+	values := make(url.Values)
+	for _, key := range sortedKeys(object) {
+		if err := addFormValue(values, key, object[key]); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(values.Encode()), nil
+}
+
+// ContentType returns application/x-www-form-urlencoded
+func (formEncoder) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+// addFormValue adds a value to the form under a key, flattening objects and arrays
+func addFormValue(values url.Values, key string, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, field := range sortedKeys(v) {
+			if err := addFormValue(values, key+"["+field+"]", v[field]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if allScalars(v) {
+			for _, item := range v {
+				if err := addFormValue(values, key, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for i, item := range v {
+			if err := addFormValue(values, key+"["+strconv.Itoa(i)+"]", item); err != nil {
+				return err
+			}
+		}
+	case []string:
+		values[key] = append(values[key], v...)
+	case nil:
+		values.Add(key, "")
+	case string:
+		values.Add(key, v)
+	case bool:
+		values.Add(key, strconv.FormatBool(v))
+	case float64:
+		values.Add(key, strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		converted, err := generic(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		return addFormValue(values, key, converted)
+	}
+	return nil
+}
+
+// allScalars reports whether no item of an array is an object or an array
+func allScalars(items []interface{}) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// sortedKeys returns the keys of an object in order, for stable encodings
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
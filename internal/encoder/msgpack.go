@@ -0,0 +1,127 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// msgpackEncoder encodes payloads as MessagePack
+type msgpackEncoder struct{}
+
+// Encode encodes the payload as MessagePack. Numbers without a fractional part are
+// encoded as integers, the others as 64-bit floats; object keys are sorted.
+func (msgpackEncoder) Encode(payload interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeMsgpack(&buf, payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ContentType returns application/msgpack
+func (msgpackEncoder) ContentType() string {
+	return "application/msgpack"
+}
+
+// writeMsgpack writes the MessagePack encoding of a value
+func writeMsgpack(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			writeMsgpackInt(buf, int64(v))
+		} else {
+			buf.WriteByte(0xcb)
+			_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+		}
+	case int:
+		writeMsgpackInt(buf, int64(v))
+	case int64:
+		writeMsgpackInt(buf, v)
+	case string:
+		writeMsgpackString(buf, v)
+	case []string:
+		writeMsgpackHeader(buf, len(v), 0x90, 0xdc, 0xdd, 15)
+		for _, item := range v {
+			writeMsgpackString(buf, item)
+		}
+	case []interface{}:
+		writeMsgpackHeader(buf, len(v), 0x90, 0xdc, 0xdd, 15)
+		for _, item := range v {
+			if err := writeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeMsgpackHeader(buf, len(v), 0x80, 0xde, 0xdf, 15)
+		for _, key := range sortedKeys(v) {
+			writeMsgpackString(buf, key)
+			if err := writeMsgpack(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		converted, err := generic(v)
+		if err != nil {
+			return err
+		}
+		return writeMsgpack(buf, converted)
+	}
+	return nil
+}
+
+// writeMsgpackInt writes an integer in its smallest MessagePack encoding
+func writeMsgpackInt(buf *bytes.Buffer, v int64) {
+	switch {
+	case v >= 0 && v <= 0x7f:
+		buf.WriteByte(byte(v))
+	case v < 0 && v >= -32:
+		buf.WriteByte(byte(int8(v)))
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(v)))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		_ = binary.Write(buf, binary.BigEndian, int16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		_ = binary.Write(buf, binary.BigEndian, int32(v))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+// writeMsgpackString writes a UTF-8 string
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	if len(s) > 31 && len(s) <= math.MaxUint8 {
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(len(s)))
+	} else {
+		writeMsgpackHeader(buf, len(s), 0xa0, 0xda, 0xdb, 31)
+	}
+	buf.WriteString(s)
+}
+
+// writeMsgpackHeader writes the header of a string, array or map of the given length: in
+// the fix byte when it is at most fixMax, else with a 16 or 32-bit length
+func writeMsgpackHeader(buf *bytes.Buffer, length int, fix, code16, code32 byte, fixMax int) {
+	switch {
+	case length <= fixMax:
+		buf.WriteByte(fix | byte(length))
+	case length <= math.MaxUint16:
+		buf.WriteByte(code16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(code32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+}
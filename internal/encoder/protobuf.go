@@ -0,0 +1,69 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protobufEncoder encodes payloads as a protobuf message of a schema loaded at startup
+type protobufEncoder struct {
+	message protoreflect.MessageDescriptor
+}
+
+// newProtobufEncoder loads the message with the given full name from a FileDescriptorSet file
+func newProtobufEncoder(schema, message string) (*protobufEncoder, error) {
+	if schema == "" || message == "" {
+		return nil, fmt.Errorf("the protobuf format requires a schema and a message")
+	}
+
+	data, err := os.ReadFile(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid schema %s, expected a FileDescriptorSet: %w", schema, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", schema, err)
+	}
+
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found in schema %s", message, schema)
+	}
+	messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message in schema %s", message, schema)
+	}
+	return &protobufEncoder{message: messageDescriptor}, nil
+}
+
+// Encode encodes the payload as the message, mapping its fields by their JSON names
+// following the protobuf JSON mapping. Fields unknown to the message are dropped.
+func (e *protobufEncoder) Encode(payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	message := dynamicpb.NewMessage(e.message)
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("payload does not match message %s: %w", e.message.FullName(), err)
+	}
+	return proto.Marshal(message)
+}
+
+// ContentType returns application/x-protobuf
+func (e *protobufEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
@@ -0,0 +1,179 @@
+// Package events provides an in-process bus of the proxy's lifecycle events, such as a
+// delivery failing for good or a destination being paused, that logging, metrics and
+// alerting subscribe to instead of being called from each place the event happens
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Type identifies a kind of lifecycle event
+type Type string
+
+const (
+	// DeliveryFailed is published when a delivery failed and all its retries are exhausted
+	DeliveryFailed Type = "delivery_failed"
+	// DestinationPaused is published when a destination stops receiving deliveries, e.g. during a DNS outage
+	DestinationPaused Type = "destination_paused"
+	// DestinationResumed is published when a paused destination receives deliveries again
+	DestinationResumed Type = "destination_resumed"
+	// QueueHighWater is published when a queue fills up past its high-water mark
+	QueueHighWater Type = "queue_high_water"
+)
+
+// Event is a lifecycle event of the proxy
+type Event struct {
+	Type Type
+	Time time.Time
+
+	// Endpoint and Destination the event relates to, when it relates to one
+	Endpoint    string
+	Destination string
+
+	// Message describes the event for humans
+	Message string
+
+	// Fields hold the details of the event, such as the error of a failed delivery
+	Fields map[string]interface{}
+}
+
+// Subscriber receives the events of a bus. Subscribers are called synchronously by the
+// publisher, possibly from several goroutines at once, and must not block.
+type Subscriber func(event Event)
+
+// subscription is a subscriber and the types of events it receives, all when empty
+type subscription struct {
+	subscriber Subscriber
+	types      map[Type]bool
+}
+
+// Bus dispatches the published events to their subscribers
+type Bus struct {
+	mu            sync.RWMutex
+	next          int
+	subscriptions map[int]subscription
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subscriptions: make(map[int]subscription)}
+}
+
+// Subscribe registers a subscriber for the given types of events, or for all events when
+// no type is given, and returns the function cancelling the subscription
+func (b *Bus) Subscribe(subscriber Subscriber, types ...Type) func() {
+	sub := subscription{subscriber: subscriber}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.subscriptions[id] = sub
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscriptions, id)
+	}
+}
+
+// Publish sends an event to the subscribers of its type, stamping it with the current
+// time when it has none. Publishing on a nil bus does nothing.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subscribers := make([]Subscriber, 0, len(b.subscriptions))
+	for _, sub := range b.subscriptions {
+		if sub.types == nil || sub.types[event.Type] {
+			subscribers = append(subscribers, sub.subscriber)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(event)
+	}
+}
+
+// levels are the log levels of the events; failed deliveries are already logged with
+// their attempts when they complete, so their event is only logged at debug level
+var levels = map[Type]logrus.Level{
+	DeliveryFailed:     logrus.DebugLevel,
+	DestinationPaused:  logrus.WarnLevel,
+	DestinationResumed: logrus.InfoLevel,
+	QueueHighWater:     logrus.WarnLevel,
+}
+
+// LogSubscriber returns a subscriber logging each event with its fields
+func LogSubscriber(log logrus.FieldLogger) Subscriber {
+	return func(event Event) {
+		fields := logrus.Fields{"event": event.Type}
+		if event.Endpoint != "" {
+			fields["endpoint"] = event.Endpoint
+		}
+		if event.Destination != "" {
+			fields["destination"] = event.Destination
+		}
+		for k, v := range event.Fields {
+			fields[k] = v
+		}
+
+		level, ok := levels[event.Type]
+		if !ok {
+			level = logrus.InfoLevel
+		}
+		log.WithFields(fields).Log(level, event.Message)
+	}
+}
+
+// Counter counts the published events by type, for the metrics
+type Counter struct {
+	mu     sync.Mutex
+	counts map[Type]int64
+}
+
+// NewCounter creates a counter with no events counted
+func NewCounter() *Counter {
+	return &Counter{counts: make(map[Type]int64)}
+}
+
+// Record counts an event; it is the counter's subscriber
+func (c *Counter) Record(event Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[event.Type]++
+}
+
+// Snapshot returns the number of events counted for each type
+func (c *Counter) Snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := make(map[string]int64, len(c.counts))
+	for t, count := range c.counts {
+		snapshot[string(t)] = count
+	}
+	return snapshot
+}
+
+// Reset clears the counts
+func (c *Counter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = make(map[Type]int64)
+}
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+
+	var all, paused []Event
+	bus.Subscribe(func(event Event) { all = append(all, event) })
+	unsubscribe := bus.Subscribe(func(event Event) { paused = append(paused, event) }, DestinationPaused)
+
+	bus.Publish(Event{Type: DestinationPaused, Destination: "https://example.com"})
+	bus.Publish(Event{Type: DeliveryFailed})
+
+	require.Len(t, all, 2)
+	require.Len(t, paused, 1)
+	assert.Equal(t, "https://example.com", paused[0].Destination)
+	assert.False(t, paused[0].Time.IsZero())
+
+	// Cancelled subscriptions receive no more events
+	unsubscribe()
+	bus.Publish(Event{Type: DestinationPaused})
+	assert.Len(t, all, 3)
+	assert.Len(t, paused, 1)
+
+	// Publishing on a nil bus does nothing
+	var nilBus *Bus
+	nilBus.Publish(Event{Type: DeliveryFailed})
+}
+
+func TestLogSubscriber(t *testing.T) {
+	log := logrus.New()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	LogSubscriber(log)(Event{
+		Type:        DestinationPaused,
+		Endpoint:    "/webhook",
+		Destination: "https://example.com",
+		Message:     "Destination paused",
+		Fields:      map[string]interface{}{"reason": "dns_outage"},
+	})
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "warning", entry["level"])
+	assert.Equal(t, "Destination paused", entry["msg"])
+	assert.Equal(t, "destination_paused", entry["event"])
+	assert.Equal(t, "/webhook", entry["endpoint"])
+	assert.Equal(t, "https://example.com", entry["destination"])
+	assert.Equal(t, "dns_outage", entry["reason"])
+
+	// Failed deliveries are already logged when they complete
+	buf.Reset()
+	LogSubscriber(log)(Event{Type: DeliveryFailed, Message: "Delivery failed"})
+	assert.Empty(t, buf.String())
+}
+
+func TestCounter(t *testing.T) {
+	bus := NewBus()
+	counter := NewCounter()
+	bus.Subscribe(counter.Record)
+
+	bus.Publish(Event{Type: DeliveryFailed})
+	bus.Publish(Event{Type: DeliveryFailed})
+	bus.Publish(Event{Type: QueueHighWater})
+	assert.Equal(t, map[string]int64{"delivery_failed": 2, "queue_high_water": 1}, counter.Snapshot())
+
+	counter.Reset()
+	assert.Empty(t, counter.Snapshot())
+}
@@ -0,0 +1,174 @@
+// Package fixture records received webhooks as sanitized fixture files and loads them
+// back, so that integration tests can replay real deliveries
+package fixture
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/redact"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// fileExtension is the extension of fixture files
+const fileExtension = ".json"
+
+// EncodingBase64 marks a fixture body that is not valid UTF-8 and is stored base64-encoded
+const EncodingBase64 = "base64"
+
+// Fixture is a recorded webhook
+type Fixture struct {
+	Endpoint     string            `json:"endpoint"`
+	Headers      map[string]string `json:"headers"`
+	Body         string            `json:"body"`
+	BodyEncoding string            `json:"body_encoding,omitempty"`
+	ReceivedAt   time.Time         `json:"received_at"`
+}
+
+// Payload returns the body of the webhook
+func (f *Fixture) Payload() ([]byte, error) {
+	if f.BodyEncoding == EncodingBase64 {
+		body, err := base64.StdEncoding.DecodeString(f.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixture body: %w", err)
+		}
+		return body, nil
+	}
+	return []byte(f.Body), nil
+}
+
+// Request returns a POST request delivering the webhook to its endpoint
+func (f *Fixture) Request() (*http.Request, error) {
+	body, err := f.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, f.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid fixture endpoint: %w", err)
+	}
+	for k, v := range f.Headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// Recorder saves received webhooks as fixture files, one directory per endpoint
+type Recorder struct {
+	dir           string
+	redactFields  []string
+	redactHeaders []string
+}
+
+// NewRecorder creates a recorder writing to the configured directory, creating it if needed
+func NewRecorder(cfg config.RecordingConfig) (*Recorder, error) {
+	if err := os.MkdirAll(cfg.Directory, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	return &Recorder{dir: cfg.Directory, redactFields: cfg.RedactFields, redactHeaders: cfg.RedactHeaders}, nil
+}
+
+// Record saves a webhook received on an endpoint, with its sensitive headers and JSON
+// fields masked, and returns the path of the fixture file
+func (r *Recorder) Record(delivery *webhook.Delivery) (string, error) {
+	receivedAt := delivery.ReceivedAt.UTC()
+	endpoint := delivery.Endpoint
+	fixture := Fixture{
+		Endpoint:   endpoint,
+		Headers:    redact.Headers(delivery.Headers, r.redactHeaders),
+		ReceivedAt: receivedAt,
+	}
+
+	body := delivery.Body
+	if len(r.redactFields) > 0 {
+		body = redact.ParsedJSON(body, delivery.JSONObject(), r.redactFields)
+	}
+	if utf8.Valid(body) {
+		fixture.Body = string(body)
+	} else {
+		fixture.Body = base64.StdEncoding.EncodeToString(body)
+		fixture.BodyEncoding = EncodingBase64
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	dir := filepath.Join(r.dir, endpointDir(endpoint))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	// Names sort in reception order
+	name := receivedAt.Format("20060102T150405.000000000Z") + "-" + uuid.NewString()[:8] + fileExtension
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return "", fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return path, nil
+}
+
+// endpointDir returns the directory name of an endpoint's fixtures
+func endpointDir(endpoint string) string {
+	name := strings.ReplaceAll(strings.Trim(endpoint, "/"), "/", "_")
+	if name == "" {
+		return "root"
+	}
+	return name
+}
+
+// Load reads a fixture file
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// LoadDir reads the fixture files of a directory and its subdirectories, in name order
+func LoadDir(dir string) ([]*Fixture, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.HasSuffix(path, fileExtension) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	sort.Strings(paths)
+
+	fixtures := make([]*Fixture, 0, len(paths))
+	for _, path := range paths {
+		fixture, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
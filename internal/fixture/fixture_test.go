@@ -0,0 +1,108 @@
+package fixture
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/redact"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(config.RecordingConfig{
+		Directory:     dir,
+		RedactFields:  []string{"customer.email"},
+		RedactHeaders: []string{"X-Api-Key"},
+	})
+	require.NoError(t, err)
+
+	path, err := recorder.Record(webhook.New("/webhook/stripe", []byte(`{"id":"evt_1","customer":{"email":"user@example.com"}}`), map[string]string{
+		"Content-Type":  "application/json",
+		"Authorization": "Bearer secret",
+		"X-Api-Key":     "key",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "webhook_stripe"), filepath.Dir(path))
+
+	fixture, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "/webhook/stripe", fixture.Endpoint)
+	assert.Equal(t, "application/json", fixture.Headers["Content-Type"])
+	assert.Equal(t, redact.Mask, fixture.Headers["Authorization"])
+	assert.Equal(t, redact.Mask, fixture.Headers["X-Api-Key"])
+	assert.JSONEq(t, `{"id":"evt_1","customer":{"email":"[REDACTED]"}}`, fixture.Body)
+	assert.Empty(t, fixture.BodyEncoding)
+	assert.False(t, fixture.ReceivedAt.IsZero())
+}
+
+func TestRecordBinaryBody(t *testing.T) {
+	recorder, err := NewRecorder(config.RecordingConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+
+	body := []byte{0xff, 0xfe, 0x00, 0x01}
+	path, err := recorder.Record(webhook.New("/", body, map[string]string{"Content-Type": "application/octet-stream"}))
+	require.NoError(t, err)
+	assert.Equal(t, "root", filepath.Base(filepath.Dir(path)))
+
+	fixture, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, EncodingBase64, fixture.BodyEncoding)
+
+	payload, err := fixture.Payload()
+	require.NoError(t, err)
+	assert.Equal(t, body, payload)
+}
+
+func TestFixtureRequest(t *testing.T) {
+	fixture := &Fixture{
+		Endpoint: "/webhook/github",
+		Headers:  map[string]string{"X-GitHub-Event": "push"},
+		Body:     `{"ref":"main"}`,
+	}
+
+	req, err := fixture.Request()
+	require.NoError(t, err)
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/webhook/github", req.URL.Path)
+	assert.Equal(t, "push", req.Header.Get("X-GitHub-Event"))
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"ref":"main"}`, string(body))
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(config.RecordingConfig{Directory: dir})
+	require.NoError(t, err)
+
+	for _, body := range []string{"first", "second", "third"} {
+		_, err := recorder.Record(webhook.New("/webhook", []byte(body), nil))
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a fixture"), 0o600))
+
+	fixtures, err := LoadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, fixtures, 3)
+	assert.Equal(t, "first", fixtures[0].Body)
+	assert.Equal(t, "second", fixtures[1].Body)
+	assert.Equal(t, "third", fixtures[2].Body)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, err := Load(path)
+	assert.Error(t, err)
+
+	_, err = LoadDir(filepath.Dir(path))
+	assert.Error(t, err)
+}
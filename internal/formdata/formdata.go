@@ -0,0 +1,116 @@
+// Package formdata parses x-www-form-urlencoded and multipart/form-data webhooks
+// into a normalized map, as sent by providers such as Mailgun or Twilio
+package formdata
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+)
+
+// Form content types
+const (
+	ContentTypeURLEncoded = "application/x-www-form-urlencoded"
+	ContentTypeMultipart  = "multipart/form-data"
+)
+
+// File is a file uploaded in a multipart form, with its content base64-encoded
+type File struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Content     string `json:"content"`
+}
+
+// IsForm reports whether a Content-Type is a form content type
+func IsForm(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == ContentTypeURLEncoded || mediaType == ContentTypeMultipart)
+}
+
+// Parse parses a form body into a map. A field with a single value maps to a string,
+// a repeated field to a slice of strings; multipart files map to File values.
+func Parse(body []byte, contentType string) (map[string]interface{}, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type: %w", err)
+	}
+
+	switch mediaType {
+	case ContentTypeURLEncoded:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid form: %w", err)
+		}
+		return normalize(values, nil), nil
+	case ContentTypeMultipart:
+		return parseMultipart(body, params["boundary"])
+	default:
+		return nil, fmt.Errorf("unsupported form content type: %s", mediaType)
+	}
+}
+
+// parseMultipart parses a multipart/form-data body
+func parseMultipart(body []byte, boundary string) (map[string]interface{}, error) {
+	if boundary == "" {
+		return nil, errors.New("invalid form: missing multipart boundary")
+	}
+
+	values := make(url.Values)
+	files := make(map[string][]File)
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid form: %w", err)
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid form: %w", err)
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			values.Add(name, string(data))
+			continue
+		}
+		files[name] = append(files[name], File{
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        len(data),
+			Content:     base64.StdEncoding.EncodeToString(data),
+		})
+	}
+
+	return normalize(values, files), nil
+}
+
+// normalize flattens single values and merges the files into the map
+func normalize(values url.Values, files map[string][]File) map[string]interface{} {
+	form := make(map[string]interface{}, len(values)+len(files))
+	for name, fieldValues := range values {
+		if len(fieldValues) == 1 {
+			form[name] = fieldValues[0]
+		} else {
+			form[name] = fieldValues
+		}
+	}
+	for name, fieldFiles := range files {
+		if len(fieldFiles) == 1 {
+			form[name] = fieldFiles[0]
+		} else {
+			form[name] = fieldFiles
+		}
+	}
+	return form
+}
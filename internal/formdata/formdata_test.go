@@ -0,0 +1,72 @@
+package formdata
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsForm(t *testing.T) {
+	assert.True(t, IsForm("application/x-www-form-urlencoded"))
+	assert.True(t, IsForm("application/x-www-form-urlencoded; charset=utf-8"))
+	assert.True(t, IsForm("multipart/form-data; boundary=abc"))
+	assert.False(t, IsForm("application/json"))
+	assert.False(t, IsForm(""))
+}
+
+func TestParseURLEncoded(t *testing.T) {
+	form, err := Parse([]byte("MessageSid=SM123&Body=hello+world&MediaUrl=a&MediaUrl=b"), "application/x-www-form-urlencoded")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"MessageSid": "SM123",
+		"Body":       "hello world",
+		"MediaUrl":   []string{"a", "b"},
+	}, form)
+}
+
+func TestParseURLEncodedInvalid(t *testing.T) {
+	_, err := Parse([]byte("a=%zz"), "application/x-www-form-urlencoded")
+	assert.Error(t, err)
+}
+
+func TestParseMultipart(t *testing.T) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	require.NoError(t, w.WriteField("recipient", "user@example.com"))
+	require.NoError(t, w.WriteField("subject", "Hello"))
+	file, err := w.CreateFormFile("attachment-1", "note.txt")
+	require.NoError(t, err)
+	_, err = file.Write([]byte("attached"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	form, err := Parse(buf.Bytes(), w.FormDataContentType())
+	require.NoError(t, err)
+
+	assert.Equal(t, "user@example.com", form["recipient"])
+	assert.Equal(t, "Hello", form["subject"])
+	assert.Equal(t, File{
+		Filename:    "note.txt",
+		ContentType: "application/octet-stream",
+		Size:        8,
+		Content:     base64.StdEncoding.EncodeToString([]byte("attached")),
+	}, form["attachment-1"])
+}
+
+func TestParseMultipartInvalid(t *testing.T) {
+	_, err := Parse([]byte("data"), "multipart/form-data")
+	assert.Error(t, err, "missing boundary")
+
+	_, err = Parse([]byte("--abc\r\nbroken"), "multipart/form-data; boundary=abc")
+	assert.Error(t, err)
+}
+
+func TestParseUnsupportedContentType(t *testing.T) {
+	_, err := Parse([]byte(`{}`), "application/json")
+	assert.Error(t, err)
+}
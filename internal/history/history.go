@@ -0,0 +1,169 @@
+// Package history keeps the most recent webhooks in memory, with the results of their
+// deliveries, so that operators can look them up and replay them once a destination that
+// was briefly down is back
+package history
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+)
+
+// maxResults bounds the attempts kept per webhook, the latest ones being kept
+const maxResults = 50
+
+// Result is the outcome of an attempt to deliver a webhook to a destination
+type Result struct {
+	Destination string        `json:"destination"`
+	Attempt     int           `json:"attempt"`
+	StatusCode  int           `json:"status_code,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Duration    time.Duration `json:"duration_ns"`
+	At          time.Time     `json:"at"`
+
+	// Headers are the response headers captured by the destination's capture_headers
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Labels are the labels of the destination
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Replay is set for the attempts of a replay
+	Replay bool `json:"replay,omitempty"`
+}
+
+// Summary describes a webhook of the history, without its body and headers
+type Summary struct {
+	ID         string    `json:"id"`
+	Endpoint   string    `json:"endpoint"`
+	ReceivedAt time.Time `json:"received_at"`
+	BodySize   int       `json:"body_size"`
+	Results    []Result  `json:"results"`
+
+	// Labels are the labels of the endpoint, set by the server
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Entry is a webhook of the history with the results of its deliveries
+type Entry struct {
+	Webhook *webhook.Delivery `json:"webhook"`
+	Results []Result          `json:"results"`
+
+	// Labels are the labels of the endpoint, set by the server
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Store keeps the last webhooks accepted by the endpoints in a ring buffer, the oldest
+// being dropped once it is full. It is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	entries []*Entry // ring buffer, next is the slot of the next webhook
+	next    int
+	byID    map[string]*Entry
+}
+
+// New returns a store keeping the given number of webhooks
+func New(size int) *Store {
+	return &Store{
+		entries: make([]*Entry, size),
+		byID:    make(map[string]*Entry, size),
+	}
+}
+
+// Add keeps a webhook accepted by an endpoint, dropping the oldest one when full. The
+// webhook must not be modified afterwards, as for its forwarding.
+func (s *Store) Add(delivery *webhook.Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) == 0 {
+		return
+	}
+	if oldest := s.entries[s.next]; oldest != nil {
+		delete(s.byID, oldest.Webhook.ID)
+	}
+	entry := &Entry{Webhook: delivery}
+	s.entries[s.next] = entry
+	s.byID[delivery.ID] = entry
+	s.next = (s.next + 1) % len(s.entries)
+}
+
+// Record adds the result of an attempt to the webhook with the given ID, unless it was
+// already dropped from the history
+func (s *Store) Record(id string, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.byID[id]
+	if !ok {
+		return
+	}
+	if len(entry.Results) == maxResults {
+		entry.Results = append(entry.Results[:0], entry.Results[1:]...)
+	}
+	entry.Results = append(entry.Results, result)
+}
+
+// List returns the webhooks of the history, newest first, received by the given endpoint
+// or by any endpoint when empty
+func (s *Store) List(endpoint string) []Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summaries := make([]Summary, 0, len(s.byID))
+	for i := 1; i <= len(s.entries); i++ {
+		entry := s.entries[(s.next-i+len(s.entries))%len(s.entries)]
+		if entry == nil {
+			break
+		}
+		if endpoint != "" && entry.Webhook.Endpoint != endpoint {
+			continue
+		}
+		summaries = append(summaries, Summary{
+			ID:         entry.Webhook.ID,
+			Endpoint:   entry.Webhook.Endpoint,
+			ReceivedAt: entry.Webhook.ReceivedAt,
+			BodySize:   len(entry.Webhook.Body),
+			Results:    append([]Result{}, entry.Results...),
+		})
+	}
+	return summaries
+}
+
+// Get returns the webhook with the given ID and the results of its deliveries
+func (s *Store) Get(id string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.byID[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return Entry{Webhook: entry.Webhook, Results: append([]Result{}, entry.Results...)}, true
+}
+
+// tag identifies the webhook a delivery belongs to, in its context
+type tag struct {
+	id     string
+	replay bool
+}
+
+// tagKey is the context key of the webhook a delivery belongs to
+type tagKey struct{}
+
+// WithWebhook returns a context whose deliveries record their results on the webhook with
+// the given ID, as replays when replay is set
+func WithWebhook(ctx context.Context, id string, replay bool) context.Context {
+	return context.WithValue(ctx, tagKey{}, tag{id: id, replay: replay})
+}
+
+// FromContext returns the ID of the webhook a delivery belongs to, and whether it is a
+// replay, as set by WithWebhook
+func FromContext(ctx context.Context) (id string, replay bool, ok bool) {
+	if ctx == nil {
+		return "", false, false
+	}
+	t, ok := ctx.Value(tagKey{}).(tag)
+	return t.id, t.replay, ok
+}
@@ -0,0 +1,72 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	store := New(2)
+	first := webhook.New("/github", []byte(`{"n":1}`), nil)
+	second := webhook.New("/stripe", []byte(`{"n":2}`), nil)
+	store.Add(first)
+	store.Add(second)
+
+	store.Record(first.ID, Result{Destination: "https://example.com", Attempt: 1, StatusCode: 503})
+	store.Record("unknown", Result{Destination: "https://example.com", Attempt: 1})
+
+	summaries := store.List("")
+	require.Len(t, summaries, 2)
+	assert.Equal(t, second.ID, summaries[0].ID, "newest first")
+	assert.Equal(t, first.ID, summaries[1].ID)
+	assert.Equal(t, 7, summaries[1].BodySize)
+	assert.Equal(t, 503, summaries[1].Results[0].StatusCode)
+
+	summaries = store.List("/github")
+	require.Len(t, summaries, 1)
+	assert.Equal(t, first.ID, summaries[0].ID)
+
+	entry, ok := store.Get(first.ID)
+	require.True(t, ok)
+	assert.Equal(t, first, entry.Webhook)
+	assert.Len(t, entry.Results, 1)
+
+	// The oldest webhook is dropped once the history is full
+	third := webhook.New("/github", nil, nil)
+	store.Add(third)
+	_, ok = store.Get(first.ID)
+	assert.False(t, ok)
+	summaries = store.List("")
+	require.Len(t, summaries, 2)
+	assert.Equal(t, third.ID, summaries[0].ID)
+	assert.Equal(t, second.ID, summaries[1].ID)
+}
+
+func TestRecordKeepsLatestResults(t *testing.T) {
+	store := New(1)
+	delivery := webhook.New("/github", nil, nil)
+	store.Add(delivery)
+
+	for attempt := 1; attempt <= maxResults+5; attempt++ {
+		store.Record(delivery.ID, Result{Attempt: attempt})
+	}
+
+	entry, _ := store.Get(delivery.ID)
+	require.Len(t, entry.Results, maxResults)
+	assert.Equal(t, 6, entry.Results[0].Attempt)
+	assert.Equal(t, maxResults+5, entry.Results[maxResults-1].Attempt)
+}
+
+func TestWithWebhook(t *testing.T) {
+	_, _, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	id, replay, ok := FromContext(WithWebhook(context.Background(), "abc", true))
+	assert.True(t, ok)
+	assert.Equal(t, "abc", id)
+	assert.True(t, replay)
+}
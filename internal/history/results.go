@@ -0,0 +1,140 @@
+package history
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// States of a delivery to a destination
+const (
+	StateInProgress = "in_progress"
+	StateRetrying   = "retrying"
+	StateDelivered  = "delivered"
+	StateFailed     = "failed"
+)
+
+// Overall states of a webhook, besides the states of its deliveries
+const (
+	// StateAccepted is the state of a webhook no delivery was started for yet
+	StateAccepted = "accepted"
+)
+
+// DestinationStatus is the state of the delivery of a webhook to a destination
+type DestinationStatus struct {
+	State      string    `json:"state"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Status is what happened to a webhook: the state of its delivery to each destination,
+// by destination, without its body, headers or errors, so that it can be shown to senders
+type Status struct {
+	ID           string                       `json:"id"`
+	Endpoint     string                       `json:"endpoint"`
+	ReceivedAt   time.Time                    `json:"received_at"`
+	State        string                       `json:"state"`
+	Destinations map[string]DestinationStatus `json:"destinations"`
+}
+
+// Results keeps the delivery results of the most recently used webhooks, the least
+// recently accepted, updated or looked up being dropped once it is full. It is safe for
+// concurrent use.
+type Results struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // of *Status, most recently used first
+	byID  map[string]*list.Element
+}
+
+// NewResults returns a cache keeping the results of the given number of webhooks
+func NewResults(size int) *Results {
+	return &Results{
+		size:  size,
+		order: list.New(),
+		byID:  make(map[string]*list.Element, size),
+	}
+}
+
+// Add starts tracking a webhook accepted by an endpoint
+func (r *Results) Add(id, endpoint string, receivedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size <= 0 {
+		return
+	}
+	if element, ok := r.byID[id]; ok {
+		r.order.MoveToFront(element)
+		return
+	}
+	r.byID[id] = r.order.PushFront(&Status{
+		ID:           id,
+		Endpoint:     endpoint,
+		ReceivedAt:   receivedAt,
+		Destinations: make(map[string]DestinationStatus),
+	})
+	if r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.byID, oldest.Value.(*Status).ID)
+	}
+}
+
+// Update sets the state of the delivery of a tracked webhook to a destination, after the
+// given attempt. Webhooks no longer tracked are ignored.
+func (r *Results) Update(id, destination, state string, attempt, statusCode int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.byID[id]
+	if !ok {
+		return
+	}
+	r.order.MoveToFront(element)
+	element.Value.(*Status).Destinations[destination] = DestinationStatus{
+		State:      state,
+		Attempts:   attempt,
+		StatusCode: statusCode,
+		UpdatedAt:  time.Now(),
+	}
+}
+
+// Get returns the results of a tracked webhook
+func (r *Results) Get(id string) (Status, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.byID[id]
+	if !ok {
+		return Status{}, false
+	}
+	r.order.MoveToFront(element)
+
+	status := *element.Value.(*Status)
+	status.Destinations = make(map[string]DestinationStatus, len(status.Destinations))
+	for destination, result := range element.Value.(*Status).Destinations {
+		status.Destinations[destination] = result
+	}
+	status.State = overallState(status.Destinations)
+	return status, true
+}
+
+// overallState returns the state of a webhook: accepted before any delivery, in progress
+// while a delivery is, delivered once every delivery is, and failed otherwise
+func overallState(destinations map[string]DestinationStatus) string {
+	if len(destinations) == 0 {
+		return StateAccepted
+	}
+	state := StateDelivered
+	for _, result := range destinations {
+		switch result.State {
+		case StateInProgress, StateRetrying:
+			return StateInProgress
+		case StateFailed:
+			state = StateFailed
+		}
+	}
+	return state
+}
@@ -0,0 +1,61 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResults(t *testing.T) {
+	results := NewResults(2)
+	results.Add("a", "/github", time.Now())
+
+	status, ok := results.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, "/github", status.Endpoint)
+	assert.Equal(t, StateAccepted, status.State)
+
+	results.Update("a", "https://one.example.com", StateRetrying, 1, 503)
+	results.Update("a", "https://two.example.com", StateDelivered, 1, 200)
+	status, _ = results.Get("a")
+	assert.Equal(t, StateInProgress, status.State)
+	assert.Equal(t, DestinationStatus{State: StateRetrying, Attempts: 1, StatusCode: 503, UpdatedAt: status.Destinations["https://one.example.com"].UpdatedAt},
+		status.Destinations["https://one.example.com"])
+
+	results.Update("a", "https://one.example.com", StateFailed, 3, 503)
+	status, _ = results.Get("a")
+	assert.Equal(t, StateFailed, status.State)
+
+	results.Update("a", "https://one.example.com", StateDelivered, 1, 200)
+	status, _ = results.Get("a")
+	assert.Equal(t, StateDelivered, status.State)
+
+	// Returned results are copies
+	status.Destinations["https://one.example.com"] = DestinationStatus{}
+	status, _ = results.Get("a")
+	assert.Equal(t, StateDelivered, status.Destinations["https://one.example.com"].State)
+
+	results.Update("unknown", "https://one.example.com", StateDelivered, 1, 200)
+	_, ok = results.Get("unknown")
+	assert.False(t, ok)
+}
+
+func TestResultsEvictsLeastRecentlyUsed(t *testing.T) {
+	results := NewResults(2)
+	results.Add("a", "/github", time.Now())
+	results.Add("b", "/github", time.Now())
+
+	// Looking a webhook up keeps it
+	_, ok := results.Get("a")
+	require.True(t, ok)
+	results.Add("c", "/github", time.Now())
+
+	_, ok = results.Get("b")
+	assert.False(t, ok)
+	_, ok = results.Get("a")
+	assert.True(t, ok)
+	_, ok = results.Get("c")
+	assert.True(t, ok)
+}
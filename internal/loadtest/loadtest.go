@@ -0,0 +1,207 @@
+// Package loadtest drives a running proxy with webhooks sent at a constant rate and reports
+// the achieved throughput, latency percentiles and error rate, to size deployments
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMaxInFlight bounds the requests waiting for a response; once reached, the rate drops
+const DefaultMaxInFlight = 1000
+
+// DefaultTimeout bounds the time a request may take
+const DefaultTimeout = 10 * time.Second
+
+// Config represents a load test: Rate webhooks per second are posted to URL for Duration
+type Config struct {
+	URL         string
+	Rate        int
+	Duration    time.Duration
+	Body        []byte
+	Headers     map[string]string
+	MaxInFlight int
+	Timeout     time.Duration
+}
+
+// Report is the outcome of a load test
+type Report struct {
+	// Sent counts the requests sent, Succeeded those answered with a 2xx status
+	Sent      int
+	Succeeded int
+
+	// Statuses counts the responses by status code, Errors the requests without a response by error
+	Statuses map[int]int
+	Errors   map[string]int
+
+	// Elapsed is the time from the first request to the last response
+	Elapsed time.Duration
+
+	// latencies are the durations of the answered requests, sorted
+	latencies []time.Duration
+}
+
+// Throughput returns the requests completed per second
+func (r *Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Sent) / r.Elapsed.Seconds()
+}
+
+// ErrorRate returns the share of the requests that were not answered with a 2xx status
+func (r *Report) ErrorRate() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	return float64(r.Sent-r.Succeeded) / float64(r.Sent)
+}
+
+// Percentile returns the latency under which the given percentage of the answered requests
+// completed, using the nearest rank
+func (r *Report) Percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+
+	rank := int(p/100*float64(len(r.latencies))+0.5) - 1
+	rank = max(0, min(rank, len(r.latencies)-1))
+	return r.latencies[rank]
+}
+
+// Write prints the report
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "Requests:   %d sent in %s, %.1f/s\n", r.Sent, r.Elapsed.Round(time.Millisecond), r.Throughput())
+	fmt.Fprintf(w, "Succeeded:  %d (%.2f%% errors)\n", r.Succeeded, r.ErrorRate()*100)
+	if len(r.latencies) > 0 {
+		fmt.Fprintf(w, "Latency:    p50 %s, p90 %s, p99 %s, max %s\n",
+			r.Percentile(50), r.Percentile(90), r.Percentile(99), r.latencies[len(r.latencies)-1])
+	}
+
+	codes := make([]int, 0, len(r.Statuses))
+	for code := range r.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "Status %d: %d\n", code, r.Statuses[code])
+	}
+
+	errs := make([]string, 0, len(r.Errors))
+	for err := range r.Errors {
+		errs = append(errs, err)
+	}
+	sort.Strings(errs)
+	for _, err := range errs {
+		fmt.Fprintf(w, "Error: %s: %d\n", err, r.Errors[err])
+	}
+}
+
+// Run posts webhooks until the duration elapses or the context is canceled, then waits for
+// the pending responses. The rate is a target: it drops once MaxInFlight requests are
+// waiting for a response, which the report's throughput shows. A nil client uses a client
+// keeping up to MaxInFlight idle connections, rather than opening one per request.
+func Run(ctx context.Context, cfg Config, client *http.Client) *Report {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = DefaultMaxInFlight
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = cfg.MaxInFlight
+		transport.MaxIdleConnsPerHost = cfg.MaxInFlight
+		client = &http.Client{Transport: transport}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	report := &Report{Statuses: make(map[int]int), Errors: make(map[string]int)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, cfg.MaxInFlight)
+
+	ticker := time.NewTicker(time.Second / time.Duration(cfg.Rate))
+	defer ticker.Stop()
+
+	start := time.Now()
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+			continue
+		case inFlight <- struct{}{}:
+		}
+
+		report.Sent++
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+
+			status, latency, err := send(client, cfg)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Errors[err.Error()]++
+				return
+			}
+			report.Statuses[status]++
+			report.latencies = append(report.latencies, latency)
+			if status >= 200 && status < 300 {
+				report.Succeeded++
+			}
+		}()
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	sort.Slice(report.latencies, func(i, j int) bool { return report.latencies[i] < report.latencies[j] })
+	return report
+}
+
+// send posts one webhook and returns its status code and latency. Requests are not bound to
+// the run's context, so that the requests sent before its end get their response.
+func send(client *http.Client, cfg Config) (int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(cfg.Body))
+	if err != nil {
+		return 0, 0, err
+	}
+	for name, value := range cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL is the same for every request; keep the cause only
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, 0, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp.StatusCode, time.Since(start), nil
+}
@@ -0,0 +1,95 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	var count atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"id":1}`, string(body))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		// Every fourth webhook is rejected
+		if count.Add(1)%4 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	report := Run(context.Background(), Config{
+		URL:      target.URL,
+		Rate:     100,
+		Duration: 300 * time.Millisecond,
+		Body:     []byte(`{"id":1}`),
+		Headers:  map[string]string{"Content-Type": "application/json"},
+	}, nil)
+
+	require.Greater(t, report.Sent, 10)
+	assert.Equal(t, int(count.Load()), report.Sent)
+	assert.Equal(t, report.Sent, report.Statuses[http.StatusAccepted]+report.Statuses[http.StatusServiceUnavailable])
+	assert.Equal(t, report.Statuses[http.StatusAccepted], report.Succeeded)
+	assert.Equal(t, report.Sent/4, report.Statuses[http.StatusServiceUnavailable])
+	assert.Empty(t, report.Errors)
+	assert.Positive(t, report.Throughput())
+	assert.Positive(t, report.Percentile(99))
+
+	var out bytes.Buffer
+	report.Write(&out)
+	assert.Contains(t, out.String(), "Latency:    p50 ")
+	assert.Contains(t, out.String(), "Status 202: ")
+	assert.Contains(t, out.String(), "Status 503: ")
+}
+
+func TestRunUnreachable(t *testing.T) {
+	target := httptest.NewServer(http.NotFoundHandler())
+	url := target.URL
+	target.Close()
+
+	report := Run(context.Background(), Config{URL: url, Rate: 50, Duration: 100 * time.Millisecond}, nil)
+
+	require.Positive(t, report.Sent)
+	assert.Zero(t, report.Succeeded)
+	assert.Equal(t, 1.0, report.ErrorRate())
+	assert.Len(t, report.Errors, 1)
+	assert.Zero(t, report.Percentile(50))
+}
+
+func TestRunCanceled(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	report := Run(ctx, Config{URL: target.URL, Rate: 50, Duration: time.Minute}, nil)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, report.Sent, report.Succeeded)
+}
+
+func TestPercentile(t *testing.T) {
+	report := &Report{}
+	for i := 1; i <= 100; i++ {
+		report.latencies = append(report.latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 50*time.Millisecond, report.Percentile(50))
+	assert.Equal(t, 90*time.Millisecond, report.Percentile(90))
+	assert.Equal(t, 99*time.Millisecond, report.Percentile(99))
+	assert.Equal(t, 100*time.Millisecond, report.Percentile(100))
+	assert.Equal(t, time.Millisecond, report.Percentile(0))
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"encoding/json"
+	"mime"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/formdata"
+	"github.com/flemzord/webhook-proxy/internal/redact"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+// LogRequestBody logs the body of a received webhook at debug level when request body logging
+// is enabled. Fields are masked in the body the delivery already parsed.
+func LogRequestBody(log logrus.FieldLogger, cfg config.BodyLoggingConfig, delivery *webhook.Delivery) {
+	if !cfg.Request {
+		return
+	}
+
+	fields := logrus.Fields{
+		"path":      delivery.Endpoint,
+		"body_size": len(delivery.Body),
+	}
+	masked, ok := maskBody(cfg, delivery.Body, delivery.ContentType(), delivery.JSONObject)
+	addBody(fields, cfg, masked, ok)
+	log.WithFields(fields).Debug("Webhook request body")
+}
+
+// LogResponseBody logs the body returned by a destination at debug level when response body
+// logging is enabled
+func LogResponseBody(log logrus.FieldLogger, cfg config.BodyLoggingConfig, destination string, statusCode int, attempt int, contentType string, body []byte) {
+	if !cfg.Response {
+		return
+	}
+
+	fields := logrus.Fields{
+		"destination": destination,
+		"status_code": statusCode,
+		"attempt":     attempt,
+		"body_size":   len(body),
+	}
+	masked, ok := maskBody(cfg, body, contentType, func() map[string]interface{} {
+		var payload map[string]interface{}
+		if json.Unmarshal(body, &payload) != nil {
+			return nil
+		}
+		return payload
+	})
+	addBody(fields, cfg, masked, ok)
+	log.WithFields(fields).Debug("Destination response body")
+}
+
+// maskBody masks the redacted fields of a body, a JSON object or a URL-encoded form. It
+// returns false for bodies of other formats when fields are redacted, as their fields
+// cannot be found and the body must not be logged.
+func maskBody(cfg config.BodyLoggingConfig, body []byte, contentType string, object func() map[string]interface{}) ([]byte, bool) {
+	if len(cfg.RedactFields) == 0 || len(body) == 0 {
+		return body, true
+	}
+
+	if payload := object(); payload != nil {
+		return redact.ParsedJSON(body, payload, cfg.RedactFields), true
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == formdata.ContentTypeURLEncoded {
+		return redact.Form(body, cfg.RedactFields)
+	}
+	return nil, false
+}
+
+// addBody adds the masked body, truncated to the maximum size, to the fields of a body log.
+// Bodies that could not be masked are left out.
+func addBody(fields logrus.Fields, cfg config.BodyLoggingConfig, masked []byte, ok bool) {
+	if !ok {
+		fields["body_omitted"] = true
+		return
+	}
+
+	body, truncated := truncateBody(cfg, masked)
+	fields["body"] = body
+	fields["truncated"] = truncated
+}
+
+// truncateBody truncates a masked body to the maximum size
+func truncateBody(cfg config.BodyLoggingConfig, masked []byte) (string, bool) {
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = config.DefaultBodyLogMaxSize
+	}
+
+	if len(masked) > maxSize {
+		return string(masked[:maxSize]), true
+	}
+	return string(masked), false
+}
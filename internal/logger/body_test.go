@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRequestBody(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.DebugLevel)
+
+	// Disabled by default
+	LogRequestBody(log, config.BodyLoggingConfig{}, webhook.New("/webhook", []byte(`{"event":"push"}`), nil))
+	assert.Empty(t, buf.String())
+
+	// Enabled with redaction
+	cfg := config.BodyLoggingConfig{Request: true, MaxSize: 1024, RedactFields: []string{"user.password"}}
+	delivery := webhook.New("/webhook", []byte(`{"event":"push","user":{"password":"secret"}}`), nil)
+	LogRequestBody(log, cfg, delivery)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Webhook request body", entry["msg"])
+	assert.Equal(t, "debug", entry["level"])
+	assert.Equal(t, "/webhook", entry["path"])
+	assert.JSONEq(t, `{"event":"push","user":{"password":"[REDACTED]"}}`, entry["body"].(string))
+	assert.Equal(t, false, entry["truncated"])
+
+	// The parsed body shared with the other readers of the delivery is not masked
+	assert.Equal(t, "secret", delivery.JSONObject()["user"].(map[string]interface{})["password"])
+
+	// Form fields are masked by name
+	buf.Reset()
+	cfg.RedactFields = []string{"password"}
+	LogRequestBody(log, cfg, webhook.New("/webhook", []byte(`event=push&password=secret`), map[string]string{"Content-Type": "application/x-www-form-urlencoded"}))
+	entry = nil
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "event=push&password=[REDACTED]", entry["body"])
+
+	// Bodies whose fields cannot be masked are left out
+	buf.Reset()
+	LogRequestBody(log, cfg, webhook.New("/webhook", []byte(`<event><password>secret</password></event>`), map[string]string{"Content-Type": "application/xml"}))
+	assert.NotContains(t, buf.String(), "secret")
+	entry = nil
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, true, entry["body_omitted"])
+	assert.Equal(t, float64(42), entry["body_size"])
+	assert.NotContains(t, entry, "body")
+
+	// Body logs are written at debug level
+	buf.Reset()
+	log.SetLevel(logrus.InfoLevel)
+	LogRequestBody(log, cfg, delivery)
+	assert.Empty(t, buf.String())
+}
+
+func TestLogResponseBody(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.DebugLevel)
+
+	// Disabled by default
+	LogResponseBody(log, config.BodyLoggingConfig{Request: true}, "https://example.com", 200, 1, "text/plain", []byte("ok"))
+	assert.Empty(t, buf.String())
+
+	// Bodies larger than the maximum size are truncated
+	cfg := config.BodyLoggingConfig{Response: true, MaxSize: 5}
+	LogResponseBody(log, cfg, "https://example.com", 500, 2, "text/plain", []byte("internal error"))
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Destination response body", entry["msg"])
+	assert.Equal(t, "https://example.com", entry["destination"])
+	assert.Equal(t, float64(500), entry["status_code"])
+	assert.Equal(t, float64(2), entry["attempt"])
+	assert.Equal(t, "inter", entry["body"])
+	assert.Equal(t, float64(14), entry["body_size"])
+	assert.Equal(t, true, entry["truncated"])
+
+	// JSON fields are masked, and bodies that cannot be masked are left out
+	cfg = config.BodyLoggingConfig{Response: true, RedactFields: []string{"token"}}
+	buf.Reset()
+	LogResponseBody(log, cfg, "https://example.com", 200, 1, "application/json", []byte(`{"token":"secret"}`))
+	entry = nil
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.JSONEq(t, `{"token":"[REDACTED]"}`, entry["body"].(string))
+
+	buf.Reset()
+	LogResponseBody(log, cfg, "https://example.com", 200, 1, "text/plain", []byte("token=secret"))
+	assert.NotContains(t, buf.String(), "secret")
+}
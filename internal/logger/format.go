@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ecsVersion is the Elastic Common Schema version the ecs format follows
+const ecsVersion = "8.11.0"
+
+// gcpLabelsKey is the field Google Cloud Logging reads entry labels from
+const gcpLabelsKey = "logging.googleapis.com/labels"
+
+// structuredFormatter writes entries as JSON objects using the field names
+// and level values expected by a log platform
+type structuredFormatter struct {
+	timeKey    string
+	levelKey   string
+	messageKey string
+	level      func(logrus.Level) string
+	labelsKey  string
+	labels     map[string]string
+	static     logrus.Fields
+}
+
+// newECSFormatter creates a formatter following the Elastic Common Schema
+func newECSFormatter(labels map[string]string) *structuredFormatter {
+	return &structuredFormatter{
+		timeKey:    "@timestamp",
+		levelKey:   "log.level",
+		messageKey: "message",
+		level:      func(level logrus.Level) string { return level.String() },
+		labelsKey:  "labels",
+		labels:     labels,
+		static:     logrus.Fields{"ecs.version": ecsVersion},
+	}
+}
+
+// newGCPFormatter creates a formatter following the Google Cloud Logging structured logging conventions
+func newGCPFormatter(labels map[string]string) *structuredFormatter {
+	return &structuredFormatter{
+		timeKey:    "timestamp",
+		levelKey:   "severity",
+		messageKey: "message",
+		level:      gcpSeverity,
+		labelsKey:  gcpLabelsKey,
+		labels:     labels,
+	}
+}
+
+// newJSONFormatter creates the json formatter, renaming the time, level and msg fields if configured
+func newJSONFormatter(fieldMap map[string]string) *logrus.JSONFormatter {
+	formatter := &logrus.JSONFormatter{}
+	if len(fieldMap) > 0 {
+		formatter.FieldMap = logrus.FieldMap{}
+		if name, ok := fieldMap["time"]; ok {
+			formatter.FieldMap[logrus.FieldKeyTime] = name
+		}
+		if name, ok := fieldMap["level"]; ok {
+			formatter.FieldMap[logrus.FieldKeyLevel] = name
+		}
+		if name, ok := fieldMap["msg"]; ok {
+			formatter.FieldMap[logrus.FieldKeyMsg] = name
+		}
+	}
+	return formatter
+}
+
+// Format renders the entry as a single JSON line
+func (f *structuredFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data)+len(f.static)+4)
+	for key, value := range entry.Data {
+		// Keep entry fields from overwriting the reserved fields
+		if key == f.timeKey || key == f.levelKey || key == f.messageKey || key == f.labelsKey {
+			key = "fields." + key
+		}
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		data[key] = value
+	}
+	for key, value := range f.static {
+		data[key] = value
+	}
+
+	data[f.timeKey] = entry.Time.Format(time.RFC3339Nano)
+	data[f.levelKey] = f.level(entry.Level)
+	data[f.messageKey] = entry.Message
+	if len(f.labels) > 0 {
+		data[f.labelsKey] = f.labels
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// gcpSeverity maps a logrus level to a Google Cloud Logging severity
+func gcpSeverity(level logrus.Level) string {
+	switch level {
+	case logrus.PanicLevel:
+		return "ALERT"
+	case logrus.FatalLevel:
+		return "CRITICAL"
+	case logrus.WarnLevel:
+		return "WARNING"
+	default:
+		return strings.ToUpper(level.String())
+	}
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// formatEntry configures a logger with the given config, logs one entry and decodes it
+func formatEntry(t *testing.T, cfg config.LoggingConfig, level logrus.Level, fields logrus.Fields) map[string]interface{} {
+	t.Helper()
+
+	log := logrus.New()
+	ConfigureLogger(log, cfg)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.WithFields(fields).Log(level, "Webhook received")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	return entry
+}
+
+func TestECSFormat(t *testing.T) {
+	entry := formatEntry(t, config.LoggingConfig{
+		Level:  "info",
+		Format: "ecs",
+		Output: "stdout",
+		Labels: map[string]string{"env": "production"},
+	}, logrus.WarnLevel, logrus.Fields{
+		"path":    "/webhook",
+		"error":   errors.New("timeout"),
+		"message": "clashing field",
+	})
+
+	assert.Contains(t, entry, "@timestamp")
+	assert.Equal(t, "warning", entry["log.level"])
+	assert.Equal(t, "Webhook received", entry["message"])
+	assert.Equal(t, ecsVersion, entry["ecs.version"])
+	assert.Equal(t, map[string]interface{}{"env": "production"}, entry["labels"])
+	assert.Equal(t, "/webhook", entry["path"])
+	assert.Equal(t, "timeout", entry["error"])
+	assert.Equal(t, "clashing field", entry["fields.message"])
+}
+
+func TestGCPFormat(t *testing.T) {
+	entry := formatEntry(t, config.LoggingConfig{
+		Level:  "info",
+		Format: "gcp",
+		Output: "stdout",
+		Labels: map[string]string{"env": "production"},
+	}, logrus.ErrorLevel, logrus.Fields{"path": "/webhook"})
+
+	assert.Contains(t, entry, "timestamp")
+	assert.Equal(t, "ERROR", entry["severity"])
+	assert.Equal(t, "Webhook received", entry["message"])
+	assert.Equal(t, map[string]interface{}{"env": "production"}, entry[gcpLabelsKey])
+	assert.Equal(t, "/webhook", entry["path"])
+}
+
+func TestGCPSeverity(t *testing.T) {
+	assert.Equal(t, "DEBUG", gcpSeverity(logrus.DebugLevel))
+	assert.Equal(t, "INFO", gcpSeverity(logrus.InfoLevel))
+	assert.Equal(t, "WARNING", gcpSeverity(logrus.WarnLevel))
+	assert.Equal(t, "ERROR", gcpSeverity(logrus.ErrorLevel))
+	assert.Equal(t, "CRITICAL", gcpSeverity(logrus.FatalLevel))
+}
+
+func TestJSONFormatFieldMap(t *testing.T) {
+	entry := formatEntry(t, config.LoggingConfig{
+		Level:    "info",
+		Format:   "json",
+		Output:   "stdout",
+		FieldMap: map[string]string{"time": "ts", "msg": "message"},
+	}, logrus.InfoLevel, nil)
+
+	assert.Contains(t, entry, "ts")
+	assert.Equal(t, "Webhook received", entry["message"])
+	assert.Equal(t, "info", entry["level"])
+	assert.NotContains(t, entry, "msg")
+}
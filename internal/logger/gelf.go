@@ -0,0 +1,247 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// gelfVersion is the GELF specification version of the messages
+	gelfVersion = "1.1"
+
+	// gelfChunkSize is the maximum size of a UDP datagram, chunk header included
+	gelfChunkSize = 8192
+
+	// gelfChunkHeaderSize is the size of the magic bytes, message ID, sequence number and count
+	gelfChunkHeaderSize = 12
+
+	// gelfMaxChunks is the maximum number of chunks of a message
+	gelfMaxChunks = 128
+
+	// gelfWriteTimeout is the maximum time allowed to write a message
+	gelfWriteTimeout = 5 * time.Second
+)
+
+// gelfInvalidFieldChars matches the characters not allowed in additional field names
+var gelfInvalidFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+// gelfHook is a logrus hook shipping every entry to Graylog as a GELF message.
+// Entries are built from their fields, independently of the logger's formatter.
+type gelfHook struct {
+	network     string
+	address     string
+	compression string
+	hostname    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newGELFHook creates a GELF hook and connects to the Graylog input
+func newGELFHook(cfg config.GELFConfig) (*gelfHook, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	hook := &gelfHook{
+		network:     cfg.Network,
+		address:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		compression: cfg.Compression,
+		hostname:    hostname,
+	}
+
+	if err := hook.connect(); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+// Levels returns the levels the hook fires for
+func (h *gelfHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire builds a GELF message from the entry and sends it to Graylog
+func (h *gelfHook) Fire(entry *logrus.Entry) error {
+	payload, err := h.encode(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Reconnect once if the connection was lost (e.g. Graylog restart)
+	if err := h.write(payload); err != nil {
+		if err := h.connect(); err != nil {
+			return err
+		}
+		return h.write(payload)
+	}
+
+	return nil
+}
+
+// Close closes the connection to Graylog
+func (h *gelfHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
+
+// connect opens the connection to the Graylog input
+func (h *gelfHook) connect() error {
+	if h.conn != nil {
+		_ = h.conn.Close()
+		h.conn = nil
+	}
+
+	conn, err := net.DialTimeout(h.network, h.address, gelfWriteTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to graylog: %w", err)
+	}
+	h.conn = conn
+	return nil
+}
+
+// encode builds the GELF message of an entry, compressed for UDP if configured
+func (h *gelfHook) encode(entry *logrus.Entry) ([]byte, error) {
+	message := map[string]interface{}{
+		"version":       gelfVersion,
+		"host":          h.hostname,
+		"short_message": entry.Message,
+		"timestamp":     float64(entry.Time.UnixNano()) / float64(time.Second),
+		"level":         syslogSeverity(entry.Level),
+	}
+	for key, value := range entry.Data {
+		message[gelfFieldName(key)] = gelfFieldValue(value)
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode gelf message: %w", err)
+	}
+
+	var buf bytes.Buffer
+	switch h.compression {
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write(data)
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case "zlib":
+		w := zlib.NewWriter(&buf)
+		_, _ = w.Write(data)
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return data, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// write sends a message on the current connection
+func (h *gelfHook) write(payload []byte) error {
+	if h.conn == nil {
+		return fmt.Errorf("not connected to graylog")
+	}
+
+	if err := h.conn.SetWriteDeadline(time.Now().Add(gelfWriteTimeout)); err != nil {
+		return err
+	}
+
+	// TCP messages are delimited by a null byte
+	if h.network == "tcp" {
+		_, err := h.conn.Write(append(payload, 0))
+		return err
+	}
+
+	if len(payload) <= gelfChunkSize {
+		_, err := h.conn.Write(payload)
+		return err
+	}
+
+	return h.writeChunks(payload)
+}
+
+// writeChunks splits a message too large for a single datagram into GELF chunks
+func (h *gelfHook) writeChunks(payload []byte) error {
+	size := gelfChunkSize - gelfChunkHeaderSize
+	count := (len(payload) + size - 1) / size
+	if count > gelfMaxChunks {
+		return fmt.Errorf("gelf message too large: %d bytes", len(payload))
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 0, gelfChunkSize)
+	for i := 0; i < count; i++ {
+		end := min((i+1)*size, len(payload))
+
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, payload[i*size:end]...)
+		if _, err := h.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gelfFieldName returns the additional field name of an entry field.
+// The id field is reserved by GELF and is renamed like other clashing fields.
+func gelfFieldName(key string) string {
+	key = gelfInvalidFieldChars.ReplaceAllString(key, "_")
+	if key == "id" {
+		return "_fields.id"
+	}
+	return "_" + key
+}
+
+// gelfFieldValue converts an entry field to a GELF value, which must be a string or a number
+func gelfFieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
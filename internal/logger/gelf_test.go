@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGELFTestLogger configures a logger shipping GELF messages to the given address
+func newGELFTestLogger(t *testing.T, network string, addr net.Addr, compression string) *logrus.Logger {
+	host, port, err := net.SplitHostPort(addr.String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	log := logrus.New()
+	ConfigureLogger(log, config.LoggingConfig{
+		Level:  "info",
+		Format: "json",
+		Output: "gelf",
+		GELF: config.GELFConfig{
+			Network:     network,
+			Host:        host,
+			Port:        portNumber,
+			Compression: compression,
+		},
+	})
+	t.Cleanup(func() { log.ReplaceHooks(make(logrus.LevelHooks)) })
+	return log
+}
+
+// readDatagram reads a single datagram from the connection
+func readDatagram(t *testing.T, conn net.PacketConn) []byte {
+	buf := make([]byte, 65536)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return buf[:n]
+}
+
+func TestGELFHookUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	log := newGELFTestLogger(t, "udp", conn.LocalAddr(), "none")
+	log.WithFields(logrus.Fields{
+		"destination": "https://example.com",
+		"status_code": 502,
+		"id":          "abc",
+		"error":       errors.New("bad gateway"),
+	}).Error("Webhook delivery failed")
+
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal(readDatagram(t, conn), &message))
+
+	assert.Equal(t, "1.1", message["version"])
+	assert.Equal(t, "Webhook delivery failed", message["short_message"])
+	assert.Equal(t, float64(3), message["level"])
+	assert.NotEmpty(t, message["host"])
+	assert.NotZero(t, message["timestamp"])
+	assert.Equal(t, "https://example.com", message["_destination"])
+	assert.Equal(t, float64(502), message["_status_code"])
+	assert.Equal(t, "abc", message["_fields.id"])
+	assert.Equal(t, "bad gateway", message["_error"])
+	assert.NotContains(t, message, "_id")
+}
+
+func TestGELFHookUDPGzip(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	log := newGELFTestLogger(t, "udp", conn.LocalAddr(), "gzip")
+	log.Info("Compressed entry")
+
+	reader, err := gzip.NewReader(bytes.NewReader(readDatagram(t, conn)))
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &message))
+	assert.Equal(t, "Compressed entry", message["short_message"])
+	assert.Equal(t, float64(6), message["level"])
+}
+
+func TestGELFHookUDPChunking(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	log := newGELFTestLogger(t, "udp", conn.LocalAddr(), "none")
+	large := strings.Repeat("x", 3*gelfChunkSize)
+	log.WithField("body", large).Info("Large entry")
+
+	// Reassemble the chunks
+	var payload []byte
+	var id []byte
+	for i := 0; ; i++ {
+		chunk := readDatagram(t, conn)
+		require.LessOrEqual(t, len(chunk), gelfChunkSize)
+		require.Equal(t, []byte{0x1e, 0x0f}, chunk[:2])
+		if id == nil {
+			id = chunk[2:10]
+		}
+		assert.Equal(t, id, chunk[2:10])
+		assert.Equal(t, byte(i), chunk[10])
+
+		payload = append(payload, chunk[gelfChunkHeaderSize:]...)
+		if int(chunk[11]) == i+1 {
+			break
+		}
+	}
+
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload, &message))
+	assert.Equal(t, "Large entry", message["short_message"])
+	assert.Equal(t, large, message["_body"])
+}
+
+func TestGELFHookTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			return
+		}
+		defer conn.Close()
+
+		frame, readErr := bufio.NewReader(conn).ReadBytes(0)
+		if readErr != nil {
+			return
+		}
+		received <- frame
+	}()
+
+	log := newGELFTestLogger(t, "tcp", listener.Addr(), "none")
+	log.Warn("TCP entry")
+
+	select {
+	case frame := <-received:
+		var message map[string]interface{}
+		require.NoError(t, json.Unmarshal(frame[:len(frame)-1], &message))
+		assert.Equal(t, "TCP entry", message["short_message"])
+		assert.Equal(t, float64(4), message["level"])
+	case <-time.After(time.Second):
+		t.Fatal("No GELF message received")
+	}
+}
+
+func TestGELFHookConnectionFailure(t *testing.T) {
+	// Closed listener, so that the connection is refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr()
+	listener.Close()
+
+	var buf bytes.Buffer
+	log := newGELFTestLogger(t, "tcp", addr, "none")
+	log.SetOutput(&buf)
+	log.Info("Fallback entry")
+
+	assert.Contains(t, buf.String(), "Fallback entry")
+}
+
+func TestGELFFieldValue(t *testing.T) {
+	assert.Equal(t, "text", gelfFieldValue("text"))
+	assert.Equal(t, 42, gelfFieldValue(42))
+	assert.Equal(t, "1.5s", gelfFieldValue(1500*time.Millisecond))
+	assert.Equal(t, "failure", gelfFieldValue(errors.New("failure")))
+	assert.Equal(t, "true", gelfFieldValue(true))
+	assert.Equal(t, "[200,502]", gelfFieldValue([]int{200, 502}))
+}
+
+func TestGELFFieldName(t *testing.T) {
+	assert.Equal(t, "_status_code", gelfFieldName("status_code"))
+	assert.Equal(t, "_http.method", gelfFieldName("http.method"))
+	assert.Equal(t, "_remote_addr", gelfFieldName("remote addr"))
+	assert.Equal(t, "_fields.id", gelfFieldName("id"))
+}
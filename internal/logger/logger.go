@@ -3,8 +3,10 @@ package logger
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/pkg/logging"
 	"github.com/sirupsen/logrus"
 )
 
@@ -36,19 +38,45 @@ func ConfigureLogger(log *logrus.Logger, cfg config.LoggingConfig) {
 	// Configure log format
 	switch cfg.Format {
 	case "json":
-		log.SetFormatter(&logrus.JSONFormatter{})
+		log.SetFormatter(newJSONFormatter(cfg.FieldMap))
 	case "text":
 		log.SetFormatter(&logrus.TextFormatter{
 			FullTimestamp: true,
 		})
+	case "ecs":
+		log.SetFormatter(newECSFormatter(cfg.Labels))
+	case "gcp":
+		log.SetFormatter(newGCPFormatter(cfg.Labels))
+	case "pretty":
+		log.SetFormatter(newPrettyFormatter())
 	default:
 		log.SetFormatter(&logrus.JSONFormatter{})
 	}
 
+	// Remove hooks installed by a previous configuration, keeping slog handlers
+	// added by embedders
+	kept := make(map[logrus.Hook]bool)
+	for _, hooks := range log.ReplaceHooks(make(logrus.LevelHooks)) {
+		for _, hook := range hooks {
+			if logging.IsSlogHook(hook) {
+				if !kept[hook] {
+					kept[hook] = true
+					log.AddHook(hook)
+				}
+				continue
+			}
+			if closer, ok := hook.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+	}
+
 	// Configure log output
 	switch cfg.Output {
 	case "stdout":
 		log.SetOutput(os.Stdout)
+	case "stderr":
+		log.SetOutput(os.Stderr)
 	case "file":
 		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 		if err != nil {
@@ -57,8 +85,39 @@ func ConfigureLogger(log *logrus.Logger, cfg config.LoggingConfig) {
 				"path":  cfg.FilePath,
 			}).Error("Failed to open log file, using stdout instead")
 			log.SetOutput(os.Stdout)
-		} else {
+		} else if cfg.MirrorToStdout() {
 			log.SetOutput(io.MultiWriter(os.Stdout, file))
+		} else {
+			log.SetOutput(file)
+		}
+	case "syslog":
+		hook, err := newSyslogHook(cfg.Syslog)
+		if err != nil {
+			log.SetOutput(os.Stdout)
+			log.WithFields(logrus.Fields{
+				"error":   err,
+				"network": cfg.Syslog.Network,
+				"address": cfg.Syslog.Address,
+			}).Error("Failed to connect to syslog, using stdout instead")
+		} else {
+			// Entries are only written by the syslog hook
+			log.SetOutput(io.Discard)
+			log.AddHook(hook)
+		}
+	case "gelf":
+		hook, err := newGELFHook(cfg.GELF)
+		if err != nil {
+			log.SetOutput(os.Stdout)
+			log.WithFields(logrus.Fields{
+				"error":   err,
+				"network": cfg.GELF.Network,
+				"host":    cfg.GELF.Host,
+				"port":    cfg.GELF.Port,
+			}).Error("Failed to connect to Graylog, using stdout instead")
+		} else {
+			// Entries are only written by the GELF hook
+			log.SetOutput(io.Discard)
+			log.AddHook(hook)
 		}
 	default:
 		log.SetOutput(os.Stdout)
@@ -66,7 +125,7 @@ func ConfigureLogger(log *logrus.Logger, cfg config.LoggingConfig) {
 }
 
 // LogWebhookReceived logs information about a received webhook
-func LogWebhookReceived(log *logrus.Logger, path string, method string, remoteAddr string, contentLength int64) {
+func LogWebhookReceived(log logrus.FieldLogger, path string, method string, remoteAddr string, contentLength int64) {
 	log.WithFields(logrus.Fields{
 		"path":           path,
 		"method":         method,
@@ -75,21 +134,44 @@ func LogWebhookReceived(log *logrus.Logger, path string, method string, remoteAd
 	}).Info("Webhook received")
 }
 
-// LogWebhookForwarded logs information about a forwarded webhook
-func LogWebhookForwarded(log *logrus.Logger, destination string, statusCode int, duration float64) {
-	log.WithFields(logrus.Fields{
-		"destination": destination,
-		"status_code": statusCode,
-		"duration_ms": duration,
-	}).Info("Webhook forwarded")
+// Delivery outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// DeliveryAttempt describes a single attempt to deliver a webhook to a destination
+type DeliveryAttempt struct {
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"status_code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
 }
 
-// LogWebhookError logs information about a webhook forwarding error
-func LogWebhookError(log *logrus.Logger, destination string, err error, attempt int, maxAttempts int) {
-	log.WithFields(logrus.Fields{
-		"destination":  destination,
-		"error":        err,
-		"attempt":      attempt,
-		"max_attempts": maxAttempts,
-	}).Error("Webhook forwarding failed")
+// LogDeliveryCompleted logs a single summary entry for a completed delivery, including every attempt
+func LogDeliveryCompleted(log logrus.FieldLogger, endpoint string, destination string, attempts []DeliveryAttempt, duration time.Duration, outcome string) {
+	statusCodes := make([]int, 0, len(attempts))
+	for _, attempt := range attempts {
+		statusCodes = append(statusCodes, attempt.StatusCode)
+	}
+
+	entry := log.WithFields(logrus.Fields{
+		"endpoint":        endpoint,
+		"destination":     destination,
+		"attempts":        len(attempts),
+		"status_codes":    statusCodes,
+		"attempt_history": attempts,
+		"duration_ms":     duration.Milliseconds(),
+		"outcome":         outcome,
+	})
+
+	if outcome == OutcomeSuccess {
+		entry.Info("Webhook delivery completed")
+		return
+	}
+
+	if len(attempts) > 0 {
+		entry = entry.WithField("error", attempts[len(attempts)-1].Error)
+	}
+	entry.Error("Webhook delivery failed")
 }
@@ -3,15 +3,18 @@ package logger
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/pkg/logging"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewLogger(t *testing.T) {
@@ -95,6 +98,21 @@ func TestConfigureLoggerFormat(t *testing.T) {
 			format:         "text",
 			expectedFormat: &logrus.TextFormatter{},
 		},
+		{
+			name:           "ECS format",
+			format:         "ecs",
+			expectedFormat: &structuredFormatter{},
+		},
+		{
+			name:           "GCP format",
+			format:         "gcp",
+			expectedFormat: &structuredFormatter{},
+		},
+		{
+			name:           "Pretty format",
+			format:         "pretty",
+			expectedFormat: &prettyFormatter{},
+		},
 		{
 			name:           "Invalid format defaults to JSON",
 			format:         "invalid",
@@ -139,6 +157,42 @@ func TestConfigureLoggerOutput(t *testing.T) {
 		assert.NotNil(t, log.Out)
 	})
 
+	// Test stderr output
+	t.Run("Stderr output", func(t *testing.T) {
+		log := logrus.New()
+
+		cfg := config.LoggingConfig{
+			Level:  "info",
+			Format: "json",
+			Output: "stderr",
+		}
+		ConfigureLogger(log, cfg)
+
+		assert.Equal(t, os.Stderr, log.Out)
+	})
+
+	// Test syslog output with unreachable server
+	t.Run("Syslog output with unreachable server", func(t *testing.T) {
+		log := logrus.New()
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+
+		cfg := config.LoggingConfig{
+			Level:  "info",
+			Format: "json",
+			Output: "syslog",
+			Syslog: config.SyslogConfig{
+				Network: "unixgram",
+				Address: filepath.Join(t.TempDir(), "missing.sock"),
+			},
+		}
+		ConfigureLogger(log, cfg)
+
+		// The logger should fall back to stdout without syslog hooks
+		assert.Equal(t, os.Stdout, log.Out)
+		assert.Empty(t, log.Hooks)
+	})
+
 	// Test file output with valid path
 	t.Run("File output with valid path", func(t *testing.T) {
 		// Create a temporary file
@@ -173,6 +227,33 @@ func TestConfigureLoggerOutput(t *testing.T) {
 		assert.Contains(t, string(content), "Test log entry")
 	})
 
+	// Test file output without stdout mirroring
+	t.Run("File output without stdout", func(t *testing.T) {
+		logPath := filepath.Join(t.TempDir(), "test.log")
+
+		log := logrus.New()
+
+		alsoStdout := false
+		cfg := config.LoggingConfig{
+			Level:      "info",
+			Format:     "json",
+			Output:     "file",
+			FilePath:   logPath,
+			AlsoStdout: &alsoStdout,
+		}
+		ConfigureLogger(log, cfg)
+
+		// The file is the only output
+		file, ok := log.Out.(*os.File)
+		assert.True(t, ok, "output should be the log file")
+		assert.Equal(t, logPath, file.Name())
+
+		log.Info("Test file only entry")
+		content, err := os.ReadFile(logPath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "Test file only entry")
+	})
+
 	// Test file output with invalid path
 	t.Run("File output with invalid path", func(t *testing.T) {
 		// Create a logger with a custom output to capture logs
@@ -251,58 +332,73 @@ func TestLogWebhookReceived(t *testing.T) {
 	assert.Equal(t, "info", logEntry["level"])
 }
 
-func TestLogWebhookForwarded(t *testing.T) {
-	// Create a logger with a buffer to capture output
-	log := logrus.New()
-	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	log.SetFormatter(&logrus.JSONFormatter{})
+func TestLogDeliveryCompleted(t *testing.T) {
+	// Test a successful delivery after a retry
+	t.Run("Success", func(t *testing.T) {
+		log := logrus.New()
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		log.SetFormatter(&logrus.JSONFormatter{})
 
-	// Call the function
-	destination := "http://example.com"
-	statusCode := 200
-	duration := 123.45
+		attempts := []DeliveryAttempt{
+			{Attempt: 1, StatusCode: 503, DurationMs: 12, Error: "received non-2xx status code: 503"},
+			{Attempt: 2, StatusCode: 200, DurationMs: 8},
+		}
+		LogDeliveryCompleted(log, "/webhook", "http://example.com", attempts, 1250*time.Millisecond, OutcomeSuccess)
 
-	LogWebhookForwarded(log, destination, statusCode, duration)
+		var logEntry map[string]interface{}
+		err := json.Unmarshal(buf.Bytes(), &logEntry)
+		assert.NoError(t, err)
 
-	// Parse the log output
-	var logEntry map[string]interface{}
-	err := json.Unmarshal(buf.Bytes(), &logEntry)
-	assert.NoError(t, err)
+		assert.Equal(t, "Webhook delivery completed", logEntry["msg"])
+		assert.Equal(t, "info", logEntry["level"])
+		assert.Equal(t, "/webhook", logEntry["endpoint"])
+		assert.Equal(t, "http://example.com", logEntry["destination"])
+		assert.Equal(t, float64(2), logEntry["attempts"])
+		assert.Equal(t, []interface{}{float64(503), float64(200)}, logEntry["status_codes"])
+		assert.Equal(t, float64(1250), logEntry["duration_ms"])
+		assert.Equal(t, OutcomeSuccess, logEntry["outcome"])
+		assert.NotContains(t, logEntry, "error")
+
+		history, ok := logEntry["attempt_history"].([]interface{})
+		assert.True(t, ok, "attempt_history should be a list")
+		assert.Len(t, history, 2)
+	})
 
-	// Assert that the log entry contains the expected fields
-	assert.Equal(t, "Webhook forwarded", logEntry["msg"])
-	assert.Equal(t, destination, logEntry["destination"])
-	assert.Equal(t, float64(statusCode), logEntry["status_code"])
-	assert.Equal(t, duration, logEntry["duration_ms"])
-	assert.Equal(t, "info", logEntry["level"])
+	// Test a failed delivery
+	t.Run("Failure", func(t *testing.T) {
+		log := logrus.New()
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		log.SetFormatter(&logrus.JSONFormatter{})
+
+		attempts := []DeliveryAttempt{
+			{Attempt: 1, DurationMs: 5, Error: "request failed: connection refused"},
+		}
+		LogDeliveryCompleted(log, "/webhook", "http://example.com", attempts, 5*time.Millisecond, OutcomeFailure)
+
+		var logEntry map[string]interface{}
+		err := json.Unmarshal(buf.Bytes(), &logEntry)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "Webhook delivery failed", logEntry["msg"])
+		assert.Equal(t, "error", logEntry["level"])
+		assert.Equal(t, OutcomeFailure, logEntry["outcome"])
+		assert.Equal(t, "request failed: connection refused", logEntry["error"])
+	})
 }
 
-func TestLogWebhookError(t *testing.T) {
-	// Create a logger with a buffer to capture output
+func TestConfigureLoggerKeepsSlogHandlers(t *testing.T) {
 	log := logrus.New()
 	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	log.SetFormatter(&logrus.JSONFormatter{})
+	logging.AddSlogHandler(log, slog.NewJSONHandler(&buf, nil))
 
-	// Call the function
-	destination := "http://example.com"
-	err := errors.New("test error")
-	attempt := 2
-	maxAttempts := 3
+	// Handlers added by embedders are kept when the logger is reconfigured
+	ConfigureLogger(log, config.LoggingConfig{Level: "info", Format: "json", Output: "stdout"})
+	log.SetOutput(&bytes.Buffer{})
+	log.Info("Reconfigured")
 
-	LogWebhookError(log, destination, err, attempt, maxAttempts)
-
-	// Parse the log output
-	var logEntry map[string]interface{}
-	err = json.Unmarshal(buf.Bytes(), &logEntry)
-	assert.NoError(t, err)
-
-	// Assert that the log entry contains the expected fields
-	assert.Equal(t, "Webhook forwarding failed", logEntry["msg"])
-	assert.Equal(t, destination, logEntry["destination"])
-	assert.Equal(t, "test error", logEntry["error"])
-	assert.Equal(t, float64(attempt), logEntry["attempt"])
-	assert.Equal(t, float64(maxAttempts), logEntry["max_attempts"])
-	assert.Equal(t, "error", logEntry["level"])
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "Reconfigured", record["msg"])
 }
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ANSI escape sequences used by the pretty format
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorDim    = "\x1b[2m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorBlue   = "\x1b[34m"
+	colorCyan   = "\x1b[36m"
+)
+
+// prettyMessageWidth is the width messages are padded to so that fields line up
+const prettyMessageWidth = 36
+
+// prettyFormatter writes colorized, aligned entries for local development
+type prettyFormatter struct {
+	colors bool
+}
+
+// newPrettyFormatter creates the pretty formatter. Colors are disabled when NO_COLOR is set.
+func newPrettyFormatter() *prettyFormatter {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &prettyFormatter{colors: !noColor}
+}
+
+// Format renders the entry as a single aligned line
+func (f *prettyFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(f.paint(colorDim, entry.Time.Format("15:04:05.000")))
+	buf.WriteByte(' ')
+
+	levelColor, levelName := prettyLevel(entry.Level)
+	buf.WriteString(f.paint(levelColor, levelName))
+	buf.WriteByte(' ')
+
+	glyphColor, glyph := prettyGlyph(entry)
+	buf.WriteString(f.paint(glyphColor, glyph))
+	buf.WriteByte(' ')
+
+	message := entry.Message
+	skip := map[string]bool{}
+	if method, ok := entry.Data["method"].(string); ok {
+		if path, ok := entry.Data["path"].(string); ok {
+			message = fmt.Sprintf("%s %s %s", message, method, path)
+			skip["method"], skip["path"] = true, true
+		}
+	}
+	if len(message) < prettyMessageWidth {
+		message += strings.Repeat(" ", prettyMessageWidth-len(message))
+	}
+	buf.WriteString(f.paint(colorBold, message))
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		if !skip[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		buf.WriteByte(' ')
+		buf.WriteString(f.paint(colorCyan, key+"="))
+		buf.WriteString(prettyValue(entry.Data[key]))
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// paint wraps the text in the given color when colors are enabled
+func (f *prettyFormatter) paint(color string, text string) string {
+	if !f.colors {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// prettyLevel returns the color and the fixed-width name of a level
+func prettyLevel(level logrus.Level) (string, string) {
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return colorDim, "DBG"
+	case logrus.InfoLevel:
+		return colorBlue, "INF"
+	case logrus.WarnLevel:
+		return colorYellow, "WRN"
+	case logrus.ErrorLevel:
+		return colorRed, "ERR"
+	default:
+		return colorRed, "FTL"
+	}
+}
+
+// prettyGlyph returns a glyph summarizing the entry: the delivery outcome,
+// the status code class or, failing that, the level
+func prettyGlyph(entry *logrus.Entry) (string, string) {
+	switch entry.Data["outcome"] {
+	case OutcomeSuccess:
+		return colorGreen, "✔"
+	case OutcomeFailure:
+		return colorRed, "✖"
+	}
+
+	if status, ok := entry.Data["status_code"].(int); ok {
+		switch {
+		case status >= 500:
+			return colorRed, "✖"
+		case status >= 400:
+			return colorYellow, "⚠"
+		case status >= 300:
+			return colorCyan, "↪"
+		case status >= 200:
+			return colorGreen, "✔"
+		}
+	}
+
+	if _, ok := entry.Data["method"]; ok {
+		return colorBlue, "→"
+	}
+
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		return colorRed, "✖"
+	case logrus.WarnLevel:
+		return colorYellow, "⚠"
+	default:
+		return colorDim, "•"
+	}
+}
+
+// prettyValue formats a field value, quoting strings containing spaces
+func prettyValue(value interface{}) string {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	text := fmt.Sprintf("%v", value)
+	if strings.ContainsAny(text, " \t\n\"") {
+		return fmt.Sprintf("%q", text)
+	}
+	return text
+}
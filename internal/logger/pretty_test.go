@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prettyLine formats an entry with the pretty formatter
+func prettyLine(t *testing.T, colors bool, level logrus.Level, message string, fields logrus.Fields) string {
+	t.Helper()
+
+	entry := logrus.NewEntry(logrus.New()).WithFields(fields)
+	entry.Time = time.Date(2024, 1, 1, 12, 30, 45, 123000000, time.UTC)
+	entry.Level = level
+	entry.Message = message
+
+	line, err := (&prettyFormatter{colors: colors}).Format(entry)
+	require.NoError(t, err)
+	return string(line)
+}
+
+func TestPrettyFormat(t *testing.T) {
+	line := prettyLine(t, false, logrus.InfoLevel, "Webhook received", logrus.Fields{
+		"method":         "POST",
+		"path":           "/webhook",
+		"content_length": 42,
+		"remote_addr":    "127.0.0.1:5000",
+	})
+
+	assert.Equal(t, "12:30:45.123 INF → Webhook received POST /webhook       content_length=42 remote_addr=127.0.0.1:5000\n", line)
+}
+
+func TestPrettyFormatGlyphs(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    logrus.Level
+		fields   logrus.Fields
+		expected string
+	}{
+		{name: "Successful delivery", level: logrus.InfoLevel, fields: logrus.Fields{"outcome": OutcomeSuccess}, expected: "✔"},
+		{name: "Failed delivery", level: logrus.ErrorLevel, fields: logrus.Fields{"outcome": OutcomeFailure}, expected: "✖"},
+		{name: "Client error status", level: logrus.InfoLevel, fields: logrus.Fields{"status_code": 404}, expected: "⚠"},
+		{name: "Server error status", level: logrus.InfoLevel, fields: logrus.Fields{"status_code": 502}, expected: "✖"},
+		{name: "Warning", level: logrus.WarnLevel, fields: logrus.Fields{}, expected: "⚠"},
+		{name: "Other entry", level: logrus.InfoLevel, fields: logrus.Fields{}, expected: "•"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := prettyLine(t, false, tt.level, "Message", tt.fields)
+			assert.Equal(t, tt.expected, strings.Fields(line)[2])
+		})
+	}
+}
+
+func TestPrettyFormatValues(t *testing.T) {
+	line := prettyLine(t, false, logrus.ErrorLevel, "Webhook delivery failed", logrus.Fields{
+		"error": errors.New("connection refused"),
+	})
+	assert.Contains(t, line, `ERR ✖ Webhook delivery failed`)
+	assert.Contains(t, line, `error="connection refused"`)
+}
+
+func TestPrettyFormatColors(t *testing.T) {
+	line := prettyLine(t, true, logrus.ErrorLevel, "Webhook delivery failed", logrus.Fields{"outcome": OutcomeFailure})
+	assert.Contains(t, line, colorRed+"ERR"+colorReset)
+	assert.Contains(t, line, colorRed+"✖"+colorReset)
+}
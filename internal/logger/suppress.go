@@ -0,0 +1,147 @@
+package logger
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ClassifiedError is a delivery error of a known class: the errors the proxy raises
+// itself, such as rejected responses, which no error type of the network stack describes
+type ClassifiedError struct {
+	Class string
+	Err   error
+}
+
+// WithClass returns the error with the given class
+func WithClass(class string, err error) error {
+	return &ClassifiedError{Class: class, Err: err}
+}
+
+func (e *ClassifiedError) Error() string { return e.Err.Error() }
+func (e *ClassifiedError) Unwrap() error { return e.Err }
+
+// ErrorClass returns a stable class for a delivery error, so that errors differing only in
+// details (timings, bodies, addresses) are grouped. Network errors are classified by their
+// type, never by their message, which quotes the destination's URL.
+func ErrorClass(err error) string {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection_reset"
+	case isTLSError(err):
+		return "tls"
+	default:
+		return "other"
+	}
+}
+
+// isTLSError reports whether an error comes from the TLS handshake or the verification of
+// the destination's certificate
+func isTLSError(err error) bool {
+	var (
+		verification *tls.CertificateVerificationError
+		record       tls.RecordHeaderError
+		alert        tls.AlertError
+		authority    x509.UnknownAuthorityError
+		hostname     x509.HostnameError
+		invalid      x509.CertificateInvalidError
+	)
+	return errors.As(err, &verification) || errors.As(err, &record) || errors.As(err, &alert) ||
+		errors.As(err, &authority) || errors.As(err, &hostname) || errors.As(err, &invalid)
+}
+
+// ErrorSuppressor groups repeated delivery errors by destination and error class.
+// The first error of a group is logged in full; the following ones within the
+// window are counted and reported in a single summary entry when the window ends.
+type ErrorSuppressor struct {
+	log    *logrus.Logger
+	window time.Duration
+
+	mu     sync.Mutex
+	groups map[string]*errorGroup
+}
+
+// errorGroup tracks the errors of a fingerprint during a window
+type errorGroup struct {
+	destination string
+	class       string
+	lastError   string
+	suppressed  int
+}
+
+// NewErrorSuppressor creates an error suppressor summarizing repeated errors over the given window
+func NewErrorSuppressor(log *logrus.Logger, window time.Duration) *ErrorSuppressor {
+	return &ErrorSuppressor{
+		log:    log,
+		window: window,
+		groups: make(map[string]*errorGroup),
+	}
+}
+
+// Allow reports whether an error for the destination should be logged in full.
+// It returns false when an error of the same class was already logged in the current window.
+func (s *ErrorSuppressor) Allow(destination string, err error) bool {
+	class := ErrorClass(err)
+	message := err.Error()
+	fingerprint := destination + "|" + class
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if group, ok := s.groups[fingerprint]; ok {
+		group.suppressed++
+		group.lastError = message
+		return false
+	}
+
+	group := &errorGroup{destination: destination, class: class, lastError: message}
+	s.groups[fingerprint] = group
+	time.AfterFunc(s.window, func() { s.flush(fingerprint, group) })
+
+	return true
+}
+
+// flush ends the window of a group and logs a summary if errors were suppressed. The
+// summary is written before the group is removed, under the lock, so that no error is
+// suppressed without being counted in it.
+func (s *ErrorSuppressor) flush(fingerprint string, group *errorGroup) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if group.suppressed > 0 {
+		s.log.WithFields(logrus.Fields{
+			"destination": group.destination,
+			"error_class": group.class,
+			"occurrences": group.suppressed + 1,
+			"suppressed":  group.suppressed,
+			"window":      s.window.String(),
+			"error":       group.lastError,
+		}).Error(fmt.Sprintf("Webhook delivery error occurred %d times in the last %s", group.suppressed+1, s.window))
+	}
+
+	if s.groups[fingerprint] == group {
+		delete(s.groups, fingerprint)
+	}
+}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorClass(t *testing.T) {
+	// Messages quoting the URL do not change the class of network errors
+	request := func(err error) error {
+		return fmt.Errorf("request failed: %w", &url.Error{Op: "Post", URL: "https://geoffrey.example.com/timeout/tls/x509", Err: err})
+	}
+	dial := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: err}}
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "status", err: WithClass("http_503", errors.New("received non-2xx status code: 503, body: timeout")), expected: "http_503"},
+		{name: "deadline", err: request(context.DeadlineExceeded), expected: "timeout"},
+		{name: "net timeout", err: request(&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}), expected: "timeout"},
+		{name: "refused", err: request(dial(syscall.ECONNREFUSED)), expected: "connection_refused"},
+		{name: "reset", err: request(&net.OpError{Op: "read", Net: "tcp", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}), expected: "connection_reset"},
+		{name: "eof", err: request(io.EOF), expected: "connection_reset"},
+		{name: "dns", err: request(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}}), expected: "dns"},
+		{name: "dns timeout", err: request(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "i/o timeout", Name: "slow.invalid", IsTimeout: true}}), expected: "dns"},
+		{name: "certificate", err: request(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}), expected: "tls"},
+		{name: "hostname", err: request(x509.HostnameError{Host: "other.example.com", Certificate: &x509.Certificate{}}), expected: "tls"},
+		{name: "handshake", err: request(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), expected: "tls"},
+		{name: "url", err: request(errors.New("geoffrey: timeout in tls, x509 certificate, EOF")), expected: "other"},
+		{name: "unexpected", err: errors.New("something unexpected"), expected: "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ErrorClass(tt.err))
+		})
+	}
+}
+
+func TestErrorSuppressor(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	suppressor := NewErrorSuppressor(log, 50*time.Millisecond)
+	status := func(code int, body string) error {
+		return WithClass(fmt.Sprintf("http_%d", code), fmt.Errorf("received non-2xx status code: %d, body: %s", code, body))
+	}
+
+	// The first error of a fingerprint is allowed, identical ones are suppressed
+	assert.True(t, suppressor.Allow("https://a.example.com", status(503, "a")))
+	assert.False(t, suppressor.Allow("https://a.example.com", status(503, "b")))
+	assert.False(t, suppressor.Allow("https://a.example.com", status(503, "c")))
+
+	// Other error classes and destinations are separate fingerprints
+	assert.True(t, suppressor.Allow("https://a.example.com", status(500, "")))
+	assert.True(t, suppressor.Allow("https://b.example.com", status(503, "")))
+
+	// A summary is logged once the window ends, for suppressed groups only
+	assert.Eventually(t, func() bool {
+		suppressor.mu.Lock()
+		defer suppressor.mu.Unlock()
+		return len(suppressor.groups) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "Webhook delivery error occurred 3 times in the last 50ms", entry["msg"])
+	assert.Equal(t, "https://a.example.com", entry["destination"])
+	assert.Equal(t, "http_503", entry["error_class"])
+	assert.Equal(t, float64(3), entry["occurrences"])
+	assert.Equal(t, float64(2), entry["suppressed"])
+	assert.Equal(t, "received non-2xx status code: 503, body: c", entry["error"])
+
+	// A new window starts after the summary
+	assert.True(t, suppressor.Allow("https://a.example.com", status(503, "d")))
+}
@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultSyslogTag is the application name used when no tag is configured
+	defaultSyslogTag = "webhook-proxy"
+
+	// defaultSyslogFacility is the facility used when none is configured
+	defaultSyslogFacility = "local0"
+
+	// syslogWriteTimeout is the maximum time allowed to write a message
+	syslogWriteTimeout = 5 * time.Second
+)
+
+// localSyslogSockets lists the sockets local syslog daemons usually listen on
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogHook is a logrus hook sending every entry to syslog with the matching severity.
+// Local daemons receive RFC3164 messages; remote servers (udp, tcp) receive RFC5424 messages.
+type syslogHook struct {
+	network  string
+	address  string
+	tag      string
+	facility int
+	hostname string
+	pid      int
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newSyslogHook creates a syslog hook and connects to the syslog server
+func newSyslogHook(cfg config.SyslogConfig) (*syslogHook, error) {
+	tag := cfg.Tag
+	if tag == "" {
+		tag = defaultSyslogTag
+	}
+
+	facilityName := cfg.Facility
+	if facilityName == "" {
+		facilityName = defaultSyslogFacility
+	}
+	facility, ok := config.SyslogFacilities[facilityName]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslog facility: %s", facilityName)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	hook := &syslogHook{
+		network:  cfg.Network,
+		address:  cfg.Address,
+		tag:      tag,
+		facility: facility,
+		hostname: hostname,
+		pid:      os.Getpid(),
+	}
+
+	if err := hook.connect(); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+// Levels returns the levels the hook fires for
+func (h *syslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire formats the entry with the logger's formatter and sends it to syslog
+func (h *syslogHook) Fire(entry *logrus.Entry) error {
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+
+	message := h.format(syslogSeverity(entry.Level), entry.Time, strings.TrimSuffix(line, "\n"))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Reconnect once if the connection was lost (e.g. syslog daemon restart)
+	if err := h.write(message); err != nil {
+		if err := h.connect(); err != nil {
+			return err
+		}
+		return h.write(message)
+	}
+
+	return nil
+}
+
+// Close closes the connection to the syslog server
+func (h *syslogHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
+
+// connect opens the connection to the syslog server
+func (h *syslogHook) connect() error {
+	if h.conn != nil {
+		_ = h.conn.Close()
+		h.conn = nil
+	}
+
+	if h.network != "" {
+		conn, err := net.DialTimeout(h.network, h.address, syslogWriteTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		h.conn = conn
+		return nil
+	}
+
+	// Local syslog daemon
+	for _, socket := range localSyslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, socket); err == nil {
+				h.conn = conn
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("failed to connect to local syslog: no syslog socket found")
+}
+
+// write sends a message on the current connection
+func (h *syslogHook) write(message string) error {
+	if h.conn == nil {
+		return fmt.Errorf("not connected to syslog")
+	}
+
+	if err := h.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout)); err != nil {
+		return err
+	}
+
+	// Stream transports use octet-counting framing (RFC6587)
+	if h.network == "tcp" || h.network == "unix" {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	_, err := h.conn.Write([]byte(message))
+	return err
+}
+
+// format builds a syslog message. Remote servers receive RFC5424 messages,
+// local daemons the traditional RFC3164 format they expect.
+func (h *syslogHook) format(severity int, timestamp time.Time, msg string) string {
+	priority := h.facility*8 + severity
+
+	if h.network == "udp" || h.network == "tcp" {
+		return fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+			priority, timestamp.Format(time.RFC3339Nano), h.hostname, h.tag, h.pid, msg)
+	}
+
+	return fmt.Sprintf("<%d>%s %s[%d]: %s", priority, timestamp.Format(time.Stamp), h.tag, h.pid, msg)
+}
+
+// syslogSeverity maps a logrus level to a syslog severity
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2 // critical
+	case logrus.ErrorLevel:
+		return 3 // error
+	case logrus.WarnLevel:
+		return 4 // warning
+	case logrus.InfoLevel:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogHookUDP(t *testing.T) {
+	// Start a UDP syslog server
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	log := logrus.New()
+	ConfigureLogger(log, config.LoggingConfig{
+		Level:  "info",
+		Format: "json",
+		Output: "syslog",
+		Syslog: config.SyslogConfig{
+			Network:  "udp",
+			Address:  conn.LocalAddr().String(),
+			Tag:      "proxy-test",
+			Facility: "local3",
+		},
+	})
+	defer log.ReplaceHooks(make(logrus.LevelHooks))
+
+	log.Warn("Test syslog entry")
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	// local3 (19) * 8 + warning (4) = 156, RFC5424 version 1
+	message := string(buf[:n])
+	pattern := regexp.MustCompile(`^<156>1 \S+ \S+ proxy-test \d+ - - \{.*"msg":"Test syslog entry".*\}$`)
+	assert.Regexp(t, pattern, message)
+}
+
+func TestSyslogHookTCP(t *testing.T) {
+	// Start a TCP syslog server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Read an octet-counted frame
+		reader := bufio.NewReader(conn)
+		length, readErr := reader.ReadString(' ')
+		if readErr != nil {
+			return
+		}
+		size, convErr := strconv.Atoi(strings.TrimSpace(length))
+		if convErr != nil {
+			return
+		}
+		frame := make([]byte, size)
+		if _, readErr = reader.Read(frame); readErr != nil {
+			return
+		}
+		received <- string(frame)
+	}()
+
+	hook, err := newSyslogHook(config.SyslogConfig{Network: "tcp", Address: listener.Addr().String()})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	log := logrus.New()
+	log.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	log.AddHook(hook)
+	log.SetOutput(os.Stderr)
+	log.Error("Test tcp entry")
+
+	select {
+	case message := <-received:
+		// local0 (16) * 8 + error (3) = 131
+		assert.True(t, strings.HasPrefix(message, "<131>1 "), message)
+		assert.Contains(t, message, " webhook-proxy ")
+		assert.Contains(t, message, "Test tcp entry")
+	case <-time.After(time.Second):
+		t.Fatal("No syslog message received")
+	}
+}
+
+func TestSyslogHookUnixSocket(t *testing.T) {
+	// Start a local (unixgram) syslog daemon
+	socket := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenPacket("unixgram", socket)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	hook, err := newSyslogHook(config.SyslogConfig{Network: "unixgram", Address: socket, Tag: "proxy"})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	log := logrus.New()
+	log.AddHook(hook)
+	log.Info("Test local entry")
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	// Local daemons receive RFC3164 messages: local0 (16) * 8 + info (6) = 134
+	message := string(buf[:n])
+	assert.Regexp(t, regexp.MustCompile(`^<134>\w{3} [ \d]\d \d{2}:\d{2}:\d{2} proxy\[\d+\]: `), message)
+	assert.Contains(t, message, "Test local entry")
+}
+
+func TestNewSyslogHookErrors(t *testing.T) {
+	// Invalid facility
+	_, err := newSyslogHook(config.SyslogConfig{Network: "udp", Address: "127.0.0.1:514", Facility: "invalid"})
+	assert.Error(t, err)
+
+	// Unreachable server
+	_, err = newSyslogHook(config.SyslogConfig{Network: "unixgram", Address: filepath.Join(t.TempDir(), "missing.sock")})
+	assert.Error(t, err)
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	assert.Equal(t, 2, syslogSeverity(logrus.PanicLevel))
+	assert.Equal(t, 2, syslogSeverity(logrus.FatalLevel))
+	assert.Equal(t, 3, syslogSeverity(logrus.ErrorLevel))
+	assert.Equal(t, 4, syslogSeverity(logrus.WarnLevel))
+	assert.Equal(t, 6, syslogSeverity(logrus.InfoLevel))
+	assert.Equal(t, 7, syslogSeverity(logrus.DebugLevel))
+	assert.Equal(t, 7, syslogSeverity(logrus.TraceLevel))
+}
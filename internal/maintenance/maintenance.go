@@ -0,0 +1,130 @@
+// Package maintenance evaluates the recurring maintenance windows of destinations, during
+// which deliveries wait for the window to end instead of being attempted
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Embed the time zone database, the container image has none
+	_ "time/tzdata"
+)
+
+// MaxDuration bounds the duration of a window, which recurs at most weekly
+const MaxDuration = 7 * 24 * time.Hour
+
+// maxChained bounds the windows joined into one when they overlap or follow each other
+const maxChained = 16
+
+// weekdays maps the day names accepted in windows to their weekday
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Window is a parsed maintenance window
+type Window struct {
+	days     [7]bool
+	hour     int
+	minute   int
+	duration time.Duration
+	location *time.Location
+}
+
+// Parse parses a window opening at start, a HH:MM time in the timezone, on each of the days,
+// and lasting duration. No days means every day and an empty timezone means UTC.
+func Parse(days []string, start string, duration time.Duration, timezone string) (*Window, error) {
+	w := &Window{duration: duration, location: time.UTC}
+
+	if len(days) == 0 {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	}
+	for _, day := range days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("invalid day: %s (must be mon, tue, wed, thu, fri, sat or sun)", day)
+		}
+		w.days[weekday] = true
+	}
+
+	clock, err := time.Parse("15:04", start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %q (must be HH:MM)", start)
+	}
+	w.hour, w.minute = clock.Hour(), clock.Minute()
+
+	if duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+	if duration > MaxDuration {
+		return nil, fmt.Errorf("duration must be at most %s", MaxDuration)
+	}
+
+	if timezone != "" {
+		if w.location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone: %s", timezone)
+		}
+	}
+
+	return w, nil
+}
+
+// End returns the end of the occurrence of the window in progress at now, or false
+// when the window is not in progress
+func (w *Window) End(now time.Time) (time.Time, bool) {
+	local := now.In(w.location)
+
+	var end time.Time
+	// An occurrence in progress opened at most MaxDuration ago
+	for offset := 0; offset <= int(MaxDuration/(24*time.Hour)); offset++ {
+		start := time.Date(local.Year(), local.Month(), local.Day()-offset, w.hour, w.minute, 0, 0, w.location)
+		if !w.days[start.Weekday()] || now.Before(start) {
+			continue
+		}
+		if occurrenceEnd := start.Add(w.duration); now.Before(occurrenceEnd) && occurrenceEnd.After(end) {
+			end = occurrenceEnd
+		}
+	}
+
+	return end, !end.IsZero()
+}
+
+// Schedule is the set of maintenance windows of a destination
+type Schedule []*Window
+
+// End returns the end of the maintenance in progress at now, or false when no window is
+// in progress. Windows overlapping or following each other make a single maintenance.
+func (s Schedule) End(now time.Time) (time.Time, bool) {
+	end, ok := s.end(now)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	for i := 0; i < maxChained; i++ {
+		next, ok := s.end(end)
+		if !ok {
+			break
+		}
+		end = next
+	}
+	return end, true
+}
+
+// end returns the latest end of the windows in progress at now
+func (s Schedule) end(now time.Time) (time.Time, bool) {
+	var end time.Time
+	for _, w := range s {
+		if windowEnd, ok := w.End(now); ok && windowEnd.After(end) {
+			end = windowEnd
+		}
+	}
+	return end, !end.IsZero()
+}
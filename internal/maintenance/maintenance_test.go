@@ -0,0 +1,98 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(t *testing.T, value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		days     []string
+		start    string
+		duration time.Duration
+		timezone string
+		err      string
+	}{
+		{name: "every day", start: "02:00", duration: time.Hour},
+		{name: "days and timezone", days: []string{"Sat", "sunday"}, start: "22:30", duration: 6 * time.Hour, timezone: "Europe/Paris"},
+		{name: "invalid day", days: []string{"someday"}, start: "02:00", duration: time.Hour, err: "invalid day: someday"},
+		{name: "invalid start", start: "2am", duration: time.Hour, err: `invalid start: "2am" (must be HH:MM)`},
+		{name: "out of range start", start: "24:00", duration: time.Hour, err: `invalid start: "24:00" (must be HH:MM)`},
+		{name: "no duration", start: "02:00", err: "duration must be positive"},
+		{name: "too long", start: "02:00", duration: 8 * 24 * time.Hour, err: "duration must be at most 168h0m0s"},
+		{name: "unknown timezone", start: "02:00", duration: time.Hour, timezone: "Mars/Olympus", err: "unknown timezone: Mars/Olympus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.days, tt.start, tt.duration, tt.timezone)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWindowEnd(t *testing.T) {
+	// Saturdays from 22:00 to 02:00, Paris time
+	window, err := Parse([]string{"sat"}, "22:00", 4*time.Hour, "Europe/Paris")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		now    string
+		end    string
+		active bool
+	}{
+		{name: "before", now: "2024-06-01T19:59:00Z"},
+		{name: "opening", now: "2024-06-01T20:00:00Z", end: "2024-06-02T00:00:00Z", active: true},
+		{name: "after midnight", now: "2024-06-01T23:30:00Z", end: "2024-06-02T00:00:00Z", active: true},
+		{name: "closing", now: "2024-06-02T00:00:00Z"},
+		{name: "other day", now: "2024-06-03T20:30:00Z"},
+		{name: "winter time", now: "2024-12-07T21:30:00Z", end: "2024-12-08T01:00:00Z", active: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end, active := window.End(date(t, tt.now))
+			assert.Equal(t, tt.active, active)
+			if tt.active {
+				assert.True(t, date(t, tt.end).Equal(end), "Expected the window to end at %s, got %s", tt.end, end)
+			}
+		})
+	}
+}
+
+func TestScheduleEnd(t *testing.T) {
+	nightly, err := Parse(nil, "01:00", 2*time.Hour, "")
+	require.NoError(t, err)
+	upgrade, err := Parse([]string{"sun"}, "02:30", 3*time.Hour, "")
+	require.NoError(t, err)
+	schedule := Schedule{nightly, upgrade}
+
+	// On Sundays the upgrade window extends the nightly one
+	end, active := schedule.End(date(t, "2024-06-02T01:15:00Z"))
+	require.True(t, active)
+	assert.True(t, date(t, "2024-06-02T05:30:00Z").Equal(end))
+
+	end, active = schedule.End(date(t, "2024-06-03T01:15:00Z"))
+	require.True(t, active)
+	assert.True(t, date(t, "2024-06-03T03:00:00Z").Equal(end))
+
+	_, active = schedule.End(date(t, "2024-06-03T04:00:00Z"))
+	assert.False(t, active)
+	_, active = Schedule(nil).End(date(t, "2024-06-03T04:00:00Z"))
+	assert.False(t, active)
+}
@@ -0,0 +1,295 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// windowLayout names the journal and manifest files after the start of their window
+const windowLayout = "20060102T150405Z"
+
+// File name prefixes and extensions
+const (
+	journalPrefix    = "journal-"
+	journalExtension = ".jsonl"
+	manifestPrefix   = "manifest-"
+	manifestExt      = ".json"
+)
+
+// Exporter journals delivery entries in a directory, one journal file per window, and
+// writes the signed manifest of each window once it has ended. Windows are aligned on
+// multiples of their duration since the Unix epoch, in UTC.
+type Exporter struct {
+	dir    string
+	window time.Duration
+	key    Key
+
+	mu sync.Mutex
+
+	// journal is the open journal file, of the window starting at journalStart
+	journal      *os.File
+	journalStart time.Time
+
+	// previous is the digest of the last manifest written, and previousEnd its window end
+	previous    string
+	previousEnd time.Time
+
+	// openedWindow is the window the exporter was opened in; empty windows get a manifest
+	// from there on only, as nothing is known of the deliveries made before
+	openedWindow time.Time
+}
+
+// Open opens the exporter in the given directory, creating it if needed, and resumes the
+// chain from the last manifest written there
+func Open(dir string, window time.Duration, key Key, now time.Time) (*Exporter, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	e := &Exporter{dir: dir, window: window, key: key, openedWindow: now.UTC().Truncate(window)}
+
+	manifests, err := e.windows(manifestPrefix, manifestExt)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) > 0 {
+		last := manifests[len(manifests)-1]
+		data, err := os.ReadFile(e.path(manifestPrefix, manifestExt, last))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the last manifest: %w", err)
+		}
+		e.previous = Digest(data)
+		e.previousEnd = last.Add(window)
+	}
+
+	return e, nil
+}
+
+// PublicKey returns the base64 public key verifying the manifests of an ed25519 key, or an
+// empty string for HMAC keys
+func (e *Exporter) PublicKey() string {
+	return PublicKey(e.key)
+}
+
+// Record journals an entry in the file of its window. Entries are written one by one, so
+// that a crash loses at most the entry being written.
+func (e *Exporter) Record(entry Entry) error {
+	entry.Time = entry.Time.UTC()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest entry: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Entries of windows already exported go to the current window
+	start := entry.Time.Truncate(e.window)
+	if start.Before(e.previousEnd) {
+		start = e.previousEnd
+	}
+	if e.journal == nil || !start.Equal(e.journalStart) {
+		if err := e.closeJournal(); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(e.path(journalPrefix, journalExtension, start), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open manifest journal: %w", err)
+		}
+		e.journal, e.journalStart = file, start
+	}
+
+	if _, err := e.journal.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write manifest entry: %w", err)
+	}
+	return nil
+}
+
+// Export writes the manifests of the windows ended by now, in window order, and returns
+// their paths. A window gets a manifest when it has a journal, or when it ended after the
+// exporter was opened, so that windows without deliveries are attested too.
+func (e *Exporter) Export(now time.Time) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now = now.UTC()
+	journals, err := e.windows(journalPrefix, journalExtension)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[int64]time.Time)
+	for _, start := range journals {
+		// A journal left before the chain's end was exported by a run that stopped before removing it
+		if start.Before(e.previousEnd) {
+			if err := os.Remove(e.path(journalPrefix, journalExtension, start)); err != nil {
+				return nil, fmt.Errorf("failed to remove manifest journal: %w", err)
+			}
+			continue
+		}
+		if !start.Add(e.window).After(now) {
+			pending[start.Unix()] = start
+		}
+	}
+	from := e.openedWindow
+	if e.previousEnd.After(from) {
+		from = e.previousEnd
+	}
+	for start := from; !start.Add(e.window).After(now); start = start.Add(e.window) {
+		pending[start.Unix()] = start
+	}
+
+	starts := make([]time.Time, 0, len(pending))
+	for _, start := range pending {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	var paths []string
+	for _, start := range starts {
+		path, err := e.export(start, now)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// export writes the manifest of a window from its journal, and removes the journal
+func (e *Exporter) export(start, now time.Time) (string, error) {
+	journalPath := e.path(journalPrefix, journalExtension, start)
+	if e.journal != nil && start.Equal(e.journalStart) {
+		if err := e.closeJournal(); err != nil {
+			return "", err
+		}
+	}
+
+	m := &Manifest{
+		Version:     Version,
+		WindowStart: start,
+		WindowEnd:   start.Add(e.window),
+		GeneratedAt: now,
+		Entries:     []Entry{},
+		Previous:    e.previous,
+	}
+	if err := m.readJournal(journalPath); err != nil {
+		return "", err
+	}
+
+	data, err := m.Sign(e.key)
+	if err != nil {
+		return "", err
+	}
+
+	// Write atomically, so that a manifest is either complete or absent
+	path := e.path(manifestPrefix, manifestExt, start)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Remove(journalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to remove manifest journal: %w", err)
+	}
+
+	e.previous = Digest(data)
+	if m.WindowEnd.After(e.previousEnd) {
+		e.previousEnd = m.WindowEnd
+	}
+	return path, nil
+}
+
+// readJournal adds the entries of a journal file to the manifest, in time order. A missing
+// journal is a window without deliveries.
+func (m *Manifest) readJournal(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read manifest journal: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			m.Unreadable++
+			continue
+		}
+		m.Entries = append(m.Entries, entry)
+		switch entry.Outcome {
+		case OutcomeDelivered:
+			m.Delivered++
+		case OutcomeDeadLetter:
+			m.DeadLetters++
+		}
+	}
+	sort.SliceStable(m.Entries, func(i, j int) bool { return m.Entries[i].Time.Before(m.Entries[j].Time) })
+	return nil
+}
+
+// Close closes the open journal file
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closeJournal()
+}
+
+// closeJournal closes the open journal file, if any
+func (e *Exporter) closeJournal() error {
+	if e.journal == nil {
+		return nil
+	}
+	err := e.journal.Close()
+	e.journal = nil
+	if err != nil {
+		return fmt.Errorf("failed to close manifest journal: %w", err)
+	}
+	return nil
+}
+
+// windows returns the window starts of the files with a prefix and extension, in order
+func (e *Exporter) windows(prefix, extension string) ([]time.Time, error) {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest directory: %w", err)
+	}
+
+	var starts []time.Time
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), prefix)
+		if entry.IsDir() || !ok {
+			continue
+		}
+		name, ok = strings.CutSuffix(name, extension)
+		if !ok {
+			continue
+		}
+		start, err := time.Parse(windowLayout, name)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	return starts, nil
+}
+
+// path returns the path of the file with a prefix and extension for a window
+func (e *Exporter) path(prefix, extension string, start time.Time) string {
+	return filepath.Join(e.dir, prefix+start.UTC().Format(windowLayout)+extension)
+}
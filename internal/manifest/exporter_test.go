@@ -0,0 +1,125 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntry(at time.Time, id, outcome string) Entry {
+	return Entry{Time: at, Endpoint: "/webhook/github", Destination: "https://example.com/a", DeliveryID: id, Outcome: outcome, Attempts: 1, BodySHA256: "ab"}
+}
+
+func readManifests(t *testing.T, paths []string, key Key) []*Manifest {
+	files := make([][]byte, len(paths))
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		files[i] = data
+	}
+	manifests, err := VerifyChain(files, key)
+	require.NoError(t, err)
+	return manifests
+}
+
+func TestExporter(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ParseSigningKey(AlgorithmHMAC, "s3cret")
+	require.NoError(t, err)
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	exporter, err := Open(dir, time.Hour, key, start.Add(10*time.Minute))
+	require.NoError(t, err)
+	defer exporter.Close()
+
+	require.NoError(t, exporter.Record(testEntry(start.Add(20*time.Minute), "d2", OutcomeDeadLetter)))
+	require.NoError(t, exporter.Record(testEntry(start.Add(15*time.Minute), "d1", OutcomeDelivered)))
+	require.NoError(t, exporter.Record(testEntry(start.Add(70*time.Minute), "d3", OutcomeDelivered)))
+
+	// Windows are exported once they have ended
+	paths, err := exporter.Export(start.Add(59 * time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+
+	paths, err = exporter.Export(start.Add(3 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, paths, 3)
+	assert.Equal(t, filepath.Join(dir, "manifest-20230101T120000Z.json"), paths[0])
+
+	manifests := readManifests(t, paths, key)
+	assert.Equal(t, 1, manifests[0].Delivered)
+	assert.Equal(t, 1, manifests[0].DeadLetters)
+	assert.Equal(t, "d1", manifests[0].Entries[0].DeliveryID, "Entries are in time order")
+	assert.Equal(t, 1, manifests[1].Delivered)
+
+	// Windows without deliveries are attested too
+	assert.Empty(t, manifests[2].Entries)
+	assert.True(t, manifests[2].WindowEnd.Equal(start.Add(3*time.Hour)))
+
+	// Journals are removed once exported
+	journals, err := filepath.Glob(filepath.Join(dir, "journal-*"))
+	require.NoError(t, err)
+	assert.Empty(t, journals)
+}
+
+func TestExporterResumesChain(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ParseSigningKey(AlgorithmHMAC, "s3cret")
+	require.NoError(t, err)
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	exporter, err := Open(dir, time.Hour, key, start)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Record(testEntry(start.Add(time.Minute), "d1", OutcomeDelivered)))
+	first, err := exporter.Export(start.Add(time.Hour))
+	require.NoError(t, err)
+
+	// An entry journaled but not exported before a restart is exported by the next run
+	require.NoError(t, exporter.Record(testEntry(start.Add(61*time.Minute), "d2", OutcomeDelivered)))
+	require.NoError(t, exporter.Close())
+
+	restarted, err := Open(dir, time.Hour, key, start.Add(5*time.Hour))
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	// Entries dated before the end of the chain go to the next window
+	require.NoError(t, restarted.Record(testEntry(start.Add(30*time.Minute), "late", OutcomeDelivered)))
+
+	second, err := restarted.Export(start.Add(6 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, second, 2, "The journaled window and the current one, not the windows while stopped")
+
+	manifests := readManifests(t, append(first, second...), key)
+	require.Len(t, manifests[1].Entries, 2)
+	assert.ElementsMatch(t, []string{"d2", "late"}, []string{manifests[1].Entries[0].DeliveryID, manifests[1].Entries[1].DeliveryID})
+	assert.True(t, manifests[2].WindowStart.Equal(start.Add(5*time.Hour)))
+}
+
+func TestExporterUnreadableEntries(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ParseSigningKey(AlgorithmHMAC, "s3cret")
+	require.NoError(t, err)
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	exporter, err := Open(dir, time.Hour, key, start)
+	require.NoError(t, err)
+	defer exporter.Close()
+	require.NoError(t, exporter.Record(testEntry(start.Add(time.Minute), "d1", OutcomeDelivered)))
+
+	// An entry cut short by a crash is counted as unreadable
+	file, err := os.OpenFile(filepath.Join(dir, "journal-20230101T120000Z.jsonl"), os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"time":"2023-01-01T12:02:00Z","endpo`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	paths, err := exporter.Export(start.Add(time.Hour))
+	require.NoError(t, err)
+	manifests := readManifests(t, paths, key)
+	assert.Len(t, manifests[0].Entries, 1)
+	assert.Equal(t, 1, manifests[0].Unreadable)
+}
@@ -0,0 +1,133 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Signing algorithms
+const (
+	AlgorithmHMAC    = "hmac-sha256"
+	AlgorithmEd25519 = "ed25519"
+)
+
+// Key signs manifests, or only verifies them for ed25519 public keys
+type Key interface {
+	Algorithm() string
+	Sign(data []byte) ([]byte, error)
+	Verify(data, signature []byte) bool
+}
+
+// ParseSigningKey returns the key signing manifests: the secret of hmac-sha256, or the
+// base64-encoded private key of ed25519, either its 32-byte seed or the 64-byte key
+func ParseSigningKey(algorithm, key string) (Key, error) {
+	switch algorithm {
+	case AlgorithmHMAC:
+		return newHMACKey(key)
+	case AlgorithmEd25519:
+		data, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ed25519 private key: %w", err)
+		}
+		switch len(data) {
+		case ed25519.SeedSize:
+			return ed25519Key{private: ed25519.NewKeyFromSeed(data)}, nil
+		case ed25519.PrivateKeySize:
+			return ed25519Key{private: ed25519.PrivateKey(data)}, nil
+		}
+		return nil, fmt.Errorf("invalid ed25519 private key: %d bytes (must be %d or %d)", len(data), ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+	return nil, fmt.Errorf("unknown algorithm: %s (must be %s or %s)", algorithm, AlgorithmHMAC, AlgorithmEd25519)
+}
+
+// ParseVerificationKey returns the key verifying manifests: the secret of hmac-sha256, or
+// the base64-encoded 32-byte public key of ed25519
+func ParseVerificationKey(algorithm, key string) (Key, error) {
+	switch algorithm {
+	case AlgorithmHMAC:
+		return newHMACKey(key)
+	case AlgorithmEd25519:
+		data, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ed25519 public key: %w", err)
+		}
+		if len(data) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key: %d bytes (must be %d)", len(data), ed25519.PublicKeySize)
+		}
+		return ed25519Key{public: ed25519.PublicKey(data)}, nil
+	}
+	return nil, fmt.Errorf("unknown algorithm: %s (must be %s or %s)", algorithm, AlgorithmHMAC, AlgorithmEd25519)
+}
+
+// PublicKey returns the base64-encoded public key of an ed25519 signing key, to be handed to
+// auditors, or an empty string for other keys
+func PublicKey(key Key) string {
+	k, ok := key.(ed25519Key)
+	if !ok || k.private == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(k.private.Public().(ed25519.PublicKey))
+}
+
+// hmacKey signs with HMAC-SHA256
+type hmacKey struct {
+	secret []byte
+}
+
+// newHMACKey returns an HMAC key, refusing empty secrets
+func newHMACKey(secret string) (Key, error) {
+	if secret == "" {
+		return nil, errors.New("hmac secret is empty")
+	}
+	return hmacKey{secret: []byte(secret)}, nil
+}
+
+// Algorithm returns hmac-sha256
+func (k hmacKey) Algorithm() string {
+	return AlgorithmHMAC
+}
+
+// Sign returns the HMAC-SHA256 of the data
+func (k hmacKey) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// Verify compares the signature to the HMAC-SHA256 of the data in constant time
+func (k hmacKey) Verify(data, signature []byte) bool {
+	expected, _ := k.Sign(data)
+	return hmac.Equal(expected, signature)
+}
+
+// ed25519Key signs with an ed25519 private key, or only verifies with a public key
+type ed25519Key struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// Algorithm returns ed25519
+func (k ed25519Key) Algorithm() string {
+	return AlgorithmEd25519
+}
+
+// Sign signs the data with the private key
+func (k ed25519Key) Sign(data []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errors.New("an ed25519 public key cannot sign")
+	}
+	return ed25519.Sign(k.private, data), nil
+}
+
+// Verify checks the signature of the data with the public key
+func (k ed25519Key) Verify(data, signature []byte) bool {
+	public := k.public
+	if public == nil {
+		public = k.private.Public().(ed25519.PublicKey)
+	}
+	return ed25519.Verify(public, data, signature)
+}
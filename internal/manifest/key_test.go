@@ -0,0 +1,69 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSeed is the base64 seed of the ed25519 key used by the tests
+var testSeed = base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+
+func TestHMACKey(t *testing.T) {
+	key, err := ParseSigningKey(AlgorithmHMAC, "s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmHMAC, key.Algorithm())
+	assert.Empty(t, PublicKey(key))
+
+	signature, err := key.Sign([]byte("manifest"))
+	require.NoError(t, err)
+	assert.True(t, key.Verify([]byte("manifest"), signature))
+	assert.False(t, key.Verify([]byte("tampered"), signature))
+
+	other, err := ParseVerificationKey(AlgorithmHMAC, "other")
+	require.NoError(t, err)
+	assert.False(t, other.Verify([]byte("manifest"), signature))
+
+	_, err = ParseSigningKey(AlgorithmHMAC, "")
+	assert.Error(t, err)
+}
+
+func TestEd25519Key(t *testing.T) {
+	key, err := ParseSigningKey(AlgorithmEd25519, testSeed)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmEd25519, key.Algorithm())
+
+	signature, err := key.Sign([]byte("manifest"))
+	require.NoError(t, err)
+	assert.True(t, key.Verify([]byte("manifest"), signature))
+
+	// Auditors verify with the public key only
+	public, err := ParseVerificationKey(AlgorithmEd25519, PublicKey(key))
+	require.NoError(t, err)
+	assert.True(t, public.Verify([]byte("manifest"), signature))
+	assert.False(t, public.Verify([]byte("tampered"), signature))
+	_, err = public.Sign([]byte("manifest"))
+	assert.Error(t, err)
+
+	// The 64-byte private key is accepted too
+	full := base64.StdEncoding.EncodeToString(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	fullKey, err := ParseSigningKey(AlgorithmEd25519, full)
+	require.NoError(t, err)
+	assert.Equal(t, PublicKey(key), PublicKey(fullKey))
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	_, err := ParseSigningKey(AlgorithmEd25519, "not base64!")
+	assert.Error(t, err)
+	_, err = ParseSigningKey(AlgorithmEd25519, base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+	_, err = ParseVerificationKey(AlgorithmEd25519, testSeed+"AAAA")
+	assert.Error(t, err)
+	_, err = ParseSigningKey("rsa", "key")
+	assert.Error(t, err)
+	_, err = ParseVerificationKey("rsa", "key")
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+// Package manifest exports signed manifests of the deliveries made during fixed time
+// windows, so that auditors can verify that the delivery records of a period are complete
+// and unaltered. Manifests are chained: each one holds the digest of the previous one, so
+// that a removed or replaced manifest breaks the chain.
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Version is the format version of the manifests written
+const Version = 1
+
+// Delivery outcomes
+const (
+	OutcomeDelivered  = "delivered"
+	OutcomeDeadLetter = "dead_letter"
+)
+
+// Entry is the outcome of the delivery of a webhook to a destination
+type Entry struct {
+	Time        time.Time `json:"time"`
+	Endpoint    string    `json:"endpoint"`
+	Destination string    `json:"destination"`
+
+	// DeliveryID identifies the delivery of the webhook to the destination
+	DeliveryID string `json:"delivery_id"`
+
+	// Outcome is delivered, or dead_letter for deliveries that failed for good
+	Outcome    string `json:"outcome"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"status_code,omitempty"`
+
+	// BodySHA256 is the hex SHA-256 of the body sent, before compression
+	BodySHA256 string `json:"body_sha256"`
+}
+
+// Manifest lists the deliveries of a time window, signed
+type Manifest struct {
+	Version     int       `json:"version"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	Delivered   int     `json:"delivered"`
+	DeadLetters int     `json:"dead_letters"`
+	Entries     []Entry `json:"entries"`
+
+	// Unreadable counts the journaled entries that could not be read, such as one cut short
+	// by a crash, and are missing from the manifest
+	Unreadable int `json:"unreadable,omitempty"`
+
+	// Previous is the hex SHA-256 of the previous manifest file, empty for the first one
+	Previous string `json:"previous,omitempty"`
+
+	Algorithm string `json:"algorithm"`
+
+	// Signature is the base64 signature of the manifest encoded without it
+	Signature string `json:"signature,omitempty"`
+}
+
+// signedData returns the bytes a manifest's signature covers: the manifest encoded without it
+func (m Manifest) signedData() ([]byte, error) {
+	m.Signature = ""
+	return json.Marshal(m)
+}
+
+// Sign sets the algorithm and signature of the manifest and returns the manifest file
+func (m *Manifest) Sign(key Key) ([]byte, error) {
+	m.Algorithm = key.Algorithm()
+	data, err := m.signedData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	signature, err := key.Sign(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(signature)
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// Digest returns the hex SHA-256 of a manifest file, the Previous value of the next manifest
+func Digest(file []byte) string {
+	sum := sha256.Sum256(file)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks the signature and counts of a manifest file and returns the manifest
+func Verify(file []byte, key Key) (*Manifest, error) {
+	var m Manifest
+	decoder := json.NewDecoder(bytes.NewReader(file))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if m.Algorithm != key.Algorithm() {
+		return nil, fmt.Errorf("manifest is signed with %s, not %s", m.Algorithm, key.Algorithm())
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil || len(signature) == 0 {
+		return nil, errors.New("manifest has no valid signature")
+	}
+	data, err := m.signedData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if !key.Verify(data, signature) {
+		return nil, errors.New("manifest signature does not match")
+	}
+
+	delivered, deadLetters := 0, 0
+	for _, entry := range m.Entries {
+		switch entry.Outcome {
+		case OutcomeDelivered:
+			delivered++
+		case OutcomeDeadLetter:
+			deadLetters++
+		}
+	}
+	if delivered != m.Delivered || deadLetters != m.DeadLetters {
+		return nil, fmt.Errorf("manifest counts %d delivered and %d dead letters, entries hold %d and %d", m.Delivered, m.DeadLetters, delivered, deadLetters)
+	}
+
+	return &m, nil
+}
+
+// VerifyChain verifies manifest files in window order, and checks that each one follows
+// the one before it: its Previous digest is the digest of that file, and its window does
+// not start before that file's window ended. Windows may leave gaps, while the proxy was
+// not running.
+func VerifyChain(files [][]byte, key Key) ([]*Manifest, error) {
+	manifests := make([]*Manifest, 0, len(files))
+	for i, file := range files {
+		m, err := Verify(file, key)
+		if err != nil {
+			return manifests, fmt.Errorf("manifest %d: %w", i, err)
+		}
+		if i > 0 {
+			previous := manifests[i-1]
+			if m.Previous != Digest(files[i-1]) {
+				return manifests, fmt.Errorf("manifest %d: previous digest does not match manifest %d", i, i-1)
+			}
+			if m.WindowStart.Before(previous.WindowEnd) {
+				return manifests, fmt.Errorf("manifest %d: window starts before the end of manifest %d", i, i-1)
+			}
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
@@ -0,0 +1,137 @@
+package manifest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testManifest(start time.Time, previous string) *Manifest {
+	return &Manifest{
+		Version:     Version,
+		WindowStart: start,
+		WindowEnd:   start.Add(time.Hour),
+		GeneratedAt: start.Add(time.Hour + time.Second),
+		Delivered:   1,
+		DeadLetters: 1,
+		Entries: []Entry{
+			{Time: start.Add(time.Minute), Endpoint: "/webhook/github", Destination: "https://example.com/a", DeliveryID: "d1", Outcome: OutcomeDelivered, Attempts: 1, StatusCode: 200, BodySHA256: "ab"},
+			{Time: start.Add(2 * time.Minute), Endpoint: "/webhook/github", Destination: "https://example.com/b", DeliveryID: "d2", Outcome: OutcomeDeadLetter, Attempts: 3, StatusCode: 503, BodySHA256: "cd"},
+		},
+		Previous: previous,
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key, err := ParseSigningKey(AlgorithmEd25519, testSeed)
+	require.NoError(t, err)
+	public, err := ParseVerificationKey(AlgorithmEd25519, PublicKey(key))
+	require.NoError(t, err)
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	file, err := testManifest(start, "").Sign(key)
+	require.NoError(t, err)
+
+	m, err := Verify(file, public)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmEd25519, m.Algorithm)
+	assert.Len(t, m.Entries, 2)
+	assert.True(t, m.WindowStart.Equal(start))
+}
+
+func TestVerifyTampered(t *testing.T) {
+	key, err := ParseSigningKey(AlgorithmHMAC, "s3cret")
+	require.NoError(t, err)
+	file, err := testManifest(time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), "").Sign(key)
+	require.NoError(t, err)
+
+	tamper := func(change func(m map[string]interface{})) []byte {
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal(file, &m))
+		change(m)
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"removed entry", tamper(func(m map[string]interface{}) {
+			m["entries"] = m["entries"].([]interface{})[:1]
+			m["dead_letters"] = 0
+		})},
+		{"changed outcome", tamper(func(m map[string]interface{}) {
+			m["entries"].([]interface{})[1].(map[string]interface{})["outcome"] = OutcomeDelivered
+		})},
+		{"missing signature", tamper(func(m map[string]interface{}) { delete(m, "signature") })},
+		{"unknown field", tamper(func(m map[string]interface{}) { m["note"] = "edited" })},
+		{"other algorithm", tamper(func(m map[string]interface{}) { m["algorithm"] = AlgorithmEd25519 })},
+		{"not json", []byte("manifest")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(tt.file, key)
+			assert.Error(t, err)
+		})
+	}
+
+	other, err := ParseVerificationKey(AlgorithmHMAC, "other")
+	require.NoError(t, err)
+	_, err = Verify(file, other)
+	assert.Error(t, err)
+}
+
+func TestVerifyCounts(t *testing.T) {
+	key, err := ParseSigningKey(AlgorithmHMAC, "s3cret")
+	require.NoError(t, err)
+
+	// A manifest signed with counts that do not match its entries is rejected
+	m := testManifest(time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), "")
+	m.Delivered = 2
+	file, err := m.Sign(key)
+	require.NoError(t, err)
+	_, err = Verify(file, key)
+	assert.Error(t, err)
+}
+
+func TestVerifyChain(t *testing.T) {
+	key, err := ParseSigningKey(AlgorithmHMAC, "s3cret")
+	require.NoError(t, err)
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	first, err := testManifest(start, "").Sign(key)
+	require.NoError(t, err)
+	second, err := testManifest(start.Add(time.Hour), Digest(first)).Sign(key)
+	require.NoError(t, err)
+	third, err := testManifest(start.Add(3*time.Hour), Digest(second)).Sign(key)
+	require.NoError(t, err)
+
+	manifests, err := VerifyChain([][]byte{first, second, third}, key)
+	require.NoError(t, err)
+	assert.Len(t, manifests, 3)
+
+	// A removed manifest breaks the chain
+	_, err = VerifyChain([][]byte{first, third}, key)
+	assert.Error(t, err)
+
+	// So does a manifest replaced by another one of the same window, even validly signed
+	replacement := testManifest(start.Add(time.Hour), Digest(first))
+	replacement.Entries = replacement.Entries[:1]
+	replacement.DeadLetters = 0
+	replaced, err := replacement.Sign(key)
+	require.NoError(t, err)
+	_, err = VerifyChain([][]byte{first, replaced, third}, key)
+	assert.Error(t, err)
+
+	// Windows must not overlap
+	overlapping, err := testManifest(start.Add(30*time.Minute), Digest(first)).Sign(key)
+	require.NoError(t, err)
+	_, err = VerifyChain([][]byte{first, overlapping}, key)
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+// Package mockdest implements a destination for local development: it accepts any request,
+// prints it, and answers with scripted status codes and delays, so that retries and
+// success rules can be exercised without a real receiver
+package mockdest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response is a scripted answer of the mock destination
+type Response struct {
+	Status int
+	Delay  time.Duration
+}
+
+// Config represents the behavior of the mock destination. Requests get the responses of
+// Sequence in order, then Default. A request can also pick its response with the status
+// and delay query parameters, which take precedence.
+type Config struct {
+	Default  Response
+	Sequence []Response
+}
+
+// Server is the mock destination
+type Server struct {
+	config Config
+	out    io.Writer
+
+	mu    sync.Mutex
+	count int
+}
+
+// New creates a mock destination printing the requests to out
+func New(cfg Config, out io.Writer) *Server {
+	if cfg.Default.Status == 0 {
+		cfg.Default.Status = http.StatusOK
+	}
+	return &Server{config: cfg, out: out}
+}
+
+// ParseSequence parses a comma-separated list of responses, each a status code optionally
+// followed by a delay, such as "503,503:2s,200"
+func ParseSequence(s string) ([]Response, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var responses []Response
+	for _, item := range strings.Split(s, ",") {
+		response, err := parseResponse(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// parseResponse parses a status code optionally followed by a delay, such as "503:2s"
+func parseResponse(s string) (Response, error) {
+	statusPart, delayPart, hasDelay := strings.Cut(s, ":")
+
+	status, err := parseStatus(statusPart)
+	if err != nil {
+		return Response{}, err
+	}
+
+	response := Response{Status: status}
+	if hasDelay {
+		if response.Delay, err = time.ParseDuration(delayPart); err != nil || response.Delay < 0 {
+			return Response{}, fmt.Errorf("invalid delay: %s", delayPart)
+		}
+	}
+	return response, nil
+}
+
+// parseStatus parses an HTTP status code
+func parseStatus(s string) (int, error) {
+	status, err := strconv.Atoi(s)
+	if err != nil || status < 100 || status > 599 {
+		return 0, fmt.Errorf("invalid status code: %s", s)
+	}
+	return status, nil
+}
+
+// ServeHTTP prints the request and answers with the next scripted response
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.count++
+	number := s.count
+	response := s.config.Default
+	if number <= len(s.config.Sequence) {
+		response = s.config.Sequence[number-1]
+	}
+	s.mu.Unlock()
+
+	// Query parameters override the script, so a destination URL can pick its response
+	query := r.URL.Query()
+	if status, err := parseStatus(query.Get("status")); err == nil {
+		response.Status = status
+	}
+	if delay, err := time.ParseDuration(query.Get("delay")); err == nil && delay >= 0 {
+		response.Delay = delay
+	}
+
+	s.print(number, r, body, response)
+
+	if response.Delay > 0 {
+		select {
+		case <-time.After(response.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status)
+	_, _ = fmt.Fprintf(w, `{"request":%d,"status":%d}`, number, response.Status)
+}
+
+// print writes a request, its headers sorted by name and its body, indented when it is JSON
+func (s *Server) print(number int, r *http.Request, body []byte, response Response) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "#%d %s %s %s -> %d", number, time.Now().Format("15:04:05.000"), r.Method, r.URL.RequestURI(), response.Status)
+	if response.Delay > 0 {
+		fmt.Fprintf(&b, " after %s", response.Delay)
+	}
+	b.WriteString("\n")
+
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s: %s\n", name, strings.Join(r.Header[name], ", "))
+	}
+
+	if len(body) > 0 {
+		var indented bytes.Buffer
+		if json.Indent(&indented, body, "  ", "  ") == nil {
+			body = indented.Bytes()
+		}
+		fmt.Fprintf(&b, "\n  %s\n", body)
+	}
+	b.WriteString("\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = io.WriteString(s.out, b.String())
+}
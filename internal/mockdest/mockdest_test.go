@@ -0,0 +1,74 @@
+package mockdest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSequence(t *testing.T) {
+	responses, err := ParseSequence("503, 503:2s,200")
+	require.NoError(t, err)
+	assert.Equal(t, []Response{
+		{Status: 503},
+		{Status: 503, Delay: 2 * time.Second},
+		{Status: 200},
+	}, responses)
+
+	responses, err = ParseSequence("")
+	assert.NoError(t, err)
+	assert.Empty(t, responses)
+
+	for _, invalid := range []string{"abc", "99", "600", "500:soon", "500:-1s"} {
+		_, err := ParseSequence(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestServer(t *testing.T) {
+	var out bytes.Buffer
+	mock := New(Config{
+		Default:  Response{Status: http.StatusAccepted},
+		Sequence: []Response{{Status: http.StatusServiceUnavailable}, {Status: http.StatusBadGateway, Delay: 10 * time.Millisecond}},
+	}, &out)
+
+	send := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"event":"push"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mock.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, send("/hook").Code)
+
+	start := time.Now()
+	assert.Equal(t, http.StatusBadGateway, send("/hook").Code)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	assert.Equal(t, http.StatusAccepted, send("/hook").Code)
+
+	// Query parameters pick the response
+	assert.Equal(t, http.StatusTooManyRequests, send("/hook?status=429").Code)
+
+	printed := out.String()
+	assert.Contains(t, printed, "POST /hook -> 503\n")
+	assert.Contains(t, printed, "POST /hook -> 502 after 10ms\n")
+	assert.Contains(t, printed, "POST /hook?status=429 -> 429\n")
+	assert.Contains(t, printed, "  Content-Type: application/json\n")
+	assert.Contains(t, printed, "  {\n    \"event\": \"push\"\n  }\n")
+}
+
+func TestServerDefaultStatus(t *testing.T) {
+	mock := New(Config{}, &bytes.Buffer{})
+	w := httptest.NewRecorder()
+	mock.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"request":1,"status":200}`, w.Body.String())
+}
@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"crypto/sha1" //nolint:gosec // some senders still sign with HMAC-SHA1
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// AuthName is the name of the presets built from an endpoint's auth block
+const AuthName = "auth"
+
+// Auth returns a preset verifying signatures as configured by an endpoint's auth block: in
+// the style of a provider, with the block's header and algorithm. It extracts no metadata.
+func Auth(auth config.AuthConfig) *Preset {
+	newHash := sha256.New
+	if auth.Algorithm == config.AuthAlgorithmSHA1 {
+		newHash = sha1.New
+	}
+
+	preset := &Preset{Name: AuthName}
+	switch auth.Style {
+	case config.AuthStyleStripe:
+		preset.verify = func(secret string, body []byte, header http.Header, at clock) error {
+			return verifyTimestampedHMAC(header.Get(auth.Header), newHash, secret, body, at)
+		}
+		preset.sign = func(secret string, body []byte, header http.Header, now time.Time) {
+			timestamp := strconv.FormatInt(now.Unix(), 10)
+			header.Set(auth.Header, "t="+timestamp+",v1="+hex.EncodeToString(signHash(newHash, secret, []byte(timestamp), []byte("."), body)))
+		}
+	case config.AuthStyleToken:
+		preset.verify = func(secret string, _ []byte, header http.Header, _ clock) error {
+			return verifyToken(header.Get(auth.Header), secret)
+		}
+		preset.sign = func(secret string, _ []byte, header http.Header, _ time.Time) {
+			header.Set(auth.Header, secret)
+		}
+	default:
+		prefix := auth.Algorithm + "="
+		preset.verify = func(secret string, body []byte, header http.Header, _ clock) error {
+			return verifyPrefixedHMAC(header.Get(auth.Header), prefix, newHash, secret, body)
+		}
+		preset.sign = func(secret string, body []byte, header http.Header, _ time.Time) {
+			header.Set(auth.Header, prefix+hex.EncodeToString(signHash(newHash, secret, body)))
+		}
+	}
+	return preset
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // the sha1 style is under test
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	body := []byte(`{"invoice":"in_1"}`)
+	tests := []struct {
+		name string
+		auth config.AuthConfig
+	}{
+		{"github sha256", config.AuthConfig{Style: config.AuthStyleGitHub, Algorithm: config.AuthAlgorithmSHA256, Header: "X-Signature"}},
+		{"github sha1", config.AuthConfig{Style: config.AuthStyleGitHub, Algorithm: config.AuthAlgorithmSHA1, Header: "X-Signature"}},
+		{"stripe sha256", config.AuthConfig{Style: config.AuthStyleStripe, Algorithm: config.AuthAlgorithmSHA256, Header: "X-Signature"}},
+		{"stripe sha1", config.AuthConfig{Style: config.AuthStyleStripe, Algorithm: config.AuthAlgorithmSHA1, Header: "X-Signature"}},
+		{"token", config.AuthConfig{Style: config.AuthStyleToken, Header: "X-Token"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preset := Auth(tt.auth)
+			assert.Equal(t, AuthName, preset.Name)
+
+			header := http.Header{}
+			preset.Sign(testSecret, body, header)
+			assert.NotEmpty(t, header.Get(tt.auth.Header))
+
+			index, err := preset.Verify([]string{testSecret}, body, header)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, index)
+
+			_, err = preset.Verify([]string{"other"}, body, header)
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+
+			_, err = preset.Verify([]string{testSecret}, body, http.Header{})
+			assert.ErrorIs(t, err, ErrMissingSignature)
+		})
+	}
+}
+
+func TestAuthGitHubSHA1(t *testing.T) {
+	body := []byte(`{"invoice":"in_1"}`)
+	mac := hmac.New(sha1.New, []byte(testSecret))
+	mac.Write(body)
+
+	header := http.Header{"X-Hub-Signature": {"sha1=" + hex.EncodeToString(mac.Sum(nil))}}
+	preset := Auth(config.AuthConfig{Style: config.AuthStyleGitHub, Algorithm: config.AuthAlgorithmSHA1, Header: "X-Hub-Signature"})
+
+	_, err := preset.Verify([]string{testSecret}, body, header)
+	assert.NoError(t, err)
+	assert.Equal(t, Metadata{Fields: map[string]string{}}, preset.Extract(newDelivery(body, header)))
+}
@@ -0,0 +1,416 @@
+// Package provider implements the presets of well-known webhook providers:
+// signature verification, delivery ID, event type and routing field extraction
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+)
+
+// timestampTolerance is the default maximum age of a signed timestamp, protecting against replays
+const timestampTolerance = 5 * time.Minute
+
+// Verification errors
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidTimestamp = errors.New("signature timestamp outside of tolerance")
+)
+
+// Preset describes how a provider signs its webhooks and where it puts their metadata
+type Preset struct {
+	Name string
+
+	// DeliveryIDHeader or DeliveryIDField locate the delivery ID, which stays
+	// the same when the provider redelivers a webhook
+	DeliveryIDHeader string
+	DeliveryIDField  string
+
+	// EventTypeHeader or EventTypeFields locate the event type; the first non-empty field is used
+	EventTypeHeader string
+	EventTypeFields []string
+
+	// Fields locate the routing fields of the provider's payloads, besides the event type;
+	// the first non-empty path of each field is used
+	Fields map[string][]string
+
+	// HandshakeEvents are the event types the provider sends to check an endpoint, such as
+	// when a webhook is created; they are answered locally instead of being forwarded
+	HandshakeEvents []string
+
+	// Tolerance is the maximum age of signed timestamps, 5 minutes when zero
+	Tolerance time.Duration
+
+	verify func(secret string, body []byte, header http.Header, at clock) error
+	sign   func(secret string, body []byte, header http.Header, now time.Time)
+}
+
+// Metadata is the information extracted from a webhook by a preset
+type Metadata struct {
+	DeliveryID string
+	EventType  string
+
+	// Fields are the routing fields of the webhook, by name, including its event type
+	Fields map[string]string
+}
+
+// DedupeKey returns the key identifying redeliveries of the same webhook, or an empty string
+func (m Metadata) DedupeKey(provider string) string {
+	if m.DeliveryID == "" {
+		return ""
+	}
+	return provider + ":" + m.DeliveryID
+}
+
+// clock is the time a signature is verified at, with the tolerance of its timestamp
+type clock struct {
+	now       time.Time
+	tolerance time.Duration
+}
+
+// presets lists the supported providers
+var presets = map[string]*Preset{
+	config.ProviderGitHub: {
+		Name:             config.ProviderGitHub,
+		DeliveryIDHeader: "X-GitHub-Delivery",
+		EventTypeHeader:  "X-GitHub-Event",
+		Fields: map[string][]string{
+			config.GitHubFieldAction: {"action"},
+			config.GitHubFieldRepo:   {"repository.full_name"},
+			config.GitHubFieldSender: {"sender.login"},
+			config.GitHubFieldRef:    {"ref"},
+		},
+		HandshakeEvents: []string{"ping"},
+		verify:          verifyGitHub,
+		sign:            signGitHub,
+	},
+	config.ProviderStripe: {
+		Name:            config.ProviderStripe,
+		DeliveryIDField: "id",
+		EventTypeFields: []string{"type"},
+		Fields: map[string][]string{
+			config.StripeFieldLivemode: {"livemode"},
+			config.StripeFieldObjectID: {"data.object.id"},
+		},
+		verify: verifyStripe,
+		sign:   signStripe,
+	},
+	config.ProviderGitLab: {
+		Name:             config.ProviderGitLab,
+		DeliveryIDHeader: "X-Gitlab-Event-UUID",
+		EventTypeHeader:  "X-Gitlab-Event",
+		verify:           verifyGitLab,
+		sign:             signGitLab,
+	},
+	config.ProviderSlack: {
+		Name:            config.ProviderSlack,
+		DeliveryIDField: "event_id",
+		EventTypeFields: []string{"event.type", "type"},
+		verify:          verifySlack,
+		sign:            signSlack,
+	},
+	config.ProviderShopify: {
+		Name:             config.ProviderShopify,
+		DeliveryIDHeader: "X-Shopify-Webhook-Id",
+		EventTypeHeader:  "X-Shopify-Topic",
+		verify:           verifyShopify,
+		sign:             signShopify,
+	},
+}
+
+// Get returns the preset of a provider
+func Get(name string) (*Preset, bool) {
+	preset, ok := presets[name]
+	return preset, ok
+}
+
+// WithTolerance returns a copy of the preset accepting signed timestamps up to the given
+// age, or the preset itself when the tolerance is zero
+func (p *Preset) WithTolerance(tolerance time.Duration) *Preset {
+	if tolerance == 0 {
+		return p
+	}
+	preset := *p
+	preset.Tolerance = tolerance
+	return &preset
+}
+
+// Verify checks the signature of a webhook with the endpoint's secrets, in order, and
+// returns the index of the secret that signed it. Accepting several secrets lets the
+// provider's secret be rotated without rejecting webhooks signed with the previous one.
+func (p *Preset) Verify(secrets []string, body []byte, header http.Header) (int, error) {
+	at := clock{now: time.Now(), tolerance: p.Tolerance}
+	if at.tolerance == 0 {
+		at.tolerance = timestampTolerance
+	}
+	for i, secret := range secrets {
+		err := p.verify(secret, body, header, at)
+		if err == nil {
+			return i, nil
+		}
+
+		// A missing signature or a stale timestamp does not depend on the secret
+		if !errors.Is(err, ErrInvalidSignature) {
+			return -1, err
+		}
+	}
+	return -1, ErrInvalidSignature
+}
+
+// Sign sets the signature headers of a webhook as the provider would, for example to
+// send synthetic webhooks through an endpoint
+func (p *Preset) Sign(secret string, body []byte, header http.Header) {
+	p.sign(secret, body, header, time.Now())
+}
+
+// Extract returns the delivery ID, event type and routing fields of a webhook. The body of
+// the delivery is parsed as JSON only for presets reading fields of the payload.
+func (p *Preset) Extract(delivery *webhook.Delivery) Metadata {
+	metadata := Metadata{Fields: make(map[string]string)}
+	defer func() {
+		if metadata.EventType != "" {
+			metadata.Fields[config.ProviderFieldEvent] = metadata.EventType
+		}
+	}()
+
+	if p.DeliveryIDHeader != "" {
+		metadata.DeliveryID = delivery.Header(p.DeliveryIDHeader)
+	}
+	if p.EventTypeHeader != "" {
+		metadata.EventType = delivery.Header(p.EventTypeHeader)
+	}
+
+	if p.DeliveryIDField == "" && len(p.EventTypeFields) == 0 && len(p.Fields) == 0 {
+		return metadata
+	}
+
+	payload := delivery.JSONObject()
+	if payload == nil {
+		return metadata
+	}
+	if p.DeliveryIDField != "" {
+		metadata.DeliveryID = stringField(payload, p.DeliveryIDField)
+	}
+	for _, field := range p.EventTypeFields {
+		if metadata.EventType = stringField(payload, field); metadata.EventType != "" {
+			break
+		}
+	}
+	for name, paths := range p.Fields {
+		for _, path := range paths {
+			if value := stringField(payload, path); value != "" {
+				metadata.Fields[name] = value
+				break
+			}
+		}
+	}
+
+	return metadata
+}
+
+// Handshake reports whether a webhook only checks the endpoint, from its metadata
+func (p *Preset) Handshake(metadata Metadata) bool {
+	for _, event := range p.HandshakeEvents {
+		if metadata.EventType == event {
+			return true
+		}
+	}
+	return false
+}
+
+// stringField returns the string or boolean at a dotted path of a JSON object, or an empty string
+func stringField(payload map[string]interface{}, path string) string {
+	var value interface{} = payload
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// sign returns the HMAC-SHA256 of the message parts
+func sign(secret string, parts ...[]byte) []byte {
+	return signHash(sha256.New, secret, parts...)
+}
+
+// signHash returns the HMAC of the message parts with the given hash
+func signHash(newHash func() hash.Hash, secret string, parts ...[]byte) []byte {
+	mac := hmac.New(newHash, []byte(secret))
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return mac.Sum(nil)
+}
+
+// checkTimestamp checks that a Unix timestamp is within the tolerance
+func checkTimestamp(timestamp string, at clock) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	age := at.now.Sub(time.Unix(seconds, 0))
+	if age > at.tolerance || age < -at.tolerance {
+		return ErrInvalidTimestamp
+	}
+	return nil
+}
+
+// verifyGitHub checks the X-Hub-Signature-256 header: sha256=hex(HMAC(body))
+func verifyGitHub(secret string, body []byte, header http.Header, _ clock) error {
+	return verifyPrefixedHMAC(header.Get("X-Hub-Signature-256"), "sha256=", sha256.New, secret, body)
+}
+
+// verifyPrefixedHMAC checks a signature made of a prefix and the hex HMAC of the body
+func verifyPrefixedHMAC(signature, prefix string, newHash func() hash.Hash, secret string, body []byte) error {
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	expected := prefix + hex.EncodeToString(signHash(newHash, secret, body))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyStripe checks the Stripe-Signature header: t=timestamp,v1=hex(HMAC(timestamp.body))
+func verifyStripe(secret string, body []byte, header http.Header, at clock) error {
+	return verifyTimestampedHMAC(header.Get("Stripe-Signature"), sha256.New, secret, body, at)
+}
+
+// verifyTimestampedHMAC checks a signature made of a timestamp and the hex HMACs of
+// timestamp.body, as t=timestamp,v1=hex
+func verifyTimestampedHMAC(signature string, newHash func() hash.Hash, secret string, body []byte, at clock) error {
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	if err := checkTimestamp(timestamp, at); err != nil {
+		return err
+	}
+
+	// Stripe sends one v1 signature per active secret while rolling secrets
+	expected := hex.EncodeToString(signHash(newHash, secret, []byte(timestamp), []byte("."), body))
+	for _, candidate := range signatures {
+		if hmac.Equal([]byte(candidate), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// verifyGitLab checks the X-Gitlab-Token header, which holds the secret itself
+func verifyGitLab(secret string, _ []byte, header http.Header, _ clock) error {
+	return verifyToken(header.Get("X-Gitlab-Token"), secret)
+}
+
+// verifyToken checks a token holding the secret itself
+func verifyToken(token, secret string) error {
+	if token == "" {
+		return ErrMissingSignature
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifySlack checks the X-Slack-Signature header: v0=hex(HMAC(v0:timestamp:body))
+func verifySlack(secret string, body []byte, header http.Header, at clock) error {
+	signature := header.Get("X-Slack-Signature")
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+
+	if err := checkTimestamp(timestamp, at); err != nil {
+		return err
+	}
+
+	expected := "v0=" + hex.EncodeToString(sign(secret, []byte("v0:"+timestamp+":"), body))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyShopify checks the X-Shopify-Hmac-Sha256 header: base64(HMAC(body))
+func verifyShopify(secret string, body []byte, header http.Header, _ clock) error {
+	signature := header.Get("X-Shopify-Hmac-Sha256")
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	expected := base64.StdEncoding.EncodeToString(sign(secret, body))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signGitHub sets the X-Hub-Signature-256 header
+func signGitHub(secret string, body []byte, header http.Header, _ time.Time) {
+	header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(sign(secret, body)))
+}
+
+// signStripe sets the Stripe-Signature header
+func signStripe(secret string, body []byte, header http.Header, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header.Set("Stripe-Signature", "t="+timestamp+",v1="+hex.EncodeToString(sign(secret, []byte(timestamp), []byte("."), body)))
+}
+
+// signGitLab sets the X-Gitlab-Token header
+func signGitLab(secret string, _ []byte, header http.Header, _ time.Time) {
+	header.Set("X-Gitlab-Token", secret)
+}
+
+// signSlack sets the X-Slack-Request-Timestamp and X-Slack-Signature headers
+func signSlack(secret string, body []byte, header http.Header, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header.Set("X-Slack-Request-Timestamp", timestamp)
+	header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(sign(secret, []byte("v0:"+timestamp+":"), body)))
+}
+
+// signShopify sets the X-Shopify-Hmac-Sha256 header
+func signShopify(secret string, body []byte, header http.Header, _ time.Time) {
+	header.Set("X-Shopify-Hmac-Sha256", base64.StdEncoding.EncodeToString(sign(secret, body)))
+}
@@ -0,0 +1,259 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "s3cr3t"
+
+// hmacHex returns the hex HMAC-SHA256 of a message with the test secret
+func hmacHex(message string) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGet(t *testing.T) {
+	for _, name := range []string{
+		config.ProviderGitHub, config.ProviderStripe, config.ProviderGitLab, config.ProviderSlack, config.ProviderShopify,
+	} {
+		preset, ok := Get(name)
+		require.True(t, ok, name)
+		assert.Equal(t, name, preset.Name)
+	}
+
+	_, ok := Get("bitbucket")
+	assert.False(t, ok)
+}
+
+func TestVerify(t *testing.T) {
+	body := `{"id":"evt_1","type":"invoice.paid"}`
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(body))
+	shopifySignature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name     string
+		provider string
+		header   http.Header
+		expected error
+	}{
+		{name: "GitHub valid", provider: config.ProviderGitHub, header: http.Header{"X-Hub-Signature-256": {"sha256=" + hmacHex(body)}}},
+		{name: "GitHub invalid", provider: config.ProviderGitHub, header: http.Header{"X-Hub-Signature-256": {"sha256=" + hmacHex("other")}}, expected: ErrInvalidSignature},
+		{name: "GitHub missing", provider: config.ProviderGitHub, header: http.Header{}, expected: ErrMissingSignature},
+		{name: "Stripe valid", provider: config.ProviderStripe, header: http.Header{"Stripe-Signature": {"t=" + timestamp + ",v1=" + hmacHex(timestamp+"."+body)}}},
+		{
+			name:     "Stripe valid among rolled secrets",
+			provider: config.ProviderStripe,
+			header:   http.Header{"Stripe-Signature": {"t=" + timestamp + ",v1=" + hmacHex("old") + ",v1=" + hmacHex(timestamp+"."+body)}},
+		},
+		{name: "Stripe invalid", provider: config.ProviderStripe, header: http.Header{"Stripe-Signature": {"t=" + timestamp + ",v1=" + hmacHex(body)}}, expected: ErrInvalidSignature},
+		{name: "Stripe stale", provider: config.ProviderStripe, header: http.Header{"Stripe-Signature": {"t=" + stale + ",v1=" + hmacHex(stale+"."+body)}}, expected: ErrInvalidTimestamp},
+		{name: "Stripe malformed", provider: config.ProviderStripe, header: http.Header{"Stripe-Signature": {"v1=" + hmacHex(body)}}, expected: ErrInvalidSignature},
+		{name: "GitLab valid", provider: config.ProviderGitLab, header: http.Header{"X-Gitlab-Token": {testSecret}}},
+		{name: "GitLab invalid", provider: config.ProviderGitLab, header: http.Header{"X-Gitlab-Token": {"guess"}}, expected: ErrInvalidSignature},
+		{
+			name:     "Slack valid",
+			provider: config.ProviderSlack,
+			header:   http.Header{"X-Slack-Signature": {"v0=" + hmacHex("v0:"+timestamp+":"+body)}, "X-Slack-Request-Timestamp": {timestamp}},
+		},
+		{
+			name:     "Slack stale",
+			provider: config.ProviderSlack,
+			header:   http.Header{"X-Slack-Signature": {"v0=" + hmacHex("v0:"+stale+":"+body)}, "X-Slack-Request-Timestamp": {stale}},
+			expected: ErrInvalidTimestamp,
+		},
+		{name: "Slack missing timestamp", provider: config.ProviderSlack, header: http.Header{"X-Slack-Signature": {"v0=" + hmacHex(body)}}, expected: ErrMissingSignature},
+		{name: "Shopify valid", provider: config.ProviderShopify, header: http.Header{"X-Shopify-Hmac-Sha256": {shopifySignature}}},
+		{name: "Shopify invalid", provider: config.ProviderShopify, header: http.Header{"X-Shopify-Hmac-Sha256": {hmacHex(body)}}, expected: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preset, ok := Get(tt.provider)
+			require.True(t, ok)
+
+			_, err := preset.Verify([]string{testSecret}, []byte(body), tt.header)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestVerifyTolerance(t *testing.T) {
+	body := `{"id":"evt_1","type":"invoice.paid"}`
+	timestamp := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	header := http.Header{"Stripe-Signature": {"t=" + timestamp + ",v1=" + hmacHex(timestamp+"."+body)}}
+	stripe, _ := Get(config.ProviderStripe)
+
+	_, err := stripe.Verify([]string{testSecret}, []byte(body), header)
+	assert.ErrorIs(t, err, ErrInvalidTimestamp)
+
+	// A wider tolerance accepts the delayed webhook, without changing the shared preset
+	_, err = stripe.WithTolerance(15*time.Minute).Verify([]string{testSecret}, []byte(body), header)
+	assert.NoError(t, err)
+	assert.Zero(t, stripe.Tolerance)
+	assert.Same(t, stripe, stripe.WithTolerance(0))
+
+	_, err = stripe.WithTolerance(time.Minute).Verify([]string{testSecret}, []byte(body), header)
+	assert.ErrorIs(t, err, ErrInvalidTimestamp)
+}
+
+func TestVerifyRotatedSecrets(t *testing.T) {
+	body := `{"event":"push"}`
+	header := http.Header{"X-Hub-Signature-256": {"sha256=" + hmacHex(body)}}
+	preset, _ := Get(config.ProviderGitHub)
+
+	index, err := preset.Verify([]string{testSecret, "previous"}, []byte(body), header)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+
+	// Webhooks signed with the previous secret are accepted during the rotation
+	index, err = preset.Verify([]string{"current", testSecret}, []byte(body), header)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+
+	index, err = preset.Verify([]string{"current", "previous"}, []byte(body), header)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	assert.Equal(t, -1, index)
+
+	_, err = preset.Verify([]string{"current", testSecret}, []byte(body), http.Header{})
+	assert.ErrorIs(t, err, ErrMissingSignature)
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		body     string
+		header   http.Header
+		expected Metadata
+	}{
+		{
+			name:     "GitHub headers",
+			provider: config.ProviderGitHub,
+			body:     `{}`,
+			header:   http.Header{"X-Github-Delivery": {"72d3162e"}, "X-Github-Event": {"push"}},
+			expected: Metadata{DeliveryID: "72d3162e", EventType: "push", Fields: map[string]string{"event": "push"}},
+		},
+		{
+			name:     "GitHub routing fields",
+			provider: config.ProviderGitHub,
+			body:     `{"action":"opened","repository":{"full_name":"octo/hello"},"sender":{"login":"octocat"},"ref":"refs/heads/main"}`,
+			header:   http.Header{"X-Github-Delivery": {"72d3162e"}, "X-Github-Event": {"pull_request"}},
+			expected: Metadata{DeliveryID: "72d3162e", EventType: "pull_request", Fields: map[string]string{
+				"event": "pull_request", "action": "opened", "repo": "octo/hello", "sender": "octocat", "ref": "refs/heads/main",
+			}},
+		},
+		{
+			name:     "Stripe body",
+			provider: config.ProviderStripe,
+			body:     `{"id":"evt_1","type":"invoice.paid"}`,
+			expected: Metadata{DeliveryID: "evt_1", EventType: "invoice.paid", Fields: map[string]string{"event": "invoice.paid"}},
+		},
+		{
+			name:     "Stripe routing fields",
+			provider: config.ProviderStripe,
+			body:     `{"id":"evt_1","type":"invoice.paid","livemode":false,"data":{"object":{"id":"in_1"}}}`,
+			expected: Metadata{DeliveryID: "evt_1", EventType: "invoice.paid", Fields: map[string]string{
+				"event": "invoice.paid", "livemode": "false", "object_id": "in_1",
+			}},
+		},
+		{
+			name:     "Slack event callback",
+			provider: config.ProviderSlack,
+			body:     `{"type":"event_callback","event_id":"Ev1","event":{"type":"app_mention"}}`,
+			expected: Metadata{DeliveryID: "Ev1", EventType: "app_mention", Fields: map[string]string{"event": "app_mention"}},
+		},
+		{
+			name:     "Slack url verification",
+			provider: config.ProviderSlack,
+			body:     `{"type":"url_verification","challenge":"abc"}`,
+			expected: Metadata{EventType: "url_verification", Fields: map[string]string{"event": "url_verification"}},
+		},
+		{
+			name:     "Invalid JSON body",
+			provider: config.ProviderStripe,
+			body:     `not json`,
+			expected: Metadata{Fields: map[string]string{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preset, ok := Get(tt.provider)
+			require.True(t, ok)
+
+			assert.Equal(t, tt.expected, preset.Extract(newDelivery([]byte(tt.body), tt.header)))
+		})
+	}
+}
+
+// newDelivery returns the delivery of a webhook received with the given body and headers
+func newDelivery(body []byte, header http.Header) *webhook.Delivery {
+	headers := make(map[string]string, len(header))
+	for k := range header {
+		headers[k] = header.Get(k)
+	}
+	return webhook.New("/webhook", body, headers)
+}
+
+func TestMetadataDedupeKey(t *testing.T) {
+	assert.Equal(t, "github:72d3162e", Metadata{DeliveryID: "72d3162e"}.DedupeKey(config.ProviderGitHub))
+	assert.Empty(t, Metadata{EventType: "push"}.DedupeKey(config.ProviderGitHub))
+}
+
+func TestHandshake(t *testing.T) {
+	github, _ := Get(config.ProviderGitHub)
+	assert.True(t, github.Handshake(Metadata{EventType: "ping"}))
+	assert.False(t, github.Handshake(Metadata{EventType: "push"}))
+
+	stripe, _ := Get(config.ProviderStripe)
+	assert.False(t, stripe.Handshake(Metadata{EventType: "ping"}))
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"invoice.paid"}`)
+	for _, name := range []string{
+		config.ProviderGitHub, config.ProviderStripe, config.ProviderGitLab, config.ProviderSlack, config.ProviderShopify,
+	} {
+		t.Run(name, func(t *testing.T) {
+			preset, ok := Get(name)
+			require.True(t, ok)
+
+			header := http.Header{}
+			preset.Sign(testSecret, body, header)
+
+			_, err := preset.Verify([]string{testSecret}, body, header)
+			assert.NoError(t, err)
+			_, err = preset.Verify([]string{"other"}, body, header)
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		})
+	}
+}
+
+func TestProviderFields(t *testing.T) {
+	// The configuration validates field filters against the fields the presets extract
+	for name, preset := range presets {
+		fields := map[string]bool{config.ProviderFieldEvent: true}
+		for field := range preset.Fields {
+			fields[field] = true
+		}
+		assert.Equal(t, config.ProviderFields[name], fields, name)
+	}
+}
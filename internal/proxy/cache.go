@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// cachedResponse is a successful response of a destination, reused until it expires
+type cachedResponse struct {
+	statusCode int
+	body       []byte
+	expires    time.Time
+}
+
+// responseCache holds the successful responses of the destinations with a cache, keyed
+// by URL including its query, so that bursts of identical events do not repeat the request
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+// newResponseCache creates an empty response cache
+func newResponseCache() *responseCache {
+	return &responseCache{entries: make(map[string]cachedResponse)}
+}
+
+// get returns the response cached for a URL, unless it expired
+func (c *responseCache) get(url string, now time.Time) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[url]
+	if !ok {
+		return cachedResponse{}, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, url)
+		return cachedResponse{}, false
+	}
+	return entry, true
+}
+
+// set caches the response of a URL until it expires
+func (c *responseCache) set(url string, statusCode int, body []byte, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[url] = cachedResponse{statusCode: statusCode, body: body, expires: expires}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	cache := newResponseCache()
+	now := time.Now()
+
+	_, ok := cache.get("https://example.com/lookup?id=1", now)
+	assert.False(t, ok)
+
+	cache.set("https://example.com/lookup?id=1", http.StatusOK, []byte("found"), now.Add(time.Minute))
+
+	cached, ok := cache.get("https://example.com/lookup?id=1", now.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, cachedResponse{statusCode: http.StatusOK, body: []byte("found"), expires: now.Add(time.Minute)}, cached)
+
+	// The query is part of the key
+	_, ok = cache.get("https://example.com/lookup?id=2", now)
+	assert.False(t, ok)
+
+	_, ok = cache.get("https://example.com/lookup?id=1", now.Add(time.Minute))
+	assert.False(t, ok)
+}
+
+func TestForwardToDestinationCache(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:     server.URL + "/lookup?id=1",
+		Method:  http.MethodGet,
+		Timeout: time.Second,
+		Cache:   &config.CacheConfig{TTL: time.Minute},
+	}
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+
+	for range 3 {
+		handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), map[string]string{}, time.Now())
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	metrics := handler.GetMetrics()
+	assert.Equal(t, int64(3), metrics["successful_requests"])
+	assert.Equal(t, int64(2), metrics["cache_hits"])
+
+	// Failed responses are not cached
+	failing := dest
+	failing.URL = server.URL + "/lookup?fail=1"
+	handler = NewProxyHandler([]config.DestinationConfig{failing}, logger)
+	for range 2 {
+		handler.forwardToDestination(context.Background(), failing, []byte(`{"event":"test"}`), map[string]string{}, time.Now())
+	}
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, int64(0), handler.GetMetrics()["cache_hits"])
+}
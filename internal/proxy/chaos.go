@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// chaosFaults are the faults injected into a delivery
+type chaosFaults struct {
+	drop      bool
+	delay     time.Duration
+	duplicate bool
+}
+
+// drawChaosFaults draws the faults to inject into a delivery. A dropped delivery is
+// neither delayed nor duplicated.
+func drawChaosFaults(c config.ChaosConfig) chaosFaults {
+	if rand.Float64() < c.DropRate {
+		return chaosFaults{drop: true}
+	}
+
+	var faults chaosFaults
+	if c.MaxDelay > 0 && rand.Float64() < c.DelayRate {
+		faults.delay = rand.N(c.MaxDelay + 1)
+	}
+	faults.duplicate = rand.Float64() < c.DuplicateRate
+	return faults
+}
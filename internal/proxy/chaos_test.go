@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrawChaosFaults(t *testing.T) {
+	assert.Equal(t, chaosFaults{}, drawChaosFaults(config.ChaosConfig{MaxDelay: time.Second}))
+	assert.Equal(t, chaosFaults{drop: true}, drawChaosFaults(config.ChaosConfig{DropRate: 1, DelayRate: 1, DuplicateRate: 1, MaxDelay: time.Second}))
+	assert.True(t, drawChaosFaults(config.ChaosConfig{DuplicateRate: 1}).duplicate)
+
+	for i := 0; i < 100; i++ {
+		faults := drawChaosFaults(config.ChaosConfig{DelayRate: 1, MaxDelay: 10 * time.Millisecond})
+		assert.LessOrEqual(t, faults.delay, 10*time.Millisecond)
+		assert.False(t, faults.drop)
+	}
+}
+
+func TestForwardToDestinationChaos(t *testing.T) {
+	tests := []struct {
+		name     string
+		chaos    config.ChaosConfig
+		expected int32
+	}{
+		{name: "no faults", chaos: config.ChaosConfig{}, expected: 1},
+		{name: "drop", chaos: config.ChaosConfig{DropRate: 1}, expected: 0},
+		{name: "duplicate", chaos: config.ChaosConfig{DuplicateRate: 1}, expected: 2},
+		{name: "delay", chaos: config.ChaosConfig{DelayRate: 1, MaxDelay: 20 * time.Millisecond}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logrus.New()
+			log.SetOutput(io.Discard)
+
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: time.Second, Chaos: &tt.chaos}
+			handler := NewProxyHandler([]config.DestinationConfig{dest}, log)
+			handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+			assert.Equal(t, tt.expected, calls.Load())
+		})
+	}
+}
+
+func TestForwardToDestinationChaosDelayCancelled(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: "http://127.0.0.1:1", Method: "POST", Timeout: time.Second,
+		Chaos: &config.ChaosConfig{DelayRate: 1, MaxDelay: time.Hour}}
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, log)
+
+	// The injected delay ends with the delivery's context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	handler.forwardToDestination(ctx, dest, []byte(`{"event":"test"}`), nil, time.Now())
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
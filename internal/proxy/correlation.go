@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Correlation headers
+const (
+	headerRequestID    = "X-Request-Id"
+	headerB3TraceID    = "X-B3-TraceId"
+	headerB3SpanID     = "X-B3-SpanId"
+	headerB3ParentSpan = "X-B3-ParentSpanId"
+	headerB3Sampled    = "X-B3-Sampled"
+)
+
+// Correlation identifies the request a webhook was received with and the trace it is
+// forwarded in, for the destinations asking for trace_headers
+type Correlation struct {
+	RequestID string
+
+	// TraceID and SpanID are the hex-encoded IDs of the span forwarding the webhook
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context carrying the ID of the request a webhook was
+// received with
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by the context, or an empty string
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// webhookIDKey is the context key of the webhook ID
+type webhookIDKey struct{}
+
+// withWebhookID returns a copy of the context carrying the ID of the webhook being forwarded,
+// added to the log lines of its deliveries
+func withWebhookID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, webhookIDKey{}, id)
+}
+
+// WebhookID returns the ID of the webhook forwarded within the context, or an empty string
+func WebhookID(ctx context.Context) string {
+	id, _ := ctx.Value(webhookIDKey{}).(string)
+	return id
+}
+
+// listenerKey is the context key of the name of the listener a webhook was received on
+type listenerKey struct{}
+
+// WithListener returns a copy of the context carrying the name of the listener a webhook
+// was received on, identifying its tenant. It is added to the log lines and the hook events
+// of the webhook's deliveries.
+func WithListener(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, listenerKey{}, name)
+}
+
+// Listener returns the name of the listener carried by the context, or an empty string
+func Listener(ctx context.Context) string {
+	name, _ := ctx.Value(listenerKey{}).(string)
+	return name
+}
+
+// newCorrelation returns the correlation of a webhook forwarded within a context, with its
+// request ID and in its span. Without a valid span, as when tracing is disabled, the webhook
+// gets a trace of its own.
+func newCorrelation(ctx context.Context) Correlation {
+	correlation := Correlation{RequestID: RequestID(ctx)}
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	if spanContext.IsValid() {
+		correlation.TraceID = spanContext.TraceID().String()
+		correlation.SpanID = spanContext.SpanID().String()
+		correlation.Sampled = spanContext.IsSampled()
+		return correlation
+	}
+
+	correlation.TraceID = randomID(16)
+	correlation.SpanID = randomID(8)
+	return correlation
+}
+
+// headers returns the webhook's headers with the correlation headers the destination asks
+// for. Each delivery is a child span of the forwarding span in B3.
+func (c Correlation) headers(dest config.DestinationConfig, headers map[string]string) map[string]string {
+	if len(dest.TraceHeaders) == 0 {
+		return headers
+	}
+
+	withCorrelation := make(map[string]string, len(headers)+5)
+	for k, v := range headers {
+		withCorrelation[k] = v
+	}
+	for _, kind := range dest.TraceHeaders {
+		switch kind {
+		case config.TraceHeadersRequestID:
+			if c.RequestID != "" {
+				withCorrelation[headerRequestID] = c.RequestID
+			}
+		case config.TraceHeadersB3:
+			if c.TraceID == "" {
+				continue
+			}
+			withCorrelation[headerB3TraceID] = c.TraceID
+			withCorrelation[headerB3SpanID] = randomID(8)
+			withCorrelation[headerB3ParentSpan] = c.SpanID
+			withCorrelation[headerB3Sampled] = "0"
+			if c.Sampled {
+				withCorrelation[headerB3Sampled] = "1"
+			}
+		}
+	}
+	return withCorrelation
+}
+
+// randomID returns a random hex-encoded ID of the given number of bytes
+func randomID(size int) string {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedContext returns a context carrying a request ID and a sampled span
+func tracedContext() context.Context {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	return WithRequestID(ctx, "req-1")
+}
+
+func TestRequestID(t *testing.T) {
+	assert.Equal(t, "req-1", RequestID(WithRequestID(context.Background(), "req-1")))
+	assert.Empty(t, RequestID(context.Background()))
+}
+
+func TestNewCorrelation(t *testing.T) {
+
+	assert.Equal(t, Correlation{
+		RequestID: "req-1",
+		TraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:    "00f067aa0ba902b7",
+		Sampled:   true,
+	}, newCorrelation(tracedContext()))
+
+	// Without a span, the webhook gets a trace of its own
+	correlation := newCorrelation(context.Background())
+	assert.Empty(t, correlation.RequestID)
+	assert.Len(t, correlation.TraceID, 32)
+	assert.Len(t, correlation.SpanID, 16)
+	assert.False(t, correlation.Sampled)
+}
+
+func TestCorrelationHeaders(t *testing.T) {
+	correlation := Correlation{RequestID: "req-1", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true}
+	headers := map[string]string{"Content-Type": "application/json"}
+
+	// Destinations without trace headers receive the webhook's headers
+	assert.Equal(t, headers, correlation.headers(config.DestinationConfig{}, headers))
+
+	withCorrelation := correlation.headers(config.DestinationConfig{
+		TraceHeaders: []string{config.TraceHeadersRequestID, config.TraceHeadersB3},
+	}, headers)
+	assert.Equal(t, "application/json", withCorrelation["Content-Type"])
+	assert.Equal(t, "req-1", withCorrelation["X-Request-Id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", withCorrelation["X-B3-TraceId"])
+	assert.Equal(t, "00f067aa0ba902b7", withCorrelation["X-B3-ParentSpanId"])
+	assert.Len(t, withCorrelation["X-B3-SpanId"], 16)
+	assert.NotEqual(t, "00f067aa0ba902b7", withCorrelation["X-B3-SpanId"])
+	assert.Equal(t, "1", withCorrelation["X-B3-Sampled"])
+
+	// The webhook's headers are shared by the destinations and left untouched
+	assert.Len(t, headers, 1)
+
+	// A webhook forwarded without a correlation gets no correlation headers
+	assert.Len(t, Correlation{}.headers(config.DestinationConfig{
+		TraceHeaders: []string{config.TraceHeadersRequestID, config.TraceHeadersB3},
+	}, headers), 1)
+}
+
+func TestForwardWebhookWithCorrelation(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	received := make(chan http.Header, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: server.URL + "/b3", Method: "POST", Timeout: time.Second, TraceHeaders: []string{config.TraceHeadersB3}},
+		{URL: server.URL + "/plain", Method: "POST", Timeout: time.Second},
+	}, log)
+	handler.ForwardWebhook(tracedContext(), webhook.New("/webhook", []byte(`{}`), map[string]string{}))
+
+	traced := 0
+	for i := 0; i < 2; i++ {
+		select {
+		case header := <-received:
+			assert.Empty(t, header.Get("X-Request-Id"))
+			if header.Get("X-B3-TraceId") != "" {
+				traced++
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", header.Get("X-B3-TraceId"))
+				assert.Equal(t, "1", header.Get("X-B3-Sampled"))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected the webhook to be forwarded to both destinations")
+		}
+	}
+	assert.Equal(t, 1, traced)
+}
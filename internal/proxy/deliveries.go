@@ -0,0 +1,303 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// DeliveryPool bounds the deliveries running at once, across all destinations and to each
+// destination. A delivery takes a slot in both for each of its attempts, and releases it
+// while it waits between attempts: for a maintenance window or a DNS outage, or for a retry
+// delay in the retry queue, without a goroutine. Deliveries without a free slot wait in the
+// queue. It is shared by the handlers, so that the bounds hold across the endpoints.
+type DeliveryPool struct {
+	config config.DeliveriesConfig
+
+	// global is the semaphore of all deliveries, nil when unbounded
+	global chan struct{}
+
+	mu           sync.Mutex
+	destinations map[string]chan struct{} // semaphores by destination, when bounded
+
+	queued   atomic.Int64
+	running  atomic.Int64
+	dropped  atomic.Int64
+	rejected atomic.Int64
+
+	// retries holds the deliveries waiting for a retry
+	retries *retryQueue
+
+	// drained counts the attempts done, to hint rejected senders when to retry
+	drained *drainRate
+	now     func() time.Time
+}
+
+// NewDeliveryPool creates a pool with the given bounds
+func NewDeliveryPool(cfg config.DeliveriesConfig) *DeliveryPool {
+	if cfg.MaxRetryAfter <= 0 {
+		cfg.MaxRetryAfter = config.DefaultDeliveriesMaxRetryAfter
+	}
+	pool := &DeliveryPool{
+		config:       cfg,
+		destinations: make(map[string]chan struct{}),
+		drained:      &drainRate{},
+		now:          time.Now,
+	}
+	pool.retries = newRetryQueue(pool)
+	if cfg.MaxConcurrent > 0 {
+		pool.global = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return pool
+}
+
+// Admit reports whether an endpoint may accept a webhook: false when the queue is full and
+// the overflow rejects webhooks, the rejection being counted
+func (p *DeliveryPool) Admit() bool {
+	if p.config.Overflow != config.DeliveriesOverflowReject || p.queued.Load() < int64(p.config.QueueSize) {
+		return true
+	}
+	p.rejected.Add(1)
+	return false
+}
+
+// Go runs fn in its own goroutine, with a context carrying the delivery's slot for the
+// destination, taken with acquireSlot for each attempt. It returns false, without running
+// fn, when there is no free slot and the queue is full, the delivery being counted as
+// dropped. With wait, the delivery is never dropped and waits in the queue however full,
+// for deliveries whose webhook is already persisted.
+func (p *DeliveryPool) Go(ctx context.Context, destination string, wait bool, fn func(ctx context.Context)) bool {
+	slot := &deliverySlot{pool: p, perDestination: p.semaphore(destination)}
+
+	// The first attempt runs at once when there is a free slot, and is queued otherwise
+	if tryAcquire(slot.perDestination, p.global) {
+		slot.held = true
+		p.running.Add(1)
+	} else if !wait {
+		if p.queued.Add(1) > int64(p.config.QueueSize) {
+			p.queued.Add(-1)
+			p.dropped.Add(1)
+			return false
+		}
+		slot.queued = true
+	}
+
+	go func() {
+		defer slot.release()
+		fn(context.WithValue(ctx, deliverySlotKey{}, slot))
+	}()
+	return true
+}
+
+// deliverySlotKey is the context key of the slot of a delivery
+type deliverySlotKey struct{}
+
+// deliverySlot is the place of a delivery in the pool. It is only used by the delivery's
+// goroutine.
+type deliverySlot struct {
+	pool           *DeliveryPool
+	perDestination chan struct{}
+
+	// held is set while the delivery has a slot, and queued while it is counted in the
+	// queue without one
+	held   bool
+	queued bool
+}
+
+// acquireSlot takes the slot of the delivery running within the context for an attempt,
+// waiting in the queue for a free one, unless the context is done first. Deliveries
+// outside a pool run at once.
+func acquireSlot(ctx context.Context) error {
+	slot, _ := ctx.Value(deliverySlotKey{}).(*deliverySlot)
+	if slot == nil || slot.held {
+		return nil
+	}
+	p := slot.pool
+
+	if !slot.queued {
+		p.queued.Add(1)
+	}
+	slot.queued = false
+	defer p.queued.Add(-1)
+
+	// Take the destination's slot first, so that deliveries waiting for a busy destination
+	// do not hold slots the other destinations could use
+	if err := acquireContext(ctx, slot.perDestination); err != nil {
+		return err
+	}
+	if err := acquireContext(ctx, p.global); err != nil {
+		release(slot.perDestination)
+		return err
+	}
+	slot.held = true
+	p.running.Add(1)
+	return nil
+}
+
+// retryQueueOf returns the retry queue of the pool the delivery running within the context
+// takes its slots from, nil outside a pool
+func retryQueueOf(ctx context.Context) *retryQueue {
+	if slot, _ := ctx.Value(deliverySlotKey{}).(*deliverySlot); slot != nil {
+		return slot.pool.retries
+	}
+	return nil
+}
+
+// releaseSlot releases the slot of the delivery running within the context while it
+// waits, or its place in the queue
+func releaseSlot(ctx context.Context) {
+	if slot, _ := ctx.Value(deliverySlotKey{}).(*deliverySlot); slot != nil {
+		slot.release()
+	}
+}
+
+// release frees the slot of the delivery, or its place in the queue
+func (s *deliverySlot) release() {
+	if s.queued {
+		s.queued = false
+		s.pool.queued.Add(-1)
+	}
+	if !s.held {
+		return
+	}
+	s.held = false
+	s.pool.running.Add(-1)
+	release(s.pool.global)
+	release(s.perDestination)
+	s.pool.drained.record(s.pool.now())
+
+	// Retries held back for the deliveries waiting may take their turn
+	s.pool.retries.release()
+}
+
+// RetryAfter returns how long a sender rejected because the queue is full should wait
+// before retrying: the time the queue takes to drain at the rate attempts were done over
+// the last seconds, at least a second and at most the configured maximum. Spreading the
+// retries over the drain time keeps them from filling the queue again at once.
+func (p *DeliveryPool) RetryAfter() time.Duration {
+	rate := p.drained.rate(p.now())
+	if rate == 0 {
+		return p.config.MaxRetryAfter
+	}
+	wait := time.Duration(float64(p.queued.Load()) / rate * float64(time.Second))
+	return min(max(wait, time.Second), p.config.MaxRetryAfter)
+}
+
+// semaphore returns the semaphore of a destination, nil when unbounded
+func (p *DeliveryPool) semaphore(destination string) chan struct{} {
+	if p.config.MaxPerDestination <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sem, ok := p.destinations[destination]
+	if !ok {
+		sem = make(chan struct{}, p.config.MaxPerDestination)
+		p.destinations[destination] = sem
+	}
+	return sem
+}
+
+// tryAcquire takes a slot of each semaphore, nil ones being unbounded, without waiting. It
+// takes none when one of them is full.
+func tryAcquire(sems ...chan struct{}) bool {
+	for i, sem := range sems {
+		if sem == nil {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		default:
+			for _, taken := range sems[:i] {
+				release(taken)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// acquireContext takes a slot of a semaphore, nil being unbounded, waiting for a free one
+// unless the context is done first
+func acquireContext(ctx context.Context, sem chan struct{}) error {
+	if sem == nil {
+		return nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot of a semaphore, nil being unbounded
+func release(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
+
+// Metrics returns the deliveries running, queued and waiting for a retry, and the
+// deliveries dropped and the webhooks rejected because the queue was full
+func (p *DeliveryPool) Metrics() map[string]interface{} {
+	return map[string]interface{}{
+		"max_concurrent":      p.config.MaxConcurrent,
+		"max_per_destination": p.config.MaxPerDestination,
+		"queue_size":          p.config.QueueSize,
+		"running":             p.running.Load(),
+		"queued":              p.queued.Load(),
+		"retrying":            p.retries.len(),
+		"dropped":             p.dropped.Load(),
+		"rejected":            p.rejected.Load(),
+		"drain_rate":          p.drained.rate(p.now()),
+	}
+}
+
+// ResetMetrics resets the counts of dropped deliveries and rejected webhooks
+func (p *DeliveryPool) ResetMetrics() {
+	p.dropped.Store(0)
+	p.rejected.Store(0)
+}
+
+// drainWindow is the number of seconds over which the drain rate of the queue is measured
+const drainWindow = 10
+
+// drainRate counts the attempts done in each of the last seconds
+type drainRate struct {
+	mu      sync.Mutex
+	seconds [drainWindow]int64 // second counted by each bucket, as a Unix time
+	counts  [drainWindow]int64
+}
+
+// record counts an attempt done at the given time
+func (d *drainRate) record(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	second := now.Unix()
+	i := second % drainWindow
+	if d.seconds[i] != second {
+		d.seconds[i], d.counts[i] = second, 0
+	}
+	d.counts[i]++
+}
+
+// rate returns the attempts done per second over the window ending at the given time
+func (d *drainRate) rate(now time.Time) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var total int64
+	for i, second := range d.seconds {
+		if age := now.Unix() - second; age >= 0 && age < drainWindow {
+			total += d.counts[i]
+		}
+	}
+	return float64(total) / drainWindow
+}
@@ -0,0 +1,327 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryPoolBounds(t *testing.T) {
+	pool := NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 3, MaxPerDestination: 2, QueueSize: 100, Overflow: config.DeliveriesOverflowReject})
+
+	var mu sync.Mutex
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	total, maxTotal := 0, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		destination := []string{"a", "b", "c"}[i%3]
+		wg.Add(1)
+		assert.True(t, pool.Go(context.Background(), destination, false, func(ctx context.Context) {
+			defer wg.Done()
+			assert.NoError(t, acquireSlot(ctx))
+			mu.Lock()
+			running[destination]++
+			total++
+			maxRunning[destination] = max(maxRunning[destination], running[destination])
+			maxTotal = max(maxTotal, total)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running[destination]--
+			total--
+			mu.Unlock()
+		}))
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxTotal, 3)
+	for destination, count := range maxRunning {
+		assert.LessOrEqual(t, count, 2, destination)
+	}
+	metrics := pool.Metrics()
+	assert.Equal(t, int64(0), metrics["running"])
+	assert.Equal(t, int64(0), metrics["queued"])
+}
+
+func TestDeliveryPoolOverflow(t *testing.T) {
+	pool := NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 2, Overflow: config.DeliveriesOverflowReject})
+
+	release := make(chan struct{})
+	var ran atomic.Int64
+	for i := 0; i < 3; i++ {
+		assert.True(t, pool.Go(context.Background(), "a", false, func(ctx context.Context) {
+			assert.NoError(t, acquireSlot(ctx))
+			ran.Add(1)
+			<-release
+		}))
+	}
+	assert.Eventually(t, func() bool { return ran.Load() == 1 }, time.Second, time.Millisecond)
+
+	// One delivery runs and two wait, which fills the queue
+	assert.False(t, pool.Admit())
+	assert.False(t, pool.Go(context.Background(), "a", false, func(context.Context) { t.Error("Expected the delivery to be dropped") }))
+	metrics := pool.Metrics()
+	assert.Equal(t, int64(1), metrics["running"])
+	assert.Equal(t, int64(2), metrics["queued"])
+	assert.Equal(t, int64(1), metrics["dropped"])
+	assert.Equal(t, int64(1), metrics["rejected"])
+
+	close(release)
+	assert.Eventually(t, func() bool { return pool.Admit() }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(3), ran.Load())
+
+	pool.ResetMetrics()
+	assert.Equal(t, int64(0), pool.Metrics()["dropped"])
+
+	// Deliveries of persisted webhooks wait in the queue however full
+	release = make(chan struct{})
+	for i := 0; i < 4; i++ {
+		assert.True(t, pool.Go(context.Background(), "a", true, func(ctx context.Context) {
+			assert.NoError(t, acquireSlot(ctx))
+			ran.Add(1)
+			<-release
+		}))
+	}
+	close(release)
+	assert.Eventually(t, func() bool { return ran.Load() == 7 }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(0), pool.Metrics()["dropped"])
+
+	// Dropping deliveries never rejects webhooks
+	pool = NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, Overflow: config.DeliveriesOverflowDrop})
+	assert.True(t, pool.Admit())
+}
+
+func TestForwardWebhookWithDeliveryPool(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			current := maxInFlight.Load()
+			if n <= current || maxInFlight.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{{URL: server.URL, Method: "POST", Timeout: time.Second}}, log)
+	handler.SetDeliveryPool(NewDeliveryPool(config.DeliveriesConfig{MaxPerDestination: 2, QueueSize: 100, Overflow: config.DeliveriesOverflowReject}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.DeliverWebhook(context.Background(), webhook.New("/webhook", []byte(`{}`), nil))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(10), handler.GetMetrics()["successful_requests"])
+	assert.LessOrEqual(t, maxInFlight.Load(), int64(2))
+}
+
+func TestDeliveryPoolReleasesSlotBetweenAttempts(t *testing.T) {
+	var failing, other atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/failing" {
+			failing.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		other.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: server.URL + "/failing", Method: "POST", Timeout: time.Second, Retries: 1, RetryDelay: time.Hour},
+		{URL: server.URL + "/other", Method: "POST", Timeout: time.Second},
+	}, log)
+	after := make(chan time.Time)
+	handler.after = func(time.Duration) <-chan time.Time { return after }
+	handler.SetDeliveryPool(NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 100, Overflow: config.DeliveriesOverflowReject}))
+
+	// The single slot is free for the other destination while the failing one waits to retry
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte(`{}`), nil))
+	assert.Eventually(t, func() bool { return failing.Load() == 1 && other.Load() == 1 }, time.Second, time.Millisecond)
+
+	close(after)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, handler.Drain(ctx))
+	assert.Equal(t, int64(2), failing.Load())
+}
+
+// deadLetterRecorder records the dead-lettered deliveries
+type deadLetterRecorder struct {
+	NopHook
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (h *deadLetterRecorder) OnDeadLetter(event *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+}
+
+func TestDeliveryPoolDeadLettersDroppedDeliveries(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{{URL: server.URL, Method: "POST", Timeout: 5 * time.Second}}, log)
+	handler.SetDeliveryPool(NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 0, Overflow: config.DeliveriesOverflowDrop}))
+	hook := &deadLetterRecorder{}
+	handler.AddHook(hook)
+
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte(`{"first":true}`), nil))
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte(`{"second":true}`), nil))
+
+	// The delivery dropped without a slot nor room in the queue is dead-lettered at once
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, handler.Drain(ctx))
+	if assert.Len(t, hook.events, 1) {
+		assert.ErrorIs(t, hook.events[0].Err, errDeliveryQueueFull)
+		assert.Equal(t, `{"second":true}`, string(hook.events[0].Body))
+		assert.NotEmpty(t, hook.events[0].ID)
+	}
+}
+
+func TestDeliveryPoolRetryAfter(t *testing.T) {
+	pool := NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 100, Overflow: config.DeliveriesOverflowReject, MaxRetryAfter: 30 * time.Second})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	// Without deliveries done, the drain rate is unknown
+	pool.queued.Store(40)
+	assert.Equal(t, 30*time.Second, pool.RetryAfter())
+
+	// 20 deliveries over the window drain 2 per second, and 40 queued ones in 20s
+	for i := 0; i < 20; i++ {
+		pool.drained.record(now.Add(-time.Duration(i%5) * time.Second))
+	}
+	assert.Equal(t, 2.0, pool.Metrics()["drain_rate"])
+	assert.Equal(t, 20*time.Second, pool.RetryAfter())
+
+	// The hint is at least a second and at most the maximum
+	pool.queued.Store(1)
+	assert.Equal(t, time.Second, pool.RetryAfter())
+	pool.queued.Store(1000)
+	assert.Equal(t, 30*time.Second, pool.RetryAfter())
+
+	// Deliveries done before the window no longer count
+	now = now.Add(time.Minute)
+	assert.Equal(t, 0.0, pool.Metrics()["drain_rate"])
+}
+
+func TestDeliveryPoolQueuesRetries(t *testing.T) {
+	var attempts atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: server.URL, Method: "POST", Timeout: time.Second, Retries: 1, RetryDelay: 200 * time.Millisecond},
+	}, log)
+	pool := NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 100, Overflow: config.DeliveriesOverflowReject})
+	handler.SetDeliveryPool(pool)
+
+	// The delivery waits for its retry in the retry queue, without a slot
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte(`{}`), nil))
+	assert.Eventually(t, func() bool { return pool.Metrics()["retrying"] == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(0), pool.Metrics()["running"])
+	assert.Equal(t, int64(1), handler.InFlight())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, handler.Drain(ctx))
+	assert.Equal(t, int64(2), attempts.Load())
+	assert.Equal(t, int64(1), handler.GetMetrics()["successful_requests"])
+	assert.Equal(t, 0, pool.Metrics()["retrying"])
+}
+
+func TestRetryQueueAging(t *testing.T) {
+	pool := NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 100, Overflow: config.DeliveriesOverflowReject})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	var resumed atomic.Int64
+	pool.retries.schedule(context.Background(), time.Hour, func() { resumed.Add(1) })
+
+	// Retries are not released before they are due
+	pool.retries.release()
+	assert.Equal(t, int64(0), resumed.Load())
+
+	// A due retry is held back while deliveries wait for a slot
+	now = now.Add(time.Hour)
+	pool.queued.Store(1)
+	pool.retries.release()
+	assert.Equal(t, int64(0), resumed.Load())
+
+	// It takes its turn once aged
+	now = now.Add(retryAging)
+	pool.retries.release()
+	assert.Equal(t, int64(1), resumed.Load())
+
+	// Without deliveries waiting, a due retry is released at once
+	pool.queued.Store(0)
+	pool.retries.schedule(context.Background(), time.Hour, func() { resumed.Add(1) })
+	now = now.Add(time.Hour)
+	pool.retries.release()
+	assert.Equal(t, int64(2), resumed.Load())
+	assert.Equal(t, 0, pool.retries.len())
+}
+
+func TestRetryQueueContextDone(t *testing.T) {
+	pool := NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 100, Overflow: config.DeliveriesOverflowReject})
+
+	// A retry whose context ends leaves the queue at once
+	ctx, cancel := context.WithCancel(context.Background())
+	resumed := make(chan struct{})
+	pool.retries.schedule(ctx, time.Hour, func() { close(resumed) })
+	cancel()
+
+	select {
+	case <-resumed:
+	case <-time.After(time.Second):
+		t.Fatal("retry not resumed when its context ended")
+	}
+	assert.Equal(t, 0, pool.retries.len())
+}
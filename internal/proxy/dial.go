@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// dialFunc opens a connection, as net.Dialer.DialContext
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// dialContext returns the dial function of a destination's transport, connecting to the
+// address families of its dial. Without a dial, the dialer's own dual-stack dialing is used.
+func dialContext(dialer *net.Dialer, dial *config.DialConfig) dialFunc {
+	family := config.IPFamilyAny
+	if dial != nil {
+		family = dial.IPFamily
+	}
+
+	switch family {
+	case config.IPFamilyIPv4:
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, familyNetwork(network, "4"), address)
+		}
+	case config.IPFamilyIPv6:
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, familyNetwork(network, "6"), address)
+		}
+	case config.IPFamilyPreferIPv4:
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialPreferred(ctx, dialer.DialContext, familyNetwork(network, "4"), familyNetwork(network, "6"), address, dial.FallbackDelay)
+		}
+	case config.IPFamilyPreferIPv6:
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialPreferred(ctx, dialer.DialContext, familyNetwork(network, "6"), familyNetwork(network, "4"), address, dial.FallbackDelay)
+		}
+	default:
+		return dialer.DialContext
+	}
+}
+
+// familyNetwork returns the network restricted to an address family, tcp4 for tcp and 4.
+// Networks already restricted are returned unchanged.
+func familyNetwork(network, family string) string {
+	if network == "tcp" {
+		return network + family
+	}
+	return network
+}
+
+// dialResult is the outcome of one of the connections raced by dialPreferred
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+// dialPreferred connects to the primary network, and also to the fallback network once
+// the primary connection has failed or is not open after the delay (happy eyeballs,
+// RFC 8305). The first connection opened is returned, and the other one is abandoned.
+// When both fail, the error of the primary network is returned.
+func dialPreferred(ctx context.Context, dial dialFunc, primary, fallback, address string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, 2)
+	start := func(network string, isPrimary bool) {
+		go func() {
+			conn, err := dial(ctx, network, address)
+			results <- dialResult{conn: conn, err: err, primary: isPrimary}
+		}()
+	}
+
+	start(primary, true)
+	pending, fallbackStarted := 1, false
+	startFallback := func() {
+		if !fallbackStarted {
+			fallbackStarted = true
+			pending++
+			start(fallback, false)
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-timer.C:
+			startFallback()
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				// Close the connection of the other network if it opens after all
+				if pending > 0 {
+					go func() {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}()
+				}
+				return result.conn, nil
+			}
+
+			if result.primary {
+				primaryErr = result.err
+				startFallback()
+			} else {
+				fallbackErr = result.err
+			}
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
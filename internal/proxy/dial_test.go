@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFamilyNetwork(t *testing.T) {
+	assert.Equal(t, "tcp4", familyNetwork("tcp", "4"))
+	assert.Equal(t, "tcp6", familyNetwork("tcp", "6"))
+	assert.Equal(t, "tcp4", familyNetwork("tcp4", "6"))
+}
+
+func TestDialPreferredFallsBackAfterDelay(t *testing.T) {
+	primaryCancelled := make(chan struct{})
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if network == "tcp6" {
+			// The primary network never answers, until the race is over
+			<-ctx.Done()
+			close(primaryCancelled)
+			return nil, ctx.Err()
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	start := time.Now()
+	conn, err := dialPreferred(context.Background(), dial, "tcp6", "tcp4", "example.com:443", 20*time.Millisecond)
+	require.NoError(t, err)
+	conn.Close()
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	select {
+	case <-primaryCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the primary connection to be abandoned")
+	}
+}
+
+func TestDialPreferredFallsBackOnFailure(t *testing.T) {
+	var networks []string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		networks = append(networks, network)
+		if network == "tcp4" {
+			return nil, errors.New("network is unreachable")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	// A failed primary connection does not wait for the delay
+	conn, err := dialPreferred(context.Background(), dial, "tcp4", "tcp6", "example.com:443", time.Hour)
+	require.NoError(t, err)
+	conn.Close()
+	assert.Equal(t, []string{"tcp4", "tcp6"}, networks)
+}
+
+func TestDialPreferredReportsPrimaryError(t *testing.T) {
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errors.New("failed on " + network)
+	}
+
+	_, err := dialPreferred(context.Background(), dial, "tcp4", "tcp6", "example.com:443", time.Millisecond)
+	assert.EqualError(t, err, "failed on tcp4")
+}
+
+func TestDialIPFamilyDelivery(t *testing.T) {
+	// The destination only listens on IPv4
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dial := func(family string) config.DestinationConfig {
+		return config.DestinationConfig{
+			URL:     server.URL + "/" + family,
+			Method:  "POST",
+			Timeout: 5 * time.Second,
+			Dial:    &config.DialConfig{IPFamily: family, FallbackDelay: 10 * time.Millisecond, Timeout: time.Second},
+		}
+	}
+	ipv4, ipv6, preferIPv6 := dial(config.IPFamilyIPv4), dial(config.IPFamilyIPv6), dial(config.IPFamilyPreferIPv6)
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{ipv4, ipv6, preferIPv6}}, log)
+	defer handler.Close()
+	assert.Len(t, handler.transports, 3)
+
+	send := func(dest config.DestinationConfig) error {
+		resp, err := handler.httpClient(dest).Post(dest.URL, "application/json", nil)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	assert.NoError(t, send(ipv4))
+	assert.Error(t, send(ipv6), "An IPv6-only dial cannot reach an IPv4 address")
+	assert.NoError(t, send(preferIPv6), "Preferring IPv6 falls back to IPv4")
+}
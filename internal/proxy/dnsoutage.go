@@ -0,0 +1,271 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
+	"github.com/sirupsen/logrus"
+)
+
+// errDNSOutage is returned for deliveries whose deadline passed while their destination was paused
+var errDNSOutage = errors.New("destination paused by a DNS outage")
+
+// dnsLookupTimeout bounds each resolution of a paused destination's host
+const dnsLookupTimeout = 5 * time.Second
+
+// dnsGuard pauses a destination after consecutive DNS resolution failures. While paused,
+// deliveries wait instead of consuming their retries, and the host is resolved on a
+// backoff schedule until it resolves again, which resumes the waiting deliveries.
+type dnsGuard struct {
+	config      config.DNSOutageConfig
+	destination string
+	host        string
+	lookup      func(ctx context.Context, host string) error
+	publish     func(event events.Event)
+	log         *logrus.Logger
+
+	mu        sync.Mutex
+	failures  int
+	paused    bool
+	resumed   chan struct{}
+	nextProbe time.Time
+	queued    int
+
+	// stopped is closed when the handler closes, ending the resolutions and the waits
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// newDNSGuard creates the guard of an HTTP destination, resolving its host with the default resolver
+func newDNSGuard(dest config.DestinationConfig, log *logrus.Logger) *dnsGuard {
+	var host string
+	if u, err := url.Parse(dest.URL); err == nil {
+		host = u.Hostname()
+	}
+
+	return &dnsGuard{
+		config:      *dest.DNSOutage,
+		destination: dest.Key(),
+		host:        host,
+		lookup: func(ctx context.Context, host string) error {
+			_, err := net.DefaultResolver.LookupHost(ctx, host)
+			return err
+		},
+		publish: func(events.Event) {},
+		log:     log,
+		stopped: make(chan struct{}),
+	}
+}
+
+// stop ends the resolutions of the paused destination and the waits of its deliveries
+func (g *dnsGuard) stop() {
+	g.stopOnce.Do(func() { close(g.stopped) })
+}
+
+// failure records a DNS resolution failure and reports whether the destination is paused
+func (g *dnsGuard) failure() bool {
+	g.mu.Lock()
+	if g.paused {
+		g.mu.Unlock()
+		return true
+	}
+	g.failures++
+	if g.failures < g.config.Threshold {
+		g.mu.Unlock()
+		return false
+	}
+
+	g.paused = true
+	g.resumed = make(chan struct{})
+	g.nextProbe = time.Now().Add(g.config.InitialBackoff)
+	failures := g.failures
+	g.mu.Unlock()
+
+	// Subscribers may read the guard's state, so the event is published without the lock
+	g.publish(events.Event{
+		Type:        events.DestinationPaused,
+		Destination: g.destination,
+		Message:     "Destination host does not resolve, pausing deliveries",
+		Fields: map[string]interface{}{
+			"reason":   "dns_outage",
+			"host":     g.host,
+			"failures": failures,
+		},
+	})
+
+	go g.probe()
+	return true
+}
+
+// success records a request that reached the destination's host
+func (g *dnsGuard) success() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = 0
+}
+
+// enqueue returns the channel closed when the paused destination resumes and the time of
+// the next resolution, or false when the destination is not paused. Queued deliveries
+// must call dequeue once resumed.
+func (g *dnsGuard) enqueue() (<-chan struct{}, time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		return nil, time.Time{}, false
+	}
+	g.queued++
+	return g.resumed, g.nextProbe, true
+}
+
+// dequeue removes a resumed delivery from the queue
+func (g *dnsGuard) dequeue() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.queued--
+}
+
+// state returns whether the destination is paused and the number of deliveries waiting
+func (g *dnsGuard) state() (bool, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused, g.queued
+}
+
+// probe resolves the host of the paused destination, doubling the delay between two
+// resolutions up to the max backoff, and resumes the destination once it resolves. It
+// returns early when the guard is stopped.
+func (g *dnsGuard) probe() {
+	backoff := g.config.InitialBackoff
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-g.stopped:
+			timer.Stop()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+		err := g.lookup(ctx, g.host)
+		cancel()
+
+		g.mu.Lock()
+		if err == nil {
+			g.paused = false
+			g.failures = 0
+			close(g.resumed)
+			queued := g.queued
+			g.mu.Unlock()
+
+			g.publish(events.Event{
+				Type:        events.DestinationResumed,
+				Destination: g.destination,
+				Message:     "Destination host resolves again, resuming deliveries",
+				Fields: map[string]interface{}{
+					"reason": "dns_outage",
+					"host":   g.host,
+					"queued": queued,
+				},
+			})
+			return
+		}
+
+		backoff *= 2
+		if backoff > g.config.MaxBackoff {
+			backoff = g.config.MaxBackoff
+		}
+		g.nextProbe = time.Now().Add(backoff)
+		g.mu.Unlock()
+
+		g.log.WithFields(logrus.Fields{
+			"error":       err,
+			"destination": g.destination,
+			"host":        g.host,
+			"next_probe":  backoff,
+		}).Debug("Destination host still does not resolve")
+	}
+}
+
+// waitForResolution holds a delivery while its destination is paused by a DNS outage and
+// reports whether it may go on: false when the delivery's deadline or context ends, or the
+// handler closes, before the host resolves again. With a retry store, the waiting delivery
+// is persisted so that it is resumed at its current attempt after a restart.
+func (p *Handler) waitForResolution(ctx context.Context, guard *dnsGuard, event *Event, attempt int, deadline time.Time) bool {
+	resumed, nextProbe, ok := guard.enqueue()
+	if !ok {
+		return true
+	}
+	defer guard.dequeue()
+	releaseSlot(ctx)
+
+	// The delivery gives up at its deadline when the host does not resolve by then
+	var expires <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expires = timer.C
+	}
+
+	p.persistWaiting(event, attempt, nextProbe, "DNS resolution")
+	var resolved bool
+	select {
+	case <-resumed:
+		resolved = true
+	case <-expires:
+	case <-ctx.Done():
+	case <-guard.stopped:
+	}
+	p.forgetWaiting(event)
+
+	return resolved
+}
+
+// persistWaiting saves a delivery waiting for its destination in the retry store, if any,
+// so that it is resumed at its current attempt after a restart
+func (p *Handler) persistWaiting(event *Event, attempt int, nextAttemptAt time.Time, reason string) {
+	if p.retryStore == nil {
+		return
+	}
+
+	err := p.retryStore.Save(retrystore.Record{
+		ID:            event.ID,
+		WebhookID:     event.WebhookID,
+		Endpoint:      event.Endpoint,
+		Destination:   event.Destination.Key(),
+		Body:          event.Body,
+		Headers:       event.Headers,
+		Attempt:       attempt - 1,
+		NextAttemptAt: nextAttemptAt,
+		ReceivedAt:    event.ReceivedAt,
+	})
+	if err != nil {
+		p.logFor(event.Context).WithFields(logrus.Fields{
+			"error":       err,
+			"delivery_id": event.ID,
+			"destination": event.Destination.Key(),
+		}).Error("Failed to persist delivery waiting for " + reason)
+	}
+}
+
+// forgetWaiting deletes a waiting delivery from the retry store once it goes on; it is
+// persisted again by the retry hook if it fails
+func (p *Handler) forgetWaiting(event *Event) {
+	if p.retryStore == nil {
+		return
+	}
+
+	if err := p.retryStore.Delete(event.ID); err != nil {
+		p.logFor(event.Context).WithFields(logrus.Fields{
+			"error":       err,
+			"delivery_id": event.ID,
+		}).Error("Failed to delete retry state")
+	}
+}
@@ -0,0 +1,259 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSGuard(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{
+		URL:       "http://api.example.com/webhook",
+		DNSOutage: &config.DNSOutageConfig{Threshold: 2, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond},
+	}
+	guard := newDNSGuard(dest, log)
+	assert.Equal(t, "api.example.com", guard.host)
+
+	// The host resolves at the third resolution
+	var lookups atomic.Int32
+	guard.lookup = func(_ context.Context, host string) error {
+		assert.Equal(t, "api.example.com", host)
+		if lookups.Add(1) < 3 {
+			return errors.New("no such host")
+		}
+		return nil
+	}
+
+	// Pausing and resuming the destination are published as lifecycle events
+	var mu sync.Mutex
+	var published []events.Type
+	guard.publish = func(event events.Event) {
+		assert.Equal(t, dest.Key(), event.Destination)
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, event.Type)
+	}
+
+	// Failures below the threshold, or interrupted by a success, do not pause the destination
+	assert.False(t, guard.failure())
+	guard.success()
+	assert.False(t, guard.failure())
+	_, _, ok := guard.enqueue()
+	assert.False(t, ok)
+
+	assert.True(t, guard.failure())
+	resumed, nextProbe, ok := guard.enqueue()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(10*time.Millisecond), nextProbe, time.Second)
+	paused, queued := guard.state()
+	assert.True(t, paused)
+	assert.Equal(t, 1, queued)
+
+	select {
+	case <-resumed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the destination to resume")
+	}
+	guard.dequeue()
+
+	assert.Equal(t, int32(3), lookups.Load())
+	paused, queued = guard.state()
+	assert.False(t, paused)
+	assert.Equal(t, 0, queued)
+	assert.False(t, guard.failure())
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return assert.ObjectsAreEqual([]events.Type{events.DestinationPaused, events.DestinationResumed}, published)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestDNSOutageDelivery(t *testing.T) {
+	store, err := retrystore.Open(t.TempDir())
+	require.NoError(t, err)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{
+		URL:                 "http://webhook-proxy-test.invalid/webhook",
+		Method:              "POST",
+		Timeout:             time.Second,
+		MaxDeliveryDuration: 300 * time.Millisecond,
+		DNSOutage:           &config.DNSOutageConfig{Threshold: 1, InitialBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond},
+	}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.SetRetryStore(store)
+
+	// The resolver recovers, but the requests keep failing, until the delivery deadline.
+	// The waiting delivery is persisted at its current attempt meanwhile.
+	var persisted []retrystore.Record
+	var once sync.Once
+	handler.dnsGuards[dest.Key()].lookup = func(context.Context, string) error {
+		once.Do(func() {
+			persisted, err = store.Load()
+			require.NoError(t, err)
+		})
+		return nil
+	}
+
+	var attempts []int
+	var deadLetter error
+	handler.AddHook(&dnsOutageHook{attempts: &attempts, deadLetter: &deadLetter})
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	// Without retries, the attempt was made again after each resolution
+	assert.Greater(t, len(attempts), 1)
+	for _, attempt := range attempts {
+		assert.Equal(t, 1, attempt)
+	}
+	assert.Error(t, deadLetter)
+
+	require.Len(t, persisted, 1)
+	assert.Equal(t, 0, persisted[0].Attempt)
+	assert.Equal(t, dest.Key(), persisted[0].Destination)
+
+	records, err := store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	destination := handler.GetMetrics()["destinations"].(map[string]interface{})[dest.Key()].(map[string]interface{})
+	assert.Contains(t, destination, "dns_paused")
+	assert.Equal(t, 0, destination["dns_queued"])
+}
+
+// dnsOutageHook records the attempts of a delivery and its final error
+type dnsOutageHook struct {
+	NopHook
+	attempts   *[]int
+	deadLetter *error
+}
+
+func (h *dnsOutageHook) BeforeForward(event *Event) {
+	*h.attempts = append(*h.attempts, event.Attempt)
+}
+
+func (h *dnsOutageHook) OnDeadLetter(event *Event) {
+	*h.deadLetter = event.Err
+}
+
+func TestDNSOutageWaitEnds(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	tests := []struct {
+		name     string
+		duration time.Duration
+		cancel   bool
+	}{
+		{name: "max delivery duration", duration: 100 * time.Millisecond},
+		{name: "context cancelled", cancel: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := config.DestinationConfig{
+				URL:                 "http://webhook-proxy-test.invalid/webhook",
+				Method:              "POST",
+				Timeout:             time.Second,
+				MaxDeliveryDuration: tt.duration,
+				DNSOutage:           &config.DNSOutageConfig{Threshold: 1, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+			}
+			handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+			defer handler.Close()
+
+			// The host never resolves while the delivery waits
+			guard := handler.dnsGuards[dest.Key()]
+			guard.lookup = func(context.Context, string) error { return errors.New("no such host") }
+			guard.failure()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+
+			var attempts []int
+			var deadLetter error
+			handler.AddHook(&dnsOutageHook{attempts: &attempts, deadLetter: &deadLetter})
+
+			done := make(chan struct{})
+			go func() {
+				handler.forwardToDestination(ctx, dest, []byte(`{"event":"test"}`), nil, time.Now())
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected the delivery to stop waiting for the destination")
+			}
+
+			assert.Empty(t, attempts)
+			assert.ErrorIs(t, deadLetter, errDNSOutage)
+		})
+	}
+}
+
+func TestDNSGuardStop(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{
+		URL:       "http://api.example.com/webhook",
+		DNSOutage: &config.DNSOutageConfig{Threshold: 1, InitialBackoff: 5 * time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+	}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	guard := handler.dnsGuards[dest.Key()]
+
+	var lookups atomic.Int32
+	guard.lookup = func(context.Context, string) error {
+		lookups.Add(1)
+		return errors.New("no such host")
+	}
+	guard.failure()
+	assert.Eventually(t, func() bool { return lookups.Load() > 0 }, time.Second, time.Millisecond)
+
+	// Closing the handler ends the resolutions of the paused destination
+	require.NoError(t, handler.Close())
+	time.Sleep(20 * time.Millisecond)
+	stopped := lookups.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, lookups.Load())
+}
+
+func TestDNSOutageIgnoresOtherErrors(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{
+		Type:      config.DestinationTypeWebSocket,
+		WebSocket: &config.WebSocketConfig{Path: "/live"},
+		URL:       "http://api.example.com/webhook",
+		Timeout:   time.Second,
+		DNSOutage: &config.DNSOutageConfig{Threshold: 1, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+	}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	defer handler.Close()
+
+	// Only resolution errors pause the destination, not errors whose message reads like one
+	handler.sinks[dest.Key()] = &mockSink{err: errors.New("lookup broker.internal: no such host")}
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	paused, _ := handler.dnsGuards[dest.Key()].state()
+	assert.False(t, paused)
+}
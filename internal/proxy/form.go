@@ -0,0 +1,29 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/flemzord/webhook-proxy/internal/formdata"
+)
+
+// formAsJSON converts a form-encoded webhook to JSON, with its Content-Type updated.
+// Webhooks that are not forms are returned unchanged.
+func formAsJSON(body []byte, headers map[string]string) ([]byte, map[string]string, error) {
+	contentType := headers["Content-Type"]
+	if !formdata.IsForm(contentType) {
+		return body, headers, nil
+	}
+
+	form, err := formdata.Parse(body, contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	converted, err := json.Marshal(form)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode form as JSON: %w", err)
+	}
+
+	return converted, jsonHeaders(headers), nil
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormAsJSON(t *testing.T) {
+	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded", "Content-Length": "25", "X-Twilio-Signature": "sig"}
+
+	body, converted, err := formAsJSON([]byte("MessageSid=SM123&Body=hi"), headers)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"MessageSid":"SM123","Body":"hi"}`, string(body))
+	assert.Equal(t, "application/json", converted["Content-Type"])
+	assert.Equal(t, "sig", converted["X-Twilio-Signature"])
+	assert.NotContains(t, converted, "Content-Length")
+
+	// The original headers are left untouched
+	assert.Equal(t, "application/x-www-form-urlencoded", headers["Content-Type"])
+}
+
+func TestFormAsJSONNotAForm(t *testing.T) {
+	headers := map[string]string{"Content-Type": "application/json"}
+
+	body, converted, err := formAsJSON([]byte(`{"event":"test"}`), headers)
+	require.NoError(t, err)
+	assert.Equal(t, `{"event":"test"}`, string(body))
+	assert.Equal(t, headers, converted)
+}
+
+func TestForwardWebhookFormFormat(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	type delivery struct {
+		contentType string
+		body        string
+	}
+	received := make(chan delivery, 2)
+	newServer := func() *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- delivery{contentType: r.Header.Get("Content-Type"), body: string(body)}
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	verbatim := newServer()
+	defer verbatim.Close()
+	converted := newServer()
+	defer converted.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: verbatim.URL, Method: "POST", Timeout: time.Second},
+		{URL: converted.URL, Method: "POST", Timeout: time.Second, FormFormat: config.PayloadFormatJSON},
+	}, logger)
+
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte("event=delivered"), map[string]string{"Content-Type": "application/x-www-form-urlencoded"}))
+
+	var deliveries []delivery
+	for i := 0; i < 2; i++ {
+		select {
+		case d := <-received:
+			deliveries = append(deliveries, d)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected the webhook to be forwarded to both destinations")
+		}
+	}
+
+	assert.ElementsMatch(t, []delivery{
+		{contentType: "application/x-www-form-urlencoded", body: "event=delivered"},
+		{contentType: "application/json", body: `{"event":"delivered"}`},
+	}, deliveries)
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/flemzord/webhook-proxy/internal/rawheaders"
+)
+
+// rawCase returns the headers named with the case they were sent with, for webhooks
+// carrying their raw headers. Headers the webhook was not sent with, such as correlation
+// headers, keep their name.
+func rawCase(headers map[string]string, raw []rawheaders.Header) map[string]string {
+	if len(raw) == 0 {
+		return headers
+	}
+
+	names := make(map[string]string, len(raw))
+	for _, h := range raw {
+		canonical := http.CanonicalHeaderKey(h.Name)
+		if _, ok := names[canonical]; !ok {
+			names[canonical] = h.Name
+		}
+	}
+
+	cased := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if name, ok := names[http.CanonicalHeaderKey(k)]; ok {
+			k = name
+		}
+		cased[k] = v
+	}
+	return cased
+}
+
+// setHeader sets a header of a request, replacing the values set under any case of its
+// name. A name that is not canonical is kept as is, as net/http writes it so on HTTP/1.x
+// connections; HTTP/2 lowercases all names.
+func setHeader(header http.Header, name, value string) {
+	canonical := http.CanonicalHeaderKey(name)
+	for k := range header {
+		if k != name && http.CanonicalHeaderKey(k) == canonical {
+			delete(header, k)
+		}
+	}
+	header[name] = []string{value}
+}
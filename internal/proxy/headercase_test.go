@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/rawheaders"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawCase(t *testing.T) {
+	headers := map[string]string{"Content-Type": "application/json", "X-Github-Event": "push", "X-Request-Id": "req-1"}
+	raw := []rawheaders.Header{{Name: "x-GitHub-event", Value: "push"}, {Name: "content-type", Value: "application/json"}}
+
+	assert.Equal(t, map[string]string{
+		"content-type":   "application/json",
+		"x-GitHub-event": "push",
+		"X-Request-Id":   "req-1",
+	}, rawCase(headers, raw))
+	assert.Equal(t, headers, rawCase(headers, nil))
+}
+
+func TestSetHeader(t *testing.T) {
+	header := http.Header{}
+	setHeader(header, "x-GitHub-event", "push")
+	assert.Equal(t, http.Header{"x-GitHub-event": {"push"}}, header)
+
+	// Setting the header under another case replaces it
+	setHeader(header, "X-Github-Event", "ping")
+	assert.Equal(t, http.Header{"X-Github-Event": {"ping"}}, header)
+}
+
+func TestForwardWebhookRawHeaders(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	received := make(chan []rawheaders.Header, 1)
+	server := httptest.NewUnstartedServer(rawheaders.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers, _ := rawheaders.FromContext(r.Context())
+		received <- headers
+		w.WriteHeader(http.StatusOK)
+	})))
+	server.Listener = rawheaders.Listener(server.Listener)
+	server.Config.ConnContext = rawheaders.ConnContext
+	server.Start()
+	defer server.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{{
+		URL:     server.URL,
+		Method:  "POST",
+		Timeout: time.Second,
+		Headers: map[string]string{"x-destination": "billing"},
+	}}, logger)
+
+	delivery := webhook.New("/webhook", []byte(`{}`), map[string]string{"Content-Type": "application/json", "X-Github-Event": "push"})
+	delivery.RawHeaders = []rawheaders.Header{{Name: "content-type", Value: "application/json"}, {Name: "X-GitHub-Event", Value: "push"}}
+	handler.ForwardWebhook(context.Background(), delivery)
+
+	var headers []rawheaders.Header
+	select {
+	case headers = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be forwarded")
+	}
+
+	names := make(map[string]string)
+	for _, h := range headers {
+		names[h.Name] = h.Value
+	}
+	require.Contains(t, names, "content-type")
+	assert.Equal(t, "push", names["X-GitHub-Event"])
+	assert.Equal(t, "billing", names["X-Destination"], "Destination headers keep their canonical name")
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/flemzord/webhook-proxy/pkg/hooks"
+)
+
+// Event describes a webhook at a point of its delivery lifecycle
+type Event = hooks.Event
+
+// Hook observes the delivery lifecycle of a handler's webhooks
+type Hook = hooks.Hook
+
+// NopHook implements Hook with no-op methods, to be embedded by hooks interested in only
+// some events
+type NopHook = hooks.NopHook
+
+// metricsHook records deliveries in the handler's metrics
+type metricsHook struct {
+	NopHook
+	metrics *Metrics
+}
+
+// BeforeForward records the delivery and its body size on its first attempt
+func (h *metricsHook) BeforeForward(event *Event) {
+	if event.Attempt == 1 {
+		h.metrics.RecordRequest(event.Destination.Key())
+		h.metrics.RecordBodySize(event.Destination.Key(), len(event.Body))
+	}
+}
+
+// AfterForward records the result of the attempt and the response headers it captured
+func (h *metricsHook) AfterForward(event *Event) {
+	if len(event.ResponseHeaders) > 0 {
+		h.metrics.RecordResponseHeaders(event.Destination.Key(), event.ResponseHeaders)
+	}
+	if event.Err != nil {
+		h.metrics.RecordFailure(event.Destination.Key(), event.WebhookID, event.Err, event.Attempt > 1)
+		return
+	}
+	if event.Cached {
+		h.metrics.RecordCacheHit(event.Destination.Key(), event.StatusCode)
+		return
+	}
+	h.metrics.RecordSuccess(event.Destination.Key(), event.StatusCode, event.Duration)
+}
+
+// eventsHook publishes the lifecycle events of deliveries
+type eventsHook struct {
+	NopHook
+	publish func(event events.Event)
+}
+
+// OnDeadLetter publishes the failure of a delivery whose retries are exhausted
+func (h *eventsHook) OnDeadLetter(event *Event) {
+	fields := map[string]interface{}{
+		"delivery_id": event.ID,
+		"webhook_id":  event.WebhookID,
+		"attempts":    event.Attempt,
+	}
+	if event.Err != nil {
+		fields["error"] = event.Err.Error()
+	}
+	h.publish(events.Event{
+		Type:        events.DeliveryFailed,
+		Destination: event.Destination.Key(),
+		Message:     "Webhook delivery failed, retries exhausted",
+		Fields:      fields,
+	})
+}
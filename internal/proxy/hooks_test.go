@@ -0,0 +1,249 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHook records the lifecycle events it receives
+type recordingHook struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *recordingHook) record(name string, event *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if event.Attempt == 0 {
+		h.events = append(h.events, name)
+		return
+	}
+	h.events = append(h.events, fmt.Sprintf("%s:%d:%d", name, event.Attempt, event.StatusCode))
+}
+
+func (h *recordingHook) OnReceive(event *Event)     { h.record("receive", event) }
+func (h *recordingHook) BeforeForward(event *Event) { h.record("before", event) }
+func (h *recordingHook) AfterForward(event *Event)  { h.record("after", event) }
+func (h *recordingHook) OnFailure(event *Event)     { h.record("failure", event) }
+func (h *recordingHook) OnDeadLetter(event *Event)  { h.record("dead_letter", event) }
+
+func TestHooksOnRetriedDelivery(t *testing.T) {
+	// Fail the first attempt, then succeed
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 2, RetryDelay: 10 * time.Millisecond}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+
+	hook := &recordingHook{}
+	handler.AddHook(hook)
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	assert.Equal(t, []string{
+		"before:1:0", "after:1:503", "failure:1:503",
+		"before:2:0", "after:2:200",
+	}, hook.events)
+
+	// The built-in metrics hook recorded the delivery
+	metrics := handler.GetMetrics()
+	assert.Equal(t, int64(1), metrics["total_requests"])
+	assert.Equal(t, int64(1), metrics["successful_requests"])
+	assert.Equal(t, int64(1), metrics["failed_requests"])
+}
+
+func TestHooksOnDeadLetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 1, RetryDelay: 10 * time.Millisecond}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+
+	var deadLetter *Event
+	handler.AddHook(&deadLetterHook{onDeadLetter: func(event *Event) { deadLetter = event }})
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	if assert.NotNil(t, deadLetter) {
+		assert.Equal(t, "/webhook", deadLetter.Endpoint)
+		assert.Equal(t, 2, deadLetter.Attempt)
+		assert.Equal(t, 2, deadLetter.MaxAttempts)
+		assert.Equal(t, http.StatusInternalServerError, deadLetter.StatusCode)
+		assert.EqualError(t, deadLetter.Err, "received non-2xx status code: 500, body: ")
+	}
+}
+
+func TestDeliveryFailedEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+
+	bus := events.NewBus()
+	var published []events.Event
+	bus.Subscribe(func(event events.Event) { published = append(published, event) }, events.DeliveryFailed)
+	handler.SetEventBus(bus)
+
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	if assert.Len(t, published, 1) {
+		assert.Equal(t, "/webhook", published[0].Endpoint)
+		assert.Equal(t, dest.Key(), published[0].Destination)
+		assert.Equal(t, 1, published[0].Fields["attempts"])
+		assert.Equal(t, "received non-2xx status code: 500, body: ", published[0].Fields["error"])
+	}
+}
+
+func TestHooksOnReceive(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook"}, log)
+
+	hook := &recordingHook{}
+	handler.AddHook(hook)
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte(`{"event":"test"}`), nil))
+
+	assert.Equal(t, []string{"receive"}, hook.events)
+}
+
+// listenerHook records the listener of the events it receives
+type listenerHook struct {
+	NopHook
+	mu        sync.Mutex
+	listeners []string
+}
+
+func (h *listenerHook) record(event *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = append(h.listeners, event.Listener)
+}
+
+func (h *listenerHook) OnReceive(event *Event)    { h.record(event) }
+func (h *listenerHook) AfterForward(event *Event) { h.record(event) }
+
+func TestHooksListener(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	handler := NewEndpointHandler(config.EndpointConfig{
+		Path:         "/webhook",
+		Destinations: []config.DestinationConfig{{URL: server.URL, Method: "POST", Timeout: time.Second}},
+	}, log)
+	hook := &listenerHook{}
+	handler.AddHook(hook)
+
+	drain := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, handler.Drain(ctx))
+	}
+
+	// The listener is read from the context the webhook is forwarded within
+	ctx := WithListener(context.Background(), "acme")
+	assert.Equal(t, "acme", Listener(ctx))
+	handler.ForwardWebhook(ctx, webhook.New("/webhook", []byte(`{}`), nil))
+	drain()
+	assert.Equal(t, []string{"acme", "acme"}, hook.listeners)
+
+	// The listener a webhook was received on, as for queued webhooks, takes precedence
+	hook.listeners = nil
+	delivery := webhook.New("/webhook", []byte(`{}`), nil)
+	delivery.Listener = "globex"
+	handler.ForwardWebhook(ctx, delivery)
+	drain()
+	assert.Equal(t, []string{"globex", "globex"}, hook.listeners)
+}
+
+// deadLetterHook only implements OnDeadLetter
+type deadLetterHook struct {
+	NopHook
+	onDeadLetter func(event *Event)
+}
+
+func (h *deadLetterHook) OnDeadLetter(event *Event) { h.onDeadLetter(event) }
+
+// cancelHook cancels the delivery's context on its first failure
+type cancelHook struct {
+	NopHook
+	cancel context.CancelFunc
+}
+
+func (h *cancelHook) OnFailure(*Event) { h.cancel() }
+
+func TestHooksOnCancelledDelivery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 3, RetryDelay: time.Hour}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+
+	ctx, cancel := context.WithCancel(WithRequestID(context.Background(), "req-1"))
+	defer cancel()
+
+	hook := &recordingHook{}
+	handler.AddHook(hook)
+	handler.AddHook(&cancelHook{cancel: cancel})
+	var requestID string
+	handler.AddHook(&deadLetterHook{onDeadLetter: func(event *Event) {
+		requestID = RequestID(event.Context)
+	}})
+
+	// A cancelled delivery is not retried, without waiting for the retry delay
+	done := make(chan struct{})
+	go func() {
+		handler.forwardToDestination(ctx, dest, []byte(`{"event":"test"}`), nil, time.Now())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the cancelled delivery to stop retrying")
+	}
+
+	assert.Equal(t, []string{"before:1:0", "after:1:503", "failure:1:503", "dead_letter:1:503"}, hook.events)
+	assert.Equal(t, "req-1", requestID)
+}
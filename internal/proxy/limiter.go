@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/logger"
+)
+
+// errConcurrencyLimit is returned for attempts shed by a destination's concurrency limit
+var errConcurrencyLimit = logger.WithClass("concurrency_limit", errors.New("destination concurrency limit reached"))
+
+// adaptiveLimiter limits the number of in-flight requests to a destination using
+// AIMD: the limit grows by one per limit's worth of fast successes and is multiplied
+// by the backoff factor when a request is slower than the threshold or fails.
+type adaptiveLimiter struct {
+	config config.ConcurrencyConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+
+	// Copies of the state read by metrics snapshots without taking the lock
+	currentLimit    atomic.Int64
+	currentInFlight atomic.Int64
+}
+
+// newAdaptiveLimiter creates a limiter starting at the configured initial limit
+func newAdaptiveLimiter(cfg config.ConcurrencyConfig) *adaptiveLimiter {
+	l := &adaptiveLimiter{
+		config: cfg,
+		limit:  float64(cfg.InitialLimit),
+	}
+	l.publish()
+	return l
+}
+
+// Acquire reserves a slot for a request. It returns false if the limit is reached.
+func (l *adaptiveLimiter) Acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		return false
+	}
+	l.inFlight++
+	l.publish()
+	return true
+}
+
+// Release frees the slot of a completed request and adjusts the limit from its
+// latency and whether the destination failed (error, 429 or 5xx)
+func (l *adaptiveLimiter) Release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.publish()
+
+	l.inFlight--
+
+	if failed || latency > l.config.LatencyThreshold {
+		l.limit = math.Max(float64(l.config.MinLimit), l.limit*l.config.Backoff)
+		return
+	}
+
+	l.limit = math.Min(float64(l.config.MaxLimit), l.limit+1/l.limit)
+}
+
+// Limit returns the current limit
+func (l *adaptiveLimiter) Limit() int {
+	return int(l.currentLimit.Load())
+}
+
+// InFlight returns the number of requests in flight
+func (l *adaptiveLimiter) InFlight() int {
+	return int(l.currentInFlight.Load())
+}
+
+// publish copies the state for lock-free reads. It must be called with the lock held.
+func (l *adaptiveLimiter) publish() {
+	l.currentLimit.Store(int64(l.limit))
+	l.currentInFlight.Store(int64(l.inFlight))
+}
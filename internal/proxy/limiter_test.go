@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConcurrencyConfig() config.ConcurrencyConfig {
+	return config.ConcurrencyConfig{
+		InitialLimit:     2,
+		MinLimit:         1,
+		MaxLimit:         4,
+		LatencyThreshold: 100 * time.Millisecond,
+		Backoff:          0.5,
+	}
+}
+
+func TestAdaptiveLimiterAcquire(t *testing.T) {
+	limiter := newAdaptiveLimiter(testConcurrencyConfig())
+
+	// Requests above the limit are shed
+	assert.True(t, limiter.Acquire())
+	assert.True(t, limiter.Acquire())
+	assert.False(t, limiter.Acquire())
+	assert.Equal(t, 2, limiter.InFlight())
+
+	// Releasing a slot allows a new request
+	limiter.Release(10*time.Millisecond, false)
+	assert.True(t, limiter.Acquire())
+}
+
+func TestAdaptiveLimiterIncrease(t *testing.T) {
+	limiter := newAdaptiveLimiter(testConcurrencyConfig())
+
+	// Fast successes grow the limit additively, up to the maximum
+	for i := 0; i < 50; i++ {
+		limiter.Acquire()
+		limiter.Release(10*time.Millisecond, false)
+	}
+	assert.Equal(t, 4, limiter.Limit())
+}
+
+func TestAdaptiveLimiterDecrease(t *testing.T) {
+	limiter := newAdaptiveLimiter(config.ConcurrencyConfig{
+		InitialLimit:     4,
+		MinLimit:         1,
+		MaxLimit:         4,
+		LatencyThreshold: 100 * time.Millisecond,
+		Backoff:          0.5,
+	})
+
+	// Slow responses shrink the limit multiplicatively
+	limiter.Acquire()
+	limiter.Release(200*time.Millisecond, false)
+	assert.Equal(t, 2, limiter.Limit())
+
+	// Failures shrink it down to the minimum
+	for i := 0; i < 5; i++ {
+		limiter.Acquire()
+		limiter.Release(10*time.Millisecond, true)
+	}
+	assert.Equal(t, 1, limiter.Limit())
+}
+
+func TestForwardToDestinationConcurrencyLimit(t *testing.T) {
+	// Block the destination until the test releases it
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := testConcurrencyConfig()
+	cfg.InitialLimit = 1
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Concurrency: &cfg}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+
+	// The first delivery takes the only slot
+	done := make(chan struct{})
+	go func() {
+		handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"first"}`), nil, time.Now())
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return handler.limiters[server.URL].InFlight() == 1 }, time.Second, 10*time.Millisecond)
+
+	// The second one is shed
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"second"}`), nil, time.Now())
+
+	close(release)
+	<-done
+
+	destMetrics := handler.GetMetrics()["destinations"].(map[string]interface{})[server.URL].(map[string]interface{})
+	assert.Equal(t, int64(1), destMetrics["successful_requests"])
+	assert.Equal(t, int64(1), destMetrics["failed_requests"])
+	assert.Equal(t, map[string]int64{"concurrency_limit": 1}, destMetrics["error_classes"])
+	assert.Equal(t, 0, destMetrics["in_flight"])
+	assert.Equal(t, 2, destMetrics["concurrency_limit"])
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/maintenance"
+	"github.com/sirupsen/logrus"
+)
+
+// errMaintenance is returned for deliveries whose deadline passed while their destination was in maintenance
+var errMaintenance = errors.New("destination in a maintenance window")
+
+// maintenanceGuard holds the deliveries of a destination during its maintenance windows.
+// Held deliveries wait for the end of the window without consuming their retries.
+type maintenanceGuard struct {
+	schedule maintenance.Schedule
+	now      func() time.Time
+	waiting  atomic.Int64
+}
+
+// newMaintenanceGuard creates the guard of a destination with maintenance windows
+func newMaintenanceGuard(dest config.DestinationConfig, log *logrus.Logger) *maintenanceGuard {
+	guard := &maintenanceGuard{now: time.Now}
+	for i, window := range dest.MaintenanceWindows {
+		parsed, err := window.Parse()
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error":       err,
+				"destination": dest.Key(),
+				"window":      i,
+			}).Error("Failed to parse the destination's maintenance window, ignoring it")
+			continue
+		}
+		guard.schedule = append(guard.schedule, parsed)
+	}
+	return guard
+}
+
+// end returns the end of the maintenance in progress, or false when the destination is not in maintenance
+func (g *maintenanceGuard) end() (time.Time, bool) {
+	return g.schedule.End(g.now())
+}
+
+// state returns whether the destination is in maintenance and the number of deliveries waiting
+func (g *maintenanceGuard) state() (bool, int) {
+	_, active := g.end()
+	return active, int(g.waiting.Load())
+}
+
+// waitForMaintenance holds a delivery while its destination is in a maintenance window and
+// reports whether it may go on: false when the delivery's deadline or context ends before
+// the window does. With a retry store, the held delivery is persisted so that it is
+// resumed at its current attempt after a restart.
+func (p *Handler) waitForMaintenance(ctx context.Context, guard *maintenanceGuard, event *Event, attempt int, deadline time.Time) bool {
+	end, ok := guard.end()
+	if !ok {
+		return true
+	}
+
+	guard.waiting.Add(1)
+	defer guard.waiting.Add(-1)
+	p.metrics.RecordMaintenanceQueued(event.Destination.Key())
+	releaseSlot(ctx)
+
+	p.logFor(event.Context).WithFields(logrus.Fields{
+		"delivery_id": event.ID,
+		"destination": event.Destination.Key(),
+		"attempt":     attempt,
+		"until":       end,
+	}).Debug("Destination in a maintenance window, holding delivery")
+
+	// The delivery gives up at its deadline when the window ends later
+	wait, expired := end.Sub(guard.now()), false
+	if remaining := time.Until(deadline); !deadline.IsZero() && remaining < wait {
+		wait, expired = remaining, true
+	}
+
+	p.persistWaiting(event, attempt, end, "the end of a maintenance window")
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		expired = true
+	}
+	p.forgetWaiting(event)
+
+	return !expired
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inMaintenance returns a clock in the nightly 00:00 to 01:00 UTC window, starting the
+// given time before its end
+func inMaintenance(remaining time.Duration) func() time.Time {
+	end := time.Now().UTC().Truncate(24 * time.Hour).Add(time.Hour)
+	started := time.Now()
+	return func() time.Time {
+		return end.Add(time.Since(started) - remaining)
+	}
+}
+
+func TestMaintenanceDelivery(t *testing.T) {
+	store, err := retrystore.Open(t.TempDir())
+	require.NoError(t, err)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:                server.URL,
+		Method:             "POST",
+		Timeout:            time.Second,
+		MaintenanceWindows: []config.MaintenanceWindowConfig{{Start: "00:00", Duration: time.Hour}},
+	}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.SetRetryStore(store)
+	guard := handler.maintenance[dest.Key()]
+	guard.now = inMaintenance(300 * time.Millisecond)
+
+	// The held delivery is persisted and reported until the window ends
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		defer close(done)
+		handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+	}()
+
+	assert.Eventually(t, func() bool {
+		active, waiting := guard.state()
+		return active && waiting == 1
+	}, time.Second, 5*time.Millisecond)
+	records, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 0, records[0].Attempt)
+	assert.Equal(t, dest.Key(), records[0].Destination)
+	assert.Equal(t, int32(0), received.Load())
+
+	// The window ends and the delivery is attempted once, without counting as a failure
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the delivery to go on after the window")
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+	assert.Equal(t, int32(1), received.Load())
+
+	records, err = store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	metrics := handler.GetMetrics()
+	assert.Equal(t, int64(1), metrics["maintenance_queued"])
+	assert.Equal(t, int64(0), metrics["failed_requests"])
+	destination := metrics["destinations"].(map[string]interface{})[dest.Key()].(map[string]interface{})
+	assert.Equal(t, int64(1), destination["maintenance_queued"])
+	assert.Equal(t, false, destination["maintenance_active"])
+	assert.Equal(t, 0, destination["maintenance_waiting"])
+}
+
+func TestMaintenanceDeliveryDeadline(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{
+		URL:                 "http://localhost:1/webhook",
+		Method:              "POST",
+		Timeout:             time.Second,
+		MaxDeliveryDuration: 50 * time.Millisecond,
+		MaintenanceWindows:  []config.MaintenanceWindowConfig{{Start: "00:00", Duration: time.Hour}},
+	}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.maintenance[dest.Key()].now = inMaintenance(30 * time.Minute)
+
+	// The window outlasts the delivery deadline, so the delivery fails without an attempt
+	var attempts []int
+	var deadLetter error
+	handler.AddHook(&dnsOutageHook{attempts: &attempts, deadLetter: &deadLetter})
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	assert.Empty(t, attempts)
+	assert.ErrorIs(t, deadLetter, errMaintenance)
+	assert.Equal(t, "paused", handler.Health()[dest.Key()].(map[string]interface{})["status"])
+}
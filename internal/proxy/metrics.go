@@ -2,153 +2,347 @@ package proxy
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/logger"
 )
 
-// Metrics represents the metrics for the proxy
+// maxStatusCode bounds the status codes counted by the metrics
+const maxStatusCode = 600
+
+// bodySizeBuckets are the upper bounds, in bytes, of the outbound body size histogram
+var bodySizeBuckets = []struct {
+	label string
+	bound int
+}{
+	{"1KB", 1 << 10},
+	{"10KB", 10 << 10},
+	{"100KB", 100 << 10},
+	{"1MB", 1 << 20},
+	{"10MB", 10 << 20},
+}
+
+// Metrics represents the metrics for the proxy.
+// Counters are atomic and each destination has its own metrics, created once,
+// so that recording does not contend on a shared lock. Snapshots are built from
+// atomic loads only and never block recording.
 type Metrics struct {
-	mu                 sync.RWMutex
-	totalRequests      int64
-	successfulRequests int64
-	failedRequests     int64
-	retries            int64
-	responseTimeTotal  time.Duration
-	responseTimeCount  int64
-	statusCodes        map[int]int64
-	destinations       map[string]*DestinationMetrics
+	// state is swapped as a whole on reset
+	state atomic.Pointer[metricsState]
+}
+
+// metricsState holds the counters since the last reset
+type metricsState struct {
+	counters
+	destinations sync.Map // map[string]*DestinationMetrics
+
+	// unmatched counts the webhooks no destination matched, which are not forwarded
+	unmatched atomic.Int64
 }
 
 // DestinationMetrics represents metrics for a specific destination
 type DestinationMetrics struct {
-	totalRequests      int64
-	successfulRequests int64
-	failedRequests     int64
-	retries            int64
-	responseTimeTotal  time.Duration
-	responseTimeCount  int64
-	statusCodes        map[int]int64
-	lastError          string
-	lastErrorTime      time.Time
+	counters
+	lastError    atomic.Pointer[errorDetails]
+	errorClasses sync.Map // map[string]*atomic.Int64
+	headers      sync.Map // map[string]*capturedHeader, by captured header name
+}
+
+// capturedHeader is the last value of a response header captured from a destination
+type capturedHeader struct {
+	Value string    `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// errorDetails describes the last error of a destination
+type errorDetails struct {
+	message string
+	time    time.Time
+
+	// webhookID is the webhook whose delivery failed, to look its log lines up
+	webhookID string
+}
+
+// counters holds the request counters shared by the global and destination metrics
+type counters struct {
+	totalRequests      atomic.Int64
+	successfulRequests atomic.Int64
+	failedRequests     atomic.Int64
+	retries            atomic.Int64
+	responseTimeTotal  atomic.Int64 // nanoseconds
+	responseTimeCount  atomic.Int64
+	statusCodes        [maxStatusCode]atomic.Int64
+	cacheHits          atomic.Int64
+	maintenanceQueued  atomic.Int64
+
+	// Outbound body sizes, with one more bucket for bodies above the last bound
+	bodyBytes     atomic.Int64
+	bodyCount     atomic.Int64
+	bodyMax       atomic.Int64
+	bodySizeCount [6]atomic.Int64
 }
 
 // NewMetrics creates a new metrics instance
 func NewMetrics() *Metrics {
-	return &Metrics{
-		statusCodes:  make(map[int]int64),
-		destinations: make(map[string]*DestinationMetrics),
-	}
+	m := &Metrics{}
+	m.state.Store(&metricsState{})
+	return m
 }
 
 // RecordRequest records a request to a destination
 func (m *Metrics) RecordRequest(destination string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	m.totalRequests++
+	state := m.state.Load()
+	state.totalRequests.Add(1)
 
 	// Initialize destination metrics if not exists
-	if _, exists := m.destinations[destination]; !exists {
-		m.destinations[destination] = &DestinationMetrics{
-			statusCodes: make(map[int]int64),
-		}
+	dest, ok := state.destinations.Load(destination)
+	if !ok {
+		dest, _ = state.destinations.LoadOrStore(destination, &DestinationMetrics{})
 	}
+	dest.(*DestinationMetrics).totalRequests.Add(1)
+}
 
-	m.destinations[destination].totalRequests++
+// RecordBodySize records the size of a body sent to a destination, once per delivery
+func (m *Metrics) RecordBodySize(destination string, size int) {
+	state := m.state.Load()
+	state.recordBodySize(size)
+
+	if dest, ok := state.destinations.Load(destination); ok {
+		dest.(*DestinationMetrics).recordBodySize(size)
+	}
 }
 
 // RecordSuccess records a successful request
 func (m *Metrics) RecordSuccess(destination string, statusCode int, duration time.Duration) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	m.successfulRequests++
-	m.responseTimeTotal += duration
-	m.responseTimeCount++
-	m.statusCodes[statusCode]++
+	state := m.state.Load()
+	state.recordSuccess(statusCode, duration)
 
 	// Update destination metrics
-	if dest, exists := m.destinations[destination]; exists {
-		dest.successfulRequests++
-		dest.responseTimeTotal += duration
-		dest.responseTimeCount++
-		dest.statusCodes[statusCode]++
+	if dest, ok := state.destinations.Load(destination); ok {
+		dest.(*DestinationMetrics).recordSuccess(statusCode, duration)
 	}
 }
 
-// RecordFailure records a failed request
-func (m *Metrics) RecordFailure(destination string, err string, retry bool) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// RecordCacheHit records a successful request served from the response cache.
+// It does not count towards the response time, as no request was sent.
+func (m *Metrics) RecordCacheHit(destination string, statusCode int) {
+	state := m.state.Load()
+	state.recordCacheHit(statusCode)
 
-	m.failedRequests++
-	if retry {
-		m.retries++
+	if dest, ok := state.destinations.Load(destination); ok {
+		dest.(*DestinationMetrics).recordCacheHit(statusCode)
+	}
+}
+
+// RecordMaintenanceQueued records a delivery held by a maintenance window of its destination.
+// Held deliveries are not failures; they are attempted once the window ends.
+func (m *Metrics) RecordMaintenanceQueued(destination string) {
+	state := m.state.Load()
+	state.maintenanceQueued.Add(1)
+
+	dest, ok := state.destinations.Load(destination)
+	if !ok {
+		dest, _ = state.destinations.LoadOrStore(destination, &DestinationMetrics{})
 	}
+	dest.(*DestinationMetrics).maintenanceQueued.Add(1)
+}
+
+// RecordUnmatched records a webhook that no destination matches
+func (m *Metrics) RecordUnmatched() {
+	m.state.Load().unmatched.Add(1)
+}
+
+// RecordFailure records a failed request of the delivery of a webhook, whose ID may be empty
+func (m *Metrics) RecordFailure(destination string, webhookID string, err error, retry bool) {
+	state := m.state.Load()
+	state.recordFailure(retry)
 
 	// Update destination metrics
-	if dest, exists := m.destinations[destination]; exists {
-		dest.failedRequests++
-		if retry {
-			dest.retries++
+	if value, ok := state.destinations.Load(destination); ok {
+		dest := value.(*DestinationMetrics)
+		dest.recordFailure(retry)
+		dest.lastError.Store(&errorDetails{message: err.Error(), time: time.Now(), webhookID: webhookID})
+
+		class := logger.ErrorClass(err)
+		count, ok := dest.errorClasses.Load(class)
+		if !ok {
+			count, _ = dest.errorClasses.LoadOrStore(class, &atomic.Int64{})
 		}
-		dest.lastError = err
-		dest.lastErrorTime = time.Now()
+		count.(*atomic.Int64).Add(1)
+	}
+}
+
+// RecordResponseHeaders records the last values of the response headers captured from a destination
+func (m *Metrics) RecordResponseHeaders(destination string, headers map[string]string) {
+	state := m.state.Load()
+	value, ok := state.destinations.Load(destination)
+	if !ok {
+		return
+	}
+	dest := value.(*DestinationMetrics)
+	now := time.Now()
+	for name, v := range headers {
+		dest.headers.Store(name, &capturedHeader{Value: v, Time: now})
 	}
 }
 
 // GetMetrics returns a copy of the current metrics
 func (m *Metrics) GetMetrics() map[string]interface{} {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	// Calculate average response time
-	var avgResponseTime float64
-	if m.responseTimeCount > 0 {
-		avgResponseTime = float64(m.responseTimeTotal.Milliseconds()) / float64(m.responseTimeCount)
-	}
+	state := m.state.Load()
 
 	// Build destinations metrics
 	destinations := make(map[string]interface{})
-	for url, dest := range m.destinations {
-		var destAvgResponseTime float64
-		if dest.responseTimeCount > 0 {
-			destAvgResponseTime = float64(dest.responseTimeTotal.Milliseconds()) / float64(dest.responseTimeCount)
+	state.destinations.Range(func(key, value interface{}) bool {
+		dest := value.(*DestinationMetrics)
+
+		errorClasses := make(map[string]int64)
+		dest.errorClasses.Range(func(class, count interface{}) bool {
+			errorClasses[class.(string)] = count.(*atomic.Int64).Load()
+			return true
+		})
+
+		headers := make(map[string]capturedHeader)
+		dest.headers.Range(func(name, header interface{}) bool {
+			headers[name.(string)] = *header.(*capturedHeader)
+			return true
+		})
+
+		var lastError, lastErrorWebhookID string
+		var lastErrorTime time.Time
+		if details := dest.lastError.Load(); details != nil {
+			lastError, lastErrorTime, lastErrorWebhookID = details.message, details.time, details.webhookID
 		}
 
-		destinations[url] = map[string]interface{}{
-			"total_requests":       dest.totalRequests,
-			"successful_requests":  dest.successfulRequests,
-			"failed_requests":      dest.failedRequests,
-			"retries":              dest.retries,
-			"avg_response_time_ms": destAvgResponseTime,
-			"status_codes":         dest.statusCodes,
-			"last_error":           dest.lastError,
-			"last_error_time":      dest.lastErrorTime,
+		destinations[key.(string)] = map[string]interface{}{
+			"total_requests":        dest.totalRequests.Load(),
+			"successful_requests":   dest.successfulRequests.Load(),
+			"failed_requests":       dest.failedRequests.Load(),
+			"retries":               dest.retries.Load(),
+			"avg_response_time_ms":  dest.avgResponseTime(),
+			"status_codes":          dest.statusCodeCounts(),
+			"cache_hits":            dest.cacheHits.Load(),
+			"maintenance_queued":    dest.maintenanceQueued.Load(),
+			"last_error":            lastError,
+			"last_error_time":       lastErrorTime,
+			"last_error_webhook_id": lastErrorWebhookID,
+			"error_classes":         errorClasses,
+			"body_size":             dest.bodySizeStats(),
+			"captured_headers":      headers,
 		}
-	}
+		return true
+	})
 
 	return map[string]interface{}{
-		"total_requests":       m.totalRequests,
-		"successful_requests":  m.successfulRequests,
-		"failed_requests":      m.failedRequests,
-		"retries":              m.retries,
-		"avg_response_time_ms": avgResponseTime,
-		"status_codes":         m.statusCodes,
+		"total_requests":       state.totalRequests.Load(),
+		"successful_requests":  state.successfulRequests.Load(),
+		"failed_requests":      state.failedRequests.Load(),
+		"retries":              state.retries.Load(),
+		"avg_response_time_ms": state.avgResponseTime(),
+		"status_codes":         state.statusCodeCounts(),
+		"cache_hits":           state.cacheHits.Load(),
+		"maintenance_queued":   state.maintenanceQueued.Load(),
+		"unmatched_requests":   state.unmatched.Load(),
+		"body_size":            state.bodySizeStats(),
 		"destinations":         destinations,
 	}
 }
 
 // Reset resets all metrics
 func (m *Metrics) Reset() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	m.totalRequests = 0
-	m.successfulRequests = 0
-	m.failedRequests = 0
-	m.retries = 0
-	m.responseTimeTotal = 0
-	m.responseTimeCount = 0
-	m.statusCodes = make(map[int]int64)
-	m.destinations = make(map[string]*DestinationMetrics)
+	m.state.Store(&metricsState{})
+}
+
+// recordSuccess counts a successful request
+func (c *counters) recordSuccess(statusCode int, duration time.Duration) {
+	c.successfulRequests.Add(1)
+	c.responseTimeTotal.Add(int64(duration))
+	c.responseTimeCount.Add(1)
+	if statusCode >= 0 && statusCode < maxStatusCode {
+		c.statusCodes[statusCode].Add(1)
+	}
+}
+
+// recordCacheHit counts a successful request served from the response cache
+func (c *counters) recordCacheHit(statusCode int) {
+	c.successfulRequests.Add(1)
+	c.cacheHits.Add(1)
+	if statusCode >= 0 && statusCode < maxStatusCode {
+		c.statusCodes[statusCode].Add(1)
+	}
+}
+
+// recordFailure counts a failed request
+func (c *counters) recordFailure(retry bool) {
+	c.failedRequests.Add(1)
+	if retry {
+		c.retries.Add(1)
+	}
+}
+
+// avgResponseTime returns the average response time in milliseconds
+func (c *counters) avgResponseTime() float64 {
+	count := c.responseTimeCount.Load()
+	if count == 0 {
+		return 0
+	}
+	return float64(time.Duration(c.responseTimeTotal.Load()).Milliseconds()) / float64(count)
+}
+
+// statusCodeCounts returns the non-zero status code counters
+func (c *counters) statusCodeCounts() map[int]int64 {
+	counts := make(map[int]int64)
+	for code := range c.statusCodes {
+		if count := c.statusCodes[code].Load(); count > 0 {
+			counts[code] = count
+		}
+	}
+	return counts
+}
+
+// recordBodySize counts an outbound body in the size histogram
+func (c *counters) recordBodySize(size int) {
+	c.bodyBytes.Add(int64(size))
+	c.bodyCount.Add(1)
+	for {
+		current := c.bodyMax.Load()
+		if int64(size) <= current || c.bodyMax.CompareAndSwap(current, int64(size)) {
+			break
+		}
+	}
+
+	bucket := len(bodySizeBuckets)
+	for i, b := range bodySizeBuckets {
+		if size <= b.bound {
+			bucket = i
+			break
+		}
+	}
+	c.bodySizeCount[bucket].Add(1)
+}
+
+// bodySizeStats returns the outbound body size statistics. Buckets count the bodies
+// up to their size, the last one those above all bounds.
+func (c *counters) bodySizeStats() map[string]interface{} {
+	count := c.bodyCount.Load()
+	total := c.bodyBytes.Load()
+
+	var avg float64
+	if count > 0 {
+		avg = float64(total) / float64(count)
+	}
+
+	buckets := make(map[string]int64, len(bodySizeBuckets)+1)
+	for i, b := range bodySizeBuckets {
+		buckets[b.label] = c.bodySizeCount[i].Load()
+	}
+	buckets["+Inf"] = c.bodySizeCount[len(bodySizeBuckets)].Load()
+
+	return map[string]interface{}{
+		"count":       count,
+		"total_bytes": total,
+		"avg_bytes":   avg,
+		"max_bytes":   c.bodyMax.Load(),
+		"buckets":     buckets,
+	}
 }
@@ -0,0 +1,316 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/encoder"
+	"github.com/flemzord/webhook-proxy/internal/formdata"
+	"github.com/flemzord/webhook-proxy/internal/transform"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/flemzord/webhook-proxy/internal/xmldata"
+	"github.com/sirupsen/logrus"
+)
+
+// payload is a received webhook with the conversions shared by its destinations.
+// Conversions are computed on first use; a payload is used by a single goroutine.
+type payload struct {
+	delivery *webhook.Delivery
+	body     []byte
+	headers  map[string]string
+	log      *logrus.Entry
+
+	// fields are the routing fields extracted by the endpoint's provider preset
+	fields map[string]string
+
+	formJSON *converted
+	xmlJSON  *converted
+
+	xmlParsed bool
+	xmlRoot   *xmldata.Node
+
+	// parsed is the JSON, form or XML payload given to transform templates
+	parsedDone bool
+	parsed     interface{}
+}
+
+// converted is a webhook body converted to another format, with its headers
+type converted struct {
+	body    []byte
+	headers map[string]string
+}
+
+// newPayload creates the payload of a received webhook
+func newPayload(delivery *webhook.Delivery, log *logrus.Entry) *payload {
+	return &payload{delivery: delivery, body: delivery.Body, headers: delivery.Headers, fields: delivery.Metadata, log: log}
+}
+
+// forDestination returns the body and headers to send to a destination,
+// converting form and XML webhooks to JSON if the destination asks for it
+func (p *payload) forDestination(dest config.DestinationConfig) ([]byte, map[string]string) {
+	contentType := p.headers["Content-Type"]
+
+	if dest.FormFormat == config.PayloadFormatJSON && formdata.IsForm(contentType) {
+		if p.formJSON == nil {
+			p.formJSON = p.convert("form", formAsJSON)
+		}
+		return p.formJSON.body, p.formJSON.headers
+	}
+
+	if dest.XMLFormat == config.PayloadFormatJSON && xmldata.IsXML(contentType) {
+		if p.xmlJSON == nil {
+			p.xmlJSON = p.convert("xml", p.xmlAsJSON)
+		}
+		return p.xmlJSON.body, p.xmlJSON.headers
+	}
+
+	return p.body, p.headers
+}
+
+// transformed returns the body and headers to send to a destination rendered by its transform
+// template, falling back to the body of forDestination on error
+func (p *payload) transformed(dest config.DestinationConfig, tmpl *transform.Template) ([]byte, map[string]string) {
+	body, err := tmpl.Render(transform.Data{
+		Endpoint:  p.delivery.Endpoint,
+		RequestID: p.delivery.RequestID,
+		Headers:   p.headers,
+		Fields:    p.fields,
+		Payload:   p.payload(),
+	})
+	if err != nil {
+		p.log.WithError(err).WithField("destination", dest.Key()).Warn("Failed to transform webhook, forwarding it untransformed")
+		return p.forDestination(dest)
+	}
+	return body, contentTypeHeaders(p.headers, dest.Transform.ContentType)
+}
+
+// encoded returns the body and headers to send to a destination with the payload converted by
+// its encoder, falling back to the body of forDestination when the payload cannot be encoded
+func (p *payload) encoded(dest config.DestinationConfig, enc encoder.Encoder) ([]byte, map[string]string) {
+	parsed := p.payload()
+	if parsed == nil {
+		p.log.WithField("destination", dest.Key()).Warn("Failed to parse webhook for encoding, forwarding it unencoded")
+		return p.forDestination(dest)
+	}
+	body, err := enc.Encode(parsed)
+	if err != nil {
+		p.log.WithError(err).WithField("destination", dest.Key()).Warn("Failed to encode webhook, forwarding it unencoded")
+		return p.forDestination(dest)
+	}
+	return body, contentTypeHeaders(p.headers, enc.ContentType())
+}
+
+// payload returns the JSON, form or XML payload of the webhook, or nil if it cannot be parsed
+func (p *payload) payload() interface{} {
+	if !p.parsedDone {
+		p.parsedDone = true
+		contentType := p.headers["Content-Type"]
+		switch {
+		case formdata.IsForm(contentType):
+			if form, err := formdata.Parse(p.body, contentType); err == nil {
+				p.parsed = form
+			}
+		case xmldata.IsXML(contentType):
+			if root := p.xml(); root != nil {
+				p.parsed = root.ToMap()
+			}
+		default:
+			p.parsed, _ = p.delivery.JSON()
+		}
+	}
+	return p.parsed
+}
+
+// convert runs a conversion, falling back to the verbatim webhook on error
+func (p *payload) convert(format string, conversion func([]byte, map[string]string) ([]byte, map[string]string, error)) *converted {
+	body, headers, err := conversion(p.body, p.headers)
+	if err != nil {
+		p.log.WithError(err).Warnf("Failed to convert %s to JSON, forwarding it verbatim", format)
+		return &converted{body: p.body, headers: p.headers}
+	}
+	return &converted{body: body, headers: headers}
+}
+
+// matches reports whether the webhook matches all the filters. XPath filters
+// only match XML webhooks, and JSON path filters JSON webhooks.
+func (p *payload) matches(filters []config.FilterConfig) bool {
+	for _, filter := range filters {
+		if !p.matchesFilter(filter) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesFilter reports whether the value selected by a filter is set, equal to its
+// Equals value and matching its Regex. XPath filters match if any selected node does.
+func (p *payload) matchesFilter(filter config.FilterConfig) bool {
+	var values []string
+	switch {
+	case filter.Field != "":
+		if value := p.fields[filter.Field]; value != "" {
+			values = []string{value}
+		}
+	case filter.Header != "":
+		if value := p.delivery.Header(filter.Header); value != "" {
+			values = []string{value}
+		}
+	case filter.JSONPath != "":
+		parsed, err := p.delivery.JSON()
+		if err != nil {
+			return false
+		}
+		if value := jsonPathValue(parsed, filter.JSONPath); value != "" {
+			values = []string{value}
+		}
+	default:
+		root := p.xml()
+		if root == nil {
+			return false
+		}
+		path, err := xmldata.Compile(filter.XPath)
+		if err != nil {
+			return false
+		}
+		values = path.Select(root)
+	}
+
+	if len(values) == 0 {
+		return false
+	}
+	if filter.Equals != "" {
+		return contains(values, filter.Equals)
+	}
+	if filter.Regex != "" {
+		pattern, err := filterPattern(filter.Regex)
+		if err != nil {
+			return false
+		}
+		for _, value := range values {
+			if pattern.MatchString(value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// filterPatterns caches the compiled regexes of destination filters, by expression
+var filterPatterns sync.Map
+
+// filterPattern returns the compiled regex of a filter
+func filterPattern(expr string) (*regexp.Regexp, error) {
+	if pattern, ok := filterPatterns.Load(expr); ok {
+		return pattern.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	filterPatterns.Store(expr, pattern)
+	return pattern, nil
+}
+
+// jsonPathValue returns the value at a dot-separated path of a parsed JSON body, as a
+// string, or an empty string for null and missing values. Numeric keys index arrays, and
+// objects and arrays are returned JSON-encoded.
+func jsonPathValue(parsed interface{}, path string) string {
+	value := parsed
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return ""
+			}
+			value = v[index]
+		default:
+			return ""
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
+
+// xml returns the root element of an XML webhook, or nil if it is not XML
+func (p *payload) xml() *xmldata.Node {
+	if !p.xmlParsed {
+		p.xmlParsed = true
+		if xmldata.IsXML(p.headers["Content-Type"]) {
+			root, err := xmldata.Parse(p.body)
+			if err != nil {
+				p.log.WithError(err).Warn("Failed to parse XML webhook")
+			}
+			p.xmlRoot = root
+		}
+	}
+	return p.xmlRoot
+}
+
+// xmlAsJSON converts an XML webhook to JSON, with its Content-Type updated.
+// Webhooks that are not XML are returned unchanged.
+func (p *payload) xmlAsJSON(body []byte, headers map[string]string) ([]byte, map[string]string, error) {
+	if !xmldata.IsXML(headers["Content-Type"]) {
+		return body, headers, nil
+	}
+
+	root := p.xml()
+	if root == nil {
+		return nil, nil, fmt.Errorf("invalid xml body")
+	}
+
+	data, err := json.Marshal(root.ToMap())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode xml as JSON: %w", err)
+	}
+	return data, jsonHeaders(headers), nil
+}
+
+// jsonHeaders returns a copy of the headers for a body converted to JSON
+func jsonHeaders(headers map[string]string) map[string]string {
+	return contentTypeHeaders(headers, "application/json")
+}
+
+// contentTypeHeaders returns a copy of the headers for a body rewritten with a content type
+func contentTypeHeaders(headers map[string]string, contentType string) map[string]string {
+	converted := make(map[string]string, len(headers))
+	for k, v := range headers {
+		converted[k] = v
+	}
+	converted["Content-Type"] = contentType
+	delete(converted, "Content-Length")
+	return converted
+}
+
+// contains reports whether a value is in a slice
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
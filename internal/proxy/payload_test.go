@@ -0,0 +1,307 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/encoder"
+	"github.com/flemzord/webhook-proxy/internal/transform"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const snsNotification = `<Notification><Type>Notification</Type><Message>paid</Message></Notification>`
+
+func newTestPayload(body, contentType string) *payload {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return newPayload(webhook.New("/webhook", []byte(body), map[string]string{"Content-Type": contentType, "Content-Length": "78"}), logrus.NewEntry(logger))
+}
+
+func TestPayloadXMLAsJSON(t *testing.T) {
+	webhook := newTestPayload(snsNotification, "text/xml")
+
+	body, headers := webhook.forDestination(config.DestinationConfig{XMLFormat: config.PayloadFormatJSON})
+	assert.JSONEq(t, `{"Notification":{"Type":"Notification","Message":"paid"}}`, string(body))
+	assert.Equal(t, "application/json", headers["Content-Type"])
+	assert.NotContains(t, headers, "Content-Length")
+
+	body, headers = webhook.forDestination(config.DestinationConfig{})
+	assert.Equal(t, snsNotification, string(body))
+	assert.Equal(t, "text/xml", headers["Content-Type"])
+}
+
+func TestPayloadBothFormats(t *testing.T) {
+	dest := config.DestinationConfig{FormFormat: config.PayloadFormatJSON, XMLFormat: config.PayloadFormatJSON}
+
+	body, _ := newTestPayload(snsNotification, "text/xml").forDestination(dest)
+	assert.JSONEq(t, `{"Notification":{"Type":"Notification","Message":"paid"}}`, string(body))
+
+	body, _ = newTestPayload("event=delivered", "application/x-www-form-urlencoded").forDestination(dest)
+	assert.JSONEq(t, `{"event":"delivered"}`, string(body))
+}
+
+func TestPayloadInvalidXMLForwardedVerbatim(t *testing.T) {
+	webhook := newTestPayload(`<Notification>`, "application/xml")
+
+	body, headers := webhook.forDestination(config.DestinationConfig{XMLFormat: config.PayloadFormatJSON})
+	assert.Equal(t, `<Notification>`, string(body))
+	assert.Equal(t, "application/xml", headers["Content-Type"])
+}
+
+func TestPayloadTransformed(t *testing.T) {
+	slack := config.DestinationConfig{URL: "https://hooks.slack.com/services/T000/B000/XXXX", Transform: &config.TransformConfig{
+		Template:    `{"text": {{json (printf "%s %s" .Fields.repo .Payload.action)}}}`,
+		ContentType: "application/json",
+	}}
+	tmpl, err := transform.Parse(slack.Transform.Template)
+	require.NoError(t, err)
+
+	payload := newTestPayload(`{"action":"opened"}`, "application/json")
+	payload.fields = map[string]string{"repo": "octo/hello"}
+	body, headers := payload.transformed(slack, tmpl)
+	assert.JSONEq(t, `{"text":"octo/hello opened"}`, string(body))
+	assert.Equal(t, "application/json", headers["Content-Type"])
+	assert.NotContains(t, headers, "Content-Length")
+
+	// Form and XML webhooks are transformed from their parsed payload
+	text := config.DestinationConfig{Transform: &config.TransformConfig{Template: `{{.Payload.event}}`, ContentType: "text/plain"}}
+	tmpl, err = transform.Parse(text.Transform.Template)
+	require.NoError(t, err)
+	body, headers = newTestPayload("event=delivered", "application/x-www-form-urlencoded").transformed(text, tmpl)
+	assert.Equal(t, "delivered", string(body))
+	assert.Equal(t, "text/plain", headers["Content-Type"])
+
+	tmpl, err = transform.Parse(`{{.Payload.Notification.Message}}`)
+	require.NoError(t, err)
+	body, _ = newTestPayload(snsNotification, "text/xml").transformed(text, tmpl)
+	assert.Equal(t, "paid", string(body))
+}
+
+func TestPayloadTransformFailureForwardsUntransformed(t *testing.T) {
+	dest := config.DestinationConfig{XMLFormat: config.PayloadFormatJSON, Transform: &config.TransformConfig{Template: `{{.Payload.Notification.Type.Name}}`, ContentType: "text/plain"}}
+	tmpl, err := transform.Parse(dest.Transform.Template)
+	require.NoError(t, err)
+
+	body, headers := newTestPayload(snsNotification, "text/xml").transformed(dest, tmpl)
+	assert.JSONEq(t, `{"Notification":{"Type":"Notification","Message":"paid"}}`, string(body))
+	assert.Equal(t, "application/json", headers["Content-Type"])
+}
+
+func TestPayloadEncoded(t *testing.T) {
+	dest := config.DestinationConfig{Encoding: &config.EncodingConfig{Format: encoder.FormatMsgpack}}
+	msgpack, err := encoder.New(encoder.FormatMsgpack, "", "")
+	require.NoError(t, err)
+
+	body, headers := newTestPayload(`{"a":1}`, "application/json").encoded(dest, msgpack)
+	assert.Equal(t, []byte{0x81, 0xa1, 'a', 0x01}, body)
+	assert.Equal(t, "application/msgpack", headers["Content-Type"])
+	assert.NotContains(t, headers, "Content-Length")
+
+	// XML webhooks are encoded from their parsed payload
+	asJSON, err := encoder.New(encoder.FormatJSON, "", "")
+	require.NoError(t, err)
+	body, headers = newTestPayload(snsNotification, "text/xml").encoded(dest, asJSON)
+	assert.JSONEq(t, `{"Notification":{"Type":"Notification","Message":"paid"}}`, string(body))
+	assert.Equal(t, "application/json", headers["Content-Type"])
+
+	// Payloads that cannot be parsed or encoded are forwarded unencoded
+	body, headers = newTestPayload(`not json`, "application/json").encoded(dest, msgpack)
+	assert.Equal(t, "not json", string(body))
+	assert.Equal(t, "application/json", headers["Content-Type"])
+
+	form, err := encoder.New(encoder.FormatForm, "", "")
+	require.NoError(t, err)
+	body, _ = newTestPayload(`["a"]`, "application/json").encoded(dest, form)
+	assert.Equal(t, `["a"]`, string(body))
+}
+
+func TestPayloadMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		filters     []config.FilterConfig
+		expected    bool
+	}{
+		{"no filters", `{}`, "application/json", nil, true},
+		{"existing node", snsNotification, "text/xml", []config.FilterConfig{{XPath: "/Notification/Message"}}, true},
+		{"missing node", snsNotification, "text/xml", []config.FilterConfig{{XPath: "/Notification/Subject"}}, false},
+		{"equal value", snsNotification, "text/xml", []config.FilterConfig{{XPath: "//Type", Equals: "Notification"}}, true},
+		{"different value", snsNotification, "text/xml", []config.FilterConfig{{XPath: "//Type", Equals: "SubscriptionConfirmation"}}, false},
+		{"all filters", snsNotification, "text/xml", []config.FilterConfig{{XPath: "//Type", Equals: "Notification"}, {XPath: "//Subject"}}, false},
+		{"not xml", `{"Type":"Notification"}`, "application/json", []config.FilterConfig{{XPath: "//Type"}}, false},
+		{"invalid xml", `<Notification>`, "text/xml", []config.FilterConfig{{XPath: "//Notification"}}, false},
+		{"field", `{}`, "application/json", []config.FilterConfig{{Field: "repo"}}, true},
+		{"equal field", `{}`, "application/json", []config.FilterConfig{{Field: "action", Equals: "opened"}}, true},
+		{"different field", `{}`, "application/json", []config.FilterConfig{{Field: "action", Equals: "closed"}}, false},
+		{"missing field", `{}`, "application/json", []config.FilterConfig{{Field: "ref"}}, false},
+		{"regex field", `{}`, "application/json", []config.FilterConfig{{Field: "repo", Regex: "^octo/"}}, true},
+		{"regex xpath", snsNotification, "text/xml", []config.FilterConfig{{XPath: "//Type", Regex: "^Subscription"}}, false},
+		{"header", `{}`, "application/json", []config.FilterConfig{{Header: "content-type", Equals: "application/json"}}, true},
+		{"missing header", `{}`, "application/json", []config.FilterConfig{{Header: "X-GitHub-Event"}}, false},
+		{"json path", `{"ref":"refs/heads/main"}`, "application/json", []config.FilterConfig{{JSONPath: "ref", Equals: "refs/heads/main"}}, true},
+		{"different json path", `{"ref":"refs/heads/dev"}`, "application/json", []config.FilterConfig{{JSONPath: "ref", Equals: "refs/heads/main"}}, false},
+		{"regex json path", `{"ref":"refs/heads/release/1.2"}`, "application/json", []config.FilterConfig{{JSONPath: "ref", Regex: "^refs/heads/(main|release/.*)$"}}, true},
+		{"nested json path", `{"commits":[{"author":{"name":"mona"}}]}`, "application/json", []config.FilterConfig{{JSONPath: "commits.0.author.name", Equals: "mona"}}, true},
+		{"json path out of range", `{"commits":[]}`, "application/json", []config.FilterConfig{{JSONPath: "commits.0.author.name"}}, false},
+		{"json path number", `{"pull_request":{"number":42}}`, "application/json", []config.FilterConfig{{JSONPath: "pull_request.number", Equals: "42"}}, true},
+		{"json path boolean", `{"deleted":false}`, "application/json", []config.FilterConfig{{JSONPath: "deleted", Equals: "false"}}, true},
+		{"json path null", `{"ref":null}`, "application/json", []config.FilterConfig{{JSONPath: "ref"}}, false},
+		{"json path not json", snsNotification, "text/xml", []config.FilterConfig{{JSONPath: "Notification"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := newTestPayload(tt.body, tt.contentType)
+			webhook.fields = map[string]string{"event": "pull_request", "action": "opened", "repo": "octo/hello"}
+			assert.Equal(t, tt.expected, webhook.matches(tt.filters))
+		})
+	}
+}
+
+func TestForwardWebhookFilters(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	received := make(chan string, 2)
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- name
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	notifications := newServer("notifications")
+	defer notifications.Close()
+	confirmations := newServer("confirmations")
+	defer confirmations.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: notifications.URL, Method: "POST", Timeout: time.Second, Filters: []config.FilterConfig{{XPath: "/Notification/Type", Equals: "Notification"}}},
+		{URL: confirmations.URL, Method: "POST", Timeout: time.Second, Filters: []config.FilterConfig{{XPath: "/Notification/Type", Equals: "SubscriptionConfirmation"}}},
+	}, logger)
+
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte(snsNotification), map[string]string{"Content-Type": "text/xml"}))
+
+	select {
+	case name := <-received:
+		assert.Equal(t, "notifications", name)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be forwarded to the matching destination")
+	}
+
+	select {
+	case name := <-received:
+		t.Fatalf("Expected no delivery to %s", name)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestForwardWebhookEvents(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	received := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-GitHub-Event")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{{
+		URL:     server.URL,
+		Method:  "POST",
+		Timeout: time.Second,
+		Events:  &config.EventsConfig{Allow: []string{"push", "pull_request"}},
+	}}, logger)
+
+	for _, event := range []string{"issues", "push"} {
+		delivery := webhook.New("/webhook", []byte(`{}`), map[string]string{"X-GitHub-Event": event})
+		delivery.Metadata = map[string]string{config.ProviderFieldEvent: event}
+		handler.ForwardWebhook(context.Background(), delivery)
+	}
+
+	select {
+	case event := <-received:
+		assert.Equal(t, "push", event)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the allowed event to be forwarded")
+	}
+
+	select {
+	case event := <-received:
+		t.Fatalf("Expected no delivery of %s", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestForwardWebhookTransform(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	received := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{{
+		URL:       server.URL,
+		Method:    "POST",
+		Timeout:   time.Second,
+		Transform: &config.TransformConfig{Template: `{"text": {{json .Payload.repository.full_name}}}`, ContentType: "application/json"},
+	}}, logger)
+	require.Contains(t, handler.transforms, handler.destinations[0].Key())
+
+	body := []byte(`{"action":"opened","repository":{"full_name":"octo/hello"}}`)
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", body, map[string]string{"Content-Type": "application/json"}))
+
+	select {
+	case data := <-received:
+		assert.JSONEq(t, `{"text":"octo/hello"}`, string(data))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the transformed webhook to be forwarded")
+	}
+}
+
+func TestForwardWebhookEncoding(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	received := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{{
+		URL:      server.URL,
+		Method:   "POST",
+		Timeout:  time.Second,
+		Encoding: &config.EncodingConfig{Format: encoder.FormatForm},
+	}}, logger)
+	require.Contains(t, handler.encoders, handler.destinations[0].Key())
+
+	body := []byte(`{"action":"opened","repository":{"full_name":"octo/hello"}}`)
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", body, map[string]string{"Content-Type": "application/json"}))
+
+	select {
+	case data := <-received:
+		assert.Equal(t, "action=opened&repository%5Bfull_name%5D=octo%2Fhello", string(data))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the encoded webhook to be forwarded")
+	}
+}
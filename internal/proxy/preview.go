@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/encoder"
+	"github.com/flemzord/webhook-proxy/internal/provider"
+	"github.com/flemzord/webhook-proxy/internal/transform"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+// Preview describes what a destination would receive for a webhook, without sending it
+type Preview struct {
+	Destination config.DestinationConfig
+
+	// Skipped is the reason the destination would not receive the webhook, if any
+	Skipped string
+
+	// Method, URL and Header of the request to an HTTP destination
+	Method string
+	URL    string
+	Header http.Header
+
+	// Body is sent to the destination, before compression
+	Body []byte
+}
+
+// PreviewWebhook runs the filters, conversions, transforms and encodings of an endpoint's destinations on a
+// webhook offline and returns, in configuration order, what each destination would
+// receive. Nothing is sent, and no destination or sink is opened.
+func PreviewWebhook(endpoint config.EndpointConfig, body []byte, headers map[string]string, log *logrus.Logger) []Preview {
+	// Field filters match the routing fields of the endpoint's provider
+	delivery := webhook.New(endpoint.Path, body, headers)
+	if preset, ok := provider.Get(endpoint.Provider); ok {
+		delivery.Metadata = preset.Extract(delivery).Fields
+	}
+	payload := newPayload(delivery, log.WithField("endpoint", endpoint.Path))
+
+	previews := make([]Preview, 0, len(endpoint.Destinations))
+	for _, dest := range endpoint.Destinations {
+		preview := Preview{Destination: dest}
+		if len(dest.Filters) > 0 && !payload.matches(dest.Filters) {
+			preview.Skipped = "does not match the destination filters"
+			previews = append(previews, preview)
+			continue
+		}
+		if !dest.Events.Allows(payload.fields[config.ProviderFieldEvent]) {
+			preview.Skipped = "event type is not sent to the destination"
+			previews = append(previews, preview)
+			continue
+		}
+
+		destBody, destHeaders := payload.forDestination(dest)
+		if dest.Transform != nil {
+			tmpl, err := transform.Parse(dest.Transform.Template)
+			if err != nil {
+				preview.Skipped = fmt.Sprintf("invalid transform template: %v", err)
+				previews = append(previews, preview)
+				continue
+			}
+			destBody, destHeaders = payload.transformed(dest, tmpl)
+		} else if dest.Encoding != nil {
+			enc, err := encoder.New(dest.Encoding.Format, dest.Encoding.Schema, dest.Encoding.Message)
+			if err != nil {
+				preview.Skipped = fmt.Sprintf("invalid encoding: %v", err)
+				previews = append(previews, preview)
+				continue
+			}
+			destBody, destHeaders = payload.encoded(dest, enc)
+		} else if dest.Type == config.DestinationTypeSlack {
+			formatter, err := newSlackFormatter(dest)
+			if err != nil {
+				preview.Skipped = fmt.Sprintf("invalid slack text: %v", err)
+				previews = append(previews, preview)
+				continue
+			}
+			destBody, destHeaders = payload.slack(dest, formatter)
+		}
+		destHeaders = newCorrelation(context.Background()).headers(dest, destHeaders)
+
+		// Bodies over the destination's limit are truncated, or fail without being sent
+		if dest.MaxBodySize > 0 && int64(len(destBody)) > dest.MaxBodySize {
+			if dest.OnOversize != config.OversizeTruncate {
+				preview.Skipped = fmt.Sprintf("%v: %d bytes, max %d", errBodyTooLarge, len(destBody), dest.MaxBodySize)
+				previews = append(previews, preview)
+				continue
+			}
+			destBody = destBody[:dest.MaxBodySize]
+		}
+		preview.Body = destBody
+
+		// Sinks receive the body and headers as converted
+		if !dest.SendsHTTP() {
+			preview.Header = make(http.Header, len(destHeaders))
+			for k, v := range destHeaders {
+				preview.Header.Set(k, v)
+			}
+			previews = append(previews, preview)
+			continue
+		}
+
+		now := time.Now()
+		req, err := newRequest(context.Background(), dest, destBody, destHeaders, signingTime(dest.Signing, now, now))
+		if err != nil {
+			preview.Skipped = err.Error()
+			previews = append(previews, preview)
+			continue
+		}
+		preview.Method, preview.URL, preview.Header = req.Method, req.URL.String(), req.Header
+		previews = append(previews, preview)
+	}
+
+	return previews
+}
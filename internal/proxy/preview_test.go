@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"io"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewWebhook(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	endpoint := config.EndpointConfig{
+		Path: "/webhook/orders",
+		Destinations: []config.DestinationConfig{
+			{
+				Type:        config.DestinationTypeHTTP,
+				URL:         "https://example.com/orders",
+				Method:      "PUT",
+				Headers:     map[string]string{"X-Source": "proxy"},
+				XMLFormat:   config.PayloadFormatJSON,
+				Compression: config.CompressionGzip,
+				Signing:     &config.SigningConfig{Secrets: []string{"s3cret"}, Header: config.DefaultSigningHeader},
+			},
+			{
+				Type:    config.DestinationTypeHTTP,
+				URL:     "https://example.com/paid",
+				Method:  "POST",
+				Filters: []config.FilterConfig{{XPath: "/order/status", Equals: "paid"}},
+			},
+			{
+				Type:        config.DestinationTypeHTTP,
+				URL:         "https://example.com/small",
+				Method:      "POST",
+				MaxBodySize: 10,
+				OnOversize:  config.OversizeDeadLetter,
+			},
+			{
+				Type:      config.DestinationTypeWebSocket,
+				WebSocket: &config.WebSocketConfig{Path: "/live/orders"},
+			},
+		},
+	}
+	body := []byte(`<order><status>pending</status></order>`)
+	headers := map[string]string{"Content-Type": "application/xml"}
+
+	previews := PreviewWebhook(endpoint, body, headers, log)
+	require.Len(t, previews, 4)
+
+	// The HTTP destination gets the converted body, with its headers, compression and signature
+	assert.Empty(t, previews[0].Skipped)
+	assert.Equal(t, "PUT", previews[0].Method)
+	assert.Equal(t, "https://example.com/orders", previews[0].URL)
+	assert.JSONEq(t, `{"order":{"status":"pending"}}`, string(previews[0].Body))
+	assert.Equal(t, "application/json", previews[0].Header.Get("Content-Type"))
+	assert.Equal(t, "proxy", previews[0].Header.Get("X-Source"))
+	assert.Equal(t, "gzip", previews[0].Header.Get("Content-Encoding"))
+	assert.Contains(t, previews[0].Header.Get(config.DefaultSigningHeader), "v1=")
+
+	assert.Equal(t, "does not match the destination filters", previews[1].Skipped)
+	assert.Contains(t, previews[2].Skipped, "body too large for destination")
+
+	// Sinks get the body and headers as received
+	assert.Empty(t, previews[3].Skipped)
+	assert.Empty(t, previews[3].Method)
+	assert.Equal(t, body, previews[3].Body)
+	assert.Equal(t, "application/xml", previews[3].Header.Get("Content-Type"))
+}
@@ -2,62 +2,519 @@ package proxy
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/flemzord/webhook-proxy/internal/bufpool"
 	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/encoder"
+	"github.com/flemzord/webhook-proxy/internal/events"
 	"github.com/flemzord/webhook-proxy/internal/logger"
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
+	"github.com/flemzord/webhook-proxy/internal/sink"
+	"github.com/flemzord/webhook-proxy/internal/transform"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
+// drainInterval is the period at which Drain checks for the deliveries in flight
+const drainInterval = 50 * time.Millisecond
+
+// errBodyTooLarge is returned for deliveries whose body exceeds the destination's max body size
+var errBodyTooLarge = logger.WithClass("body_too_large", errors.New("body too large for destination"))
+
+// errDeliveryQueueFull is returned for deliveries dropped because the queue of the delivery pool is full
+var errDeliveryQueueFull = errors.New("delivery queue full")
+
 // Handler handles forwarding webhooks to destinations
 type Handler struct {
+	endpoint     string
+	labels       map[string]string
 	destinations []config.DestinationConfig
 	client       *http.Client
 	log          *logrus.Logger
 	metrics      *Metrics
+	sinks        map[string]sink.Sink
+	bodyLogging  config.BodyLoggingConfig
+	suppressor   *logger.ErrorSuppressor
+	hooks        []Hook
+	limiters     map[string]*adaptiveLimiter
+	dnsGuards    map[string]*dnsGuard
+	maintenance  map[string]*maintenanceGuard
+	transports   map[string]*http.Transport
+	pools        map[string]*connPool
+	clients      map[string]*http.Client
+	transforms   map[string]*transform.Template
+	encoders     map[string]encoder.Encoder
+	slack        map[string]*slackFormatter
+	retryStore   *retrystore.Store
+	cache        *responseCache
+	generation   atomic.Int64
+	events       *events.Bus
+	pool         *DeliveryPool
+	inFlight     atomic.Int64 // deliveries started by forward and not done yet
+
+	// resuming ends, once the handler drains, the waits of the resumed deliveries for
+	// their next attempt
+	resuming     context.Context
+	stopResuming context.CancelFunc
+
+	// after, when set, replaces the timers of the retry delays
+	after func(time.Duration) <-chan time.Time
 }
 
 // NewProxyHandler creates a new proxy handler
 func NewProxyHandler(destinations []config.DestinationConfig, log *logrus.Logger) *Handler {
+	return NewEndpointHandler(config.EndpointConfig{Destinations: destinations}, log)
+}
+
+// NewEndpointHandler creates a new proxy handler for an endpoint
+func NewEndpointHandler(endpoint config.EndpointConfig, log *logrus.Logger) *Handler {
 	// Create HTTP client with reasonable defaults
 	client := &http.Client{
 		Timeout: 10 * time.Second,
 	}
 
-	return &Handler{
-		destinations: destinations,
+	// Create sinks for non-HTTP destinations and adaptive concurrency limiters
+	sinks := make(map[string]sink.Sink)
+	limiters := make(map[string]*adaptiveLimiter)
+	dnsGuards := make(map[string]*dnsGuard)
+	maintenance := make(map[string]*maintenanceGuard)
+	transports := make(map[string]*http.Transport)
+	pools := make(map[string]*connPool)
+	clients := make(map[string]*http.Client)
+	transforms := make(map[string]*transform.Template)
+	encoders := make(map[string]encoder.Encoder)
+	slack := make(map[string]*slackFormatter)
+	for _, dest := range endpoint.Destinations {
+		if dest.Concurrency != nil {
+			limiters[dest.Key()] = newAdaptiveLimiter(*dest.Concurrency)
+		}
+		if dest.DNSOutage != nil {
+			dnsGuards[dest.Key()] = newDNSGuard(dest, log)
+		}
+		if len(dest.MaintenanceWindows) > 0 {
+			maintenance[dest.Key()] = newMaintenanceGuard(dest, log)
+		}
+
+		// Destinations with the same local address, proxy and protocol share a transport
+		if key := transportKey(dest); key != "" && transports[key] == nil {
+			transport, err := newTransport(dest)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"error":         err,
+					"destination":   dest.Key(),
+					"local_address": dest.LocalAddress,
+					"proxy":         proxyAddress(dest),
+					"protocol":      dest.Protocol,
+				}).Error("Failed to set up the destination's connections, using the shared transport")
+			} else {
+				pools[key] = &connPool{}
+				transports[key] = pools[key].count(transport)
+			}
+		}
+
+		// Deliveries to the destination reuse its client and the connections of its transport
+		clients[dest.Key()] = newClient(dest, transports)
+
+		if dest.Transform != nil {
+			tmpl, err := transform.Parse(dest.Transform.Template)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"error":       err,
+					"destination": dest.Key(),
+				}).Error("Failed to parse the destination's transform template, forwarding webhooks untransformed")
+			} else {
+				transforms[dest.Key()] = tmpl
+			}
+		}
+
+		if dest.Encoding != nil {
+			enc, err := encoder.New(dest.Encoding.Format, dest.Encoding.Schema, dest.Encoding.Message)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"error":       err,
+					"destination": dest.Key(),
+					"format":      dest.Encoding.Format,
+				}).Error("Failed to set up the destination's encoding, forwarding webhooks unencoded")
+			} else {
+				encoders[dest.Key()] = enc
+			}
+		}
+
+		if dest.Type == config.DestinationTypeSlack {
+			formatter, err := newSlackFormatter(dest)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"error":       err,
+					"destination": dest.Key(),
+				}).Error("Failed to parse the destination's Slack message template, sending the default message")
+				formatter = &slackFormatter{config: *dest.Slack}
+			}
+			slack[dest.Key()] = formatter
+		}
+
+		if dest.Chaos != nil {
+			log.WithFields(logrus.Fields{
+				"endpoint":    endpoint.Path,
+				"destination": dest.Key(),
+			}).Warn("Chaos mode enabled, deliveries to this destination will be delayed, dropped or duplicated")
+		}
+
+		s, err := sink.New(endpoint.Path, dest, log)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error":       err,
+				"destination": dest.Key(),
+				"type":        dest.Type,
+			}).Error("Failed to create destination sink")
+			continue
+		}
+		if s != nil {
+			sinks[dest.Key()] = s
+		}
+	}
+
+	metrics := NewMetrics()
+	hooks := []Hook{&metricsHook{metrics: metrics}}
+
+	// Report the final outcome of each delivery to the callback URL
+	if endpoint.CallbackURL != "" {
+		hooks = append(hooks, newReceiptHook(endpoint.CallbackURL, log))
+	}
+
+	// Lifecycle events are logged until the handler is attached to a shared bus
+	bus := events.NewBus()
+	bus.Subscribe(events.LogSubscriber(log))
+
+	p := &Handler{
+		endpoint:     endpoint.Path,
+		labels:       endpoint.Labels,
+		destinations: endpoint.Destinations,
 		client:       client,
 		log:          log,
-		metrics:      NewMetrics(),
+		metrics:      metrics,
+		sinks:        sinks,
+		hooks:        hooks,
+		limiters:     limiters,
+		dnsGuards:    dnsGuards,
+		maintenance:  maintenance,
+		transports:   transports,
+		pools:        pools,
+		clients:      clients,
+		transforms:   transforms,
+		encoders:     encoders,
+		slack:        slack,
+		cache:        newResponseCache(),
+		events:       bus,
 	}
+	p.resuming, p.stopResuming = context.WithCancel(context.Background())
+	p.hooks = append(p.hooks, &eventsHook{publish: p.publish})
+	for _, guard := range dnsGuards {
+		guard.publish = p.publish
+	}
+
+	return p
 }
 
-// ForwardWebhook forwards a webhook to all configured destinations
-func (p *Handler) ForwardWebhook(body []byte, headers map[string]string) {
+// AddHook registers a hook called at each step of the delivery lifecycle.
+// Hooks must be added before the handler starts forwarding webhooks.
+func (p *Handler) AddHook(hook Hook) {
+	p.hooks = append(p.hooks, hook)
+}
+
+// SetEventBus publishes the handler's lifecycle events on a bus shared with other
+// components, whose subscribers replace the handler's own logging of the events.
+// The bus must be set before the handler starts forwarding webhooks.
+func (p *Handler) SetEventBus(bus *events.Bus) {
+	p.events = bus
+}
+
+// SetDeliveryPool runs the deliveries of the handler in the pool, instead of a goroutine
+// each. The pool must be set before the handler starts forwarding webhooks.
+func (p *Handler) SetDeliveryPool(pool *DeliveryPool) {
+	p.pool = pool
+}
+
+// publish publishes a lifecycle event of the endpoint
+func (p *Handler) publish(event events.Event) {
+	event.Endpoint = p.endpoint
+	p.events.Publish(event)
+}
+
+// SetGeneration sets the configuration generation of the endpoint, reported with its deliveries
+func (p *Handler) SetGeneration(generation int64) {
+	p.generation.Store(generation)
+}
+
+// Generation returns the configuration generation of the endpoint, or 0 when it is not tracked
+func (p *Handler) Generation() int64 {
+	return p.generation.Load()
+}
+
+// ForwardWebhook forwards a webhook to all configured destinations, matching the filters
+// of the destinations. The deliveries run within the context: its cancellation or deadline
+// stops their attempts and retries, and its span and request ID (see WithRequestID) are
+// sent to the destinations asking for correlation headers.
+func (p *Handler) ForwardWebhook(ctx context.Context, delivery *webhook.Delivery) {
+	p.forward(ctx, delivery, "", false)
+}
+
+// DeliverWebhook forwards a webhook like ForwardWebhook, and returns once the delivery to
+// every destination is done, retries included: delivered or dead-lettered. The webhook is
+// expected to be persisted, so its deliveries wait for a slot of the delivery pool however
+// full its queue is, instead of being dropped.
+func (p *Handler) DeliverWebhook(ctx context.Context, delivery *webhook.Delivery) {
+	p.forward(ctx, delivery, "", true).Wait()
+}
+
+// ReplayWebhook forwards a webhook again like ForwardWebhook or, when a destination is
+// given, to that destination only, whatever its filters. It returns false if the
+// destination is not configured on this handler.
+func (p *Handler) ReplayWebhook(ctx context.Context, delivery *webhook.Delivery, destination string) bool {
+	if destination == "" {
+		p.forward(ctx, delivery, "", false)
+		return true
+	}
+	for _, dest := range p.destinations {
+		if dest.Key() == destination {
+			p.forward(ctx, delivery, destination, false)
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether a webhook is forwarded to a destination, rather than left out by
+// the filters or event types of every destination
+func (p *Handler) Matches(delivery *webhook.Delivery) bool {
+	payload := newPayload(delivery, p.log.WithField("endpoint", p.endpoint))
+	for _, dest := range p.destinations {
+		if len(dest.Filters) > 0 && !payload.matches(dest.Filters) {
+			continue
+		}
+		if dest.Events.Allows(payload.fields[config.ProviderFieldEvent]) {
+			return true
+		}
+	}
+	return len(p.destinations) == 0
+}
+
+// RecordUnmatched counts a webhook that no destination matches, so that it is not forwarded
+func (p *Handler) RecordUnmatched() {
+	p.metrics.RecordUnmatched()
+}
+
+// forward starts the delivery of a webhook to each matching destination, or to the only
+// given one, in its own goroutine, and returns the group the deliveries are done with.
+// With wait, the deliveries are never dropped by the delivery pool.
+func (p *Handler) forward(ctx context.Context, delivery *webhook.Delivery, only string, wait bool) *sync.WaitGroup {
+	ctx = withWebhookID(ctx, delivery.ID)
+	if delivery.Listener != "" {
+		ctx = WithListener(ctx, delivery.Listener)
+	}
+	received := &Event{
+		Context:    ctx,
+		WebhookID:  delivery.ID,
+		Listener:   Listener(ctx),
+		Endpoint:   p.endpoint,
+		Generation: p.Generation(),
+		Body:       delivery.Body,
+		Headers:    delivery.Headers,
+		ReceivedAt: delivery.ReceivedAt,
+	}
+	for _, hook := range p.hooks {
+		hook.OnReceive(received)
+	}
+
 	var wg sync.WaitGroup
 
+	// Conversions and parsed payloads are shared by the destinations
+	log := p.logFor(ctx)
+	payload := newPayload(delivery, log.WithField("endpoint", p.endpoint))
+	correlation := newCorrelation(ctx)
+
 	for _, dest := range p.destinations {
+		if only != "" && dest.Key() != only {
+			continue
+		}
+		if only == "" && len(dest.Filters) > 0 && !payload.matches(dest.Filters) {
+			log.WithFields(logrus.Fields{
+				"endpoint":    p.endpoint,
+				"destination": dest.Key(),
+			}).Debug("Webhook does not match the destination filters, skipping")
+			continue
+		}
+		if only == "" && !dest.Events.Allows(payload.fields[config.ProviderFieldEvent]) {
+			log.WithFields(logrus.Fields{
+				"endpoint":    p.endpoint,
+				"destination": dest.Key(),
+				"event_type":  payload.fields[config.ProviderFieldEvent],
+			}).Debug("Webhook event type is not sent to the destination, skipping")
+			continue
+		}
+		destBody, destHeaders := payload.forDestination(dest)
+		if tmpl, ok := p.transforms[dest.Key()]; ok {
+			destBody, destHeaders = payload.transformed(dest, tmpl)
+		} else if enc, ok := p.encoders[dest.Key()]; ok {
+			destBody, destHeaders = payload.encoded(dest, enc)
+		} else if formatter, ok := p.slack[dest.Key()]; ok {
+			destBody, destHeaders = payload.slack(dest, formatter)
+		}
+		destHeaders = rawCase(correlation.headers(dest, destHeaders), delivery.RawHeaders)
+
 		wg.Add(1)
-		// Forward to each destination in a separate goroutine
-		go func(d config.DestinationConfig) {
-			defer wg.Done()
-			p.forwardToDestination(d, body, headers)
-		}(dest)
+		p.inFlight.Add(1)
+		run := func(ctx context.Context) {
+			p.startForwarding(ctx, dest, destBody, destHeaders, delivery.ReceivedAt, func(context.Context) {
+				wg.Done()
+				p.inFlight.Add(-1)
+			})
+		}
+
+		// Forward to each destination in a separate goroutine, taking slots of the pool
+		if p.pool == nil {
+			go run(ctx)
+		} else if !p.pool.Go(ctx, dest.Key(), wait, run) {
+			wg.Done()
+			p.inFlight.Add(-1)
+			p.dropDelivery(ctx, dest, destBody, destHeaders, delivery.ReceivedAt)
+		}
 	}
 
-	// Wait for all forwarding operations to complete (optional)
-	// If you want to return immediately to the caller, comment this out
-	// wg.Wait()
+	return &wg
+}
+
+// dropDelivery dead-letters a delivery dropped because the queue of the delivery pool is
+// full, without attempting it, so that it can be redriven
+func (p *Handler) dropDelivery(ctx context.Context, dest config.DestinationConfig, body []byte, headers map[string]string, receivedAt time.Time) {
+	event := &Event{
+		Context:     ctx,
+		ID:          uuid.NewString(),
+		WebhookID:   WebhookID(ctx),
+		Listener:    Listener(ctx),
+		Endpoint:    p.endpoint,
+		Generation:  p.Generation(),
+		Destination: dest,
+		Body:        body,
+		Headers:     headers,
+		MaxAttempts: max(dest.Retries+1, 1),
+		Err:         errDeliveryQueueFull,
+		ReceivedAt:  receivedAt,
+	}
+
+	p.logFor(ctx).WithFields(logrus.Fields{
+		"endpoint":    p.endpoint,
+		"destination": dest.Key(),
+		"delivery_id": event.ID,
+	}).Warn("Delivery queue full, dead-lettering the delivery")
+
+	for _, hook := range p.hooks {
+		hook.OnDeadLetter(event)
+	}
+}
+
+// Drain waits for the deliveries of the forwarded webhooks, retries included, until the
+// context is done. Resumed deliveries still waiting for their next attempt stop waiting and
+// are not waited for: their state stays persisted, for the next start to resume them.
+func (p *Handler) Drain(ctx context.Context) error {
+	p.stopResuming()
+
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for p.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
 }
 
 // GetMetrics returns the current metrics
 func (p *Handler) GetMetrics() map[string]interface{} {
-	return p.metrics.GetMetrics()
+	metrics := p.metrics.GetMetrics()
+	if generation := p.Generation(); generation > 0 {
+		metrics["config_generation"] = generation
+	}
+	if len(p.labels) > 0 {
+		metrics["labels"] = p.labels
+	}
+
+	// Add the state of the adaptive concurrency limits
+	destinations, _ := metrics["destinations"].(map[string]interface{})
+	for key, limiter := range p.limiters {
+		if dest, ok := destinations[key].(map[string]interface{}); ok {
+			dest["concurrency_limit"] = limiter.Limit()
+			dest["in_flight"] = limiter.InFlight()
+		}
+	}
+
+	// Add the labels of the destinations, and the connections of the HTTP destinations' transports
+	for _, destination := range p.destinations {
+		dest, ok := destinations[destination.Key()].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if len(destination.Labels) > 0 {
+			dest["labels"] = destination.Labels
+		}
+		if _, ok := p.sinks[destination.Key()]; !ok {
+			dest["connections_open"], dest["connections_opened"], dest["connections_reused"] = p.connPool(destination).stats()
+		}
+	}
+
+	// Add the state of the destinations paused by a DNS outage
+	for key, guard := range p.dnsGuards {
+		if dest, ok := destinations[key].(map[string]interface{}); ok {
+			dest["dns_paused"], dest["dns_queued"] = guard.state()
+		}
+	}
+
+	// Add the state of the destinations with maintenance windows
+	for key, guard := range p.maintenance {
+		if dest, ok := destinations[key].(map[string]interface{}); ok {
+			dest["maintenance_active"], dest["maintenance_waiting"] = guard.state()
+		}
+	}
+
+	return metrics
+}
+
+// Health returns the status of each destination, keyed by destination: ok, or paused
+// with the reason and the number of deliveries waiting for it to resume
+func (p *Handler) Health() map[string]interface{} {
+	health := make(map[string]interface{}, len(p.destinations))
+	for _, dest := range p.destinations {
+		status := map[string]interface{}{"status": "ok"}
+		if guard, ok := p.dnsGuards[dest.Key()]; ok {
+			if paused, queued := guard.state(); paused {
+				status["status"] = "paused"
+				status["reason"] = "dns_outage"
+				status["queued"] = queued
+			}
+		}
+		if guard, ok := p.maintenance[dest.Key()]; ok {
+			if active, waiting := guard.state(); active {
+				status["status"] = "paused"
+				status["reason"] = "maintenance"
+				status["queued"] = waiting
+			}
+		}
+		health[dest.Key()] = status
+	}
+	return health
 }
 
 // ResetMetrics resets all metrics
@@ -65,14 +522,239 @@ func (p *Handler) ResetMetrics() {
 	p.metrics.Reset()
 }
 
-// forwardToDestination forwards a webhook to a single destination
-func (p *Handler) forwardToDestination(dest config.DestinationConfig, body []byte, headers map[string]string) {
-	// Record the request in metrics
-	p.metrics.RecordRequest(dest.URL)
+// Prewarm opens connections to the HTTP destinations with a HEAD request, so that the
+// DNS lookup and TLS handshake are done before the first webhook. Connections are
+// kept in the shared transport's idle pool and reused by deliveries.
+func (p *Handler) Prewarm(ctx context.Context) {
+	for _, dest := range p.destinations {
+		if _, ok := p.sinks[dest.Key()]; ok {
+			continue
+		}
+
+		if err := p.probeDestination(ctx, dest, http.MethodHead); err != nil {
+			p.log.WithFields(logrus.Fields{
+				"error":       err,
+				"destination": dest.Key(),
+			}).Warn("Failed to prewarm destination connection")
+			continue
+		}
 
-	// Set client timeout for this specific request
-	client := &http.Client{
-		Timeout: dest.Timeout,
+		p.log.WithFields(logrus.Fields{
+			"destination": dest.Key(),
+		}).Debug("Destination connection prewarmed")
+	}
+}
+
+// ProbeResult is the outcome of probing an HTTP destination, a nil Err meaning it is reachable
+type ProbeResult struct {
+	Destination config.DestinationConfig
+	Err         error
+}
+
+// Probe sends a request with the given method (HEAD or OPTIONS) to the HTTP destinations
+// concurrently and returns the results in configuration order. A destination answering
+// with any status is reachable.
+func (p *Handler) Probe(ctx context.Context, method string) []ProbeResult {
+	var results []ProbeResult
+	for _, dest := range p.destinations {
+		if _, ok := p.sinks[dest.Key()]; !ok {
+			results = append(results, ProbeResult{Destination: dest})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(result *ProbeResult) {
+			defer wg.Done()
+			result.Err = p.probeDestination(ctx, result.Destination, method)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results
+}
+
+// probeDestination sends a request without body to the destination, whatever the response status
+func (p *Handler) probeDestination(ctx context.Context, dest config.DestinationConfig, method string) error {
+	ctx, cancel := context.WithTimeout(ctx, dest.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, dest.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.httpClient(dest).Do(req)
+	if err != nil {
+		return err
+	}
+
+	// Drain the body so the connection goes back to the idle pool
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// SetBodyLogging configures the logging of destination response bodies
+func (p *Handler) SetBodyLogging(cfg config.BodyLoggingConfig) {
+	p.bodyLogging = cfg
+}
+
+// SetTransport sends the requests of the HTTP destinations through the round tripper,
+// instead of their connections. The transport must be set before the handler starts
+// forwarding webhooks.
+func (p *Handler) SetTransport(transport http.RoundTripper) {
+	for _, client := range p.clients {
+		client.Transport = transport
+	}
+}
+
+// SetRetryTimer waits for the retry delays on the channels returned by after, which
+// receive once the delay is over, instead of timers and the retry queue of the delivery
+// pool. The timer must be set before the handler starts forwarding webhooks.
+func (p *Handler) SetRetryTimer(after func(time.Duration) <-chan time.Time) {
+	p.after = after
+}
+
+// InFlight returns the number of deliveries started by ForwardWebhook and not done yet,
+// retries included
+func (p *Handler) InFlight() int64 {
+	return p.inFlight.Load()
+}
+
+// SetErrorSuppressor summarizes repeated delivery failures instead of logging each of them
+func (p *Handler) SetErrorSuppressor(suppressor *logger.ErrorSuppressor) {
+	p.suppressor = suppressor
+}
+
+// Close releases the resources held by the handler's sinks, flushing any buffered data,
+// and the idle connections of its local address transports
+func (p *Handler) Close() error {
+	for _, transport := range p.transports {
+		transport.CloseIdleConnections()
+	}
+	for _, guard := range p.dnsGuards {
+		guard.stop()
+	}
+
+	var errs []error
+	for key, s := range p.sinks {
+		if closer, ok := s.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sinks returns the sinks of the handler's non-HTTP destinations
+func (p *Handler) Sinks() []sink.Sink {
+	sinks := make([]sink.Sink, 0, len(p.sinks))
+	for _, s := range p.sinks {
+		sinks = append(sinks, s)
+	}
+	return sinks
+}
+
+// forwardToDestination forwards a webhook to a single destination, and returns once its
+// deliveries are done
+func (p *Handler) forwardToDestination(ctx context.Context, dest config.DestinationConfig, body []byte, headers map[string]string, receivedAt time.Time) {
+	done := make(chan struct{})
+	p.startForwarding(ctx, dest, body, headers, receivedAt, func(context.Context) { close(done) })
+	<-done
+}
+
+// startForwarding forwards a webhook to a single destination, and calls done with the
+// context of the last delivery once its deliveries are done, from another goroutine when a
+// delivery waits for a retry in the delivery pool
+func (p *Handler) startForwarding(ctx context.Context, dest config.DestinationConfig, body []byte, headers map[string]string, receivedAt time.Time, done func(ctx context.Context)) {
+	if dest.Chaos == nil {
+		p.runDelivery(ctx, p.newDelivery(ctx, uuid.NewString(), dest, body, headers, 1, receivedAt), done)
+		return
+	}
+
+	// Inject the faults drawn for this webhook
+	faults := drawChaosFaults(*dest.Chaos)
+	fields := logrus.Fields{
+		"endpoint":    p.endpoint,
+		"destination": dest.Key(),
+	}
+	log := p.logFor(ctx).WithFields(fields)
+	if faults.drop {
+		log.Info("Chaos: dropping webhook")
+		done(ctx)
+		return
+	}
+	if faults.delay > 0 {
+		log.WithField("delay", faults.delay).Info("Chaos: delaying webhook")
+		releaseSlot(ctx)
+		timer := time.NewTimer(faults.delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// The delivery still runs, and fails or gives up on the context like any other
+			timer.Stop()
+		}
+	}
+
+	p.runDelivery(ctx, p.newDelivery(ctx, uuid.NewString(), dest, body, headers, 1, receivedAt), func(ctx context.Context) {
+		if !faults.duplicate {
+			done(ctx)
+			return
+		}
+		log.Info("Chaos: duplicating webhook")
+		p.runDelivery(ctx, p.newDelivery(ctx, uuid.NewString(), dest, body, headers, 1, receivedAt), done)
+	})
+}
+
+// Redrive starts a new delivery, with its own retries, of a webhook that failed for good
+// to one of the handler's destinations. It returns false if the destination is no longer
+// configured on this handler.
+func (p *Handler) Redrive(ctx context.Context, destination string, body []byte, headers map[string]string) bool {
+	for _, dest := range p.destinations {
+		if dest.Key() != destination {
+			continue
+		}
+		go p.deliver(ctx, uuid.NewString(), dest, body, headers, 1, time.Time{})
+		return true
+	}
+	return false
+}
+
+// deliver runs the attempts of a delivery, starting at the given attempt, and returns once
+// they are done. The time the webhook was received is the signing time of the destinations
+// signing with it; when zero, the delivery's start is used.
+func (p *Handler) deliver(ctx context.Context, id string, dest config.DestinationConfig, body []byte, headers map[string]string, firstAttempt int, receivedAt time.Time) {
+	done := make(chan struct{})
+	p.runDelivery(ctx, p.newDelivery(ctx, id, dest, body, headers, firstAttempt, receivedAt), func(context.Context) { close(done) })
+	<-done
+}
+
+// delivery is the state of a delivery kept across its attempts, so that it can wait for a
+// retry in the retry queue of the delivery pool without its goroutine
+type delivery struct {
+	log         logrus.FieldLogger
+	dest        config.DestinationConfig
+	body        []byte
+	headers     map[string]string
+	event       *Event
+	attempt     int
+	maxAttempts int
+	oversized   bool
+	startTime   time.Time
+	deadline    time.Time
+	attempts    []logger.DeliveryAttempt
+	outcome     string
+}
+
+// newDelivery prepares a delivery starting at the given attempt
+func (p *Handler) newDelivery(ctx context.Context, id string, dest config.DestinationConfig, body []byte, headers map[string]string, firstAttempt int, receivedAt time.Time) *delivery {
+	// Every log line of the delivery carries the IDs of the webhook and of the delivery, and
+	// the labels of the destination
+	log := p.logFor(ctx).WithField("delivery_id", id)
+	if len(dest.Labels) > 0 {
+		log = log.WithField("labels", dest.Labels)
 	}
 
 	// Retry logic
@@ -81,99 +763,375 @@ func (p *Handler) forwardToDestination(dest config.DestinationConfig, body []byt
 		maxAttempts = 1 // At least one attempt
 	}
 
-	var lastErr error
+	startTime := time.Now()
+	if receivedAt.IsZero() {
+		receivedAt = startTime
+	}
 
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		isRetry := attempt > 1
+	// Bodies over the destination's limit are truncated, or fail without being sent
+	oversized := dest.MaxBodySize > 0 && int64(len(body)) > dest.MaxBodySize
+	if oversized && dest.OnOversize == config.OversizeTruncate {
+		log.WithFields(logrus.Fields{
+			"destination":   dest.Key(),
+			"body_size":     len(body),
+			"max_body_size": dest.MaxBodySize,
+		}).Warn("Truncating body larger than the destination's max body size")
+		body = body[:dest.MaxBodySize]
+		oversized = false
+	}
 
-		// Send the request
-		statusCode, respBody, duration, err := p.sendRequest(client, dest, body, headers, isRetry)
-		if err != nil {
-			lastErr = err
+	d := &delivery{
+		log:         log,
+		dest:        dest,
+		body:        body,
+		headers:     headers,
+		attempt:     firstAttempt,
+		maxAttempts: maxAttempts,
+		oversized:   oversized,
+		startTime:   startTime,
+		attempts:    make([]logger.DeliveryAttempt, 0, maxAttempts),
+		outcome:     logger.OutcomeFailure,
+		event: &Event{
+			Context:     ctx,
+			ID:          id,
+			WebhookID:   WebhookID(ctx),
+			Listener:    Listener(ctx),
+			Endpoint:    p.endpoint,
+			Generation:  p.Generation(),
+			Destination: dest,
+			Body:        body,
+			Headers:     headers,
+			MaxAttempts: maxAttempts,
+			ReceivedAt:  receivedAt,
+		},
+	}
+
+	// Bound the total time spent across all attempts
+	if dest.MaxDeliveryDuration > 0 {
+		d.deadline = startTime.Add(dest.MaxDeliveryDuration)
+	}
+	return d
+}
+
+// runDelivery runs the attempts of a delivery from its next one, and calls done with the
+// context of the last one once they are done. Within a delivery pool, the delivery waits
+// for its retries in the pool's retry queue: runDelivery then returns at once, and the
+// delivery continues in another goroutine of the pool.
+func (p *Handler) runDelivery(ctx context.Context, d *delivery, done func(ctx context.Context)) {
+	dest, event := d.dest, d.event
+	log := d.log
 
-			// If this is not the last attempt, wait before retrying
-			if p.shouldRetry(attempt, maxAttempts, dest) {
-				continue
+	// Deliveries reuse the client of the destination and its connections
+	client := p.httpClient(dest)
+
+	guard := p.dnsGuards[dest.Key()]
+	maintenance := p.maintenance[dest.Key()]
+
+	for ; d.attempt <= d.maxAttempts; d.attempt++ {
+		attempt, maxAttempts := d.attempt, d.maxAttempts
+
+		// Hold the delivery while the destination is in a maintenance window, unless the deadline passes meanwhile
+		if maintenance != nil && !p.waitForMaintenance(ctx, maintenance, event, attempt, d.deadline) {
+			if event.Err == nil {
+				event.Err = errMaintenance
 			}
-			return
+			break
 		}
 
-		// If successful (2xx status code), log and return
-		if statusCode >= 200 && statusCode < 300 {
-			// Record success in metrics
-			p.metrics.RecordSuccess(dest.URL, statusCode, duration)
+		// Wait while the destination is paused by a DNS outage, unless the deadline passes or the context ends meanwhile
+		if guard != nil && !p.waitForResolution(ctx, guard, event, attempt, d.deadline) {
+			if event.Err == nil {
+				event.Err = errDNSOutage
+			}
+			break
+		}
 
-			// Log success with more details
-			p.log.WithFields(logrus.Fields{
-				"destination":   dest.URL,
-				"status_code":   statusCode,
-				"duration_ms":   duration.Milliseconds(),
+		// Take a slot of the delivery pool for the attempt only, released while the delivery waits
+		if err := acquireSlot(ctx); err != nil {
+			event.Err = err
+			break
+		}
+
+		// An attempt never outlives the delivery deadline
+		attemptDest := dest
+		if !d.deadline.IsZero() {
+			if remaining := time.Until(d.deadline); remaining < attemptDest.Timeout {
+				attemptDest.Timeout = remaining
+			}
+		}
+
+		event.Attempt = attempt
+		event.StatusCode, event.Duration, event.Err, event.Cached, event.ResponseHeaders = 0, 0, nil, false, nil
+		for _, hook := range p.hooks {
+			hook.BeforeForward(event)
+		}
+
+		// Send the request, either through the destination's sink or over HTTP, unless a
+		// cached response is reused. Attempts above the destination's concurrency limit are shed.
+		var respBody []byte
+		limiter := p.limiters[dest.Key()]
+		if d.oversized {
+			event.Err = fmt.Errorf("%w: %d bytes, max %d", errBodyTooLarge, len(d.body), dest.MaxBodySize)
+			limiter = nil
+		} else if cached, ok := p.cachedResponse(dest); ok {
+			event.StatusCode, respBody, event.Cached = cached.statusCode, cached.body, true
+			limiter = nil
+		} else if limiter != nil && !limiter.Acquire() {
+			event.Err = errConcurrencyLimit
+		} else if s, ok := p.sinks[dest.Key()]; ok {
+			event.StatusCode, event.Duration, event.Err = p.sendToSink(ctx, s, attemptDest, event, d.body, d.headers)
+		} else {
+			signedAt := signingTime(dest.Signing, event.ReceivedAt, time.Now())
+			var respHeader http.Header
+			event.StatusCode, respBody, respHeader, event.Duration, event.Err = p.sendRequest(ctx, client, attemptDest, d.body, d.headers, signedAt)
+			event.ResponseHeaders = captureHeaders(dest.CaptureHeaders, respHeader)
+			if event.Err == nil {
+				logger.LogResponseBody(log, p.bodyLogging, dest.Key(), event.StatusCode, attempt, respHeader.Get("Content-Type"), respBody)
+			}
+		}
+		if limiter != nil && event.Err != errConcurrencyLimit {
+			failed := event.Err != nil || event.StatusCode == http.StatusTooManyRequests || event.StatusCode >= 500
+			limiter.Release(event.Duration, failed)
+		}
+
+		// Track the DNS failures of the requests sent to the destination
+		dnsFailure := false
+		if guard != nil && !d.oversized && !event.Cached && event.Err != errConcurrencyLimit {
+			if dnsFailure = errors.As(event.Err, new(*net.DNSError)); !dnsFailure {
+				guard.success()
+			}
+		}
+
+		if event.Err == nil {
+			// The destination answered, check that the response counts as a success
+			if event.Err = checkResponse(dest, event.StatusCode, respBody); event.Err != nil {
+				log.WithFields(logrus.Fields{
+					"destination":   dest.Key(),
+					"status_code":   event.StatusCode,
+					"attempt":       attempt,
+					"max_attempts":  maxAttempts,
+					"response_body": string(respBody),
+				}).Debug("Webhook delivery attempt failed")
+			}
+		}
+
+		record := logger.DeliveryAttempt{
+			Attempt:    attempt,
+			StatusCode: event.StatusCode,
+			DurationMs: event.Duration.Milliseconds(),
+		}
+		if event.Err != nil {
+			record.Error = event.Err.Error()
+		}
+		d.attempts = append(d.attempts, record)
+
+		for _, hook := range p.hooks {
+			hook.AfterForward(event)
+		}
+
+		// If successful, we are done
+		if event.Err == nil {
+			d.outcome = logger.OutcomeSuccess
+
+			// Keep the response of a cached destination for the next deliveries
+			if p.cacheable(dest) && !event.Cached {
+				p.cache.set(dest.URL, event.StatusCode, respBody, time.Now().Add(dest.Cache.TTL))
+			}
+
+			log.WithFields(logrus.Fields{
+				"destination":   dest.Key(),
+				"status_code":   event.StatusCode,
+				"duration_ms":   event.Duration.Milliseconds(),
 				"attempt":       attempt,
 				"response_size": len(respBody),
-			}).Info("Webhook forwarded successfully")
+				"cached":        event.Cached,
+			}).Debug("Webhook forwarded successfully")
 
+			p.completeDelivery(d)
+			done(ctx)
 			return
 		}
 
-		// If we got a non-2xx status code and have retries left
-		lastErr = fmt.Errorf("received non-2xx status code: %d, body: %s", statusCode, string(respBody))
-		logger.LogWebhookError(p.log, dest.URL, lastErr, attempt, maxAttempts)
+		for _, hook := range p.hooks {
+			hook.OnFailure(event)
+		}
 
-		// Record failure in metrics
-		p.metrics.RecordFailure(dest.URL, lastErr.Error(), isRetry)
+		// An oversized body will not shrink on retry
+		if d.oversized {
+			break
+		}
 
-		if p.shouldRetry(attempt, maxAttempts, dest) {
-			// Log retry attempt with more details
-			p.log.WithFields(logrus.Fields{
-				"destination":   dest.URL,
-				"status_code":   statusCode,
-				"attempt":       attempt,
-				"max_attempts":  maxAttempts,
-				"retry_delay":   dest.RetryDelay,
-				"response_body": string(respBody),
-			}).Info("Retrying webhook forwarding due to non-2xx status code")
+		// A DNS outage pausing the destination does not consume the delivery's retries:
+		// the attempt is made again once the host resolves
+		if dnsFailure && guard.failure() {
+			d.attempt--
+			continue
+		}
+
+		// Give up once the delivery's context is cancelled or past its deadline
+		if ctx.Err() != nil {
+			log.WithFields(logrus.Fields{
+				"destination":  dest.Key(),
+				"attempt":      attempt,
+				"max_attempts": maxAttempts,
+				"error":        ctx.Err(),
+			}).Debug("Delivery context done, not retrying")
+			break
+		}
+
+		// Give up on errors whose class the retry policy does not retry
+		if class := logger.ErrorClass(event.Err); attempt < maxAttempts && !dest.RetryErrorClass(class) {
+			log.WithFields(logrus.Fields{
+				"destination":  dest.Key(),
+				"attempt":      attempt,
+				"max_attempts": maxAttempts,
+				"error_class":  class,
+			}).Debug("Error class excluded by the retry policy, not retrying")
+			break
+		}
+
+		// Give up when the next attempt would start past the delivery deadline
+		if !d.deadline.IsZero() && attempt < maxAttempts && time.Now().Add(retryDelay(dest)).After(d.deadline) {
+			log.WithFields(logrus.Fields{
+				"destination":           dest.Key(),
+				"attempt":               attempt,
+				"max_attempts":          maxAttempts,
+				"max_delivery_duration": dest.MaxDeliveryDuration,
+			}).Debug("Max delivery duration reached, not retrying")
+			break
+		}
+
+		// Within a delivery pool, the retry waits in the pool's retry queue instead of the
+		// delivery's goroutine
+		if queue := retryQueueOf(ctx); queue != nil && p.after == nil && attempt < maxAttempts {
+			p.scheduleRetry(ctx, queue, d, done)
+			return
+		}
+
+		// If this is not the last attempt, wait before retrying
+		if !p.shouldRetry(ctx, attempt, maxAttempts, dest) {
+			break
 		}
 	}
 
-	// If we've exhausted all retries, log a final error
-	if lastErr != nil {
-		p.log.WithFields(logrus.Fields{
-			"destination": dest.URL,
-			"error":       lastErr,
-			"attempts":    maxAttempts,
-		}).Error("Webhook forwarding failed after all retry attempts")
+	for _, hook := range p.hooks {
+		hook.OnDeadLetter(event)
 	}
+	p.completeDelivery(d)
+	done(ctx)
 }
 
-// sendRequest sends a request to the destination and returns the status code, response body, duration, and error
-func (p *Handler) sendRequest(client *http.Client, dest config.DestinationConfig, body []byte, headers map[string]string, isRetry bool) (int, []byte, time.Duration, error) {
-	// Create request with context for better timeout handling
-	ctx, cancel := context.WithTimeout(context.Background(), dest.Timeout)
+// scheduleRetry queues the next attempt of a delivery in the retry queue of the delivery
+// pool. Once released, the delivery continues in a goroutine of the pool, waiting in its
+// queue for a slot; if the context ends first, the delivery gives up.
+func (p *Handler) scheduleRetry(ctx context.Context, queue *retryQueue, d *delivery, done func(ctx context.Context)) {
+	delay := retryDelay(d.dest)
+	p.logRetry(ctx, d.attempt, d.maxAttempts, d.dest, delay)
+
+	// The delivery does not hold a slot of the delivery pool while it waits
+	releaseSlot(ctx)
+
+	d.attempt++
+	queue.schedule(ctx, delay, func() {
+		if ctx.Err() != nil {
+			for _, hook := range p.hooks {
+				hook.OnDeadLetter(d.event)
+			}
+			p.completeDelivery(d)
+			done(ctx)
+			return
+		}
+
+		// The delivery was accepted already, so it is never dropped by the pool
+		queue.pool.Go(ctx, d.dest.Key(), true, func(ctx context.Context) {
+			p.runDelivery(ctx, d, done)
+		})
+	})
+}
+
+// completeDelivery logs a single summary of the delivery once all its attempts are done
+func (p *Handler) completeDelivery(d *delivery) {
+	// Repeated failures are counted by the suppressor and summarized later
+	if d.outcome == logger.OutcomeFailure && p.suppressor != nil && len(d.attempts) > 0 && d.event.Err != nil &&
+		!p.suppressor.Allow(d.dest.Key(), d.event.Err) {
+		return
+	}
+	completed := d.log
+	if generation := p.Generation(); generation > 0 {
+		completed = completed.WithField("config_generation", generation)
+	}
+	logger.LogDeliveryCompleted(completed, p.endpoint, d.dest.Key(), d.attempts, time.Since(d.startTime), d.outcome)
+}
+
+// captureHeaders returns the values of the listed headers found in a response, keyed by
+// their configured name, or nil when there are none
+func captureHeaders(names []string, header http.Header) map[string]string {
+	var captured map[string]string
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			if captured == nil {
+				captured = make(map[string]string, len(names))
+			}
+			captured[name] = value
+		}
+	}
+	return captured
+}
+
+// logFor returns the handler's logger with the ID of the webhook forwarded within the
+// context and the listener it was received on, when there are
+func (p *Handler) logFor(ctx context.Context) logrus.FieldLogger {
+	var log logrus.FieldLogger = p.log
+	if id := WebhookID(ctx); id != "" {
+		log = log.WithField("webhook_id", id)
+	}
+	if name := Listener(ctx); name != "" {
+		log = log.WithField("listener", name)
+	}
+	return log
+}
+
+// cacheable reports whether the responses of a destination are cached: only HTTP
+// destinations with a cache are
+func (p *Handler) cacheable(dest config.DestinationConfig) bool {
+	_, isSink := p.sinks[dest.Key()]
+	return dest.Cache != nil && !isSink
+}
+
+// cachedResponse returns the unexpired cached response of a destination
+func (p *Handler) cachedResponse(dest config.DestinationConfig) (cachedResponse, bool) {
+	if !p.cacheable(dest) {
+		return cachedResponse{}, false
+	}
+	return p.cache.get(dest.URL, time.Now())
+}
+
+// sendRequest sends a request to the destination and returns the status code, response body, response headers, duration, and error.
+// Signed destinations sign it with the given time.
+func (p *Handler) sendRequest(ctx context.Context, client *http.Client, dest config.DestinationConfig, body []byte, headers map[string]string, signedAt time.Time) (int, []byte, http.Header, time.Duration, error) {
+	// Bound the request by the destination's timeout, within the delivery's context
+	ctx, cancel := context.WithTimeout(ctx, dest.Timeout)
 	defer cancel() // Cancel the context to prevent resource leaks
 
-	req, err := http.NewRequestWithContext(ctx, dest.Method, dest.URL, bytes.NewReader(body))
+	req, err := newRequest(ctx, dest, body, headers, signedAt)
 	if err != nil {
-		lastErr := fmt.Errorf("failed to create request: %w", err)
-		p.log.WithFields(logrus.Fields{
+		p.logFor(ctx).WithFields(logrus.Fields{
 			"error":       err,
-			"destination": dest.URL,
+			"destination": dest.Key(),
 			"method":      dest.Method,
 		}).Error("Failed to create request")
-
-		// Record failure in metrics
-		p.metrics.RecordFailure(dest.URL, lastErr.Error(), isRetry)
-		return 0, nil, 0, lastErr
-	}
-
-	// Add headers
-	for k, v := range headers {
-		req.Header.Set(k, v)
+		return 0, nil, nil, 0, err
 	}
 
-	// Add custom headers from configuration
-	for k, v := range dest.Headers {
-		req.Header.Set(k, v)
-	}
+	// Count the requests reusing a connection of the destination's transport
+	pool := p.connPool(dest)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				pool.reused.Add(1)
+			}
+		},
+	}))
 
 	// Send request and measure time
 	startTime := time.Now()
@@ -182,51 +1140,163 @@ func (p *Handler) sendRequest(client *http.Client, dest config.DestinationConfig
 
 	if err != nil {
 		lastErr := fmt.Errorf("request failed: %w", err)
-		logger.LogWebhookError(p.log, dest.URL, err, 1, 1)
-
-		// Record failure in metrics
-		p.metrics.RecordFailure(dest.URL, lastErr.Error(), isRetry)
-		return 0, nil, duration, lastErr
+		p.logFor(ctx).WithFields(logrus.Fields{
+			"error":       err,
+			"destination": dest.Key(),
+		}).Debug("Webhook delivery attempt failed")
+		return 0, nil, nil, duration, lastErr
 	}
 
 	// Get status code
 	statusCode := resp.StatusCode
 
 	// Read and close response body
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := bufpool.ReadAll(resp.Body, resp.ContentLength)
 	resp.Body.Close()
 
 	if err != nil {
 		lastErr := fmt.Errorf("failed to read response body: %w", err)
-		logger.LogWebhookError(p.log, dest.URL, err, 1, 1)
+		p.logFor(ctx).WithFields(logrus.Fields{
+			"error":       err,
+			"destination": dest.Key(),
+		}).Debug("Failed to read destination response body")
+		return statusCode, nil, resp.Header, duration, lastErr
+	}
 
-		// Record failure in metrics
-		p.metrics.RecordFailure(dest.URL, lastErr.Error(), isRetry)
-		return statusCode, nil, duration, lastErr
+	return statusCode, respBody, resp.Header, duration, nil
+}
+
+// newRequest builds the request of a delivery to an HTTP destination: the webhook's
+// headers, the destination's headers, and the compression and signature it asks for
+func newRequest(ctx context.Context, dest config.DestinationConfig, body []byte, headers map[string]string, signedAt time.Time) (*http.Request, error) {
+	// Compress the body for destinations that accept it
+	reqBody := body
+	if dest.Compression == config.CompressionGzip {
+		compressed, err := gzipBody(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress body: %w", err)
+		}
+		reqBody = compressed
 	}
 
-	return statusCode, respBody, duration, nil
+	req, err := http.NewRequestWithContext(ctx, dest.Method, dest.URL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, logger.WithClass("invalid_request", fmt.Errorf("failed to create request: %w", err))
+	}
+
+	// Add headers, with the case of webhooks forwarded with their raw headers
+	for k, v := range headers {
+		setHeader(req.Header, k, v)
+	}
+
+	// Add custom headers from configuration
+	for k, v := range dest.Headers {
+		setHeader(req.Header, http.CanonicalHeaderKey(k), v)
+	}
+
+	if dest.Compression == config.CompressionGzip {
+		setHeader(req.Header, "Content-Encoding", "gzip")
+	}
+
+	// The signature covers the uncompressed body
+	if dest.Signing != nil {
+		setHeader(req.Header, http.CanonicalHeaderKey(dest.Signing.Header), signatureHeader(*dest.Signing, body, signedAt))
+	}
+
+	return req, nil
 }
 
-// shouldRetry determines if a retry should be attempted
-func (p *Handler) shouldRetry(attempt, maxAttempts int, dest config.DestinationConfig) bool {
+// gzipBody returns the gzip-compressed body
+func gzipBody(body []byte) ([]byte, error) {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	compressed := make([]byte, buf.Len())
+	copy(compressed, buf.Bytes())
+	return compressed, nil
+}
+
+// sendToSink sends the delivery of a webhook through a non-HTTP sink and returns the status code, duration, and error.
+// Sinks have no status code, so a successful send is reported as 200 OK.
+func (p *Handler) sendToSink(ctx context.Context, s sink.Sink, dest config.DestinationConfig, event *Event, body []byte, headers map[string]string) (int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, dest.Timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	err := s.Send(ctx, &sink.Message{
+		ID:          event.ID,
+		WebhookID:   event.WebhookID,
+		Endpoint:    event.Endpoint,
+		Destination: dest.Key(),
+		ReceivedAt:  event.ReceivedAt,
+		Body:        body,
+		Headers:     headers,
+	})
+	duration := time.Since(startTime)
+
+	if err != nil {
+		lastErr := fmt.Errorf("sink delivery failed: %w", err)
+		p.logFor(ctx).WithFields(logrus.Fields{
+			"error":       err,
+			"destination": dest.Key(),
+		}).Debug("Webhook delivery attempt failed")
+		return 0, duration, lastErr
+	}
+
+	return http.StatusOK, duration, nil
+}
+
+// shouldRetry determines if a retry should be attempted, waiting for the retry delay.
+// The wait ends early, without a retry, when the context is done.
+func (p *Handler) shouldRetry(ctx context.Context, attempt, maxAttempts int, dest config.DestinationConfig) bool {
 	if attempt >= maxAttempts {
 		return false
 	}
 
-	retryDelay := dest.RetryDelay
-	if retryDelay <= 0 {
-		retryDelay = 1 * time.Second
+	retryDelay := retryDelay(dest)
+	p.logRetry(ctx, attempt, maxAttempts, dest, retryDelay)
+
+	// The delivery does not hold a slot of the delivery pool while it waits
+	releaseSlot(ctx)
+
+	var wait <-chan time.Time
+	if p.after != nil {
+		wait = p.after(retryDelay)
+	} else {
+		timer := time.NewTimer(retryDelay)
+		defer timer.Stop()
+		wait = timer.C
 	}
+	select {
+	case <-wait:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
 
-	// Log retry attempt
-	p.log.WithFields(logrus.Fields{
-		"destination":  dest.URL,
+// logRetry logs the retry of a delivery after the delay
+func (p *Handler) logRetry(ctx context.Context, attempt, maxAttempts int, dest config.DestinationConfig, delay time.Duration) {
+	p.logFor(ctx).WithFields(logrus.Fields{
+		"destination":  dest.Key(),
 		"attempt":      attempt,
 		"max_attempts": maxAttempts,
-		"retry_delay":  retryDelay,
-	}).Info("Retrying webhook forwarding")
+		"retry_delay":  delay,
+	}).Debug("Retrying webhook forwarding")
+}
 
-	time.Sleep(retryDelay)
-	return true
+// retryDelay returns the delay before retrying a delivery to the destination
+func retryDelay(dest config.DestinationConfig) time.Duration {
+	if dest.RetryDelay <= 0 {
+		return 1 * time.Second
+	}
+	return dest.RetryDelay
 }
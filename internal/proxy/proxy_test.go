@@ -1,16 +1,31 @@
 package proxy
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/logger"
+	"github.com/flemzord/webhook-proxy/internal/sink"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestProxyHandler_ForwardWebhook(t *testing.T) {
@@ -65,7 +80,7 @@ func TestProxyHandler_ForwardWebhook(t *testing.T) {
 	// Forward webhook
 	body := []byte(`{"event":"test"}`)
 	headers := map[string]string{"User-Agent": "test-agent"}
-	handler.ForwardWebhook(body, headers)
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", body, headers))
 
 	// Add a small delay to allow goroutines to complete
 	time.Sleep(100 * time.Millisecond)
@@ -79,6 +94,56 @@ func TestProxyHandler_ForwardWebhook(t *testing.T) {
 	assert.Equal(t, int64(1), metrics["failed_requests"])
 }
 
+func TestProxyHandler_DeliverWebhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: server.URL + "/a", Method: "POST", Timeout: time.Second},
+		{URL: server.URL + "/b", Method: "POST", Timeout: time.Second},
+	}, log)
+
+	// The deliveries are done when DeliverWebhook returns, without waiting
+	handler.DeliverWebhook(context.Background(), webhook.New("/webhook", []byte(`{"event":"test"}`), map[string]string{}))
+
+	metrics := handler.GetMetrics()
+	assert.Equal(t, int64(2), metrics["total_requests"])
+	assert.Equal(t, int64(2), metrics["successful_requests"])
+}
+
+func TestProxyHandler_Drain(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: server.URL, Method: "POST", Timeout: time.Second},
+	}, log)
+
+	// Nothing in flight
+	assert.NoError(t, handler.Drain(context.Background()))
+
+	// The delivery blocks the drain until it is done
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte(`{"event":"test"}`), map[string]string{}))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, handler.Drain(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, handler.Drain(context.Background()))
+	assert.Equal(t, int64(1), handler.GetMetrics()["successful_requests"])
+}
+
 func TestMetrics(t *testing.T) {
 	// Create metrics
 	metrics := NewMetrics()
@@ -87,10 +152,10 @@ func TestMetrics(t *testing.T) {
 	metrics.RecordRequest("https://example.com/webhook1")
 	metrics.RecordSuccess("https://example.com/webhook1", 200, 100*time.Millisecond)
 	metrics.RecordRequest("https://example.com/webhook1")
-	metrics.RecordFailure("https://example.com/webhook1", "connection timeout", false)
+	metrics.RecordFailure("https://example.com/webhook1", "", context.DeadlineExceeded, false)
 
 	// Record a retry (which is a failure with retry=true)
-	metrics.RecordFailure("https://example.com/webhook1", "connection timeout", true)
+	metrics.RecordFailure("https://example.com/webhook1", "", context.DeadlineExceeded, true)
 
 	metrics.RecordRequest("https://example.com/webhook2")
 	metrics.RecordSuccess("https://example.com/webhook2", 201, 150*time.Millisecond)
@@ -121,7 +186,7 @@ func TestMetrics(t *testing.T) {
 	assert.Equal(t, int64(1), webhook1["successful_requests"])
 	assert.Equal(t, int64(2), webhook1["failed_requests"])
 	assert.Equal(t, int64(1), webhook1["retries"])
-	assert.Equal(t, "connection timeout", webhook1["last_error"])
+	assert.Equal(t, "context deadline exceeded", webhook1["last_error"])
 
 	webhook2Raw, ok := destinations["https://example.com/webhook2"]
 	assert.True(t, ok, "webhook2 key should exist in destinations")
@@ -148,6 +213,83 @@ func TestMetrics(t *testing.T) {
 	metrics.Reset()
 }
 
+// TestMetricsConcurrentRecording tests recording metrics from many goroutines
+func TestMetricsConcurrentRecording(t *testing.T) {
+	metrics := NewMetrics()
+	destinations := []string{"https://example.com/webhook1", "https://example.com/webhook2"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dest := destinations[i%len(destinations)]
+			for j := 0; j < 100; j++ {
+				metrics.RecordRequest(dest)
+				if j%2 == 0 {
+					metrics.RecordSuccess(dest, 200, time.Millisecond)
+				} else {
+					metrics.RecordFailure(dest, "", checkResponse(config.DestinationConfig{}, http.StatusServiceUnavailable, nil), false)
+				}
+				_ = metrics.GetMetrics()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result := metrics.GetMetrics()
+	assert.Equal(t, int64(5000), result["total_requests"])
+	assert.Equal(t, int64(2500), result["successful_requests"])
+	assert.Equal(t, int64(2500), result["failed_requests"])
+	assert.Equal(t, map[int]int64{200: 2500}, result["status_codes"])
+
+	webhook1 := result["destinations"].(map[string]interface{})["https://example.com/webhook1"].(map[string]interface{})
+	assert.Equal(t, int64(2500), webhook1["total_requests"])
+	assert.Equal(t, map[string]int64{"http_503": 1250}, webhook1["error_classes"])
+}
+
+// TestMetricsSnapshotIsolation tests that snapshots are not affected by later recording
+func TestMetricsSnapshotIsolation(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.RecordRequest("https://example.com/webhook")
+	metrics.RecordFailure("https://example.com/webhook", "webhook-1", context.DeadlineExceeded, false)
+
+	snapshot := metrics.GetMetrics()
+
+	metrics.RecordRequest("https://example.com/webhook")
+	metrics.RecordFailure("https://example.com/webhook", "", syscall.ECONNREFUSED, false)
+
+	dest := snapshot["destinations"].(map[string]interface{})["https://example.com/webhook"].(map[string]interface{})
+	assert.Equal(t, int64(1), snapshot["total_requests"])
+	assert.Equal(t, "context deadline exceeded", dest["last_error"])
+	assert.Equal(t, "webhook-1", dest["last_error_webhook_id"])
+	assert.Equal(t, map[string]int64{"timeout": 1}, dest["error_classes"])
+}
+
+func BenchmarkMetricsSnapshot(b *testing.B) {
+	metrics := NewMetrics()
+	for i := 0; i < 10; i++ {
+		dest := fmt.Sprintf("https://example.com/webhook%d", i)
+		metrics.RecordRequest(dest)
+		metrics.RecordSuccess(dest, 200, time.Millisecond)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = metrics.GetMetrics()
+	}
+}
+
+func BenchmarkMetricsRecord(b *testing.B) {
+	metrics := NewMetrics()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			metrics.RecordRequest("https://example.com/webhook")
+			metrics.RecordSuccess("https://example.com/webhook", 200, time.Millisecond)
+		}
+	})
+}
+
 // TestResetMetrics tests the ResetMetrics function
 func TestResetMetrics(t *testing.T) {
 	// Create logger
@@ -208,11 +350,11 @@ func TestShouldRetry(t *testing.T) {
 
 	// Test case 1: Should retry (attempt < maxAttempts)
 	dest := destinations[0]
-	result := handler.shouldRetry(1, 4, dest)
+	result := handler.shouldRetry(context.Background(), 1, 4, dest)
 	assert.True(t, result, "Should retry when attempt < maxAttempts")
 
 	// Test case 2: Should not retry (attempt >= maxAttempts)
-	result = handler.shouldRetry(4, 4, dest)
+	result = handler.shouldRetry(context.Background(), 4, 4, dest)
 	assert.False(t, result, "Should not retry when attempt >= maxAttempts")
 
 	// Test case 3: Should retry with default retry delay (RetryDelay <= 0)
@@ -224,7 +366,7 @@ func TestShouldRetry(t *testing.T) {
 		Retries:    3,
 		RetryDelay: 0 * time.Millisecond,
 	}
-	result = handler.shouldRetry(1, 4, destWithZeroDelay)
+	result = handler.shouldRetry(context.Background(), 1, 4, destWithZeroDelay)
 	assert.True(t, result, "Should retry with default delay when RetryDelay <= 0")
 }
 
@@ -264,7 +406,7 @@ func TestSendRequest(t *testing.T) {
 	client := &http.Client{Timeout: 5 * time.Second}
 	body := []byte(`{"event":"test"}`)
 	headers := map[string]string{"User-Agent": "test-agent"}
-	statusCode, respBody, duration, err := handler.sendRequest(client, dest1, body, headers, false)
+	statusCode, respBody, _, duration, err := handler.sendRequest(context.Background(), client, dest1, body, headers, time.Now())
 
 	// Verify response
 	assert.NoError(t, err)
@@ -291,7 +433,7 @@ func TestSendRequest(t *testing.T) {
 	}
 
 	// Send request
-	statusCode, respBody, duration, err = handler.sendRequest(client, dest2, body, headers, false)
+	statusCode, respBody, _, duration, err = handler.sendRequest(context.Background(), client, dest2, body, headers, time.Now())
 
 	// Verify response
 	assert.NoError(t, err)
@@ -308,7 +450,7 @@ func TestSendRequest(t *testing.T) {
 	}
 
 	// Send request
-	statusCode, respBody, duration, err = handler.sendRequest(client, destInvalid, body, headers, true)
+	statusCode, respBody, _, duration, err = handler.sendRequest(context.Background(), client, destInvalid, body, headers, time.Now())
 
 	// Verify response
 	assert.Error(t, err)
@@ -326,7 +468,7 @@ func TestSendRequest(t *testing.T) {
 	}
 
 	// Send request
-	statusCode, respBody, _, err = handler.sendRequest(client, destInvalidMethod, body, headers, false)
+	statusCode, respBody, _, _, err = handler.sendRequest(context.Background(), client, destInvalidMethod, body, headers, time.Now())
 
 	// Verify response
 	assert.Error(t, err)
@@ -392,7 +534,7 @@ func TestSendRequestReadBodyError(t *testing.T) {
 	// Send request
 	body := []byte(`{"event":"test"}`)
 	headers := map[string]string{"User-Agent": "test-agent"}
-	statusCode, respBody, duration, err := handler.sendRequest(client, dest, body, headers, false)
+	statusCode, respBody, _, duration, err := handler.sendRequest(context.Background(), client, dest, body, headers, time.Now())
 
 	// Verify response
 	assert.Error(t, err)
@@ -442,7 +584,7 @@ func TestForwardToDestination(t *testing.T) {
 	// Forward webhook
 	body := []byte(`{"event":"test"}`)
 	headers := map[string]string{"User-Agent": "test-agent"}
-	handler.forwardToDestination(dest1, body, headers)
+	handler.forwardToDestination(context.Background(), dest1, body, headers, time.Now())
 
 	// Verify metrics
 	metrics := handler.GetMetrics()
@@ -474,7 +616,7 @@ func TestForwardToDestination(t *testing.T) {
 	handler.ResetMetrics()
 
 	// Forward webhook
-	handler.forwardToDestination(dest2, body, headers)
+	handler.forwardToDestination(context.Background(), dest2, body, headers, time.Now())
 
 	// Verify metrics
 	metrics = handler.GetMetrics()
@@ -496,7 +638,7 @@ func TestForwardToDestination(t *testing.T) {
 	handler.ResetMetrics()
 
 	// Forward webhook
-	handler.forwardToDestination(dest3, body, headers)
+	handler.forwardToDestination(context.Background(), dest3, body, headers, time.Now())
 
 	// Verify metrics
 	metrics = handler.GetMetrics()
@@ -528,7 +670,7 @@ func TestForwardToDestinationWithRequestError(t *testing.T) {
 	// Forward webhook
 	body := []byte(`{"event":"test"}`)
 	headers := map[string]string{"User-Agent": "test-agent"}
-	handler.forwardToDestination(dest, body, headers)
+	handler.forwardToDestination(context.Background(), dest, body, headers, time.Now())
 
 	// Verify metrics
 	metrics := handler.GetMetrics()
@@ -537,3 +679,781 @@ func TestForwardToDestinationWithRequestError(t *testing.T) {
 	assert.Equal(t, int64(2), metrics["failed_requests"]) // Initial attempt + 1 retry
 	assert.Equal(t, int64(1), metrics["retries"])
 }
+
+// TestForwardToDestinationMaxDeliveryDuration tests that retries stop at the delivery deadline
+func TestForwardToDestinationMaxDeliveryDuration(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:                 server.URL,
+		Method:              "POST",
+		Timeout:             time.Second,
+		Retries:             10,
+		RetryDelay:          100 * time.Millisecond,
+		MaxDeliveryDuration: 250 * time.Millisecond,
+	}
+
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+
+	start := time.Now()
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), map[string]string{}, time.Now())
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, 3, calls)
+	mu.Unlock()
+	assert.Equal(t, int64(3), handler.GetMetrics()["failed_requests"])
+}
+
+// TestForwardToDestinationMaxDeliveryDurationCapsTimeout tests that an attempt is cut at the delivery deadline
+func TestForwardToDestinationMaxDeliveryDurationCapsTimeout(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	dest := config.DestinationConfig{
+		URL:                 server.URL,
+		Method:              "POST",
+		Timeout:             5 * time.Second,
+		MaxDeliveryDuration: 100 * time.Millisecond,
+	}
+
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+
+	start := time.Now()
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), map[string]string{}, time.Now())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int64(1), handler.GetMetrics()["failed_requests"])
+}
+
+// TestForwardToDestinationRetryPolicy tests that error classes excluded by the retry policy are not retried
+func TestForwardToDestinationRetryPolicy(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	// Closed listener, so that connections are refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	refusedURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	rejected := config.DestinationConfig{
+		URL:         server.URL,
+		Method:      "POST",
+		Timeout:     time.Second,
+		Retries:     3,
+		RetryDelay:  10 * time.Millisecond,
+		RetryPolicy: map[string]bool{"http_400": false},
+	}
+	refused := config.DestinationConfig{
+		URL:         refusedURL,
+		Method:      "POST",
+		Timeout:     time.Second,
+		Retries:     2,
+		RetryDelay:  10 * time.Millisecond,
+		RetryPolicy: map[string]bool{"dns": false},
+	}
+
+	handler := NewProxyHandler([]config.DestinationConfig{rejected, refused}, logger)
+	handler.forwardToDestination(context.Background(), rejected, []byte(`{"event":"test"}`), map[string]string{}, time.Now())
+	handler.forwardToDestination(context.Background(), refused, []byte(`{"event":"test"}`), map[string]string{}, time.Now())
+
+	mu.Lock()
+	assert.Equal(t, 1, calls)
+	mu.Unlock()
+
+	destinations := handler.GetMetrics()["destinations"].(map[string]interface{})
+	rejectedMetrics := destinations[rejected.Key()].(map[string]interface{})
+	assert.Equal(t, map[string]int64{"http_400": 1}, rejectedMetrics["error_classes"])
+
+	// Classes missing from the policy are retried
+	refusedMetrics := destinations[refused.Key()].(map[string]interface{})
+	assert.Equal(t, map[string]int64{"connection_refused": 3}, refusedMetrics["error_classes"])
+}
+
+// TestSendRequestCompression tests that bodies are gzip-compressed for destinations that enable it
+func TestSendRequestCompression(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+		reader, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"event":"test"}`, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:         server.URL,
+		Method:      "POST",
+		Timeout:     time.Second,
+		Compression: config.CompressionGzip,
+	}
+
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+	statusCode, _, _, _, err := handler.sendRequest(context.Background(), &http.Client{}, dest, []byte(`{"event":"test"}`), map[string]string{}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+}
+
+// mockSink is a sink that records deliveries and returns a configurable error
+type mockSink struct {
+	bodies [][]byte
+	ids    []string
+	err    error
+}
+
+func (m *mockSink) Send(_ context.Context, msg *sink.Message) error {
+	m.bodies = append(m.bodies, msg.Body)
+	m.ids = append(m.ids, msg.ID)
+	return m.err
+}
+
+// TestForwardToSink tests forwarding to a non-HTTP destination
+func TestForwardToSink(t *testing.T) {
+	// Create logger
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{
+		Type:       config.DestinationTypeWebSocket,
+		WebSocket:  &config.WebSocketConfig{Path: "/live"},
+		Timeout:    5 * time.Second,
+		Retries:    1,
+		RetryDelay: 10 * time.Millisecond,
+	}
+
+	// Create proxy handler
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+	assert.Len(t, handler.Sinks(), 1)
+
+	// Replace the websocket hub with a mock sink
+	mock := &mockSink{}
+	handler.sinks[dest.Key()] = mock
+
+	// Test case 1: Successful delivery
+	body := []byte(`{"event":"test"}`)
+	handler.forwardToDestination(context.Background(), dest, body, nil, time.Now())
+
+	assert.Len(t, mock.bodies, 1)
+	metrics := handler.GetMetrics()
+	assert.Equal(t, int64(1), metrics["successful_requests"])
+	destinations, ok := metrics["destinations"].(map[string]interface{})
+	assert.True(t, ok, "destinations should be a map[string]interface{}")
+	assert.Contains(t, destinations, "websocket:/live")
+
+	// Test case 2: Failed delivery with retries
+	handler.ResetMetrics()
+	mock.err = errors.New("sink unavailable")
+	handler.forwardToDestination(context.Background(), dest, body, nil, time.Now())
+
+	metrics = handler.GetMetrics()
+	assert.Equal(t, int64(0), metrics["successful_requests"])
+	assert.Equal(t, int64(2), metrics["failed_requests"]) // Initial attempt + 1 retry
+	assert.Equal(t, int64(1), metrics["retries"])
+
+	// Retries are sent with the ID of their delivery
+	require.Len(t, mock.ids, 3)
+	assert.NotEmpty(t, mock.ids[1])
+	assert.Equal(t, mock.ids[1], mock.ids[2])
+	assert.NotEqual(t, mock.ids[0], mock.ids[1])
+}
+
+// closingSink is a sink that records whether it was closed
+type closingSink struct {
+	mockSink
+	closed bool
+	err    error
+}
+
+func (c *closingSink) Close() error {
+	c.closed = true
+	return c.err
+}
+
+// TestHandlerClose tests that closing a handler closes its sinks
+func TestHandlerClose(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook"}, logger)
+	assert.Equal(t, "/webhook", handler.endpoint)
+
+	s := &closingSink{}
+	handler.sinks["s3://archive"] = s
+	handler.sinks["websocket:/live"] = &mockSink{}
+
+	assert.NoError(t, handler.Close())
+	assert.True(t, s.closed)
+
+	// Close errors are reported
+	s.err = errors.New("flush failed")
+	err := handler.Close()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "s3://archive")
+}
+
+// TestForwardToDestinationDeliveryLog tests that a single summary entry is logged per delivery
+func TestForwardToDestinationDeliveryLog(t *testing.T) {
+	// Fail the first attempt, then succeed
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:        server.URL,
+		Method:     "POST",
+		Timeout:    5 * time.Second,
+		Retries:    2,
+		RetryDelay: 10 * time.Millisecond,
+	}
+
+	// Capture info-level logs
+	log := logrus.New()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.InfoLevel)
+
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	// Only the summary entry is logged at info level
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 1)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "Webhook delivery completed", entry["msg"])
+	assert.Equal(t, "/webhook", entry["endpoint"])
+	assert.Equal(t, server.URL, entry["destination"])
+	assert.Equal(t, float64(2), entry["attempts"])
+	assert.Equal(t, []interface{}{float64(503), float64(200)}, entry["status_codes"])
+	assert.Equal(t, "success", entry["outcome"])
+}
+
+func TestGeneration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second}
+
+	log := logrus.New()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+
+	// Untracked handlers report no generation
+	_, ok := handler.GetMetrics()["config_generation"]
+	assert.False(t, ok)
+
+	handler.SetGeneration(3)
+	var generations []int64
+	handler.AddHook(&generationHook{generations: &generations})
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	// The generation is reported to the hooks, in the delivery log and in the metrics
+	assert.Equal(t, []int64{3, 3}, generations)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, float64(3), entry["config_generation"])
+
+	assert.Equal(t, int64(3), handler.GetMetrics()["config_generation"])
+}
+
+// generationHook records the generation of the events before and after each attempt
+type generationHook struct {
+	NopHook
+	generations *[]int64
+}
+
+func (h *generationHook) BeforeForward(event *Event) {
+	*h.generations = append(*h.generations, event.Generation)
+}
+
+func (h *generationHook) AfterForward(event *Event) {
+	*h.generations = append(*h.generations, event.Generation)
+}
+
+func TestForwardToDestinationErrorSuppression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:     server.URL,
+		Method:  "POST",
+		Timeout: 5 * time.Second,
+	}
+
+	log := logrus.New()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.InfoLevel)
+
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.SetErrorSuppressor(logger.NewErrorSuppressor(log, time.Hour))
+
+	for i := 0; i < 3; i++ {
+		handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+	}
+
+	// Only the first failure is logged
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "Webhook delivery failed")
+
+	// Every failure is still counted in metrics
+	destMetrics := handler.GetMetrics()["destinations"].(map[string]interface{})[server.URL].(map[string]interface{})
+	assert.Equal(t, int64(3), destMetrics["failed_requests"])
+	assert.Equal(t, map[string]int64{"http_502": 3}, destMetrics["error_classes"])
+}
+
+func TestPrewarm(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	connections := 0
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second}
+	sinkDest := config.DestinationConfig{Type: config.DestinationTypeWebSocket, WebSocket: &config.WebSocketConfig{Path: "/live"}}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest, sinkDest}}, log)
+
+	// Only HTTP destinations are prewarmed
+	handler.Prewarm(context.Background())
+
+	// The delivery reuses the prewarmed connection
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), nil, time.Now())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{http.MethodHead, http.MethodPost}, methods)
+	assert.Equal(t, 1, connections)
+}
+
+func TestProbe(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	methods := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods <- r.Method
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	closed.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: server.URL, Method: "POST", Timeout: time.Second},
+		{URL: closed.URL, Method: "POST", Timeout: time.Second},
+	}, logger)
+
+	results := handler.Probe(context.Background(), http.MethodOptions)
+
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.Equal(t, server.URL, results[0].Destination.URL)
+	assert.NoError(t, results[0].Err, "any response status means the destination is reachable")
+	assert.Equal(t, closed.URL, results[1].Destination.URL)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, http.MethodOptions, <-methods)
+}
+
+// TestForwardToDestinationMaxBodySize tests that oversized bodies are dead-lettered or truncated
+func TestForwardToDestinationMaxBodySize(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var mu sync.Mutex
+	var received [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	body := []byte(`{"event":"too large"}`)
+
+	t.Run("dead letter", func(t *testing.T) {
+		mu.Lock()
+		received = nil
+		mu.Unlock()
+
+		dest := config.DestinationConfig{
+			URL:         server.URL,
+			Method:      "POST",
+			Timeout:     time.Second,
+			Retries:     3,
+			RetryDelay:  10 * time.Millisecond,
+			MaxBodySize: 8,
+			OnOversize:  config.OversizeDeadLetter,
+		}
+		handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+		handler.forwardToDestination(context.Background(), dest, body, map[string]string{}, time.Now())
+
+		mu.Lock()
+		assert.Empty(t, received)
+		mu.Unlock()
+
+		metrics := handler.GetMetrics()
+		assert.Equal(t, int64(1), metrics["failed_requests"])
+		assert.Equal(t, int64(0), metrics["retries"])
+		destMetrics := metrics["destinations"].(map[string]interface{})[server.URL].(map[string]interface{})
+		assert.Equal(t, int64(1), destMetrics["error_classes"].(map[string]int64)["body_too_large"])
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		mu.Lock()
+		received = nil
+		mu.Unlock()
+
+		dest := config.DestinationConfig{
+			URL:         server.URL,
+			Method:      "POST",
+			Timeout:     time.Second,
+			MaxBodySize: 8,
+			OnOversize:  config.OversizeTruncate,
+		}
+		handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+		handler.forwardToDestination(context.Background(), dest, body, map[string]string{}, time.Now())
+
+		mu.Lock()
+		assert.Equal(t, [][]byte{body[:8]}, received)
+		mu.Unlock()
+		assert.Equal(t, int64(1), handler.GetMetrics()["successful_requests"])
+	})
+}
+
+// TestMetricsBodySize tests the outbound body size distribution
+func TestMetricsBodySize(t *testing.T) {
+	metrics := NewMetrics()
+	destination := "https://example.com/webhook"
+
+	for _, size := range []int{100, 1024, 5000, 2 << 20, 20 << 20} {
+		metrics.RecordRequest(destination)
+		metrics.RecordBodySize(destination, size)
+	}
+
+	result := metrics.GetMetrics()
+	bodySize := result["body_size"].(map[string]interface{})
+	assert.Equal(t, int64(5), bodySize["count"])
+	assert.Equal(t, int64(100+1024+5000+(2<<20)+(20<<20)), bodySize["total_bytes"])
+	assert.Equal(t, int64(20<<20), bodySize["max_bytes"])
+	assert.Equal(t, map[string]int64{
+		"1KB":   2,
+		"10KB":  1,
+		"100KB": 0,
+		"1MB":   0,
+		"10MB":  1,
+		"+Inf":  1,
+	}, bodySize["buckets"])
+
+	dest := result["destinations"].(map[string]interface{})[destination].(map[string]interface{})
+	assert.Equal(t, bodySize, dest["body_size"])
+
+	metrics.Reset()
+	assert.Equal(t, int64(0), metrics.GetMetrics()["body_size"].(map[string]interface{})["count"])
+}
+
+func TestCaptureResponseHeaders(t *testing.T) {
+	var remaining atomic.Int64
+	remaining.Store(100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining.Add(-1), 10))
+		w.Header().Set("X-Request-Cost", "1")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: time.Second, CaptureHeaders: []string{"x-ratelimit-remaining", "X-RateLimit-Reset"}}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+
+	var captured []map[string]string
+	handler.AddHook(&afterForwardHook{onAfterForward: func(event *Event) {
+		captured = append(captured, event.ResponseHeaders)
+	}})
+
+	// Only the listed headers the destination answered with are captured, by their configured name
+	handler.forwardToDestination(context.Background(), dest, []byte(`{}`), nil, time.Now())
+	handler.forwardToDestination(context.Background(), dest, []byte(`{}`), nil, time.Now())
+	assert.Equal(t, []map[string]string{{"x-ratelimit-remaining": "99"}, {"x-ratelimit-remaining": "98"}}, captured)
+
+	// The metrics keep the last value of each header
+	metrics := handler.GetMetrics()["destinations"].(map[string]interface{})[dest.Key()].(map[string]interface{})
+	headers := metrics["captured_headers"].(map[string]capturedHeader)
+	assert.Len(t, headers, 1)
+	assert.Equal(t, "98", headers["x-ratelimit-remaining"].Value)
+	assert.WithinDuration(t, time.Now(), headers["x-ratelimit-remaining"].Time, time.Second)
+}
+
+func TestMetricsLabels(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	labeled := config.DestinationConfig{URL: "https://a.example.com", Labels: map[string]string{"team": "payments", "tier": "1"}}
+	plain := config.DestinationConfig{URL: "https://b.example.com"}
+	handler := NewEndpointHandler(config.EndpointConfig{
+		Path:         "/webhook",
+		Labels:       map[string]string{"team": "payments"},
+		Destinations: []config.DestinationConfig{labeled, plain},
+	}, log)
+	handler.metrics.RecordRequest(labeled.Key())
+	handler.metrics.RecordRequest(plain.Key())
+
+	metrics := handler.GetMetrics()
+	assert.Equal(t, map[string]string{"team": "payments"}, metrics["labels"])
+	destinations := metrics["destinations"].(map[string]interface{})
+	assert.Equal(t, labeled.Labels, destinations[labeled.Key()].(map[string]interface{})["labels"])
+	assert.NotContains(t, destinations[plain.Key()].(map[string]interface{}), "labels")
+}
+
+func TestHealth(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	healthy := config.DestinationConfig{URL: "http://localhost:1/a", Method: "POST"}
+	guarded := config.DestinationConfig{
+		URL:       "http://localhost:1/b",
+		Method:    "POST",
+		DNSOutage: &config.DNSOutageConfig{Threshold: 1, InitialBackoff: time.Minute, MaxBackoff: time.Minute},
+	}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{healthy, guarded}}, log)
+
+	health := handler.Health()
+	assert.Equal(t, map[string]interface{}{"status": "ok"}, health[healthy.Key()])
+	assert.Equal(t, map[string]interface{}{"status": "ok"}, health[guarded.Key()])
+
+	// A paused destination reports the deliveries waiting for it
+	guard := handler.dnsGuards[guarded.Key()]
+	guard.mu.Lock()
+	guard.paused, guard.queued = true, 2
+	guard.mu.Unlock()
+
+	health = handler.Health()
+	assert.Equal(t, map[string]interface{}{"status": "ok"}, health[healthy.Key()])
+	assert.Equal(t, map[string]interface{}{"status": "paused", "reason": "dns_outage", "queued": 2}, health[guarded.Key()])
+}
+
+func TestRedrive(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.Header.Get("X-Test") + " " + string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: server.URL, Method: "POST", Timeout: time.Second},
+	}, log)
+
+	assert.False(t, handler.Redrive(context.Background(), "https://removed.example.com", []byte(`{}`), nil))
+	assert.True(t, handler.Redrive(context.Background(), server.URL, []byte(`{"event":"test"}`), map[string]string{"X-Test": "1"}))
+
+	select {
+	case request := <-received:
+		assert.Equal(t, `1 {"event":"test"}`, request)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be delivered again")
+	}
+}
+
+func TestReplayWebhook(t *testing.T) {
+	received := make(chan string, 2)
+	newDestination := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			received <- name
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	first, second := newDestination("first"), newDestination("second")
+	defer first.Close()
+	defer second.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: first.URL, Method: "POST", Timeout: time.Second},
+		{URL: second.URL, Method: "POST", Timeout: time.Second, Filters: []config.FilterConfig{{Header: "X-Event", Equals: "paid"}}},
+	}, log)
+	delivery := webhook.New("/webhook", []byte(`{}`), map[string]string{"X-Event": "created"})
+
+	assert.False(t, handler.ReplayWebhook(context.Background(), delivery, "https://removed.example.com"))
+
+	// A replay to one destination bypasses its filters
+	assert.True(t, handler.ReplayWebhook(context.Background(), delivery, second.URL))
+	select {
+	case name := <-received:
+		assert.Equal(t, "second", name)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be replayed")
+	}
+
+	// A replay to every destination applies them
+	assert.True(t, handler.ReplayWebhook(context.Background(), delivery, ""))
+	select {
+	case name := <-received:
+		assert.Equal(t, "first", name)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be replayed")
+	}
+	select {
+	case name := <-received:
+		t.Fatalf("Expected the filters to skip %s", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestProxyHandler_Matches(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	handler := NewProxyHandler([]config.DestinationConfig{
+		{URL: "https://payments.example.com", Method: "POST", Filters: []config.FilterConfig{{Header: "X-Event", Equals: "paid"}}},
+		{URL: "https://releases.example.com", Method: "POST", Events: &config.EventsConfig{Allow: []string{"release"}}},
+	}, log)
+
+	assert.True(t, handler.Matches(webhook.New("/webhook", []byte(`{}`), map[string]string{"X-Event": "paid"})))
+	release := webhook.New("/webhook", []byte(`{}`), nil)
+	release.Metadata = map[string]string{config.ProviderFieldEvent: "release"}
+	assert.True(t, handler.Matches(release))
+
+	// A webhook left out by every destination is counted once reported
+	unmatched := webhook.New("/webhook", []byte(`{}`), map[string]string{"X-Event": "created"})
+	assert.False(t, handler.Matches(unmatched))
+	handler.RecordUnmatched()
+	assert.Equal(t, int64(1), handler.GetMetrics()["unmatched_requests"])
+
+	// Without filters, every webhook is forwarded
+	assert.True(t, NewProxyHandler([]config.DestinationConfig{{URL: "https://example.com", Method: "POST"}}, log).Matches(unmatched))
+}
+
+func TestWebhookIDPropagation(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.DebugLevel)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 2, RetryDelay: 10 * time.Millisecond}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	var webhookIDs []string
+	handler.AddHook(&afterForwardHook{onAfterForward: func(event *Event) { webhookIDs = append(webhookIDs, event.WebhookID) }})
+
+	delivery := webhook.New("/webhook", []byte(`{}`), nil)
+	handler.DeliverWebhook(context.Background(), delivery)
+
+	// Each attempt belongs to the webhook
+	assert.Equal(t, []string{delivery.ID, delivery.ID}, webhookIDs)
+
+	// Every log line of the delivery, retries included, carries the IDs of the webhook and delivery
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Greater(t, len(lines), 1)
+	var deliveryID interface{}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, delivery.ID, entry["webhook_id"], entry["msg"])
+		if entry["msg"] == "Webhook delivery completed" {
+			deliveryID = entry["delivery_id"]
+		}
+	}
+	assert.NotEmpty(t, deliveryID)
+
+	// The last error of the destination points to the webhook
+	metrics := handler.GetMetrics()["destinations"].(map[string]interface{})[server.URL].(map[string]interface{})
+	assert.Equal(t, delivery.ID, metrics["last_error_webhook_id"])
+}
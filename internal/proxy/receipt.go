@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// receiptTimeout bounds the time spent sending a receipt
+const receiptTimeout = 5 * time.Second
+
+// Receipt reports the final outcome of a delivery to a destination
+type Receipt struct {
+	DeliveryID  string    `json:"delivery_id"`
+	Endpoint    string    `json:"endpoint"`
+	Destination string    `json:"destination"`
+	Outcome     string    `json:"outcome"`
+	Attempts    int       `json:"attempts"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// receiptHook posts a receipt to a callback URL when a delivery succeeds or dead-letters.
+// Receipts are sent once, without retries; failures are logged.
+type receiptHook struct {
+	NopHook
+	url    string
+	client *http.Client
+	log    *logrus.Logger
+}
+
+// newReceiptHook creates a hook posting receipts to the callback URL
+func newReceiptHook(url string, log *logrus.Logger) *receiptHook {
+	return &receiptHook{url: url, client: &http.Client{Timeout: receiptTimeout}, log: log}
+}
+
+// AfterForward sends the receipt of a successful delivery
+func (h *receiptHook) AfterForward(event *Event) {
+	if event.Err == nil {
+		h.send(event, logger.OutcomeSuccess)
+	}
+}
+
+// OnDeadLetter sends the receipt of a failed delivery
+func (h *receiptHook) OnDeadLetter(event *Event) {
+	h.send(event, logger.OutcomeFailure)
+}
+
+// send posts the receipt of a delivery, logging failures
+func (h *receiptHook) send(event *Event, outcome string) {
+	receipt := Receipt{
+		DeliveryID:  event.ID,
+		Endpoint:    event.Endpoint,
+		Destination: event.Destination.Key(),
+		Outcome:     outcome,
+		Attempts:    event.Attempt,
+		StatusCode:  event.StatusCode,
+		CompletedAt: time.Now().UTC(),
+	}
+	if event.Err != nil {
+		receipt.Error = event.Err.Error()
+	}
+
+	if err := h.post(receipt); err != nil {
+		h.log.WithFields(logrus.Fields{
+			"error":        err,
+			"webhook_id":   event.WebhookID,
+			"delivery_id":  event.ID,
+			"callback_url": h.url,
+		}).Warn("Failed to send delivery receipt")
+	}
+}
+
+// post sends a receipt to the callback URL, expecting a 2xx response
+func (h *receiptHook) post(receipt Receipt) error {
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("failed to encode receipt: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), receiptTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("received non-2xx status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReceiptServer returns a callback server decoding the receipts it receives
+func newReceiptServer(t *testing.T) (*httptest.Server, chan Receipt) {
+	receipts := make(chan Receipt, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var receipt Receipt
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&receipt))
+		receipts <- receipt
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return server, receipts
+}
+
+func TestReceiptOnSuccess(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	callback, receipts := newReceiptServer(t)
+	defer callback.Close()
+
+	attempts := 0
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	dest := config.DestinationConfig{URL: destination.URL, Method: "POST", Timeout: time.Second, Retries: 1, RetryDelay: 10 * time.Millisecond}
+	handler := NewEndpointHandler(config.EndpointConfig{
+		Path:         "/webhook",
+		Destinations: []config.DestinationConfig{dest},
+		CallbackURL:  callback.URL,
+	}, log)
+
+	handler.deliver(context.Background(), "delivery-1", dest, []byte(`{}`), nil, 1, time.Time{})
+
+	// Only the final outcome is reported
+	require.Len(t, receipts, 1)
+	receipt := <-receipts
+	assert.Equal(t, "delivery-1", receipt.DeliveryID)
+	assert.Equal(t, "/webhook", receipt.Endpoint)
+	assert.Equal(t, destination.URL, receipt.Destination)
+	assert.Equal(t, logger.OutcomeSuccess, receipt.Outcome)
+	assert.Equal(t, 2, receipt.Attempts)
+	assert.Equal(t, http.StatusOK, receipt.StatusCode)
+	assert.Empty(t, receipt.Error)
+	assert.False(t, receipt.CompletedAt.IsZero())
+}
+
+func TestReceiptOnDeadLetter(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	callback, receipts := newReceiptServer(t)
+	defer callback.Close()
+
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer destination.Close()
+
+	dest := config.DestinationConfig{URL: destination.URL, Method: "POST", Timeout: time.Second, Retries: 1, RetryDelay: 10 * time.Millisecond}
+	handler := NewEndpointHandler(config.EndpointConfig{
+		Path:         "/webhook",
+		Destinations: []config.DestinationConfig{dest},
+		CallbackURL:  callback.URL,
+	}, log)
+
+	handler.deliver(context.Background(), "delivery-2", dest, []byte(`{}`), nil, 1, time.Time{})
+
+	require.Len(t, receipts, 1)
+	receipt := <-receipts
+	assert.Equal(t, "delivery-2", receipt.DeliveryID)
+	assert.Equal(t, logger.OutcomeFailure, receipt.Outcome)
+	assert.Equal(t, 2, receipt.Attempts)
+	assert.Equal(t, http.StatusBadGateway, receipt.StatusCode)
+	assert.Contains(t, receipt.Error, "status code: 502")
+}
+
+func TestReceiptCallbackFailure(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer callback.Close()
+
+	hook := newReceiptHook(callback.URL, log)
+	err := hook.post(Receipt{DeliveryID: "delivery-3"})
+	assert.ErrorContains(t, err, "status code: 500")
+}
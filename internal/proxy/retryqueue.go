@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// retryAging is how long a due retry is held back while deliveries wait for a slot of the
+// pool, before it takes its turn in the queue with them
+const retryAging = 5 * time.Second
+
+// retryQueue is the delay queue of the deliveries of a pool waiting for a retry. They wait
+// without a goroutine, for a single timer firing at the next due retry. Due retries are
+// handed back to the pool while no delivery waits for a slot; otherwise they are held back,
+// so that retries piling up behind a failing destination do not starve fresh deliveries,
+// until they have aged for retryAging.
+type retryQueue struct {
+	pool *DeliveryPool
+
+	mu      sync.Mutex
+	entries retryHeap
+	timer   *time.Timer
+}
+
+// retryEntry is a delivery waiting in the retry queue
+type retryEntry struct {
+	due   time.Time
+	index int // in the heap, -1 once out of the queue
+
+	// resume continues the delivery, and stop unregisters the end of its context
+	resume func()
+	stop   func() bool
+}
+
+// newRetryQueue creates the retry queue of a pool
+func newRetryQueue(pool *DeliveryPool) *retryQueue {
+	return &retryQueue{pool: pool}
+}
+
+// schedule calls resume once the delay is over and the retry is released to the pool, or
+// as soon as the context is done
+func (q *retryQueue) schedule(ctx context.Context, delay time.Duration, resume func()) {
+	entry := &retryEntry{due: q.pool.now().Add(delay), resume: resume}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	heap.Push(&q.entries, entry)
+	q.reset(q.pool.now())
+
+	// A delivery whose context ends leaves the queue at once, to give up, unless it was
+	// released first
+	entry.stop = context.AfterFunc(ctx, func() {
+		if q.remove(entry) {
+			entry.resume()
+		}
+	})
+}
+
+// release resumes the due retries, unless they are held back for the deliveries waiting
+// for a slot, and sets the timer for the next one
+func (q *retryQueue) release() {
+	now := q.pool.now()
+
+	q.mu.Lock()
+	var due []*retryEntry
+	for len(q.entries) > 0 {
+		next := q.entries[0]
+		if next.due.After(now) || (q.pool.queued.Load() > 0 && now.Before(next.due.Add(retryAging))) {
+			break
+		}
+		heap.Pop(&q.entries)
+		due = append(due, next)
+	}
+	q.reset(now)
+	q.mu.Unlock()
+
+	for _, entry := range due {
+		entry.stop()
+		entry.resume()
+	}
+}
+
+// remove takes an entry out of the queue, and reports whether it was still in it
+func (q *retryQueue) remove(entry *retryEntry) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if entry.index < 0 {
+		return false
+	}
+	heap.Remove(&q.entries, entry.index)
+	q.reset(q.pool.now())
+	return true
+}
+
+// reset sets the timer for the next retry due, or for its aging when it is held back. It
+// must be called with the lock held.
+func (q *retryQueue) reset(now time.Time) {
+	if len(q.entries) == 0 {
+		if q.timer != nil {
+			q.timer.Stop()
+		}
+		return
+	}
+
+	next := q.entries[0].due
+	if !next.After(now) {
+		next = next.Add(retryAging)
+	}
+	if q.timer == nil {
+		q.timer = time.AfterFunc(next.Sub(now), q.release)
+	} else {
+		q.timer.Reset(next.Sub(now))
+	}
+}
+
+// len returns the number of deliveries waiting for a retry
+func (q *retryQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// retryHeap orders the retries by due time
+type retryHeap []*retryEntry
+
+func (h retryHeap) Len() int           { return len(h) }
+func (h retryHeap) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+
+func (h retryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *retryHeap) Push(x any) {
+	entry := x.(*retryEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *retryHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	entry.index = -1
+	*h = old[:len(old)-1]
+	return entry
+}
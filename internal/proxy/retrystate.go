@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
+	"github.com/sirupsen/logrus"
+)
+
+// retryStateHook persists deliveries waiting for a retry and removes them once completed
+type retryStateHook struct {
+	NopHook
+	store *retrystore.Store
+	log   *logrus.Logger
+}
+
+// OnFailure saves the delivery state when another attempt will follow
+func (h *retryStateHook) OnFailure(event *Event) {
+	if event.Attempt >= event.MaxAttempts {
+		return
+	}
+
+	err := h.store.Save(retrystore.Record{
+		ID:            event.ID,
+		WebhookID:     event.WebhookID,
+		Endpoint:      event.Endpoint,
+		Destination:   event.Destination.Key(),
+		Body:          event.Body,
+		Headers:       event.Headers,
+		Attempt:       event.Attempt,
+		ReceivedAt:    event.ReceivedAt,
+		NextAttemptAt: time.Now().Add(retryDelay(event.Destination)),
+	})
+	if err != nil {
+		h.log.WithFields(logrus.Fields{
+			"error":       err,
+			"webhook_id":  event.WebhookID,
+			"delivery_id": event.ID,
+			"destination": event.Destination.Key(),
+		}).Error("Failed to persist retry state")
+	}
+}
+
+// AfterForward removes the state of a delivery that succeeded after a retry
+func (h *retryStateHook) AfterForward(event *Event) {
+	if event.Err == nil && event.Attempt > 1 {
+		h.delete(event)
+	}
+}
+
+// OnDeadLetter removes the state of a delivery that exhausted its retries
+func (h *retryStateHook) OnDeadLetter(event *Event) {
+	h.delete(event)
+}
+
+// delete removes the state of a delivery
+func (h *retryStateHook) delete(event *Event) {
+	if err := h.store.Delete(event.ID); err != nil {
+		h.log.WithFields(logrus.Fields{
+			"error":       err,
+			"delivery_id": event.ID,
+		}).Error("Failed to delete retry state")
+	}
+}
+
+// SetRetryStore persists the state of deliveries waiting for a retry in the store,
+// so that they can be resumed after a restart with Resume
+func (p *Handler) SetRetryStore(store *retrystore.Store) {
+	p.retryStore = store
+	p.AddHook(&retryStateHook{store: store, log: p.log})
+}
+
+// Resume continues a delivery persisted before a restart, at its next attempt time. Like
+// live deliveries, it runs in the delivery pool and, once attempted, is waited for by Drain.
+// Draining before its next attempt time ends its wait instead, leaving it persisted.
+// It returns false if the delivery's destination is no longer configured on this handler.
+func (p *Handler) Resume(record retrystore.Record) bool {
+	for _, dest := range p.destinations {
+		if dest.Key() != record.Destination {
+			continue
+		}
+
+		p.metrics.RecordRequest(dest.Key())
+
+		p.inFlight.Add(1)
+		run := func(ctx context.Context) {
+			// The delivery waits for its next attempt without a slot of the pool
+			releaseSlot(ctx)
+			timer := time.NewTimer(time.Until(record.NextAttemptAt))
+			select {
+			case <-timer.C:
+			case <-p.resuming.Done():
+				timer.Stop()
+				p.inFlight.Add(-1)
+				return
+			}
+
+			d := p.newDelivery(ctx, record.ID, dest, record.Body, record.Headers, record.Attempt+1, record.ReceivedAt)
+			p.runDelivery(ctx, d, func(context.Context) { p.inFlight.Add(-1) })
+		}
+
+		// The delivery is persisted, so it is never dropped by the pool
+		ctx := withWebhookID(context.Background(), record.WebhookID)
+		if p.pool == nil {
+			go run(ctx)
+		} else {
+			p.pool.Go(ctx, dest.Key(), true, run)
+		}
+		return true
+	}
+
+	return false
+}
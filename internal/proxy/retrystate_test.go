@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryStatePersistence(t *testing.T) {
+	store, err := retrystore.Open(t.TempDir())
+	require.NoError(t, err)
+
+	// Fail the first attempt, then succeed
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 1, RetryDelay: 100 * time.Millisecond}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.SetRetryStore(store)
+
+	// Check the persisted state while the delivery waits for its retry
+	var persisted []retrystore.Record
+	handler.AddHook(&failureHook{onFailure: func(*Event) {
+		persisted, err = store.Load()
+		require.NoError(t, err)
+	}})
+
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), map[string]string{"X-Test": "1"}, time.Now())
+
+	require.Len(t, persisted, 1)
+	assert.Equal(t, "/webhook", persisted[0].Endpoint)
+	assert.Equal(t, server.URL, persisted[0].Destination)
+	assert.Equal(t, 1, persisted[0].Attempt)
+	assert.Equal(t, `{"event":"test"}`, string(persisted[0].Body))
+	assert.Equal(t, map[string]string{"X-Test": "1"}, persisted[0].Headers)
+	assert.WithinDuration(t, time.Now().Add(100*time.Millisecond), persisted[0].NextAttemptAt, time.Second)
+	assert.WithinDuration(t, time.Now(), persisted[0].ReceivedAt, time.Second)
+
+	// The state is removed once the retry succeeded
+	records, err := store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestResume(t *testing.T) {
+	store, err := retrystore.Open(t.TempDir())
+	require.NoError(t, err)
+
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 2, RetryDelay: time.Second}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.SetRetryStore(store)
+
+	attempts := make(chan int, 3)
+	handler.AddHook(&afterForwardHook{onAfterForward: func(event *Event) {
+		assert.Equal(t, "persisted-id", event.ID)
+		attempts <- event.Attempt
+	}})
+
+	record := retrystore.Record{
+		ID:            "persisted-id",
+		Endpoint:      "/webhook",
+		Destination:   server.URL,
+		Body:          []byte(`{"event":"resumed"}`),
+		Attempt:       1,
+		NextAttemptAt: time.Now().Add(50 * time.Millisecond),
+	}
+	require.NoError(t, store.Save(record))
+
+	// Unknown destinations are not resumed
+	assert.False(t, handler.Resume(retrystore.Record{ID: "other", Destination: "https://removed.example.com"}))
+
+	// The delivery continues at its next attempt
+	require.True(t, handler.Resume(record))
+	select {
+	case body := <-received:
+		assert.Equal(t, `{"event":"resumed"}`, body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the delivery to be resumed")
+	}
+
+	assert.Eventually(t, func() bool {
+		records, err := store.Load()
+		return err == nil && len(records) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, <-attempts)
+	assert.Empty(t, attempts)
+}
+
+// failureHook only implements OnFailure
+type failureHook struct {
+	NopHook
+	onFailure func(event *Event)
+}
+
+func (h *failureHook) OnFailure(event *Event) { h.onFailure(event) }
+
+// afterForwardHook only implements AfterForward
+type afterForwardHook struct {
+	NopHook
+	onAfterForward func(event *Event)
+}
+
+func (h *afterForwardHook) AfterForward(event *Event) { h.onAfterForward(event) }
+
+func TestResumeWithDeliveryPool(t *testing.T) {
+	release := make(chan struct{})
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		received.Add(1)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 2}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.SetDeliveryPool(NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 100, Overflow: config.DeliveriesOverflowReject}))
+
+	// The resumed delivery waits for the slot taken by a live one, and is counted in flight
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", []byte(`{}`), nil))
+	assert.Eventually(t, func() bool { return received.Load() == 1 }, time.Second, time.Millisecond)
+	require.True(t, handler.Resume(retrystore.Record{ID: "persisted-id", Destination: server.URL, Body: []byte(`{}`), Attempt: 1}))
+	assert.Equal(t, int64(2), handler.InFlight())
+	assert.Never(t, func() bool { return received.Load() > 1 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, handler.Drain(ctx))
+	assert.Equal(t, int64(2), received.Load())
+}
+
+func TestDrainEndsResumedWaits(t *testing.T) {
+	store, err := retrystore.Open(t.TempDir())
+	require.NoError(t, err)
+
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dest := config.DestinationConfig{URL: server.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 2}
+	handler := NewEndpointHandler(config.EndpointConfig{Path: "/webhook", Destinations: []config.DestinationConfig{dest}}, log)
+	handler.SetRetryStore(store)
+	handler.SetDeliveryPool(NewDeliveryPool(config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 100, Overflow: config.DeliveriesOverflowReject}))
+
+	record := retrystore.Record{ID: "persisted-id", Endpoint: "/webhook", Destination: server.URL, Body: []byte(`{}`), Attempt: 1, NextAttemptAt: time.Now().Add(time.Hour)}
+	require.NoError(t, store.Save(record))
+	require.True(t, handler.Resume(record))
+	assert.Equal(t, int64(1), handler.InFlight())
+
+	// Draining does not wait for the next attempt, and leaves the delivery persisted
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, handler.Drain(ctx))
+	assert.Equal(t, int64(0), received.Load())
+
+	records, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "persisted-id", records[0].ID)
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// signatureHeader returns the signature header of a request to a signed destination:
+// t=timestamp followed by one v1=hex(HMAC-SHA256(timestamp.body)) per secret, so that
+// the receiver can verify it with either its current or its previous key
+func signatureHeader(signing config.SigningConfig, body []byte, now time.Time) string {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	parts := make([]string, 0, len(signing.Secrets)+1)
+	parts = append(parts, "t="+timestamp)
+	for _, secret := range signing.Secrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp))
+		mac.Write([]byte("."))
+		mac.Write(body)
+		parts = append(parts, "v1="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// signingTime returns the time an attempt is signed with: the attempt's time, or the time
+// the webhook was received until it is older than the max age. The skew is then added.
+func signingTime(signing *config.SigningConfig, receivedAt, now time.Time) time.Time {
+	if signing == nil {
+		return now
+	}
+
+	signedAt := now
+	if signing.TimestampSource == config.SigningTimestampReceived &&
+		(signing.MaxAge == 0 || now.Sub(receivedAt) <= signing.MaxAge) {
+		signedAt = receivedAt
+	}
+	return signedAt.Add(signing.Skew)
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSignature returns the v1 signature of a body with a secret
+func testSignature(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSignatureHeader(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := `{"event":"test"}`
+
+	header := signatureHeader(config.SigningConfig{Secrets: []string{"current", "previous"}}, []byte(body), now)
+
+	assert.Equal(t, strings.Join([]string{
+		"t=1700000000",
+		testSignature("current", "1700000000", body),
+		testSignature("previous", "1700000000", body),
+	}, ","), header)
+}
+
+func TestSendRequestSigning(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	body := `{"event":"test"}`
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Signature")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:     server.URL,
+		Method:  "POST",
+		Timeout: time.Second,
+		Signing: &config.SigningConfig{Secrets: []string{"current"}, Header: "X-Signature"},
+	}
+
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+	_, _, _, _, err := handler.sendRequest(context.Background(), &http.Client{}, dest, []byte(body), map[string]string{}, time.Now())
+	assert.NoError(t, err)
+
+	timestamp, v1, ok := strings.Cut(signature, ",")
+	assert.True(t, ok)
+	seconds, err := strconv.ParseInt(strings.TrimPrefix(timestamp, "t="), 10, 64)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), time.Unix(seconds, 0), time.Minute)
+	assert.Equal(t, testSignature("current", strings.TrimPrefix(timestamp, "t="), body), v1)
+}
+
+func TestSigningTime(t *testing.T) {
+	received := time.Unix(1700000000, 0)
+	now := received.Add(3 * time.Minute)
+
+	tests := []struct {
+		name     string
+		signing  *config.SigningConfig
+		expected time.Time
+	}{
+		{"unsigned", nil, now},
+		{"attempt", &config.SigningConfig{TimestampSource: config.SigningTimestampAttempt}, now},
+		{"received", &config.SigningConfig{TimestampSource: config.SigningTimestampReceived}, received},
+		{"received within max age", &config.SigningConfig{TimestampSource: config.SigningTimestampReceived, MaxAge: 5 * time.Minute}, received},
+		{"received over max age", &config.SigningConfig{TimestampSource: config.SigningTimestampReceived, MaxAge: time.Minute}, now},
+		{"skew", &config.SigningConfig{TimestampSource: config.SigningTimestampAttempt, Skew: -30 * time.Second}, now.Add(-30 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, signingTime(tt.signing, received, now))
+		})
+	}
+}
+
+func TestDeliverSigningReceivedTimestamp(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var signatures []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signatures = append(signatures, r.Header.Get("X-Signature"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:        server.URL,
+		Method:     "POST",
+		Timeout:    time.Second,
+		Retries:    2,
+		RetryDelay: 10 * time.Millisecond,
+		Signing: &config.SigningConfig{
+			Secrets:         []string{"current"},
+			Header:          "X-Signature",
+			TimestampSource: config.SigningTimestampReceived,
+		},
+	}
+
+	// Retries carry the signature of the time the webhook was received
+	received := time.Now().Add(-time.Hour)
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, logger)
+	handler.deliver(context.Background(), "delivery-1", dest, []byte(`{}`), nil, 1, received)
+
+	assert.Len(t, signatures, 3)
+	for _, signature := range signatures {
+		assert.Equal(t, signatureHeader(*dest.Signing, []byte(`{}`), received), signature)
+	}
+}
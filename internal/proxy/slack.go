@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/transform"
+)
+
+// slackMaxPayloadBytes bounds the payload quoted in the default Slack message, well below
+// the length Slack truncates messages at
+const slackMaxPayloadBytes = 3000
+
+// slackMessage is the body of a request to a Slack incoming webhook
+type slackMessage struct {
+	Text      string `json:"text"`
+	Channel   string `json:"channel,omitempty"`
+	Username  string `json:"username,omitempty"`
+	IconEmoji string `json:"icon_emoji,omitempty"`
+}
+
+// slackFormatter wraps the webhooks sent to a slack destination in Slack messages
+type slackFormatter struct {
+	config config.SlackConfig
+
+	// text renders the text of the messages, nil for the default summary
+	text *transform.Template
+}
+
+// newSlackFormatter creates the formatter of a slack destination
+func newSlackFormatter(dest config.DestinationConfig) (*slackFormatter, error) {
+	f := &slackFormatter{}
+	if dest.Slack == nil {
+		return f, nil
+	}
+	f.config = *dest.Slack
+	if f.config.Text != "" {
+		tmpl, err := transform.Parse(f.config.Text)
+		if err != nil {
+			return nil, err
+		}
+		f.text = tmpl
+	}
+	return f, nil
+}
+
+// slack returns the body and headers to send to a slack destination: a Slack message with the
+// rendered text, or summarizing the webhook when there is no template or it fails to render
+func (p *payload) slack(dest config.DestinationConfig, f *slackFormatter) ([]byte, map[string]string) {
+	text := ""
+	if f.text != nil {
+		rendered, err := f.text.Render(transform.Data{
+			Endpoint:  p.delivery.Endpoint,
+			RequestID: p.delivery.RequestID,
+			Headers:   p.headers,
+			Fields:    p.fields,
+			Payload:   p.payload(),
+		})
+		if err != nil {
+			p.log.WithError(err).WithField("destination", dest.Key()).Warn("Failed to render the Slack message, sending the default one")
+		} else {
+			text = string(rendered)
+		}
+	}
+	if text == "" {
+		text = p.slackSummary()
+	}
+
+	// A message of strings always encodes
+	body, _ := json.Marshal(slackMessage{
+		Text:      text,
+		Channel:   f.config.Channel,
+		Username:  f.config.Username,
+		IconEmoji: f.config.IconEmoji,
+	})
+	return body, contentTypeHeaders(p.headers, "application/json")
+}
+
+// slackSummary returns the default text of the Slack messages: the endpoint and event type of
+// the webhook, followed by its payload in a code block, truncated when too long
+func (p *payload) slackSummary() string {
+	title := fmt.Sprintf("Webhook received on `%s`", p.delivery.Endpoint)
+	if event := p.fields[config.ProviderFieldEvent]; event != "" {
+		title += fmt.Sprintf(" (%s)", event)
+	}
+
+	quoted := p.body
+	var indented bytes.Buffer
+	if json.Indent(&indented, p.body, "", "  ") == nil {
+		quoted = indented.Bytes()
+	}
+	if len(quoted) == 0 {
+		return title
+	}
+	if len(quoted) > slackMaxPayloadBytes {
+		cut := slackMaxPayloadBytes
+		for cut > 0 && !utf8.RuneStart(quoted[cut]) {
+			cut--
+		}
+		quoted = append(quoted[:cut:cut], "\n…"...)
+	}
+	return title + "\n```\n" + string(quoted) + "\n```"
+}
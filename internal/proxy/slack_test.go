@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadSlack(t *testing.T) {
+	dest := config.DestinationConfig{Type: config.DestinationTypeSlack, URL: "https://hooks.slack.com/services/T000/B000/XXXX", Slack: &config.SlackConfig{
+		Text:      `{{.Fields.repo}}: {{.Payload.action}}`,
+		Channel:   "#deploys",
+		Username:  "webhook-proxy",
+		IconEmoji: ":rocket:",
+	}}
+	formatter, err := newSlackFormatter(dest)
+	require.NoError(t, err)
+
+	payload := newTestPayload(`{"action":"opened"}`, "application/json")
+	payload.fields = map[string]string{"repo": "octo/hello"}
+	body, headers := payload.slack(dest, formatter)
+	assert.JSONEq(t, `{"text":"octo/hello: opened","channel":"#deploys","username":"webhook-proxy","icon_emoji":":rocket:"}`, string(body))
+	assert.Equal(t, "application/json", headers["Content-Type"])
+	assert.NotContains(t, headers, "Content-Length")
+
+	// Without a template, or when it fails to render, the message summarizes the webhook
+	for _, slack := range []*config.SlackConfig{nil, {Text: `{{.Payload.action.name}}`}} {
+		dest.Slack = slack
+		formatter, err := newSlackFormatter(dest)
+		require.NoError(t, err)
+
+		payload := newTestPayload(`{"action":"opened"}`, "application/json")
+		payload.fields = map[string]string{config.ProviderFieldEvent: "pull_request"}
+		body, _ := payload.slack(dest, formatter)
+
+		var message slackMessage
+		require.NoError(t, json.Unmarshal(body, &message))
+		assert.Equal(t, "Webhook received on `/webhook` (pull_request)\n```\n{\n  \"action\": \"opened\"\n}\n```", message.Text)
+		assert.Empty(t, message.Channel)
+	}
+}
+
+func TestSlackSummaryTruncated(t *testing.T) {
+	payload := newTestPayload(strings.Repeat("é", slackMaxPayloadBytes), "text/plain")
+	summary := payload.slackSummary()
+
+	assert.True(t, utf8.ValidString(summary))
+	assert.True(t, strings.HasSuffix(summary, "\n…\n```"))
+	assert.Less(t, len(summary), slackMaxPayloadBytes+100)
+}
+
+func TestForwardWebhookSlack(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	received := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler := NewProxyHandler([]config.DestinationConfig{{
+		Type:    config.DestinationTypeSlack,
+		URL:     server.URL,
+		Method:  "POST",
+		Timeout: time.Second,
+		Slack:   &config.SlackConfig{Text: `New issue in {{.Payload.repository.full_name}}`},
+	}}, logger)
+	require.Contains(t, handler.slack, handler.destinations[0].Key())
+	require.NotContains(t, handler.sinks, handler.destinations[0].Key())
+
+	body := []byte(`{"action":"opened","repository":{"full_name":"octo/hello"}}`)
+	handler.ForwardWebhook(context.Background(), webhook.New("/webhook", body, map[string]string{"Content-Type": "application/json"}))
+
+	select {
+	case data := <-received:
+		assert.JSONEq(t, `{"text":"New issue in octo/hello"}`, string(data))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be sent as a Slack message")
+	}
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/logger"
+)
+
+// checkResponse returns an error if a destination's response does not count as a
+// successful delivery: a non-2xx status, or one breaking the destination's success rules
+func checkResponse(dest config.DestinationConfig, statusCode int, body []byte) error {
+	rules := dest.Success
+	if rules == nil || len(rules.StatusCodes) == 0 {
+		if statusCode < 200 || statusCode >= 300 {
+			return logger.WithClass(statusClass(statusCode), fmt.Errorf("received non-2xx status code: %d, body: %s", statusCode, string(body)))
+		}
+	} else if !containsStatus(rules.StatusCodes, statusCode) {
+		return logger.WithClass(statusClass(statusCode), fmt.Errorf("received unexpected status code: %d, body: %s", statusCode, string(body)))
+	}
+
+	if rules == nil {
+		return nil
+	}
+
+	// The body is left out of these errors, as the rule already tells what it is
+	if rules.BodyContains != "" && !bytes.Contains(body, []byte(rules.BodyContains)) {
+		return logger.WithClass("unexpected_body", fmt.Errorf("response body rejected: missing %q, status: %d", rules.BodyContains, statusCode))
+	}
+	if rules.BodyNotContains != "" && bytes.Contains(body, []byte(rules.BodyNotContains)) {
+		return logger.WithClass("unexpected_body", fmt.Errorf("response body rejected: contains %q, status: %d", rules.BodyNotContains, statusCode))
+	}
+
+	return nil
+}
+
+// statusClass returns the error class of a response with an unaccepted status code
+func statusClass(statusCode int) string {
+	return "http_" + strconv.Itoa(statusCode)
+}
+
+// containsStatus reports whether a status code is in a list
+func containsStatus(codes []int, statusCode int) bool {
+	for _, code := range codes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
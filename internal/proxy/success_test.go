@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		success    *config.SuccessConfig
+		statusCode int
+		body       string
+		class      string
+	}{
+		{name: "default 2xx", statusCode: http.StatusCreated, body: `{}`},
+		{name: "default non-2xx", statusCode: http.StatusBadGateway, body: `{}`, class: "http_502"},
+		{name: "accepted status", success: &config.SuccessConfig{StatusCodes: []int{200, 204}}, statusCode: http.StatusNoContent},
+		{name: "unaccepted 2xx status", success: &config.SuccessConfig{StatusCodes: []int{200}}, statusCode: http.StatusAccepted, class: "http_202"},
+		{name: "body contains", success: &config.SuccessConfig{BodyContains: `"ok":true`}, statusCode: http.StatusOK, body: `{"ok":true}`},
+		{name: "body missing", success: &config.SuccessConfig{BodyContains: `"ok":true`}, statusCode: http.StatusOK, body: `{"ok":false,"error":"invalid_auth"}`, class: "unexpected_body"},
+		{name: "body without error", success: &config.SuccessConfig{BodyNotContains: `"error"`}, statusCode: http.StatusOK, body: `{"ok":true}`},
+		{name: "body with error", success: &config.SuccessConfig{BodyNotContains: `"error"`}, statusCode: http.StatusOK, body: `{"error":"timeout"}`, class: "unexpected_body"},
+		{name: "status checked before body", success: &config.SuccessConfig{BodyContains: `"ok":true`}, statusCode: http.StatusInternalServerError, body: `{"ok":true}`, class: "http_500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkResponse(config.DestinationConfig{Success: tt.success}, tt.statusCode, []byte(tt.body))
+			if tt.class == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.class, logger.ErrorClass(err))
+			}
+		})
+	}
+}
+
+// TestForwardToDestinationSuccessRules tests that a 200 with an error body is retried
+func TestForwardToDestinationSuccessRules(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		calls++
+		call := calls
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		if call == 1 {
+			_, _ = w.Write([]byte(`{"ok":false,"error":"rate_limited"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	dest := config.DestinationConfig{
+		URL:        server.URL,
+		Method:     "POST",
+		Timeout:    time.Second,
+		Retries:    2,
+		RetryDelay: 10 * time.Millisecond,
+		Success:    &config.SuccessConfig{BodyContains: `"ok":true`},
+	}
+
+	handler := NewProxyHandler([]config.DestinationConfig{dest}, log)
+	handler.forwardToDestination(context.Background(), dest, []byte(`{"event":"test"}`), map[string]string{}, time.Now())
+
+	mu.Lock()
+	assert.Equal(t, 2, calls)
+	mu.Unlock()
+
+	metrics := handler.GetMetrics()
+	assert.Equal(t, int64(1), metrics["successful_requests"])
+	assert.Equal(t, int64(1), metrics["failed_requests"])
+}
@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// maxIdleConnsPerHost is the number of idle connections kept open to each destination
+// host. The default of 2 would close and reopen connections under concurrent HTTP/1.1
+// deliveries, exhausting ephemeral ports.
+const maxIdleConnsPerHost = 100
+
+// sharedTransport is the transport of the HTTP destinations without connection settings
+// of their own, shared by every endpoint. HTTP/2 is negotiated with https destinations,
+// so that concurrent deliveries to a destination are multiplexed over few connections.
+var sharedTransport = sharedPool.count(newSharedTransport())
+
+// sharedPool counts the connections of the shared transport
+var sharedPool = &connPool{}
+
+// newSharedTransport creates a transport behaving as the default one, with HTTP/2 over TLS
+// and more idle connections per host
+func newSharedTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return transport
+}
+
+// newTransport creates the HTTP transport of a destination with a local address, a dial,
+// an egress proxy, a protocol or a connection pool: its connections originate from the
+// local IP address, or from the first address of the network interface, they are opened
+// to the address families of the dial, its requests go through the proxy, it speaks the
+// protocol, and it keeps its connections as the pool says. It otherwise behaves as the
+// shared transport.
+func newTransport(dest config.DestinationConfig) (*http.Transport, error) {
+	transport := newSharedTransport()
+
+	if dest.LocalAddress != "" || dest.Dial != nil || dest.ConnectionPool != nil {
+		dialer := &net.Dialer{
+			Timeout:   config.DefaultDialTimeout,
+			KeepAlive: config.DefaultPoolKeepAlive,
+		}
+		if dest.LocalAddress != "" {
+			ip, err := resolveLocalAddress(dest.LocalAddress)
+			if err != nil {
+				return nil, err
+			}
+			dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+		if dest.Dial != nil {
+			dialer.Timeout = dest.Dial.Timeout
+			dialer.FallbackDelay = dest.Dial.FallbackDelay
+		}
+		if dest.ConnectionPool != nil {
+			dialer.KeepAlive = dest.ConnectionPool.KeepAlive
+		}
+		transport.DialContext = dialContext(dialer, dest.Dial)
+	}
+
+	if pool := dest.ConnectionPool; pool != nil {
+		transport.MaxIdleConns = pool.MaxIdleConns
+		transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+		transport.MaxConnsPerHost = pool.MaxConnsPerHost
+		transport.IdleConnTimeout = pool.IdleConnTimeout
+		transport.DisableKeepAlives = pool.DisableKeepAlives
+	}
+
+	// The transport speaks to HTTP and SOCKS5 proxies, authenticating with the URL's credentials
+	if dest.Proxy != nil {
+		proxyURL, err := url.Parse(dest.Proxy.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url")
+		}
+		if dest.Proxy.Username != "" {
+			proxyURL.User = url.UserPassword(dest.Proxy.Username, dest.Proxy.Password)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	switch dest.Protocol {
+	case config.ProtocolHTTP1:
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+	case config.ProtocolH2C:
+		// Without HTTP/1.1, http:// requests use HTTP/2 with prior knowledge
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	return transport, nil
+}
+
+// transportKey identifies the destinations sharing a transport, empty for the destinations
+// using the shared one
+func transportKey(dest config.DestinationConfig) string {
+	protocol := dest.Protocol
+	if protocol == config.ProtocolAuto {
+		protocol = ""
+	}
+	if dest.Proxy == nil && protocol == "" && dest.Dial == nil && dest.ConnectionPool == nil {
+		return dest.LocalAddress
+	}
+
+	// Each setting has its own slot, so that different settings never share a key
+	parts := make([]string, 9)
+	parts[0], parts[1] = dest.LocalAddress, protocol
+	if dest.Dial != nil {
+		parts[2], parts[3], parts[4] = dest.Dial.IPFamily, dest.Dial.FallbackDelay.String(), dest.Dial.Timeout.String()
+	}
+	if dest.Proxy != nil {
+		parts[5], parts[6], parts[7] = dest.Proxy.URL, dest.Proxy.Username, dest.Proxy.Password
+	}
+	if dest.ConnectionPool != nil {
+		parts[8] = fmt.Sprintf("%+v", *dest.ConnectionPool)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// proxyAddress returns the URL of a destination's proxy without its credentials, for logs
+func proxyAddress(dest config.DestinationConfig) string {
+	if dest.Proxy == nil {
+		return ""
+	}
+	if u, err := url.Parse(dest.Proxy.URL); err == nil {
+		return u.Redacted()
+	}
+	return ""
+}
+
+// resolveLocalAddress returns the IP address of a local address: the address itself, or
+// the first address of the network interface it names, IPv4 first
+func resolveLocalAddress(localAddress string) (net.IP, error) {
+	if ip := net.ParseIP(localAddress); ip != nil {
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(localAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unknown network interface %s: %w", localAddress, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the addresses of %s: %w", localAddress, err)
+	}
+
+	var first net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if first == nil {
+			first = ipNet.IP
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("network interface %s has no address", localAddress)
+	}
+	return first, nil
+}
+
+// httpClient returns the client of an HTTP destination, created with the handler and
+// reused by its deliveries
+func (p *Handler) httpClient(dest config.DestinationConfig) *http.Client {
+	if client, ok := p.clients[dest.Key()]; ok {
+		return client
+	}
+	return newClient(dest, p.transports)
+}
+
+// newClient creates the client of an HTTP destination, bound to the transport of the
+// destination's local address, proxy, protocol and connection pool when it has them
+func newClient(dest config.DestinationConfig, transports map[string]*http.Transport) *http.Client {
+	client := &http.Client{Timeout: dest.Timeout, Transport: sharedTransport}
+	if transport, ok := transports[transportKey(dest)]; ok {
+		client.Transport = transport
+	}
+	return client
+}
+
+// connPool returns the connection counts of the transport of an HTTP destination
+func (p *Handler) connPool(dest config.DestinationConfig) *connPool {
+	if pool, ok := p.pools[transportKey(dest)]; ok {
+		return pool
+	}
+	return sharedPool
+}
+
+// connPool counts the connections of a transport, to report how well they are reused
+type connPool struct {
+	open   atomic.Int64 // connections currently open
+	opened atomic.Int64 // connections opened since startup
+	reused atomic.Int64 // requests sent on a connection kept from a previous request
+}
+
+// count counts the connections the transport opens and closes, and returns it
+func (c *connPool) count(transport *http.Transport) *http.Transport {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		c.open.Add(1)
+		c.opened.Add(1)
+		return &countedConn{Conn: conn, pool: c}, nil
+	}
+	return transport
+}
+
+// stats returns the connection counts for the metrics
+func (c *connPool) stats() (open, opened, reused int64) {
+	return c.open.Load(), c.opened.Load(), c.reused.Load()
+}
+
+// countedConn is a connection counted by its pool until it is closed
+type countedConn struct {
+	net.Conn
+	pool   *connPool
+	closed sync.Once
+}
+
+// Close closes the connection, counting it once
+func (c *countedConn) Close() error {
+	c.closed.Do(func() { c.pool.open.Add(-1) })
+	return c.Conn.Close()
+}
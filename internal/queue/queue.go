@@ -0,0 +1,327 @@
+// Package queue persists the webhooks accepted by the endpoints until they are forwarded,
+// one file per webhook in a directory, so that a crash or a restart never loses a webhook
+// that was acknowledged to its sender
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+)
+
+// fileExtension is the extension of entry files
+const fileExtension = ".json"
+
+// tmpExtension is the extension of entry files being written
+const tmpExtension = ".tmp"
+
+// lockFile is the name of the file locked by the process that opened the queue
+const lockFile = ".lock"
+
+// ErrLocked is returned by Open when another process has the queue directory open
+var ErrLocked = errors.New("queue directory is locked by another process")
+
+// lockRetryInterval is how often Lock tries to take the lock of the queue directory
+const lockRetryInterval = 100 * time.Millisecond
+
+// ErrClosed is returned by Pop once the queue is closed
+var ErrClosed = errors.New("queue closed")
+
+// entry is a queued webhook. Only its ID is kept in memory, its body is read on Pop.
+type entry struct {
+	id         string
+	receivedAt time.Time
+}
+
+// Queue keeps one file per queued webhook in a directory, and hands the webhooks to the
+// workers in the order they were received. An entry stays on disk until it is acknowledged.
+// The directory is locked by the process that drains the entries left on disk, so that two
+// processes never forward the same entry.
+type Queue struct {
+	dir string
+
+	mu       sync.Mutex
+	ready    *sync.Cond
+	lock     *os.File
+	pending  []entry
+	inFlight map[string]struct{}
+	stopped  bool
+}
+
+// Open opens the queue in the given directory, creating it if needed, and queues again the
+// entries left by a previous run. Unreadable entries are skipped and reported in the
+// returned error, along with a usable queue.
+//
+// When another process has the directory locked, such as the process being upgraded, Open
+// returns ErrLocked along with a queue that only hands the workers the entries pushed to
+// it, until Lock takes the directory over.
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	q := &Queue{dir: dir, inFlight: make(map[string]struct{})}
+	q.ready = sync.NewCond(&q.mu)
+
+	lock, err := lockDir(filepath.Join(dir, lockFile))
+	if errors.Is(err, ErrLocked) {
+		return q, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.lock = lock
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to read queue directory: %w", err)
+	}
+
+	// Entries whose write was interrupted were never acknowledged to their sender
+	return q, q.scan(entries, true)
+}
+
+// Lock waits for the process that has the queue directory locked to close its queue, unless
+// the context is done first, then takes the directory over and queues the entries that
+// process left on disk. It returns at once when the queue has the directory locked already.
+func (q *Queue) Lock(ctx context.Context) error {
+	q.mu.Lock()
+	locked := q.lock != nil
+	q.mu.Unlock()
+	if locked {
+		return nil
+	}
+
+	ticker := time.NewTicker(lockRetryInterval)
+	defer ticker.Stop()
+	for {
+		lock, err := lockDir(filepath.Join(q.dir, lockFile))
+		if err == nil {
+			q.mu.Lock()
+			q.lock = lock
+			q.mu.Unlock()
+
+			entries, err := os.ReadDir(q.dir)
+			if err != nil {
+				return fmt.Errorf("failed to read queue directory: %w", err)
+			}
+
+			// Temporary files may be pushes of this process, so they are left alone
+			return q.scan(entries, false)
+		}
+		if !errors.Is(err, ErrLocked) {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// scan queues the entries of the directory that the queue does not know yet, oldest first,
+// and removes the temporary files of interrupted writes with cleanTmp. Unreadable entries
+// are skipped and reported in the returned error.
+func (q *Queue) scan(dirEntries []os.DirEntry, cleanTmp bool) error {
+	q.mu.Lock()
+	known := make(map[string]struct{}, len(q.pending)+len(q.inFlight))
+	for _, e := range q.pending {
+		known[e.id] = struct{}{}
+	}
+	for id := range q.inFlight {
+		known[id] = struct{}{}
+	}
+	q.mu.Unlock()
+
+	var found []entry
+	var errs []error
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() {
+			continue
+		}
+
+		if strings.HasSuffix(name, tmpExtension) {
+			if !cleanTmp {
+				continue
+			}
+			if err := os.Remove(filepath.Join(q.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if !strings.HasSuffix(name, fileExtension) {
+			continue
+		}
+
+		id := strings.TrimSuffix(name, fileExtension)
+		if _, ok := known[id]; ok {
+			continue
+		}
+		delivery, err := q.read(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		found = append(found, entry{id: delivery.ID, receivedAt: delivery.ReceivedAt})
+	}
+
+	if len(found) > 0 {
+		q.mu.Lock()
+		q.pending = append(found, q.pending...)
+		sort.SliceStable(q.pending, func(i, j int) bool {
+			return q.pending[i].receivedAt.Before(q.pending[j].receivedAt)
+		})
+		q.mu.Unlock()
+		q.ready.Broadcast()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Push writes the delivery to disk, then queues it for the workers. The delivery is durable
+// once Push returns without error.
+func (q *Queue) Push(delivery *webhook.Delivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to encode queue entry: %w", err)
+	}
+
+	// Write to a temporary file first so that a crash never leaves a partial entry, and
+	// sync it so that the entry survives a power loss once acknowledged to the sender
+	tmp, err := os.CreateTemp(q.dir, delivery.ID+".*"+tmpExtension)
+	if err != nil {
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), q.path(delivery.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+
+	// Sync the directory too, so that the rename itself survives a power loss
+	if err := syncDir(q.dir); err != nil {
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+
+	q.mu.Lock()
+	q.pending = append(q.pending, entry{id: delivery.ID, receivedAt: delivery.ReceivedAt})
+	q.mu.Unlock()
+	q.ready.Signal()
+	return nil
+}
+
+// Pop waits for a queued delivery and returns it, oldest first, read from disk. The
+// delivery stays on disk until it is acknowledged. It returns ErrClosed once the queue is
+// stopped, and an error for an entry that can no longer be read, which is skipped.
+func (q *Queue) Pop() (*webhook.Delivery, error) {
+	q.mu.Lock()
+	for len(q.pending) == 0 && !q.stopped {
+		q.ready.Wait()
+	}
+	if q.stopped {
+		q.mu.Unlock()
+		return nil, ErrClosed
+	}
+	next := q.pending[0]
+	q.pending = q.pending[1:]
+	q.inFlight[next.id] = struct{}{}
+	q.mu.Unlock()
+
+	delivery, err := q.read(next.id)
+	if err != nil {
+		q.mu.Lock()
+		delete(q.inFlight, next.id)
+		q.mu.Unlock()
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Ack removes a delivery returned by Pop, once it has been forwarded
+func (q *Queue) Ack(id string) error {
+	q.mu.Lock()
+	delete(q.inFlight, id)
+	q.mu.Unlock()
+
+	if err := os.Remove(q.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete queue entry: %w", err)
+	}
+	return nil
+}
+
+// Depth returns the number of entries waiting for a worker, and of entries being forwarded
+func (q *Queue) Depth() (pending, inFlight int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending), len(q.inFlight)
+}
+
+// Stop stops handing entries to the workers, waking those waiting in Pop. Entries can
+// still be pushed, and are left on disk for the process that takes the directory over.
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.mu.Unlock()
+	q.ready.Broadcast()
+}
+
+// Close stops the queue and unlocks the directory, for another process to take it over
+// and queue again the entries left on disk. The entries being forwarded must be
+// acknowledged before, so that they are not forwarded twice.
+func (q *Queue) Close() error {
+	q.Stop()
+
+	q.mu.Lock()
+	lock := q.lock
+	q.lock = nil
+	q.mu.Unlock()
+	if lock == nil {
+		return nil
+	}
+	return lock.Close()
+}
+
+// read reads the entry with the given ID
+func (q *Queue) read(id string) (*webhook.Delivery, error) {
+	data, err := os.ReadFile(q.path(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var delivery webhook.Delivery
+	if err := json.Unmarshal(data, &delivery); err != nil {
+		return nil, fmt.Errorf("%s%s: %w", id, fileExtension, err)
+	}
+	return &delivery, nil
+}
+
+// path returns the path of the file of an entry
+func (q *Queue) path(id string) string {
+	return filepath.Join(q.dir, id+fileExtension)
+}
@@ -0,0 +1,210 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	first := &webhook.Delivery{
+		ID:         "first",
+		Endpoint:   "/webhook",
+		Body:       []byte(`{"event":"push"}`),
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		RequestID:  "req-1",
+		Metadata:   map[string]string{"team": "payments"},
+		ReceivedAt: now,
+	}
+	second := &webhook.Delivery{ID: "second", Endpoint: "/webhook", ReceivedAt: now.Add(time.Second)}
+
+	require.NoError(t, q.Push(first))
+	require.NoError(t, q.Push(second))
+
+	pending, inFlight := q.Depth()
+	assert.Equal(t, 2, pending)
+	assert.Equal(t, 0, inFlight)
+
+	// Entries are popped in order and stay on disk until acknowledged
+	entry, err := q.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, "first", entry.ID)
+	assert.Equal(t, first.Body, entry.Body)
+	pending, inFlight = q.Depth()
+	assert.Equal(t, 1, pending)
+	assert.Equal(t, 1, inFlight)
+	assert.FileExists(t, filepath.Join(dir, "first.json"))
+
+	require.NoError(t, q.Ack(entry.ID))
+	assert.NoFileExists(t, filepath.Join(dir, "first.json"))
+	pending, inFlight = q.Depth()
+	assert.Equal(t, 1, pending)
+	assert.Equal(t, 0, inFlight)
+
+	// Acknowledging twice is not an error
+	require.NoError(t, q.Ack(entry.ID))
+}
+
+func TestQueueReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	later := &webhook.Delivery{ID: "later", Endpoint: "/webhook", Body: []byte("b"), ReceivedAt: now.Add(time.Second)}
+	sooner := &webhook.Delivery{ID: "sooner", Endpoint: "/webhook", Body: []byte("a"), Metadata: map[string]string{"team": "payments"}, ReceivedAt: now}
+	require.NoError(t, q.Push(later))
+	require.NoError(t, q.Push(sooner))
+
+	// An entry popped but not acknowledged before the restart is queued again
+	popped, err := q.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, "later", popped.ID)
+
+	// A single process has the directory open at once
+	_, err = Open(dir)
+	assert.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, q.Close())
+
+	reopened, err := Open(dir)
+	require.NoError(t, err)
+	pending, _ := reopened.Depth()
+	assert.Equal(t, 2, pending)
+
+	entry, err := reopened.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, "sooner", entry.ID)
+	assert.Equal(t, sooner.Body, entry.Body)
+	assert.Equal(t, sooner.Metadata, entry.Metadata)
+	assert.True(t, sooner.ReceivedAt.Equal(entry.ReceivedAt))
+	entry, err = reopened.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, "later", entry.ID)
+}
+
+func TestQueuePopWaits(t *testing.T) {
+	q, err := Open(t.TempDir())
+	require.NoError(t, err)
+
+	popped := make(chan *webhook.Delivery)
+	go func() {
+		entry, _ := q.Pop()
+		popped <- entry
+	}()
+
+	select {
+	case <-popped:
+		t.Fatal("Pop returned from an empty queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	require.NoError(t, q.Push(&webhook.Delivery{ID: "entry", Endpoint: "/webhook", ReceivedAt: time.Now()}))
+	select {
+	case entry := <-popped:
+		assert.Equal(t, "entry", entry.ID)
+	case <-time.After(time.Second):
+		t.Fatal("Pop did not return the pushed entry")
+	}
+}
+
+func TestQueueOpenSkipsInvalidEntries(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "partial.123.tmp"), []byte("{"), 0o600))
+
+	q, err := Open(dir)
+	assert.Error(t, err)
+	require.NotNil(t, q)
+	pending, _ := q.Depth()
+	assert.Equal(t, 0, pending)
+
+	// Entries whose write was interrupted are removed
+	assert.NoFileExists(t, filepath.Join(dir, "partial.123.tmp"))
+}
+
+func TestQueueReadsEntriesOnPop(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Push(&webhook.Delivery{ID: "removed", Endpoint: "/webhook", ReceivedAt: time.Now()}))
+	require.NoError(t, q.Push(&webhook.Delivery{ID: "kept", Endpoint: "/webhook", Body: []byte("b"), ReceivedAt: time.Now()}))
+
+	// Entries are read from disk when popped, skipping those that can no longer be read
+	require.NoError(t, os.Remove(filepath.Join(dir, "removed.json")))
+	_, err = q.Pop()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	entry, err := q.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("b"), entry.Body)
+	_, inFlight := q.Depth()
+	assert.Equal(t, 1, inFlight)
+}
+
+func TestQueueClose(t *testing.T) {
+	q, err := Open(t.TempDir())
+	require.NoError(t, err)
+
+	// Close wakes the workers waiting for an entry
+	done := make(chan error)
+	go func() {
+		_, err := q.Pop()
+		done <- err
+	}()
+	require.NoError(t, q.Close())
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrClosed)
+	case <-time.After(time.Second):
+		t.Fatal("Pop did not return once the queue was closed")
+	}
+	require.NoError(t, q.Close())
+}
+
+func TestQueueLock(t *testing.T) {
+	dir := t.TempDir()
+	previous, err := Open(dir)
+	require.NoError(t, err)
+
+	// While another process has the directory locked, the queue only hands the entries
+	// pushed to it
+	q, err := Open(dir)
+	assert.ErrorIs(t, err, ErrLocked)
+	require.NotNil(t, q)
+	now := time.Now()
+	require.NoError(t, q.Push(&webhook.Delivery{ID: "own", Endpoint: "/webhook", ReceivedAt: now}))
+	entry, err := q.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, "own", entry.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Lock(ctx), context.DeadlineExceeded)
+
+	// Once unlocked, the entries left by the other process are queued, but not those being
+	// forwarded already
+	require.NoError(t, previous.Push(&webhook.Delivery{ID: "left", Endpoint: "/webhook", ReceivedAt: now.Add(-time.Second)}))
+	require.NoError(t, previous.Close())
+	require.NoError(t, q.Lock(context.Background()))
+	pending, inFlight := q.Depth()
+	assert.Equal(t, 1, pending)
+	assert.Equal(t, 1, inFlight)
+	entry, err = q.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, "left", entry.ID)
+
+	// Locking again is a no-op
+	require.NoError(t, q.Lock(context.Background()))
+	require.NoError(t, q.Close())
+}
@@ -0,0 +1,33 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockDir locks the queue directory through the given lock file, without waiting. The lock
+// is released when the returned file is closed, or when the process exits.
+func lockDir(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue lock: %w", err)
+	}
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, &windows.Overlapped{}); err != nil {
+		f.Close()
+		if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("failed to lock queue directory: %w", err)
+	}
+	return f, nil
+}
+
+// syncDir flushes the entries of a directory to disk. Windows cannot sync a directory, and
+// NTFS journals the rename itself.
+func syncDir(string) error {
+	return nil
+}
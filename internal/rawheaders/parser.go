@@ -0,0 +1,181 @@
+package rawheaders
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Limits past which a connection is no longer parsed, as net/http rejects such requests
+const (
+	// maxHeadBytes is http.DefaultMaxHeaderBytes, with the slack net/http allows
+	maxHeadBytes = 1<<20 + 4096
+
+	// maxLineBytes bounds the chunk size and trailer lines of chunked bodies
+	maxLineBytes = 4096
+)
+
+// parserState is the part of an HTTP/1.x message the parser is in
+type parserState int
+
+const (
+	stateHead parserState = iota
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateChunkEnd
+	stateTrailer
+	stateOff
+)
+
+// parser follows the requests of an HTTP/1.x connection: it collects the lines of each
+// request head, and skips bodies according to their framing. It stops parsing what it
+// cannot follow, such as upgraded connections, after which no more heads are captured.
+type parser struct {
+	state     parserState
+	line      []byte
+	lines     []string
+	headBytes int
+
+	// remaining is the number of bytes left in the body or chunk
+	remaining int64
+}
+
+// feed parses the bytes read, calling push for each complete request head
+func (p *parser) feed(data []byte, push func(head)) {
+	for len(data) > 0 && p.state != stateOff {
+		switch p.state {
+		case stateBody, stateChunkData:
+			n := int64(len(data))
+			if n > p.remaining {
+				n = p.remaining
+			}
+			data = data[n:]
+			p.remaining -= n
+			if p.remaining == 0 {
+				if p.state == stateBody {
+					p.state = stateHead
+				} else {
+					p.state = stateChunkEnd
+				}
+			}
+		default:
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				p.appendLine(data)
+				return
+			}
+			p.appendLine(data[:i])
+			data = data[i+1:]
+			if p.state != stateOff {
+				line := strings.TrimSuffix(string(p.line), "\r")
+				p.line = p.line[:0]
+				p.endLine(line, push)
+			}
+		}
+	}
+}
+
+// appendLine adds bytes to the current line, giving up on lines over the limits
+func (p *parser) appendLine(data []byte) {
+	p.line = append(p.line, data...)
+	limit := maxLineBytes
+	if p.state == stateHead {
+		p.headBytes += len(data) + 1
+		limit = maxHeadBytes
+		if p.headBytes > maxHeadBytes {
+			p.state = stateOff
+			return
+		}
+	}
+	if len(p.line) > limit {
+		p.state = stateOff
+	}
+}
+
+// endLine handles a complete line
+func (p *parser) endLine(line string, push func(head)) {
+	switch p.state {
+	case stateHead:
+		if line != "" {
+			p.lines = append(p.lines, line)
+			return
+		}
+		// Empty lines before a request line are ignored, as net/http does
+		if len(p.lines) == 0 {
+			p.headBytes = 0
+			return
+		}
+		p.endHead(push)
+	case stateChunkSize:
+		size, _, _ := strings.Cut(line, ";")
+		n, err := strconv.ParseInt(strings.TrimSpace(size), 16, 64)
+		switch {
+		case err != nil || n < 0:
+			p.state = stateOff
+		case n == 0:
+			p.state = stateTrailer
+		default:
+			p.state, p.remaining = stateChunkData, n
+		}
+	case stateChunkEnd:
+		p.state = stateChunkSize
+	case stateTrailer:
+		if line == "" {
+			p.state = stateHead
+		}
+	}
+}
+
+// endHead pushes the collected head and follows the framing of its body
+func (p *parser) endHead(push func(head)) {
+	h := head{requestLine: p.lines[0]}
+	var contentLength, transferEncoding string
+	upgrade := strings.HasPrefix(h.requestLine, "PRI ")
+	for _, line := range p.lines[1:] {
+		// Obsolete line folding continues the previous header
+		if (line[0] == ' ' || line[0] == '\t') && len(h.headers) > 0 {
+			h.headers[len(h.headers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		h.headers = append(h.headers, Header{Name: name, Value: value})
+
+		switch strings.ToLower(name) {
+		case "content-length":
+			if contentLength == "" {
+				contentLength = value
+			}
+		case "transfer-encoding":
+			transferEncoding = strings.ToLower(value)
+		case "upgrade":
+			upgrade = true
+		}
+	}
+	push(h)
+
+	p.lines, p.headBytes = p.lines[:0], 0
+	switch {
+	case upgrade:
+		// The connection may switch protocols, what follows is not followed
+		p.state = stateOff
+	case transferEncoding != "":
+		if !strings.HasSuffix(transferEncoding, "chunked") {
+			p.state = stateOff
+			return
+		}
+		p.state = stateChunkSize
+	case contentLength != "":
+		n, err := strconv.ParseInt(contentLength, 10, 64)
+		switch {
+		case err != nil || n < 0:
+			p.state = stateOff
+		case n > 0:
+			p.state, p.remaining = stateBody, n
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package rawheaders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parse feeds the stream to a parser in chunks of the given size and returns the heads
+func parse(stream string, chunk int) ([]head, parserState) {
+	var p parser
+	var heads []head
+	data := []byte(stream)
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		p.feed(data[:n], func(h head) { heads = append(heads, h) })
+		data = data[n:]
+	}
+	return heads, p.state
+}
+
+func TestParser(t *testing.T) {
+	stream := "POST /webhook HTTP/1.1\r\nHost: proxy\r\nx-github-event: push\r\nContent-Length: 9\r\nX-Hub-Signature-256: sha256=ab\r\n\r\n" +
+		`{"a":"b"}` +
+		"POST /webhook?retry=1 HTTP/1.1\r\nHost: proxy\r\nTransfer-Encoding: chunked\r\nX-Folded: first\r\n\tsecond\r\n\r\n" +
+		"4;ext=1\r\nPOST\r\n5\r\n /x \r\n0\r\nX-Trailer: done\r\n\r\n" +
+		"\r\nGET /health HTTP/1.1\r\nhost: proxy\r\n\r\n"
+
+	for _, chunk := range []int{1, 7, len(stream)} {
+		heads, state := parse(stream, chunk)
+		assert.Equal(t, stateHead, state)
+		if !assert.Len(t, heads, 3, "chunk size %d", chunk) {
+			continue
+		}
+
+		assert.Equal(t, "POST /webhook HTTP/1.1", heads[0].requestLine)
+		assert.Equal(t, []Header{
+			{Name: "Host", Value: "proxy"},
+			{Name: "x-github-event", Value: "push"},
+			{Name: "Content-Length", Value: "9"},
+			{Name: "X-Hub-Signature-256", Value: "sha256=ab"},
+		}, heads[0].headers)
+
+		assert.Equal(t, "POST /webhook?retry=1 HTTP/1.1", heads[1].requestLine)
+		assert.Equal(t, Header{Name: "X-Folded", Value: "first second"}, heads[1].headers[2])
+
+		assert.Equal(t, "GET /health HTTP/1.1", heads[2].requestLine)
+		assert.Equal(t, []Header{{Name: "host", Value: "proxy"}}, heads[2].headers)
+	}
+}
+
+func TestParserStops(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+	}{
+		{"upgrade", "GET /ws HTTP/1.1\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n\x81\x05hello"},
+		{"http2 preface", "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"},
+		{"invalid content length", "POST / HTTP/1.1\r\nContent-Length: ten\r\n\r\n"},
+		{"unknown transfer encoding", "POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n"},
+		{"invalid chunk size", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, state := parse(tt.stream+"POST / HTTP/1.1\r\n\r\n", 5)
+			assert.Equal(t, stateOff, state)
+		})
+	}
+}
+
+func TestParserHeadLimit(t *testing.T) {
+	var p parser
+	pushed := false
+	p.feed([]byte("POST / HTTP/1.1\r\nX-Large: "), func(head) { pushed = true })
+	large := make([]byte, maxHeadBytes)
+	for i := range large {
+		large[i] = 'a'
+	}
+	p.feed(large, func(head) { pushed = true })
+	p.feed([]byte("\r\n\r\n"), func(head) { pushed = true })
+	assert.False(t, pushed)
+	assert.Equal(t, stateOff, p.state)
+}
@@ -0,0 +1,142 @@
+// Package rawheaders captures the request headers of HTTP/1.x connections as they were
+// sent, with the case and order of their names, which net/http canonicalizes and stores in
+// a map. Connections of a wrapped listener parse the request heads they read; a middleware
+// hands each request its head.
+package rawheaders
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxQueuedHeads bounds the heads read ahead of their requests on a connection, such as
+// pipelined requests; older ones are dropped
+const maxQueuedHeads = 16
+
+// Header is a request header as sent
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// head is a captured request head
+type head struct {
+	requestLine string
+	headers     []Header
+}
+
+// contextKey keys the connection and the captured headers in contexts
+type contextKey int
+
+const (
+	connKey contextKey = iota
+	headersKey
+)
+
+// ListenAndServe serves HTTP on the address like http.ListenAndServe, capturing the raw
+// headers of the requests
+func ListenAndServe(addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: handler, ConnContext: ConnContext}
+	return server.Serve(Listener(listener))
+}
+
+// Listener wraps a listener so that its connections capture the heads of the requests
+// read from them. The server must set ConnContext as its ConnContext.
+func Listener(l net.Listener) net.Listener {
+	return &listener{Listener: l}
+}
+
+// ConnContext adds a capturing connection to the context of its requests, for the
+// Middleware. Other connections leave the context unchanged.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	if rc, ok := c.(*conn); ok {
+		return context.WithValue(ctx, connKey, rc)
+	}
+	return ctx
+}
+
+// Middleware hands each request of a capturing connection its raw headers, available
+// with FromContext. It must handle every request of the connections, so that heads and
+// requests stay paired.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := r.Context().Value(connKey).(*conn)
+		if ok && r.ProtoMajor == 1 {
+			if headers, ok := c.pop(r.Method + " " + r.RequestURI + " "); ok {
+				r = r.WithContext(context.WithValue(r.Context(), headersKey, headers))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FromContext returns the raw headers of the request, in the order they were sent. They
+// are missing for requests not read from a capturing listener, such as HTTP/2 requests.
+func FromContext(ctx context.Context) ([]Header, bool) {
+	headers, ok := ctx.Value(headersKey).([]Header)
+	return headers, ok
+}
+
+// listener wraps the connections it accepts
+type listener struct {
+	net.Listener
+}
+
+// Accept wraps the accepted connection
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c}, nil
+}
+
+// conn parses the request heads of the bytes read from a connection
+type conn struct {
+	net.Conn
+
+	mu     sync.Mutex
+	parser parser
+	heads  []head
+}
+
+// Read reads from the connection and parses what was read
+func (c *conn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.mu.Lock()
+		c.parser.feed(p[:n], c.push)
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// push queues a parsed head; called with the lock held
+func (c *conn) push(h head) {
+	if len(c.heads) == maxQueuedHeads {
+		c.heads = c.heads[1:]
+	}
+	c.heads = append(c.heads, h)
+}
+
+// pop returns the headers of the oldest queued head starting with the request line prefix,
+// dropping the heads before it
+func (c *conn) pop(prefix string) ([]Header, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.heads) > 0 {
+		h := c.heads[0]
+		c.heads = c.heads[1:]
+		if strings.HasPrefix(h.requestLine, prefix) {
+			return h.headers, true
+		}
+	}
+	return nil, false
+}
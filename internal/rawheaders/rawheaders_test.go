@@ -0,0 +1,87 @@
+package rawheaders
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	captured := make(chan []Header, 3)
+	server := httptest.NewUnstartedServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		headers, ok := FromContext(r.Context())
+		if ok {
+			captured <- headers
+		} else {
+			captured <- nil
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+	server.Listener = Listener(server.Listener)
+	server.Config.ConnContext = ConnContext
+	server.Start()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// Requests sent on the same connection each get their own head
+	send := func(request string) {
+		_, err := conn.Write([]byte(request))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(reader, nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	send("POST /webhook HTTP/1.1\r\nhost: proxy\r\nX-GitHub-Event: push\r\ncontent-length: 2\r\n\r\n{}")
+	send("POST /webhook HTTP/1.1\r\nHost: proxy\r\nx-b: 2\r\nx-a: 1\r\nContent-Length: 0\r\n\r\n")
+
+	assert.Equal(t, []Header{
+		{Name: "host", Value: "proxy"},
+		{Name: "X-GitHub-Event", Value: "push"},
+		{Name: "content-length", Value: "2"},
+	}, <-captured)
+	assert.Equal(t, []Header{
+		{Name: "Host", Value: "proxy"},
+		{Name: "x-b", Value: "2"},
+		{Name: "x-a", Value: "1"},
+		{Name: "Content-Length", Value: "0"},
+	}, <-captured)
+}
+
+func TestFromContextWithoutCapture(t *testing.T) {
+	var ok bool
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok = FromContext(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhook", nil))
+	assert.False(t, ok)
+}
+
+func TestPopSkipsStaleHeads(t *testing.T) {
+	c := &conn{}
+	c.push(head{requestLine: "GET /health HTTP/1.1"})
+	c.push(head{requestLine: "POST /webhook HTTP/1.1", headers: []Header{{Name: "x-a", Value: "1"}}})
+
+	headers, ok := c.pop("POST /webhook ")
+	assert.True(t, ok)
+	assert.Equal(t, []Header{{Name: "x-a", Value: "1"}}, headers)
+
+	_, ok = c.pop("POST /webhook ")
+	assert.False(t, ok)
+
+	for i := 0; i < maxQueuedHeads+1; i++ {
+		c.push(head{requestLine: "GET /health HTTP/1.1"})
+	}
+	assert.Len(t, c.heads, maxQueuedHeads)
+}
@@ -0,0 +1,170 @@
+// Package redact provides helpers to mask sensitive data in payloads and headers
+package redact
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Mask is the value that replaces redacted data
+const Mask = "[REDACTED]"
+
+// DefaultHeaders lists the headers that are always redacted
+var DefaultHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+}
+
+// Headers returns a copy of the headers with the default headers and the given names masked
+func Headers(headers map[string]string, names []string) map[string]string {
+	masked := make(map[string]bool, len(DefaultHeaders)+len(names))
+	for _, name := range DefaultHeaders {
+		masked[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range names {
+		masked[http.CanonicalHeaderKey(name)] = true
+	}
+
+	result := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if masked[http.CanonicalHeaderKey(k)] {
+			result[k] = Mask
+			continue
+		}
+		result[k] = v
+	}
+
+	return result
+}
+
+// JSON masks the given fields in a JSON body. Fields are dot-separated paths
+// into nested objects (e.g. "customer.email"). Bodies that are not JSON objects
+// are returned unchanged.
+func JSON(body []byte, fields []string) []byte {
+	if len(fields) == 0 {
+		return body
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body
+	}
+	return ParsedJSON(body, payload, fields)
+}
+
+// ParsedJSON masks the given fields like JSON, in a body already parsed into payload,
+// saving a second parse. The payload, which may be shared, is left untouched. A nil
+// payload, for bodies that are not JSON objects, returns the body unchanged.
+func ParsedJSON(body []byte, payload map[string]interface{}, fields []string) []byte {
+	if len(fields) == 0 || payload == nil {
+		return body
+	}
+
+	for _, field := range fields {
+		payload, _ = maskField(payload, strings.Split(field, "."))
+	}
+
+	result, err := json.Marshal(payload)
+	if err != nil {
+		return body
+	}
+
+	return result
+}
+
+// Values masks every value of a JSON body, keeping its keys and structure only. Bodies
+// that are not JSON are masked whole.
+func Values(body []byte) []byte {
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		payload = Mask
+	}
+
+	result, err := json.Marshal(maskValues(payload))
+	if err != nil {
+		return []byte(`"` + Mask + `"`)
+	}
+	return result
+}
+
+// maskValues returns a copy of a decoded JSON value with its scalar values masked
+func maskValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			masked[key] = maskValues(nested)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, nested := range v {
+			masked[i] = maskValues(nested)
+		}
+		return masked
+	case nil:
+		return nil
+	default:
+		return Mask
+	}
+}
+
+// Form masks the given fields in a URL-encoded form body. Fields are matched against the
+// decoded field names, and the other fields keep their order and encoding. It returns false
+// for bodies that are not valid forms.
+func Form(body []byte, fields []string) ([]byte, bool) {
+	if len(fields) == 0 {
+		return body, true
+	}
+
+	masked := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		masked[field] = true
+	}
+
+	pairs := strings.Split(string(body), "&")
+	for i, pair := range pairs {
+		rawKey, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil || strings.Contains(rawKey, ";") {
+			return nil, false
+		}
+		if masked[key] {
+			pairs[i] = rawKey + "=" + Mask
+		}
+	}
+
+	return []byte(strings.Join(pairs, "&")), true
+}
+
+// maskField returns the decoded JSON object with the value at the given path masked, and
+// whether it was found. The objects along the path are copied before they are changed and
+// the rest is shared, so that the object passed in is never modified.
+func maskField(obj map[string]interface{}, path []string) (map[string]interface{}, bool) {
+	value, exists := obj[path[0]]
+	if !exists {
+		return obj, false
+	}
+
+	var masked interface{} = Mask
+	if len(path) > 1 {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return obj, false
+		}
+		if masked, ok = maskField(nested, path[1:]); !ok {
+			return obj, false
+		}
+	}
+
+	copied := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		copied[k] = v
+	}
+	copied[path[0]] = masked
+	return copied, true
+}
@@ -0,0 +1,118 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaders(t *testing.T) {
+	headers := map[string]string{
+		"authorization":   "Bearer secret",
+		"X-Api-Key":       "key",
+		"X-Custom-Header": "custom-value",
+	}
+
+	result := Headers(headers, []string{"x-api-key"})
+
+	assert.Equal(t, Mask, result["authorization"])
+	assert.Equal(t, Mask, result["X-Api-Key"])
+	assert.Equal(t, "custom-value", result["X-Custom-Header"])
+
+	// The original headers must not be modified
+	assert.Equal(t, "Bearer secret", headers["authorization"])
+}
+
+func TestJSON(t *testing.T) {
+	body := []byte(`{"event":"push","customer":{"email":"a@example.com","id":1},"token":"secret"}`)
+
+	result := JSON(body, []string{"customer.email", "token", "missing.field"})
+
+	var payload map[string]interface{}
+	assert.NoError(t, json.Unmarshal(result, &payload))
+	assert.Equal(t, "push", payload["event"])
+	assert.Equal(t, Mask, payload["token"])
+
+	customer, ok := payload["customer"].(map[string]interface{})
+	assert.True(t, ok, "customer should be an object")
+	assert.Equal(t, Mask, customer["email"])
+	assert.Equal(t, float64(1), customer["id"])
+}
+
+func TestJSONUnchanged(t *testing.T) {
+	// No fields to redact
+	body := []byte(`{"event":"push"}`)
+	assert.Equal(t, body, JSON(body, nil))
+
+	// Not a JSON object
+	body = []byte(`not json`)
+	assert.Equal(t, body, JSON(body, []string{"token"}))
+}
+
+func TestValues(t *testing.T) {
+	body := []byte(`{"event":"push","customer":{"email":"a@example.com","id":1},"tags":["a",true],"deleted":null}`)
+	assert.JSONEq(t, `{"event":"[REDACTED]","customer":{"email":"[REDACTED]","id":"[REDACTED]"},"tags":["[REDACTED]","[REDACTED]"],"deleted":null}`, string(Values(body)))
+
+	// Bodies that are not JSON are masked whole
+	assert.Equal(t, `"[REDACTED]"`, string(Values([]byte(`not json`))))
+}
+
+func TestParsedJSON(t *testing.T) {
+	body := []byte(`{"event":"push","customer":{"email":"a@example.com","id":1},"token":"secret"}`)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &payload))
+
+	result := ParsedJSON(body, payload, []string{"customer.email", "token", "customer.missing", "event.nested"})
+	assert.JSONEq(t, `{"event":"push","customer":{"email":"[REDACTED]","id":1},"token":"[REDACTED]"}`, string(result))
+
+	// The parsed payload is shared with other readers and must not be modified
+	assert.Equal(t, "secret", payload["token"])
+	assert.Equal(t, "a@example.com", payload["customer"].(map[string]interface{})["email"])
+
+	// Bodies that are not JSON objects are returned unchanged
+	assert.Equal(t, []byte(`[1]`), ParsedJSON([]byte(`[1]`), nil, []string{"token"}))
+}
+
+func TestForm(t *testing.T) {
+	body := []byte(`event=push&password=secret&user%5Btoken%5D=abc&password=again&note=a+b`)
+
+	result, ok := Form(body, []string{"password", "user[token]", "missing"})
+	require.True(t, ok)
+	assert.Equal(t, `event=push&password=[REDACTED]&user%5Btoken%5D=[REDACTED]&password=[REDACTED]&note=a+b`, string(result))
+
+	// No fields to redact
+	result, ok = Form(body, nil)
+	require.True(t, ok)
+	assert.Equal(t, body, result)
+
+	// Invalid forms cannot be redacted
+	_, ok = Form([]byte(`password=secret&%zz=1`), []string{"password"})
+	assert.False(t, ok)
+}
+
+// BenchmarkParsedJSON masks a large payload already parsed, as for a webhook whose body
+// is parsed once and read by several components
+func BenchmarkParsedJSON(b *testing.B) {
+	payload := map[string]interface{}{"token": "secret", "customer": map[string]interface{}{"email": "a@example.com"}}
+	items := make([]interface{}, 1000)
+	for i := range items {
+		items[i] = map[string]interface{}{"id": i, "name": "item"}
+	}
+	payload["items"] = items
+	body, err := json.Marshal(payload)
+	require.NoError(b, err)
+	fields := []string{"token", "customer.email"}
+
+	b.Run("parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			JSON(body, fields)
+		}
+	})
+	b.Run("parsed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ParsedJSON(body, payload, fields)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+// Package retrystore persists the state of deliveries waiting for a retry,
+// so that they can be resumed after a restart
+package retrystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// fileExtension is the extension of record files
+const fileExtension = ".json"
+
+// Record is the state of a delivery waiting for a retry
+type Record struct {
+	ID            string            `json:"id"`
+	WebhookID     string            `json:"webhook_id,omitempty"`
+	Endpoint      string            `json:"endpoint"`
+	Destination   string            `json:"destination"`
+	Body          []byte            `json:"body"`
+	Headers       map[string]string `json:"headers"`
+	Attempt       int               `json:"attempt"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+
+	// ReceivedAt is when the webhook was received, zero for deliveries persisted before it was recorded
+	ReceivedAt time.Time `json:"received_at,omitzero"`
+}
+
+// Store keeps one file per pending delivery in a directory
+type Store struct {
+	dir string
+}
+
+// Open opens the store in the given directory, creating it if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create retry state directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Save writes the record, replacing any previous state of the same delivery
+func (s *Store) Save(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode retry state: %w", err)
+	}
+
+	// Write to a temporary file first so that a crash never leaves a partial record
+	tmp, err := os.CreateTemp(s.dir, record.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write retry state: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write retry state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write retry state: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(record.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write retry state: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the state of a delivery. Deleting an unknown delivery is not an error.
+func (s *Store) Delete(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete retry state: %w", err)
+	}
+	return nil
+}
+
+// Load returns the stored records, ordered by next attempt time.
+// Unreadable records are skipped and reported in the returned error.
+func (s *Store) Load() ([]Record, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retry state directory: %w", err)
+	}
+
+	var records []Record
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileExtension) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].NextAttemptAt.Before(records[j].NextAttemptAt)
+	})
+
+	return records, errors.Join(errs...)
+}
+
+// storedFile describes a record file for compaction
+type storedFile struct {
+	name     string
+	endpoint string
+	size     int64
+	modTime  time.Time
+}
+
+// Compact removes the records beyond the retention limits, oldest first, and returns
+// how many were removed. A record's age is the time since it was last saved.
+// Unreadable records count towards the total size and are removed like any other.
+func (s *Store) Compact(retention config.RetentionConfig, now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read retry state directory: %w", err)
+	}
+
+	var files []storedFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileExtension) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		file := storedFile{name: entry.Name(), size: info.Size(), modTime: info.ModTime()}
+
+		var record Record
+		if data, err := os.ReadFile(filepath.Join(s.dir, entry.Name())); err == nil && json.Unmarshal(data, &record) == nil {
+			file.endpoint = record.Endpoint
+		}
+		files = append(files, file)
+	}
+
+	// Newest first, so that the records kept are at the start
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	var kept []storedFile
+	var expired []storedFile
+	counts := make(map[string]int)
+	for _, file := range files {
+		switch {
+		case retention.MaxAge > 0 && now.Sub(file.modTime) > retention.MaxAge:
+			expired = append(expired, file)
+		case retention.MaxCountPerEndpoint > 0 && counts[file.endpoint] >= retention.MaxCountPerEndpoint:
+			expired = append(expired, file)
+		default:
+			counts[file.endpoint]++
+			kept = append(kept, file)
+		}
+	}
+
+	if retention.MaxTotalSize > 0 {
+		var total int64
+		for i, file := range kept {
+			total += file.size
+			if total > retention.MaxTotalSize {
+				expired = append(expired, kept[i:]...)
+				break
+			}
+		}
+	}
+
+	var errs []error
+	removed := 0
+	for _, file := range expired {
+		if err := os.Remove(filepath.Join(s.dir, file.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to delete retry state: %w", err))
+			continue
+		}
+		removed++
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// path returns the file of a record
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+fileExtension)
+}
@@ -0,0 +1,138 @@
+package retrystore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "retries"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	later := Record{
+		ID:            "later",
+		Endpoint:      "/webhook",
+		Destination:   "https://example.com",
+		Body:          []byte(`{"event":"push"}`),
+		Headers:       map[string]string{"Content-Type": "application/json"},
+		Attempt:       1,
+		NextAttemptAt: now.Add(time.Minute),
+	}
+	sooner := later
+	sooner.ID = "sooner"
+	sooner.NextAttemptAt = now.Add(time.Second)
+
+	require.NoError(t, store.Save(later))
+	require.NoError(t, store.Save(sooner))
+
+	// Saving again replaces the state
+	later.Attempt = 2
+	require.NoError(t, store.Save(later))
+
+	records, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "sooner", records[0].ID)
+	assert.Equal(t, later.ID, records[1].ID)
+	assert.Equal(t, 2, records[1].Attempt)
+	assert.Equal(t, later.Body, records[1].Body)
+	assert.Equal(t, later.Headers, records[1].Headers)
+	assert.True(t, later.NextAttemptAt.Equal(records[1].NextAttemptAt))
+
+	// Deleted records are not loaded, deleting twice is not an error
+	require.NoError(t, store.Delete("sooner"))
+	require.NoError(t, store.Delete("sooner"))
+	records, err = store.Load()
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestStoreLoadSkipsInvalidRecords(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Save(Record{ID: "valid", NextAttemptAt: time.Now()}))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.json"), []byte("{"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "leftover.123.tmp"), []byte("{"), 0o600))
+
+	records, err := store.Load()
+	assert.Error(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "valid", records[0].ID)
+}
+
+// saveAged saves a record last updated at the given time
+func saveAged(t *testing.T, store *Store, record Record, modTime time.Time) {
+	require.NoError(t, store.Save(record))
+	require.NoError(t, os.Chtimes(store.path(record.ID), modTime, modTime))
+}
+
+// storedIDs returns the IDs of the stored records
+func storedIDs(t *testing.T, store *Store) []string {
+	records, err := store.Load()
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+func TestStoreCompact(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		retention config.RetentionConfig
+		expected  []string
+	}{
+		{name: "No limits", retention: config.RetentionConfig{}, expected: []string{"a1", "a2", "a3", "b1"}},
+		{name: "Max age", retention: config.RetentionConfig{MaxAge: 90 * time.Minute}, expected: []string{"a1", "b1"}},
+		{name: "Max count per endpoint", retention: config.RetentionConfig{MaxCountPerEndpoint: 2}, expected: []string{"a1", "a2", "b1"}},
+		{name: "Max total size", retention: config.RetentionConfig{MaxTotalSize: 350}, expected: []string{"a1", "b1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := Open(t.TempDir())
+			require.NoError(t, err)
+
+			// Records of about 150 bytes, a1 being the most recently saved
+			saveAged(t, store, Record{ID: "a1", Endpoint: "/a", NextAttemptAt: now}, now.Add(-time.Hour))
+			saveAged(t, store, Record{ID: "a2", Endpoint: "/a", NextAttemptAt: now.Add(time.Second)}, now.Add(-2*time.Hour))
+			saveAged(t, store, Record{ID: "a3", Endpoint: "/a", NextAttemptAt: now.Add(2 * time.Second)}, now.Add(-3*time.Hour))
+			saveAged(t, store, Record{ID: "b1", Endpoint: "/b", NextAttemptAt: now.Add(3 * time.Second)}, now.Add(-time.Minute))
+
+			removed, err := store.Compact(tt.retention, now)
+			require.NoError(t, err)
+			assert.Equal(t, 4-len(tt.expected), removed)
+			assert.ElementsMatch(t, tt.expected, storedIDs(t, store))
+		})
+	}
+}
+
+func TestStoreCompactInvalidRecords(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.json"), []byte("{"), 0o600))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "invalid.json"), old, old))
+	saveAged(t, store, Record{ID: "valid", Endpoint: "/a"}, time.Now())
+
+	removed, err := store.Compact(config.RetentionConfig{MaxAge: time.Minute}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, filepath.Join(dir, "invalid.json"))
+	assert.Equal(t, []string{"valid"}, storedIDs(t, store))
+}
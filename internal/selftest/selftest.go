@@ -0,0 +1,299 @@
+// Package selftest sends a synthetic webhook through every endpoint of a configuration,
+// with the destinations replaced by an in-process mock, and reports which deliveries
+// arrived. It is a smoke test for CI images and configuration changes.
+package selftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/provider"
+	"github.com/flemzord/webhook-proxy/internal/server"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTimeout bounds the wait for the synthetic webhooks to reach the mock destinations
+const DefaultTimeout = 10 * time.Second
+
+// idHeader identifies the synthetic webhook of an endpoint; the proxy forwards it to the destinations
+const idHeader = "X-Selftest-Id"
+
+// pollInterval is the period between two checks of the mock destinations
+const pollInterval = 10 * time.Millisecond
+
+// Statuses of a destination in a report
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Result is the outcome of the synthetic webhook of an endpoint for one of its destinations
+type Result struct {
+	Endpoint    string
+	Destination string
+	Status      string
+	Detail      string
+}
+
+// Report lists the results of a self-test
+type Report struct {
+	Results []Result
+}
+
+// Passed reports whether no destination failed
+func (r *Report) Passed() bool {
+	for _, result := range r.Results {
+		if result.Status == StatusFail {
+			return false
+		}
+	}
+	return true
+}
+
+// Write prints one line per result, followed by a summary
+func (r *Report) Write(w io.Writer) {
+	counts := make(map[string]int)
+	for _, result := range r.Results {
+		counts[result.Status]++
+
+		line := fmt.Sprintf("%-4s %s -> %s", strings.ToUpper(result.Status), result.Endpoint, result.Destination)
+		if result.Detail != "" {
+			line += " (" + result.Detail + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "%d passed, %d failed, %d skipped\n", counts[StatusPass], counts[StatusFail], counts[StatusSkip])
+}
+
+// target is a destination expected to receive the synthetic webhook of an endpoint
+type target struct {
+	destination string
+	mockPath    string
+	filtered    bool
+}
+
+// Run boots the proxy in process with the configuration, its destinations replaced by a
+// mock, sends a synthetic webhook signed with its secret to every endpoint and waits up to
+// the timeout for each destination to receive it. Destinations with filters are skipped,
+// as the synthetic webhook is not expected to match them.
+func Run(cfg *config.Config, log *logrus.Logger, timeout time.Duration) (*Report, error) {
+	mock := newMockDestinationServer()
+	mockServer := httptest.NewServer(mock)
+	defer mockServer.Close()
+
+	testCfg, targets := prepare(cfg, mockServer.URL, timeout)
+
+	harness, err := server.NewHarness(testCfg, log)
+	if err != nil {
+		return nil, err
+	}
+	defer harness.Close()
+
+	// Send the synthetic webhooks
+	ids := make([]string, len(testCfg.Endpoints))
+	statuses := make([]int, len(testCfg.Endpoints))
+	for i, endpoint := range testCfg.Endpoints {
+		ids[i] = uuid.NewString()
+		req, err := newRequest(endpoint, ids[i])
+		if err != nil {
+			return nil, err
+		}
+
+		w := httptest.NewRecorder()
+		harness.ServeHTTP(w, req)
+		statuses[i] = w.Code
+	}
+
+	// Wait for the destinations of the accepted webhooks
+	deadline := time.Now().Add(timeout)
+	report := &Report{}
+	for i, endpoint := range testCfg.Endpoints {
+		accepted := statuses[i] >= 200 && statuses[i] < 300
+		for _, t := range targets[i] {
+			result := Result{Endpoint: endpoint.Path, Destination: t.destination}
+			switch {
+			case !accepted:
+				result.Status = StatusFail
+				result.Detail = fmt.Sprintf("endpoint answered %d", statuses[i])
+			case t.filtered:
+				result.Status = StatusSkip
+				result.Detail = "destination has filters"
+			case mock.wait(t.mockPath, ids[i], deadline):
+				result.Status = StatusPass
+			default:
+				result.Status = StatusFail
+				result.Detail = "webhook not received within " + timeout.String()
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	return report, nil
+}
+
+// prepare copies the configuration with every destination replaced by a path of the mock,
+// and the side effects of a real run (persisted retries, dead letters and queue, recording,
+// statistics, accounting, receipts, quotas, alerts, startup probes) disabled. Endpoints of a pipeline share their
+// mock paths, as they share their destinations.
+func prepare(cfg *config.Config, mockURL string, timeout time.Duration) (*config.Config, [][]target) {
+	testCfg := *cfg
+	testCfg.Server.Startup = config.StartupConfig{}
+	testCfg.Server.Prewarm = config.PrewarmConfig{}
+	testCfg.Server.CrashReports = config.CrashReportConfig{}
+	testCfg.RetryState = config.RetryStateConfig{}
+	testCfg.DeadLetters = config.DeadLetterConfig{}
+	testCfg.Queue = config.QueueConfig{}
+	testCfg.Recording = config.RecordingConfig{}
+	testCfg.Stats = config.StatsConfig{}
+	testCfg.Accounting = config.AccountingConfig{}
+	testCfg.Alerts = nil
+	testCfg.Telemetry.Enabled = false
+
+	paths := make(map[string]string)
+	targets := make([][]target, len(cfg.Endpoints))
+	testCfg.Endpoints = make([]config.EndpointConfig, len(cfg.Endpoints))
+	for i, endpoint := range cfg.Endpoints {
+		endpoint.CallbackURL = ""
+		endpoint.Quota = nil
+
+		key := endpoint.Path
+		if endpoint.Pipeline != "" {
+			key = endpoint.Pipeline
+		}
+
+		destinations := make([]config.DestinationConfig, len(endpoint.Destinations))
+		for j, dest := range endpoint.Destinations {
+			id := key + "#" + strconv.Itoa(j)
+			path, ok := paths[id]
+			if !ok {
+				path = "/" + strconv.Itoa(len(paths))
+				paths[id] = path
+			}
+
+			targets[i] = append(targets[i], target{
+				destination: dest.Key(),
+				mockPath:    path,
+				filtered:    len(dest.Filters) > 0 || dest.Events != nil,
+			})
+
+			destinations[j] = mockDestination(dest, mockURL+path, timeout)
+		}
+		endpoint.Destinations = destinations
+		testCfg.Endpoints[i] = endpoint
+	}
+
+	return &testCfg, targets
+}
+
+// mockDestination returns an HTTP destination sending to the mock, keeping the settings
+// that shape the request and dropping those that depend on the real destination
+func mockDestination(dest config.DestinationConfig, url string, timeout time.Duration) config.DestinationConfig {
+	mock := dest
+	// Slack destinations keep their type, to send the mock the messages Slack would receive
+	if !mock.SendsHTTP() {
+		mock.Type = config.DestinationTypeHTTP
+	}
+	mock.URL = url
+	mock.WebSocket = nil
+	mock.Database = nil
+	mock.S3 = nil
+	mock.Kafka = nil
+	mock.SQS = nil
+	mock.SNS = nil
+	mock.Timeout = timeout
+	mock.Retries = 0
+	mock.MaxDeliveryDuration = 0
+	mock.Success = nil
+	mock.Concurrency = nil
+	mock.Chaos = nil
+	mock.Cache = nil
+	mock.DNSOutage = nil
+	mock.LocalAddress = ""
+	mock.Proxy = nil
+	mock.MaxBodySize = 0
+	if mock.Method == "" {
+		mock.Method = config.DefaultMethod
+	}
+	return mock
+}
+
+// newRequest builds the synthetic webhook of an endpoint, signed as its provider would
+func newRequest(endpoint config.EndpointConfig, id string) (*http.Request, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"event_id": id,
+		"type":     "selftest",
+		"event":    map[string]string{"type": "selftest"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode synthetic webhook: %w", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, endpoint.Path, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idHeader, id)
+
+	if preset, ok := provider.Get(endpoint.Provider); ok {
+		if preset.DeliveryIDHeader != "" {
+			req.Header.Set(preset.DeliveryIDHeader, id)
+		}
+		if preset.EventTypeHeader != "" {
+			req.Header.Set(preset.EventTypeHeader, "selftest")
+		}
+		preset.Sign(endpoint.Secret, body, req.Header)
+	}
+	if endpoint.Auth != nil {
+		provider.Auth(*endpoint.Auth).Sign(endpoint.Auth.Secret, body, req.Header)
+	}
+
+	return req, nil
+}
+
+// mockDestinationServer records the synthetic webhooks received on each path
+type mockDestinationServer struct {
+	mu       sync.Mutex
+	received map[string]bool // path + " " + id
+}
+
+// newMockDestinationServer creates an empty mock
+func newMockDestinationServer() *mockDestinationServer {
+	return &mockDestinationServer{received: make(map[string]bool)}
+}
+
+// ServeHTTP records the webhook and accepts it
+func (m *mockDestinationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+
+	m.mu.Lock()
+	m.received[r.URL.Path+" "+r.Header.Get(idHeader)] = true
+	m.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// wait reports whether the webhook reaches the path before the deadline
+func (m *mockDestinationServer) wait(path, id string, deadline time.Time) bool {
+	for {
+		m.mu.Lock()
+		ok := m.received[path+" "+id]
+		m.mu.Unlock()
+
+		if ok {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(pollInterval)
+	}
+}
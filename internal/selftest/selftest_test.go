@@ -0,0 +1,67 @@
+package selftest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	shared := []config.DestinationConfig{{URL: "https://billing.example.com/events", Method: "POST"}}
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path:     "/webhook/github",
+				Provider: config.ProviderGitHub,
+				Secret:   "s3cr3t",
+				Destinations: []config.DestinationConfig{
+					{URL: "https://unreachable.invalid/github", Method: "POST", Retries: 5, Success: &config.SuccessConfig{BodyContains: "ok"}},
+					{Type: config.DestinationTypeWebSocket, WebSocket: &config.WebSocketConfig{Path: "/live/github"}},
+					{URL: "https://example.com/xml", Method: "POST", Filters: []config.FilterConfig{{XPath: "/event"}}},
+				},
+				CallbackURL: "https://unreachable.invalid/receipts",
+			},
+			{Path: "/webhook/stripe", Provider: config.ProviderStripe, Secret: "whsec", Pipeline: "billing", Destinations: shared},
+			{Path: "/webhook/paddle", Pipeline: "billing", Destinations: shared},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	report, err := Run(cfg, log, 2*time.Second)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Result{
+		{Endpoint: "/webhook/github", Destination: "https://unreachable.invalid/github", Status: StatusPass},
+		{Endpoint: "/webhook/github", Destination: "websocket:/live/github", Status: StatusPass},
+		{Endpoint: "/webhook/github", Destination: "https://example.com/xml", Status: StatusSkip, Detail: "destination has filters"},
+		{Endpoint: "/webhook/stripe", Destination: "https://billing.example.com/events", Status: StatusPass},
+		{Endpoint: "/webhook/paddle", Destination: "https://billing.example.com/events", Status: StatusPass},
+	}, report.Results)
+	assert.True(t, report.Passed())
+
+	// The configuration itself is left untouched
+	assert.Equal(t, "https://unreachable.invalid/github", cfg.Endpoints[0].Destinations[0].URL)
+	assert.Equal(t, "https://unreachable.invalid/receipts", cfg.Endpoints[0].CallbackURL)
+}
+
+func TestReportWrite(t *testing.T) {
+	report := &Report{Results: []Result{
+		{Endpoint: "/webhook/github", Destination: "https://example.com/github", Status: StatusPass},
+		{Endpoint: "/webhook/stripe", Destination: "https://example.com/stripe", Status: StatusFail, Detail: "endpoint answered 401"},
+	}}
+	assert.False(t, report.Passed())
+
+	var out bytes.Buffer
+	report.Write(&out)
+	assert.Equal(t, "PASS /webhook/github -> https://example.com/github\n"+
+		"FAIL /webhook/stripe -> https://example.com/stripe (endpoint answered 401)\n"+
+		"1 passed, 1 failed, 0 skipped\n", out.String())
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// adminGuard protects destructive admin routes with a time-based token bucket, shared
+// by all of them, and an optional confirmation token
+type adminGuard struct {
+	config config.AdminConfig
+	log    *logrus.Logger
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newAdminGuard creates a guard with a full bucket
+func newAdminGuard(cfg config.AdminConfig, log *logrus.Logger) *adminGuard {
+	return &adminGuard{
+		config: cfg,
+		log:    log,
+		tokens: float64(cfg.Burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// allow takes a token from the bucket, or returns the time until the next one.
+// A guard without burst or interval does not limit the actions.
+func (g *adminGuard) allow() (bool, time.Duration) {
+	if g.config.Burst <= 0 || g.config.Interval <= 0 {
+		return true, 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.tokens = math.Min(float64(g.config.Burst), g.tokens+float64(now.Sub(g.last))/float64(g.config.Interval))
+	g.last = now
+
+	if g.tokens < 1 {
+		return false, time.Duration((1 - g.tokens) * float64(g.config.Interval))
+	}
+	g.tokens--
+	return true, 0
+}
+
+// middleware rejects the requests without the confirmation token and those over the rate limit.
+// Rejected requests still take a token, so that a failing automation cannot retry quickly.
+func (g *adminGuard) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := g.allow(); !ok {
+			g.log.WithFields(logrus.Fields{
+				"path":        r.URL.Path,
+				"remote_addr": r.RemoteAddr,
+			}).Warn("Rate limited destructive admin action")
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many admin actions", http.StatusTooManyRequests)
+			return
+		}
+
+		if g.config.ConfirmToken != "" {
+			token := r.URL.Query().Get("confirm")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(g.config.ConfirmToken)) != 1 {
+				g.log.WithFields(logrus.Fields{
+					"path":        r.URL.Path,
+					"remote_addr": r.RemoteAddr,
+				}).Warn("Rejected destructive admin action without a valid confirmation token")
+
+				http.Error(w, "Invalid confirmation token", http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminGuardRateLimit(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	guard := newAdminGuard(config.AdminConfig{Burst: 2, Interval: time.Minute}, log)
+	now := time.Now()
+	guard.last = now
+	guard.now = func() time.Time { return now }
+
+	ok, _ := guard.allow()
+	assert.True(t, ok)
+	ok, _ = guard.allow()
+	assert.True(t, ok)
+
+	ok, wait := guard.allow()
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, wait)
+
+	// One token is refilled per interval
+	now = now.Add(30 * time.Second)
+	ok, wait = guard.allow()
+	assert.False(t, ok)
+	assert.Equal(t, 30*time.Second, wait)
+
+	now = now.Add(30 * time.Second)
+	ok, _ = guard.allow()
+	assert.True(t, ok)
+
+	// The bucket never holds more than the burst
+	now = now.Add(time.Hour)
+	for range 2 {
+		ok, _ = guard.allow()
+		assert.True(t, ok)
+	}
+	ok, _ = guard.allow()
+	assert.False(t, ok)
+}
+
+func TestAdminGuardUnlimited(t *testing.T) {
+	guard := newAdminGuard(config.AdminConfig{}, logrus.New())
+	for range 10 {
+		ok, _ := guard.allow()
+		assert.True(t, ok)
+	}
+}
+
+func TestMetricsResetAdminGuard(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{Server: config.ServerConfig{Admin: config.AdminConfig{
+		Burst:        2,
+		Interval:     time.Hour,
+		ConfirmToken: "yes-really",
+	}}}
+	server := NewServer(cfg, log)
+	server.registerMetricsEndpoint()
+
+	reset := func(query string) *http.Response {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics/reset"+query, nil))
+		return w.Result()
+	}
+
+	assert.Equal(t, http.StatusForbidden, reset("").StatusCode)
+	assert.Equal(t, http.StatusOK, reset("?confirm=yes-really").StatusCode)
+
+	// The rejected request took a token too
+	resp := reset("?confirm=yes-really")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "3600", resp.Header.Get("Retry-After"))
+}
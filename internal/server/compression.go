@@ -0,0 +1,184 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressionKey is the context key of the compressing writer of a response
+type compressionKey struct{}
+
+// compressResponses gzips the JSON responses of the clients accepting it, when the server
+// compresses responses or when an endpoint enables it for its own responses
+func (s *Server) compressResponses(next http.Handler) http.Handler {
+	compression := s.config.Server.Compression
+	if compression.Level == 0 {
+		compression.Level = gzip.DefaultCompression
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			enabled:        compression.Enabled,
+			level:          compression.Level,
+			minSize:        compression.MinSize,
+			statusCode:     http.StatusOK,
+		}
+		defer cw.close()
+		next.ServeHTTP(cw, r.WithContext(context.WithValue(r.Context(), compressionKey{}, cw)))
+	})
+}
+
+// setResponseCompression enables or disables the compression of the response of a request,
+// before anything is written. It does nothing for clients not accepting gzip.
+func setResponseCompression(ctx context.Context, enabled bool) {
+	if cw, ok := ctx.Value(compressionKey{}).(*compressWriter); ok {
+		cw.enabled = enabled
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header accepts gzip
+func acceptsGzip(header string) bool {
+	for _, coding := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "x-gzip" && name != "*" {
+			continue
+		}
+
+		// A zero quality value refuses the coding
+		quality := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				quality = q
+			}
+		}
+		return quality > 0
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether to compress it:
+// once MinSize bytes are written, a JSON response is gzipped, and shorter or other
+// responses are written as is
+type compressWriter struct {
+	http.ResponseWriter
+	enabled bool
+	level   int
+	minSize int
+
+	statusCode  int
+	buf         []byte
+	decided     bool
+	wroteHeader bool
+	gz          *gzip.Writer
+}
+
+// WriteHeader records the status code, written with the first bytes of the body
+func (c *compressWriter) WriteHeader(statusCode int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.statusCode = statusCode
+
+	// Responses without a body are not buffered
+	if !c.enabled || !bodyAllowed(statusCode) {
+		c.decide(false)
+	}
+}
+
+// Write buffers the body until the decision to compress it is made
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.decided {
+		if c.gz != nil {
+			return c.gz.Write(p)
+		}
+		return c.ResponseWriter.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= c.minSize {
+		if err := c.flushBuffer(c.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// close writes the buffered body of a response shorter than MinSize, or ends the gzip stream
+func (c *compressWriter) close() {
+	if !c.decided {
+		if !c.wroteHeader {
+			return
+		}
+		_ = c.flushBuffer(false)
+	}
+	if c.gz != nil {
+		_ = c.gz.Close()
+	}
+}
+
+// flushBuffer writes the status code and the buffered body, compressed or not
+func (c *compressWriter) flushBuffer(compress bool) error {
+	c.decide(compress)
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if c.gz != nil {
+		_, err := c.gz.Write(buf)
+		return err
+	}
+	_, err := c.ResponseWriter.Write(buf)
+	return err
+}
+
+// decide writes the status code, with the compression headers when compressing
+func (c *compressWriter) decide(compress bool) {
+	c.decided = true
+	if compress {
+		header := c.Header()
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+
+		// The level is validated with the configuration, so this only fails for
+		// configurations built in code; those responses use the default level
+		gz, err := gzip.NewWriterLevel(c.ResponseWriter, c.level)
+		if err != nil {
+			gz = gzip.NewWriter(c.ResponseWriter)
+		}
+		c.gz = gz
+	}
+	c.ResponseWriter.WriteHeader(c.statusCode)
+}
+
+// compressible reports whether the response is a JSON response not already encoded
+func (c *compressWriter) compressible() bool {
+	if !c.enabled || c.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(c.Header().Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// bodyAllowed reports whether a response with the given status can have a body
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.8", true},
+		{"GZIP", true},
+		{"gzip;q=0", false},
+		{"*", true},
+		{"br, deflate", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, acceptsGzip(tt.header), tt.header)
+	}
+}
+
+func TestCompressResponses(t *testing.T) {
+	large := `{"items":"` + strings.Repeat("a", 2048) + `"}`
+
+	newServer := func(enabled bool) *Server {
+		log := logrus.New()
+		log.SetOutput(io.Discard) // Silence logs during tests
+
+		return &Server{config: &config.Config{Server: config.ServerConfig{
+			Compression: config.CompressionConfig{Enabled: enabled, Level: 6, MinSize: 1024},
+		}}, log: log}
+	}
+
+	serve := func(s *Server, contentType, body, acceptEncoding string, enable *bool) *httptest.ResponseRecorder {
+		handler := s.compressResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if enable != nil {
+				setResponseCompression(r.Context(), *enable)
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = io.WriteString(w, body)
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	decompress := func(t *testing.T, w *httptest.ResponseRecorder) string {
+		reader, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	t.Run("large JSON response", func(t *testing.T) {
+		w := serve(newServer(true), "application/json", large, "gzip", nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal(t, large, decompress(t, w))
+	})
+
+	t.Run("small JSON response", func(t *testing.T) {
+		w := serve(newServer(true), "application/json", `{"ok":true}`, "gzip", nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, `{"ok":true}`, w.Body.String())
+	})
+
+	t.Run("not JSON", func(t *testing.T) {
+		w := serve(newServer(true), "text/plain", large, "gzip", nil)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("gzip not accepted", func(t *testing.T) {
+		w := serve(newServer(true), "application/json", large, "", nil)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		w := serve(newServer(false), "application/json", large, "gzip", nil)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("enabled for a response", func(t *testing.T) {
+		enabled := true
+		w := serve(newServer(false), "application/json", large, "gzip", &enabled)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, decompress(t, w))
+	})
+
+	t.Run("disabled for a response", func(t *testing.T) {
+		disabled := false
+		w := serve(newServer(true), "application/json", large, "gzip", &disabled)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+}
+
+func TestRegisterEndpointCompressResponse(t *testing.T) {
+	enabled := true
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path:             "/webhook",
+				CompressResponse: &enabled,
+				Response: &config.ResponseConfig{
+					StatusCode: http.StatusOK,
+					Headers:    map[string]string{"Content-Type": "application/json"},
+					Body:       `{"id":"{{.ID}}","padding":"` + strings.Repeat("x", 64) + `"}`,
+				},
+			},
+		},
+	}
+	cfg.Server.Compression.MinSize = 16
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"push"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.NotEmpty(t, w.Header().Get(headerDeliveryID))
+
+	reader, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Contains(t, string(decompressed), w.Header().Get(headerDeliveryID))
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/deadletter"
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// deadLetterHook saves the deliveries of every endpoint that failed for good
+type deadLetterHook struct {
+	proxy.NopHook
+	store *deadletter.Store
+	log   *logrus.Logger
+}
+
+// OnDeadLetter saves the delivery with the result of its last attempt
+func (h *deadLetterHook) OnDeadLetter(event *proxy.Event) {
+	letter := deadletter.Letter{
+		ID:          event.ID,
+		Endpoint:    event.Endpoint,
+		Destination: event.Destination.Key(),
+		Labels:      event.Destination.Labels,
+		Body:        event.Body,
+		Headers:     event.Headers,
+		Attempts:    event.Attempt,
+		StatusCode:  event.StatusCode,
+		ReceivedAt:  event.ReceivedAt,
+		FailedAt:    time.Now(),
+	}
+	if event.Err != nil {
+		letter.Error = event.Err.Error()
+	}
+
+	if err := h.store.Save(letter); err != nil {
+		h.log.WithFields(logrus.Fields{
+			"error":       err,
+			"webhook_id":  event.WebhookID,
+			"delivery_id": event.ID,
+			"destination": letter.Destination,
+		}).Error("Failed to save dead letter")
+	}
+}
+
+// saveUnmatched saves a webhook that no destination matched as a dead letter, without a
+// destination, so that it can be inspected and redriven once the filters are fixed
+func (s *Server) saveUnmatched(delivery *webhook.Delivery) error {
+	return s.deadLetters.Save(deadletter.Letter{
+		ID:         delivery.ID,
+		Endpoint:   delivery.Endpoint,
+		Metadata:   delivery.Metadata,
+		Body:       delivery.Body,
+		Headers:    delivery.Headers,
+		Error:      "no destination matches the webhook",
+		ReceivedAt: delivery.ReceivedAt,
+		FailedAt:   time.Now(),
+	})
+}
+
+// registerDeadLetterEndpoints registers the routes listing, showing, sending again and
+// deleting dead letters. Sending again and deleting are destructive admin actions.
+func (s *Server) registerDeadLetterEndpoints() {
+	s.router.Get("/admin/dead-letters", func(w http.ResponseWriter, r *http.Request) {
+		letters, ok := s.listDeadLetters(w, r)
+		if !ok {
+			return
+		}
+
+		// Bodies are left out of the list, they are shown one letter at a time
+		for i := range letters {
+			letters[i].Body = nil
+		}
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"count":        len(letters),
+			"dead_letters": letters,
+		})
+	})
+
+	s.router.Get("/admin/dead-letters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		letter, ok := s.deadLetter(w, chi.URLParam(r, "id"))
+		if !ok {
+			return
+		}
+		s.writeJSON(w, http.StatusOK, letter)
+	})
+
+	s.router.With(s.admin.middleware).Post("/admin/dead-letters/{id}/redrive", func(w http.ResponseWriter, r *http.Request) {
+		letter, ok := s.deadLetter(w, chi.URLParam(r, "id"))
+		if !ok {
+			return
+		}
+		if !s.redrive(letter) {
+			http.Error(w, "Destination no longer configured, or no destination matches", http.StatusConflict)
+			return
+		}
+		s.writeJSON(w, http.StatusAccepted, map[string]interface{}{"redriven": 1})
+	})
+
+	s.router.With(s.admin.middleware).Post("/admin/dead-letters/redrive", func(w http.ResponseWriter, r *http.Request) {
+		letters, ok := s.listDeadLetters(w, r)
+		if !ok {
+			return
+		}
+		redriven := 0
+		for _, letter := range letters {
+			if s.redrive(letter) {
+				redriven++
+			}
+		}
+		s.writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"redriven": redriven,
+			"skipped":  len(letters) - redriven,
+		})
+	})
+
+	s.router.With(s.admin.middleware).Delete("/admin/dead-letters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := s.deadLetters.Delete(chi.URLParam(r, "id"))
+		switch {
+		case errors.Is(err, deadletter.ErrNotFound):
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+		case err != nil:
+			s.log.WithError(err).Error("Failed to delete dead letter")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// listDeadLetters returns the dead letters matching the endpoint and destination query
+// parameters, or writes the error
+func (s *Server) listDeadLetters(w http.ResponseWriter, r *http.Request) ([]deadletter.Letter, bool) {
+	letters, err := s.deadLetters.List()
+	if letters == nil && err != nil {
+		s.log.WithError(err).Error("Failed to list dead letters")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if err != nil {
+		s.log.WithError(err).Warn("Skipped unreadable dead letters")
+	}
+
+	endpoint := r.URL.Query().Get("endpoint")
+	destination := r.URL.Query().Get("destination")
+	matching := make([]deadletter.Letter, 0, len(letters))
+	for _, letter := range letters {
+		if (endpoint == "" || letter.Endpoint == endpoint) && (destination == "" || letter.Destination == destination) {
+			matching = append(matching, letter)
+		}
+	}
+	return matching, true
+}
+
+// deadLetter returns a dead letter, or writes the error
+func (s *Server) deadLetter(w http.ResponseWriter, id string) (deadletter.Letter, bool) {
+	letter, err := s.deadLetters.Get(id)
+	switch {
+	case errors.Is(err, deadletter.ErrNotFound):
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return letter, false
+	case err != nil:
+		s.log.WithError(err).Error("Failed to read dead letter")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return letter, false
+	}
+	return letter, true
+}
+
+// redrive starts a new delivery of a dead letter and removes it from the store. It returns
+// false, keeping the letter, when its endpoint or destination is no longer configured.
+// A delivery failing again is saved as a new dead letter. A letter without destination,
+// which no destination matched, is forwarded again through the filters, and kept while
+// none matches it.
+func (s *Server) redrive(letter deadletter.Letter) bool {
+	if letter.Destination == "" {
+		if !s.forwardUnmatched(letter) {
+			return false
+		}
+	} else {
+		handler, ok := s.proxyHandlers[letter.Endpoint]
+		if !ok || !handler.Redrive(context.Background(), letter.Destination, letter.Body, letter.Headers) {
+			return false
+		}
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"delivery_id": letter.ID,
+		"endpoint":    letter.Endpoint,
+		"destination": letter.Destination,
+	}).Info("Redriving dead letter")
+	if err := s.deadLetters.Delete(letter.ID); err != nil && !errors.Is(err, deadletter.ErrNotFound) {
+		s.log.WithError(err).Error("Failed to delete redriven dead letter")
+	}
+	return true
+}
+
+// forwardUnmatched forwards a dead letter that no destination matched to the destinations
+// of its endpoint matching it now. It returns false when none does.
+func (s *Server) forwardUnmatched(letter deadletter.Letter) bool {
+	endpoint, ok := s.endpoint(letter.Endpoint)
+	if !ok {
+		return false
+	}
+	handler := s.proxyHandlers[handlerKey(endpoint)]
+
+	// The letter has the ID of the webhook, whose history entry records the deliveries
+	delivery := webhook.New(letter.Endpoint, letter.Body, letter.Headers)
+	delivery.ID = letter.ID
+	delivery.Metadata = letter.Metadata
+	delivery.ReceivedAt = letter.ReceivedAt
+	if !handler.Matches(delivery) {
+		return false
+	}
+	go s.forwardWebhook(endpoint, handler, delivery, false)
+	return true
+}
+
+// writeJSON writes a JSON response with the given status code
+func (s *Server) writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.log.WithError(err).Error("Failed to encode response")
+	}
+}
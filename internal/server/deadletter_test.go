@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/deadletter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadLettersResponse is the body of /admin/dead-letters
+type deadLettersResponse struct {
+	Count       int                 `json:"count"`
+	DeadLetters []deadletter.Letter `json:"dead_letters"`
+}
+
+func TestDeadLetterEndpoints(t *testing.T) {
+	var healthy atomic.Bool
+	delivered := make(chan string, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		delivered <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		DeadLetters: config.DeadLetterConfig{Directory: t.TempDir()},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: []config.DestinationConfig{
+			{URL: destination.URL, Method: "POST", Timeout: time.Second},
+		}}},
+	}
+	server := NewServer(cfg, log)
+	require.NotNil(t, server.deadLetters)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerDeadLetterEndpoints()
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	list := func(query string) deadLettersResponse {
+		w := serve(http.MethodGet, "/admin/dead-letters"+query)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response deadLettersResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"push"}`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	// The failed delivery is listed without its body
+	require.Eventually(t, func() bool {
+		return list("").Count == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	letter := list("").DeadLetters[0]
+	assert.Equal(t, "/webhook", letter.Endpoint)
+	assert.Equal(t, destination.URL, letter.Destination)
+	assert.Equal(t, http.StatusServiceUnavailable, letter.StatusCode)
+	assert.Equal(t, 1, letter.Attempts)
+	assert.NotEmpty(t, letter.Error)
+	assert.Empty(t, letter.Body)
+	assert.Equal(t, 0, list("?destination=https://other.example.com").Count)
+	assert.Equal(t, 1, list("?endpoint=/webhook").Count)
+
+	// A single letter is shown with its body
+	w = serve(http.MethodGet, "/admin/dead-letters/"+letter.ID)
+	require.Equal(t, http.StatusOK, w.Code)
+	var shown deadletter.Letter
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shown))
+	assert.Equal(t, `{"event":"push"}`, string(shown.Body))
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/admin/dead-letters/unknown").Code)
+
+	// Once the destination is fixed, the letter is sent again and removed
+	healthy.Store(true)
+	assert.Equal(t, http.StatusAccepted, serve(http.MethodPost, "/admin/dead-letters/"+letter.ID+"/redrive").Code)
+	select {
+	case body := <-delivered:
+		assert.Equal(t, `{"event":"push"}`, body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the dead letter to be delivered again")
+	}
+	assert.Equal(t, 0, list("").Count)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/admin/dead-letters/"+letter.ID+"/redrive").Code)
+
+	// Letters of a removed destination are kept, and can be deleted
+	require.NoError(t, server.deadLetters.Save(deadletter.Letter{
+		ID:          "removed",
+		Endpoint:    "/webhook",
+		Destination: "https://removed.example.com",
+		FailedAt:    time.Now(),
+	}))
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/admin/dead-letters/removed/redrive").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/admin/dead-letters/removed").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/dead-letters/removed").Code)
+}
+
+func TestDeadLetterBulkRedrive(t *testing.T) {
+	delivered := make(chan string, 2)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		delivered <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		DeadLetters: config.DeadLetterConfig{Directory: t.TempDir()},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: []config.DestinationConfig{
+			{URL: destination.URL, Method: "POST", Timeout: time.Second},
+		}}},
+	}
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerDeadLetterEndpoints()
+
+	for _, letter := range []deadletter.Letter{
+		{ID: "first", Endpoint: "/webhook", Destination: destination.URL, Body: []byte("1"), FailedAt: time.Now()},
+		{ID: "second", Endpoint: "/webhook", Destination: destination.URL, Body: []byte("2"), FailedAt: time.Now()},
+		{ID: "removed", Endpoint: "/removed", Destination: destination.URL, Body: []byte("3"), FailedAt: time.Now()},
+	} {
+		require.NoError(t, server.deadLetters.Save(letter))
+	}
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/dead-letters/redrive", nil))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"redriven":2,"skipped":1}`, w.Body.String())
+
+	var bodies []string
+	for i := 0; i < 2; i++ {
+		select {
+		case body := <-delivered:
+			bodies = append(bodies, body)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected the dead letters to be delivered again")
+		}
+	}
+	assert.ElementsMatch(t, []string{"1", "2"}, bodies)
+
+	letters, err := server.deadLetters.List()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "removed", letters[0].ID)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// dedupKey is a key remembered by an endpoint, with the webhook it was accepted with
+type dedupKey struct {
+	key     string
+	id      string
+	expires time.Time
+}
+
+// endpointDedup remembers the keys of the webhooks an endpoint accepted, so that their
+// redeliveries are not forwarded again. Keys share the endpoint's TTL, so they expire in
+// the order they were remembered.
+type endpointDedup struct {
+	config config.DedupConfig
+	now    func() time.Time
+
+	mu         sync.Mutex
+	keys       map[string]dedupKey
+	order      []dedupKey // oldest first, including keys forgotten since
+	duplicates int64
+}
+
+// newEndpointDedup creates the deduplication of an endpoint
+func newEndpointDedup(cfg config.DedupConfig) *endpointDedup {
+	return &endpointDedup{config: cfg, now: time.Now, keys: make(map[string]dedupKey)}
+}
+
+// key returns the key of a webhook: the value of the configured header, or else the dedupe
+// key of the endpoint's provider when it has one, or else the SHA-256 of the body. Webhooks
+// without the configured header have no key and are not deduplicated.
+func (d *endpointDedup) key(body []byte, header http.Header, dedupeKey string) string {
+	if d.config.Header != "" {
+		return header.Get(d.config.Header)
+	}
+	if dedupeKey != "" {
+		return dedupeKey
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// claim remembers the key of a webhook, unless a webhook accepted within the TTL has the
+// same key. It then returns the ID of that webhook and true.
+func (d *endpointDedup) claim(key, id string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	for len(d.order) > 0 && !now.Before(d.order[0].expires) {
+		d.evict()
+	}
+
+	if first, ok := d.keys[key]; ok {
+		d.duplicates++
+		return first.id, true
+	}
+
+	for len(d.order) > 0 && len(d.keys) >= d.config.MaxKeys {
+		d.evict()
+	}
+	entry := dedupKey{key: key, id: id, expires: now.Add(d.config.TTL)}
+	d.keys[key] = entry
+	d.order = append(d.order, entry)
+	return "", false
+}
+
+// forget forgets the key of a webhook that was not accepted after all, so that its
+// redelivery is forwarded
+func (d *endpointDedup) forget(key, id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry, ok := d.keys[key]; ok && entry.id == id {
+		delete(d.keys, key)
+	}
+}
+
+// evict forgets the oldest key, unless it was forgotten or claimed again since
+func (d *endpointDedup) evict() {
+	oldest := d.order[0]
+	d.order = d.order[1:]
+	if entry, ok := d.keys[oldest.key]; ok && entry.id == oldest.id {
+		delete(d.keys, oldest.key)
+	}
+}
+
+// resetMetrics zeroes the count of duplicates
+func (d *endpointDedup) resetMetrics() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.duplicates = 0
+}
+
+// snapshot returns the duplicates answered and the keys remembered, for the metrics
+func (d *endpointDedup) snapshot() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return map[string]interface{}{
+		"duplicates": d.duplicates,
+		"keys":       len(d.keys),
+	}
+}
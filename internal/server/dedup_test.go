@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDedup creates a dedup whose clock is set by the returned function
+func newTestDedup(cfg config.DedupConfig, start time.Time) (*endpointDedup, func(time.Time)) {
+	now := start
+	dedup := newEndpointDedup(cfg)
+	dedup.now = func() time.Time { return now }
+	return dedup, func(t time.Time) { now = t }
+}
+
+func TestEndpointDedupClaim(t *testing.T) {
+	start := time.Now()
+	dedup, setNow := newTestDedup(config.DedupConfig{TTL: time.Hour, MaxKeys: 10}, start)
+
+	_, duplicate := dedup.claim("a", "1")
+	assert.False(t, duplicate)
+	firstID, duplicate := dedup.claim("a", "2")
+	assert.True(t, duplicate)
+	assert.Equal(t, "1", firstID)
+
+	// Keys are forgotten once their TTL elapsed
+	setNow(start.Add(time.Hour))
+	_, duplicate = dedup.claim("a", "3")
+	assert.False(t, duplicate)
+
+	snapshot := dedup.snapshot()
+	assert.Equal(t, int64(1), snapshot["duplicates"])
+	assert.Equal(t, 1, snapshot["keys"])
+}
+
+func TestEndpointDedupMaxKeys(t *testing.T) {
+	dedup, _ := newTestDedup(config.DedupConfig{TTL: time.Hour, MaxKeys: 2}, time.Now())
+
+	dedup.claim("a", "1")
+	dedup.claim("b", "2")
+	dedup.claim("c", "3")
+
+	// The oldest key is forgotten first
+	_, duplicate := dedup.claim("a", "4")
+	assert.False(t, duplicate)
+	_, duplicate = dedup.claim("c", "5")
+	assert.True(t, duplicate)
+}
+
+func TestEndpointDedupForget(t *testing.T) {
+	dedup, _ := newTestDedup(config.DedupConfig{TTL: time.Hour, MaxKeys: 2}, time.Now())
+
+	dedup.claim("a", "1")
+	dedup.forget("a", "1")
+	_, duplicate := dedup.claim("a", "2")
+	assert.False(t, duplicate)
+
+	// Only the webhook that claimed a key forgets it
+	dedup.forget("a", "1")
+	_, duplicate = dedup.claim("a", "3")
+	assert.True(t, duplicate)
+
+	// The key claimed again is not evicted with its forgotten claim
+	dedup.claim("b", "4")
+	dedup.claim("c", "5")
+	assert.Equal(t, 2, dedup.snapshot()["keys"])
+}
+
+func TestEndpointDedupKey(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-GitHub-Delivery", "72d3162e")
+
+	byHeader := newEndpointDedup(config.DedupConfig{Header: "x-github-delivery"})
+	assert.Equal(t, "72d3162e", byHeader.key([]byte("{}"), header, "github:other"))
+	assert.Empty(t, byHeader.key([]byte("{}"), http.Header{}, "github:other"))
+
+	// Without a header, the provider's dedupe key is used, then the body
+	byProvider := newEndpointDedup(config.DedupConfig{})
+	assert.Equal(t, "github:72d3162e", byProvider.key([]byte("{}"), header, "github:72d3162e"))
+
+	byBody := newEndpointDedup(config.DedupConfig{})
+	assert.Equal(t, byBody.key([]byte("{}"), header, ""), byBody.key([]byte("{}"), http.Header{}, ""))
+	assert.NotEqual(t, byBody.key([]byte("{}"), header, ""), byBody.key([]byte("[]"), header, ""))
+}
+
+// newDedupServer returns a server whose endpoint deduplicates webhooks on X-Delivery, with
+// a daily quota of quota webhooks, and the number of requests its destination received
+func newDedupServer(t *testing.T, quota int64) (*Server, func() int) {
+	received := make(chan struct{}, 10)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(destination.Close)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{{
+			Path:         "/webhook",
+			Dedup:        &config.DedupConfig{Header: "X-Delivery", TTL: time.Hour, MaxKeys: 10},
+			Quota:        &config.QuotaConfig{Daily: quota, OnExceed: config.QuotaReject},
+			Destinations: []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: time.Second}},
+		}},
+	}
+	server := NewServer(cfg, log)
+	server.forwardInline = true
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerMetricsEndpoint()
+
+	// The deliveries are in flight once the webhooks are answered, so the count waits for them
+	return server, func() int {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, server.proxyHandlers["/webhook"].Drain(ctx))
+		return len(received)
+	}
+}
+
+// postDelivery posts a webhook with the given X-Delivery header
+func postDelivery(server *Server, delivery string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"action":"opened"}`))
+	req.Header.Set("X-Delivery", delivery)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDedupAnswersDuplicates(t *testing.T) {
+	server, received := newDedupServer(t, 10)
+
+	first := postDelivery(server, "72d3162e")
+	require.Equal(t, http.StatusAccepted, first.Code)
+
+	duplicate := postDelivery(server, "72d3162e")
+	require.Equal(t, http.StatusOK, duplicate.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(duplicate.Body.Bytes(), &body))
+	assert.Equal(t, "duplicate", body["status"])
+	assert.Equal(t, first.Header().Get(headerDeliveryID), body["id"])
+	assert.Equal(t, first.Header().Get(headerDeliveryID), duplicate.Header().Get(headerDeliveryID))
+	assert.Equal(t, "true", duplicate.Header().Get(headerDuplicate))
+	assert.Empty(t, first.Header().Get(headerDuplicate))
+
+	// Webhooks without the header are not deduplicated
+	assert.Equal(t, http.StatusAccepted, postDelivery(server, "").Code)
+	assert.Equal(t, http.StatusAccepted, postDelivery(server, "").Code)
+	assert.Equal(t, 3, received())
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics struct {
+		Global map[string]interface{}            `json:"global"`
+		Dedup  map[string]map[string]interface{} `json:"dedup"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, float64(1), metrics.Global["duplicates"])
+	assert.Equal(t, float64(1), metrics.Dedup["/webhook"]["duplicates"])
+}
+
+func TestDedupForgetsRefusedWebhooks(t *testing.T) {
+	server, received := newDedupServer(t, 1)
+
+	require.Equal(t, http.StatusAccepted, postDelivery(server, "first").Code)
+
+	// A webhook refused over the quota is not remembered, so its redelivery is refused again
+	// rather than answered as a duplicate
+	require.Equal(t, http.StatusTooManyRequests, postDelivery(server, "second").Code)
+	require.Equal(t, http.StatusTooManyRequests, postDelivery(server, "second").Code)
+	assert.Equal(t, 1, received())
+}
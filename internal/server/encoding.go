@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// maxBodySize is the maximum size of a webhook body, both as received and once decompressed
+const maxBodySize = 10 << 20
+
+// statusClientClosedRequest is answered, for the access logs, to senders that disconnected
+// before their body was read
+const statusClientClosedRequest = 499
+
+// Request body errors
+var (
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+	errInvalidEncoding     = errors.New("invalid encoded body")
+	errBodyTooLarge        = errors.New("request body too large")
+	errClientDisconnected  = errors.New("client disconnected before the body was fully read")
+)
+
+// connReader records the last error of the connection a body is read from, so that a sender
+// disconnecting mid-body is told apart from an invalid or oversized body
+type connReader struct {
+	reader io.Reader
+	err    error
+}
+
+// Read reads the raw body
+func (c *connReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	if err != nil && err != io.EOF {
+		c.err = err
+	}
+	return n, err
+}
+
+// disconnected returns errClientDisconnected wrapping the connection error when the body
+// of a request could not be read because its sender went away, and err otherwise
+func (c *connReader) disconnected(r *http.Request, err error) error {
+	var netErr net.Error
+	switch {
+	case c.err == nil:
+		return err
+	case errors.Is(c.err, io.ErrUnexpectedEOF), errors.As(c.err, &netErr),
+		errors.Is(r.Context().Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", errClientDisconnected, c.err)
+	default:
+		return err
+	}
+}
+
+// decodeBody returns a reader decompressing a body with the given Content-Encoding.
+// Bodies without an encoding, or with the identity encoding, are returned as is.
+func decodeBody(body io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidEncoding, err)
+		}
+		return &decodingReader{reader: reader}, nil
+	case "deflate":
+		// HTTP deflate is zlib-wrapped, but some senders use raw deflate
+		reader, err := newDeflateReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidEncoding, err)
+		}
+		return &decodingReader{reader: reader}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedEncoding, encoding)
+	}
+}
+
+// newDeflateReader reads a zlib stream, falling back to raw deflate when the zlib header is missing
+func newDeflateReader(body io.Reader) (io.Reader, error) {
+	header := make([]byte, 2)
+	n, err := io.ReadFull(body, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	stream := io.MultiReader(bytes.NewReader(header[:n]), body)
+
+	// A zlib header is a deflate method byte whose 16-bit value is a multiple of 31
+	if n == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(stream)
+	}
+	return flate.NewReader(stream), nil
+}
+
+// decodingReader marks the errors of a decompressor as invalid encoding,
+// except for the body size limit
+type decodingReader struct {
+	reader io.Reader
+}
+
+// Read reads decompressed data
+func (d *decodingReader) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	if err != nil && err != io.EOF {
+		var maxBytesErr *http.MaxBytesError
+		if !errors.As(err, &maxBytesErr) {
+			err = fmt.Errorf("%w: %w", errInvalidEncoding, err)
+		}
+	}
+	return n, err
+}
+
+// bodyErrorStatus returns the status answered for a request body error
+func bodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, errBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, errInvalidEncoding):
+		return http.StatusBadRequest
+	case errors.Is(err, errClientDisconnected):
+		return statusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
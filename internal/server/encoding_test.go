@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compress compresses data with the given writer constructor
+func compress(t *testing.T, data string, newWriter func(io.Writer) io.WriteCloser) []byte {
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestReadRequestBodyDecompression(t *testing.T) {
+	payload := `{"event":"compressed"}`
+	gzipped := compress(t, payload, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	zlibbed := compress(t, payload, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })
+	deflated := compress(t, payload, func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	})
+
+	tests := []struct {
+		name           string
+		encoding       string
+		body           []byte
+		expectedStatus int
+	}{
+		{name: "Identity", encoding: "identity", body: []byte(payload)},
+		{name: "Gzip", encoding: "gzip", body: gzipped},
+		{name: "Deflate", encoding: "deflate", body: zlibbed},
+		{name: "Raw deflate", encoding: "deflate", body: deflated},
+		{name: "Unsupported encoding", encoding: "br", body: gzipped, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Invalid gzip header", encoding: "gzip", body: []byte(payload), expectedStatus: http.StatusBadRequest},
+		{name: "Truncated gzip body", encoding: "gzip", body: gzipped[:len(gzipped)-4], expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.encoding)
+
+			body, err := readRequestBody(req)
+			if tt.expectedStatus != 0 {
+				require.Error(t, err)
+				assert.Equal(t, tt.expectedStatus, bodyErrorStatus(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, payload, string(body))
+		})
+	}
+}
+
+func TestReadRequestBodyClientDisconnected(t *testing.T) {
+	payload := `{"event":"interrupted","padding":"` + strings.Repeat("x", 1024) + `"}`
+	gzipped := compress(t, payload, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+	tests := []struct {
+		name           string
+		encoding       string
+		body           []byte
+		err            error
+		expectedStatus int
+	}{
+		{name: "Connection closed mid-body", body: []byte(payload[:100]), err: io.ErrUnexpectedEOF, expectedStatus: statusClientClosedRequest},
+		{name: "Connection reset", body: []byte(payload[:100]), err: reset, expectedStatus: statusClientClosedRequest},
+		{name: "Compressed body interrupted", encoding: "gzip", body: gzipped[:len(gzipped)/2], err: io.ErrUnexpectedEOF, expectedStatus: statusClientClosedRequest},
+		{name: "Other read error", body: []byte(payload[:100]), err: errors.New("boom"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := io.MultiReader(bytes.NewReader(tt.body), iotest.ErrReader(tt.err))
+			req := httptest.NewRequest(http.MethodPost, "/webhook", body)
+			req.Header.Set("Content-Encoding", tt.encoding)
+
+			_, err := readRequestBody(req)
+			require.Error(t, err)
+			assert.Equal(t, tt.expectedStatus, bodyErrorStatus(err))
+		})
+	}
+}
+
+func TestReadRequestBodyDecompressedTooLarge(t *testing.T) {
+	// A small compressed body expanding past the size limit
+	bomb := compress(t, strings.Repeat("0", maxBodySize+1), func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	require.Less(t, len(bomb), maxBodySize)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(bomb))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	_, err := readRequestBody(req)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, bodyErrorStatus(err))
+}
+
+func TestBodyErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusRequestEntityTooLarge, bodyErrorStatus(&http.MaxBytesError{Limit: maxBodySize}))
+	assert.Equal(t, http.StatusInternalServerError, bodyErrorStatus(io.ErrUnexpectedEOF))
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// configGenerations numbers the applied configurations and tracks, for each endpoint, the
+// generation in which its configuration last changed. Applying a configuration only moves
+// the endpoints that differ from the previous one to the new generation, so that operators
+// can tell which configuration served a delivery during a rollout.
+type configGenerations struct {
+	mu        sync.RWMutex
+	current   int64
+	endpoints map[string]endpointGeneration
+}
+
+// endpointGeneration is the generation of an endpoint and the fingerprint of its configuration
+type endpointGeneration struct {
+	number      int64
+	fingerprint string
+}
+
+// newConfigGenerations creates a tracker with no applied configuration
+func newConfigGenerations() *configGenerations {
+	return &configGenerations{endpoints: make(map[string]endpointGeneration)}
+}
+
+// apply starts a new generation with the endpoints of a configuration and returns the keys
+// of the endpoints that were added or changed, sorted. Endpoints of a pipeline are tracked
+// together, under the pipeline name. Removed endpoints are forgotten.
+func (g *configGenerations) apply(endpoints []config.EndpointConfig) []string {
+	// Group the endpoints by handler, in configuration order
+	grouped := make(map[string][]config.EndpointConfig)
+	for _, endpoint := range endpoints {
+		key := handlerKey(endpoint)
+		grouped[key] = append(grouped[key], endpoint)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.current++
+	next := make(map[string]endpointGeneration, len(grouped))
+	var changed []string
+	for key, group := range grouped {
+		fingerprint := endpointFingerprint(group)
+		previous, ok := g.endpoints[key]
+		if ok && fingerprint != "" && previous.fingerprint == fingerprint {
+			next[key] = previous
+			continue
+		}
+		next[key] = endpointGeneration{number: g.current, fingerprint: fingerprint}
+		changed = append(changed, key)
+	}
+	g.endpoints = next
+
+	sort.Strings(changed)
+	return changed
+}
+
+// generation returns the generation of the last applied configuration
+func (g *configGenerations) generation() int64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.current
+}
+
+// endpoint returns the generation of an endpoint, or 0 when it is unknown
+func (g *configGenerations) endpoint(key string) int64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.endpoints[key].number
+}
+
+// endpointFingerprint hashes the configuration of the endpoints of a handler
+func endpointFingerprint(endpoints []config.EndpointConfig) string {
+	encoded, err := json.Marshal(endpoints)
+	if err != nil {
+		// Unhashable configurations are always considered changed
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
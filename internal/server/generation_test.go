@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigGenerations(t *testing.T) {
+	github := config.EndpointConfig{Path: "/github", Destinations: []config.DestinationConfig{{URL: "http://a"}}}
+	stripe := config.EndpointConfig{Path: "/stripe", Destinations: []config.DestinationConfig{{URL: "http://b"}}}
+	orders := config.EndpointConfig{Path: "/orders", Pipeline: "orders", Destinations: []config.DestinationConfig{{URL: "http://c"}}}
+	refunds := config.EndpointConfig{Path: "/refunds", Pipeline: "orders", Destinations: []config.DestinationConfig{{URL: "http://c"}}}
+
+	g := newConfigGenerations()
+	assert.Equal(t, int64(0), g.generation())
+	assert.Equal(t, int64(0), g.endpoint("/github"))
+
+	// The first configuration sets every endpoint to generation 1
+	changed := g.apply([]config.EndpointConfig{github, stripe, orders, refunds})
+	assert.Equal(t, []string{"/github", "/stripe", "orders"}, changed)
+	assert.Equal(t, int64(1), g.generation())
+	assert.Equal(t, int64(1), g.endpoint("/github"))
+	assert.Equal(t, int64(1), g.endpoint("orders"))
+
+	// Only the changed endpoints move to the new generation
+	stripe.Destinations = []config.DestinationConfig{{URL: "http://b2"}}
+	changed = g.apply([]config.EndpointConfig{github, stripe, orders, refunds})
+	assert.Equal(t, []string{"/stripe"}, changed)
+	assert.Equal(t, int64(2), g.generation())
+	assert.Equal(t, int64(1), g.endpoint("/github"))
+	assert.Equal(t, int64(2), g.endpoint("/stripe"))
+	assert.Equal(t, int64(1), g.endpoint("orders"))
+
+	// Removing an endpoint of a pipeline changes the pipeline, and removed endpoints are forgotten
+	changed = g.apply([]config.EndpointConfig{stripe, orders})
+	assert.Equal(t, []string{"orders"}, changed)
+	assert.Equal(t, int64(0), g.endpoint("/github"))
+	assert.Equal(t, int64(2), g.endpoint("/stripe"))
+	assert.Equal(t, int64(3), g.endpoint("orders"))
+
+	// An endpoint added back starts at the current generation
+	changed = g.apply([]config.EndpointConfig{github, stripe, orders})
+	assert.Equal(t, []string{"/github"}, changed)
+	assert.Equal(t, int64(4), g.endpoint("/github"))
+}
+
+func TestConfigGenerationMetrics(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	harness, err := NewHarness(&config.Config{Endpoints: []config.EndpointConfig{
+		{Path: "/webhook", Destinations: []config.DestinationConfig{{URL: "http://localhost:1"}}},
+	}}, log)
+	require.NoError(t, err)
+	defer harness.Close()
+
+	assert.Equal(t, int64(1), harness.Metrics("/webhook")["config_generation"])
+
+	w := httptest.NewRecorder()
+	harness.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics struct {
+		Global map[string]interface{} `json:"global"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, float64(1), metrics.Global["config_generation"])
+}
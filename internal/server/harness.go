@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/fixture"
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/sirupsen/logrus"
+)
+
+// Harness runs the proxy in process without listening, so that integration tests can
+// replay recorded fixtures through the configured endpoints and destinations
+type Harness struct {
+	server *Server
+}
+
+// HarnessOptions replace the connections and timers of the proxy run by a harness
+type HarnessOptions struct {
+	// Transport, when set, sends the requests of the HTTP destinations instead of their
+	// connections, from the startup checks on
+	Transport http.RoundTripper
+
+	// RetryTimer, when set, returns the channels the retry delays are waited on, receiving
+	// once the delay is over, instead of timers
+	RetryTimer func(time.Duration) <-chan time.Time
+
+	// Hooks are registered on every endpoint
+	Hooks []proxy.Hook
+}
+
+// NewHarness starts the proxy for the configuration. The startup checks and the
+// resumption of persisted retries run as on a real startup. The deliveries of a webhook
+// are started before it is answered.
+func NewHarness(cfg *config.Config, log *logrus.Logger) (*Harness, error) {
+	return NewHarnessWithOptions(cfg, log, HarnessOptions{})
+}
+
+// NewHarnessWithOptions starts the proxy for the configuration like NewHarness, with the
+// connections and timers of the options
+func NewHarnessWithOptions(cfg *config.Config, log *logrus.Logger, opts HarnessOptions) (*Harness, error) {
+	server := NewServer(cfg, log)
+	server.forwardInline = true
+	server.transport = opts.Transport
+	server.retryTimer = opts.RetryTimer
+	for _, hook := range opts.Hooks {
+		server.AddHook(hook)
+	}
+	if err := server.StartWithServerFunc(func(string, http.Handler) error { return nil }); err != nil {
+		return nil, fmt.Errorf("failed to start harness: %w", err)
+	}
+	return &Harness{server: server}, nil
+}
+
+// ServeHTTP serves a request as the proxy would
+func (h *Harness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.server.router.ServeHTTP(w, r)
+}
+
+// Replay delivers a fixture to its endpoint and returns the proxy's response.
+// Destinations receive the webhook asynchronously, as with a real delivery.
+func (h *Harness) Replay(f *fixture.Fixture) (*httptest.ResponseRecorder, error) {
+	req, err := f.Request()
+	if err != nil {
+		return nil, err
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w, nil
+}
+
+// ReplayDir replays the fixtures of a directory in order, as recorded
+func (h *Harness) ReplayDir(dir string) ([]*httptest.ResponseRecorder, error) {
+	fixtures, err := fixture.LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*httptest.ResponseRecorder, 0, len(fixtures))
+	for _, f := range fixtures {
+		w, err := h.Replay(f)
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, w)
+	}
+	return responses, nil
+}
+
+// Metrics returns the metrics of the handler of an endpoint, or of its pipeline
+func (h *Harness) Metrics(key string) map[string]interface{} {
+	handler, ok := h.server.proxyHandlers[key]
+	if !ok {
+		return nil
+	}
+	return handler.GetMetrics()
+}
+
+// InFlight returns the number of deliveries of every endpoint not done yet, retries included
+func (h *Harness) InFlight() int64 {
+	var inFlight int64
+	for _, handler := range h.server.proxyHandlers {
+		inFlight += handler.InFlight()
+	}
+	return inFlight
+}
+
+// Close releases the resources of the endpoints' destinations
+func (h *Harness) Close() error {
+	var firstErr error
+	for _, handler := range h.server.proxyHandlers {
+		if err := handler.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
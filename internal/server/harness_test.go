@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	received := make(chan string, 4)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	dir := t.TempDir()
+	endpoints := []config.EndpointConfig{
+		{
+			Path:         "/webhook",
+			Destinations: []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second}},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	// Record a webhook received by a proxy in recording mode
+	recording, err := NewHarness(&config.Config{
+		Recording: config.RecordingConfig{Directory: dir, RedactFields: []string{"token"}},
+		Endpoints: endpoints,
+	}, log)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(`{"event":"push","token":"secret"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	recording.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"event":"push","token":"secret"}`, <-received)
+	require.NoError(t, recording.Close())
+
+	// Replay the sanitized fixture through another proxy
+	harness, err := NewHarness(&config.Config{Endpoints: endpoints}, log)
+	require.NoError(t, err)
+	defer harness.Close()
+
+	responses, err := harness.ReplayDir(dir)
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, http.StatusAccepted, responses[0].Code)
+
+	select {
+	case body := <-received:
+		assert.JSONEq(t, `{"event":"push","token":"[REDACTED]"}`, body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the fixture to be forwarded")
+	}
+
+	assert.Eventually(t, func() bool {
+		return harness.Metrics("/webhook")["successful_requests"] == int64(1)
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, harness.Metrics("/unknown"))
+}
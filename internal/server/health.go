@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// verboseHealthRequested reports whether a health request asks for the subsystem details,
+// with verbose=1 or any other true boolean
+func verboseHealthRequested(r *http.Request) bool {
+	verbose, err := strconv.ParseBool(r.URL.Query().Get("verbose"))
+	return err == nil && verbose
+}
+
+// addHealthDetails adds the status of each subsystem to a health response: the queues,
+// the configuration generation, the destinations and the telemetry exporter. The overall
+// status becomes degraded when a destination is paused, other than by a maintenance window,
+// or the exporter failed to start.
+func (s *Server) addHealthDetails(health map[string]interface{}) {
+	degraded := false
+
+	// Destinations, keyed by endpoint like the metrics
+	var dnsQueued, maintenanceQueued int
+	destinations := make(map[string]interface{}, len(s.proxyHandlers))
+	for key, handler := range s.proxyHandlers {
+		endpointHealth := handler.Health()
+		for _, dest := range endpointHealth {
+			status, _ := dest.(map[string]interface{})
+			queued, _ := status["queued"].(int)
+			switch status["reason"] {
+			case "maintenance":
+				maintenanceQueued += queued
+			case "dns_outage":
+				dnsQueued += queued
+			}
+			if status["status"] != "ok" && status["reason"] != "maintenance" {
+				degraded = true
+			}
+		}
+		destinations[key] = endpointHealth
+	}
+	health["destinations"] = destinations
+
+	// Deliveries waiting for a quota period, a destination or a retry
+	var quotaQueued int
+	for _, quota := range s.quotas {
+		if queued, ok := quota.snapshot()["queued"].(int); ok {
+			quotaQueued += queued
+		}
+	}
+	queues := map[string]interface{}{
+		"quota_queued":       quotaQueued,
+		"dns_queued":         dnsQueued,
+		"maintenance_queued": maintenanceQueued,
+	}
+	if s.retryStore != nil {
+		if records, err := s.retryStore.Load(); err == nil {
+			queues["retry_pending"] = len(records)
+		} else {
+			queues["retry_pending"] = "unknown"
+		}
+	}
+	health["queues"] = queues
+
+	health["config"] = map[string]interface{}{
+		"generation": s.generations.generation(),
+	}
+
+	// The exporter failed when telemetry is enabled but the server fell back to a noop tracer
+	telemetryHealth := map[string]interface{}{
+		"status":   "disabled",
+		"exporter": s.config.Telemetry.ExporterType,
+	}
+	if s.config.Telemetry.Enabled {
+		telemetryHealth["status"] = "ok"
+		if s.tracer == nil || !s.tracer.Enabled() {
+			telemetryHealth["status"] = "failed"
+			degraded = true
+		}
+	}
+	health["telemetry"] = telemetryHealth
+
+	if degraded {
+		health["status"] = "degraded"
+	}
+}
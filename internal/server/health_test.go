@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
+	"github.com/flemzord/webhook-proxy/internal/telemetry"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getHealth requests the health endpoint with the query and decodes the response
+func getHealth(t *testing.T, handler http.Handler, query string) map[string]interface{} {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var health map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	return health
+}
+
+func TestVerboseHealth(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	harness, err := NewHarness(&config.Config{Endpoints: []config.EndpointConfig{
+		{Path: "/webhook", Destinations: []config.DestinationConfig{{URL: "http://localhost:1/hook", Method: "POST"}}},
+	}}, log)
+	require.NoError(t, err)
+	defer harness.Close()
+
+	// The default response stays terse
+	health := getHealth(t, harness, "")
+	assert.Equal(t, "ok", health["status"])
+	assert.NotContains(t, health, "destinations")
+	assert.NotContains(t, getHealth(t, harness, "?verbose=0"), "destinations")
+
+	health = getHealth(t, harness, "?verbose=1")
+	assert.Equal(t, "ok", health["status"])
+	assert.NotEmpty(t, health["version"])
+	assert.Equal(t, map[string]interface{}{"generation": float64(1)}, health["config"])
+	assert.Equal(t, map[string]interface{}{"quota_queued": float64(0), "dns_queued": float64(0), "maintenance_queued": float64(0)}, health["queues"])
+	assert.Equal(t, map[string]interface{}{
+		"/webhook": map[string]interface{}{
+			"http://localhost:1/hook": map[string]interface{}{"status": "ok"},
+		},
+	}, health["destinations"])
+	assert.Equal(t, map[string]interface{}{"status": "disabled", "exporter": ""}, health["telemetry"])
+}
+
+func TestVerboseHealthDegraded(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	store, err := retrystore.Open(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, store.Save(retrystore.Record{ID: "1", Endpoint: "/webhook"}))
+
+	// Telemetry is enabled, but the server runs with the noop tracer
+	server := NewServer(&config.Config{Telemetry: config.TelemetryConfig{Enabled: true, ExporterType: "otlp"}}, log)
+	server.tracer = telemetry.NewNoopTracer()
+	server.retryStore = store
+	server.registerHealthCheckEndpoint()
+
+	health := getHealth(t, server.router, "?verbose=true")
+	assert.Equal(t, "degraded", health["status"])
+	assert.Equal(t, map[string]interface{}{"status": "failed", "exporter": "otlp"}, health["telemetry"])
+	assert.Equal(t, float64(1), health["queues"].(map[string]interface{})["retry_pending"])
+}
+
+func TestVerboseHealthMaintenance(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	// The window covers every day, so the destination is always in maintenance
+	harness, err := NewHarness(&config.Config{Endpoints: []config.EndpointConfig{
+		{Path: "/webhook", Destinations: []config.DestinationConfig{{
+			URL:                "http://localhost:1/hook",
+			Method:             "POST",
+			MaintenanceWindows: []config.MaintenanceWindowConfig{{Start: "00:00", Duration: 24 * time.Hour}},
+		}}},
+	}}, log)
+	require.NoError(t, err)
+	defer harness.Close()
+
+	// A planned maintenance does not degrade the proxy
+	health := getHealth(t, harness, "?verbose=1")
+	assert.Equal(t, "ok", health["status"])
+	assert.Equal(t, map[string]interface{}{
+		"/webhook": map[string]interface{}{
+			"http://localhost:1/hook": map[string]interface{}{"status": "paused", "reason": "maintenance", "queued": float64(0)},
+		},
+	}, health["destinations"])
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/history"
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// historyHook records the result of each attempt on the webhook it delivers
+type historyHook struct {
+	proxy.NopHook
+	store *history.Store
+}
+
+// AfterForward records the attempt, when the delivery belongs to a webhook of the history
+func (h *historyHook) AfterForward(event *proxy.Event) {
+	id, replay, ok := history.FromContext(event.Context)
+	if !ok {
+		return
+	}
+
+	result := history.Result{
+		Destination: event.Destination.Key(),
+		Attempt:     event.Attempt,
+		StatusCode:  event.StatusCode,
+		Duration:    event.Duration,
+		Headers:     event.ResponseHeaders,
+		Labels:      event.Destination.Labels,
+		At:          time.Now(),
+		Replay:      replay,
+	}
+	if event.Err != nil {
+		result.Error = event.Err.Error()
+	}
+	h.store.Record(id, result)
+}
+
+// resultsHook keeps the state of the deliveries of each webhook, for /deliveries/{id}
+type resultsHook struct {
+	proxy.NopHook
+	results *history.Results
+}
+
+// BeforeForward marks the delivery as in progress on its first attempt
+func (h *resultsHook) BeforeForward(event *proxy.Event) {
+	if event.Attempt != 1 {
+		return
+	}
+	if id, _, ok := history.FromContext(event.Context); ok {
+		h.results.Update(id, event.Destination.Key(), history.StateInProgress, event.Attempt, 0)
+	}
+}
+
+// AfterForward marks the delivery as delivered, or as retrying until OnDeadLetter gives up
+func (h *resultsHook) AfterForward(event *proxy.Event) {
+	id, _, ok := history.FromContext(event.Context)
+	if !ok {
+		return
+	}
+	state := history.StateDelivered
+	if event.Err != nil {
+		state = history.StateRetrying
+	}
+	h.results.Update(id, event.Destination.Key(), state, event.Attempt, event.StatusCode)
+}
+
+// OnDeadLetter marks the delivery as failed
+func (h *resultsHook) OnDeadLetter(event *proxy.Event) {
+	if id, _, ok := history.FromContext(event.Context); ok {
+		h.results.Update(id, event.Destination.Key(), history.StateFailed, event.Attempt, event.StatusCode)
+	}
+}
+
+// registerResultsEndpoint registers the route returning the delivery results of a webhook
+// to its sender, by the ID the endpoint answered with
+func (s *Server) registerResultsEndpoint() {
+	s.router.Get("/deliveries/{id}", func(w http.ResponseWriter, r *http.Request) {
+		status, ok := s.results.Get(chi.URLParam(r, "id"))
+		if !ok {
+			http.Error(w, "Delivery not found", http.StatusNotFound)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, status)
+	})
+}
+
+// registerHistoryEndpoints registers the routes listing, showing, replaying and re-running the
+// webhooks of the history. Replaying is a destructive admin action; re-running sends nothing.
+func (s *Server) registerHistoryEndpoints() {
+	s.router.Get("/admin/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		deliveries := s.history.List(r.URL.Query().Get("endpoint"))
+		for i := range deliveries {
+			deliveries[i].Labels = s.endpointLabels(deliveries[i].Endpoint)
+		}
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"count":      len(deliveries),
+			"deliveries": deliveries,
+		})
+	})
+
+	s.router.Get("/admin/deliveries/{id}", func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := s.history.Get(chi.URLParam(r, "id"))
+		if !ok {
+			http.Error(w, "Delivery not found", http.StatusNotFound)
+			return
+		}
+		entry.Labels = s.endpointLabels(entry.Webhook.Endpoint)
+		s.writeJSON(w, http.StatusOK, entry)
+	})
+
+	s.router.With(s.admin.middleware).Post("/admin/deliveries/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := s.history.Get(chi.URLParam(r, "id"))
+		if !ok {
+			http.Error(w, "Delivery not found", http.StatusNotFound)
+			return
+		}
+		destination := r.URL.Query().Get("destination")
+		if !s.replay(entry, destination) {
+			http.Error(w, "Endpoint or destination no longer configured", http.StatusConflict)
+			return
+		}
+		s.writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"id":          entry.Webhook.ID,
+			"destination": destination,
+		})
+	})
+
+	s.router.With(s.admin.middleware).Post("/admin/deliveries/{id}/rerun", func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := s.history.Get(chi.URLParam(r, "id"))
+		if !ok {
+			http.Error(w, "Delivery not found", http.StatusNotFound)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			http.Error(w, "Failed to read the candidate endpoint", http.StatusBadRequest)
+			return
+		}
+		endpoint, err := config.ParseEndpoint(data, entry.Webhook.Endpoint, s.config)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid candidate endpoint: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		requests := make([]rerunRequest, 0, len(endpoint.Destinations))
+		for _, preview := range proxy.PreviewWebhook(endpoint, entry.Webhook.Body, entry.Webhook.Headers, s.log) {
+			requests = append(requests, newRerunRequest(preview))
+		}
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":       entry.Webhook.ID,
+			"endpoint": endpoint.Path,
+			"requests": requests,
+		})
+	})
+}
+
+// rerunRequest is what a destination of a candidate endpoint would receive for a webhook of
+// the history, or why it would not receive it
+type rerunRequest struct {
+	Destination string            `json:"destination"`
+	Type        string            `json:"type"`
+	Skipped     string            `json:"skipped,omitempty"`
+	Method      string            `json:"method,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// newRerunRequest returns the request of a preview, with its headers joined by name
+func newRerunRequest(preview proxy.Preview) rerunRequest {
+	request := rerunRequest{
+		Destination: preview.Destination.Key(),
+		Type:        preview.Destination.Type,
+		Skipped:     preview.Skipped,
+		Method:      preview.Method,
+		URL:         preview.URL,
+		Body:        preview.Body,
+	}
+	if len(preview.Header) > 0 {
+		request.Headers = make(map[string]string, len(preview.Header))
+		for name, values := range preview.Header {
+			request.Headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return request
+}
+
+// replay forwards a webhook of the history again, to every destination of its endpoint or
+// to the given one only. It returns false when the endpoint or destination is no longer
+// configured. The attempts are recorded on the webhook, as replays.
+func (s *Server) replay(entry history.Entry, destination string) bool {
+	endpoint, ok := s.endpoint(entry.Webhook.Endpoint)
+	if !ok {
+		return false
+	}
+
+	ctx := history.WithWebhook(context.Background(), entry.Webhook.ID, true)
+	ctx = proxy.WithRequestID(ctx, entry.Webhook.RequestID)
+	if !s.proxyHandlers[handlerKey(endpoint)].ReplayWebhook(ctx, entry.Webhook, destination) {
+		return false
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"webhook_id":  entry.Webhook.ID,
+		"endpoint":    entry.Webhook.Endpoint,
+		"destination": destination,
+	}).Info("Replaying webhook")
+	return true
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/history"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deliveriesResponse is the body of /admin/deliveries
+type deliveriesResponse struct {
+	Count      int               `json:"count"`
+	Deliveries []history.Summary `json:"deliveries"`
+}
+
+func TestHistoryEndpoints(t *testing.T) {
+	var healthy atomic.Bool
+	delivered := make(chan string, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		delivered <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		History: config.HistoryConfig{Size: 10},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Labels: map[string]string{"team": "payments"}, Destinations: []config.DestinationConfig{
+			{URL: destination.URL, Method: "POST", Timeout: time.Second, CaptureHeaders: []string{"Retry-After"}, Labels: map[string]string{"team": "payments", "tier": "1"}},
+		}}},
+	}
+	server := NewServer(cfg, log)
+	require.NotNil(t, server.history)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerHistoryEndpoints()
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	list := func() deliveriesResponse {
+		w := serve(http.MethodGet, "/admin/deliveries?endpoint=/webhook")
+		require.Equal(t, http.StatusOK, w.Code)
+		var response deliveriesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"push"}`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	// The webhook is listed with the result of its failed delivery
+	require.Eventually(t, func() bool {
+		response := list()
+		return response.Count == 1 && len(response.Deliveries[0].Results) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	summary := list().Deliveries[0]
+	assert.Equal(t, "/webhook", summary.Endpoint)
+	assert.Equal(t, destination.URL, summary.Results[0].Destination)
+	assert.Equal(t, http.StatusServiceUnavailable, summary.Results[0].StatusCode)
+	assert.Equal(t, map[string]string{"Retry-After": "30"}, summary.Results[0].Headers)
+	assert.False(t, summary.Results[0].Replay)
+	assert.Equal(t, map[string]string{"team": "payments"}, summary.Labels)
+	assert.Equal(t, map[string]string{"team": "payments", "tier": "1"}, summary.Results[0].Labels)
+
+	// A webhook is shown with its body
+	w = serve(http.MethodGet, "/admin/deliveries/"+summary.ID)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"body":"eyJldmVudCI6InB1c2gifQ=="`)
+	assert.Contains(t, w.Body.String(), `"labels":{"team":"payments"}`)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/admin/deliveries/unknown").Code)
+
+	// Unknown webhooks and destinations cannot be replayed
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/admin/deliveries/unknown/replay").Code)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/admin/deliveries/"+summary.ID+"/replay?destination=https://removed.example.com").Code)
+
+	// Once the destination is back, the replay is delivered and recorded
+	healthy.Store(true)
+	assert.Equal(t, http.StatusAccepted, serve(http.MethodPost, "/admin/deliveries/"+summary.ID+"/replay?destination="+destination.URL).Code)
+	select {
+	case body := <-delivered:
+		assert.Equal(t, `{"event":"push"}`, body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be replayed")
+	}
+	require.Eventually(t, func() bool {
+		return len(list().Deliveries[0].Results) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	replayed := list().Deliveries[0].Results[1]
+	assert.True(t, replayed.Replay)
+	assert.Equal(t, http.StatusOK, replayed.StatusCode)
+	assert.Empty(t, replayed.Headers)
+}
+
+func TestResultsEndpoint(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		History: config.HistoryConfig{ResultsSize: 10},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: []config.DestinationConfig{
+			{URL: destination.URL, Method: "POST", Timeout: time.Second},
+		}}},
+	}
+	server := NewServer(cfg, log)
+	require.NotNil(t, server.results)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerResultsEndpoint()
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"push"}`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	// The response carries the ID the results are looked up with
+	var accepted struct {
+		Status string `json:"status"`
+		ID     string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+	require.NotEmpty(t, accepted.ID)
+
+	lookup := func() history.Status {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/deliveries/"+accepted.ID, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var status history.Status
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return status
+	}
+	require.Eventually(t, func() bool {
+		return lookup().State == history.StateDelivered
+	}, 2*time.Second, 10*time.Millisecond)
+	status := lookup()
+	assert.Equal(t, "/webhook", status.Endpoint)
+	assert.Equal(t, history.StateDelivered, status.Destinations[destination.URL].State)
+	assert.Equal(t, 1, status.Destinations[destination.URL].Attempts)
+	assert.Equal(t, http.StatusOK, status.Destinations[destination.URL].StatusCode)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/deliveries/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRerunEndpoint(t *testing.T) {
+	var received atomic.Int64
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer destination.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		Server:  config.ServerConfig{Admin: config.AdminConfig{ConfirmToken: "yes-really"}},
+		History: config.HistoryConfig{Size: 10},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: []config.DestinationConfig{
+			{URL: destination.URL, Method: "POST", Timeout: time.Second},
+		}}},
+	}
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerHistoryEndpoints()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"repository":{"full_name":"octo/hello"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Eventually(t, func() bool { return received.Load() == 1 }, 2*time.Second, 10*time.Millisecond)
+	id := server.history.List("")[0].ID
+
+	rerun := func(id, candidate string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/deliveries/"+id+"/rerun?confirm=yes-really", strings.NewReader(candidate)))
+		return w
+	}
+
+	// Re-running is guarded like the other admin actions
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/deliveries/"+id+"/rerun", strings.NewReader(`destinations: []`)))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// The candidate runs on the stored webhook, without sending anything
+	w = rerun(id, `
+destinations:
+  - url: "https://fixed.example.com/events"
+    headers:
+      X-Team: "platform"
+    transform:
+      template: '{"repo": {{json .Payload.repository.full_name}}}'
+  - url: "https://other.example.com/events"
+    filters:
+      - json_path: "repository.full_name"
+        equals: "octo/other"
+`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		ID       string         `json:"id"`
+		Endpoint string         `json:"endpoint"`
+		Requests []rerunRequest `json:"requests"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, id, response.ID)
+	assert.Equal(t, "/webhook", response.Endpoint)
+	require.Len(t, response.Requests, 2)
+
+	fixed := response.Requests[0]
+	assert.Equal(t, "https://fixed.example.com/events", fixed.Destination)
+	assert.Equal(t, config.DestinationTypeHTTP, fixed.Type)
+	assert.Equal(t, http.MethodPost, fixed.Method)
+	assert.Equal(t, "https://fixed.example.com/events", fixed.URL)
+	assert.Equal(t, "platform", fixed.Headers["X-Team"])
+	assert.JSONEq(t, `{"repo":"octo/hello"}`, string(fixed.Body))
+	assert.Equal(t, "does not match the destination filters", response.Requests[1].Skipped)
+	assert.Equal(t, int64(1), received.Load())
+
+	// Invalid candidates and unknown webhooks are rejected
+	w = rerun(id, `destinations: [{url: "not a url"}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid url")
+	assert.Equal(t, http.StatusBadRequest, rerun(id, `destinations: {`).Code)
+	assert.Equal(t, http.StatusNotFound, rerun("unknown", `destinations: []`).Code)
+
+	// Secret references of candidates are never resolved
+	w = rerun(id, `{"secret": "file:/etc/hostname", "destinations": [{"url": "https://example.com"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "secret references are not allowed")
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/sirupsen/logrus"
+)
+
+// listener serves the router on one port. A tenant listener only serves its endpoints and
+// the health check; the main listener serves every route but the endpoints of the tenant
+// listeners.
+type listener struct {
+	name     string
+	port     int
+	paths    map[string]bool
+	main     bool
+	next     http.Handler
+	requests atomic.Int64
+}
+
+// ServeHTTP serves the requests for the routes of the listener. Their context carries the
+// name of the listener, which identifies the tenant of their webhooks.
+func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if l.paths[r.URL.Path] == l.main {
+		http.NotFound(w, r)
+		return
+	}
+	l.requests.Add(1)
+	l.next.ServeHTTP(w, r.WithContext(proxy.WithListener(r.Context(), l.name)))
+}
+
+// newListeners returns the main listener followed by the tenant listeners
+func (s *Server) newListeners() []*listener {
+	main := &listener{
+		name:  config.DefaultListener,
+		port:  s.config.Server.Port,
+		paths: make(map[string]bool),
+		main:  true,
+		next:  s.router,
+	}
+	listeners := []*listener{main}
+	for _, cfg := range s.config.Server.Listeners {
+		l := &listener{
+			name:  cfg.Name,
+			port:  cfg.Port,
+			paths: make(map[string]bool, len(cfg.Endpoints)),
+			next:  s.router,
+		}
+		for _, path := range cfg.Endpoints {
+			l.paths[path] = true
+			main.paths[path] = true
+		}
+		// Each port is probed on its own by the load balancers in front of it
+		l.paths[healthPath] = true
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+// serve serves the router on the main port and, with tenant listeners, on each of their
+// ports, and returns the first error
+func (s *Server) serve(serverFunc HTTPServerFunc) error {
+	addr := s.addr(s.config.Server.Port)
+	if len(s.config.Server.Listeners) == 0 {
+		s.log.WithFields(logrus.Fields{
+			"address": addr,
+		}).Info("Starting HTTP server")
+		return serverFunc(addr, s.router)
+	}
+
+	s.listeners = s.newListeners()
+	errs := make(chan error, len(s.listeners))
+	for _, l := range s.listeners {
+		addr := s.addr(l.port)
+		s.log.WithFields(logrus.Fields{
+			"address":  addr,
+			"listener": l.name,
+		}).Info("Starting HTTP server")
+		go func(l *listener) {
+			errs <- serverFunc(addr, l)
+		}(l)
+	}
+	return <-errs
+}
+
+// addr returns the address of a port on the server's host
+func (s *Server) addr(port int) string {
+	return fmt.Sprintf("%s:%d", s.config.Server.Host, port)
+}
+
+// listenerMetrics returns the port and number of requests of each listener
+func (s *Server) listenerMetrics() map[string]interface{} {
+	metrics := make(map[string]interface{}, len(s.listeners))
+	for _, l := range s.listeners {
+		metrics[l.name] = map[string]interface{}{
+			"port":     l.port,
+			"requests": l.requests.Load(),
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantListeners(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Host: "localhost",
+			Port: 8080,
+			Listeners: []config.ListenerConfig{
+				{Name: "acme", Port: 9001, Endpoints: []string{"/acme"}},
+			},
+		},
+		Endpoints: []config.EndpointConfig{
+			{Path: "/acme", Destinations: []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: time.Second}}},
+			{Path: "/shared", Destinations: []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: time.Second}}},
+		},
+	}
+
+	log, hook := test.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+
+	var mu sync.Mutex
+	handlers := make(map[string]http.Handler)
+	server := NewServer(cfg, log)
+	server.forwardInline = true
+	err := server.StartWithServerFunc(func(addr string, handler http.Handler) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handlers[addr] = handler
+		return nil
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handlers) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	post := func(addr, path string) int {
+		w := httptest.NewRecorder()
+		handlers[addr].ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`)))
+		return w.Code
+	}
+
+	// Each endpoint is only served on its own port
+	assert.Equal(t, http.StatusAccepted, post("localhost:9001", "/acme"))
+	assert.Equal(t, http.StatusNotFound, post("localhost:8080", "/acme"))
+	assert.Equal(t, http.StatusAccepted, post("localhost:8080", "/shared"))
+	assert.Equal(t, http.StatusNotFound, post("localhost:9001", "/shared"))
+
+	// Admin routes stay on the main port
+	w := httptest.NewRecorder()
+	handlers["localhost:9001"].ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	handlers["localhost:8080"].ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var metrics struct {
+		Listeners map[string]struct {
+			Port     int   `json:"port"`
+			Requests int64 `json:"requests"`
+		} `json:"listeners"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, 9001, metrics.Listeners["acme"].Port)
+	assert.Equal(t, int64(1), metrics.Listeners["acme"].Requests)
+	assert.Equal(t, int64(2), metrics.Listeners[config.DefaultListener].Requests)
+
+	// Deliveries are logged with the listener their webhook was received on
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.proxyHandlers["/acme"].Drain(ctx))
+	var delivered bool
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "Webhook delivery completed" {
+			expected := map[string]string{"/acme": "acme", "/shared": config.DefaultListener}[entry.Data["endpoint"].(string)]
+			assert.Equal(t, expected, entry.Data["listener"])
+			delivered = true
+		}
+	}
+	assert.True(t, delivered)
+
+	// The health check is served on every port
+	for _, addr := range []string{"localhost:8080", "localhost:9001"} {
+		w = httptest.NewRecorder()
+		handlers[addr].ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		assert.Equal(t, http.StatusOK, w.Code, addr)
+	}
+}
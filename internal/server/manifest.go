@@ -0,0 +1,86 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/manifest"
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/sirupsen/logrus"
+)
+
+// manifestExportInterval is the period between two checks for ended manifest windows
+const manifestExportInterval = time.Minute
+
+// manifestHook journals the final outcome of the deliveries of every endpoint
+type manifestHook struct {
+	proxy.NopHook
+	exporter *manifest.Exporter
+	log      *logrus.Logger
+}
+
+// AfterForward journals the delivery when the attempt succeeded
+func (h *manifestHook) AfterForward(event *proxy.Event) {
+	if event.Err == nil {
+		h.record(event, manifest.OutcomeDelivered)
+	}
+}
+
+// OnDeadLetter journals the delivery that failed for good
+func (h *manifestHook) OnDeadLetter(event *proxy.Event) {
+	h.record(event, manifest.OutcomeDeadLetter)
+}
+
+// record journals the delivery with its outcome and the digest of the body sent
+func (h *manifestHook) record(event *proxy.Event, outcome string) {
+	sum := sha256.Sum256(event.Body)
+	entry := manifest.Entry{
+		Time:        time.Now(),
+		Endpoint:    event.Endpoint,
+		Destination: event.Destination.Key(),
+		DeliveryID:  event.ID,
+		Outcome:     outcome,
+		Attempts:    event.Attempt,
+		StatusCode:  event.StatusCode,
+		BodySHA256:  hex.EncodeToString(sum[:]),
+	}
+	if err := h.exporter.Record(entry); err != nil {
+		h.log.WithFields(logrus.Fields{
+			"error":       err,
+			"webhook_id":  event.WebhookID,
+			"delivery_id": event.ID,
+			"destination": entry.Destination,
+		}).Error("Failed to journal delivery for its manifest")
+	}
+}
+
+// exportManifests writes the manifests of the ended windows, checking every minute
+func (s *Server) exportManifests() {
+	ticker := time.NewTicker(manifestExportInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.writeManifests(time.Now())
+	}
+}
+
+// writeManifests writes the manifests of the windows ended by now
+func (s *Server) writeManifests(now time.Time) {
+	paths, err := s.manifests.Export(now)
+	for _, path := range paths {
+		s.log.WithField("path", path).Info("Wrote delivery manifest")
+	}
+	if err != nil {
+		s.log.WithError(err).Error("Failed to write delivery manifest")
+	}
+}
+
+// openManifests opens the manifest exporter of the configuration
+func openManifests(cfg config.ManifestConfig) (*manifest.Exporter, error) {
+	key, err := manifest.ParseSigningKey(cfg.Algorithm, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Open(cfg.Directory, cfg.Window, key, time.Now())
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/manifest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifests(t *testing.T) {
+	succeeding := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer succeeding.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Manifests: config.ManifestConfig{Directory: dir, Window: time.Hour, Algorithm: manifest.AlgorithmHMAC, Key: "manifest-secret"},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: []config.DestinationConfig{
+			{URL: succeeding.URL, Method: "POST", Timeout: time.Second},
+			{URL: failing.URL, Method: "POST", Timeout: time.Second},
+		}}},
+	}
+	server := NewServer(cfg, log)
+	require.NotNil(t, server.manifests)
+	defer server.manifests.Close()
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"push"}`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	require.Eventually(t, func() bool {
+		journals, _ := filepath.Glob(filepath.Join(dir, "journal-*.jsonl"))
+		if len(journals) != 1 {
+			return false
+		}
+		data, _ := os.ReadFile(journals[0])
+		return strings.Count(string(data), "\n") == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	server.writeManifests(time.Now().Add(time.Hour))
+	paths, err := filepath.Glob(filepath.Join(dir, "manifest-*.json"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	data, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	key, err := manifest.ParseVerificationKey(manifest.AlgorithmHMAC, "manifest-secret")
+	require.NoError(t, err)
+	m, err := manifest.Verify(data, key)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, m.Delivered)
+	assert.Equal(t, 1, m.DeadLetters)
+	for _, entry := range m.Entries {
+		assert.Equal(t, "/webhook", entry.Endpoint)
+		assert.Equal(t, "e845e2930aaff21b8e2d09a78424a580bbb00cf76877e75cfed1c63725771a2c", entry.BodySHA256)
+		if entry.Outcome == manifest.OutcomeDeadLetter {
+			assert.Equal(t, failing.URL, entry.Destination)
+			assert.Equal(t, http.StatusServiceUnavailable, entry.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// monitorSample is a sample of the proxy's state taken by the self-monitor
+type monitorSample struct {
+	SampledAt  time.Time `json:"sampled_at"`
+	DurationMs int64     `json:"duration_ms"`
+
+	// OverBudget is set when the sample was cut short, leaving some sections out
+	OverBudget bool `json:"over_budget"`
+
+	Runtime      monitorRuntime                           `json:"runtime"`
+	Queues       monitorQueues                            `json:"queues"`
+	Destinations map[string]map[string]monitorDestination `json:"destinations,omitempty"`
+}
+
+// monitorRuntime holds the Go runtime figures of a sample
+type monitorRuntime struct {
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	GCCycles       uint32 `json:"gc_cycles"`
+}
+
+// monitorQueues holds the depths of the queues of a sample, by endpoint
+type monitorQueues struct {
+	Quota        map[string]int `json:"quota"`
+	DNS          map[string]int `json:"dns"`
+	RetryPending *int           `json:"retry_pending,omitempty"`
+}
+
+// monitorDestination is the result of a destination probe
+type monitorDestination struct {
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// selfMonitor samples the proxy's state in a single background goroutine, apart from the
+// goroutines serving and delivering webhooks, and keeps the last sample for /admin/status
+type selfMonitor struct {
+	mu     sync.RWMutex
+	sample *monitorSample
+}
+
+// runMonitor samples the proxy's state now and then at the configured interval
+func (s *Server) runMonitor() {
+	interval := s.config.Server.Monitor.Interval
+	if interval <= 0 {
+		interval = config.DefaultMonitorInterval
+	}
+	s.sampleMonitor()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.sampleMonitor()
+	}
+}
+
+// sampleMonitor takes a sample within the monitor's budget and stores it. A panic while
+// sampling is logged and skips the sample, and never reaches the delivery goroutines.
+func (s *Server) sampleMonitor() {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.WithField("panic", r).Error("Self-monitoring sample failed")
+		}
+	}()
+
+	budget := s.config.Server.Monitor.Budget
+	if budget <= 0 {
+		budget = config.DefaultMonitorBudget
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	sample := s.takeSample(ctx)
+	s.monitor.mu.Lock()
+	s.monitor.sample = sample
+	s.monitor.mu.Unlock()
+
+	if sample.OverBudget {
+		s.log.WithFields(logrus.Fields{
+			"duration_ms": sample.DurationMs,
+			"budget":      budget,
+		}).Warn("Self-monitoring sample over budget, skipped the remaining sections")
+	}
+}
+
+// takeSample samples the runtime, the queues and, when enabled, the destinations, in that
+// order, skipping the remaining sections once the context is done
+func (s *Server) takeSample(ctx context.Context) *monitorSample {
+	start := time.Now()
+	sample := &monitorSample{SampledAt: start.UTC()}
+	defer func() {
+		sample.DurationMs = time.Since(start).Milliseconds()
+		sample.OverBudget = ctx.Err() != nil
+	}()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	sample.Runtime = monitorRuntime{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		GCCycles:       mem.NumGC,
+	}
+
+	sample.Queues = monitorQueues{Quota: make(map[string]int), DNS: make(map[string]int)}
+	for path, quota := range s.quotas {
+		if queued, ok := quota.snapshot()["queued"].(int); ok {
+			sample.Queues.Quota[path] = queued
+		}
+	}
+	for key, handler := range s.proxyHandlers {
+		for _, dest := range handler.Health() {
+			if queued, ok := dest.(map[string]interface{})["queued"].(int); ok {
+				sample.Queues.DNS[key] += queued
+			}
+		}
+	}
+	if s.retryStore != nil && ctx.Err() == nil {
+		if records, err := s.retryStore.Load(); err == nil {
+			pending := len(records)
+			sample.Queues.RetryPending = &pending
+		}
+	}
+
+	if !s.config.Server.Monitor.Probe {
+		return sample
+	}
+	sample.Destinations = make(map[string]map[string]monitorDestination, len(s.proxyHandlers))
+	for key, handler := range s.proxyHandlers {
+		if ctx.Err() != nil {
+			break
+		}
+		results := handler.Probe(ctx, s.config.Server.Startup.ProbeMethod)
+		if ctx.Err() != nil {
+			// Probes cut short by the budget say nothing of the destinations
+			break
+		}
+		destinations := make(map[string]monitorDestination)
+		for _, result := range results {
+			dest := monitorDestination{Reachable: result.Err == nil}
+			if result.Err != nil {
+				dest.Error = result.Err.Error()
+			}
+			destinations[result.Destination.Key()] = dest
+		}
+		sample.Destinations[key] = destinations
+	}
+	return sample
+}
+
+// registerStatusEndpoint registers /admin/status, serving the last self-monitoring sample
+// without computing anything on the request path
+func (s *Server) registerStatusEndpoint() {
+	s.router.Get("/admin/status", func(w http.ResponseWriter, _ *http.Request) {
+		s.monitor.mu.RLock()
+		sample := s.monitor.sample
+		s.monitor.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if sample == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"pending","message":"No self-monitoring sample yet"}`))
+			return
+		}
+		if err := json.NewEncoder(w).Encode(sample); err != nil {
+			s.log.WithError(err).Error("Failed to encode status response")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMonitoredServer creates a server with the self-monitor and its status endpoint
+func newMonitoredServer(t *testing.T, monitor config.MonitorConfig, destinations ...config.DestinationConfig) *Server {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		Server:    config.ServerConfig{Monitor: monitor, Startup: config.StartupConfig{ProbeMethod: http.MethodHead}},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: destinations}},
+	}
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerStatusEndpoint()
+	return server
+}
+
+// getStatus requests /admin/status and decodes the response
+func getStatus(t *testing.T, server *Server) (int, map[string]interface{}) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/status", nil))
+
+	var status map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	return w.Code, status
+}
+
+func TestSelfMonitor(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	server := newMonitoredServer(t,
+		config.MonitorConfig{Enabled: true, Interval: time.Minute, Budget: 5 * time.Second, Probe: true},
+		config.DestinationConfig{URL: destination.URL, Method: "POST", Timeout: time.Second},
+		config.DestinationConfig{URL: "http://127.0.0.1:1/down", Method: "POST", Timeout: time.Second},
+	)
+
+	// Nothing is computed on the request path before the first sample
+	code, status := getStatus(t, server)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "pending", status["status"])
+
+	server.sampleMonitor()
+
+	code, status = getStatus(t, server)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, status["over_budget"])
+	assert.Greater(t, status["runtime"].(map[string]interface{})["goroutines"], float64(0))
+	assert.Equal(t, map[string]interface{}{
+		"quota": map[string]interface{}{},
+		"dns":   map[string]interface{}{},
+	}, status["queues"])
+
+	destinations := status["destinations"].(map[string]interface{})["/webhook"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"reachable": true}, destinations[destination.URL])
+	down := destinations["http://127.0.0.1:1/down"].(map[string]interface{})
+	assert.Equal(t, false, down["reachable"])
+	assert.NotEmpty(t, down["error"])
+}
+
+func TestSelfMonitorBudget(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	server := newMonitoredServer(t,
+		config.MonitorConfig{Enabled: true, Interval: time.Minute, Budget: 50 * time.Millisecond, Probe: true},
+		config.DestinationConfig{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second},
+	)
+
+	// Probes cut short by the budget are left out of the sample
+	start := time.Now()
+	server.sampleMonitor()
+	assert.Less(t, time.Since(start), 400*time.Millisecond)
+
+	_, status := getStatus(t, server)
+	assert.Equal(t, true, status["over_budget"])
+	assert.Empty(t, status["destinations"])
+	assert.NotNil(t, status["runtime"])
+}
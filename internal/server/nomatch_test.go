@@ -0,0 +1,135 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/deadletter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newNoMatchServer returns a server whose endpoint only forwards the webhooks with the
+// X-Event header set to paid, to a destination reporting the bodies it receives
+func newNoMatchServer(t *testing.T, onNoMatch string) (*Server, chan string) {
+	delivered := make(chan string, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		delivered <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(destination.Close)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		DeadLetters: config.DeadLetterConfig{Directory: t.TempDir()},
+		Endpoints: []config.EndpointConfig{{
+			Path:      "/webhook",
+			OnNoMatch: onNoMatch,
+			Destinations: []config.DestinationConfig{{
+				URL: destination.URL, Method: "POST", Timeout: time.Second,
+				Filters: []config.FilterConfig{{Header: "X-Event", Equals: "paid"}},
+			}},
+		}},
+	}
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerDeadLetterEndpoints()
+	return server, delivered
+}
+
+// postEvent posts a webhook with the given X-Event header
+func postEvent(server *Server, event string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"`+event+`"}`))
+	req.Header.Set("X-Event", event)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOnNoMatchAccept(t *testing.T) {
+	server, delivered := newNoMatchServer(t, config.NoMatchAccept)
+
+	w := postEvent(server, "created")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	select {
+	case body := <-delivered:
+		t.Fatalf("Expected the webhook not to be forwarded, got %s", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, int64(1), server.proxyHandlers["/webhook"].GetMetrics()["unmatched_requests"])
+
+	// Matching webhooks are forwarded and not counted
+	assert.Equal(t, http.StatusAccepted, postEvent(server, "paid").Code)
+	select {
+	case body := <-delivered:
+		assert.Equal(t, `{"event":"paid"}`, body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be forwarded")
+	}
+	assert.Equal(t, int64(1), server.proxyHandlers["/webhook"].GetMetrics()["unmatched_requests"])
+}
+
+func TestOnNoMatchReject(t *testing.T) {
+	server, _ := newNoMatchServer(t, config.NoMatchReject)
+
+	w := postEvent(server, "created")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.NotEmpty(t, w.Header().Get(headerDeliveryID))
+	assert.Equal(t, int64(1), server.proxyHandlers["/webhook"].GetMetrics()["unmatched_requests"])
+
+	assert.Equal(t, http.StatusAccepted, postEvent(server, "paid").Code)
+}
+
+func TestOnNoMatchDeadLetter(t *testing.T) {
+	server, delivered := newNoMatchServer(t, config.NoMatchDeadLetter)
+
+	w := postEvent(server, "created")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// The webhook is saved without a destination
+	letters, err := server.deadLetters.List()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	letter := letters[0]
+	assert.Equal(t, w.Header().Get(headerDeliveryID), letter.ID)
+	assert.Equal(t, "/webhook", letter.Endpoint)
+	assert.Empty(t, letter.Destination)
+	assert.Zero(t, letter.Attempts)
+	assert.NotEmpty(t, letter.Error)
+
+	redrive := func() int {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/dead-letters/"+letter.ID+"/redrive", nil))
+		return w.Code
+	}
+
+	// The letter is kept while no destination matches it
+	assert.Equal(t, http.StatusConflict, redrive())
+
+	// Once it matches, it is forwarded and removed
+	require.NoError(t, server.deadLetters.Save(deadletter.Letter{
+		ID:       letter.ID,
+		Endpoint: letter.Endpoint,
+		Body:     []byte(`{"event":"paid"}`),
+		Headers:  map[string]string{"X-Event": "paid"},
+		FailedAt: time.Now(),
+	}))
+	assert.Equal(t, http.StatusAccepted, redrive())
+	select {
+	case body := <-delivered:
+		assert.Equal(t, `{"event":"paid"}`, body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the dead letter to be forwarded")
+	}
+	_, err = server.deadLetters.Get(letter.ID)
+	assert.ErrorIs(t, err, deadletter.ErrNotFound)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/queue"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+// enqueueWebhook persists a webhook accepted by an endpoint in the delivery queue
+func (s *Server) enqueueWebhook(delivery *webhook.Delivery) error {
+	return s.queue.Push(delivery)
+}
+
+// startQueue starts the workers forwarding the queued webhooks. When another process has
+// the queue locked, such as the process being upgraded, the workers forward the webhooks
+// this process queues, and those left by the other process once it unlocks the queue.
+func (s *Server) startQueue() {
+	go func() {
+		if err := s.queue.Lock(context.Background()); err != nil {
+			s.log.WithError(err).Error("Failed to take over the queued webhooks")
+		}
+		if pending, _ := s.queue.Depth(); pending > 0 {
+			s.log.WithField("queued", pending).Info("Resuming queued webhooks")
+		}
+	}()
+
+	for i := 0; i < s.config.Queue.Workers; i++ {
+		s.queueWorkers.Add(1)
+		go func() {
+			defer s.queueWorkers.Done()
+			s.drainQueue()
+		}()
+	}
+}
+
+// stopQueue stops the workers once they are done with the webhooks they are forwarding,
+// within the context, and unlocks the queue for the process taking it over. The webhooks
+// still queued are left on disk for that process. When the context is done first, the
+// queue stays locked until this process exits, so that the webhooks being forwarded are
+// not forwarded twice.
+func (s *Server) stopQueue(ctx context.Context) error {
+	s.queue.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.queueWorkers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return s.queue.Close()
+}
+
+// drainQueue forwards the queued webhooks one at a time, removing each from the queue once
+// its deliveries are done, until the queue is closed. Several workers drain the queue
+// concurrently.
+func (s *Server) drainQueue() {
+	for {
+		delivery, err := s.queue.Pop()
+		if errors.Is(err, queue.ErrClosed) {
+			return
+		}
+		if err != nil {
+			s.log.WithError(err).Error("Skipped unreadable queued webhook")
+			continue
+		}
+		s.deliverQueued(delivery)
+	}
+}
+
+// deliverQueued forwards a queued webhook on its endpoint's handler and waits for its
+// deliveries. Webhooks whose endpoint was removed from the configuration are dropped.
+func (s *Server) deliverQueued(delivery *webhook.Delivery) {
+	endpoint, ok := s.endpoint(delivery.Endpoint)
+	if ok {
+		s.forwardWebhook(endpoint, s.proxyHandlers[handlerKey(endpoint)], delivery, true)
+	} else {
+		s.log.WithFields(logrus.Fields{
+			"queue_id": delivery.ID,
+			"endpoint": delivery.Endpoint,
+		}).Warn("Dropping queued webhook for an endpoint that is no longer configured")
+	}
+
+	if err := s.queue.Ack(delivery.ID); err != nil {
+		s.log.WithFields(logrus.Fields{
+			"error":    err,
+			"queue_id": delivery.ID,
+		}).Error("Failed to remove delivered webhook from the queue, it will be forwarded again on restart")
+	}
+}
+
+// endpoint returns the configuration of the endpoint with the given path
+func (s *Server) endpoint(path string) (config.EndpointConfig, bool) {
+	for _, endpoint := range s.config.Endpoints {
+		if endpoint.Path == path {
+			return endpoint, true
+		}
+	}
+	return config.EndpointConfig{}, false
+}
+
+// endpointLabels returns the labels of the endpoint with the given path, if still configured
+func (s *Server) endpointLabels(path string) map[string]string {
+	endpoint, _ := s.endpoint(path)
+	return endpoint.Labels
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/queue"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queueConfig returns a configuration with a delivery queue in dir and one endpoint
+// forwarding to url
+func queueConfig(dir, url string) *config.Config {
+	return &config.Config{
+		Queue: config.QueueConfig{Directory: dir, Workers: 2},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: []config.DestinationConfig{
+			{URL: url, Method: "POST", Timeout: time.Second},
+		}}},
+	}
+}
+
+// queuedFiles returns the number of entries persisted in a queue directory
+func queuedFiles(t *testing.T, dir string) int {
+	entries, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	return len(entries)
+}
+
+func TestQueuedWebhookDelivery(t *testing.T) {
+	received := make(chan string, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dir := t.TempDir()
+	cfg := queueConfig(dir, destination.URL)
+	server := NewServer(cfg, log)
+	require.NotNil(t, server.queue)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerMetricsEndpoint()
+
+	// The webhook is persisted when it is acknowledged, before any worker runs
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"push"}`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, 1, queuedFiles(t, dir))
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics struct {
+		Queue map[string]int `json:"queue"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, map[string]int{"pending": 1, "in_flight": 0}, metrics.Queue)
+
+	// A worker forwards it, then removes it from the queue
+	go server.drainQueue()
+	select {
+	case body := <-received:
+		assert.Equal(t, `{"event":"push"}`, body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the queued webhook to be forwarded")
+	}
+	require.Eventually(t, func() bool {
+		return queuedFiles(t, dir) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestQueuedWebhookResume(t *testing.T) {
+	received := make(chan string, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Team")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	// Webhooks left in the queue by a previous run
+	dir := t.TempDir()
+	previous, err := queue.Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, previous.Push(&webhook.Delivery{
+		ID:         "kept",
+		Endpoint:   "/webhook",
+		Body:       []byte(`{}`),
+		Headers:    map[string]string{"X-Team": "payments"},
+		ReceivedAt: time.Now(),
+	}))
+	require.NoError(t, previous.Push(&webhook.Delivery{ID: "dropped", Endpoint: "/removed", Body: []byte(`{}`), ReceivedAt: time.Now()}))
+	require.NoError(t, previous.Close())
+
+	cfg := queueConfig(dir, destination.URL)
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+	go server.drainQueue()
+
+	select {
+	case team := <-received:
+		assert.Equal(t, "payments", team)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook queued before the restart to be forwarded")
+	}
+
+	// Both the delivered webhook and the one of a removed endpoint leave the queue
+	require.Eventually(t, func() bool {
+		return queuedFiles(t, dir) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestQueuedWebhookPersistFailure(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dir := t.TempDir()
+	cfg := queueConfig(dir, "http://127.0.0.1:1")
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	// A webhook that cannot be persisted is not acknowledged, so that the sender retries it
+	require.NoError(t, os.RemoveAll(dir))
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+)
+
+// quotaReleaseInterval is the period between two checks for queued webhooks to release
+const quotaReleaseInterval = time.Minute
+
+// quotaDecision is the fate of a webhook received by an endpoint with a quota
+type quotaDecision int
+
+const (
+	// quotaAllowed webhooks are within the quota and forwarded
+	quotaAllowed quotaDecision = iota
+	// quotaLogged webhooks are over the quota but forwarded, in log-only mode
+	quotaLogged
+	// quotaQueued webhooks are held until the quota resets
+	quotaQueued
+	// quotaRejected webhooks are refused
+	quotaRejected
+)
+
+// endpointQuota counts the webhooks forwarded by an endpoint per UTC day and month
+type endpointQuota struct {
+	config   config.QuotaConfig
+	now      func() time.Time
+	endpoint string
+	events   *events.Bus
+
+	mu       sync.Mutex
+	day      string
+	month    string
+	daily    int64
+	monthly  int64
+	rejected int64
+	exceeded int64
+	pending  []*webhook.Delivery
+
+	// highWater is set once the queue reaches its high-water mark, until it drains below it
+	highWater bool
+}
+
+// newEndpointQuota creates the quota of an endpoint
+func newEndpointQuota(cfg config.QuotaConfig) *endpointQuota {
+	return &endpointQuota{config: cfg, now: time.Now}
+}
+
+// admit counts a webhook against the quota and decides its fate. In queue mode,
+// webhooks also wait while older ones are queued, so that they are forwarded in order.
+func (q *endpointQuota) admit(delivery *webhook.Delivery) quotaDecision {
+	// The high-water event is published once the lock is released
+	var highWater int
+	defer func() {
+		if highWater > 0 {
+			q.events.Publish(events.Event{
+				Type:     events.QueueHighWater,
+				Endpoint: q.endpoint,
+				Message:  "Quota queue reached its high-water mark",
+				Fields: map[string]interface{}{
+					"queue":      "quota",
+					"queued":     highWater,
+					"queue_size": q.config.QueueSize,
+				},
+			})
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.roll()
+
+	if q.available() && len(q.pending) == 0 {
+		q.consume()
+		return quotaAllowed
+	}
+
+	switch q.config.OnExceed {
+	case config.QuotaLogOnly:
+		q.consume()
+		q.exceeded++
+		return quotaLogged
+	case config.QuotaQueue:
+		if len(q.pending) < q.config.QueueSize {
+			q.pending = append(q.pending, delivery)
+			if !q.highWater && len(q.pending) >= q.highWaterMark() {
+				q.highWater = true
+				highWater = len(q.pending)
+			}
+			return quotaQueued
+		}
+	}
+
+	q.rejected++
+	return quotaRejected
+}
+
+// release returns the queued webhooks that fit in the quota, oldest first
+func (q *endpointQuota) release() []*webhook.Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.roll()
+
+	n := 0
+	for n < len(q.pending) && q.available() {
+		q.consume()
+		n++
+	}
+
+	released := q.pending[:n:n]
+	q.pending = q.pending[n:]
+	if len(q.pending) < q.highWaterMark() {
+		q.highWater = false
+	}
+	return released
+}
+
+// highWaterMark returns the number of queued webhooks at which the queue is nearly
+// full: 80% of its size, rounded up
+func (q *endpointQuota) highWaterMark() int {
+	return (q.config.QueueSize*4 + 4) / 5
+}
+
+// resetIn returns the time until the exhausted quotas reset
+func (q *endpointQuota) resetIn() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	if q.config.Monthly > 0 && q.monthly >= q.config.Monthly {
+		reset = time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return reset.Sub(now)
+}
+
+// snapshot returns the quota consumption for the metrics
+func (q *endpointQuota) snapshot() map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.roll()
+
+	return map[string]interface{}{
+		"daily_used":    q.daily,
+		"daily_limit":   q.config.Daily,
+		"monthly_used":  q.monthly,
+		"monthly_limit": q.config.Monthly,
+		"on_exceed":     q.config.OnExceed,
+		"queued":        len(q.pending),
+		"rejected":      q.rejected,
+		"exceeded":      q.exceeded,
+	}
+}
+
+// roll resets the counters of the periods that ended. The caller holds the lock.
+func (q *endpointQuota) roll() {
+	now := q.now().UTC()
+	if day := now.Format(time.DateOnly); day != q.day {
+		q.day, q.daily = day, 0
+	}
+	if month := now.Format("2006-01"); month != q.month {
+		q.month, q.monthly = month, 0
+	}
+}
+
+// available reports whether a webhook fits in the quota. The caller holds the lock.
+func (q *endpointQuota) available() bool {
+	if q.config.Daily > 0 && q.daily >= q.config.Daily {
+		return false
+	}
+	return q.config.Monthly == 0 || q.monthly < q.config.Monthly
+}
+
+// consume counts a forwarded webhook. The caller holds the lock.
+func (q *endpointQuota) consume() {
+	q.daily++
+	q.monthly++
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestQuota creates a quota whose clock is set by the returned function
+func newTestQuota(cfg config.QuotaConfig, start time.Time) (*endpointQuota, func(time.Time)) {
+	now := start
+	quota := newEndpointQuota(cfg)
+	quota.now = func() time.Time { return now }
+	return quota, func(t time.Time) { now = t }
+}
+
+func TestEndpointQuotaReject(t *testing.T) {
+	start := time.Date(2024, 5, 31, 23, 0, 0, 0, time.UTC)
+	quota, setNow := newTestQuota(config.QuotaConfig{Daily: 2, Monthly: 3, OnExceed: config.QuotaReject}, start)
+
+	assert.Equal(t, quotaAllowed, quota.admit(&webhook.Delivery{}))
+	assert.Equal(t, quotaAllowed, quota.admit(&webhook.Delivery{}))
+	assert.Equal(t, quotaRejected, quota.admit(&webhook.Delivery{}))
+	assert.Equal(t, time.Hour, quota.resetIn())
+
+	// The daily quota resets at midnight UTC, the monthly one on the first day of the month
+	setNow(start.Add(2 * time.Hour))
+	assert.Equal(t, quotaAllowed, quota.admit(&webhook.Delivery{}))
+
+	snapshot := quota.snapshot()
+	assert.Equal(t, int64(1), snapshot["daily_used"])
+	assert.Equal(t, int64(1), snapshot["monthly_used"])
+	assert.Equal(t, int64(1), snapshot["rejected"])
+}
+
+func TestEndpointQuotaMonthlyResetIn(t *testing.T) {
+	start := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	quota, _ := newTestQuota(config.QuotaConfig{Monthly: 1, OnExceed: config.QuotaReject}, start)
+
+	assert.Equal(t, quotaAllowed, quota.admit(&webhook.Delivery{}))
+	assert.Equal(t, quotaRejected, quota.admit(&webhook.Delivery{}))
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).Sub(start), quota.resetIn())
+}
+
+func TestEndpointQuotaLogOnly(t *testing.T) {
+	quota, _ := newTestQuota(config.QuotaConfig{Daily: 1, OnExceed: config.QuotaLogOnly}, time.Now())
+
+	assert.Equal(t, quotaAllowed, quota.admit(&webhook.Delivery{}))
+	assert.Equal(t, quotaLogged, quota.admit(&webhook.Delivery{}))
+
+	snapshot := quota.snapshot()
+	assert.Equal(t, int64(2), snapshot["daily_used"])
+	assert.Equal(t, int64(1), snapshot["exceeded"])
+}
+
+func TestEndpointQuotaQueue(t *testing.T) {
+	start := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	quota, setNow := newTestQuota(config.QuotaConfig{Daily: 1, OnExceed: config.QuotaQueue, QueueSize: 2}, start)
+
+	assert.Equal(t, quotaAllowed, quota.admit(&webhook.Delivery{Body: []byte("1")}))
+	assert.Equal(t, quotaQueued, quota.admit(&webhook.Delivery{Body: []byte("2")}))
+	assert.Equal(t, quotaQueued, quota.admit(&webhook.Delivery{Body: []byte("3")}))
+	assert.Equal(t, quotaRejected, quota.admit(&webhook.Delivery{Body: []byte("4")}))
+	assert.Empty(t, quota.release())
+
+	// Once the quota resets, the queued webhooks are released in order, within the quota
+	setNow(start.Add(24 * time.Hour))
+	released := quota.release()
+	require.Len(t, released, 1)
+	assert.Equal(t, []byte("2"), released[0].Body)
+
+	// New webhooks wait behind the queued ones
+	setNow(start.Add(48 * time.Hour))
+	assert.Equal(t, quotaQueued, quota.admit(&webhook.Delivery{Body: []byte("5")}))
+	released = quota.release()
+	require.Len(t, released, 1)
+	assert.Equal(t, []byte("3"), released[0].Body)
+	assert.Equal(t, 1, quota.snapshot()["queued"])
+}
+
+func TestEndpointQuotaHighWater(t *testing.T) {
+	start := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	quota, setNow := newTestQuota(config.QuotaConfig{Daily: 1, OnExceed: config.QuotaQueue, QueueSize: 5}, start)
+	quota.endpoint, quota.events = "/webhook", events.NewBus()
+
+	var published []events.Event
+	quota.events.Subscribe(func(event events.Event) { published = append(published, event) })
+
+	// The event is published once when the queue reaches 80% of its size
+	for i := 0; i < 6; i++ {
+		quota.admit(&webhook.Delivery{})
+	}
+	require.Len(t, published, 1)
+	assert.Equal(t, events.QueueHighWater, published[0].Type)
+	assert.Equal(t, "/webhook", published[0].Endpoint)
+	assert.Equal(t, 4, published[0].Fields["queued"])
+
+	// And again once the queue drained below the mark and filled up again
+	setNow(start.Add(24 * time.Hour))
+	quota.release()
+	setNow(start.Add(48 * time.Hour))
+	quota.release()
+	assert.Equal(t, 3, quota.snapshot()["queued"])
+	quota.admit(&webhook.Delivery{})
+	assert.Len(t, published, 2)
+}
+
+func TestRegisterEndpointQuota(t *testing.T) {
+	received := make(chan string, 2)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{{
+			Path:         "/webhook/paid",
+			Destinations: []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second}},
+			Quota:        &config.QuotaConfig{Daily: 1, OnExceed: config.QuotaQueue, QueueSize: 1},
+		}},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerMetricsEndpoint()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook/paid", strings.NewReader(body)))
+		return w
+	}
+
+	assert.Equal(t, http.StatusAccepted, post("first").Code)
+	assert.Equal(t, http.StatusAccepted, post("queued").Code)
+
+	rejected := post("rejected")
+	assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	assert.NotEmpty(t, rejected.Header().Get("Retry-After"))
+
+	select {
+	case body := <-received:
+		assert.Equal(t, "first", body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook within the quota to be forwarded")
+	}
+
+	// The quota consumption is reported in the metrics
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `"quotas":{"/webhook/paid":{`)
+
+	// The queued webhook is forwarded once the quota resets
+	quota := server.quotas["/webhook/paid"]
+	quota.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	server.releaseQuotas()
+
+	select {
+	case body := <-received:
+		assert.Equal(t, "queued", body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the queued webhook to be forwarded")
+	}
+}
+
+func TestQuotaUntouchedByRejectedWebhooks(t *testing.T) {
+	cfg := &config.Config{
+		Outbound: config.OutboundConfig{
+			Deliveries: &config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 0, Overflow: config.DeliveriesOverflowReject},
+		},
+		Endpoints: []config.EndpointConfig{{
+			Path:         "/webhook/paid",
+			Destinations: []config.DestinationConfig{{URL: "http://127.0.0.1:0", Method: "POST", Timeout: time.Second}},
+			Quota:        &config.QuotaConfig{Daily: 1, OnExceed: config.QuotaQueue, QueueSize: 1},
+		}},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	// A webhook rejected because the delivery queue is full neither uses the quota nor waits
+	// for it to reset, so that its redelivery is not forwarded twice
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook/paid", strings.NewReader("rejected")))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	}
+
+	snapshot := server.quotas["/webhook/paid"].snapshot()
+	assert.Equal(t, int64(0), snapshot["daily_used"])
+	assert.Equal(t, 0, snapshot["queued"])
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	reports := make(chan CrashReport, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report CrashReport
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+		reports <- report
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer collector.Close()
+
+	log, hook := test.NewNullLogger()
+	cfg := &config.Config{Server: config.ServerConfig{CrashReports: config.CrashReportConfig{URL: collector.URL}}}
+	server := NewServer(cfg, log)
+	server.router.Get("/boom", func(http.ResponseWriter, *http.Request) {
+		panic("something went wrong")
+	})
+	server.registerMetricsEndpoint()
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// The panic is logged with its stack trace as structured fields
+	var entry *logrus.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.ErrorLevel {
+			entry = e
+		}
+	}
+	require.NotNil(t, entry)
+	assert.Equal(t, "something went wrong", entry.Data["panic"])
+	assert.Equal(t, "/boom", entry.Data["path"])
+	assert.Contains(t, entry.Data["stack"], "recoverPanics")
+
+	select {
+	case report := <-reports:
+		assert.Equal(t, "something went wrong", report.Panic)
+		assert.Equal(t, http.MethodGet, report.Method)
+		assert.Equal(t, "/boom", report.Path)
+		assert.NotEmpty(t, report.RequestID)
+		assert.NotEmpty(t, report.Stack)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a crash report")
+	}
+
+	// The panics are counted in the metrics
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics struct {
+		Global map[string]interface{} `json:"global"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, float64(1), metrics.Global["panics"])
+}
+
+func TestRecoverPanicsAbortHandler(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	server := NewServer(&config.Config{}, log)
+	server.router.Get("/abort", func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		server.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+	})
+	assert.Equal(t, int64(0), server.panics.Load())
+}
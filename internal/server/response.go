@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/formdata"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/flemzord/webhook-proxy/internal/xmldata"
+)
+
+// headerDeliveryID is the response header carrying the ID of an accepted webhook, the one
+// found in its log lines, results and history
+const headerDeliveryID = "X-Delivery-ID"
+
+// headerDuplicate is the response header marking a redelivery answered without being forwarded
+const headerDuplicate = "X-Duplicate"
+
+// endpointResponse is the response returned to the sender of an accepted webhook
+type endpointResponse struct {
+	statusCode int
+	headers    map[string]string
+	body       *template.Template
+
+	// usesPayload avoids parsing the payload for templates that do not use it
+	usesPayload bool
+}
+
+// responseData is the data available to response body templates
+type responseData struct {
+	// ID is the proxy's ID of the webhook, whose delivery results are served on /deliveries/{id}
+	ID string
+
+	Endpoint   string
+	RequestID  string
+	Provider   string
+	DeliveryID string
+	EventType  string
+
+	// Fields are the routing fields extracted by the provider preset, such as repo for GitHub
+	Fields map[string]string
+
+	Payload interface{}
+}
+
+// newEndpointResponse creates the response of an endpoint, the default one if cfg is nil
+func newEndpointResponse(cfg *config.ResponseConfig) (*endpointResponse, error) {
+	if cfg == nil {
+		cfg = &config.ResponseConfig{StatusCode: http.StatusAccepted, Body: config.DefaultResponseBody}
+	}
+
+	body, err := template.New("response").Parse(cfg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response body template: %w", err)
+	}
+
+	return &endpointResponse{
+		statusCode:  cfg.StatusCode,
+		headers:     cfg.Headers,
+		body:        body,
+		usesPayload: strings.Contains(cfg.Body, ".Payload"),
+	}, nil
+}
+
+// render renders the response body. The JSON, form or XML payload of the webhook is made
+// available to the template when it uses it; a payload that cannot be parsed is nil.
+func (r *endpointResponse) render(data responseData, delivery *webhook.Delivery) ([]byte, error) {
+	if r.usesPayload {
+		contentType := delivery.ContentType()
+		switch {
+		case formdata.IsForm(contentType):
+			if form, err := formdata.Parse(delivery.Body, contentType); err == nil {
+				data.Payload = form
+			}
+		case xmldata.IsXML(contentType):
+			if root, err := xmldata.Parse(delivery.Body); err == nil {
+				data.Payload = root.ToMap()
+			}
+		default:
+			data.Payload, _ = delivery.JSON()
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := r.body.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render response body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// write writes the status, headers and rendered body
+func (r *endpointResponse) write(w http.ResponseWriter, body []byte) error {
+	for name, value := range r.headers {
+		w.Header().Set(name, value)
+	}
+	w.WriteHeader(r.statusCode)
+
+	_, err := w.Write(body)
+	return err
+}
+
+// defaultResponseBody returns the default body for the webhook with the given ID, used when
+// an endpoint's own body fails to render
+func defaultResponseBody(id string) []byte {
+	return []byte(strings.Replace(config.DefaultResponseBody, "{{.ID}}", id, 1))
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointResponseDefault(t *testing.T) {
+	response, err := newEndpointResponse(nil)
+	require.NoError(t, err)
+
+	body, err := response.render(responseData{ID: "abc", Endpoint: "/webhook"}, webhook.New("/webhook", []byte(`{}`), map[string]string{"Content-Type": "application/json"}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, response.write(w, body))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, `{"status":"accepted","id":"abc"}`, w.Body.String())
+}
+
+func TestEndpointResponseTemplate(t *testing.T) {
+	response, err := newEndpointResponse(&config.ResponseConfig{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/json", "X-Received": "true"},
+		Body:       `{"id":"{{.DeliveryID}}","event":"{{.EventType}}","request":"{{.RequestID}}","challenge":"{{.Payload.challenge}}","team":"{{.Fields.team}}"}`,
+	})
+	require.NoError(t, err)
+
+	body, err := response.render(responseData{
+		Endpoint:   "/webhook/slack",
+		RequestID:  "req-1",
+		Provider:   config.ProviderSlack,
+		DeliveryID: "Ev1",
+		EventType:  "url_verification",
+		Fields:     map[string]string{"event": "url_verification", "team": "T1"},
+	}, webhook.New("/webhook/slack", []byte(`{"type":"url_verification","challenge":"abc"}`), map[string]string{"Content-Type": "application/json"}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, response.write(w, body))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "true", w.Header().Get("X-Received"))
+	assert.Equal(t, `{"id":"Ev1","event":"url_verification","request":"req-1","challenge":"abc","team":"T1"}`, w.Body.String())
+}
+
+func TestEndpointResponseNonJSONPayload(t *testing.T) {
+	response, err := newEndpointResponse(&config.ResponseConfig{StatusCode: http.StatusOK, Body: `{{if .Payload}}json{{else}}raw{{end}}`})
+	require.NoError(t, err)
+
+	body, err := response.render(responseData{}, webhook.New("/webhook", []byte(`token=abc`), map[string]string{"Content-Type": "text/plain"}))
+	require.NoError(t, err)
+	assert.Equal(t, "raw", string(body))
+}
+
+func TestEndpointResponseInvalidTemplate(t *testing.T) {
+	_, err := newEndpointResponse(&config.ResponseConfig{StatusCode: http.StatusOK, Body: `{{.Unclosed`})
+	assert.Error(t, err)
+}
+
+func TestEndpointResponseFormPayload(t *testing.T) {
+	response, err := newEndpointResponse(&config.ResponseConfig{StatusCode: http.StatusOK, Body: `<Response>{{.Payload.MessageSid}}</Response>`})
+	require.NoError(t, err)
+
+	body, err := response.render(responseData{}, webhook.New("/webhook", []byte(`MessageSid=SM123&Body=hello`), map[string]string{"Content-Type": "application/x-www-form-urlencoded"}))
+	require.NoError(t, err)
+	assert.Equal(t, "<Response>SM123</Response>", string(body))
+}
+
+func TestEndpointResponseXMLPayload(t *testing.T) {
+	response, err := newEndpointResponse(&config.ResponseConfig{StatusCode: http.StatusOK, Body: `{{.Payload.Notification.MessageId}}`})
+	require.NoError(t, err)
+
+	body, err := response.render(responseData{}, webhook.New("/webhook", []byte(`<Notification><MessageId>m-1</MessageId></Notification>`), map[string]string{"Content-Type": "text/xml"}))
+	require.NoError(t, err)
+	assert.Equal(t, "m-1", string(body))
+}
@@ -3,15 +3,37 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/flemzord/webhook-proxy/internal/accounting"
+	"github.com/flemzord/webhook-proxy/internal/alert"
+	"github.com/flemzord/webhook-proxy/internal/bufpool"
+	"github.com/flemzord/webhook-proxy/internal/charset"
 	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/deadletter"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/flemzord/webhook-proxy/internal/fixture"
+	"github.com/flemzord/webhook-proxy/internal/history"
 	"github.com/flemzord/webhook-proxy/internal/logger"
+	"github.com/flemzord/webhook-proxy/internal/manifest"
+	"github.com/flemzord/webhook-proxy/internal/provider"
 	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/flemzord/webhook-proxy/internal/queue"
+	"github.com/flemzord/webhook-proxy/internal/rawheaders"
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
+	"github.com/flemzord/webhook-proxy/internal/sink"
+	"github.com/flemzord/webhook-proxy/internal/stats"
 	"github.com/flemzord/webhook-proxy/internal/telemetry"
+	"github.com/flemzord/webhook-proxy/internal/webhook"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/sirupsen/logrus"
@@ -26,6 +48,40 @@ type Server struct {
 	proxyHandlers map[string]*proxy.Handler
 	version       string
 	tracer        *telemetry.Tracer
+	suppressor    *logger.ErrorSuppressor
+	hooks         []proxy.Hook
+	retryStore    *retrystore.Store
+	deadLetters   *deadletter.Store
+	history       *history.Store
+	results       *history.Results
+	deliveries    *proxy.DeliveryPool
+	queue         *queue.Queue
+	queueWorkers  sync.WaitGroup
+	stats         *stats.Store
+	accounting    *accounting.Counters
+	manifests     *manifest.Exporter
+	recorder      *fixture.Recorder
+	admin         *adminGuard
+	quotas        map[string]*endpointQuota
+	dedups        map[string]*endpointDedup
+	rejections    map[string]*atomic.Int64 // webhooks with an invalid signature, by endpoint
+	disconnects   map[string]*atomic.Int64 // webhooks whose sender disconnected mid-body, by endpoint
+	generations   *configGenerations
+	events        *events.Bus
+	eventCounts   *events.Counter
+	monitor       selfMonitor
+	panics        atomic.Int64
+	listeners     []*listener // the main and tenant listeners, when there are tenant listeners
+	upgrades      *upgrades   // the graceful upgrades, when enabled
+
+	// forwardInline starts the deliveries of a webhook before answering it, instead of in
+	// a goroutine, so that harnesses find them in flight once the webhook is answered
+	forwardInline bool
+
+	// transport and retryTimer, when set, replace the connections of the HTTP destinations
+	// and the timers of the retry delays, for harnesses
+	transport  http.RoundTripper
+	retryTimer func(time.Duration) <-chan time.Time
 }
 
 // HTTPServerFunc is a function type that matches http.ListenAndServe
@@ -44,31 +100,187 @@ func NewServer(cfg *config.Config, log *logrus.Logger) *Server {
 	router := chi.NewRouter()
 
 	// Add middleware
-	router.Use(middleware.Recoverer)
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
 	router.Use(middleware.Timeout(30 * time.Second))
+	router.Use(rawheaders.Middleware)
 
 	// Create a tracer
-	tracer, err := telemetry.NewTracer(context.Background(), telemetry.Config{
-		ServiceName:    "webhook-proxy",
-		ServiceVersion: "1.0.0", // This will be updated with SetVersion
-		ExporterType:   cfg.Telemetry.ExporterType,
-		Endpoint:       cfg.Telemetry.Endpoint,
-		Enabled:        cfg.Telemetry.Enabled,
-	}, log)
+	tracer, err := telemetry.NewTracer(context.Background(), tracerConfig(cfg.Telemetry, "1.0.0"), log) // The version is updated with SetVersion
 	if err != nil {
 		log.WithError(err).Warn("Failed to create tracer, using noop tracer")
 		tracer = telemetry.NewNoopTracer()
 	}
 
+	// Lifecycle events are logged and counted in the metrics
+	bus := events.NewBus()
+	bus.Subscribe(events.LogSubscriber(log))
+	eventCounts := events.NewCounter()
+	bus.Subscribe(eventCounts.Record)
+
+	// Alerts report the proxy's own problems to operators
+	if len(cfg.Alerts) > 0 {
+		bus.Subscribe(alert.NewManager(cfg.Alerts, log).Handle)
+		log.WithField("alerts", len(cfg.Alerts)).Info("Alerting enabled")
+	}
+
 	server := &Server{
 		config:        cfg,
 		router:        router,
 		log:           log,
 		proxyHandlers: make(map[string]*proxy.Handler),
+		quotas:        make(map[string]*endpointQuota),
+		dedups:        make(map[string]*endpointDedup),
+		rejections:    make(map[string]*atomic.Int64),
+		disconnects:   make(map[string]*atomic.Int64),
+		generations:   newConfigGenerations(),
+		events:        bus,
+		eventCounts:   eventCounts,
 		version:       "1.0.0",
 		tracer:        tracer,
+		admin:         newAdminGuard(cfg.Server.Admin, log),
+	}
+
+	// Persist deliveries waiting for a retry so they survive restarts
+	if cfg.RetryState.Directory != "" {
+		store, err := retrystore.Open(cfg.RetryState.Directory)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error":     err,
+				"directory": cfg.RetryState.Directory,
+			}).Error("Failed to open retry state store, retries will not survive restarts")
+		} else {
+			server.retryStore = store
+		}
+	}
+
+	// Keep the deliveries that failed for good, to be inspected and sent again
+	if cfg.DeadLetters.Directory != "" {
+		store, err := deadletter.Open(cfg.DeadLetters.Directory)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error":     err,
+				"directory": cfg.DeadLetters.Directory,
+			}).Error("Failed to open dead letter store, failed deliveries will not be kept")
+		} else {
+			server.deadLetters = store
+			server.AddHook(&deadLetterHook{store: store, log: log})
+		}
+	}
+
+	// Bound the deliveries running at once, across the endpoints
+	if cfg.Outbound.Deliveries != nil {
+		server.deliveries = proxy.NewDeliveryPool(*cfg.Outbound.Deliveries)
+	}
+
+	// Keep the last webhooks with their delivery results, to be looked up and replayed
+	if cfg.History.Size > 0 {
+		server.history = history.New(cfg.History.Size)
+		server.AddHook(&historyHook{store: server.history})
+	}
+	if cfg.History.ResultsSize > 0 {
+		server.results = history.NewResults(cfg.History.ResultsSize)
+		server.AddHook(&resultsHook{results: server.results})
+	}
+
+	// Persist the accepted webhooks until they are forwarded, so that none is lost on a crash
+	if cfg.Queue.Directory != "" {
+		q, err := queue.Open(cfg.Queue.Directory)
+		switch {
+		case errors.Is(err, queue.ErrLocked):
+			log.WithField("directory", cfg.Queue.Directory).Info("Delivery queue locked by another process, taking it over once it is drained")
+			server.queue = q
+		case q == nil:
+			log.WithFields(logrus.Fields{
+				"error":     err,
+				"directory": cfg.Queue.Directory,
+			}).Error("Failed to open delivery queue, webhooks will be forwarded without being persisted")
+		case err != nil:
+			log.WithFields(logrus.Fields{
+				"error":     err,
+				"directory": cfg.Queue.Directory,
+			}).Error("Skipped unreadable queued webhooks")
+			fallthrough
+		default:
+			server.queue = q
+		}
+	}
+
+	// Roll up the deliveries per day so that trends survive restarts
+	if cfg.Stats.Directory != "" {
+		store, err := stats.Open(cfg.Stats.Directory, cfg.Stats.RetentionDays, time.Now())
+		switch {
+		case store == nil:
+			log.WithFields(logrus.Fields{
+				"error":     err,
+				"directory": cfg.Stats.Directory,
+			}).Error("Failed to open stats directory, delivery statistics will not be persisted")
+		case err != nil:
+			log.WithFields(logrus.Fields{
+				"error":     err,
+				"directory": cfg.Stats.Directory,
+			}).Warn("Skipped unreadable delivery statistics")
+			fallthrough
+		default:
+			server.stats = store
+			server.AddHook(&statsHook{store: store})
+		}
+	}
+
+	// Account for the delivered webhooks, for billing internal customers
+	if cfg.Accounting.CSVPath != "" {
+		file, err := accounting.OpenCSV(cfg.Accounting.CSVPath, log)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error": err,
+				"path":  cfg.Accounting.CSVPath,
+			}).Error("Failed to open accounting file, deliveries will not be written to it")
+		} else {
+			server.AddHook(accounting.NewHook(file))
+		}
+	}
+	if cfg.Accounting.Prometheus {
+		server.accounting = accounting.NewCounters()
+		server.AddHook(accounting.NewHook(server.accounting))
+	}
+
+	// Journal the delivery outcomes for the signed manifests handed to auditors
+	if cfg.Manifests.Directory != "" {
+		exporter, err := openManifests(cfg.Manifests)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error":     err,
+				"directory": cfg.Manifests.Directory,
+			}).Error("Failed to open manifest directory, delivery manifests will not be written")
+		} else {
+			server.manifests = exporter
+			server.AddHook(&manifestHook{exporter: exporter, log: log})
+			log.WithFields(logrus.Fields{
+				"directory":  cfg.Manifests.Directory,
+				"window":     cfg.Manifests.Window,
+				"algorithm":  cfg.Manifests.Algorithm,
+				"public_key": exporter.PublicKey(),
+			}).Info("Writing signed delivery manifests")
+		}
+	}
+
+	// Record accepted webhooks as fixtures for integration tests
+	if cfg.Recording.Directory != "" {
+		recorder, err := fixture.NewRecorder(cfg.Recording)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error":     err,
+				"directory": cfg.Recording.Directory,
+			}).Error("Failed to open recording directory, webhooks will not be recorded")
+		} else {
+			server.recorder = recorder
+			log.WithField("directory", cfg.Recording.Directory).Warn("Recording received webhooks as fixtures")
+		}
+	}
+
+	// Share the error suppressor between endpoints so identical errors are grouped
+	if cfg.Logging.ErrorSuppression.Enabled {
+		server.suppressor = logger.NewErrorSuppressor(log, cfg.Logging.ErrorSuppression.Window)
 	}
 
 	// Add custom logger and tracing middleware
@@ -84,6 +296,9 @@ func NewServer(cfg *config.Config, log *logrus.Logger) *Server {
 			telemetry.AddAttribute(ctx, "http.host", r.Host)
 			telemetry.AddAttribute(ctx, "http.user_agent", r.UserAgent())
 			telemetry.AddAttribute(ctx, "http.request_id", middleware.GetReqID(ctx))
+			if name := proxy.Listener(ctx); name != "" {
+				telemetry.AddAttribute(ctx, "http.listener", name)
+			}
 
 			// Update the request with the new context
 			r = r.WithContext(ctx)
@@ -91,9 +306,17 @@ func NewServer(cfg *config.Config, log *logrus.Logger) *Server {
 			// Process request
 			next.ServeHTTP(w, r)
 
-			// Log after request
+			// Log after request, with the listener it was received on and the ID of the
+			// webhook it was accepted as
+			var received logrus.FieldLogger = log
+			if name := proxy.Listener(ctx); name != "" {
+				received = received.WithField("listener", name)
+			}
+			if id := w.Header().Get(headerDeliveryID); id != "" {
+				received = received.WithField("webhook_id", id)
+			}
 			logger.LogWebhookReceived(
-				log,
+				received,
 				r.URL.Path,
 				r.Method,
 				r.RemoteAddr,
@@ -102,48 +325,368 @@ func NewServer(cfg *config.Config, log *logrus.Logger) *Server {
 		})
 	})
 
+	// Compress JSON responses, including the error responses of recovered panics
+	router.Use(server.compressResponses)
+
+	// Recover from panics within the request span, so that the span records them
+	router.Use(server.recoverPanics)
+
 	return server
 }
 
-// Start starts the HTTP server
+// AddHook registers a delivery lifecycle hook on every endpoint. It must be called before Start.
+func (s *Server) AddHook(hook proxy.Hook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// Subscribe registers a subscriber for the given types of lifecycle events of every
+// endpoint, or for all of them when no type is given, and returns the function
+// cancelling the subscription
+func (s *Server) Subscribe(subscriber events.Subscriber, types ...events.Type) func() {
+	return s.events.Subscribe(subscriber, types...)
+}
+
+// Start starts the HTTP server. With graceful upgrades, it returns once a new process took
+// the sockets over and this one is drained.
 func (s *Server) Start() error {
-	return s.StartWithServerFunc(DefaultHTTPServerFunc)
+	if s.config.Server.Upgrade.Enabled {
+		return s.startUpgradable()
+	}
+	return s.StartWithServerFunc(s.defaultServerFunc())
+}
+
+// defaultServerFunc returns the server function serving the endpoints. Endpoints preserving
+// raw headers need a listener capturing them.
+func (s *Server) defaultServerFunc() HTTPServerFunc {
+	if s.preservesRawHeaders() {
+		return rawheaders.ListenAndServe
+	}
+	return DefaultHTTPServerFunc
+}
+
+// preservesRawHeaders reports whether an endpoint preserves the raw headers of its requests
+func (s *Server) preservesRawHeaders() bool {
+	for _, endpoint := range s.config.Endpoints {
+		if endpoint.PreserveRawHeaders {
+			return true
+		}
+	}
+	return false
 }
 
 // StartWithServerFunc starts the HTTP server using the provided server function
 func (s *Server) StartWithServerFunc(serverFunc HTTPServerFunc) error {
+	// Number the configuration so that deliveries report the generation that served them
+	s.generations.apply(s.config.Endpoints)
+	s.log.WithFields(logrus.Fields{
+		"config_generation": s.generations.generation(),
+	}).Info("Applying configuration")
+
 	// Register routes for each endpoint
 	for _, endpoint := range s.config.Endpoints {
 		s.registerEndpoint(endpoint)
 	}
 
+	// Register the routes answering with a fixed response
+	for _, static := range s.config.StaticEndpoints {
+		s.registerStaticEndpoint(static)
+	}
+
+	// Report misconfigured or unreachable destinations before accepting webhooks
+	if err := s.checkDestinations(); err != nil {
+		return err
+	}
+
+	// Resume the deliveries that were waiting for a retry before the restart
+	if s.retryStore != nil {
+		s.resumeRetries()
+	}
+
+	// Forward the queued webhooks, starting with those left by the previous run
+	if s.queue != nil {
+		s.startQueue()
+	}
+
+	// Keep the retry state within its retention limits
+	if s.retryStore != nil && s.config.RetryState.Retention.Enabled() {
+		go s.compactRetryState()
+	}
+
+	// Forward the webhooks queued over their endpoint's quota once it resets
+	if s.hasQueuedQuotas() {
+		go s.releaseQueuedWebhooks()
+	}
+
+	// Prewarm destination connections in the background
+	if s.config.Server.Prewarm.Enabled {
+		go s.prewarmDestinations()
+	}
+
+	// Sample the proxy's state for /admin/status in the background
+	if s.config.Server.Monitor.Enabled {
+		go s.runMonitor()
+		s.registerStatusEndpoint()
+	}
+
+	// Serve the dead letters on /admin/dead-letters
+	if s.deadLetters != nil {
+		s.registerDeadLetterEndpoints()
+	}
+
+	// Serve the recent webhooks on /admin/deliveries
+	if s.history != nil {
+		s.registerHistoryEndpoints()
+	}
+
+	// Serve the delivery results of the recent webhooks on /deliveries/{id}
+	if s.results != nil {
+		s.registerResultsEndpoint()
+	}
+
+	// Persist the delivery statistics and serve them on /admin/stats
+	if s.stats != nil {
+		go s.flushStats()
+		s.registerStatsEndpoint()
+	}
+
+	// Write the manifests of the delivery windows as they end
+	if s.manifests != nil {
+		go s.exportManifests()
+	}
+
 	// Register metrics endpoint
 	s.registerMetricsEndpoint()
+	if s.accounting != nil {
+		s.router.Get("/metrics/accounting", s.accounting.ServeHTTP)
+	}
 
 	// Register health check endpoint
 	s.registerHealthCheckEndpoint()
 
 	// Start server
-	addr := fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port)
-	s.log.WithFields(logrus.Fields{
-		"address": addr,
-	}).Info("Starting HTTP server")
+	return s.serve(serverFunc)
+}
+
+// checkDestinations warns about destinations configured twice on an endpoint and, when
+// probing or in strict mode, about unreachable HTTP destinations. In strict mode, an
+// unreachable critical destination fails the startup.
+func (s *Server) checkDestinations() error {
+	startup := s.config.Server.Startup
+
+	for _, endpoint := range s.config.Endpoints {
+		for _, key := range endpoint.DuplicateDestinations() {
+			s.log.WithFields(logrus.Fields{
+				"endpoint":    endpoint.Path,
+				"destination": key,
+			}).Warn("Destination configured more than once, each copy receives every webhook")
+		}
+	}
+
+	if !startup.Probe && !startup.Strict {
+		return nil
+	}
+
+	method := startup.ProbeMethod
+	if method == "" {
+		method = http.MethodHead
+	}
+
+	var unreachable []string
+	probed := make(map[string]bool)
+	for _, endpoint := range s.config.Endpoints {
+		key := handlerKey(endpoint)
+		handler, ok := s.proxyHandlers[key]
+		if !ok || probed[key] {
+			continue
+		}
+		probed[key] = true
+
+		for _, result := range handler.Probe(context.Background(), method) {
+			if result.Err == nil {
+				continue
+			}
+
+			s.log.WithFields(logrus.Fields{
+				"error":       result.Err,
+				"endpoint":    key,
+				"destination": result.Destination.Key(),
+				"critical":    result.Destination.Critical,
+			}).Warn("Destination unreachable on startup")
+
+			if result.Destination.Critical {
+				unreachable = append(unreachable, result.Destination.Key())
+			}
+		}
+	}
+
+	if startup.Strict && len(unreachable) > 0 {
+		return fmt.Errorf("critical destinations unreachable: %s", strings.Join(unreachable, ", "))
+	}
+	return nil
+}
+
+// resumeRetries resumes the persisted deliveries on their endpoint's handler.
+// Deliveries whose endpoint or destination was removed from the configuration are dropped.
+func (s *Server) resumeRetries() {
+	records, err := s.retryStore.Load()
+	if err != nil {
+		s.log.WithError(err).Error("Failed to load some retry states")
+	}
 
-	return serverFunc(addr, s.router)
+	resumed := 0
+	for _, record := range records {
+		if handler, ok := s.proxyHandlers[record.Endpoint]; ok && handler.Resume(record) {
+			resumed++
+			continue
+		}
+
+		s.log.WithFields(logrus.Fields{
+			"delivery_id": record.ID,
+			"endpoint":    record.Endpoint,
+			"destination": record.Destination,
+		}).Warn("Dropping persisted retry for a destination that is no longer configured")
+		if err := s.retryStore.Delete(record.ID); err != nil {
+			s.log.WithError(err).Error("Failed to delete retry state")
+		}
+	}
+
+	if len(records) > 0 {
+		s.log.WithFields(logrus.Fields{
+			"resumed": resumed,
+			"dropped": len(records) - resumed,
+		}).Info("Resumed persisted retries")
+	}
+}
+
+// compactRetryState removes the persisted retries beyond the retention limits,
+// on startup then at the configured interval
+func (s *Server) compactRetryState() {
+	retention := s.config.RetryState.Retention
+
+	compact := func() {
+		removed, err := s.retryStore.Compact(retention, time.Now())
+		if err != nil {
+			s.log.WithError(err).Error("Failed to compact retry state")
+		}
+		if removed > 0 {
+			s.log.WithFields(logrus.Fields{
+				"removed":                removed,
+				"max_age":                retention.MaxAge,
+				"max_total_size":         retention.MaxTotalSize,
+				"max_count_per_endpoint": retention.MaxCountPerEndpoint,
+			}).Warn("Dropped persisted retries beyond the retention limits")
+		}
+	}
+
+	compact()
+
+	if retention.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(retention.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		compact()
+	}
+}
+
+// prewarmDestinations prewarms the connections of every endpoint's destinations,
+// then again at the configured interval if any
+func (s *Server) prewarmDestinations() {
+	handlers := make([]*proxy.Handler, 0, len(s.proxyHandlers))
+	for _, handler := range s.proxyHandlers {
+		handlers = append(handlers, handler)
+	}
+
+	prewarm := func() {
+		for _, handler := range handlers {
+			handler.Prewarm(context.Background())
+		}
+	}
+
+	prewarm()
+
+	interval := s.config.Server.Prewarm.Interval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		prewarm()
+	}
+}
+
+// handlerKey returns the key of the proxy handler of an endpoint: the endpoint's path,
+// or the name of its pipeline when its handler is shared with other endpoints
+func handlerKey(endpoint config.EndpointConfig) string {
+	if endpoint.Pipeline != "" {
+		return endpoint.Pipeline
+	}
+	return endpoint.Path
 }
 
 // registerEndpoint registers a webhook endpoint
 func (s *Server) registerEndpoint(endpoint config.EndpointConfig) {
 	s.log.WithFields(logrus.Fields{
-		"path":         endpoint.Path,
-		"destinations": len(endpoint.Destinations),
+		"path":              endpoint.Path,
+		"pipeline":          endpoint.Pipeline,
+		"listener":          s.config.Server.Listener(endpoint.Path),
+		"destinations":      len(endpoint.Destinations),
+		"config_generation": s.generations.endpoint(handlerKey(endpoint)),
 	}).Info("Registering webhook endpoint")
 
-	// Create a proxy handler for this endpoint
-	proxyHandler := proxy.NewProxyHandler(endpoint.Destinations, s.log)
+	// Endpoints of the same pipeline share its proxy handler
+	proxyHandler, ok := s.proxyHandlers[handlerKey(endpoint)]
+	if !ok {
+		proxyHandler = s.newProxyHandler(endpoint)
+	}
 
-	// Store the proxy handler for metrics access
-	s.proxyHandlers[endpoint.Path] = proxyHandler
+	// Provider endpoints verify signatures and extract webhook metadata; auth endpoints
+	// only verify signatures
+	preset, _ := provider.Get(endpoint.Provider)
+	secrets := endpoint.Secrets()
+	if endpoint.Auth != nil {
+		preset, secrets = provider.Auth(*endpoint.Auth), endpoint.Auth.Secrets()
+	}
+	if preset != nil {
+		preset = preset.WithTolerance(endpoint.SignatureTolerance)
+	}
+	var rejections *atomic.Int64
+	if preset != nil {
+		rejections = &atomic.Int64{}
+		s.rejections[endpoint.Path] = rejections
+	}
+	disconnects := &atomic.Int64{}
+	s.disconnects[endpoint.Path] = disconnects
+
+	// Endpoints with a quota count the webhooks they forward
+	var quota *endpointQuota
+	if endpoint.Quota != nil {
+		quota = newEndpointQuota(*endpoint.Quota)
+		quota.endpoint, quota.events = endpoint.Path, s.events
+		s.quotas[endpoint.Path] = quota
+	}
+
+	// Endpoints with dedup remember the keys of the webhooks they accept
+	var dedup *endpointDedup
+	if endpoint.Dedup != nil {
+		dedup = newEndpointDedup(*endpoint.Dedup)
+		s.dedups[endpoint.Path] = dedup
+	}
+
+	// The response template is validated with the configuration, so this only fails for
+	// configurations built in code; those endpoints answer with the default response
+	response, err := newEndpointResponse(endpoint.Response)
+	if err != nil {
+		s.log.WithFields(logrus.Fields{
+			"error": err,
+			"path":  endpoint.Path,
+		}).Error("Invalid endpoint response, using the default response")
+		response, _ = newEndpointResponse(nil)
+	}
 
 	// Register the endpoint
 	s.router.Post(endpoint.Path, func(w http.ResponseWriter, r *http.Request) {
@@ -157,14 +700,32 @@ func (s *Server) registerEndpoint(endpoint config.EndpointConfig) {
 		// Add endpoint attributes to the span
 		telemetry.AddAttribute(ctx, "webhook.path", endpoint.Path)
 		telemetry.AddAttribute(ctx, "webhook.destinations", len(endpoint.Destinations))
+		addLabelAttributes(ctx, endpoint.Labels)
+
+		// Endpoints may compress their responses unlike the rest of the server
+		setResponseCompression(ctx, endpoint.CompressesResponse(s.config.Server.Compression.Enabled))
 
 		// Read the request body
 		var body []byte
 		var err error
 
 		// Limit the body size to 10MB
-		r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
 		body, err = readRequestBody(r)
+		if errors.Is(err, errClientDisconnected) {
+			// Nothing is forwarded and the partial body is already released
+			disconnects.Add(1)
+			s.log.WithFields(logrus.Fields{
+				"error": err,
+				"path":  endpoint.Path,
+			}).Warn("Sender disconnected before the webhook body was read, dropping the webhook")
+
+			telemetry.RecordError(ctx, err)
+			telemetry.SetStatus(ctx, codes.Error, "Client disconnected")
+
+			w.WriteHeader(statusClientClosedRequest)
+			return
+		}
 		if err != nil {
 			s.log.WithFields(logrus.Fields{
 				"error": err,
@@ -175,13 +736,60 @@ func (s *Server) registerEndpoint(endpoint config.EndpointConfig) {
 			telemetry.RecordError(ctx, err)
 			telemetry.SetStatus(ctx, codes.Error, "Failed to read request body")
 
-			http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+			http.Error(w, "Failed to read request body", bodyErrorStatus(err))
 			return
 		}
 
 		// Add body size to the span
 		telemetry.AddAttribute(ctx, "webhook.body_size", len(body))
 
+		if preset != nil {
+			secretIndex, err := preset.Verify(secrets, body, r.Header)
+			if err != nil {
+				rejections.Add(1)
+				s.log.WithFields(logrus.Fields{
+					"error":    err,
+					"path":     endpoint.Path,
+					"provider": preset.Name,
+				}).Warn("Rejected webhook with an invalid signature")
+
+				telemetry.RecordError(ctx, err)
+				telemetry.SetStatus(ctx, codes.Error, "Invalid signature")
+
+				http.Error(w, "Invalid signature", http.StatusUnauthorized)
+				return
+			}
+
+			telemetry.AddAttribute(ctx, "webhook.provider", preset.Name)
+			telemetry.AddAttribute(ctx, "webhook.secret_index", secretIndex)
+
+			s.log.WithFields(logrus.Fields{
+				"path":         endpoint.Path,
+				"provider":     preset.Name,
+				"secret_index": secretIndex,
+			}).Debug("Webhook signature verified")
+		}
+
+		// Convert bodies sent in a legacy charset to UTF-8, once the signature is verified
+		contentType := r.Header.Get("Content-Type")
+		if endpoint.Charset != nil && endpoint.Charset.Normalize {
+			result, err := charset.Normalize(body, contentType, endpoint.Charset.Fallback)
+			if err != nil {
+				s.log.WithFields(logrus.Fields{
+					"error": err,
+					"path":  endpoint.Path,
+				}).Warn("Failed to convert webhook body to UTF-8, forwarding it as is")
+			}
+			if result.Charset != "" {
+				s.log.WithFields(logrus.Fields{
+					"path":    endpoint.Path,
+					"charset": result.Charset,
+				}).Debug("Converted webhook body to UTF-8")
+				telemetry.AddAttribute(ctx, "webhook.charset", result.Charset)
+			}
+			body, contentType = result.Body, result.ContentType
+		}
+
 		// Get the headers
 		headers := make(map[string]string)
 		for k, v := range r.Header {
@@ -190,29 +798,229 @@ func (s *Server) registerEndpoint(endpoint config.EndpointConfig) {
 			}
 		}
 
-		// Forward the webhook in a goroutine with the trace context
-		go func() {
-			// Create a new context for the goroutine
-			forwardCtx, forwardSpan := s.tracer.StartSpan(context.Background(), "webhook.forward")
-			defer forwardSpan.End()
+		// The body was decompressed, so it is forwarded without its encoding
+		delete(headers, "Content-Encoding")
+		if contentType != "" {
+			headers["Content-Type"] = contentType
+		}
+
+		delivery := webhook.New(endpoint.Path, body, headers)
+		delivery.RequestID = middleware.GetReqID(ctx)
+		if name := proxy.Listener(ctx); name != "" {
+			delivery.Listener = name
+		}
+		if endpoint.PreserveRawHeaders {
+			if raw, ok := rawheaders.FromContext(r.Context()); ok {
+				delivery.RawHeaders = raw
+			} else {
+				s.log.WithFields(logrus.Fields{
+					"path":  endpoint.Path,
+					"proto": r.Proto,
+				}).Debug("Raw headers not captured, forwarding canonical headers")
+			}
+		}
+		telemetry.AddAttribute(ctx, "webhook.id", delivery.ID)
+
+		// Identify the webhook to its sender and in every log line, so that it can be traced
+		// end-to-end across its destinations and retries
+		w.Header().Set(headerDeliveryID, delivery.ID)
+		log := s.log.WithFields(logrus.Fields{"path": endpoint.Path, "webhook_id": delivery.ID})
+		if delivery.Listener != "" {
+			log = log.WithField("listener", delivery.Listener)
+		}
+		if len(endpoint.Labels) > 0 {
+			log = log.WithField("labels", endpoint.Labels)
+		}
+
+		// Log the body when request body logging is enabled
+		logger.LogRequestBody(log, s.config.Logging.Body, delivery)
+
+		// Extract the routing fields from the body converted to UTF-8. The body is parsed
+		// once and shared by the preset, body logging, recording and the response.
+		var metadata provider.Metadata
+		if preset != nil {
+			metadata = preset.Extract(delivery)
+			delivery.Metadata = metadata.Fields
+			telemetry.AddAttribute(ctx, "webhook.event_type", metadata.EventType)
+			telemetry.AddAttribute(ctx, "webhook.delivery_id", metadata.DeliveryID)
+
+			log.WithFields(logrus.Fields{
+				"provider":             preset.Name,
+				"event_type":           metadata.EventType,
+				"provider_delivery_id": metadata.DeliveryID,
+				"dedupe_key":           metadata.DedupeKey(preset.Name),
+			}).Debug("Extracted webhook metadata")
+		}
+
+		// Handshakes only check that the endpoint answers, so they are not forwarded
+		if preset != nil && preset.Handshake(metadata) {
+			log.WithFields(logrus.Fields{
+				"provider":   preset.Name,
+				"event_type": metadata.EventType,
+			}).Info("Answered provider handshake")
+
+			telemetry.AddAttribute(ctx, "webhook.handshake", true)
+			telemetry.SetStatus(ctx, codes.Ok, "Handshake answered")
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok", "event": metadata.EventType}); err != nil {
+				log.WithError(err).Error("Failed to write response")
+			}
+			return
+		}
+
+		// Redeliveries of a webhook accepted within the TTL are answered without being
+		// forwarded again. The key is forgotten when the webhook is refused, so that its
+		// redelivery is forwarded.
+		var accepted bool
+		if dedup != nil {
+			var dedupeKey string
+			if preset != nil {
+				dedupeKey = metadata.DedupeKey(preset.Name)
+			}
+			if key := dedup.key(body, r.Header, dedupeKey); key == "" {
+				log.WithField("header", endpoint.Dedup.Header).Debug("Webhook without a dedup key, forwarding it without deduplication")
+			} else if firstID, duplicate := dedup.claim(key, delivery.ID); duplicate {
+				log.WithField("first_webhook_id", firstID).Info("Dropped duplicate webhook")
+
+				telemetry.AddAttribute(ctx, "webhook.duplicate", true)
+				telemetry.SetStatus(ctx, codes.Ok, "Duplicate webhook")
+
+				w.Header().Set(headerDeliveryID, firstID)
+				w.Header().Set(headerDuplicate, "true")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				if err := json.NewEncoder(w).Encode(map[string]string{"status": "duplicate", "id": firstID}); err != nil {
+					log.WithError(err).Error("Failed to write response")
+				}
+				return
+			} else {
+				defer func() {
+					if !accepted {
+						dedup.forget(key, delivery.ID)
+					}
+				}()
+			}
+		}
+
+		// Webhooks that no destination matches are not forwarded: they are accepted, rejected
+		// or saved as dead letters
+		matched := proxyHandler.Matches(delivery)
+		if !matched {
+			proxyHandler.RecordUnmatched()
+			telemetry.AddAttribute(ctx, "webhook.unmatched", true)
+
+			switch endpoint.OnNoMatch {
+			case config.NoMatchReject:
+				log.Warn("Rejected webhook matching no destination")
+
+				telemetry.SetStatus(ctx, codes.Error, "No matching destination")
+
+				http.Error(w, "No destination matches the webhook", http.StatusUnprocessableEntity)
+				return
+			case config.NoMatchDeadLetter:
+				if err := s.saveUnmatched(delivery); err != nil {
+					log.WithError(err).Error("Failed to save dead letter")
+
+					telemetry.RecordError(ctx, err)
+					telemetry.SetStatus(ctx, codes.Error, "Failed to save dead letter")
+
+					http.Error(w, "Failed to save webhook", http.StatusInternalServerError)
+					return
+				}
+				log.Warn("No destination matches the webhook, saved as a dead letter")
+			default:
+				log.Info("No destination matches the webhook, accepted without forwarding")
+			}
+		}
+
+		// Webhooks whose deliveries would overflow the delivery queue are rejected, before
+		// they count against the quota
+		if matched && s.deliveries != nil && !s.deliveries.Admit() {
+			retryAfter := s.deliveries.RetryAfter()
+			log.WithField("retry_after", retryAfter).Warn("Rejected webhook, the delivery queue is full")
+
+			telemetry.SetStatus(ctx, codes.Error, "Delivery queue full")
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Delivery queue full", http.StatusServiceUnavailable)
+			return
+		}
+
+		// Webhooks over the endpoint's quota are rejected, queued or only logged
+		decision := quotaAllowed
+		if quota != nil && matched {
+			decision = quota.admit(delivery)
+			switch decision {
+			case quotaRejected:
+				retryAfter := quota.resetIn()
+				log.WithField("retry_after", retryAfter).Warn("Rejected webhook over the endpoint quota")
+
+				telemetry.SetStatus(ctx, codes.Error, "Quota exceeded")
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, "Quota exceeded", http.StatusTooManyRequests)
+				return
+			case quotaQueued:
+				log.Info("Queued webhook over the endpoint quota")
+			case quotaLogged:
+				log.Warn("Forwarding webhook over the endpoint quota")
+			}
+			telemetry.AddAttribute(ctx, "webhook.quota_queued", decision == quotaQueued)
+		}
 
-			// Add attributes to the forward span
-			telemetry.AddAttribute(forwardCtx, "webhook.path", endpoint.Path)
-			telemetry.AddAttribute(forwardCtx, "webhook.destinations", len(endpoint.Destinations))
-			telemetry.AddAttribute(forwardCtx, "webhook.body_size", len(body))
+		// Save the webhook as a fixture when recording
+		if s.recorder != nil {
+			if _, err := s.recorder.Record(delivery); err != nil {
+				log.WithError(err).Error("Failed to record webhook")
+			}
+		}
 
-			// Forward the webhook
-			proxyHandler.ForwardWebhook(body, headers)
+		// Keep the webhook in the history of the last webhooks
+		if s.history != nil {
+			s.history.Add(delivery)
+		}
+		if s.results != nil {
+			s.results.Add(delivery.ID, endpoint.Path, delivery.ReceivedAt)
+		}
 
-			// Set success status
-			telemetry.SetStatus(forwardCtx, codes.Ok, "Webhook forwarded")
-		}()
+		// Forward the webhook in a goroutine, unless it matches no destination or waits for the
+		// quota to reset. With a delivery queue, the webhook is persisted before it is
+		// acknowledged, and forwarded by the queue's workers.
+		if matched && decision != quotaQueued {
+			if s.queue == nil && s.forwardInline {
+				s.forwardWebhook(endpoint, proxyHandler, delivery, false)
+			} else if s.queue == nil {
+				go s.forwardWebhook(endpoint, proxyHandler, delivery, false)
+			} else if err := s.enqueueWebhook(delivery); err != nil {
+				log.WithError(err).Error("Failed to queue webhook")
+
+				telemetry.RecordError(ctx, err)
+				telemetry.SetStatus(ctx, codes.Error, "Failed to queue webhook")
+
+				http.Error(w, "Failed to queue webhook", http.StatusServiceUnavailable)
+				return
+			}
+		}
 
-		// Return a success response
-		w.WriteHeader(http.StatusAccepted)
-		_, err = w.Write([]byte(`{"status":"accepted"}`))
+		// Return the endpoint's success response
+		accepted = true
+		responseBody, err := response.render(responseData{
+			ID:         delivery.ID,
+			Endpoint:   endpoint.Path,
+			RequestID:  delivery.RequestID,
+			Provider:   endpoint.Provider,
+			DeliveryID: metadata.DeliveryID,
+			EventType:  metadata.EventType,
+			Fields:     metadata.Fields,
+		}, delivery)
 		if err != nil {
-			s.log.WithError(err).Error("Failed to write response")
+			log.WithError(err).Error("Failed to render endpoint response, using the default body")
+			responseBody = defaultResponseBody(delivery.ID)
+		}
+		if err := response.write(w, responseBody); err != nil {
+			log.WithError(err).Error("Failed to write response")
 		}
 
 		// Set success status for the main span
@@ -220,6 +1028,145 @@ func (s *Server) registerEndpoint(endpoint config.EndpointConfig) {
 	})
 }
 
+// addLabelAttributes adds the labels of an endpoint to a span, as label.<key> attributes
+func addLabelAttributes(ctx context.Context, labels map[string]string) {
+	for key, value := range labels {
+		telemetry.AddAttribute(ctx, "label."+key, value)
+	}
+}
+
+// forwardWebhook forwards a webhook received by an endpoint, in its own trace, correlated
+// with the ID of the request it was received with. When wait is set, it returns once the
+// deliveries are done.
+func (s *Server) forwardWebhook(endpoint config.EndpointConfig, proxyHandler *proxy.Handler, delivery *webhook.Delivery, wait bool) {
+	forwardCtx, forwardSpan := s.tracer.StartSpan(context.Background(), "webhook.forward")
+	defer forwardSpan.End()
+
+	// Add attributes to the forward span
+	telemetry.AddAttribute(forwardCtx, "webhook.path", endpoint.Path)
+	telemetry.AddAttribute(forwardCtx, "webhook.destinations", len(endpoint.Destinations))
+	telemetry.AddAttribute(forwardCtx, "webhook.id", delivery.ID)
+	telemetry.AddAttribute(forwardCtx, "webhook.body_size", len(delivery.Body))
+	addLabelAttributes(forwardCtx, endpoint.Labels)
+
+	// Forward the webhook. Its deliveries outlive the request, so the forward context is
+	// detached from it and only carries the forward span, the request ID and, with a history
+	// or delivery results, the webhook the results are recorded on.
+	forwardCtx = proxy.WithRequestID(forwardCtx, delivery.RequestID)
+	if s.history != nil || s.results != nil {
+		forwardCtx = history.WithWebhook(forwardCtx, delivery.ID, false)
+	}
+	if wait {
+		proxyHandler.DeliverWebhook(forwardCtx, delivery)
+	} else {
+		proxyHandler.ForwardWebhook(forwardCtx, delivery)
+	}
+
+	// Set success status
+	telemetry.SetStatus(forwardCtx, codes.Ok, "Webhook forwarded")
+}
+
+// hasQueuedQuotas reports whether an endpoint queues the webhooks over its quota
+func (s *Server) hasQueuedQuotas() bool {
+	for _, endpoint := range s.config.Endpoints {
+		if endpoint.Quota != nil && endpoint.Quota.OnExceed == config.QuotaQueue {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseQueuedWebhooks forwards, at every check, the queued webhooks that fit in
+// their endpoint's quota again
+func (s *Server) releaseQueuedWebhooks() {
+	ticker := time.NewTicker(quotaReleaseInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.releaseQuotas()
+	}
+}
+
+// releaseQuotas forwards the queued webhooks that fit in their endpoint's quota
+func (s *Server) releaseQuotas() {
+	for _, endpoint := range s.config.Endpoints {
+		quota, ok := s.quotas[endpoint.Path]
+		if !ok {
+			continue
+		}
+
+		released := quota.release()
+		if len(released) == 0 {
+			continue
+		}
+
+		s.log.WithFields(logrus.Fields{
+			"path":     endpoint.Path,
+			"released": len(released),
+		}).Info("Forwarding webhooks queued over the endpoint quota")
+
+		proxyHandler := s.proxyHandlers[handlerKey(endpoint)]
+		for _, delivery := range released {
+			if s.queue != nil {
+				err := s.enqueueWebhook(delivery)
+				if err == nil {
+					continue
+				}
+				s.log.WithFields(logrus.Fields{
+					"error": err,
+					"path":  endpoint.Path,
+				}).Error("Failed to queue webhook released from the endpoint quota, forwarding it directly")
+			}
+			go s.forwardWebhook(endpoint, proxyHandler, delivery, false)
+		}
+	}
+}
+
+// newProxyHandler creates the proxy handler of an endpoint, or of its pipeline, and
+// registers the subscription routes of its destinations
+func (s *Server) newProxyHandler(endpoint config.EndpointConfig) *proxy.Handler {
+	key := handlerKey(endpoint)
+	endpoint.Path = key
+
+	proxyHandler := proxy.NewEndpointHandler(endpoint, s.log)
+	proxyHandler.SetEventBus(s.events)
+	proxyHandler.SetBodyLogging(s.config.Logging.Body)
+	if s.suppressor != nil {
+		proxyHandler.SetErrorSuppressor(s.suppressor)
+	}
+	for _, hook := range s.hooks {
+		proxyHandler.AddHook(hook)
+	}
+	if s.retryStore != nil {
+		proxyHandler.SetRetryStore(s.retryStore)
+	}
+	if s.deliveries != nil {
+		proxyHandler.SetDeliveryPool(s.deliveries)
+	}
+	if s.transport != nil {
+		proxyHandler.SetTransport(s.transport)
+	}
+	if s.retryTimer != nil {
+		proxyHandler.SetRetryTimer(s.retryTimer)
+	}
+	proxyHandler.SetGeneration(s.generations.endpoint(key))
+
+	// Store the proxy handler for metrics access
+	s.proxyHandlers[key] = proxyHandler
+
+	// Register subscription routes for sinks that serve their own clients (e.g. websocket)
+	for _, snk := range proxyHandler.Sinks() {
+		if sub, ok := snk.(sink.Subscribable); ok {
+			s.log.WithFields(logrus.Fields{
+				"path":     sub.Path(),
+				"endpoint": key,
+			}).Info("Registering destination subscription route")
+			s.router.Get(sub.Path(), sub.ServeHTTP)
+		}
+	}
+
+	return proxyHandler
+}
+
 // registerMetricsEndpoint registers the metrics endpoint
 func (s *Server) registerMetricsEndpoint() {
 	s.router.Get("/metrics", func(w http.ResponseWriter, r *http.Request) {
@@ -238,6 +1185,8 @@ func (s *Server) registerMetricsEndpoint() {
 		var successfulRequests int64
 		var failedRequests int64
 		var retries int64
+		var maintenanceQueued int64
+		var unmatchedRequests int64
 
 		// Collect metrics from each proxy handler
 		endpointMetrics := make(map[string]interface{})
@@ -258,6 +1207,39 @@ func (s *Server) registerMetricsEndpoint() {
 			if val, ok := handlerMetrics["retries"].(int64); ok {
 				retries += val
 			}
+			if val, ok := handlerMetrics["maintenance_queued"].(int64); ok {
+				maintenanceQueued += val
+			}
+			if val, ok := handlerMetrics["unmatched_requests"].(int64); ok {
+				unmatchedRequests += val
+			}
+		}
+
+		// Count the redeliveries answered without being forwarded
+		var duplicates int64
+		dedups := make(map[string]interface{}, len(s.dedups))
+		for path, dedup := range s.dedups {
+			snapshot := dedup.snapshot()
+			dedups[path] = snapshot
+			if val, ok := snapshot["duplicates"].(int64); ok {
+				duplicates += val
+			}
+		}
+
+		// Count the webhooks rejected for their signature
+		var signatureFailures int64
+		rejections := make(map[string]int64, len(s.rejections))
+		for path, count := range s.rejections {
+			rejections[path] = count.Load()
+			signatureFailures += rejections[path]
+		}
+
+		// Count the webhooks dropped because their sender disconnected mid-body
+		var clientDisconnects int64
+		disconnects := make(map[string]int64, len(s.disconnects))
+		for path, count := range s.disconnects {
+			disconnects[path] = count.Load()
+			clientDisconnects += disconnects[path]
 		}
 
 		// Build the complete metrics response
@@ -266,9 +1248,56 @@ func (s *Server) registerMetricsEndpoint() {
 			"successful_requests": successfulRequests,
 			"failed_requests":     failedRequests,
 			"retries":             retries,
+			"maintenance_queued":  maintenanceQueued,
+			"unmatched_requests":  unmatchedRequests,
+			"duplicates":          duplicates,
 			"success_rate":        calculateSuccessRate(successfulRequests, totalRequests),
+			"panics":              s.panics.Load(),
+			"signature_failures":  signatureFailures,
+			"client_disconnects":  clientDisconnects,
+			"config_generation":   s.generations.generation(),
+			"events":              s.eventCounts.Snapshot(),
 		}
 		metrics["endpoints"] = endpointMetrics
+
+		// Add the quota consumption of the endpoints with a quota
+		if len(s.quotas) > 0 {
+			quotas := make(map[string]interface{}, len(s.quotas))
+			for path, quota := range s.quotas {
+				quotas[path] = quota.snapshot()
+			}
+			metrics["quotas"] = quotas
+		}
+
+		// Add the redeliveries answered by the endpoints with dedup
+		if len(dedups) > 0 {
+			metrics["dedup"] = dedups
+		}
+
+		// Add the depth of the delivery queue
+		if s.queue != nil {
+			pending, inFlight := s.queue.Depth()
+			metrics["queue"] = map[string]interface{}{
+				"pending":   pending,
+				"in_flight": inFlight,
+			}
+		}
+
+		// Add the signature failures of the endpoints verifying signatures
+		if len(rejections) > 0 {
+			metrics["signature_failures"] = rejections
+		}
+		metrics["client_disconnects"] = disconnects
+
+		// Add the state of the bounded deliveries
+		if s.deliveries != nil {
+			metrics["deliveries"] = s.deliveries.Metrics()
+		}
+
+		// Add the requests received on each port, with tenant listeners
+		if len(s.listeners) > 0 {
+			metrics["listeners"] = s.listenerMetrics()
+		}
 		metrics["timestamp"] = time.Now().Format(time.RFC3339)
 
 		// Add metrics to the span
@@ -296,8 +1325,8 @@ func (s *Server) registerMetricsEndpoint() {
 		telemetry.SetStatus(ctx, codes.Ok, "Metrics returned successfully")
 	})
 
-	// Add endpoint to reset metrics
-	s.router.Post("/metrics/reset", func(w http.ResponseWriter, r *http.Request) {
+	// Add endpoint to reset metrics, a destructive admin action
+	s.router.With(s.admin.middleware).Post("/metrics/reset", func(w http.ResponseWriter, r *http.Request) {
 		// Get the parent span from the context
 		ctx := r.Context()
 
@@ -309,6 +1338,23 @@ func (s *Server) registerMetricsEndpoint() {
 		for _, handler := range s.proxyHandlers {
 			handler.ResetMetrics()
 		}
+		s.panics.Store(0)
+		for _, count := range s.rejections {
+			count.Store(0)
+		}
+		for _, count := range s.disconnects {
+			count.Store(0)
+		}
+		for _, dedup := range s.dedups {
+			dedup.resetMetrics()
+		}
+		for _, l := range s.listeners {
+			l.requests.Store(0)
+		}
+		if s.deliveries != nil {
+			s.deliveries.ResetMetrics()
+		}
+		s.eventCounts.Reset()
 
 		// Add reset info to the span
 		telemetry.AddAttribute(ctx, "metrics.reset", true)
@@ -330,9 +1376,12 @@ func (s *Server) registerMetricsEndpoint() {
 	})
 }
 
+// healthPath is the path of the health check, served on every listener
+const healthPath = "/health"
+
 // registerHealthCheckEndpoint registers the health check endpoint
 func (s *Server) registerHealthCheckEndpoint() {
-	s.router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+	s.router.Get(healthPath, func(w http.ResponseWriter, r *http.Request) {
 		// Get the parent span from the context
 		ctx := r.Context()
 
@@ -346,8 +1395,13 @@ func (s *Server) registerHealthCheckEndpoint() {
 			"version":   s.version,
 		}
 
+		// The terse response suits load balancers, verbose=1 details each subsystem
+		if verboseHealthRequested(r) {
+			s.addHealthDetails(health)
+		}
+
 		// Add health info to the span
-		telemetry.AddAttribute(ctx, "health.status", "ok")
+		telemetry.AddAttribute(ctx, "health.status", health["status"])
 		telemetry.AddAttribute(ctx, "health.version", s.version)
 
 		w.Header().Set("Content-Type", "application/json")
@@ -375,14 +1429,31 @@ func calculateSuccessRate(successful, total int64) float64 {
 	return float64(successful) / float64(total) * 100
 }
 
-// readRequestBody reads the request body
+// readRequestBody reads the request body, decompressing it according to its Content-Encoding
 func readRequestBody(r *http.Request) ([]byte, error) {
 	defer r.Body.Close()
 
-	// Read the body
-	body, err := io.ReadAll(r.Body)
+	// A sender disconnecting mid-body fails the read, instead of leaving a truncated body
+	conn := &connReader{reader: r.Body}
+	reader, err := decodeBody(conn, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, conn.disconnected(r, err)
+	}
+
+	// Compressed bodies are read with an unknown size, bounded like uncompressed ones
+	sizeHint := r.ContentLength
+	if reader != io.Reader(conn) {
+		sizeHint = -1
+	}
+
+	// Read the body through a pooled buffer, released on error; the returned slice is
+	// shared by all destinations
+	body, err := bufpool.ReadAll(io.LimitReader(reader, maxBodySize+1), sizeHint)
 	if err != nil {
-		return nil, err
+		return nil, conn.disconnected(r, err)
+	}
+	if len(body) > maxBodySize {
+		return nil, errBodyTooLarge
 	}
 
 	return body, nil
@@ -398,16 +1469,34 @@ func (s *Server) SetVersion(version string) {
 	}
 }
 
+// tracerConfig returns the configuration of the tracer of the given version
+func tracerConfig(cfg config.TelemetryConfig, version string) telemetry.Config {
+	tc := telemetry.Config{
+		ServiceName:    "webhook-proxy",
+		ServiceVersion: version,
+		ExporterType:   cfg.ExporterType,
+		Endpoint:       cfg.Endpoint,
+		Enabled:        cfg.Enabled,
+		Protocol:       cfg.Protocol,
+		Headers:        cfg.Headers,
+		Insecure:       cfg.Insecure,
+	}
+	if cfg.TLS != nil {
+		tc.TLS = telemetry.TLSConfig{
+			CAFile:             cfg.TLS.CAFile,
+			CertFile:           cfg.TLS.CertFile,
+			KeyFile:            cfg.TLS.KeyFile,
+			ServerName:         cfg.TLS.ServerName,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		}
+	}
+	return tc
+}
+
 // updateTracer creates a new tracer with the updated version
 func (s *Server) updateTracer(version string) {
 	// Create a new tracer with the updated version
-	newTracer, err := telemetry.NewTracer(context.Background(), telemetry.Config{
-		ServiceName:    "webhook-proxy", // Use the default service name
-		ServiceVersion: version,
-		ExporterType:   s.config.Telemetry.ExporterType,
-		Endpoint:       s.config.Telemetry.Endpoint,
-		Enabled:        s.config.Telemetry.Enabled,
-	}, s.log)
+	newTracer, err := telemetry.NewTracer(context.Background(), tracerConfig(s.config.Telemetry, version), s.log)
 
 	if err != nil {
 		s.log.WithError(err).Warn("Failed to update tracer version")
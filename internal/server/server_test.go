@@ -2,6 +2,10 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,14 +13,20 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/flemzord/webhook-proxy/internal/config"
 	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/flemzord/webhook-proxy/internal/rawheaders"
+	"github.com/flemzord/webhook-proxy/internal/retrystore"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewServer(t *testing.T) {
@@ -287,6 +297,501 @@ func TestRegisterEndpoint(t *testing.T) {
 
 	// Assert status code (should be 200 OK as we return immediately)
 	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// The webhook's ID is returned in a header and in the default body
+	id := resp.Header.Get("X-Delivery-ID")
+	assert.NotEmpty(t, id)
+	assert.JSONEq(t, `{"status":"accepted","id":"`+id+`"}`, w.Body.String())
+}
+
+func TestRegisterEndpointPreserveRawHeaders(t *testing.T) {
+	received := make(chan []rawheaders.Header, 1)
+	dest := httptest.NewUnstartedServer(rawheaders.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers, _ := rawheaders.FromContext(r.Context())
+		received <- headers
+		w.WriteHeader(http.StatusOK)
+	})))
+	dest.Listener = rawheaders.Listener(dest.Listener)
+	dest.Config.ConnContext = rawheaders.ConnContext
+	dest.Start()
+	defer dest.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path:               "/webhook",
+				PreserveRawHeaders: true,
+				Destinations: []config.DestinationConfig{
+					{URL: dest.URL, Method: http.MethodPost, Timeout: 5 * time.Second},
+				},
+			},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	front := httptest.NewUnstartedServer(server.router)
+	front.Listener = rawheaders.Listener(front.Listener)
+	front.Config.ConnContext = rawheaders.ConnContext
+	front.Start()
+	defer front.Close()
+
+	req, err := http.NewRequest(http.MethodPost, front.URL+"/webhook", strings.NewReader(`{"test": "data"}`))
+	require.NoError(t, err)
+	req.Header["X-GitHub-Event"] = []string{"push"}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	select {
+	case headers := <-received:
+		var names []string
+		for _, h := range headers {
+			names = append(names, h.Name)
+		}
+		assert.Contains(t, names, "X-GitHub-Event")
+		assert.NotContains(t, names, "X-Github-Event")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be forwarded")
+	}
+}
+
+func TestRegisterEndpointClientDisconnected(t *testing.T) {
+	var received atomic.Int32
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer dest.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Path: "/webhook", Destinations: []config.DestinationConfig{{URL: dest.URL, Method: http.MethodPost, Timeout: 5 * time.Second}}},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerMetricsEndpoint()
+	front := httptest.NewServer(server.router)
+	defer front.Close()
+
+	// The sender announces 100 bytes but disconnects after 10
+	conn, err := net.Dial("tcp", front.Listener.Addr().String())
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(conn, "POST /webhook HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"event\":")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	assert.Eventually(t, func() bool {
+		return server.disconnects["/webhook"].Load() == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(0), received.Load(), "The truncated webhook is not forwarded")
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, float64(1), metrics["global"].(map[string]interface{})["client_disconnects"])
+	assert.Equal(t, map[string]interface{}{"/webhook": float64(1)}, metrics["client_disconnects"])
+}
+
+func TestRegisterEndpointWithWebSocketDestination(t *testing.T) {
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path: "/webhook",
+				Destinations: []config.DestinationConfig{
+					{
+						Type:      config.DestinationTypeWebSocket,
+						WebSocket: &config.WebSocketConfig{Path: "/live", Token: "subscriber-token"},
+						Timeout:   5 * time.Second,
+					},
+				},
+			},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	// The subscription route is registered and rejects non-websocket requests
+	req := httptest.NewRequest(http.MethodGet, "/live", nil)
+	req.Header.Set("Authorization", "Bearer subscriber-token")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// receiveHook counts received webhooks
+type receiveHook struct {
+	proxy.NopHook
+	received chan string
+}
+
+func (h *receiveHook) OnReceive(event *proxy.Event) { h.received <- event.Endpoint }
+
+func TestServerAddHook(t *testing.T) {
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Path: "/webhook"},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	hook := &receiveHook{received: make(chan string, 1)}
+	server.AddHook(hook)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	// Hooks registered on the server are called for every endpoint
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"test"}`))
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+
+	select {
+	case endpoint := <-hook.received:
+		assert.Equal(t, "/webhook", endpoint)
+	case <-time.After(time.Second):
+		t.Fatal("Expected the hook to be called")
+	}
+}
+
+func TestRegisterEndpointProviderVerification(t *testing.T) {
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Path: "/webhook/github", Provider: config.ProviderGitHub, Secret: "s3cr3t"},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	body := `{"zen":"Keep it logically awesome."}`
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte(body))
+	previous := hmac.New(sha256.New, []byte("old"))
+	previous.Write([]byte(body))
+	validSignature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name           string
+		signature      string
+		expectedStatus int
+	}{
+		{name: "Valid signature", signature: validSignature, expectedStatus: http.StatusAccepted},
+		{name: "Invalid signature", signature: "sha256=0000", expectedStatus: http.StatusUnauthorized},
+		{name: "Missing signature", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhook/github", strings.NewReader(body))
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-GitHub-Delivery", "72d3162e")
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature-256", tt.signature)
+			}
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestRegisterEndpointProviderHandshake(t *testing.T) {
+	destination := make(chan struct{}, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		destination <- struct{}{}
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path:         "/webhook/github",
+				Provider:     config.ProviderGitHub,
+				Secret:       "s3cr3t",
+				Destinations: []config.DestinationConfig{{URL: backend.URL, Method: "POST", Timeout: time.Second}},
+			},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	body := `{"zen":"Keep it logically awesome.","hook_id":1}`
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte(body))
+	previous := hmac.New(sha256.New, []byte("old"))
+	previous.Write([]byte(body))
+
+	send := func(signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/github", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "ping")
+		req.Header.Set("X-Hub-Signature-256", signature)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Pings are answered once their signature is verified, and are not forwarded
+	assert.Equal(t, http.StatusUnauthorized, send("sha256=0000").Code)
+
+	w := send("sha256=" + hex.EncodeToString(mac.Sum(nil)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok","event":"ping"}`, w.Body.String())
+
+	select {
+	case <-destination:
+		t.Fatal("Expected the ping not to be forwarded")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRegisterEndpointAuth(t *testing.T) {
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path: "/webhook/billing",
+				Auth: &config.AuthConfig{
+					Style:     config.AuthStyleGitHub,
+					Algorithm: config.AuthAlgorithmSHA256,
+					Header:    "X-Billing-Signature",
+					Secret:    "s3cr3t",
+					// The previous secret is still accepted while the secret is rotated
+					PreviousSecrets: []string{"old"},
+				},
+			},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerMetricsEndpoint()
+
+	body := `{"invoice":"in_1"}`
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte(body))
+	previous := hmac.New(sha256.New, []byte("old"))
+	previous.Write([]byte(body))
+
+	send := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/billing", strings.NewReader(body))
+		req.Header.Set("X-Billing-Signature", signature)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusAccepted, send("sha256="+hex.EncodeToString(mac.Sum(nil))))
+	assert.Equal(t, http.StatusAccepted, send("sha256="+hex.EncodeToString(previous.Sum(nil))))
+	assert.Equal(t, http.StatusUnauthorized, send("sha256=0000"))
+	assert.Equal(t, http.StatusUnauthorized, send(""))
+
+	// Rejected webhooks are counted in the metrics
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics struct {
+		Global struct {
+			SignatureFailures int64 `json:"signature_failures"`
+		} `json:"global"`
+		SignatureFailures map[string]int64 `json:"signature_failures"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, int64(2), metrics.Global.SignatureFailures)
+	assert.Equal(t, map[string]int64{"/webhook/billing": 2}, metrics.SignatureFailures)
+}
+
+func TestRegisterEndpointCustomResponse(t *testing.T) {
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path: "/webhook",
+				Response: &config.ResponseConfig{
+					StatusCode: http.StatusOK,
+					Headers:    map[string]string{"Content-Type": "text/plain"},
+					Body:       "received {{.Endpoint}}",
+				},
+			},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"test"}`))
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "received /webhook", w.Body.String())
+}
+
+func TestRegisterEndpointCompressedBody(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path:         "/webhook",
+				Destinations: []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second}},
+			},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(`{"event":"test"}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// The destination receives the decompressed body without the encoding header
+	select {
+	case r := <-received:
+		assert.Empty(t, r.Header.Get("Content-Encoding"))
+		assert.Equal(t, `{"event":"test"}`, <-bodies)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be forwarded")
+	}
+}
+
+func TestRegisterEndpointCharset(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path:         "/webhook",
+				Charset:      &config.CharsetConfig{Normalize: true, Fallback: config.DefaultCharsetFallback},
+				Destinations: []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second}},
+			},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	// "Café" in ISO-8859-1
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte("{\"name\":\"Caf\xe9\"}")))
+	req.Header.Set("Content-Type", "application/json; charset=ISO-8859-1")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// The destination receives the body in UTF-8
+	select {
+	case r := <-received:
+		assert.Equal(t, "application/json; charset=utf-8", r.Header.Get("Content-Type"))
+		assert.Equal(t, `{"name":"Café"}`, <-bodies)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be forwarded")
+	}
+}
+
+func TestRegisterEndpointTraceHeaders(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path: "/webhook",
+				Destinations: []config.DestinationConfig{{
+					URL:          destination.URL,
+					Method:       "POST",
+					Timeout:      5 * time.Second,
+					TraceHeaders: []string{config.TraceHeadersRequestID, config.TraceHeadersB3},
+				}},
+			},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(`{"event":"test"}`)))
+	req.Header.Set("X-Request-Id", "sender-request-1")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// The destination receives the request ID and a trace of its own, tracing being disabled
+	select {
+	case r := <-received:
+		assert.Equal(t, "sender-request-1", r.Header.Get("X-Request-Id"))
+		assert.Len(t, r.Header.Get("X-B3-TraceId"), 32)
+		assert.Len(t, r.Header.Get("X-B3-SpanId"), 16)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be forwarded")
+	}
 }
 
 func TestRegisterMetricsEndpoint(t *testing.T) {
@@ -919,3 +1424,257 @@ func TestStart(t *testing.T) {
 	// Verify that all endpoints were registered
 	assert.Contains(t, server.proxyHandlers, "/webhook")
 }
+
+func TestResumeRetries(t *testing.T) {
+	received := make(chan string, 1)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		RetryState: config.RetryStateConfig{Directory: dir},
+		Endpoints: []config.EndpointConfig{
+			{
+				Path: "/webhook",
+				Destinations: []config.DestinationConfig{
+					{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second, Retries: 1},
+				},
+			},
+		},
+	}
+
+	// Persist a delivery for a configured destination and one for a removed endpoint
+	store, err := retrystore.Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(retrystore.Record{ID: "known", Endpoint: "/webhook", Destination: destination.URL, Body: []byte("resumed"), Attempt: 1}))
+	require.NoError(t, store.Save(retrystore.Record{ID: "removed", Endpoint: "/removed", Destination: destination.URL, Attempt: 1}))
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	err = server.StartWithServerFunc(func(string, http.Handler) error { return nil })
+	require.NoError(t, err)
+
+	select {
+	case body := <-received:
+		assert.Equal(t, "resumed", body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the persisted delivery to be resumed")
+	}
+
+	assert.Eventually(t, func() bool {
+		records, err := store.Load()
+		return err == nil && len(records) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCompactRetryState(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		RetryState: config.RetryStateConfig{
+			Directory: dir,
+			Retention: config.RetentionConfig{MaxAge: time.Minute},
+		},
+	}
+
+	store, err := retrystore.Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(retrystore.Record{ID: "stale", Endpoint: "/webhook"}))
+	require.NoError(t, store.Save(retrystore.Record{ID: "recent", Endpoint: "/webhook"}))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "stale.json"), old, old))
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	// Without an interval, the state is compacted once
+	server := NewServer(cfg, log)
+	server.compactRetryState()
+
+	records, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "recent", records[0].ID)
+}
+
+func TestCheckDestinations(t *testing.T) {
+	reachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer reachable.Close()
+
+	// A closed server refuses connections
+	closed := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	unreachableURL := closed.URL
+	closed.Close()
+
+	tests := []struct {
+		name        string
+		startup     config.StartupConfig
+		critical    bool
+		expectError bool
+	}{
+		{name: "no probe", startup: config.StartupConfig{}, critical: true},
+		{name: "probe only", startup: config.StartupConfig{Probe: true}, critical: true},
+		{name: "strict with non-critical destination", startup: config.StartupConfig{Strict: true}},
+		{name: "strict with critical destination", startup: config.StartupConfig{Strict: true}, critical: true, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Server: config.ServerConfig{Startup: tt.startup},
+				Endpoints: []config.EndpointConfig{
+					{
+						Path: "/webhook",
+						Destinations: []config.DestinationConfig{
+							{URL: reachable.URL, Method: "POST", Timeout: time.Second, Critical: true},
+							{URL: unreachableURL, Method: "POST", Timeout: time.Second, Critical: tt.critical},
+						},
+					},
+				},
+			}
+
+			log := logrus.New()
+			log.SetOutput(io.Discard) // Silence logs during tests
+
+			served := false
+			server := NewServer(cfg, log)
+			err := server.StartWithServerFunc(func(string, http.Handler) error {
+				served = true
+				return nil
+			})
+
+			if tt.expectError {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), unreachableURL)
+				assert.False(t, served)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, served)
+			}
+		})
+	}
+}
+
+func TestCheckDestinationsDuplicates(t *testing.T) {
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path: "/webhook",
+				Destinations: []config.DestinationConfig{
+					{URL: "http://example.com/a", Method: "POST", Timeout: time.Second},
+					{URL: "http://example.com/a", Method: "POST", Timeout: time.Second},
+					{URL: "http://example.com/b", Method: "POST", Timeout: time.Second},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	server := NewServer(cfg, log)
+	require.NoError(t, server.checkDestinations())
+
+	assert.Equal(t, 1, strings.Count(buf.String(), "Destination configured more than once"))
+	assert.Contains(t, buf.String(), `"destination":"http://example.com/a"`)
+}
+
+func TestRegisterEndpointPipeline(t *testing.T) {
+	received := make(chan string, 2)
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	destinations := []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second}}
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Path: "/webhook/push", Pipeline: "github-events", Destinations: destinations},
+			{Path: "/webhook/issues", Pipeline: "github-events", Destinations: destinations},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	for _, endpoint := range cfg.Endpoints {
+		server.registerEndpoint(endpoint)
+	}
+
+	// Both endpoints feed the pipeline's handler
+	require.Len(t, server.proxyHandlers, 1)
+	require.Contains(t, server.proxyHandlers, "github-events")
+
+	for _, path := range []string{"/webhook/push", "/webhook/issues"} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(path)))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+	}
+
+	var bodies []string
+	for i := 0; i < 2; i++ {
+		select {
+		case body := <-received:
+			bodies = append(bodies, body)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected both webhooks to be forwarded")
+		}
+	}
+	assert.ElementsMatch(t, []string{"/webhook/push", "/webhook/issues"}, bodies)
+
+	assert.Eventually(t, func() bool {
+		return server.proxyHandlers["github-events"].GetMetrics()["total_requests"] == int64(2)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRegisterEndpointDeliveryQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+	defer close(release)
+
+	cfg := &config.Config{
+		Outbound: config.OutboundConfig{Deliveries: &config.DeliveriesConfig{MaxConcurrent: 1, QueueSize: 1, Overflow: config.DeliveriesOverflowReject}},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: []config.DestinationConfig{
+			{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second},
+		}}},
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	server := NewServer(cfg, log)
+	server.registerEndpoint(cfg.Endpoints[0])
+
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{}`)))
+		return w
+	}
+
+	// The first delivery runs and the second one waits, which fills the queue
+	assert.Equal(t, http.StatusAccepted, send().Code)
+	assert.Equal(t, http.StatusAccepted, send().Code)
+	assert.Eventually(t, func() bool {
+		return server.deliveries.Metrics()["queued"] == int64(1)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// No delivery was done yet, so the sender is told to wait the longest
+	w := send()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, int64(1), server.deliveries.Metrics()["rejected"])
+}
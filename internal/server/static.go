@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// registerStaticEndpoint registers an endpoint answering every request with its fixed
+// response. A GET endpoint also answers HEAD requests, without the body.
+func (s *Server) registerStaticEndpoint(static config.StaticEndpointConfig) {
+	s.log.WithFields(logrus.Fields{
+		"path":        static.Path,
+		"method":      static.Method,
+		"status_code": static.StatusCode,
+	}).Info("Registering static endpoint")
+
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		for k, v := range static.Headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(static.StatusCode)
+		_, _ = w.Write([]byte(static.Body))
+	}
+
+	s.router.Method(static.Method, static.Path, http.HandlerFunc(handler))
+	if static.Method == http.MethodGet {
+		s.router.Method(http.MethodHead, static.Path, http.HandlerFunc(handler))
+	}
+}
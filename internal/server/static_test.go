@@ -0,0 +1,52 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticEndpoint(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	server := NewServer(&config.Config{}, log)
+	server.registerStaticEndpoint(config.StaticEndpointConfig{
+		Path:       "/robots.txt",
+		Method:     http.MethodGet,
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "text/plain"},
+		Body:       "User-agent: *\nDisallow: /\n",
+	})
+	server.registerStaticEndpoint(config.StaticEndpointConfig{
+		Path:       "/verify",
+		Method:     http.MethodPost,
+		StatusCode: http.StatusNoContent,
+	})
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "User-agent: *\nDisallow: /\n", w.Body.String())
+
+	// A GET endpoint also answers HEAD requests
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/robots.txt", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/verify", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// Other methods are not allowed
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/verify", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/proxy"
+	"github.com/flemzord/webhook-proxy/internal/stats"
+)
+
+// defaultStatsDays is the number of days /admin/stats returns without a days parameter
+const defaultStatsDays = 30
+
+// statsHook rolls up the deliveries of every endpoint in the stats store
+type statsHook struct {
+	proxy.NopHook
+	store *stats.Store
+}
+
+// AfterForward counts the attempt, with its latency unless it reused a cached response
+func (h *statsHook) AfterForward(event *proxy.Event) {
+	latency := event.Duration
+	if event.Cached {
+		latency = 0
+	}
+	h.store.RecordAttempt(time.Now(), event.Destination.Key(), event.Err == nil, latency)
+}
+
+// OnDeadLetter counts the delivery that failed for good
+func (h *statsHook) OnDeadLetter(event *proxy.Event) {
+	h.store.RecordDeadLetter(time.Now(), event.Destination.Key())
+}
+
+// flushStats writes the delivery statistics at the configured interval
+func (s *Server) flushStats() {
+	ticker := time.NewTicker(s.config.Stats.FlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.stats.Flush(time.Now()); err != nil {
+			s.log.WithError(err).Error("Failed to write delivery statistics")
+		}
+	}
+}
+
+// registerStatsEndpoint registers /admin/stats, serving the daily delivery statistics of
+// the last days, 30 by default
+func (s *Server) registerStatsEndpoint() {
+	s.router.Get("/admin/stats", func(w http.ResponseWriter, r *http.Request) {
+		days := defaultStatsDays
+		if value := r.URL.Query().Get("days"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				http.Error(w, "Invalid days parameter", http.StatusBadRequest)
+				return
+			}
+			days = n
+		}
+		days = min(days, s.stats.RetentionDays())
+
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"retention_days": s.stats.RetentionDays(),
+			"days":           s.stats.Days(days, time.Now()),
+		})
+		if err != nil {
+			s.log.WithError(err).Error("Failed to encode stats response")
+		}
+	})
+}
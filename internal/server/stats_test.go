@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/stats"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statsResponse is the body of /admin/stats
+type statsResponse struct {
+	RetentionDays int         `json:"retention_days"`
+	Days          []stats.Day `json:"days"`
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		Stats: config.StatsConfig{Directory: t.TempDir(), RetentionDays: 7, FlushInterval: time.Minute},
+		Endpoints: []config.EndpointConfig{{Path: "/webhook", Destinations: []config.DestinationConfig{
+			{URL: destination.URL, Method: "POST", Timeout: time.Second},
+		}}},
+	}
+	server := NewServer(cfg, log)
+	require.NotNil(t, server.stats)
+	server.registerEndpoint(cfg.Endpoints[0])
+	server.registerStatsEndpoint()
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{}`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	get := func(query string) (int, statsResponse) {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/stats"+query, nil))
+		var response statsResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+
+	require.Eventually(t, func() bool {
+		_, response := get("")
+		return len(response.Days) == 1 && response.Days[0].Destinations[destination.URL].Successes == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	code, response := get("?days=30")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 7, response.RetentionDays)
+	assert.Equal(t, time.Now().UTC().Format("2006-01-02"), response.Days[0].Date)
+	assert.Equal(t, int64(1), response.Days[0].Destinations[destination.URL].Attempts)
+	assert.Equal(t, int64(1), response.Days[0].Destinations[destination.URL].LatencyCount)
+
+	code, _ = get("?days=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("?days=week")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
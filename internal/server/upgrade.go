@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/flemzord/webhook-proxy/internal/rawheaders"
+	"github.com/flemzord/webhook-proxy/internal/upgrade"
+	"github.com/sirupsen/logrus"
+)
+
+// upgrades is the state of the graceful upgrades of a server
+type upgrades struct {
+	upgrader *upgrade.Upgrader
+	addrs    int // the number of sockets served
+
+	mu       sync.Mutex
+	servers  []*http.Server
+	draining bool
+	drained  chan struct{}
+}
+
+// startUpgradable starts the HTTP server on the sockets of an upgrader, inherited from the
+// process it upgrades, if any. On upgrade.Signal, a new process takes the sockets over and
+// it returns once this one is drained.
+func (s *Server) startUpgradable() error {
+	if upgrade.Signal == nil {
+		s.log.Warn("Graceful upgrades are not supported on this platform")
+		return s.StartWithServerFunc(s.defaultServerFunc())
+	}
+
+	upgrader, err := upgrade.New(s.config.Server.Upgrade.PIDFile)
+	if err != nil {
+		return err
+	}
+
+	// Open every socket before serving, so that an upgrade hands them all over
+	addrs := []string{s.addr(s.config.Server.Port)}
+	for _, l := range s.config.Server.Listeners {
+		addrs = append(addrs, s.addr(l.Port))
+	}
+	for _, addr := range addrs {
+		if _, err := upgrader.Listen(addr); err != nil {
+			upgrader.Close()
+			return err
+		}
+	}
+	if upgrader.HasParent() {
+		s.log.WithField("sockets", len(addrs)).Info("Taking over the sockets of the upgraded process")
+	}
+
+	s.upgrades = &upgrades{upgrader: upgrader, addrs: len(addrs), drained: make(chan struct{})}
+	return s.StartWithServerFunc(s.serveUpgradable)
+}
+
+// serveUpgradable serves the handler on the upgrader's socket of the address until the
+// server is drained. Once every socket is served, the upgraded process is notified and
+// upgrades are handled.
+func (s *Server) serveUpgradable(addr string, handler http.Handler) error {
+	u := s.upgrades
+	l, err := u.upgrader.Listen(addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: handler}
+	if s.preservesRawHeaders() {
+		l = rawheaders.Listener(l)
+		server.ConnContext = rawheaders.ConnContext
+	}
+
+	u.mu.Lock()
+	if u.draining {
+		u.mu.Unlock()
+		<-u.drained
+		return nil
+	}
+	u.servers = append(u.servers, server)
+	ready := len(u.servers) == u.addrs
+	u.mu.Unlock()
+
+	if ready {
+		if err := u.upgrader.Ready(); err != nil {
+			return err
+		}
+		go s.handleUpgrades()
+	}
+
+	if err := server.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-u.drained
+	return nil
+}
+
+// handleUpgrades hands the sockets over to a new process on upgrade.Signal, then drains
+// the server. The server keeps serving when the new process fails to start.
+func (s *Server) handleUpgrades() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, upgrade.Signal)
+	defer signal.Stop(signals)
+
+	for range signals {
+		s.log.Info("Upgrading, starting the new process")
+		if err := s.upgrades.upgrader.Upgrade(s.config.Server.Upgrade.ReadyTimeout); err != nil {
+			s.log.WithError(err).Error("Failed to upgrade, serving on")
+			continue
+		}
+		s.log.Info("New process serving, draining")
+		s.drain()
+		return
+	}
+}
+
+// drain stops the queue workers and accepting connections, then waits for the requests
+// being served and the deliveries of the forwarded webhooks, within the drain timeout, hands
+// the queue over to the new process and closes the sinks
+func (s *Server) drain() {
+	u := s.upgrades
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Server.Upgrade.DrainTimeout)
+	defer cancel()
+
+	// Stop the queue workers first, so that the webhooks queued while draining are left on
+	// disk for the new process
+	if s.queue != nil {
+		s.queue.Stop()
+	}
+
+	u.mu.Lock()
+	u.draining = true
+	servers := u.servers
+	u.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				s.log.WithError(err).WithField("address", server.Addr).Warn("Drain timed out, closing the remaining connections")
+				server.Close()
+			}
+		}(server)
+	}
+	wg.Wait()
+
+	// The new process takes the queue over once the workers are done with their webhooks
+	if s.queue != nil {
+		if err := s.stopQueue(ctx); err != nil {
+			s.log.WithError(err).Warn("Drain timed out, the queue is handed over on exit")
+		}
+	}
+
+	for path, handler := range s.proxyHandlers {
+		if err := handler.Drain(ctx); err != nil {
+			s.log.WithError(err).WithField("endpoint", path).Warn("Drain timed out, exiting with deliveries in flight")
+		}
+		if err := handler.Close(); err != nil {
+			s.log.WithError(err).WithField("endpoint", path).Error("Failed to close the sinks")
+		}
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"pid": os.Getpid(),
+	}).Info("Drained, exiting")
+	close(u.drained)
+}
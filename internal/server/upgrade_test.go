@@ -0,0 +1,166 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/upgrade"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeDrain(t *testing.T) {
+	if upgrade.Signal == nil {
+		t.Skip("upgrades are not supported on this platform")
+	}
+
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		received <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	// A free port for the proxy
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	pidFile := filepath.Join(t.TempDir(), "webhook-proxy.pid")
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Host:    "127.0.0.1",
+			Port:    port,
+			Upgrade: config.UpgradeConfig{Enabled: true, ReadyTimeout: time.Second, DrainTimeout: 5 * time.Second, PIDFile: pidFile},
+		},
+		Endpoints: []config.EndpointConfig{
+			{Path: "/webhook", Destinations: []config.DestinationConfig{{URL: destination.URL, Method: "POST", Timeout: 5 * time.Second}}},
+		},
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard) // Silence logs during tests
+
+	server := NewServer(cfg, log)
+	started := make(chan error, 1)
+	go func() {
+		started <- server.Start()
+	}()
+
+	// The PID file is written once the proxy serves
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(pidFile)
+		return err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid())
+	}, 5*time.Second, 10*time.Millisecond)
+
+	addr := "http://" + server.addr(port)
+	resp, err := http.Post(addr+"/webhook", "application/json", strings.NewReader(`{"event":"test"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	<-received
+
+	// The drain stops accepting connections and waits for the delivery in flight
+	go server.drain()
+	require.Eventually(t, func() bool {
+		_, err := http.Get(addr + "/health")
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	select {
+	case <-started:
+		t.Fatal("Expected the server to wait for the delivery in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-started:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to stop once drained")
+	}
+	assert.Equal(t, int64(1), server.proxyHandlers["/webhook"].GetMetrics()["successful_requests"])
+}
+
+func TestUpgradeDrainHandsOverQueue(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	received := map[string]int{}
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[string(body)]++
+		mu.Unlock()
+		if string(body) == "in-flight" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+	count := func(body string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return received[body]
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	dir := t.TempDir()
+	cfg := queueConfig(dir, destination.URL)
+	cfg.Server.Upgrade.DrainTimeout = 5 * time.Second
+	post := func(server *Server, body string) {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body)))
+		require.Equal(t, http.StatusAccepted, w.Code)
+	}
+
+	// The upgraded process is forwarding a queued webhook
+	old := NewServer(cfg, log)
+	old.registerEndpoint(cfg.Endpoints[0])
+	old.upgrades = &upgrades{drained: make(chan struct{})}
+	old.startQueue()
+	post(old, "in-flight")
+	require.Eventually(t, func() bool { return count("in-flight") == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	// The new process forwards the webhooks it queues while the queue is locked
+	upgraded := NewServer(cfg, log)
+	upgraded.registerEndpoint(cfg.Endpoints[0])
+	upgraded.startQueue()
+	post(upgraded, "new")
+	require.Eventually(t, func() bool { return count("new") == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	// A webhook queued by the upgraded process while it drains is left for the new process
+	go old.drain()
+	require.Eventually(t, func() bool {
+		old.upgrades.mu.Lock()
+		defer old.upgrades.mu.Unlock()
+		return old.upgrades.draining
+	}, time.Second, time.Millisecond)
+	post(old, "draining")
+	assert.Never(t, func() bool { return count("draining") > 0 }, 100*time.Millisecond, 5*time.Millisecond)
+
+	// Once the webhook in flight is delivered, the queue is handed over
+	close(release)
+	<-old.upgrades.drained
+	require.Eventually(t, func() bool {
+		return count("draining") == 1 && queuedFiles(t, dir) == 0
+	}, 2*time.Second, 5*time.Millisecond)
+
+	// Every webhook is delivered once
+	assert.Equal(t, map[string]int{"in-flight": 1, "new": 1, "draining": 1}, received)
+}
@@ -0,0 +1,143 @@
+package sink
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/transform"
+	"github.com/sirupsen/logrus"
+)
+
+// awsDefaultRegion is used when no region is configured or found in the AWS environment
+const awsDefaultRegion = "us-east-1"
+
+// loadAWSConfig loads the AWS configuration of a destination, with its region and static
+// credentials when set, and the AWS default chain otherwise
+func loadAWSConfig(region, accessKeyID, secretAccessKey string) (aws.Config, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	if accessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, ""),
+		))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load aws configuration: %w", err)
+	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = awsDefaultRegion
+	}
+	return awsCfg, nil
+}
+
+// message is a webhook as an SQS or SNS message
+type message struct {
+	body       string
+	group      string
+	attributes map[string]string
+}
+
+// messageTemplates renders the message group and attributes of the SQS and SNS messages of
+// an endpoint's webhooks
+type messageTemplates struct {
+	endpoint   string
+	log        *logrus.Logger
+	group      *transform.Template
+	attributes map[string]*transform.Template
+}
+
+// newMessageTemplates parses the message group and attribute templates of a destination
+func newMessageTemplates(endpoint, group string, attributes map[string]string, log *logrus.Logger) (*messageTemplates, error) {
+	m := &messageTemplates{endpoint: endpoint, log: log, attributes: make(map[string]*transform.Template, len(attributes))}
+
+	if group != "" {
+		tmpl, err := transform.Parse(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message group template: %w", err)
+		}
+		m.group = tmpl
+	}
+
+	for name, attribute := range attributes {
+		tmpl, err := transform.Parse(attribute)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template of attribute %s: %w", name, err)
+		}
+		m.attributes[name] = tmpl
+	}
+
+	return m, nil
+}
+
+// message returns the message of a webhook. Attributes failing to render, or rendering
+// empty, are left out, and the message group falls back to the endpoint. Bodies that SQS
+// and SNS do not accept as text are sent base64-encoded, with a Content-Transfer-Encoding
+// attribute.
+func (m *messageTemplates) message(body []byte, headers map[string]string) message {
+	msg := message{body: string(body), attributes: make(map[string]string, len(m.attributes)+1)}
+	if !isMessageText(body) {
+		msg.body = base64.StdEncoding.EncodeToString(body)
+		msg.attributes[config.ContentTransferEncodingAttribute] = "base64"
+	}
+	if m.group == nil && len(m.attributes) == 0 {
+		return msg
+	}
+
+	data := transform.Data{Endpoint: m.endpoint, Headers: headers}
+	var payload interface{}
+	if json.Unmarshal(body, &payload) == nil {
+		data.Payload = payload
+	}
+
+	for name, tmpl := range m.attributes {
+		value, err := tmpl.Render(data)
+		if err != nil {
+			m.log.WithError(err).WithFields(logrus.Fields{
+				"endpoint":  m.endpoint,
+				"attribute": name,
+			}).Warn("Failed to render a message attribute, sending the message without it")
+			continue
+		}
+		if len(value) > 0 {
+			msg.attributes[name] = string(value)
+		}
+	}
+
+	if m.group != nil {
+		group, err := m.group.Render(data)
+		if err != nil {
+			m.log.WithError(err).WithField("endpoint", m.endpoint).Warn("Failed to render the message group, using the endpoint")
+		}
+		msg.group = string(group)
+		if err != nil || msg.group == "" {
+			msg.group = m.endpoint
+		}
+	}
+
+	return msg
+}
+
+// isMessageText reports whether SQS and SNS accept the body as the text of a message: valid
+// UTF-8 without the control characters XML forbids
+func isMessageText(body []byte) bool {
+	if !utf8.Valid(body) {
+		return false
+	}
+	for _, r := range string(body) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package sink
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageTemplates(t *testing.T) {
+	templates, err := newMessageTemplates("/webhook/github", `{{.Payload.repository.full_name}}`, map[string]string{
+		"event":  `{{index .Headers "X-GitHub-Event"}}`,
+		"action": `{{with .Payload.action}}{{.}}{{end}}`,
+	}, discardLogger())
+	require.NoError(t, err)
+
+	body := []byte(`{"action":"opened","repository":{"full_name":"octo/hello"}}`)
+	msg := templates.message(body, map[string]string{"X-GitHub-Event": "pull_request"})
+	assert.Equal(t, string(body), msg.body)
+	assert.Equal(t, "octo/hello", msg.group)
+	assert.Equal(t, map[string]string{"event": "pull_request", "action": "opened"}, msg.attributes)
+
+	// Attributes rendering empty are left out, and the group falls back to the endpoint
+	msg = templates.message([]byte(`{}`), nil)
+	assert.Equal(t, "/webhook/github", msg.group)
+	assert.Empty(t, msg.attributes)
+
+	_, err = newMessageTemplates("/webhook", "", map[string]string{"event": "{{.Payload"}, discardLogger())
+	assert.ErrorContains(t, err, "attribute event")
+}
+
+func TestMessageBinaryBody(t *testing.T) {
+	templates, err := newMessageTemplates("/webhook", "", nil, discardLogger())
+	require.NoError(t, err)
+
+	for _, body := range [][]byte{{0x82, 0xa5, 0xff}, []byte("nul\x00byte")} {
+		msg := templates.message(body, nil)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(body), msg.body)
+		assert.Equal(t, map[string]string{config.ContentTransferEncodingAttribute: "base64"}, msg.attributes)
+		assert.Empty(t, msg.group)
+	}
+
+	msg := templates.message([]byte("line one\r\n\tline two é"), nil)
+	assert.Equal(t, "line one\r\n\tline two é", msg.body)
+	assert.Empty(t, msg.attributes)
+}
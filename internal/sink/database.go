@@ -0,0 +1,163 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+
+	// Register the PostgreSQL driver for database/sql
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// DatabaseSink inserts webhooks as rows into a PostgreSQL or ClickHouse table.
+// Each row holds the webhook ID, the endpoint, the destination, the reception time,
+// the headers, and the payload as JSON.
+type DatabaseSink struct {
+	config config.DatabaseConfig
+	db     *sql.DB
+	client *http.Client
+}
+
+// databaseRow is a webhook as stored in the database
+type databaseRow struct {
+	DeliveryID  string
+	Endpoint    string
+	Destination string
+	ReceivedAt  time.Time
+	Headers     json.RawMessage
+	Payload     json.RawMessage
+}
+
+// NewDatabaseSink creates a new database sink. PostgreSQL is reached through
+// database/sql; ClickHouse is reached through its HTTP interface.
+func NewDatabaseSink(cfg config.DatabaseConfig) (*DatabaseSink, error) {
+	s := &DatabaseSink{config: cfg}
+
+	switch cfg.Driver {
+	case config.DatabaseDriverPostgres:
+		db, err := sql.Open("pgx", cfg.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		s.db = db
+	case config.DatabaseDriverClickHouse:
+		s.client = &http.Client{}
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+
+	return s, nil
+}
+
+// Send inserts the webhook into the configured table
+func (s *DatabaseSink) Send(ctx context.Context, msg *Message) error {
+	row, err := newDatabaseRow(msg)
+	if err != nil {
+		return err
+	}
+
+	if s.db != nil {
+		return s.insertPostgres(ctx, row)
+	}
+	return s.insertClickHouse(ctx, row)
+}
+
+// Close closes the connections to PostgreSQL. ClickHouse requests hold none.
+func (s *DatabaseSink) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// newDatabaseRow builds the row stored for a webhook
+func newDatabaseRow(msg *Message) (databaseRow, error) {
+	payload, err := jsonPayload(msg.Body)
+	if err != nil {
+		return databaseRow{}, err
+	}
+
+	headers := msg.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return databaseRow{}, err
+	}
+
+	receivedAt := msg.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+
+	return databaseRow{
+		DeliveryID:  webhookID(msg),
+		Endpoint:    msg.Endpoint,
+		Destination: msg.Destination,
+		ReceivedAt:  receivedAt.UTC(),
+		Headers:     encodedHeaders,
+		Payload:     payload,
+	}, nil
+}
+
+// insertPostgres inserts a row into a PostgreSQL table
+func (s *DatabaseSink) insertPostgres(ctx context.Context, row databaseRow) error {
+	// The table name is validated against an identifier pattern when the configuration is loaded
+	query := fmt.Sprintf("INSERT INTO %s (delivery_id, endpoint, destination, received_at, headers, payload) VALUES ($1, $2, $3, $4, $5, $6)", s.config.Table)
+
+	if _, err := s.db.ExecContext(ctx, query, row.DeliveryID, row.Endpoint, row.Destination, row.ReceivedAt, string(row.Headers), string(row.Payload)); err != nil {
+		return fmt.Errorf("failed to insert row: %w", err)
+	}
+
+	return nil
+}
+
+// insertClickHouse inserts a row into a ClickHouse table using the JSONEachRow format
+func (s *DatabaseSink) insertClickHouse(ctx context.Context, row databaseRow) error {
+	line, err := json.Marshal(map[string]string{
+		"delivery_id": row.DeliveryID,
+		"endpoint":    row.Endpoint,
+		"destination": row.Destination,
+		"received_at": row.ReceivedAt.Format("2006-01-02 15:04:05.000"),
+		"headers":     string(row.Headers),
+		"payload":     string(row.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := url.Parse(s.config.DSN)
+	if err != nil {
+		return fmt.Errorf("invalid dsn: %w", err)
+	}
+	query := endpoint.Query()
+	query.Set("query", fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", s.config.Table))
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(append(line, '\n')))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse returned status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/transform"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// kafkaClientID identifies the proxy to the brokers
+	kafkaClientID = "webhook-proxy"
+
+	// kafkaBatchTimeout bounds the time a record waits for others to be produced with it
+	kafkaBatchTimeout = 10 * time.Millisecond
+)
+
+// messageWriter is the subset of the Kafka writer used by the sink
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaSink publishes webhooks as records of a Kafka topic. The record value is the body of
+// the webhook and its headers are the record headers. Records are produced once: failed
+// deliveries go through the destination's retry policy.
+type KafkaSink struct {
+	endpoint string
+	log      *logrus.Logger
+	writer   messageWriter
+	keys     *transform.Template
+}
+
+// NewKafkaSink creates a new Kafka sink for the given endpoint
+func NewKafkaSink(endpoint string, cfg config.KafkaConfig, log *logrus.Logger) (*KafkaSink, error) {
+	transport := &kafka.Transport{ClientID: kafkaClientID}
+
+	if cfg.TLS != nil {
+		tlsConfig, err := kafkaTLSConfig(*cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLS = tlsConfig
+	}
+
+	if cfg.SASL != nil {
+		mechanism, err := kafkaSASLMechanism(*cfg.SASL)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		MaxAttempts:  1,
+		BatchTimeout: kafkaBatchTimeout,
+		RequiredAcks: kafkaRequiredAcks(cfg.Acks),
+		Transport:    transport,
+	}
+
+	return newKafkaSink(endpoint, cfg, writer, log)
+}
+
+// newKafkaSink creates a Kafka sink using the given writer
+func newKafkaSink(endpoint string, cfg config.KafkaConfig, writer messageWriter, log *logrus.Logger) (*KafkaSink, error) {
+	s := &KafkaSink{endpoint: endpoint, log: log, writer: writer}
+
+	if cfg.KeyTemplate != "" {
+		keys, err := transform.Parse(cfg.KeyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key template: %w", err)
+		}
+		s.keys = keys
+	}
+
+	return s, nil
+}
+
+// Send publishes the webhook and waits for its acknowledgement
+func (s *KafkaSink) Send(ctx context.Context, msg *Message) error {
+	message := kafka.Message{
+		Value:   msg.Body,
+		Headers: kafkaHeaders(msg.Headers),
+		Time:    time.Now(),
+	}
+
+	if s.keys != nil {
+		message.Key = s.key(msg.Body, msg.Headers)
+	}
+
+	if err := s.writer.WriteMessages(ctx, message); err != nil {
+		return fmt.Errorf("failed to produce record: %w", err)
+	}
+	return nil
+}
+
+// key renders the record key of a webhook. Records whose key is empty or fails to render
+// are produced without a key.
+func (s *KafkaSink) key(body []byte, headers map[string]string) []byte {
+	data := transform.Data{Endpoint: s.endpoint, Headers: headers}
+	var payload interface{}
+	if json.Unmarshal(body, &payload) == nil {
+		data.Payload = payload
+	}
+
+	key, err := s.keys.Render(data)
+	if err != nil {
+		s.log.WithError(err).WithField("endpoint", s.endpoint).Warn("Failed to render the Kafka record key, producing the record without a key")
+		return nil
+	}
+	if len(key) == 0 {
+		return nil
+	}
+	return key
+}
+
+// Close waits for the records being produced and closes the connections to the brokers
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
+
+// kafkaHeaders returns the headers of a webhook as record headers, sorted by name
+func kafkaHeaders(headers map[string]string) []kafka.Header {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	recordHeaders := make([]kafka.Header, len(names))
+	for i, name := range names {
+		recordHeaders[i] = kafka.Header{Key: name, Value: []byte(headers[name])}
+	}
+	return recordHeaders
+}
+
+// kafkaRequiredAcks returns the acknowledgement of the configured acks
+func kafkaRequiredAcks(acks string) kafka.RequiredAcks {
+	switch acks {
+	case config.KafkaAcksOne:
+		return kafka.RequireOne
+	case config.KafkaAcksNone:
+		return kafka.RequireNone
+	default:
+		return kafka.RequireAll
+	}
+}
+
+// kafkaSASLMechanism returns the SASL mechanism authenticating to the brokers
+func kafkaSASLMechanism(cfg config.KafkaSASLConfig) (sasl.Mechanism, error) {
+	switch cfg.Mechanism {
+	case config.KafkaSASLPlain:
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case config.KafkaSASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case config.KafkaSASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unsupported sasl mechanism: %s", cfg.Mechanism)
+	}
+}
+
+// kafkaTLSConfig returns the TLS configuration of the connections to the brokers
+func kafkaTLSConfig(cfg config.KafkaTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // explicitly requested in the configuration
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the broker CA file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the broker CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
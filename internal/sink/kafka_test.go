@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWriter is an in-memory messageWriter
+type fakeWriter struct {
+	messages []kafka.Message
+	err      error
+	closed   bool
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, msgs...)
+	return nil
+}
+
+func (f *fakeWriter) Close() error {
+	f.closed = true
+	return nil
+}
+
+func discardLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestKafkaSinkSend(t *testing.T) {
+	writer := &fakeWriter{}
+	s, err := newKafkaSink("/webhook/github", config.KafkaConfig{
+		Brokers:     []string{"localhost:9092"},
+		Topic:       "webhooks",
+		KeyTemplate: `{{.Payload.repository.full_name}}`,
+	}, writer, discardLogger())
+	require.NoError(t, err)
+
+	body := []byte(`{"repository":{"full_name":"octo/hello"}}`)
+	require.NoError(t, s.Send(context.Background(), &Message{Body: body, Headers: map[string]string{"X-GitHub-Event": "push", "Content-Type": "application/json"}}))
+
+	require.Len(t, writer.messages, 1)
+	message := writer.messages[0]
+	assert.Equal(t, body, message.Value)
+	assert.Equal(t, []byte("octo/hello"), message.Key)
+	assert.Equal(t, []kafka.Header{
+		{Key: "Content-Type", Value: []byte("application/json")},
+		{Key: "X-GitHub-Event", Value: []byte("push")},
+	}, message.Headers)
+
+	// Payloads without the key's fields, and bodies that are not JSON, are produced without a key
+	require.NoError(t, s.Send(context.Background(), &Message{Body: []byte(`{}`)}))
+	require.NoError(t, s.Send(context.Background(), &Message{Body: []byte(`event=push`)}))
+	assert.Nil(t, writer.messages[1].Key)
+	assert.Nil(t, writer.messages[2].Key)
+
+	require.NoError(t, s.Close())
+	assert.True(t, writer.closed)
+}
+
+func TestKafkaSinkSendError(t *testing.T) {
+	writer := &fakeWriter{err: errors.New("leader not available")}
+	s, err := newKafkaSink("/webhook", config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "webhooks"}, writer, discardLogger())
+	require.NoError(t, err)
+
+	err = s.Send(context.Background(), &Message{Body: []byte(`{}`)})
+	assert.ErrorContains(t, err, "leader not available")
+}
+
+func TestNewKafkaSink(t *testing.T) {
+	s, err := NewKafkaSink("/webhook", config.KafkaConfig{
+		Brokers: []string{"localhost:9092"},
+		Topic:   "webhooks",
+		Acks:    config.KafkaAcksOne,
+		SASL:    &config.KafkaSASLConfig{Mechanism: config.KafkaSASLScramSHA512, Username: "proxy", Password: "secret"},
+		TLS:     &config.KafkaTLSConfig{ServerName: "kafka.internal"},
+	}, discardLogger())
+	require.NoError(t, err)
+	writer := s.writer.(*kafka.Writer)
+	assert.Equal(t, kafka.RequireOne, writer.RequiredAcks)
+	assert.Equal(t, 1, writer.MaxAttempts)
+	transport := writer.Transport.(*kafka.Transport)
+	assert.Equal(t, "kafka.internal", transport.TLS.ServerName)
+	assert.Equal(t, "SCRAM-SHA-512", transport.SASL.Name())
+	require.NoError(t, s.Close())
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+	_, err = NewKafkaSink("/webhook", config.KafkaConfig{
+		Brokers: []string{"localhost:9092"},
+		Topic:   "webhooks",
+		TLS:     &config.KafkaTLSConfig{CAFile: caFile},
+	}, discardLogger())
+	assert.ErrorContains(t, err, "no certificate found")
+}
@@ -0,0 +1,300 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flemzord/webhook-proxy/internal/bufpool"
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// s3FlushTimeout is the maximum time allowed to upload a batch
+const s3FlushTimeout = 30 * time.Second
+
+// objectPutter is the subset of the S3 client used by the sink
+type objectPutter interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Sink archives webhooks as JSON-lines objects in an S3-compatible bucket.
+// With a batch size greater than one, webhooks are buffered and uploaded together
+// once the batch is full or the flush interval elapses; each send returns once its
+// batch is uploaded, so that webhooks are only acknowledged once stored.
+type S3Sink struct {
+	config   config.S3Config
+	endpoint string
+	log      *logrus.Logger
+	client   objectPutter
+	keys     *template.Template
+
+	mu      sync.Mutex
+	pending []s3Line
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// s3Line is the JSON line of a webhook, with the ID and reception time naming its object
+type s3Line struct {
+	id         string
+	receivedAt time.Time
+	data       []byte
+
+	// done receives the result of the upload of a buffered line
+	done chan error
+}
+
+// s3Record is a webhook as stored in an object
+type s3Record struct {
+	ID         string            `json:"id"`
+	ReceivedAt string            `json:"received_at"`
+	Endpoint   string            `json:"endpoint"`
+	Headers    map[string]string `json:"headers"`
+	Payload    json.RawMessage   `json:"payload"`
+}
+
+// s3KeyData holds the values available to the object key template
+type s3KeyData struct {
+	Year      string
+	Month     string
+	Day       string
+	Hour      string
+	Timestamp string
+	Endpoint  string
+	ID        string
+}
+
+// NewS3Sink creates a new S3 sink for the given endpoint
+func NewS3Sink(endpoint string, cfg config.S3Config, log *logrus.Logger) (*S3Sink, error) {
+	// S3-compatible stores usually ignore the region
+	awsCfg, err := loadAWSConfig(cfg.Region, cfg.AccessKeyID, cfg.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.PathStyle
+	})
+
+	return newS3Sink(endpoint, cfg, client, log)
+}
+
+// newS3Sink creates an S3 sink using the given client
+func newS3Sink(endpoint string, cfg config.S3Config, client objectPutter, log *logrus.Logger) (*S3Sink, error) {
+	keys, err := template.New("key").Option("missingkey=error").Parse(cfg.KeyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key template: %w", err)
+	}
+
+	s := &S3Sink{
+		config:   cfg,
+		endpoint: endpoint,
+		log:      log,
+		client:   client,
+		keys:     keys,
+		stop:     make(chan struct{}),
+	}
+
+	if cfg.BatchSize > 1 {
+		s.wg.Add(1)
+		go s.flushLoop()
+	}
+
+	return s, nil
+}
+
+// Send stores the webhook. Without batching it is uploaded immediately, otherwise
+// it is buffered and Send waits for the batch to be uploaded: a failed upload fails
+// every webhook of the batch, for their deliveries to retry.
+func (s *S3Sink) Send(ctx context.Context, msg *Message) error {
+	data, receivedAt, err := s.encode(msg)
+	if err != nil {
+		return err
+	}
+	line := s3Line{id: webhookID(msg), receivedAt: receivedAt, data: data}
+
+	if s.config.BatchSize <= 1 {
+		return s.upload(ctx, []s3Line{line})
+	}
+
+	line.done = make(chan error, 1)
+	s.mu.Lock()
+	s.pending = append(s.pending, line)
+	full := len(s.pending) >= s.config.BatchSize
+	s.mu.Unlock()
+
+	if full {
+		s.flush()
+	}
+
+	select {
+	case err := <-line.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the flush loop and uploads any buffered webhooks
+func (s *S3Sink) Close() error {
+	if s.config.BatchSize <= 1 {
+		return nil
+	}
+
+	close(s.stop)
+	s.wg.Wait()
+
+	return s.flush()
+}
+
+// flushLoop flushes the buffered webhooks at the configured interval
+func (s *S3Sink) flushLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// flush uploads the buffered webhooks as one object, and hands the result to
+// their sends
+func (s *S3Sink) flush() error {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3FlushTimeout)
+	defer cancel()
+
+	err := s.upload(ctx, batch)
+	if err != nil {
+		s.log.WithFields(logrus.Fields{
+			"error":    err,
+			"bucket":   s.config.Bucket,
+			"webhooks": len(batch),
+		}).Error("Failed to upload webhook batch")
+	}
+
+	for _, line := range batch {
+		line.done <- err
+	}
+	return err
+}
+
+// encode builds the JSON line stored for a webhook, and returns the time it was received
+func (s *S3Sink) encode(msg *Message) ([]byte, time.Time, error) {
+	payload, err := jsonPayload(msg.Body)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	receivedAt := msg.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+
+	data, err := json.Marshal(s3Record{
+		ID:         webhookID(msg),
+		ReceivedAt: receivedAt.UTC().Format(time.RFC3339Nano),
+		Endpoint:   s.endpoint,
+		Headers:    msg.Headers,
+		Payload:    payload,
+	})
+	return data, receivedAt, err
+}
+
+// upload writes the given JSON lines as a single object, named after the first webhook
+func (s *S3Sink) upload(ctx context.Context, lines []s3Line) error {
+	key, err := s.objectKey(lines[0].receivedAt.UTC(), lines[0].id)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	for _, line := range lines {
+		body = append(body, line.data...)
+		body = append(body, '\n')
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.config.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String("application/x-ndjson"),
+	}
+
+	if s.config.Gzip {
+		// The pooled buffer is only released once the object is uploaded
+		buf := bufpool.Get()
+		defer bufpool.Put(buf)
+
+		gz := gzip.NewWriter(buf)
+		if _, err := gz.Write(body); err != nil {
+			return fmt.Errorf("failed to compress object: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress object: %w", err)
+		}
+		body = buf.Bytes()
+		input.ContentEncoding = aws.String("gzip")
+	}
+
+	input.Body = bytes.NewReader(body)
+	input.ContentLength = aws.Int64(int64(len(body)))
+
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// objectKey renders the object key template for the webhook received at the given time
+func (s *S3Sink) objectKey(receivedAt time.Time, id string) (string, error) {
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	var key strings.Builder
+	err := s.keys.Execute(&key, s3KeyData{
+		Year:      receivedAt.Format("2006"),
+		Month:     receivedAt.Format("01"),
+		Day:       receivedAt.Format("02"),
+		Hour:      receivedAt.Format("15"),
+		Timestamp: receivedAt.Format("20060102T150405Z"),
+		Endpoint:  strings.Trim(s.endpoint, "/"),
+		ID:        id,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render object key: %w", err)
+	}
+
+	// Avoid empty path segments when the endpoint is unknown
+	return strings.ReplaceAll(strings.TrimPrefix(key.String(), "/"), "//", "/"), nil
+}
@@ -0,0 +1,241 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObject is an object stored by fakePutter
+type fakeObject struct {
+	key             string
+	contentEncoding string
+	body            []byte
+}
+
+// fakePutter is an in-memory objectPutter
+type fakePutter struct {
+	mu      sync.Mutex
+	objects []fakeObject
+	err     error
+}
+
+func (f *fakePutter) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f.objects = append(f.objects, fakeObject{
+		key:             aws.ToString(params.Key),
+		contentEncoding: aws.ToString(params.ContentEncoding),
+		body:            body,
+	})
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakePutter) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.objects)
+}
+
+// readRecords decodes the JSON lines of an object
+func readRecords(t *testing.T, obj fakeObject) []s3Record {
+	var reader io.Reader = bytes.NewReader(obj.body)
+	if obj.contentEncoding == "gzip" {
+		gz, err := gzip.NewReader(reader)
+		require.NoError(t, err)
+		reader = gz
+	}
+
+	var records []s3Record
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var record s3Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestS3SinkSend(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	putter := &fakePutter{}
+	s, err := newS3Sink("/webhook/github", config.S3Config{
+		Bucket:      "archive",
+		KeyTemplate: config.DefaultS3KeyTemplate,
+		BatchSize:   1,
+	}, putter, log)
+	require.NoError(t, err)
+
+	// Objects are named after the webhook and the time it was received
+	receivedAt := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+	err = s.Send(context.Background(), &Message{ID: "delivery-1", WebhookID: "webhook-1", ReceivedAt: receivedAt, Body: []byte(`{"event":"push"}`), Headers: map[string]string{"X-Event": "push"}})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, putter.count())
+	obj := putter.objects[0]
+	assert.Equal(t, "2024/03/05/webhook/github/20240305T143000Z-webhook-1.jsonl", obj.key)
+	assert.Empty(t, obj.contentEncoding)
+
+	records := readRecords(t, obj)
+	require.Len(t, records, 1)
+	assert.Equal(t, "webhook-1", records[0].ID)
+	assert.Equal(t, "/webhook/github", records[0].Endpoint)
+	assert.Equal(t, "push", records[0].Headers["X-Event"])
+	assert.JSONEq(t, `{"event":"push"}`, string(records[0].Payload))
+
+	// Upload errors are returned so the delivery can be retried
+	putter.err = errors.New("access denied")
+	err = s.Send(context.Background(), &Message{Body: []byte(`{"event":"push"}`)})
+	assert.Error(t, err)
+
+	assert.NoError(t, s.Close())
+}
+
+// pendingCount returns the number of webhooks buffered by a sink
+func pendingCount(s *S3Sink) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// sendAsync sends a webhook in the background, once the previous ones are buffered, and
+// returns the channel of its result
+func sendAsync(t *testing.T, s *S3Sink, msg *Message) <-chan error {
+	buffered := pendingCount(s)
+	result := make(chan error, 1)
+	go func() { result <- s.Send(context.Background(), msg) }()
+	require.Eventually(t, func() bool { return pendingCount(s) > buffered }, time.Second, time.Millisecond)
+	return result
+}
+
+func TestS3SinkBatching(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	putter := &fakePutter{}
+	s, err := newS3Sink("/webhook", config.S3Config{
+		Bucket:        "archive",
+		KeyTemplate:   "{{.Endpoint}}/{{.ID}}.jsonl.gz",
+		Gzip:          true,
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+	}, putter, log)
+	require.NoError(t, err)
+
+	// Webhooks are buffered, and not acknowledged, until the batch is uploaded
+	var results []<-chan error
+	for i := 0; i < 2; i++ {
+		results = append(results, sendAsync(t, s, &Message{WebhookID: fmt.Sprintf("webhook-%d", i), Body: []byte(`{"event":"push"}`)}))
+	}
+	assert.Equal(t, 0, putter.count())
+	for _, result := range results {
+		assert.Empty(t, result)
+	}
+
+	// The object is named after the first webhook of the batch
+	require.NoError(t, s.Send(context.Background(), &Message{WebhookID: "webhook-2", Body: []byte(`{"event":"push"}`)}))
+	for _, result := range results {
+		assert.NoError(t, <-result)
+	}
+	require.Equal(t, 1, putter.count())
+	assert.Equal(t, "gzip", putter.objects[0].contentEncoding)
+	assert.Equal(t, "webhook/webhook-0.jsonl.gz", putter.objects[0].key)
+	assert.Len(t, readRecords(t, putter.objects[0]), 3)
+
+	// A failed upload fails every webhook of the batch, for their deliveries to retry
+	putter.err = errors.New("unavailable")
+	results = nil
+	for i := 0; i < 2; i++ {
+		results = append(results, sendAsync(t, s, &Message{Body: []byte(`{"event":"push"}`)}))
+	}
+	assert.Error(t, s.Send(context.Background(), &Message{Body: []byte(`{"event":"push"}`)}))
+	for _, result := range results {
+		assert.Error(t, <-result)
+	}
+	assert.Equal(t, 0, pendingCount(s))
+
+	// Close flushes the remaining webhooks
+	putter.err = nil
+	result := sendAsync(t, s, &Message{Body: []byte(`{"event":"push"}`)})
+	require.NoError(t, s.Close())
+	assert.NoError(t, <-result)
+	require.Equal(t, 2, putter.count())
+	assert.Len(t, readRecords(t, putter.objects[1]), 1)
+}
+
+func TestS3SinkBatchContext(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	putter := &fakePutter{}
+	s, err := newS3Sink("/webhook", config.S3Config{
+		Bucket:        "archive",
+		KeyTemplate:   config.DefaultS3KeyTemplate,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	}, putter, log)
+	require.NoError(t, err)
+
+	// A send whose context ends before its batch is uploaded fails
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = s.Send(ctx, &Message{Body: []byte(`{"event":"push"}`)})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, s.Close())
+}
+
+func TestS3SinkFlushInterval(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	putter := &fakePutter{}
+	s, err := newS3Sink("/webhook", config.S3Config{
+		Bucket:        "archive",
+		KeyTemplate:   config.DefaultS3KeyTemplate,
+		BatchSize:     100,
+		FlushInterval: 20 * time.Millisecond,
+	}, putter, log)
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Send(context.Background(), &Message{Body: []byte(`{"event":"push"}`)}))
+
+	assert.Eventually(t, func() bool { return putter.count() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestNewS3SinkInvalidTemplate(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	_, err := newS3Sink("/webhook", config.S3Config{Bucket: "archive", KeyTemplate: "{{.Unclosed"}, &fakePutter{}, log)
+	assert.Error(t, err)
+}
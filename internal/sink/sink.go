@@ -0,0 +1,96 @@
+// Package sink provides destinations that deliver webhooks over transports other than HTTP
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Sink delivers a webhook to a non-HTTP destination
+type Sink interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Message is the delivery of a webhook to a sink
+type Message struct {
+	// ID identifies the delivery, the same across its retries
+	ID string
+	// WebhookID identifies the webhook, the same across its destinations
+	WebhookID   string
+	Endpoint    string
+	Destination string
+	ReceivedAt  time.Time
+	// Body is shared between destinations and must not be modified
+	Body    []byte
+	Headers map[string]string
+}
+
+// Subscribable is a sink that serves its own subscription route on the proxy's server
+type Subscribable interface {
+	Sink
+	http.Handler
+	Path() string
+}
+
+// New creates the sink for a destination of the given endpoint. It returns nil for HTTP and Slack destinations.
+func New(endpoint string, dest config.DestinationConfig, log *logrus.Logger) (Sink, error) {
+	switch dest.Type {
+	case "", config.DestinationTypeHTTP, config.DestinationTypeSlack:
+		return nil, nil
+	case config.DestinationTypeWebSocket:
+		if dest.WebSocket == nil {
+			return nil, fmt.Errorf("websocket configuration is required")
+		}
+		return NewWebSocketHub(*dest.WebSocket, log), nil
+	case config.DestinationTypeDatabase:
+		if dest.Database == nil {
+			return nil, fmt.Errorf("database configuration is required")
+		}
+		return NewDatabaseSink(*dest.Database)
+	case config.DestinationTypeS3:
+		if dest.S3 == nil {
+			return nil, fmt.Errorf("s3 configuration is required")
+		}
+		return NewS3Sink(endpoint, *dest.S3, log)
+	case config.DestinationTypeKafka:
+		if dest.Kafka == nil {
+			return nil, fmt.Errorf("kafka configuration is required")
+		}
+		return NewKafkaSink(endpoint, *dest.Kafka, log)
+	case config.DestinationTypeSQS:
+		if dest.SQS == nil {
+			return nil, fmt.Errorf("sqs configuration is required")
+		}
+		return NewSQSSink(endpoint, *dest.SQS, log)
+	case config.DestinationTypeSNS:
+		if dest.SNS == nil {
+			return nil, fmt.Errorf("sns configuration is required")
+		}
+		return NewSNSSink(endpoint, *dest.SNS, log)
+	default:
+		return nil, fmt.Errorf("unsupported destination type: %s", dest.Type)
+	}
+}
+
+// jsonPayload returns the body as raw JSON. Bodies that are not valid JSON are encoded as a JSON string.
+func jsonPayload(body []byte) (json.RawMessage, error) {
+	if json.Valid(body) {
+		return body, nil
+	}
+	return json.Marshal(string(body))
+}
+
+// webhookID returns the ID of the webhook, the one its sender was answered with. Deliveries
+// resumed from an older retry state may not carry it, and fall back to their own ID.
+func webhookID(msg *Message) string {
+	if msg.WebhookID != "" {
+		return msg.WebhookID
+	}
+	return msg.ID
+}
@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"io"
+	"testing"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	// HTTP destinations have no sink
+	s, err := New("/webhook", config.DestinationConfig{Type: config.DestinationTypeHTTP, URL: "http://example.com"}, log)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	// Websocket destinations create a hub
+	s, err = New("/webhook", config.DestinationConfig{
+		Type:      config.DestinationTypeWebSocket,
+		WebSocket: &config.WebSocketConfig{Path: "/live"},
+	}, log)
+	assert.NoError(t, err)
+	hub, ok := s.(*WebSocketHub)
+	assert.True(t, ok, "sink should be a websocket hub")
+	assert.Equal(t, "/live", hub.Path())
+
+	// Websocket destinations require their configuration
+	_, err = New("/webhook", config.DestinationConfig{Type: config.DestinationTypeWebSocket}, log)
+	assert.Error(t, err)
+
+	// S3 destinations create an S3 sink
+	s, err = New("/webhook", config.DestinationConfig{
+		Type: config.DestinationTypeS3,
+		S3:   &config.S3Config{Bucket: "archive", Region: "eu-west-1", KeyTemplate: config.DefaultS3KeyTemplate, BatchSize: 1},
+	}, log)
+	assert.NoError(t, err)
+	_, ok = s.(*S3Sink)
+	assert.True(t, ok, "sink should be an S3 sink")
+
+	// Kafka destinations create a Kafka sink, and require their configuration
+	s, err = New("/webhook", config.DestinationConfig{
+		Type:  config.DestinationTypeKafka,
+		Kafka: &config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "webhooks"},
+	}, log)
+	assert.NoError(t, err)
+	_, ok = s.(*KafkaSink)
+	assert.True(t, ok, "sink should be a Kafka sink")
+	_, err = New("/webhook", config.DestinationConfig{Type: config.DestinationTypeKafka}, log)
+	assert.Error(t, err)
+
+	// SQS and SNS destinations create their sinks, and require their configuration
+	s, err = New("/webhook", config.DestinationConfig{
+		Type: config.DestinationTypeSQS,
+		SQS:  &config.SQSConfig{Queue: "arn:aws:sqs:eu-west-1:123456789012:webhooks"},
+	}, log)
+	assert.NoError(t, err)
+	_, ok = s.(*SQSSink)
+	assert.True(t, ok, "sink should be an SQS sink")
+	_, err = New("/webhook", config.DestinationConfig{Type: config.DestinationTypeSQS}, log)
+	assert.Error(t, err)
+
+	s, err = New("/webhook", config.DestinationConfig{
+		Type: config.DestinationTypeSNS,
+		SNS:  &config.SNSConfig{TopicARN: "arn:aws:sns:eu-west-1:123456789012:webhooks"},
+	}, log)
+	assert.NoError(t, err)
+	_, ok = s.(*SNSSink)
+	assert.True(t, ok, "sink should be an SNS sink")
+	_, err = New("/webhook", config.DestinationConfig{Type: config.DestinationTypeSNS}, log)
+	assert.Error(t, err)
+
+	// Unknown types are rejected
+	_, err = New("/webhook", config.DestinationConfig{Type: "carrier-pigeon"}, log)
+	assert.Error(t, err)
+}
+
+func TestJSONPayload(t *testing.T) {
+	payload, err := jsonPayload([]byte(`{"event":"push"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"event":"push"}`, string(payload))
+
+	payload, err = jsonPayload([]byte("plain text"))
+	assert.NoError(t, err)
+	assert.Equal(t, `"plain text"`, string(payload))
+}
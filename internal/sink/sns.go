@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// messagePublisher is the subset of the SNS client used by the sink
+type messagePublisher interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SNSSink publishes webhooks as messages of an SNS topic, fanned out to its subscriptions.
+// The message is the body of the webhook.
+type SNSSink struct {
+	topicARN  string
+	client    messagePublisher
+	templates *messageTemplates
+}
+
+// NewSNSSink creates a new SNS sink for the given endpoint
+func NewSNSSink(endpoint string, cfg config.SNSConfig, log *logrus.Logger) (*SNSSink, error) {
+	topic, err := arn.Parse(cfg.TopicARN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid topic arn: %w", err)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = topic.Region
+	}
+
+	awsCfg, err := loadAWSConfig(region, cfg.AccessKeyID, cfg.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	client := sns.NewFromConfig(awsCfg, func(o *sns.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	return newSNSSink(endpoint, cfg, client, log)
+}
+
+// newSNSSink creates an SNS sink using the given client
+func newSNSSink(endpoint string, cfg config.SNSConfig, client messagePublisher, log *logrus.Logger) (*SNSSink, error) {
+	templates, err := newMessageTemplates(endpoint, cfg.MessageGroupID, cfg.Attributes, log)
+	if err != nil {
+		return nil, err
+	}
+	return &SNSSink{topicARN: cfg.TopicARN, client: client, templates: templates}, nil
+}
+
+// Send publishes the webhook as a message of the topic
+func (s *SNSSink) Send(ctx context.Context, webhook *Message) error {
+	msg := s.templates.message(webhook.Body, webhook.Headers)
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(s.topicARN),
+		Message:  aws.String(msg.body),
+	}
+	if msg.group != "" {
+		input.MessageGroupId = aws.String(msg.group)
+	}
+	if len(msg.attributes) > 0 {
+		input.MessageAttributes = make(map[string]types.MessageAttributeValue, len(msg.attributes))
+		for name, value := range msg.attributes {
+			input.MessageAttributes[name] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+		}
+	}
+
+	if _, err := s.client.Publish(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePublisher is an in-memory messagePublisher
+type fakePublisher struct {
+	inputs []*sns.PublishInput
+	err    error
+}
+
+func (f *fakePublisher) Publish(_ context.Context, params *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.inputs = append(f.inputs, params)
+	return &sns.PublishOutput{}, nil
+}
+
+func TestSNSSinkSend(t *testing.T) {
+	publisher := &fakePublisher{}
+	topic := "arn:aws:sns:eu-west-1:123456789012:stripe-events"
+	s, err := newSNSSink("/webhook/stripe", config.SNSConfig{
+		TopicARN:   topic,
+		Attributes: map[string]string{"type": `{{.Payload.type}}`},
+	}, publisher, discardLogger())
+	require.NoError(t, err)
+
+	body := []byte(`{"type":"invoice.paid"}`)
+	require.NoError(t, s.Send(context.Background(), &Message{Body: body}))
+
+	require.Len(t, publisher.inputs, 1)
+	input := publisher.inputs[0]
+	assert.Equal(t, topic, aws.ToString(input.TopicArn))
+	assert.Equal(t, string(body), aws.ToString(input.Message))
+	assert.Nil(t, input.MessageGroupId)
+	require.Contains(t, input.MessageAttributes, "type")
+	assert.Equal(t, "invoice.paid", aws.ToString(input.MessageAttributes["type"].StringValue))
+
+	publisher.err = errors.New("topic not found")
+	assert.ErrorContains(t, s.Send(context.Background(), &Message{Body: body}), "topic not found")
+}
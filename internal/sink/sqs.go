@@ -0,0 +1,114 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// messageSender is the subset of the SQS client used by the sink
+type messageSender interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// SQSSink sends webhooks as messages of an SQS queue, buffering them for consumers that
+// poll the queue. The message body is the body of the webhook.
+type SQSSink struct {
+	queueURL  string
+	client    messageSender
+	templates *messageTemplates
+}
+
+// NewSQSSink creates a new SQS sink for the given endpoint
+func NewSQSSink(endpoint string, cfg config.SQSConfig, log *logrus.Logger) (*SQSSink, error) {
+	queueURL, region, err := sqsQueue(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Region != "" {
+		region = cfg.Region
+	}
+
+	awsCfg, err := loadAWSConfig(region, cfg.AccessKeyID, cfg.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	client := sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	return newSQSSink(endpoint, cfg, queueURL, client, log)
+}
+
+// newSQSSink creates an SQS sink using the given client
+func newSQSSink(endpoint string, cfg config.SQSConfig, queueURL string, client messageSender, log *logrus.Logger) (*SQSSink, error) {
+	templates, err := newMessageTemplates(endpoint, cfg.MessageGroupID, cfg.Attributes, log)
+	if err != nil {
+		return nil, err
+	}
+	return &SQSSink{queueURL: queueURL, client: client, templates: templates}, nil
+}
+
+// Send sends the webhook as a message of the queue
+func (s *SQSSink) Send(ctx context.Context, webhook *Message) error {
+	msg := s.templates.message(webhook.Body, webhook.Headers)
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(s.queueURL),
+		MessageBody: aws.String(msg.body),
+	}
+	if msg.group != "" {
+		input.MessageGroupId = aws.String(msg.group)
+	}
+	if len(msg.attributes) > 0 {
+		input.MessageAttributes = make(map[string]types.MessageAttributeValue, len(msg.attributes))
+		for name, value := range msg.attributes {
+			input.MessageAttributes[name] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+		}
+	}
+
+	if _, err := s.client.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// sqsQueue returns the URL of the configured queue and the region found in its URL or ARN,
+// if any. Queue URLs are https://sqs.<region>.amazonaws.com/<account>/<name>; the URL of
+// an ARN uses the custom endpoint when set.
+func sqsQueue(cfg config.SQSConfig) (queueURL, region string, err error) {
+	if !arn.IsARN(cfg.Queue) {
+		u, err := url.Parse(cfg.Queue)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid queue url: %w", err)
+		}
+		if labels := strings.Split(u.Hostname(), "."); len(labels) > 2 && labels[0] == "sqs" {
+			region = labels[1]
+		}
+		return cfg.Queue, region, nil
+	}
+
+	parsed, err := arn.Parse(cfg.Queue)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid queue arn: %w", err)
+	}
+	base := cfg.Endpoint
+	if base == "" {
+		domain := "amazonaws.com"
+		if parsed.Partition == "aws-cn" {
+			domain = "amazonaws.com.cn"
+		}
+		base = "https://sqs." + parsed.Region + "." + domain
+	}
+	return strings.TrimSuffix(base, "/") + "/" + parsed.AccountID + "/" + parsed.Resource, parsed.Region, nil
+}
@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender is an in-memory messageSender
+type fakeSender struct {
+	inputs []*sqs.SendMessageInput
+	err    error
+}
+
+func (f *fakeSender) SendMessage(_ context.Context, params *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.inputs = append(f.inputs, params)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func TestSQSSinkSend(t *testing.T) {
+	sender := &fakeSender{}
+	queueURL := "https://sqs.eu-west-1.amazonaws.com/123456789012/github.fifo"
+	s, err := newSQSSink("/webhook/github", config.SQSConfig{
+		Queue:          queueURL,
+		MessageGroupID: `{{.Payload.repository.full_name}}`,
+		Attributes:     map[string]string{"event": `{{index .Headers "X-GitHub-Event"}}`},
+	}, queueURL, sender, discardLogger())
+	require.NoError(t, err)
+
+	body := []byte(`{"repository":{"full_name":"octo/hello"}}`)
+	require.NoError(t, s.Send(context.Background(), &Message{Body: body, Headers: map[string]string{"X-GitHub-Event": "push"}}))
+
+	require.Len(t, sender.inputs, 1)
+	input := sender.inputs[0]
+	assert.Equal(t, queueURL, aws.ToString(input.QueueUrl))
+	assert.Equal(t, string(body), aws.ToString(input.MessageBody))
+	assert.Equal(t, "octo/hello", aws.ToString(input.MessageGroupId))
+	require.Contains(t, input.MessageAttributes, "event")
+	assert.Equal(t, "String", aws.ToString(input.MessageAttributes["event"].DataType))
+	assert.Equal(t, "push", aws.ToString(input.MessageAttributes["event"].StringValue))
+
+	sender.err = errors.New("queue does not exist")
+	assert.ErrorContains(t, s.Send(context.Background(), &Message{Body: body}), "queue does not exist")
+}
+
+func TestSQSQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   config.SQSConfig
+		queueURL string
+		region   string
+	}{
+		{"url", config.SQSConfig{Queue: "https://sqs.eu-west-1.amazonaws.com/123456789012/webhooks"}, "https://sqs.eu-west-1.amazonaws.com/123456789012/webhooks", "eu-west-1"},
+		{"custom url", config.SQSConfig{Queue: "http://localstack:4566/000000000000/webhooks"}, "http://localstack:4566/000000000000/webhooks", ""},
+		{"arn", config.SQSConfig{Queue: "arn:aws:sqs:us-west-2:123456789012:webhooks"}, "https://sqs.us-west-2.amazonaws.com/123456789012/webhooks", "us-west-2"},
+		{"china arn", config.SQSConfig{Queue: "arn:aws-cn:sqs:cn-north-1:123456789012:webhooks"}, "https://sqs.cn-north-1.amazonaws.com.cn/123456789012/webhooks", "cn-north-1"},
+		{"arn with endpoint", config.SQSConfig{Queue: "arn:aws:sqs:us-east-1:000000000000:webhooks", Endpoint: "http://localstack:4566/"}, "http://localstack:4566/000000000000/webhooks", "us-east-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queueURL, region, err := sqsQueue(tt.config)
+			require.NoError(t, err)
+			assert.Equal(t, tt.queueURL, queueURL)
+			assert.Equal(t, tt.region, region)
+		})
+	}
+}
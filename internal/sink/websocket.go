@@ -0,0 +1,238 @@
+package sink
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/redact"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// websocketSendBuffer is the number of messages buffered per client before it is dropped
+	websocketSendBuffer = 64
+
+	// websocketWriteTimeout is the maximum time allowed to write a message to a client
+	websocketWriteTimeout = 10 * time.Second
+)
+
+// WebSocketHub broadcasts webhooks to the websocket clients subscribed to its path
+type WebSocketHub struct {
+	config   config.WebSocketConfig
+	log      *logrus.Logger
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[*websocketClient]struct{}
+}
+
+// websocketClient is a single subscriber connection
+type websocketClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// websocketMessage is the envelope sent to subscribers for every webhook
+type websocketMessage struct {
+	Timestamp string            `json:"timestamp"`
+	Headers   map[string]string `json:"headers"`
+	Payload   json.RawMessage   `json:"payload"`
+}
+
+// NewWebSocketHub creates a new websocket broadcast hub
+func NewWebSocketHub(cfg config.WebSocketConfig, log *logrus.Logger) *WebSocketHub {
+	hub := &WebSocketHub{
+		config:  cfg,
+		log:     log,
+		clients: make(map[*websocketClient]struct{}),
+	}
+	hub.upgrader = websocket.Upgrader{CheckOrigin: hub.checkOrigin}
+	return hub
+}
+
+// Path returns the path subscribers connect to
+func (h *WebSocketHub) Path() string {
+	return h.config.Path
+}
+
+// Clients returns the number of connected subscribers
+func (h *WebSocketHub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Send broadcasts a sanitized copy of the webhook to all connected subscribers: its
+// payload masked, or redacted when configured so. Subscribers that cannot keep up are
+// disconnected rather than slowing down delivery.
+func (h *WebSocketHub) Send(_ context.Context, msg *Message) error {
+	body := redact.JSON(msg.Body, h.config.RedactFields)
+	if h.config.Payload != config.WebSocketPayloadRedacted {
+		body = redact.Values(msg.Body)
+	}
+	payload, err := jsonPayload(body)
+	if err != nil {
+		return err
+	}
+
+	message, err := json.Marshal(websocketMessage{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Headers:   redact.Headers(msg.Headers, h.config.RedactHeaders),
+		Payload:   payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		select {
+		case client.send <- message:
+		default:
+			h.log.WithFields(logrus.Fields{
+				"path":        h.config.Path,
+				"remote_addr": client.conn.RemoteAddr().String(),
+			}).Warn("Dropping slow websocket subscriber")
+			h.removeLocked(client)
+		}
+	}
+
+	return nil
+}
+
+// ServeHTTP upgrades the request to a websocket connection and subscribes it to the hub,
+// once authenticated with the subscription token
+func (h *WebSocketHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(r) {
+		h.log.WithFields(logrus.Fields{
+			"path":        h.config.Path,
+			"remote_addr": r.RemoteAddr,
+		}).Warn("Rejected websocket subscriber without a valid token")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		h.log.WithFields(logrus.Fields{
+			"error": err,
+			"path":  h.config.Path,
+		}).Warn("Failed to upgrade websocket connection")
+		return
+	}
+
+	client := &websocketClient{
+		conn: conn,
+		send: make(chan []byte, websocketSendBuffer),
+	}
+
+	h.mu.Lock()
+	h.clients[client] = struct{}{}
+	h.mu.Unlock()
+
+	h.log.WithFields(logrus.Fields{
+		"path":        h.config.Path,
+		"remote_addr": conn.RemoteAddr().String(),
+	}).Info("Websocket subscriber connected")
+
+	go h.writeLoop(client)
+	h.readLoop(client)
+}
+
+// readLoop discards incoming messages and unsubscribes the client once the connection is closed
+func (h *WebSocketHub) readLoop(client *websocketClient) {
+	defer func() {
+		h.mu.Lock()
+		h.removeLocked(client)
+		h.mu.Unlock()
+	}()
+
+	for {
+		if _, _, err := client.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writeLoop writes queued messages to the client until its send channel is closed
+func (h *WebSocketHub) writeLoop(client *websocketClient) {
+	defer client.conn.Close()
+
+	for message := range client.send {
+		if err := client.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+			return
+		}
+		if err := client.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			return
+		}
+	}
+
+	// Send a close frame once the hub has dropped the client
+	_ = client.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(websocketWriteTimeout),
+	)
+}
+
+// removeLocked unsubscribes a client. The caller must hold h.mu.
+func (h *WebSocketHub) removeLocked(client *websocketClient) {
+	if _, exists := h.clients[client]; !exists {
+		return
+	}
+	delete(h.clients, client)
+	close(client.send)
+}
+
+// authenticated reports whether the request carries the subscription token, as a bearer
+// token or, for browsers which cannot set headers on websocket requests, in the token
+// query parameter
+func (h *WebSocketHub) authenticated(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if scheme, credentials, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		token = credentials
+	}
+	return h.config.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.config.Token)) == 1
+}
+
+// checkOrigin validates the Origin header against the configured allowed origins.
+// Without allowed origins only same-host requests are accepted. Requests without an
+// Origin header come from clients other than browsers, authenticated by their token.
+func (h *WebSocketHub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(h.config.AllowedOrigins) == 0 {
+		return sameHostOrigin(origin, r.Host)
+	}
+
+	for _, allowed := range h.config.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameHostOrigin reports whether the origin points at the given host
+func sameHostOrigin(origin, host string) bool {
+	for _, scheme := range []string{"http://", "https://"} {
+		if origin == scheme+host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/redact"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebSocketHubBroadcast(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	hub := NewWebSocketHub(config.WebSocketConfig{
+		Path:          "/live",
+		Token:         "subscriber-token",
+		Payload:       config.WebSocketPayloadRedacted,
+		RedactFields:  []string{"token"},
+		RedactHeaders: []string{"X-Secret"},
+	}, log)
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	// Connect a subscriber
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer subscriber-token"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	defer resp.Body.Close()
+
+	// Wait for the subscriber to be registered
+	assert.Eventually(t, func() bool { return hub.Clients() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Broadcast a webhook
+	err = hub.Send(context.Background(), &Message{Body: []byte(`{"event":"push","token":"secret"}`), Headers: map[string]string{
+		"Content-Type":  "application/json",
+		"Authorization": "Bearer secret",
+		"X-Secret":      "secret",
+	}})
+	require.NoError(t, err)
+
+	// Read the broadcast message
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var message websocketMessage
+	require.NoError(t, json.Unmarshal(data, &message))
+	assert.Equal(t, "application/json", message.Headers["Content-Type"])
+	assert.Equal(t, redact.Mask, message.Headers["Authorization"])
+	assert.Equal(t, redact.Mask, message.Headers["X-Secret"])
+	assert.JSONEq(t, `{"event":"push","token":"[REDACTED]"}`, string(message.Payload))
+
+	// Non-JSON payloads are sent as strings
+	require.NoError(t, hub.Send(context.Background(), &Message{Body: []byte("plain text")}))
+	_, data, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &message))
+	assert.Equal(t, `"plain text"`, string(message.Payload))
+
+	// Closing the connection unsubscribes the client
+	conn.Close()
+	assert.Eventually(t, func() bool { return hub.Clients() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocketHubMasksPayloadsByDefault(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	hub := NewWebSocketHub(config.WebSocketConfig{Path: "/live", Token: "subscriber-token"}, log)
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	// Browsers authenticate with the token query parameter
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?token=subscriber-token", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	defer resp.Body.Close()
+	assert.Eventually(t, func() bool { return hub.Clients() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Only the structure of the payload is broadcast
+	require.NoError(t, hub.Send(context.Background(), &Message{Body: []byte(`{"event":"push","sender":{"email":"dev@example.com"},"commits":[1,null]}`)}))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var message websocketMessage
+	require.NoError(t, json.Unmarshal(data, &message))
+	assert.JSONEq(t, `{"event":"[REDACTED]","sender":{"email":"[REDACTED]"},"commits":["[REDACTED]",null]}`, string(message.Payload))
+
+	// Non-JSON payloads are masked whole
+	require.NoError(t, hub.Send(context.Background(), &Message{Body: []byte("plain text")}))
+	_, data, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &message))
+	assert.Equal(t, `"[REDACTED]"`, string(message.Payload))
+}
+
+func TestWebSocketHubRequiresToken(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	hub := NewWebSocketHub(config.WebSocketConfig{Path: "/live", Token: "subscriber-token"}, log)
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	for name, header := range map[string]http.Header{
+		"missing":      nil,
+		"invalid":      {"Authorization": {"Bearer other-token"}},
+		"other scheme": {"Authorization": {"Basic subscriber-token"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+			require.Error(t, err)
+			require.NotNil(t, resp)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+	}
+	assert.Equal(t, 0, hub.Clients())
+}
+
+func TestWebSocketHubSendWithoutClients(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	hub := NewWebSocketHub(config.WebSocketConfig{Path: "/live"}, log)
+
+	// Broadcasting without subscribers is not an error
+	assert.NoError(t, hub.Send(context.Background(), &Message{Body: []byte(`{"event":"push"}`)}))
+}
+
+func TestWebSocketHubCheckOrigin(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	tests := []struct {
+		name           string
+		allowedOrigins []string
+		origin         string
+		expected       bool
+	}{
+		{name: "No origin", origin: "", expected: true},
+		{name: "Same host", origin: "http://proxy.example.com", expected: true},
+		{name: "Other host", origin: "http://evil.example.com", expected: false},
+		{name: "Allowed origin", allowedOrigins: []string{"http://dev.example.com"}, origin: "http://dev.example.com", expected: true},
+		{name: "Not allowed origin", allowedOrigins: []string{"http://dev.example.com"}, origin: "http://evil.example.com", expected: false},
+		{name: "Wildcard", allowedOrigins: []string{"*"}, origin: "http://evil.example.com", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewWebSocketHub(config.WebSocketConfig{Path: "/live", AllowedOrigins: tt.allowedOrigins}, log)
+
+			req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/live", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			assert.Equal(t, tt.expected, hub.checkOrigin(req))
+		})
+	}
+}
@@ -0,0 +1,276 @@
+// Package stats rolls up the deliveries of each destination per UTC day and persists the
+// rollups in a directory, one file per day, so that operators get trends across restarts
+// without external monitoring
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dateLayout names the day files and the days of the rollups
+const dateLayout = "2006-01-02"
+
+// fileExtension is the extension of day files
+const fileExtension = ".json"
+
+// Rollup holds the deliveries to a destination during a day
+type Rollup struct {
+	// Attempts counts the attempts, Successes and Failures their results
+	Attempts  int64 `json:"attempts"`
+	Successes int64 `json:"successes"`
+	Failures  int64 `json:"failures"`
+
+	// DeadLetters counts the deliveries that failed for good
+	DeadLetters int64 `json:"dead_letters"`
+
+	// Latencies of the attempts that sent a request, in milliseconds
+	LatencyCount   int64   `json:"latency_count"`
+	LatencyTotalMs float64 `json:"latency_total_ms"`
+	LatencyMaxMs   float64 `json:"latency_max_ms"`
+}
+
+// AvgLatencyMs returns the average latency of the attempts that sent a request
+func (r Rollup) AvgLatencyMs() float64 {
+	if r.LatencyCount == 0 {
+		return 0
+	}
+	return r.LatencyTotalMs / float64(r.LatencyCount)
+}
+
+// MarshalJSON adds the average latency to the counters
+func (r Rollup) MarshalJSON() ([]byte, error) {
+	type counters Rollup
+	return json.Marshal(struct {
+		counters
+		AvgLatencyMs float64 `json:"avg_latency_ms"`
+	}{counters(r), r.AvgLatencyMs()})
+}
+
+// Day holds the rollups of a day, by destination
+type Day struct {
+	Date         string            `json:"date"`
+	Destinations map[string]Rollup `json:"destinations"`
+}
+
+// Store keeps the rollups of the retained days in memory, and one file per day in a directory
+type Store struct {
+	dir           string
+	retentionDays int
+
+	mu    sync.Mutex
+	days  map[string]map[string]*Rollup // date -> destination -> rollup
+	dirty map[string]bool
+}
+
+// Open opens the store in the given directory, creating it if needed, and loads the days
+// within the retention. Unreadable day files are skipped and reported in the returned
+// error, along with a usable store.
+func Open(dir string, retentionDays int, now time.Time) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create stats directory: %w", err)
+	}
+	s := &Store{
+		dir:           dir,
+		retentionDays: retentionDays,
+		days:          make(map[string]map[string]*Rollup),
+		dirty:         make(map[string]bool),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats directory: %w", err)
+	}
+
+	oldest := s.oldest(now)
+	var errs []error
+	for _, entry := range entries {
+		date, ok := strings.CutSuffix(entry.Name(), fileExtension)
+		if entry.IsDir() || !ok || date < oldest {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var rollups map[string]*Rollup
+		if err := json.Unmarshal(data, &rollups); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		}
+		s.days[date] = rollups
+	}
+
+	return s, errors.Join(errs...)
+}
+
+// RecordAttempt counts an attempt to deliver to a destination. The latency of attempts that
+// sent no request, such as cached ones, is zero and left out of the averages.
+func (s *Store) RecordAttempt(at time.Time, destination string, success bool, latency time.Duration) {
+	s.update(at, destination, func(r *Rollup) {
+		r.Attempts++
+		if success {
+			r.Successes++
+		} else {
+			r.Failures++
+		}
+		if latency > 0 {
+			ms := float64(latency) / float64(time.Millisecond)
+			r.LatencyCount++
+			r.LatencyTotalMs += ms
+			r.LatencyMaxMs = max(r.LatencyMaxMs, ms)
+		}
+	})
+}
+
+// RecordDeadLetter counts a delivery to a destination that failed for good
+func (s *Store) RecordDeadLetter(at time.Time, destination string) {
+	s.update(at, destination, func(r *Rollup) {
+		r.DeadLetters++
+	})
+}
+
+// update applies a change to the rollup of a destination for the day of at
+func (s *Store) update(at time.Time, destination string, change func(r *Rollup)) {
+	date := at.UTC().Format(dateLayout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rollups, ok := s.days[date]
+	if !ok {
+		rollups = make(map[string]*Rollup)
+		s.days[date] = rollups
+	}
+	rollup, ok := rollups[destination]
+	if !ok {
+		rollup = &Rollup{}
+		rollups[destination] = rollup
+	}
+	change(rollup)
+	s.dirty[date] = true
+}
+
+// Days returns the rollups of the last days, today included, oldest first. Days without
+// deliveries are left out.
+func (s *Store) Days(days int, now time.Time) []Day {
+	oldest := now.UTC().AddDate(0, 0, 1-days).Format(dateLayout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Day, 0, len(s.days))
+	for date, rollups := range s.days {
+		if date < oldest {
+			continue
+		}
+		day := Day{Date: date, Destinations: make(map[string]Rollup, len(rollups))}
+		for destination, rollup := range rollups {
+			day.Destinations[destination] = *rollup
+		}
+		result = append(result, day)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Date < result[j].Date })
+	return result
+}
+
+// Flush writes the days changed since the last flush, then removes the days beyond the
+// retention, in memory and on disk
+func (s *Store) Flush(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for date := range s.dirty {
+		if err := s.write(date); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(s.dirty, date)
+	}
+
+	if err := s.prune(now); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// write saves the rollups of a day, through a temporary file so that a crash never
+// leaves a partial day
+func (s *Store) write(date string) error {
+	data, err := json.Marshal(s.days[date])
+	if err != nil {
+		return fmt.Errorf("failed to encode stats of %s: %w", date, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, date+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write stats of %s: %w", date, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write stats of %s: %w", date, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write stats of %s: %w", date, err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, date+fileExtension)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write stats of %s: %w", date, err)
+	}
+	return nil
+}
+
+// prune removes the days older than the retention
+func (s *Store) prune(now time.Time) error {
+	oldest := s.oldest(now)
+	for date := range s.days {
+		if date < oldest {
+			delete(s.days, date)
+			delete(s.dirty, date)
+		}
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read stats directory: %w", err)
+	}
+	var errs []error
+	for _, entry := range entries {
+		date, ok := strings.CutSuffix(entry.Name(), fileExtension)
+		if entry.IsDir() || !ok || date >= oldest {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove stats of %s: %w", date, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// oldest returns the date of the oldest retained day
+func (s *Store) oldest(now time.Time) string {
+	return now.UTC().AddDate(0, 0, 1-s.retentionDays).Format(dateLayout)
+}
+
+// RetentionDays returns the number of days kept
+func (s *Store) RetentionDays() int {
+	return s.retentionDays
+}
@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRollups(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	store, err := Open(t.TempDir(), 30, now)
+	require.NoError(t, err)
+
+	store.RecordAttempt(now, "https://a.example.com", true, 100*time.Millisecond)
+	store.RecordAttempt(now, "https://a.example.com", false, 300*time.Millisecond)
+	store.RecordAttempt(now, "https://a.example.com", true, 0)
+	store.RecordDeadLetter(now, "https://a.example.com")
+	store.RecordAttempt(now.AddDate(0, 0, -1), "https://b.example.com", true, 50*time.Millisecond)
+
+	days := store.Days(30, now)
+	require.Len(t, days, 2)
+	assert.Equal(t, "2026-10-14", days[0].Date)
+	assert.Equal(t, Rollup{Attempts: 1, Successes: 1, LatencyCount: 1, LatencyTotalMs: 50, LatencyMaxMs: 50}, days[0].Destinations["https://b.example.com"])
+
+	assert.Equal(t, "2026-10-15", days[1].Date)
+	rollup := days[1].Destinations["https://a.example.com"]
+	assert.Equal(t, Rollup{Attempts: 3, Successes: 2, Failures: 1, DeadLetters: 1, LatencyCount: 2, LatencyTotalMs: 400, LatencyMaxMs: 300}, rollup)
+	assert.Equal(t, 200.0, rollup.AvgLatencyMs())
+
+	// Only the requested days are returned
+	assert.Len(t, store.Days(1, now), 1)
+}
+
+func TestStoreRollupJSON(t *testing.T) {
+	data, err := json.Marshal(Rollup{Attempts: 2, Successes: 2, LatencyCount: 2, LatencyTotalMs: 30, LatencyMaxMs: 20})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"attempts":2,"successes":2,"failures":0,"dead_letters":0,"latency_count":2,"latency_total_ms":30,"latency_max_ms":20,"avg_latency_ms":15}`, string(data))
+}
+
+func TestStoreFlushAndReopen(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	store, err := Open(dir, 30, now)
+	require.NoError(t, err)
+
+	store.RecordAttempt(now, "https://a.example.com", true, 100*time.Millisecond)
+	require.NoError(t, store.Flush(now))
+	assert.FileExists(t, filepath.Join(dir, "2026-10-15.json"))
+
+	// A reopened store continues the day's rollups
+	reopened, err := Open(dir, 30, now)
+	require.NoError(t, err)
+	reopened.RecordAttempt(now, "https://a.example.com", false, 0)
+	assert.Equal(t, Rollup{Attempts: 2, Successes: 1, Failures: 1, LatencyCount: 1, LatencyTotalMs: 100, LatencyMaxMs: 100},
+		reopened.Days(1, now)[0].Destinations["https://a.example.com"])
+}
+
+func TestStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2026-09-01.json"), []byte(`{"https://a.example.com":{"attempts":1}}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.json"), []byte(`{}`), 0o600))
+
+	store, err := Open(dir, 7, now)
+	require.NoError(t, err)
+	assert.Empty(t, store.Days(7, now))
+
+	store.RecordAttempt(now.AddDate(0, 0, -6), "https://a.example.com", true, 0)
+	store.RecordAttempt(now, "https://a.example.com", true, 0)
+	require.NoError(t, store.Flush(now))
+	assert.NoFileExists(t, filepath.Join(dir, "2026-09-01.json"))
+	assert.FileExists(t, filepath.Join(dir, "2026-10-09.json"))
+	assert.FileExists(t, filepath.Join(dir, "notes.json"))
+
+	// A day leaves the retention as time passes
+	require.NoError(t, store.Flush(now.AddDate(0, 0, 1)))
+	assert.NoFileExists(t, filepath.Join(dir, "2026-10-09.json"))
+	assert.Len(t, store.Days(7, now.AddDate(0, 0, 1)), 1)
+}
+
+func TestOpenUnreadableDay(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2026-10-14.json"), []byte(`not json`), 0o600))
+
+	store, err := Open(dir, 30, now)
+	assert.Error(t, err)
+	require.NotNil(t, store)
+	assert.Empty(t, store.Days(30, now))
+}
@@ -0,0 +1,121 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// ProtocolGRPC exports spans to the collector over gRPC, usually on port 4317
+	ProtocolGRPC = "grpc"
+
+	// ProtocolHTTP exports spans to the collector as protobuf over HTTP, usually on port 4318
+	ProtocolHTTP = "http"
+)
+
+// TLSConfig represents the TLS settings of the connection to the collector
+type TLSConfig struct {
+	// CAFile verifies the collector's certificate instead of the system roots
+	CAFile string
+
+	// CertFile and KeyFile authenticate the proxy to the collector
+	CertFile string
+	KeyFile  string
+
+	// ServerName overrides the name verified in the collector's certificate
+	ServerName string
+
+	// InsecureSkipVerify skips the verification of the collector's certificate
+	InsecureSkipVerify bool
+}
+
+// newOTLPExporter creates the exporter sending spans to an OpenTelemetry collector.
+// The endpoint is a host:port, or a URL whose http scheme disables TLS and whose path,
+// over HTTP, replaces /v1/traces.
+func newOTLPExporter(ctx context.Context, config Config) (*otlptrace.Exporter, error) {
+	var tlsConfig *tls.Config
+	if !config.Insecure {
+		var err error
+		if tlsConfig, err = config.TLS.build(); err != nil {
+			return nil, err
+		}
+	}
+	isURL := strings.Contains(config.Endpoint, "://")
+
+	switch config.Protocol {
+	case ProtocolGRPC, "":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(config.Headers)}
+		if isURL {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(config.Endpoint))
+		} else {
+			opts = append(opts, otlptracegrpc.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		} else if tlsConfig != nil {
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+
+	case ProtocolHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithHeaders(config.Headers)}
+		if isURL {
+			opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		} else {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		} else if tlsConfig != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+		}
+		return otlptracehttp.New(ctx, opts...)
+
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol: %s", config.Protocol)
+	}
+}
+
+// build returns the TLS configuration of the connection to the collector, nil to use the defaults
+func (c TLSConfig) build() (*tls.Config, error) {
+	if c == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // explicitly requested in the configuration
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the collector CA file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the collector CA file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,185 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// exportSpan creates a tracer, records one span and flushes it to the collector
+func exportSpan(t *testing.T, config Config) {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	config.Enabled = true
+	config.ExporterType = "otlp"
+	config.ServiceName = "webhook-proxy"
+	tracer, err := NewTracer(context.Background(), config, log)
+	require.NoError(t, err)
+
+	_, span := tracer.StartSpan(context.Background(), "webhook.forward")
+	span.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+}
+
+// spanNames returns the names of the spans of an export request
+func spanNames(request *coltracepb.ExportTraceServiceRequest) []string {
+	var names []string
+	for _, resourceSpans := range request.GetResourceSpans() {
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			for _, span := range scopeSpans.GetSpans() {
+				names = append(names, span.GetName())
+			}
+		}
+	}
+	return names
+}
+
+// collectorHandler decodes the OTLP/HTTP export requests sent to it
+func collectorHandler(t *testing.T, requests chan<- *http.Request, exports chan<- *coltracepb.ExportTraceServiceRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var export coltracepb.ExportTraceServiceRequest
+		assert.NoError(t, proto.Unmarshal(body, &export))
+
+		requests <- r
+		exports <- &export
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	exports := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	collector := httptest.NewServer(collectorHandler(t, requests, exports))
+	defer collector.Close()
+
+	// The http scheme of the endpoint disables TLS
+	exportSpan(t, Config{
+		Endpoint: collector.URL,
+		Protocol: ProtocolHTTP,
+		Headers:  map[string]string{"Api-Key": "collector-key"},
+	})
+
+	request := <-requests
+	assert.Equal(t, "/v1/traces", request.URL.Path)
+	assert.Equal(t, "collector-key", request.Header.Get("Api-Key"))
+	assert.Equal(t, []string{"webhook.forward"}, spanNames(<-exports))
+}
+
+func TestOTLPHTTPExporterTLS(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	exports := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	collector := httptest.NewTLSServer(collectorHandler(t, requests, exports))
+	defer collector.Close()
+
+	// The collector's certificate is verified with the CA file
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: collector.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, certificate, 0o600))
+
+	exportSpan(t, Config{
+		Endpoint: collector.URL + "/otlp/v1/traces",
+		Protocol: ProtocolHTTP,
+		TLS:      TLSConfig{CAFile: caFile},
+	})
+
+	request := <-requests
+	assert.Equal(t, "/otlp/v1/traces", request.URL.Path)
+	assert.Equal(t, []string{"webhook.forward"}, spanNames(<-exports))
+}
+
+// traceService is a collector receiving spans over gRPC
+type traceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	exports  chan *coltracepb.ExportTraceServiceRequest
+	metadata chan metadata.MD
+}
+
+func (s *traceService) Export(ctx context.Context, request *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.metadata <- md
+	s.exports <- request
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func TestOTLPGRPCExporter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	service := &traceService{
+		exports:  make(chan *coltracepb.ExportTraceServiceRequest, 1),
+		metadata: make(chan metadata.MD, 1),
+	}
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, service)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	exportSpan(t, Config{
+		Endpoint: listener.Addr().String(),
+		Protocol: ProtocolGRPC,
+		Insecure: true,
+		Headers:  map[string]string{"api-key": "collector-key"},
+	})
+
+	assert.Equal(t, []string{"collector-key"}, (<-service.metadata).Get("api-key"))
+	assert.Equal(t, []string{"webhook.forward"}, spanNames(<-service.exports))
+}
+
+func TestNewTracerExporterErrors(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{
+			name:   "unknown exporter",
+			config: Config{Enabled: true, ExporterType: "jaeger"},
+			err:    "unknown exporter type: jaeger",
+		},
+		{
+			name:   "unknown protocol",
+			config: Config{Enabled: true, ExporterType: "otlp", Endpoint: "localhost:4317", Protocol: "thrift"},
+			err:    "unknown OTLP protocol: thrift",
+		},
+		{
+			name:   "missing CA file",
+			config: Config{Enabled: true, ExporterType: "otlp", Endpoint: "localhost:4317", TLS: TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+			err:    "failed to read the collector CA file",
+		},
+		{
+			name:   "missing client certificate",
+			config: Config{Enabled: true, ExporterType: "otlp", Endpoint: "localhost:4317", TLS: TLSConfig{CertFile: "missing.pem", KeyFile: "missing.key"}},
+			err:    "failed to load the client certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTracer(context.Background(), tt.config, log)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -21,16 +21,23 @@ import (
 type Config struct {
 	ServiceName    string
 	ServiceVersion string
-	ExporterType   string // stdout, otlp, etc.
+	ExporterType   string // stdout or otlp
 	Endpoint       string // for OTLP exporter
 	Enabled        bool
+
+	// Protocol, Headers, Insecure and TLS set up the connection of the OTLP exporter
+	Protocol string // grpc (default) or http
+	Headers  map[string]string
+	Insecure bool
+	TLS      TLSConfig
 }
 
 // Tracer is a wrapper around the OpenTelemetry tracer
 type Tracer struct {
-	tracer trace.Tracer
-	log    *logrus.Logger
-	config Config
+	tracer   trace.Tracer
+	provider *sdktrace.TracerProvider
+	log      *logrus.Logger
+	config   Config
 }
 
 // NewTracer creates a new tracer with the given configuration
@@ -48,15 +55,14 @@ func NewTracer(ctx context.Context, config Config, log *logrus.Logger) (*Tracer,
 	var err error
 
 	switch config.ExporterType {
-	case "stdout":
+	case "stdout", "":
 		exporter, err = stdouttrace.New(
 			stdouttrace.WithPrettyPrint(),
 		)
+	case "otlp":
+		exporter, err = newOTLPExporter(ctx, config)
 	default:
-		// Default to stdout
-		exporter, err = stdouttrace.New(
-			stdouttrace.WithPrettyPrint(),
-		)
+		err = fmt.Errorf("unknown exporter type: %s", config.ExporterType)
 	}
 
 	if err != nil {
@@ -88,22 +94,17 @@ func NewTracer(ctx context.Context, config Config, log *logrus.Logger) (*Tracer,
 	tracer := tp.Tracer(config.ServiceName)
 
 	return &Tracer{
-		tracer: tracer,
-		log:    log,
-		config: config,
+		tracer:   tracer,
+		provider: tp,
+		log:      log,
+		config:   config,
 	}, nil
 }
 
-// Shutdown shuts down the tracer provider
+// Shutdown flushes the spans of the tracer and shuts down its provider. The provider is the
+// tracer's own, so that replacing a tracer does not shut down the global provider of its successor.
 func (t *Tracer) Shutdown(ctx context.Context) error {
-	if !t.config.Enabled {
-		return nil
-	}
-
-	// Get tracer provider
-	tp, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
-	if !ok {
-		t.log.Warn("Failed to get tracer provider for shutdown")
+	if !t.config.Enabled || t.provider == nil {
 		return nil
 	}
 
@@ -111,13 +112,18 @@ func (t *Tracer) Shutdown(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if err := tp.Shutdown(ctx); err != nil {
+	if err := t.provider.Shutdown(ctx); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// Enabled reports whether the tracer exports spans
+func (t *Tracer) Enabled() bool {
+	return t.config.Enabled
+}
+
 // StartSpan starts a new span with the given name
 func (t *Tracer) StartSpan(ctx context.Context, name string) (context.Context, trace.Span) {
 	return t.tracer.Start(ctx, name)
@@ -3,6 +3,7 @@ package telemetry
 import (
 	"context"
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -74,6 +75,25 @@ func TestTracerShutdown(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestTracerShutdownKeepsSuccessor(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	config := Config{ServiceName: "test-service", ExporterType: "stdout", Enabled: true}
+	previous, err := NewTracer(context.Background(), config, log)
+	assert.NoError(t, err)
+	config.ServiceVersion = "2.0.0"
+	current, err := NewTracer(context.Background(), config, log)
+	assert.NoError(t, err)
+
+	// Shutting down the replaced tracer leaves the current one recording
+	assert.NoError(t, previous.Shutdown(context.Background()))
+	_, span := current.StartSpan(context.Background(), "test-span")
+	defer span.End()
+	assert.True(t, span.IsRecording())
+	assert.NoError(t, current.Shutdown(context.Background()))
+}
+
 func TestStartSpan(t *testing.T) {
 	// Create a logger
 	log := logrus.New()
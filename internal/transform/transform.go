@@ -0,0 +1,96 @@
+// Package transform renders the body sent to a destination from a text/template, to
+// reshape webhooks into the format the destination expects, such as GitHub events into
+// Slack messages
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Data is the data available to transform templates
+type Data struct {
+	Endpoint  string
+	RequestID string
+	Headers   map[string]string
+
+	// Fields are the routing fields extracted by the provider preset, such as repo for GitHub
+	Fields map[string]string
+
+	// Payload is the JSON, form or XML payload of the webhook, nil if it cannot be parsed
+	Payload interface{}
+}
+
+// funcs are the functions available to transform templates, on top of the text/template ones
+var funcs = template.FuncMap{
+	"json":    toJSON,
+	"default": defaultValue,
+	"join":    join,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+}
+
+// Template is a parsed transform template
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses a transform template. Missing payload keys render as empty values.
+func Parse(text string) (*Template, error) {
+	tmpl, err := template.New("transform").Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Render renders the template with the data of a webhook
+func (t *Template) Render(data Data) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render transform template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// toJSON encodes a value as JSON, to embed payload values in JSON bodies with their quotes
+// and escaping
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// defaultValue returns the value, or the fallback if the value is nil or an empty string
+func defaultValue(fallback, value interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+	if s, ok := value.(string); ok && s == "" {
+		return fallback
+	}
+	return value
+}
+
+// join joins the values of a payload array with a separator
+func join(sep string, values interface{}) string {
+	switch v := values.(type) {
+	case []string:
+		return strings.Join(v, sep)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, value := range v {
+			parts[i] = fmt.Sprint(value)
+		}
+		return strings.Join(parts, sep)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,73 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payload(t *testing.T, body string) interface{} {
+	var parsed interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &parsed))
+	return parsed
+}
+
+func TestRender(t *testing.T) {
+	tmpl, err := Parse(`{"text": {{json (printf "%s pushed to %s" .Payload.pusher.name .Fields.repo)}}, "channel": {{json (default "#general" .Headers.Channel)}}}`)
+	require.NoError(t, err)
+
+	body, err := tmpl.Render(Data{
+		Endpoint: "/github",
+		Headers:  map[string]string{"X-Github-Event": "push"},
+		Fields:   map[string]string{"repo": "octo/hello"},
+		Payload:  payload(t, `{"pusher":{"name":"mona \"the octocat\""}}`),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":"mona \"the octocat\" pushed to octo/hello","channel":"#general"}`, string(body))
+}
+
+func TestRenderFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		payload  string
+		expected string
+	}{
+		{name: "json", template: `{{json .Payload.labels}}`, payload: `{"labels":["bug","ui"]}`, expected: `["bug","ui"]`},
+		{name: "join", template: `{{join ", " .Payload.labels}}`, payload: `{"labels":["bug","ui"]}`, expected: `bug, ui`},
+		{name: "join missing", template: `{{join ", " .Payload.labels}}`, payload: `{}`, expected: ``},
+		{name: "default", template: `{{default "none" .Payload.action}}`, payload: `{"action":""}`, expected: `none`},
+		{name: "default set", template: `{{default "none" .Payload.action}}`, payload: `{"action":"opened"}`, expected: `opened`},
+		{name: "upper", template: `{{upper .Payload.action}}`, payload: `{"action":"opened"}`, expected: `OPENED`},
+		{name: "missing key", template: `{{json .Payload.missing}}`, payload: `{}`, expected: `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(tt.template)
+			require.NoError(t, err)
+
+			body, err := tmpl.Render(Data{Payload: payload(t, tt.payload)})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(body))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(`{{.Payload`)
+	assert.Error(t, err)
+
+	_, err = Parse(`{{unknown .Payload}}`)
+	assert.Error(t, err)
+}
+
+func TestRenderError(t *testing.T) {
+	tmpl, err := Parse(`{{.Payload.action.name}}`)
+	require.NoError(t, err)
+
+	_, err = tmpl.Render(Data{Payload: "not an object"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,287 @@
+// Package upgrade hands the listening sockets of the proxy over to a new process, so that a
+// new binary takes over without refusing a connection: the new process inherits the sockets
+// as extra file descriptors, tells the old one when it serves, and both accept connections
+// until the old one stops and drains.
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// envListeners lists the addresses of the inherited sockets, in the order of their file
+	// descriptors, from 3
+	envListeners = "WEBHOOK_PROXY_LISTENERS"
+
+	// envReady is the file descriptor the new process notifies the old one on
+	envReady = "WEBHOOK_PROXY_READY_FD"
+
+	// firstInheritedFD is the first extra file descriptor of a process
+	firstInheritedFD = 3
+)
+
+// ErrNotReady is returned when the new process exits, or fails to serve in time, during an
+// upgrade. The old process keeps serving.
+var ErrNotReady = errors.New("new process not ready")
+
+// fileListener is a listener whose socket can be handed to another process
+type fileListener interface {
+	net.Listener
+	syscall.Conn
+}
+
+// Upgrader opens the listening sockets of a process, inheriting them from the process it
+// upgrades, if any, and hands them over to the process upgrading it
+type Upgrader struct {
+	pidFile string
+	args    []string // the command starting the new process
+
+	mu        sync.Mutex
+	inherited map[string]net.Listener
+	listeners map[string]fileListener
+	addrs     []string // the addresses of the listeners, in the order they were opened
+	parent    *os.File // notified once the process serves, when started by an upgrade
+	upgrading bool
+}
+
+// New returns an upgrader for the process, with the sockets inherited from the process it
+// upgrades. PIDFile, when set, receives the PID of the process once it serves.
+func New(pidFile string) (*Upgrader, error) {
+	u := &Upgrader{
+		pidFile:   pidFile,
+		args:      os.Args,
+		inherited: make(map[string]net.Listener),
+		listeners: make(map[string]fileListener),
+	}
+
+	value, ok := os.LookupEnv(envReady)
+	if !ok {
+		return u, nil
+	}
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", envReady, value)
+	}
+	u.parent = os.NewFile(uintptr(fd), "upgrade-ready")
+
+	var addrs []string
+	if value := os.Getenv(envListeners); value != "" {
+		addrs = strings.Split(value, ",")
+	}
+	for i, addr := range addrs {
+		file := os.NewFile(uintptr(firstInheritedFD+i), addr)
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			u.closeInherited()
+			return nil, fmt.Errorf("failed to inherit the socket of %s: %w", addr, err)
+		}
+		u.inherited[addr] = l
+	}
+
+	// Processes started by the new process do not inherit the sockets
+	os.Unsetenv(envReady)
+	os.Unsetenv(envListeners)
+	return u, nil
+}
+
+// Listen returns the listening socket of the address: the one inherited for it, if any,
+// or a new one. Listening twice on an address returns the same socket.
+func (u *Upgrader) Listen(addr string) (net.Listener, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if l, ok := u.listeners[addr]; ok {
+		return l, nil
+	}
+
+	l, ok := u.inherited[addr]
+	if ok {
+		delete(u.inherited, addr)
+	} else {
+		var err error
+		if l, err = net.Listen("tcp", addr); err != nil {
+			return nil, err
+		}
+	}
+
+	fl, ok := l.(fileListener)
+	if !ok {
+		l.Close()
+		return nil, fmt.Errorf("socket of %s cannot be handed over", addr)
+	}
+	u.listeners[addr] = fl
+	u.addrs = append(u.addrs, addr)
+	return fl, nil
+}
+
+// HasParent reports whether the process was started by an upgrade
+func (u *Upgrader) HasParent() bool {
+	return u.parent != nil
+}
+
+// Ready records that the process serves: the inherited sockets it does not listen on are
+// closed, the PID file is written and the process it upgrades, if any, is notified
+func (u *Upgrader) Ready() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.closeInherited()
+
+	if u.pidFile != "" {
+		if err := writePIDFile(u.pidFile, os.Getpid()); err != nil {
+			return err
+		}
+	}
+
+	if u.parent == nil {
+		return nil
+	}
+	_, err := u.parent.Write([]byte{1})
+	u.parent.Close()
+	u.parent = nil
+	if err != nil {
+		return fmt.Errorf("failed to notify the upgraded process: %w", err)
+	}
+	return nil
+}
+
+// Upgrade starts the binary the process was started with, with the same arguments and the
+// listening sockets, and waits for it to serve. It returns nil once the new process serves,
+// when the caller must stop accepting connections and exit, and ErrNotReady when the new
+// process exits or fails to serve within the timeout, when it is killed.
+func (u *Upgrader) Upgrade(timeout time.Duration) error {
+	u.mu.Lock()
+	if u.upgrading {
+		u.mu.Unlock()
+		return errors.New("upgrade already in progress")
+	}
+	u.upgrading = true
+	addrs := append([]string(nil), u.addrs...)
+	files := make([]*os.File, 0, len(addrs))
+	var err error
+	for _, addr := range addrs {
+		var file *os.File
+		if file, err = socketFile(u.listeners[addr], addr); err != nil {
+			err = fmt.Errorf("failed to hand over the socket of %s: %w", addr, err)
+			break
+		}
+		files = append(files, file)
+	}
+	u.mu.Unlock()
+
+	if err == nil {
+		err = u.start(addrs, files, timeout)
+	}
+	for _, file := range files {
+		file.Close()
+	}
+
+	u.mu.Lock()
+	u.upgrading = false
+	u.mu.Unlock()
+	return err
+}
+
+// start starts the new process with the socket files and waits for its notification
+func (u *Upgrader) start(addrs []string, files []*os.File, timeout time.Duration) error {
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create the notification pipe: %w", err)
+	}
+	defer readyReader.Close()
+
+	cmd := exec.Command(u.args[0], u.args[1:]...) //nolint:gosec // the binary of the process itself
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(append([]*os.File(nil), files...), readyWriter)
+	cmd.Env = append(os.Environ(),
+		envListeners+"="+strings.Join(addrs, ","),
+		envReady+"="+strconv.Itoa(firstInheritedFD+len(files)),
+	)
+
+	err = cmd.Start()
+	readyWriter.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start the new process: %w", err)
+	}
+
+	// The notification is a byte; the pipe closes without one when the new process exits
+	notified := make(chan bool, 1)
+	go func() {
+		var b [1]byte
+		_, err := io.ReadFull(readyReader, b[:])
+		notified <- err == nil
+	}()
+
+	select {
+	case ok := <-notified:
+		if ok {
+			// The new process outlives this one
+			return cmd.Process.Release()
+		}
+	case <-time.After(timeout):
+	}
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	return ErrNotReady
+}
+
+// Close closes the listening sockets and the inherited ones
+func (u *Upgrader) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.closeInherited()
+	var errs []error
+	for _, l := range u.listeners {
+		if err := l.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeInherited closes the inherited sockets not listened on
+func (u *Upgrader) closeInherited() {
+	for addr, l := range u.inherited {
+		l.Close()
+		delete(u.inherited, addr)
+	}
+}
+
+// writePIDFile replaces the PID file atomically, so that it never holds a partial PID
+func writePIDFile(path string, pid int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write the PID file: %w", err)
+	}
+	_, err = fmt.Fprintf(tmp, "%d\n", pid)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644) //nolint:gosec // process managers read the PID file
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write the PID file: %w", err)
+	}
+	return nil
+}
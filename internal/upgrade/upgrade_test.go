@@ -0,0 +1,148 @@
+package upgrade
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// helperAddr is the environment variable of the address the helper process listens on
+const helperAddr = "UPGRADE_HELPER_ADDR"
+
+// TestHelperProcess is the new process of the upgrade tests: it takes the socket over,
+// answers one request and exits, or exits before serving with UPGRADE_HELPER_FAIL
+func TestHelperProcess(t *testing.T) {
+	addr := os.Getenv(helperAddr)
+	if addr == "" {
+		t.Skip("only run as the new process of an upgrade")
+	}
+	if os.Getenv("UPGRADE_HELPER_FAIL") != "" {
+		os.Exit(1)
+	}
+
+	u, err := New("")
+	if err != nil || !u.HasParent() {
+		os.Exit(2)
+	}
+	l, err := u.Listen(addr)
+	if err != nil {
+		os.Exit(3)
+	}
+	if err := u.Ready(); err != nil {
+		os.Exit(4)
+	}
+
+	served := make(chan struct{})
+	go func() {
+		_ = http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Connection", "close")
+			io.WriteString(w, strconv.Itoa(os.Getpid()))
+			close(served)
+		}))
+	}()
+	select {
+	case <-served:
+		time.Sleep(100 * time.Millisecond)
+	case <-time.After(10 * time.Second):
+	}
+	os.Exit(0)
+}
+
+// newTestUpgrader returns an upgrader starting the helper process
+func newTestUpgrader(t *testing.T) *Upgrader {
+	if Signal == nil {
+		t.Skip("upgrades are not supported on this platform")
+	}
+	u, err := New("")
+	require.NoError(t, err)
+	u.args = []string{os.Args[0], "-test.run=^TestHelperProcess$"}
+	t.Cleanup(func() { u.Close() })
+	return u
+}
+
+func TestUpgrade(t *testing.T) {
+	u := newTestUpgrader(t)
+	assert.False(t, u.HasParent())
+
+	l, err := u.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	again, err := u.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	assert.Same(t, l, again, "listening twice returns the same socket")
+	addr := l.Addr().String()
+
+	// The old process accepts connections during the upgrade
+	served := make(chan error, 1)
+	go func() {
+		served <- http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			io.WriteString(w, strconv.Itoa(os.Getpid()))
+		}))
+	}()
+
+	t.Setenv(helperAddr, "127.0.0.1:0")
+	require.NoError(t, u.Upgrade(10*time.Second))
+
+	// Once the old process stops accepting, the new one serves the socket
+	require.NoError(t, l.Close())
+	assert.ErrorIs(t, <-served, net.ErrClosed)
+	resp, err := http.Get("http://" + addr)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotEqual(t, strconv.Itoa(os.Getpid()), string(body))
+}
+
+func TestUpgradeNotReady(t *testing.T) {
+	u := newTestUpgrader(t)
+	l, err := u.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+
+	// The new process exits before serving
+	t.Setenv(helperAddr, "127.0.0.1:0")
+	t.Setenv("UPGRADE_HELPER_FAIL", "1")
+	assert.ErrorIs(t, u.Upgrade(10*time.Second), ErrNotReady)
+
+	// The new process does not serve in time
+	u.args = []string{"sleep", "10"}
+	start := time.Now()
+	assert.ErrorIs(t, u.Upgrade(100*time.Millisecond), ErrNotReady)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// The old process keeps serving
+	go func() {
+		_ = http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			io.WriteString(w, "old")
+		}))
+	}()
+	resp, err := http.Get("http://" + l.Addr().String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "old", string(body))
+}
+
+func TestReadyWritesPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook-proxy.pid")
+	require.NoError(t, os.WriteFile(path, []byte("1\n"), 0o600))
+
+	u, err := New(path)
+	require.NoError(t, err)
+	require.NoError(t, u.Ready())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(os.Getpid()), strings.TrimSpace(string(data)))
+
+	u.pidFile = filepath.Join(t.TempDir(), "missing", "webhook-proxy.pid")
+	assert.ErrorContains(t, u.Ready(), "failed to write the PID file")
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package upgrade
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Signal asks a process to upgrade
+var Signal os.Signal = syscall.SIGUSR2
+
+// socketFile duplicates the socket of a listener as a file to hand over. Unlike the file of
+// the listener, whose descriptor turns blocking once handed to a process, the duplicate
+// leaves the socket nonblocking, as the listener accepting connections meanwhile needs.
+func socketFile(l fileListener, name string) (*os.File, error) {
+	rc, err := l.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var fd int
+	var dupErr error
+	err = rc.Control(func(s uintptr) {
+		syscall.ForkLock.RLock()
+		defer syscall.ForkLock.RUnlock()
+		if fd, dupErr = syscall.Dup(int(s)); dupErr == nil {
+			syscall.CloseOnExec(fd)
+		}
+	})
+	if err == nil {
+		err = dupErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to duplicate the socket: %w", err)
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
@@ -0,0 +1,15 @@
+package upgrade
+
+import (
+	"errors"
+	"os"
+)
+
+// Signal asks a process to upgrade. Windows has neither the signal nor inheritable sockets,
+// so processes never upgrade there.
+var Signal os.Signal
+
+// socketFile is not supported on Windows
+func socketFile(fileListener, string) (*os.File, error) {
+	return nil, errors.New("upgrades are not supported on windows")
+}
@@ -0,0 +1,96 @@
+// Package webhook defines the delivery of a webhook accepted by an endpoint, the one value
+// passed from the endpoint to the quota, the queue, the provider presets and the proxy, so
+// that each of them reads the same body, headers and metadata
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/rawheaders"
+	"github.com/google/uuid"
+)
+
+// Delivery is a webhook accepted by an endpoint. The body and headers are those forwarded
+// to the destinations, once decompressed and converted to UTF-8. A delivery is shared by
+// the goroutines forwarding it, and must not be modified once it is forwarded.
+type Delivery struct {
+	// ID identifies the accepted webhook; each destination's delivery has an ID of its own
+	ID         string    `json:"id"`
+	ReceivedAt time.Time `json:"received_at"`
+
+	// Endpoint is the path of the endpoint that accepted the webhook
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers"`
+	Body     []byte            `json:"body"`
+
+	// RequestID is the ID of the request the webhook was received with
+	RequestID string `json:"request_id,omitempty"`
+
+	// Listener is the name of the listener the webhook was received on, for servers with
+	// tenant listeners
+	Listener string `json:"listener,omitempty"`
+
+	// Metadata are the routing fields extracted by the endpoint's provider preset, by name
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// RawHeaders are the headers as sent, in order and with the case of their names, for
+	// endpoints preserving them
+	RawHeaders []rawheaders.Header `json:"raw_headers,omitempty"`
+
+	parseOnce sync.Once
+	parsed    interface{}
+	parseErr  error
+}
+
+// New returns the delivery of a webhook received now by an endpoint
+func New(endpoint string, body []byte, headers map[string]string) *Delivery {
+	return &Delivery{
+		ID:         uuid.NewString(),
+		ReceivedAt: time.Now(),
+		Endpoint:   endpoint,
+		Headers:    headers,
+		Body:       body,
+	}
+}
+
+// Header returns the value of a header, matching its name case-insensitively, or an empty
+// string
+func (d *Delivery) Header(name string) string {
+	if value, ok := d.Headers[name]; ok {
+		return value
+	}
+	for k, v := range d.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// ContentType returns the Content-Type header of the webhook
+func (d *Delivery) ContentType() string {
+	return d.Header("Content-Type")
+}
+
+// JSON returns the body parsed as JSON. The body is parsed on first use only, and the
+// parsed value is shared by the callers: those changing it copy what they change first,
+// as redact.ParsedJSON does, and share the rest.
+func (d *Delivery) JSON() (interface{}, error) {
+	d.parseOnce.Do(func() {
+		d.parseErr = json.Unmarshal(d.Body, &d.parsed)
+	})
+	return d.parsed, d.parseErr
+}
+
+// JSONObject returns the body parsed as a JSON object, or nil if it is not one
+func (d *Delivery) JSONObject() map[string]interface{} {
+	parsed, err := d.JSON()
+	if err != nil {
+		return nil
+	}
+	object, _ := parsed.(map[string]interface{})
+	return object
+}
@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	delivery := New("/webhook", []byte(`{}`), map[string]string{"Content-Type": "application/json"})
+	assert.NotEmpty(t, delivery.ID)
+	assert.False(t, delivery.ReceivedAt.IsZero())
+	assert.Equal(t, "/webhook", delivery.Endpoint)
+	assert.NotEqual(t, delivery.ID, New("/webhook", nil, nil).ID)
+}
+
+func TestHeader(t *testing.T) {
+	delivery := New("/webhook", nil, map[string]string{"Content-Type": "application/json", "X-Github-Event": "push"})
+	assert.Equal(t, "application/json", delivery.ContentType())
+	assert.Equal(t, "push", delivery.Header("X-GitHub-Event"))
+	assert.Empty(t, delivery.Header("X-Missing"))
+}
+
+func TestJSON(t *testing.T) {
+	delivery := New("/webhook", []byte(`{"action":"opened","repository":{"full_name":"octo/hello"}}`), nil)
+
+	parsed, err := delivery.JSON()
+	require.NoError(t, err)
+	assert.Equal(t, "opened", parsed.(map[string]interface{})["action"])
+
+	// The body is parsed once, later calls share the parsed value
+	delivery.Body = []byte(`{}`)
+	object := delivery.JSONObject()
+	assert.Equal(t, "opened", object["action"])
+
+	_, err = New("/webhook", []byte(`not json`), nil).JSON()
+	assert.Error(t, err)
+	assert.Nil(t, New("/webhook", []byte(`not json`), nil).JSONObject())
+	assert.Nil(t, New("/webhook", []byte(`[1, 2]`), nil).JSONObject())
+}
+
+func TestDeliveryEncoding(t *testing.T) {
+	delivery := New("/webhook", []byte(`{"event":"push"}`), map[string]string{"X-Team": "payments"})
+	delivery.RequestID = "req-1"
+	delivery.Metadata = map[string]string{"event": "push"}
+
+	data, err := json.Marshal(delivery)
+	require.NoError(t, err)
+
+	var decoded Delivery
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, delivery.ID, decoded.ID)
+	assert.True(t, delivery.ReceivedAt.Equal(decoded.ReceivedAt))
+	assert.Equal(t, delivery.Body, decoded.Body)
+	assert.Equal(t, delivery.Headers, decoded.Headers)
+	assert.Equal(t, delivery.RequestID, decoded.RequestID)
+	assert.Equal(t, delivery.Metadata, decoded.Metadata)
+	assert.Equal(t, "push", decoded.JSONObject()["event"])
+}
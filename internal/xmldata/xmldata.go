@@ -0,0 +1,130 @@
+// Package xmldata parses XML webhooks, as sent by AWS SNS or some payment providers,
+// to select values with a subset of XPath and to convert them to JSON
+package xmldata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// Node is an XML element
+type Node struct {
+	Name     string
+	Attrs    []xml.Attr
+	Children []*Node
+	Text     string
+}
+
+// IsXML reports whether a Content-Type is an XML content type
+func IsXML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// Parse parses an XML document and returns its root element
+func Parse(body []byte) (*Node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+
+	var root *Node
+	var stack []*Node
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &Node{Name: t.Name.Local, Attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("invalid xml: no root element")
+	}
+	return root, nil
+}
+
+// Value returns the text of the element and of its descendants, trimmed
+func (n *Node) Value() string {
+	var b strings.Builder
+	n.writeText(&b)
+	return strings.TrimSpace(b.String())
+}
+
+// writeText writes the text of the element and of its descendants in document order
+func (n *Node) writeText(b *strings.Builder) {
+	b.WriteString(n.Text)
+	for _, child := range n.Children {
+		child.writeText(b)
+	}
+}
+
+// Attr returns the value of an attribute
+func (n *Node) Attr(name string) (string, bool) {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// ToMap converts the document to a map keyed by the root element name. Attributes
+// become "@name" keys, repeated elements arrays, and the text of elements with
+// attributes or children a "#text" key; other elements become their text.
+func (n *Node) ToMap() map[string]interface{} {
+	return map[string]interface{}{n.Name: n.toValue()}
+}
+
+// toValue converts an element to a string or a map
+func (n *Node) toValue() interface{} {
+	text := strings.TrimSpace(n.Text)
+	if len(n.Attrs) == 0 && len(n.Children) == 0 {
+		return text
+	}
+
+	value := make(map[string]interface{}, len(n.Attrs)+len(n.Children))
+	for _, attr := range n.Attrs {
+		value["@"+attr.Name.Local] = attr.Value
+	}
+	for _, child := range n.Children {
+		childValue := child.toValue()
+		switch existing := value[child.Name].(type) {
+		case nil:
+			value[child.Name] = childValue
+		case []interface{}:
+			value[child.Name] = append(existing, childValue)
+		default:
+			value[child.Name] = []interface{}{existing, childValue}
+		}
+	}
+	if text != "" {
+		value["#text"] = text
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+package xmldata
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const notification = `<?xml version="1.0" encoding="UTF-8"?>
+<Notification id="n-1">
+  <Type>Notification</Type>
+  <Message>Order paid</Message>
+  <Item sku="A">First</Item>
+  <Item sku="B">Second</Item>
+</Notification>`
+
+func TestIsXML(t *testing.T) {
+	assert.True(t, IsXML("application/xml"))
+	assert.True(t, IsXML("text/xml; charset=utf-8"))
+	assert.True(t, IsXML("application/atom+xml"))
+	assert.False(t, IsXML("application/json"))
+	assert.False(t, IsXML(""))
+}
+
+func TestParse(t *testing.T) {
+	root, err := Parse([]byte(notification))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Notification", root.Name)
+	require.Len(t, root.Children, 4)
+	assert.Equal(t, "Order paid", root.Children[1].Value())
+
+	id, ok := root.Attr("id")
+	assert.True(t, ok)
+	assert.Equal(t, "n-1", id)
+
+	_, ok = root.Attr("missing")
+	assert.False(t, ok)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte(`<Notification><Type>`))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(``))
+	assert.Error(t, err)
+}
+
+func TestToMap(t *testing.T) {
+	root, err := Parse([]byte(notification))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(root.ToMap())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Notification": {
+			"@id": "n-1",
+			"Type": "Notification",
+			"Message": "Order paid",
+			"Item": [
+				{"@sku": "A", "#text": "First"},
+				{"@sku": "B", "#text": "Second"}
+			]
+		}
+	}`, string(data))
+}
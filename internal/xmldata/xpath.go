@@ -0,0 +1,135 @@
+package xmldata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Path is a compiled XPath expression. The supported subset is absolute location
+// paths of child (/) and descendant (//) steps, with element names or the * wildcard,
+// optionally ending with an @attribute step: /Notification/Type, //Amount, /Event/@id.
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// step is a step of a location path
+type step struct {
+	name       string
+	descendant bool
+	attribute  bool
+}
+
+// Compile parses an XPath expression
+func Compile(expr string) (*Path, error) {
+	if !strings.HasPrefix(expr, "/") {
+		return nil, fmt.Errorf("invalid xpath %q: must be an absolute path", expr)
+	}
+
+	path := &Path{expr: expr}
+	rest := expr
+	for rest != "" {
+		descendant := strings.HasPrefix(rest, "//")
+		if descendant {
+			rest = rest[2:]
+		} else {
+			rest = rest[1:]
+		}
+
+		name := rest
+		if i := strings.Index(rest, "/"); i >= 0 {
+			name, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+
+		s := step{name: name, descendant: descendant}
+		if strings.HasPrefix(name, "@") {
+			s.name = strings.TrimPrefix(name, "@")
+			s.attribute = true
+		}
+		if s.attribute && descendant {
+			return nil, fmt.Errorf("invalid xpath %q: an attribute step must be a child step", expr)
+		}
+		if !validName(s.name) && !(s.name == "*" && !s.attribute) {
+			return nil, fmt.Errorf("invalid xpath %q: invalid step %q", expr, name)
+		}
+		if len(path.steps) > 0 && path.steps[len(path.steps)-1].attribute {
+			return nil, fmt.Errorf("invalid xpath %q: an attribute step must be the last one", expr)
+		}
+		path.steps = append(path.steps, s)
+	}
+
+	return path, nil
+}
+
+// String returns the expression of the path
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Select returns the values of the elements or attributes selected in the document
+func (p *Path) Select(root *Node) []string {
+	nodes := []*Node{{Children: []*Node{root}}}
+
+	for _, s := range p.steps {
+		if s.attribute {
+			var values []string
+			for _, node := range nodes {
+				if value, ok := node.Attr(s.name); ok {
+					values = append(values, value)
+				}
+			}
+			return values
+		}
+
+		seen := make(map[*Node]bool)
+		var next []*Node
+		for _, node := range nodes {
+			for _, candidate := range candidates(node, s.descendant) {
+				if (s.name == "*" || candidate.Name == s.name) && !seen[candidate] {
+					seen[candidate] = true
+					next = append(next, candidate)
+				}
+			}
+		}
+		nodes = next
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		values = append(values, node.Value())
+	}
+	return values
+}
+
+// candidates returns the children of a node, or all its descendants
+func candidates(node *Node, descendant bool) []*Node {
+	if !descendant {
+		return node.Children
+	}
+
+	var nodes []*Node
+	for _, child := range node.Children {
+		nodes = append(nodes, child)
+		nodes = append(nodes, candidates(child, true)...)
+	}
+	return nodes
+}
+
+// validName reports whether a step name is a valid XML name (without namespace prefix)
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		letter := r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 127
+		if i == 0 && !letter {
+			return false
+		}
+		if !letter && r != '-' && r != '.' && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
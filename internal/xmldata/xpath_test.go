@@ -0,0 +1,48 @@
+package xmldata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileInvalid(t *testing.T) {
+	for _, expr := range []string{"", "Notification/Type", "/", "/Notification//", "//@id", "/Notification/@id/Type", "/@*", "/1Type"} {
+		_, err := Compile(expr)
+		assert.Error(t, err, "expected an error for %q", expr)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	root, err := Parse([]byte(notification))
+	require.NoError(t, err)
+
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{"/Notification/Type", []string{"Notification"}},
+		{"/Notification/@id", []string{"n-1"}},
+		{"//Item", []string{"First", "Second"}},
+		{"//Item/@sku", []string{"A", "B"}},
+		{"/Notification/*/@sku", []string{"A", "B"}},
+		{"/Type", []string{}},
+		{"/Notification/Missing", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			path, err := Compile(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expr, path.String())
+
+			values := path.Select(root)
+			if len(tt.expected) == 0 {
+				assert.Empty(t, values)
+			} else {
+				assert.Equal(t, tt.expected, values)
+			}
+		})
+	}
+}
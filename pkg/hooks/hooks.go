@@ -0,0 +1,96 @@
+// Package hooks defines the hooks observing the delivery lifecycle of the proxy's webhooks,
+// for code embedding or extending the proxy to register on its endpoints
+package hooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+)
+
+// Destination is the configuration of the destination of a delivery
+type Destination = config.DestinationConfig
+
+// Event describes a webhook at a point of its delivery lifecycle.
+// Destination, attempt and result fields are only set for forwarding events.
+type Event struct {
+	// Context is the context the webhook is forwarded within, carrying the values set by
+	// the caller of ForwardWebhook
+	Context context.Context
+
+	// ID identifies the delivery of the webhook to the destination
+	ID string
+
+	// WebhookID identifies the webhook the delivery belongs to, the same across its
+	// destinations and retries; empty for deliveries not started from a received webhook
+	WebhookID string
+
+	// Listener is the name of the listener the webhook was received on, identifying its
+	// tenant; empty when the proxy has no tenant listeners
+	Listener    string
+	Endpoint    string
+	Destination Destination
+	Body        []byte
+	Headers     map[string]string
+
+	// Attempt is the attempt number, starting at 1
+	Attempt     int
+	MaxAttempts int
+
+	// Result of the attempt, set for AfterForward, OnFailure and OnDeadLetter
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+
+	// Cached is set when the attempt reused a cached response instead of sending a request
+	Cached bool
+
+	// ResponseHeaders are the response headers captured by the destination's
+	// capture_headers, set for AfterForward when the destination answered with them
+	ResponseHeaders map[string]string
+
+	// Generation is the configuration generation of the endpoint, 0 when it is not tracked
+	Generation int64
+
+	// ReceivedAt is when the webhook was received
+	ReceivedAt time.Time
+}
+
+// Hook observes the delivery lifecycle of a handler's webhooks.
+// Hooks are called synchronously from the delivery goroutines and must be safe for concurrent use.
+type Hook interface {
+	// OnReceive is called once when a webhook is received, before it is forwarded
+	OnReceive(event *Event)
+
+	// BeforeForward is called before each attempt to deliver the webhook to a destination
+	BeforeForward(event *Event)
+
+	// AfterForward is called after each attempt, whatever its result
+	AfterForward(event *Event)
+
+	// OnFailure is called after each failed attempt
+	OnFailure(event *Event)
+
+	// OnDeadLetter is called when a delivery failed and all retries are exhausted
+	OnDeadLetter(event *Event)
+}
+
+// NopHook implements Hook with no-op methods, to be embedded by hooks
+// interested in only some events
+type NopHook struct{}
+
+// OnReceive does nothing
+func (NopHook) OnReceive(*Event) {}
+
+// BeforeForward does nothing
+func (NopHook) BeforeForward(*Event) {}
+
+// AfterForward does nothing
+func (NopHook) AfterForward(*Event) {}
+
+// OnFailure does nothing
+func (NopHook) OnFailure(*Event) {}
+
+// OnDeadLetter does nothing
+func (NopHook) OnDeadLetter(*Event) {}
@@ -0,0 +1,186 @@
+// Package logging bridges the proxy's logrus logger with log/slog, so that code embedding
+// the proxy logs through its configured pipeline, or attaches its own slog handlers, such
+// as an OpenTelemetry log bridge
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logrusHandler is a slog.Handler writing records through a logrus logger,
+// so code using log/slog shares the configured level, format and outputs
+type logrusHandler struct {
+	log    *logrus.Logger
+	fields logrus.Fields
+	group  string
+}
+
+// NewSlogHandler creates a slog.Handler backed by the given logrus logger
+func NewSlogHandler(log *logrus.Logger) slog.Handler {
+	return &logrusHandler{log: log, fields: logrus.Fields{}}
+}
+
+// NewSlogLogger creates a slog.Logger backed by the given logrus logger
+func NewSlogLogger(log *logrus.Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(log))
+}
+
+// Enabled reports whether the logrus logger handles records at the given level
+func (h *logrusHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.log.IsLevelEnabled(logrusLevel(level))
+}
+
+// Handle writes the record through the logrus logger
+func (h *logrusHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make(logrus.Fields, len(h.fields)+record.NumAttrs())
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addAttr(fields, h.group, attr)
+		return true
+	})
+
+	entry := h.log.WithContext(ctx).WithFields(fields)
+	if !record.Time.IsZero() {
+		entry = entry.WithTime(record.Time)
+	}
+	entry.Log(logrusLevel(record.Level), record.Message)
+	return nil
+}
+
+// WithAttrs returns a handler adding the given attributes to every record
+func (h *logrusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(logrus.Fields, len(h.fields)+len(attrs))
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+	for _, attr := range attrs {
+		addAttr(fields, h.group, attr)
+	}
+	return &logrusHandler{log: h.log, fields: fields, group: h.group}
+}
+
+// WithGroup returns a handler prefixing the keys of the following attributes with the group name
+func (h *logrusHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &logrusHandler{log: h.log, fields: h.fields, group: joinKey(h.group, name)}
+}
+
+// addAttr adds an attribute to the fields, flattening groups into dotted keys
+func addAttr(fields logrus.Fields, group string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if value.Kind() == slog.KindGroup {
+		prefix := group
+		if attr.Key != "" {
+			prefix = joinKey(group, attr.Key)
+		}
+		for _, child := range value.Group() {
+			addAttr(fields, prefix, child)
+		}
+		return
+	}
+
+	fields[joinKey(group, attr.Key)] = value.Any()
+}
+
+// joinKey joins a group name and a key
+func joinKey(group string, key string) string {
+	if group == "" {
+		return key
+	}
+	return group + "." + key
+}
+
+// slogHook is a logrus hook forwarding every entry to a slog.Handler
+type slogHook struct {
+	handler slog.Handler
+}
+
+// AddSlogHandler forwards every entry of the logrus logger to the given slog.Handler,
+// e.g. to export logs through OpenTelemetry. The handler must not write back to the
+// same logrus logger. Handlers are kept when the logger is reconfigured.
+func AddSlogHandler(log *logrus.Logger, handler slog.Handler) {
+	log.AddHook(&slogHook{handler: handler})
+}
+
+// Levels returns the levels the hook fires for
+func (h *slogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire converts the entry to a slog record and passes it to the handler
+func (h *slogHook) Fire(entry *logrus.Entry) error {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	level := slogLevel(entry.Level)
+	if !h.handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	record := slog.NewRecord(entry.Time, level, entry.Message, 0)
+	for _, key := range keys {
+		value := entry.Data[key]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		record.AddAttrs(slog.Any(key, value))
+	}
+
+	return h.handler.Handle(ctx, record)
+}
+
+// logrusLevel maps a slog level to a logrus level
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
+
+// slogLevel maps a logrus level to a slog level
+func slogLevel(level logrus.Level) slog.Level {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		return slog.LevelError
+	case logrus.WarnLevel:
+		return slog.LevelWarn
+	case logrus.InfoLevel:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// IsSlogHook reports whether a logrus hook was added with AddSlogHandler
+func IsSlogHook(hook logrus.Hook) bool {
+	_, ok := hook.(*slogHook)
+	return ok
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.InfoLevel)
+
+	slogger := NewSlogLogger(log).With("component", "test").WithGroup("request")
+
+	// Records below the logrus level are dropped
+	slogger.Debug("Debug message")
+	assert.Empty(t, buf.String())
+
+	slogger.Warn("Slow request", "path", "/webhook", slog.Group("timing", "duration_ms", 120))
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "warning", entry["level"])
+	assert.Equal(t, "Slow request", entry["msg"])
+	assert.Equal(t, "test", entry["component"])
+	assert.Equal(t, "/webhook", entry["request.path"])
+	assert.Equal(t, float64(120), entry["request.timing.duration_ms"])
+}
+
+func TestAddSlogHandler(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(&bytes.Buffer{})
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	var buf bytes.Buffer
+	AddSlogHandler(log, slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	log.SetLevel(logrus.DebugLevel)
+
+	// Records below the handler level are dropped
+	log.Debug("Debug message")
+	assert.Empty(t, buf.String())
+
+	log.WithFields(logrus.Fields{
+		"destination": "https://example.com",
+		"error":       errors.New("connection refused"),
+	}).Error("Webhook delivery failed")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "Webhook delivery failed", record["msg"])
+	assert.Equal(t, "https://example.com", record["destination"])
+	assert.Equal(t, "connection refused", record["error"])
+}
+
+func TestSlogHandlerEnabled(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.WarnLevel)
+
+	handler := NewSlogHandler(log)
+	assert.False(t, handler.Enabled(context.Background(), slog.LevelInfo))
+	assert.True(t, handler.Enabled(context.Background(), slog.LevelWarn))
+	assert.True(t, handler.Enabled(context.Background(), slog.LevelError))
+}
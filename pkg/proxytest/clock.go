@@ -0,0 +1,71 @@
+package proxytest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time the retry delays of a Proxy are waited on. It only moves when
+// advanced, so that a test decides when each retry is attempted.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*clockTimer
+}
+
+// clockTimer is a retry delay waiting for the clock to reach its deadline
+type clockTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewClock returns a clock starting at the given time
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the time of the clock
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel receiving the time of the clock once it is advanced by d.
+// A non-positive d fires at once.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &clockTimer{deadline: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		timer.ch <- c.now
+		return timer.ch
+	}
+	c.timers = append(c.timers, timer)
+	return timer.ch
+}
+
+// Advance moves the clock forward, firing the timers whose deadline it reaches
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.deadline.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// Pending returns the number of timers waiting for the clock to be advanced
+func (c *Clock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
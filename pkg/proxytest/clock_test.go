@@ -0,0 +1,43 @@
+package proxytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+
+	first, second := c.After(time.Second), c.After(time.Minute)
+	assert.Equal(t, 2, c.Pending())
+
+	// Timers fire once the clock reaches their deadline
+	c.Advance(time.Second)
+	select {
+	case at := <-first:
+		assert.Equal(t, start.Add(time.Second), at)
+	default:
+		t.Fatal("Expected the first timer to fire")
+	}
+	select {
+	case <-second:
+		t.Fatal("Expected the second timer to wait")
+	default:
+	}
+	assert.Equal(t, 1, c.Pending())
+
+	c.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour+time.Second), <-second)
+	assert.Equal(t, start.Add(time.Hour+time.Second), c.Now())
+	assert.Zero(t, c.Pending())
+
+	// Non-positive delays fire at once
+	select {
+	case <-c.After(0):
+	default:
+		t.Fatal("Expected a zero delay to fire at once")
+	}
+}
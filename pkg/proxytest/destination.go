@@ -0,0 +1,103 @@
+package proxytest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Request is a request received by a destination
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// Destination is an in-memory HTTP destination. It records the requests it receives and
+// answers them with 200 OK, the statuses given to Respond, or its handler.
+type Destination struct {
+	host string
+
+	mu       sync.Mutex
+	requests []Request
+	statuses []int
+	handler  http.Handler
+}
+
+// newDestination returns the destination of a host
+func newDestination(host string) *Destination {
+	return &Destination{host: host}
+}
+
+// URL returns the base URL of the destination, for the configuration
+func (d *Destination) URL() string {
+	return "http://" + d.host
+}
+
+// Respond answers the next requests with the statuses, in order, then with 200 OK again
+func (d *Destination) Respond(statuses ...int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statuses = append(d.statuses, statuses...)
+}
+
+// Handle answers the requests with the handler, once the statuses given to Respond are used
+func (d *Destination) Handle(handler http.Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handler = handler
+}
+
+// Requests returns the requests received so far, in order
+func (d *Destination) Requests() []Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Request(nil), d.requests...)
+}
+
+// Reset forgets the requests received and the statuses not used yet
+func (d *Destination) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = nil
+	d.statuses = nil
+}
+
+// serve records a request and returns its response
+func (d *Destination) serve(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	d.mu.Lock()
+	d.requests = append(d.requests, Request{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: body})
+
+	status, handler := http.StatusOK, d.handler
+	if len(d.statuses) > 0 {
+		status, handler = d.statuses[0], nil
+		d.statuses = d.statuses[1:]
+	}
+	d.mu.Unlock()
+
+	// The handler reads the body as sent
+	w := httptest.NewRecorder()
+	if handler != nil {
+		served := req.Clone(req.Context())
+		served.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, served)
+	} else {
+		w.WriteHeader(status)
+	}
+
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
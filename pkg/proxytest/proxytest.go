@@ -0,0 +1,160 @@
+// Package proxytest runs the proxy in process for tests, with in-memory HTTP destinations
+// and a clock controlling the retry delays, so that tests of a configuration, or of code
+// embedding or extending the proxy, are fast and deterministic
+package proxytest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/server"
+	"github.com/flemzord/webhook-proxy/pkg/hooks"
+	"github.com/sirupsen/logrus"
+)
+
+// settleTimeout bounds the wait for the deliveries to settle
+const settleTimeout = 5 * time.Second
+
+// settleInterval is the period at which Settle checks the deliveries
+const settleInterval = time.Millisecond
+
+// Proxy is the proxy running a configuration in process, without listening. Its HTTP
+// destinations are in-memory destinations, one per host, and its retry delays are waited
+// on its clock.
+type Proxy struct {
+	t       testing.TB
+	harness *server.Harness
+	clock   *Clock
+
+	mu           sync.Mutex
+	destinations map[string]*Destination
+}
+
+// New starts the proxy for a YAML configuration, loaded like a configuration file, and
+// stops it with the test. The requests to the HTTP destinations never reach the network:
+// they are received by the in-memory destination of their host. The hooks are registered
+// on every endpoint. Logs are discarded.
+func New(t testing.TB, configYAML string, lifecycleHooks ...hooks.Hook) *Proxy {
+	t.Helper()
+
+	cfg, err := config.Parse([]byte(configYAML))
+	if err != nil {
+		t.Fatalf("proxytest: %v", err)
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	p := &Proxy{t: t, clock: NewClock(time.Now()), destinations: make(map[string]*Destination)}
+	harness, err := server.NewHarnessWithOptions(cfg, log, server.HarnessOptions{
+		Transport:  transport(p.roundTrip),
+		RetryTimer: p.clock.After,
+		Hooks:      lifecycleHooks,
+	})
+	if err != nil {
+		t.Fatalf("proxytest: %v", err)
+	}
+	p.harness = harness
+	t.Cleanup(func() {
+		if err := harness.Close(); err != nil {
+			t.Errorf("proxytest: %v", err)
+		}
+	})
+	return p
+}
+
+// Destination returns the in-memory destination of a host, such as example.com for the
+// destination URL https://example.com/webhook
+func (p *Proxy) Destination(host string) *Destination {
+	host = strings.ToLower(host)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	d, ok := p.destinations[host]
+	if !ok {
+		d = newDestination(host)
+		p.destinations[host] = d
+	}
+	return d
+}
+
+// Clock returns the clock the retry delays are waited on
+func (p *Proxy) Clock() *Clock {
+	return p.clock
+}
+
+// Send posts a webhook to the endpoint with the path, and returns the proxy's response.
+// Its deliveries are started by the time it returns; Settle waits for them.
+func (p *Proxy) Send(path string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	return w
+}
+
+// ServeHTTP serves a request as the proxy would, on any of its routes
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.harness.ServeHTTP(w, r)
+}
+
+// Settle waits until every delivery started is done, or waits for a retry on the clock.
+// It fails the test when the deliveries do not settle in time, such as deliveries held by
+// a maintenance window or a concurrency limit.
+func (p *Proxy) Settle() {
+	p.t.Helper()
+
+	deadline := time.Now().Add(settleTimeout)
+	for {
+		inFlight, pending := p.harness.InFlight(), p.clock.Pending()
+		if inFlight == int64(pending) {
+			return
+		}
+		if time.Now().After(deadline) {
+			p.t.Fatalf("proxytest: deliveries not settled after %s: %d in flight, %d waiting for a retry", settleTimeout, inFlight, pending)
+		}
+		time.Sleep(settleInterval)
+	}
+}
+
+// Retry advances the clock by d, attempting the retries due by then, and waits for the
+// deliveries to settle again
+func (p *Proxy) Retry(d time.Duration) {
+	p.t.Helper()
+	p.clock.Advance(d)
+	p.Settle()
+}
+
+// Metrics returns the metrics of an endpoint, or of its pipeline, as served on /metrics
+func (p *Proxy) Metrics(path string) map[string]interface{} {
+	return p.harness.Metrics(path)
+}
+
+// roundTrip delivers a request to the destination of its host
+func (p *Proxy) roundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if host == "" {
+		return nil, fmt.Errorf("proxytest: request without host: %s", req.URL)
+	}
+	return p.Destination(host).serve(req)
+}
+
+// transport is a function serving as a round tripper
+type transport func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls the function
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t(req)
+}
@@ -0,0 +1,128 @@
+package proxytest
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/pkg/hooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+endpoints:
+  - path: "/webhook"
+    destinations:
+      - url: "https://billing.example.com/hooks"
+        retries: 2
+        retry_delay: 1m
+      - url: "https://audit.example.com/hooks"
+        filters:
+          - header: "X-Event"
+            equals: "paid"
+`
+
+func TestProxyDelivers(t *testing.T) {
+	p := New(t, testConfig)
+
+	w := p.Send("/webhook", []byte(`{"id":1}`), map[string]string{"Content-Type": "application/json", "X-Event": "paid"})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	p.Settle()
+
+	for _, host := range []string{"billing.example.com", "AUDIT.example.com"} {
+		requests := p.Destination(host).Requests()
+		require.Len(t, requests, 1, host)
+		assert.Equal(t, http.MethodPost, requests[0].Method)
+		assert.Equal(t, "https://"+p.Destination(host).host+"/hooks", requests[0].URL)
+		assert.Equal(t, "application/json", requests[0].Header.Get("Content-Type"))
+		assert.Equal(t, `{"id":1}`, string(requests[0].Body))
+	}
+
+	// Filters apply as configured
+	p.Send("/webhook", []byte(`{"id":2}`), map[string]string{"X-Event": "created"})
+	p.Settle()
+	assert.Len(t, p.Destination("billing.example.com").Requests(), 2)
+	assert.Len(t, p.Destination("audit.example.com").Requests(), 1)
+}
+
+func TestProxyRetriesOnClock(t *testing.T) {
+	p := New(t, testConfig)
+	billing := p.Destination("billing.example.com")
+	billing.Respond(http.StatusServiceUnavailable, http.StatusBadGateway)
+
+	p.Send("/webhook", []byte(`{}`), nil)
+	p.Settle()
+	assert.Len(t, billing.Requests(), 1)
+	assert.Equal(t, 1, p.Clock().Pending(), "the retry waits for the clock")
+
+	// Nothing is retried before the delay is over
+	p.Retry(59 * time.Second)
+	assert.Len(t, billing.Requests(), 1)
+
+	p.Retry(time.Second)
+	assert.Len(t, billing.Requests(), 2)
+	p.Retry(time.Minute)
+	assert.Len(t, billing.Requests(), 3)
+	assert.Zero(t, p.Clock().Pending())
+
+	metrics := p.Metrics("/webhook")
+	assert.Equal(t, int64(1), metrics["successful_requests"])
+	assert.Equal(t, int64(2), metrics["failed_requests"])
+}
+
+// deadLetters records the dead-lettered deliveries
+type deadLetters struct {
+	hooks.NopHook
+	mu     sync.Mutex
+	events []*hooks.Event
+}
+
+func (h *deadLetters) OnDeadLetter(event *hooks.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+}
+
+func TestProxyHooks(t *testing.T) {
+	hook := &deadLetters{}
+	p := New(t, testConfig, hook)
+	p.Destination("billing.example.com").Respond(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+
+	p.Send("/webhook", []byte(`{}`), nil)
+	p.Settle()
+	p.Retry(time.Minute)
+	p.Retry(time.Minute)
+
+	// The hook sees the delivery dead-lettered once its retries are exhausted
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	require.Len(t, hook.events, 1)
+	assert.Equal(t, "/webhook", hook.events[0].Endpoint)
+	assert.Equal(t, "https://billing.example.com/hooks", hook.events[0].Destination.URL)
+	assert.Equal(t, 3, hook.events[0].Attempt)
+}
+
+func TestDestinationHandle(t *testing.T) {
+	p := New(t, testConfig)
+	billing := p.Destination("billing.example.com")
+	billing.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == `{"fail":true}` {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	p.Send("/webhook", []byte(`{"fail":true}`), nil)
+	p.Send("/webhook", []byte(`{"fail":false}`), nil)
+	p.Settle()
+	assert.Equal(t, 1, p.Clock().Pending())
+	assert.Equal(t, int64(1), p.Metrics("/webhook")["successful_requests"])
+
+	billing.Reset()
+	assert.Empty(t, billing.Requests())
+}
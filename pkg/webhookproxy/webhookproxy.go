@@ -0,0 +1,63 @@
+// Package webhookproxy embeds the proxy in another program. It re-exports the configuration
+// and the server of the internal packages, the single implementation the binary runs too,
+// so that embedders get every fix without a copy drifting apart.
+package webhookproxy
+
+import (
+	"github.com/flemzord/webhook-proxy/internal/config"
+	"github.com/flemzord/webhook-proxy/internal/events"
+	"github.com/flemzord/webhook-proxy/internal/logger"
+	"github.com/flemzord/webhook-proxy/internal/server"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the configuration of the proxy, as loaded from a configuration file
+type Config = config.Config
+
+// Server is the proxy serving the configured endpoints. Hooks are registered with AddHook
+// and lifecycle subscribers with Subscribe, both before Start.
+type Server = server.Server
+
+// ServeFunc serves the proxy's handler on an address, like http.ListenAndServe, for
+// StartWithServerFunc
+type ServeFunc = server.HTTPServerFunc
+
+// Event is a lifecycle event of the proxy, received by the subscribers of Server.Subscribe
+type Event = events.Event
+
+// EventType identifies a kind of lifecycle event
+type EventType = events.Type
+
+// Subscriber receives lifecycle events. Subscribers are called synchronously and must not
+// block.
+type Subscriber = events.Subscriber
+
+// The types of lifecycle events
+const (
+	DeliveryFailed     = events.DeliveryFailed
+	DestinationPaused  = events.DestinationPaused
+	DestinationResumed = events.DestinationResumed
+	QueueHighWater     = events.QueueHighWater
+)
+
+// LoadConfig loads, validates and completes with defaults the configuration file at path
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadConfig(path)
+}
+
+// ParseConfig parses a YAML configuration like LoadConfig
+func ParseConfig(data []byte) (*Config, error) {
+	return config.Parse(data)
+}
+
+// NewLogger creates a logger configured by the logging section of the configuration
+func NewLogger(cfg *Config) *logrus.Logger {
+	log := logger.NewLogger()
+	logger.ConfigureLogger(log, cfg.Logging)
+	return log
+}
+
+// New creates the proxy for the configuration, logging to log
+func New(cfg *Config, log *logrus.Logger) *Server {
+	return server.NewServer(cfg, log)
+}
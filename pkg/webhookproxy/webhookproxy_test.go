@@ -0,0 +1,79 @@
+package webhookproxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flemzord/webhook-proxy/pkg/hooks"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forwarded reports the attempts made by the proxy
+type forwarded struct {
+	hooks.NopHook
+	attempts chan *hooks.Event
+}
+
+func (h *forwarded) AfterForward(event *hooks.Event) { h.attempts <- event }
+
+func TestEmbeddedServer(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	cfg, err := ParseConfig([]byte(`
+endpoints:
+  - path: "/webhook"
+    destinations:
+      - url: "` + destination.URL + `"
+`))
+	require.NoError(t, err)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	server := New(cfg, log)
+
+	hook := &forwarded{attempts: make(chan *hooks.Event, 1)}
+	server.AddHook(hook)
+	cancel := server.Subscribe(func(Event) {}, DeliveryFailed)
+	defer cancel()
+
+	// The embedder serves the proxy's handler itself
+	var handler http.Handler
+	require.NoError(t, server.StartWithServerFunc(func(_ string, h http.Handler) error {
+		handler = h
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	select {
+	case event := <-hook.attempts:
+		assert.Equal(t, "/webhook", event.Endpoint)
+		assert.Equal(t, http.StatusOK, event.StatusCode)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the webhook to be forwarded")
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+logging:
+  level: debug
+endpoints:
+  - path: "/webhook"
+    destinations:
+      - url: "https://example.com"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, logrus.DebugLevel, NewLogger(cfg).GetLevel())
+}